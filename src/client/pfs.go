@@ -1,10 +1,12 @@
 package client
 
 import (
+	"fmt"
 	"io"
 	"math"
 
 	"github.com/pachyderm/pachyderm/src/client/pfs"
+	"go.pedge.io/pb/go/google/protobuf"
 	"go.pedge.io/proto/stream"
 	"golang.org/x/net/context"
 )
@@ -60,6 +62,25 @@ func (c APIClient) CreateRepo(repoName string) error {
 	return sanitizeErr(err)
 }
 
+// CreateRepoIfNotExists is like CreateRepo except it succeeds if the Repo
+// already exists with the same provenance. It returns an error if the Repo
+// exists with different provenance.
+func (c APIClient) CreateRepoIfNotExists(repoName string, provenance []string) error {
+	var provRepos []*pfs.Repo
+	for _, provName := range provenance {
+		provRepos = append(provRepos, NewRepo(provName))
+	}
+	_, err := c.PfsAPIClient.CreateRepo(
+		context.Background(),
+		&pfs.CreateRepoRequest{
+			Repo:              NewRepo(repoName),
+			Provenance:        provRepos,
+			CreateIfNotExists: true,
+		},
+	)
+	return sanitizeErr(err)
+}
+
 // InspectRepo returns info about a specific Repo.
 func (c APIClient) InspectRepo(repoName string) (*pfs.RepoInfo, error) {
 	repoInfo, err := c.PfsAPIClient.InspectRepo(
@@ -74,6 +95,43 @@ func (c APIClient) InspectRepo(repoName string) (*pfs.RepoInfo, error) {
 	return repoInfo, nil
 }
 
+// InspectRepoDAG returns info about a specific Repo along with a page of its
+// commit DAG, parents come before their children.
+// offset is the number of nodes to skip and limit is the maximum number of
+// nodes to return, 0 means the server's default. RepoInfo.DagNextOffset is
+// the offset of the next page, 0 once there are no more pages.
+func (c APIClient) InspectRepoDAG(repoName string, offset uint64, limit uint64) (*pfs.RepoInfo, error) {
+	repoInfo, err := c.PfsAPIClient.InspectRepo(
+		context.Background(),
+		&pfs.InspectRepoRequest{
+			Repo:       NewRepo(repoName),
+			IncludeDag: true,
+			DagOffset:  offset,
+			DagLimit:   limit,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return repoInfo, nil
+}
+
+// InspectRepoBranchHeads returns info about a specific Repo along with the
+// commit at the head of each of its branches, keyed by branch name.
+func (c APIClient) InspectRepoBranchHeads(repoName string) (*pfs.RepoInfo, error) {
+	repoInfo, err := c.PfsAPIClient.InspectRepo(
+		context.Background(),
+		&pfs.InspectRepoRequest{
+			Repo:               NewRepo(repoName),
+			IncludeBranchHeads: true,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return repoInfo, nil
+}
+
 // ListRepo returns info about all Repos.
 // provenance specifies a set of provenance repos, only repos which have ALL of
 // the specified repos as provenance will be returned unless provenance is nil
@@ -137,6 +195,44 @@ func (c APIClient) StartCommit(repoName string, parentCommit string, branch stri
 	return commit, nil
 }
 
+// ReserveCommit allocates a Commit ID without starting the Commit. It lets
+// several writers agree on the Commit they'll write to before any of them
+// starts it, one of them then starts it with StartReservedCommit.
+// Reservations are held in memory, they're lost if pachd restarts or if the
+// repo's shards move to another server, and they expire if the Commit isn't
+// started within COMMIT_RESERVATION_TIMEOUT_SECONDS.
+func (c APIClient) ReserveCommit(repoName string) (*pfs.Commit, error) {
+	commit, err := c.PfsAPIClient.ReserveCommit(
+		context.Background(),
+		&pfs.ReserveCommitRequest{
+			Repo: NewRepo(repoName),
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commit, nil
+}
+
+// StartReservedCommit is like StartCommit except that the new Commit gets
+// commitID, which must have been returned by ReserveCommit. Each reserved
+// ID can only be started once.
+func (c APIClient) StartReservedCommit(repoName string, commitID string, parentCommit string, branch string) (*pfs.Commit, error) {
+	commit, err := c.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfs.StartCommitRequest{
+			Repo:     NewRepo(repoName),
+			ID:       commitID,
+			ParentID: parentCommit,
+			Branch:   branch,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commit, nil
+}
+
 // FinishCommit ends the process of committing data to a Repo and persists the
 // Commit. Once a Commit is finished the data becomes immutable and future
 // attempts to write to it with PutFile will error.
@@ -150,6 +246,50 @@ func (c APIClient) FinishCommit(repoName string, commitID string) error {
 	return sanitizeErr(err)
 }
 
+// FinishCommitWithDescription is like FinishCommit except it also sets a
+// human readable description of the Commit, like a git commit message. The
+// description is returned by InspectCommit and ListCommit.
+func (c APIClient) FinishCommitWithDescription(repoName string, commitID string, description string) error {
+	_, err := c.PfsAPIClient.FinishCommit(
+		context.Background(),
+		&pfs.FinishCommitRequest{
+			Commit:      NewCommit(repoName, commitID),
+			Description: description,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// FinishCommitWithAnnotations is like FinishCommit except it also sets
+// key/value annotations on the Commit, see ListCommitByAnnotation.
+func (c APIClient) FinishCommitWithAnnotations(repoName string, commitID string, annotations map[string]string) error {
+	_, err := c.PfsAPIClient.FinishCommit(
+		context.Background(),
+		&pfs.FinishCommitRequest{
+			Commit:      NewCommit(repoName, commitID),
+			Annotations: annotations,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// FinishCommitIfParent is like FinishCommit except it only finishes the
+// Commit if its parent is expectedParentID and no other Commit has been
+// started on its branch since it was. Otherwise it returns a conflict error
+// and the Commit is left open, so callers can use it to avoid lost updates.
+// expectedParentID may be a branch name, in which case it refers to the
+// branch's head.
+func (c APIClient) FinishCommitIfParent(repoName string, commitID string, expectedParentID string) error {
+	_, err := c.PfsAPIClient.FinishCommit(
+		context.Background(),
+		&pfs.FinishCommitRequest{
+			Commit:         NewCommit(repoName, commitID),
+			ExpectedParent: NewCommit(repoName, expectedParentID),
+		},
+	)
+	return sanitizeErr(err)
+}
+
 // CancelCommit ends the process of committing data to a repo. It differs from
 // FinishCommit in that the Commit will not be used as a source for downstream
 // pipelines. CancelCommit is used primarily by PPS for the output commits of
@@ -179,6 +319,21 @@ func (c APIClient) InspectCommit(repoName string, commitID string) (*pfs.CommitI
 	return commitInfo, nil
 }
 
+// InspectBranch returns info about the commit at the head of a branch.
+func (c APIClient) InspectBranch(repoName string, branch string) (*pfs.CommitInfo, error) {
+	commitInfo, err := c.PfsAPIClient.InspectBranch(
+		context.Background(),
+		&pfs.InspectBranchRequest{
+			Repo:   NewRepo(repoName),
+			Branch: branch,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commitInfo, nil
+}
+
 // ListCommit returns info about multiple commits.
 // repoNames defines a set of Repos to consider commits from, if repoNames is left
 // nil or empty then the result will be empty.
@@ -220,6 +375,142 @@ func (c APIClient) ListCommit(repoNames []string, fromCommitIDs []string,
 	return commitInfos.CommitInfo, nil
 }
 
+// ListCommitByAnnotation returns info about the Commits in repoNames that
+// have all of the given annotations.
+func (c APIClient) ListCommitByAnnotation(repoNames []string, annotations map[string]string) ([]*pfs.CommitInfo, error) {
+	var repos []*pfs.Repo
+	for _, repoName := range repoNames {
+		repos = append(repos, NewRepo(repoName))
+	}
+	commitInfos, err := c.PfsAPIClient.ListCommit(
+		context.Background(),
+		&pfs.ListCommitRequest{
+			Repo:        repos,
+			Annotations: annotations,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commitInfos.CommitInfo, nil
+}
+
+// ListCommitOnBranch returns info about the Commits in repoName that are
+// reachable from branch but not from baseBranch, i.e. the commits that are
+// unique to branch. If baseBranch is "" all commits reachable from branch are
+// returned.
+func (c APIClient) ListCommitOnBranch(repoName string, branch string, baseBranch string) ([]*pfs.CommitInfo, error) {
+	commitInfos, err := c.PfsAPIClient.ListCommit(
+		context.Background(),
+		&pfs.ListCommitRequest{
+			Repo:       []*pfs.Repo{NewRepo(repoName)},
+			Branch:     branch,
+			BaseBranch: baseBranch,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commitInfos.CommitInfo, nil
+}
+
+// CommitChangedFiles returns the regular files that a Commit added or
+// modified and the paths it deleted, sorted by path. Deleted paths may be
+// directories, the files under a deleted directory are returned as deleted
+// as well.
+func (c APIClient) CommitChangedFiles(repoName string, commitID string) ([]*pfs.FileChange, error) {
+	fileChanges, err := c.PfsAPIClient.CommitChangedFiles(
+		context.Background(),
+		&pfs.CommitChangedFilesRequest{
+			Commit: NewCommit(repoName, commitID),
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return fileChanges.FileChange, nil
+}
+
+// CommitDiff returns the regular files that differ between two Commits in
+// repoName, sorted by path. Files only in toCommitID are added, files only
+// in fromCommitID are deleted and files in both with different content are
+// modified. The Commits can be on different branches, they don't need a
+// common ancestor.
+func (c APIClient) CommitDiff(repoName string, fromCommitID string, toCommitID string) ([]*pfs.FileChange, error) {
+	fileChanges, err := c.PfsAPIClient.CommitDiff(
+		context.Background(),
+		&pfs.CommitDiffRequest{
+			FromCommit: NewCommit(repoName, fromCommitID),
+			ToCommit:   NewCommit(repoName, toCommitID),
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return fileChanges.FileChange, nil
+}
+
+// CommitManifest returns the path, size and block refs hash of every regular
+// file in a Commit, sorted by path, without reading their content. Comparing
+// the manifests of two Commits shows which files differ between them, files
+// whose content is the same may still differ if it was written differently.
+func (c APIClient) CommitManifest(repoName string, commitID string) ([]*pfs.ManifestEntry, error) {
+	commitManifestClient, err := c.PfsAPIClient.CommitManifest(
+		context.Background(),
+		&pfs.CommitManifestRequest{
+			Commit: NewCommit(repoName, commitID),
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	var result []*pfs.ManifestEntry
+	for {
+		manifestEntry, err := commitManifestClient.Recv()
+		if err == io.EOF {
+			return result, nil
+		}
+		if err != nil {
+			return nil, sanitizeErr(err)
+		}
+		result = append(result, manifestEntry)
+	}
+}
+
+// PackCommit rewrites the files in a finished Commit that are no bigger than
+// maxFileSize bytes so that they share blocks, which makes reading lots of
+// small files cheaper. maxFileSize 0 uses a default of 64KB. The files'
+// content doesn't change. It returns the number of files packed.
+// Packing only reduces the number of blocks the Commit refers to, it doesn't
+// free any space: the blocks the files were in stay in the block store, which
+// has no garbage collection, and packing writes new blocks on top of them.
+func (c APIClient) PackCommit(repoName string, commitID string, maxFileSize uint64) (uint64, error) {
+	response, err := c.PfsAPIClient.PackCommit(
+		context.Background(),
+		&pfs.PackCommitRequest{
+			Commit:           NewCommit(repoName, commitID),
+			MaxFileSizeBytes: maxFileSize,
+		},
+	)
+	if err != nil {
+		return 0, sanitizeErr(err)
+	}
+	return response.FilesPacked, nil
+}
+
+// OpenCommits returns info about every open commit in the cluster, across
+// all repos, the most recently started first.
+func (c APIClient) OpenCommits() ([]*pfs.CommitInfo, error) {
+	commitInfos, err := c.PfsAPIClient.OpenCommits(
+		context.Background(),
+		&pfs.OpenCommitsRequest{},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commitInfos.CommitInfo, nil
+}
+
 // ListBranch lists the active branches on a Repo.
 func (c APIClient) ListBranch(repoName string) ([]*pfs.CommitInfo, error) {
 	commitInfos, err := c.PfsAPIClient.ListBranch(
@@ -246,6 +537,31 @@ func (c APIClient) DeleteCommit(repoName string, commitID string) error {
 	return sanitizeErr(err)
 }
 
+// SoftDeleteCommit marks a commit as deleted, deleted commits aren't returned
+// by ListCommit. The commit can be restored with RestoreCommit until it's
+// garbage collected.
+func (c APIClient) SoftDeleteCommit(repoName string, commitID string) error {
+	_, err := c.PfsAPIClient.DeleteCommit(
+		context.Background(),
+		&pfs.DeleteCommitRequest{
+			Commit: NewCommit(repoName, commitID),
+			Soft:   true,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// RestoreCommit restores a commit that was deleted with SoftDeleteCommit.
+func (c APIClient) RestoreCommit(repoName string, commitID string) error {
+	_, err := c.PfsAPIClient.RestoreCommit(
+		context.Background(),
+		&pfs.RestoreCommitRequest{
+			Commit: NewCommit(repoName, commitID),
+		},
+	)
+	return sanitizeErr(err)
+}
+
 // FlushCommit blocks until all of the commits which have a set of commits as
 // provenance have finished. For commits to be considered they must have all of
 // the specified commits as provenance. This in effect waits for all of the
@@ -386,8 +702,223 @@ func (c APIClient) PutFileWithDelimiter(repoName string, commitID string, path s
 	return int(written), err
 }
 
+// PutFileAt writes the data from reader to a file in PFS at offset, the data
+// overwrites what was there rather than being appended. Writing past the end
+// of the file fills the gap with zeros.
+func (c APIClient) PutFileAt(repoName string, commitID string, path string, offset uint64, reader io.Reader) (_ int, retErr error) {
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, pfs.Delimiter_NONE, "")
+	if err != nil {
+		return 0, sanitizeErr(err)
+	}
+	writer.request.OffsetBytes = &google_protobuf.UInt64Value{Value: offset}
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	written, err := io.Copy(writer, reader)
+	return int(written), err
+}
+
+// PutFileWithXattrs writes a file to PFS from a reader and sets xattrs on
+// it, xattrs are extended attributes that are returned by InspectFile.
+func (c APIClient) PutFileWithXattrs(repoName string, commitID string, path string,
+	xattrs map[string]string, reader io.Reader) (_ int, retErr error) {
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, pfs.Delimiter_LINE, "")
+	if err != nil {
+		return 0, sanitizeErr(err)
+	}
+	writer.request.Xattrs = xattrs
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	written, err := io.Copy(writer, reader)
+	return int(written), err
+}
+
+// PutFileWithContentType writes a file to PFS from a reader, the write fails
+// with "validation failed" if the data isn't valid for contentType, e.g.
+// application/x-ndjson for JSON lines.
+func (c APIClient) PutFileWithContentType(repoName string, commitID string, path string,
+	contentType string, reader io.Reader) (_ int, retErr error) {
+	writer, err := c.newPutFileWriteCloser(repoName, commitID, path, pfs.Delimiter_LINE, "")
+	if err != nil {
+		return 0, sanitizeErr(err)
+	}
+	writer.request.ContentType = contentType
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	written, err := io.Copy(writer, reader)
+	return int(written), err
+}
+
+// SetXattr sets an extended attribute on a file without rewriting its
+// content, an empty value removes the attribute.
+func (c APIClient) SetXattr(repoName string, commitID string, path string, name string, value string) error {
+	_, err := c.PfsAPIClient.SetXattr(
+		context.Background(),
+		&pfs.SetXattrRequest{
+			File:  NewFile(repoName, commitID, path),
+			Name:  name,
+			Value: value,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// FileCompareAndSwap replaces the content of a file in an open commit with
+// value if the hex encoded SHA-256 of its current content is expectedHash,
+// an empty expectedHash means the file mustn't exist. It returns whether the
+// content was replaced and the hash of the file's content afterwards, which
+// is what the next swap should expect.
+func (c APIClient) FileCompareAndSwap(repoName string, commitID string, path string, expectedHash string, value []byte) (bool, string, error) {
+	response, err := c.PfsAPIClient.FileCompareAndSwap(
+		context.Background(),
+		&pfs.FileCompareAndSwapRequest{
+			File:         NewFile(repoName, commitID, path),
+			ExpectedHash: expectedHash,
+			Value:        value,
+		},
+	)
+	if err != nil {
+		return false, "", sanitizeErr(err)
+	}
+	return response.Swapped, response.Hash, nil
+}
+
+// SetImmutable makes a file immutable. Once it is, PutFile and DeleteFile
+// return an error for the file, in commitID and in every commit descended
+// from it, and directories containing the file can't be deleted either.
+// A commit started from another commit can't have it as its parent. A file
+// that was put with a TTL still expires.
+func (c APIClient) SetImmutable(repoName string, commitID string, path string) error {
+	_, err := c.PfsAPIClient.SetImmutable(
+		context.Background(),
+		&pfs.SetImmutableRequest{
+			File: NewFile(repoName, commitID, path),
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// GetXattr returns the value of one of a file's extended attributes.
+func (c APIClient) GetXattr(repoName string, commitID string, path string, name string) (string, error) {
+	value, err := c.PfsAPIClient.GetXattr(
+		context.Background(),
+		&pfs.GetXattrRequest{
+			File: NewFile(repoName, commitID, path),
+			Name: name,
+		},
+	)
+	if err != nil {
+		return "", sanitizeErr(err)
+	}
+	return value.Value, nil
+}
+
+// PutFileURL writes the content at url to a file in PFS, the content is
+// fetched by the server rather than passing through the client.
+// headers are sent with the request for url, e.g. to authenticate with the
+// source. maxBytes is the most data that will be fetched, 0 means no limit.
+// Only http and https URLs are supported. The server won't fetch from
+// loopback, private or link local addresses unless it was started with
+// DENY_PRIVATE_URLS=false.
+func (c APIClient) PutFileURL(repoName string, commitID string, path string, url string,
+	headers map[string]string, maxBytes uint64) error {
+	_, err := c.PfsAPIClient.PutFileURL(
+		context.Background(),
+		&pfs.PutFileURLRequest{
+			File:      NewFile(repoName, commitID, path),
+			Url:       url,
+			Headers:   headers,
+			MaxBytes:  maxBytes,
+			Delimiter: pfs.Delimiter_LINE,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// PutFileMulti appends values to a file in order as a single write. The
+// values are contiguous in the file, concurrent writes to the same file will
+// land either before or after all of them.
+func (c APIClient) PutFileMulti(repoName string, commitID string, path string, values [][]byte) error {
+	_, err := c.PfsAPIClient.PutFileMulti(
+		context.Background(),
+		&pfs.PutFileMultiRequest{
+			File:      NewFile(repoName, commitID, path),
+			Value:     values,
+			Delimiter: pfs.Delimiter_LINE,
+		},
+	)
+	return sanitizeErr(err)
+}
+
+// StageBlob uploads the data in reader without putting it in a file, the
+// returned handle can be passed to PutFileStaged to put the data in one or
+// more files. This lets a slow upload happen before the commit it's put in
+// is started. ttlSeconds is how long the blob stays staged, 0 means an hour.
+// Staged blobs are held in memory by pachd, if it restarts before the blob
+// is put in a file the handle stops working and the data has to be staged
+// again.
+func (c APIClient) StageBlob(reader io.Reader, ttlSeconds int64) (*pfs.StagedBlob, error) {
+	stageBlobClient, err := c.PfsAPIClient.StageBlob(context.Background())
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	request := &pfs.StageBlobRequest{
+		Delimiter:  pfs.Delimiter_LINE,
+		TtlSeconds: ttlSeconds,
+	}
+	// the first request is sent even if reader is empty so the server knows
+	// the ttl
+	sent := false
+	// requests are kept well under grpc's message size limit
+	buffer := make([]byte, 1024*1024)
+	for {
+		n, err := reader.Read(buffer)
+		if n > 0 || !sent {
+			request.Value = buffer[:n]
+			if err := stageBlobClient.Send(request); err != nil {
+				return nil, sanitizeErr(err)
+			}
+			request = &pfs.StageBlobRequest{}
+			sent = true
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	stagedBlob, err := stageBlobClient.CloseAndRecv()
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return stagedBlob, nil
+}
+
+// PutFileStaged appends a blob staged with StageBlob to a file.
+func (c APIClient) PutFileStaged(repoName string, commitID string, path string, handle string) error {
+	_, err := c.PfsAPIClient.PutFileStaged(
+		context.Background(),
+		&pfs.PutFileStagedRequest{
+			File:   NewFile(repoName, commitID, path),
+			Handle: handle,
+		},
+	)
+	return sanitizeErr(err)
+}
+
 // GetFile returns the contents of a file at a specific Commit.
 // offset specifies a number of bytes that should be skipped in the beginning of the file.
+// A negative offset counts back from the end of the file, -n reads the last n
+// bytes, or the whole file if it's shorter than n.
 // size limits the total amount of data returned, note you will get fewer bytes
 // than size if you pass a value larger than the size of the file.
 // If size is set to 0 then all of the data will be returned.
@@ -399,6 +930,12 @@ func (c APIClient) GetFile(repoName string, commitID string, path string, offset
 	return c.getFile(repoName, commitID, path, offset, size, fromCommitID, shard, false, "", writer)
 }
 
+// GetFileUnsafe is identical to GetFile except that it will consider writes
+// to unfinished commits, this is how a writer reads its own writes before
+// finishing a commit. Writes made with a handle are only seen by reads with
+// the same handle, or by reads with no handle. Writes made without a handle
+// are seen by every unsafe read. GetFile never sees writes to unfinished
+// commits, for it an open commit looks like its parent.
 func (c APIClient) GetFileUnsafe(repoName string, commitID string, path string, offset int64,
 	size int64, fromCommitID string, shard *pfs.Shard, handle string, writer io.Writer) error {
 	return c.getFile(repoName, commitID, path, offset, size, fromCommitID, shard, true, handle, writer)
@@ -430,6 +967,121 @@ func (c APIClient) getFile(repoName string, commitID string, path string, offset
 	return nil
 }
 
+// GetFileMerged writes the latest-wins merge of path across commits to
+// writer: the content comes from whichever of the commits changed path most
+// recently. If that change deleted path a not found error is returned. The
+// commits may be on different branches or in different repos.
+func (c APIClient) GetFileMerged(commits []*pfs.Commit, path string, writer io.Writer) error {
+	if len(commits) == 0 {
+		return fmt.Errorf("GetFileMerged needs at least one commit")
+	}
+	apiGetFileClient, err := c.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfs.GetFileRequest{
+			File:        NewFile(commits[0].Repo.Name, commits[0].ID, path),
+			SizeBytes:   math.MaxInt64,
+			MergeCommit: commits[1:],
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	if err := protostream.WriteFromStreamingBytesClient(apiGetFileClient, writer); err != nil {
+		return sanitizeErr(err)
+	}
+	return nil
+}
+
+// GetFileDiff writes the content that a Commit itself wrote to path to
+// writer, without the content the file had in the Commit's parent. If the
+// Commit didn't write to path a not found error is returned.
+func (c APIClient) GetFileDiff(repoName string, commitID string, path string, writer io.Writer) error {
+	apiGetFileClient, err := c.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfs.GetFileRequest{
+			File:      NewFile(repoName, commitID, path),
+			SizeBytes: math.MaxInt64,
+			DiffOnly:  true,
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	if err := protostream.WriteFromStreamingBytesClient(apiGetFileClient, writer); err != nil {
+		return sanitizeErr(err)
+	}
+	return nil
+}
+
+// GetFileArchive writes a zip archive containing the files at paths in a
+// Commit to writer, each file is stored in the archive at its path.
+func (c APIClient) GetFileArchive(repoName string, commitID string, paths []string, writer io.Writer) error {
+	var files []*pfs.File
+	for _, path := range paths {
+		files = append(files, NewFile(repoName, commitID, path))
+	}
+	getFileArchiveClient, err := c.PfsAPIClient.GetFileArchive(
+		context.Background(),
+		&pfs.GetFileArchiveRequest{
+			File: files,
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	if err := protostream.WriteFromStreamingBytesClient(getFileArchiveClient, writer); err != nil {
+		return sanitizeErr(err)
+	}
+	return nil
+}
+
+// GetFileLines writes count lines of a file at a specific Commit to writer,
+// starting at line start. The first line in the file is line 0, count 0
+// means every line from start to the end of the file.
+func (c APIClient) GetFileLines(repoName string, commitID string, path string,
+	start uint64, count uint64, writer io.Writer) error {
+	apiGetFileClient, err := c.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfs.GetFileRequest{
+			File: NewFile(repoName, commitID, path),
+			LineRange: &pfs.LineRange{
+				Start: start,
+				Count: count,
+			},
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	if err := protostream.WriteFromStreamingBytesClient(apiGetFileClient, writer); err != nil {
+		return sanitizeErr(err)
+	}
+	return nil
+}
+
+// GetFileTranscoded writes a file at a specific Commit to writer, transcoded
+// from the character encoding it's stored in to another. Encodings are
+// named like "utf-8", "iso-8859-1" or "utf-16le".
+func (c APIClient) GetFileTranscoded(repoName string, commitID string, path string,
+	sourceEncoding string, targetEncoding string, writer io.Writer) error {
+	apiGetFileClient, err := c.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfs.GetFileRequest{
+			File:           NewFile(repoName, commitID, path),
+			SizeBytes:      math.MaxInt64,
+			SourceEncoding: sourceEncoding,
+			TargetEncoding: targetEncoding,
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	if err := protostream.WriteFromStreamingBytesClient(apiGetFileClient, writer); err != nil {
+		return sanitizeErr(err)
+	}
+	return nil
+}
+
 // InspectFile returns info about a specific file.  fromCommitID lets you get
 // only info which was added after this Commit.  shard allows you to downsample
 // the data, returning info about only a subset of the blocks in the file.
@@ -440,6 +1092,9 @@ func (c APIClient) InspectFile(repoName string, commitID string, path string,
 	return c.inspectFile(repoName, commitID, path, fromCommitID, shard, false, "")
 }
 
+// InspectFileUnsafe is identical to InspectFile except that it will consider
+// writes to unfinished commits, handle has the same meaning as in
+// GetFileUnsafe.
 func (c APIClient) InspectFileUnsafe(repoName string, commitID string, path string,
 	fromCommitID string, shard *pfs.Shard, handle string) (*pfs.FileInfo, error) {
 	return c.inspectFile(repoName, commitID, path, fromCommitID, shard, true, handle)
@@ -463,6 +1118,22 @@ func (c APIClient) inspectFile(repoName string, commitID string, path string,
 	return fileInfo, nil
 }
 
+// FileType returns the type of a file, FileType_FILE_TYPE_NONE if it doesn't
+// exist. It's cheaper than InspectFile for callers that only need to know
+// whether a path is a file or a directory.
+func (c APIClient) FileType(repoName string, commitID string, path string) (pfs.FileType, error) {
+	fileTypeResponse, err := c.PfsAPIClient.FileType(
+		context.Background(),
+		&pfs.FileTypeRequest{
+			File: NewFile(repoName, commitID, path),
+		},
+	)
+	if err != nil {
+		return pfs.FileType_FILE_TYPE_NONE, sanitizeErr(err)
+	}
+	return fileTypeResponse.FileType, nil
+}
+
 // ListFile returns info about all files in a Commit.
 // fromCommitID lets you get only info which was added after this Commit.
 // shard allows you to downsample the data, returning info about only a subset
@@ -470,11 +1141,67 @@ func (c APIClient) inspectFile(repoName string, commitID string, path string,
 // in which case info about all the files and all the blocks in those files
 // will be returned.
 // recurse causes ListFile to accurately report the size of data stored in directories, it makes the call more expensive
+// Each FileInfo's CommitModified is the most recent commit that changed it,
+// for a directory that's the most recent commit that changed anything in it.
 func (c APIClient) ListFile(repoName string, commitID string, path string, fromCommitID string,
 	shard *pfs.Shard, recurse bool) ([]*pfs.FileInfo, error) {
 	return c.listFile(repoName, commitID, path, fromCommitID, shard, recurse, false, "")
 }
 
+// ListFileMulti lists several files, which may be in different repos and
+// commits, in one call. The result has the FileInfos for each of files, in
+// the same order. recurse has the same meaning as in ListFile.
+func (c APIClient) ListFileMulti(files []*pfs.File, recurse bool) ([][]*pfs.FileInfo, error) {
+	request := &pfs.ListFileMultiRequest{}
+	for _, file := range files {
+		request.Request = append(request.Request, &pfs.ListFileRequest{
+			File:    file,
+			Recurse: recurse,
+		})
+	}
+	response, err := c.PfsAPIClient.ListFileMulti(context.Background(), request)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	var result [][]*pfs.FileInfo
+	for _, fileInfos := range response.FileInfos {
+		result = append(result, fileInfos.FileInfo)
+	}
+	return result, nil
+}
+
+// ListFileStream is like ListFile except the FileInfos are passed to f as
+// they're found rather than being returned all at once, which is cheaper
+// for large directories. Regular files come first, in no particular order,
+// followed by directories.
+func (c APIClient) ListFileStream(repoName string, commitID string, path string, fromCommitID string,
+	shard *pfs.Shard, recurse bool, f func(*pfs.FileInfo) error) error {
+	listFileStreamClient, err := c.PfsAPIClient.ListFileStream(
+		context.Background(),
+		&pfs.ListFileRequest{
+			File:       NewFile(repoName, commitID, path),
+			Shard:      shard,
+			FromCommit: newFromCommit(repoName, fromCommitID),
+			Recurse:    recurse,
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	for {
+		fileInfo, err := listFileStreamClient.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return sanitizeErr(err)
+		}
+		if err := f(fileInfo); err != nil {
+			return err
+		}
+	}
+}
+
 // ListFileUnsafe is identical to ListFile except that it will consider files in unfinished commits.
 // handle can be used to specify a specific set of dirty writes that you're interested in.
 func (c APIClient) ListFileUnsafe(repoName string, commitID string, path string, fromCommitID string,
@@ -518,6 +1245,161 @@ func (c APIClient) DeleteFile(repoName string, commitID string, path string, uns
 	return err
 }
 
+// FilesExist returns whether each of paths exists in a Commit, in the same
+// order as paths. It's cheaper than calling InspectFile for each path.
+func (c APIClient) FilesExist(repoName string, commitID string, paths []string) ([]bool, error) {
+	request := &pfs.FilesExistRequest{}
+	for _, path := range paths {
+		request.File = append(request.File, NewFile(repoName, commitID, path))
+	}
+	response, err := c.PfsAPIClient.FilesExist(context.Background(), request)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response.Exists, nil
+}
+
+// DeleteFiles deletes many files from a Commit in one call. A result is
+// returned for each path, in the same order, one file failing to be deleted
+// doesn't stop the others from being deleted.
+// unsafe and handle have the same meaning as in DeleteFile.
+func (c APIClient) DeleteFiles(repoName string, commitID string, paths []string, unsafe bool, handle string) ([]*pfs.DeleteFileResult, error) {
+	request := &pfs.DeleteFilesRequest{
+		Unsafe: unsafe,
+		Handle: handle,
+	}
+	for _, path := range paths {
+		request.File = append(request.File, NewFile(repoName, commitID, path))
+	}
+	response, err := c.PfsAPIClient.DeleteFiles(context.Background(), request)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response.Result, nil
+}
+
+// Validate checks a batch of writes without making them. A result is
+// returned for each operation, in the same order, saying whether it would be
+// accepted and which shard and server it would be routed to.
+func (c APIClient) Validate(operations []*pfs.Operation) ([]*pfs.ValidateResult, error) {
+	response, err := c.PfsAPIClient.Validate(
+		context.Background(),
+		&pfs.ValidateRequest{
+			Operation: operations,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response.Result, nil
+}
+
+// ExportCommit streams every file in a finished commit to f, see
+// pfs.ExportRecord for how the records describe the files. The commit is
+// streamed as it's read, it's never held in memory as a whole.
+func (c APIClient) ExportCommit(repoName string, commitID string, f func(*pfs.ExportRecord) error) error {
+	exportCommitClient, err := c.PfsAPIClient.ExportCommit(
+		context.Background(),
+		&pfs.ExportCommitRequest{
+			Commit: NewCommit(repoName, commitID),
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	for {
+		record, err := exportCommitClient.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return sanitizeErr(err)
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadShard streams the regular files in a finished commit that belong to
+// shard to f, in the same format as ExportCommit. Reading every shard gives
+// every file in the commit, each shard can be read where it's served.
+func (c APIClient) ReadShard(repoName string, commitID string, shard uint64, f func(*pfs.ExportRecord) error) error {
+	readShardClient, err := c.PfsAPIClient.ReadShard(
+		context.Background(),
+		&pfs.ReadShardRequest{
+			Commit: NewCommit(repoName, commitID),
+			Shard:  shard,
+		},
+	)
+	if err != nil {
+		return sanitizeErr(err)
+	}
+	for {
+		record, err := readShardClient.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return sanitizeErr(err)
+		}
+		if err := f(record); err != nil {
+			return err
+		}
+	}
+}
+
+// ImportCommit starts a commit in repoName and writes the records that f
+// passes to send to it, see ExportCommit. The commit is finished once f
+// returns, if f returns an error the commit is cancelled.
+// parentCommit and branch have the same meaning as in StartCommit.
+func (c APIClient) ImportCommit(repoName string, parentCommit string, branch string,
+	f func(send func(*pfs.ExportRecord) error) error) (*pfs.Commit, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	importCommitClient, err := c.PfsAPIClient.ImportCommit(ctx)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	request := &pfs.ImportCommitRequest{
+		Repo:     NewRepo(repoName),
+		ParentID: parentCommit,
+		Branch:   branch,
+	}
+	if err := importCommitClient.Send(request); err != nil {
+		return nil, sanitizeErr(err)
+	}
+	if err := f(func(record *pfs.ExportRecord) error {
+		return importCommitClient.Send(&pfs.ImportCommitRequest{Record: record})
+	}); err != nil {
+		// cancelling the stream makes the server cancel the commit
+		return nil, err
+	}
+	commit, err := importCommitClient.CloseAndRecv()
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return commit, nil
+}
+
+// ExportToPath writes the files in a finished commit to path, a directory on
+// the filesystem of the pachd node that serves the request. path is relative
+// to the node's export root, ExportToPath fails if pachd doesn't have one. It
+// returns the number of files and bytes that were written.
+func (c APIClient) ExportToPath(repoName string, commitID string, path string) (*pfs.ExportToPathResponse, error) {
+	response, err := c.PfsAPIClient.ExportToPath(
+		context.Background(),
+		&pfs.ExportToPathRequest{
+			Commit: NewCommit(repoName, commitID),
+			Path:   path,
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response, nil
+}
+
 // MakeDirectory creates a directory in PFS.
 // Note directories are created implicitly by PutFile, so you technically never
 // need this function unless you want to create an empty directory.
@@ -539,6 +1421,50 @@ func (c APIClient) MakeDirectory(repoName string, commitID string, path string)
 	))
 }
 
+// ListShard returns info about every shard in the cluster, including the
+// address of the server that's responsible for it and whether that server has
+// finished loading it.
+func (c APIClient) ListShard() ([]*pfs.ShardInfo, error) {
+	shardInfos, err := c.PfsAPIClient.ListShard(
+		context.Background(),
+		&pfs.ListShardRequest{},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return shardInfos.ShardInfo, nil
+}
+
+// ShardStats returns storage stats for every shard in the cluster: how much
+// data it holds, how many blocks it references and how many open commits it
+// has.
+func (c APIClient) ShardStats() ([]*pfs.ShardStat, error) {
+	response, err := c.PfsAPIClient.ShardStats(
+		context.Background(),
+		&pfs.ShardStatsRequest{},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response.ShardStat, nil
+}
+
+// BlockReferences returns the files that refer to the block with hash, each
+// in the commit in which the block was written to it. A block with no
+// references isn't needed by any commit.
+func (c APIClient) BlockReferences(hash string) ([]*pfs.File, error) {
+	response, err := c.PfsAPIClient.BlockReferences(
+		context.Background(),
+		&pfs.BlockReferencesRequest{
+			Block: NewBlock(hash),
+		},
+	)
+	if err != nil {
+		return nil, sanitizeErr(err)
+	}
+	return response.File, nil
+}
+
 type putFileWriteCloser struct {
 	request       *pfs.PutFileRequest
 	putFileClient pfs.API_PutFileClient
@@ -16,6 +16,7 @@ It has these top-level messages:
 	Diff
 	RepoInfo
 	RepoInfos
+	CommitNode
 	CommitInfo
 	CommitInfos
 	FileInfo
@@ -27,23 +28,79 @@ It has these top-level messages:
 	BlockInfo
 	BlockInfos
 	DiffInfo
+	IdempotencyKeys
+	FileChange
+	FileChanges
 	Shard
+	ShardInfo
+	ShardInfos
+	ShardStat
+	ShardStatsResponse
 	CreateRepoRequest
 	InspectRepoRequest
 	ListRepoRequest
 	DeleteRepoRequest
 	StartCommitRequest
+	ReserveCommitRequest
+	UnstartCommitRequest
 	FinishCommitRequest
 	InspectCommitRequest
+	CommitChangedFilesRequest
+	CommitDiffRequest
+	CommitManifestRequest
+	ManifestEntry
+	PackCommitRequest
+	PackCommitResponse
 	ListCommitRequest
+	OpenCommitsRequest
 	ListBranchRequest
+	InspectBranchRequest
 	DeleteCommitRequest
+	RestoreCommitRequest
 	FlushCommitRequest
+	StageBlobRequest
+	StagedBlob
+	InspectStagedBlobRequest
+	PutFileStagedRequest
+	PutFileURLRequest
+	PutFileMultiRequest
 	GetFileRequest
+	LineRange
 	PutFileRequest
+	GetFileArchiveRequest
 	InspectFileRequest
+	FileTypeRequest
+	FileTypeResponse
 	ListFileRequest
+	ListFileMultiRequest
+	ListFileMultiResponse
+	SetImmutableRequest
+	CheckMutableRequest
+	SetXattrRequest
+	FileCompareAndSwapRequest
+	FileCompareAndSwapResponse
+	GetXattrRequest
 	DeleteFileRequest
+	FilesExistRequest
+	FilesExistResponse
+	DeleteFilesRequest
+	DeleteFileResult
+	DeleteFilesResponse
+	Operation
+	ValidateRequest
+	ValidateResult
+	ValidateResponse
+	ExportCommitRequest
+	ExportRecord
+	ReadShardRequest
+	ExportToPathRequest
+	ExportToPathResponse
+	ImportCommitRequest
+	ListShardRequest
+	ShardStatsRequest
+	BlockReferencesRequest
+	BlockReferencesResponse
+	DumpShardRequest
 	PutBlockRequest
 	GetBlockRequest
 	DeleteBlockRequest
@@ -126,6 +183,57 @@ func (x FileType) String() string {
 }
 func (FileType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
 
+type ChangeType int32
+
+const (
+	ChangeType_CHANGE_TYPE_NONE     ChangeType = 0
+	ChangeType_CHANGE_TYPE_ADDED    ChangeType = 1
+	ChangeType_CHANGE_TYPE_MODIFIED ChangeType = 2
+	ChangeType_CHANGE_TYPE_DELETED  ChangeType = 3
+)
+
+var ChangeType_name = map[int32]string{
+	0: "CHANGE_TYPE_NONE",
+	1: "CHANGE_TYPE_ADDED",
+	2: "CHANGE_TYPE_MODIFIED",
+	3: "CHANGE_TYPE_DELETED",
+}
+var ChangeType_value = map[string]int32{
+	"CHANGE_TYPE_NONE":     0,
+	"CHANGE_TYPE_ADDED":    1,
+	"CHANGE_TYPE_MODIFIED": 2,
+	"CHANGE_TYPE_DELETED":  3,
+}
+
+func (x ChangeType) String() string {
+	return proto.EnumName(ChangeType_name, int32(x))
+}
+func (ChangeType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+type ShardState int32
+
+const (
+	ShardState_SHARD_STATE_NONE    ShardState = 0
+	ShardState_SHARD_STATE_LOADING ShardState = 1
+	ShardState_SHARD_STATE_READY   ShardState = 2
+)
+
+var ShardState_name = map[int32]string{
+	0: "SHARD_STATE_NONE",
+	1: "SHARD_STATE_LOADING",
+	2: "SHARD_STATE_READY",
+}
+var ShardState_value = map[string]int32{
+	"SHARD_STATE_NONE":    0,
+	"SHARD_STATE_LOADING": 1,
+	"SHARD_STATE_READY":   2,
+}
+
+func (x ShardState) String() string {
+	return proto.EnumName(ShardState_name, int32(x))
+}
+func (ShardState) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
 type Delimiter int32
 
 const (
@@ -148,7 +256,31 @@ var Delimiter_value = map[string]int32{
 func (x Delimiter) String() string {
 	return proto.EnumName(Delimiter_name, int32(x))
 }
-func (Delimiter) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+func (Delimiter) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type OperationType int32
+
+const (
+	OperationType_OPERATION_TYPE_NONE   OperationType = 0
+	OperationType_OPERATION_TYPE_PUT    OperationType = 1
+	OperationType_OPERATION_TYPE_DELETE OperationType = 2
+)
+
+var OperationType_name = map[int32]string{
+	0: "OPERATION_TYPE_NONE",
+	1: "OPERATION_TYPE_PUT",
+	2: "OPERATION_TYPE_DELETE",
+}
+var OperationType_value = map[string]int32{
+	"OPERATION_TYPE_NONE":   0,
+	"OPERATION_TYPE_PUT":    1,
+	"OPERATION_TYPE_DELETE": 2,
+}
+
+func (x OperationType) String() string {
+	return proto.EnumName(OperationType_name, int32(x))
+}
+func (OperationType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 type Repo struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
@@ -224,6 +356,15 @@ type RepoInfo struct {
 	Created    *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
 	SizeBytes  uint64                      `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
 	Provenance []*Repo                     `protobuf:"bytes,4,rep,name=provenance" json:"provenance,omitempty"`
+	// dag is only set if include_dag was set in the request, it holds a page
+	// of the repo's commits, parents before children.
+	Dag []*CommitNode `protobuf:"bytes,5,rep,name=dag" json:"dag,omitempty"`
+	// dag_next_offset is the dag_offset of the next page of the DAG, 0 means
+	// this is the last page.
+	DagNextOffset uint64 `protobuf:"varint,6,opt,name=dag_next_offset,json=dagNextOffset" json:"dag_next_offset,omitempty"`
+	// branch_heads is only set if include_branch_heads was set in the
+	// request, it maps each branch to the commit at its head.
+	BranchHeads map[string]*CommitInfo `protobuf:"bytes,7,rep,name=branch_heads,json=branchHeads" json:"branch_heads,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *RepoInfo) Reset()                    { *m = RepoInfo{} }
@@ -252,6 +393,20 @@ func (m *RepoInfo) GetProvenance() []*Repo {
 	return nil
 }
 
+func (m *RepoInfo) GetDag() []*CommitNode {
+	if m != nil {
+		return m.Dag
+	}
+	return nil
+}
+
+func (m *RepoInfo) GetBranchHeads() map[string]*CommitInfo {
+	if m != nil {
+		return m.BranchHeads
+	}
+	return nil
+}
+
 type RepoInfos struct {
 	RepoInfo []*RepoInfo `protobuf:"bytes,1,rep,name=repo_info,json=repoInfo" json:"repo_info,omitempty"`
 }
@@ -268,6 +423,48 @@ func (m *RepoInfos) GetRepoInfo() []*RepoInfo {
 	return nil
 }
 
+// CommitNode is a commit's place in its repo's commit DAG.
+type CommitNode struct {
+	Commit       *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	ParentCommit *Commit                     `protobuf:"bytes,2,opt,name=parent_commit,json=parentCommit" json:"parent_commit,omitempty"`
+	Branch       string                      `protobuf:"bytes,3,opt,name=branch" json:"branch,omitempty"`
+	Started      *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=started" json:"started,omitempty"`
+	Deleted      *google_protobuf2.Timestamp `protobuf:"bytes,5,opt,name=deleted" json:"deleted,omitempty"`
+}
+
+func (m *CommitNode) Reset()                    { *m = CommitNode{} }
+func (m *CommitNode) String() string            { return proto.CompactTextString(m) }
+func (*CommitNode) ProtoMessage()               {}
+func (*CommitNode) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+func (m *CommitNode) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *CommitNode) GetParentCommit() *Commit {
+	if m != nil {
+		return m.ParentCommit
+	}
+	return nil
+}
+
+func (m *CommitNode) GetStarted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Started
+	}
+	return nil
+}
+
+func (m *CommitNode) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
 type CommitInfo struct {
 	Commit       *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
 	Branch       string                      `protobuf:"bytes,2,opt,name=branch" json:"branch,omitempty"`
@@ -278,12 +475,20 @@ type CommitInfo struct {
 	SizeBytes    uint64                      `protobuf:"varint,7,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
 	Cancelled    bool                        `protobuf:"varint,8,opt,name=cancelled" json:"cancelled,omitempty"`
 	Provenance   []*Commit                   `protobuf:"bytes,9,rep,name=provenance" json:"provenance,omitempty"`
+	// deleted is set if the commit has been soft deleted.
+	Deleted *google_protobuf2.Timestamp `protobuf:"bytes,10,opt,name=deleted" json:"deleted,omitempty"`
+	// description is a human readable description of the commit, it's set
+	// when the commit is finished.
+	Description string `protobuf:"bytes,11,opt,name=description" json:"description,omitempty"`
+	// annotations are key/value pairs describing the commit, they're set when
+	// the commit is finished.
+	Annotations map[string]string `protobuf:"bytes,12,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *CommitInfo) Reset()                    { *m = CommitInfo{} }
 func (m *CommitInfo) String() string            { return proto.CompactTextString(m) }
 func (*CommitInfo) ProtoMessage()               {}
-func (*CommitInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+func (*CommitInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
 
 func (m *CommitInfo) GetCommit() *Commit {
 	if m != nil {
@@ -320,6 +525,20 @@ func (m *CommitInfo) GetProvenance() []*Commit {
 	return nil
 }
 
+func (m *CommitInfo) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+func (m *CommitInfo) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
 type CommitInfos struct {
 	CommitInfo []*CommitInfo `protobuf:"bytes,1,rep,name=commit_info,json=commitInfo" json:"commit_info,omitempty"`
 }
@@ -327,7 +546,7 @@ type CommitInfos struct {
 func (m *CommitInfos) Reset()                    { *m = CommitInfos{} }
 func (m *CommitInfos) String() string            { return proto.CompactTextString(m) }
 func (*CommitInfos) ProtoMessage()               {}
-func (*CommitInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+func (*CommitInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
 
 func (m *CommitInfos) GetCommitInfo() []*CommitInfo {
 	if m != nil {
@@ -337,18 +556,29 @@ func (m *CommitInfos) GetCommitInfo() []*CommitInfo {
 }
 
 type FileInfo struct {
-	File           *File                       `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	FileType       FileType                    `protobuf:"varint,2,opt,name=file_type,json=fileType,enum=pfs.FileType" json:"file_type,omitempty"`
-	SizeBytes      uint64                      `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
-	Modified       *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=modified" json:"modified,omitempty"`
-	CommitModified *Commit                     `protobuf:"bytes,5,opt,name=commit_modified,json=commitModified" json:"commit_modified,omitempty"`
-	Children       []*File                     `protobuf:"bytes,6,rep,name=children" json:"children,omitempty"`
+	File      *File                       `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	FileType  FileType                    `protobuf:"varint,2,opt,name=file_type,json=fileType,enum=pfs.FileType" json:"file_type,omitempty"`
+	SizeBytes uint64                      `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	Modified  *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=modified" json:"modified,omitempty"`
+	// commit_modified is the most recent commit in which the file was changed.
+	CommitModified *Commit `protobuf:"bytes,5,opt,name=commit_modified,json=commitModified" json:"commit_modified,omitempty"`
+	Children       []*File `protobuf:"bytes,6,rep,name=children" json:"children,omitempty"`
+	// existed_in is set by union listings, it's the commits in the range that
+	// the file existed in, in no particular order.
+	ExistedIn []*Commit `protobuf:"bytes,7,rep,name=existed_in,json=existedIn" json:"existed_in,omitempty"`
+	// xattrs are the file's extended attributes.
+	Xattrs map[string]string `protobuf:"bytes,8,rep,name=xattrs" json:"xattrs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// immutable is true if the file has been made immutable.
+	Immutable bool `protobuf:"varint,9,opt,name=immutable" json:"immutable,omitempty"`
+	// deleted is set by listings that include deleted files, the rest of the
+	// info is from the most recent commit the file existed in.
+	Deleted bool `protobuf:"varint,10,opt,name=deleted" json:"deleted,omitempty"`
 }
 
 func (m *FileInfo) Reset()                    { *m = FileInfo{} }
 func (m *FileInfo) String() string            { return proto.CompactTextString(m) }
 func (*FileInfo) ProtoMessage()               {}
-func (*FileInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+func (*FileInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
 
 func (m *FileInfo) GetFile() *File {
 	if m != nil {
@@ -378,6 +608,20 @@ func (m *FileInfo) GetChildren() []*File {
 	return nil
 }
 
+func (m *FileInfo) GetExistedIn() []*Commit {
+	if m != nil {
+		return m.ExistedIn
+	}
+	return nil
+}
+
+func (m *FileInfo) GetXattrs() map[string]string {
+	if m != nil {
+		return m.Xattrs
+	}
+	return nil
+}
+
 type FileInfos struct {
 	FileInfo []*FileInfo `protobuf:"bytes,1,rep,name=file_info,json=fileInfo" json:"file_info,omitempty"`
 }
@@ -385,7 +629,7 @@ type FileInfos struct {
 func (m *FileInfos) Reset()                    { *m = FileInfos{} }
 func (m *FileInfos) String() string            { return proto.CompactTextString(m) }
 func (*FileInfos) ProtoMessage()               {}
-func (*FileInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+func (*FileInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
 
 func (m *FileInfos) GetFileInfo() []*FileInfo {
 	if m != nil {
@@ -402,17 +646,20 @@ type ByteRange struct {
 func (m *ByteRange) Reset()                    { *m = ByteRange{} }
 func (m *ByteRange) String() string            { return proto.CompactTextString(m) }
 func (*ByteRange) ProtoMessage()               {}
-func (*ByteRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+func (*ByteRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
 
 type BlockRef struct {
 	Block *Block     `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	Range *ByteRange `protobuf:"bytes,2,opt,name=range" json:"range,omitempty"`
+	// data, if set, is block's content stored inline, block isn't on the
+	// block server. It's used for tiny files, see INLINE_FILE_SIZE_BYTES.
+	Data []byte `protobuf:"bytes,3,opt,name=data,proto3" json:"data,omitempty"`
 }
 
 func (m *BlockRef) Reset()                    { *m = BlockRef{} }
 func (m *BlockRef) String() string            { return proto.CompactTextString(m) }
 func (*BlockRef) ProtoMessage()               {}
-func (*BlockRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+func (*BlockRef) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
 
 func (m *BlockRef) GetBlock() *Block {
 	if m != nil {
@@ -435,7 +682,7 @@ type BlockRefs struct {
 func (m *BlockRefs) Reset()                    { *m = BlockRefs{} }
 func (m *BlockRefs) String() string            { return proto.CompactTextString(m) }
 func (*BlockRefs) ProtoMessage()               {}
-func (*BlockRefs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+func (*BlockRefs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
 
 func (m *BlockRefs) GetBlockRef() []*BlockRef {
 	if m != nil {
@@ -452,12 +699,21 @@ type Append struct {
 	Handles       map[string]*BlockRefs `protobuf:"bytes,5,rep,name=handles" json:"handles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	HandleDeletes map[string]bool       `protobuf:"bytes,6,rep,name=handle_deletes,json=handleDeletes" json:"handle_deletes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
 	FileType      FileType              `protobuf:"varint,7,opt,name=file_type,json=fileType,enum=pfs.FileType" json:"file_type,omitempty"`
+	// expires is when the data put in this append expires, once it has the
+	// append is treated as a delete.
+	Expires *google_protobuf2.Timestamp `protobuf:"bytes,8,opt,name=expires" json:"expires,omitempty"`
+	// xattrs are extended attributes set in this append, they take precedence
+	// over those set in earlier appends. An empty value removes the attribute.
+	Xattrs map[string]string `protobuf:"bytes,9,rep,name=xattrs" json:"xattrs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// immutable is set when the file is made immutable in this append, after
+	// that the file's content can't be changed and it can't be deleted.
+	Immutable bool `protobuf:"varint,10,opt,name=immutable" json:"immutable,omitempty"`
 }
 
 func (m *Append) Reset()                    { *m = Append{} }
 func (m *Append) String() string            { return proto.CompactTextString(m) }
 func (*Append) ProtoMessage()               {}
-func (*Append) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+func (*Append) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
 
 func (m *Append) GetBlockRefs() []*BlockRef {
 	if m != nil {
@@ -494,6 +750,20 @@ func (m *Append) GetHandleDeletes() map[string]bool {
 	return nil
 }
 
+func (m *Append) GetExpires() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Expires
+	}
+	return nil
+}
+
+func (m *Append) GetXattrs() map[string]string {
+	if m != nil {
+		return m.Xattrs
+	}
+	return nil
+}
+
 type BlockInfo struct {
 	Block     *Block                      `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
 	Created   *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
@@ -503,7 +773,7 @@ type BlockInfo struct {
 func (m *BlockInfo) Reset()                    { *m = BlockInfo{} }
 func (m *BlockInfo) String() string            { return proto.CompactTextString(m) }
 func (*BlockInfo) ProtoMessage()               {}
-func (*BlockInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+func (*BlockInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
 
 func (m *BlockInfo) GetBlock() *Block {
 	if m != nil {
@@ -526,7 +796,7 @@ type BlockInfos struct {
 func (m *BlockInfos) Reset()                    { *m = BlockInfos{} }
 func (m *BlockInfos) String() string            { return proto.CompactTextString(m) }
 func (*BlockInfos) ProtoMessage()               {}
-func (*BlockInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*BlockInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
 
 func (m *BlockInfos) GetBlockInfo() []*BlockInfo {
 	if m != nil {
@@ -542,16 +812,26 @@ type DiffInfo struct {
 	Started      *google_protobuf2.Timestamp `protobuf:"bytes,4,opt,name=started" json:"started,omitempty"`
 	Finished     *google_protobuf2.Timestamp `protobuf:"bytes,5,opt,name=finished" json:"finished,omitempty"`
 	// Appends is the BlockRefs which have been append to files indexed by path.
-	Appends    map[string]*Append `protobuf:"bytes,6,rep,name=appends" json:"appends,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	SizeBytes  uint64             `protobuf:"varint,7,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
-	Cancelled  bool               `protobuf:"varint,8,opt,name=cancelled" json:"cancelled,omitempty"`
-	Provenance []*Commit          `protobuf:"bytes,9,rep,name=provenance" json:"provenance,omitempty"`
+	Appends    map[string]*Append          `protobuf:"bytes,6,rep,name=appends" json:"appends,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SizeBytes  uint64                      `protobuf:"varint,7,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	Cancelled  bool                        `protobuf:"varint,8,opt,name=cancelled" json:"cancelled,omitempty"`
+	Provenance []*Commit                   `protobuf:"bytes,9,rep,name=provenance" json:"provenance,omitempty"`
+	Deleted    *google_protobuf2.Timestamp `protobuf:"bytes,10,opt,name=deleted" json:"deleted,omitempty"`
+	// checksum is a hash of the rest of the diff, it's set when the diff is
+	// persisted so that a diff that's been corrupted can be detected when it's
+	// loaded.
+	Checksum    string            `protobuf:"bytes,11,opt,name=checksum" json:"checksum,omitempty"`
+	Description string            `protobuf:"bytes,12,opt,name=description" json:"description,omitempty"`
+	Annotations map[string]string `protobuf:"bytes,13,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// idempotency_keys are the keys of the puts that have been applied to the
+	// commit while it's open, by path. They're dropped when it's finished.
+	IdempotencyKeys map[string]*IdempotencyKeys `protobuf:"bytes,14,rep,name=idempotency_keys,json=idempotencyKeys" json:"idempotency_keys,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 }
 
 func (m *DiffInfo) Reset()                    { *m = DiffInfo{} }
 func (m *DiffInfo) String() string            { return proto.CompactTextString(m) }
 func (*DiffInfo) ProtoMessage()               {}
-func (*DiffInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*DiffInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
 
 func (m *DiffInfo) GetDiff() *Diff {
 	if m != nil {
@@ -595,6 +875,76 @@ func (m *DiffInfo) GetProvenance() []*Commit {
 	return nil
 }
 
+func (m *DiffInfo) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+func (m *DiffInfo) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *DiffInfo) GetIdempotencyKeys() map[string]*IdempotencyKeys {
+	if m != nil {
+		return m.IdempotencyKeys
+	}
+	return nil
+}
+
+type IdempotencyKeys struct {
+	Key map[string]bool `protobuf:"bytes,1,rep,name=key" json:"key,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+}
+
+func (m *IdempotencyKeys) Reset()                    { *m = IdempotencyKeys{} }
+func (m *IdempotencyKeys) String() string            { return proto.CompactTextString(m) }
+func (*IdempotencyKeys) ProtoMessage()               {}
+func (*IdempotencyKeys) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *IdempotencyKeys) GetKey() map[string]bool {
+	if m != nil {
+		return m.Key
+	}
+	return nil
+}
+
+type FileChange struct {
+	File       *File      `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	ChangeType ChangeType `protobuf:"varint,2,opt,name=change_type,json=changeType,enum=pfs.ChangeType" json:"change_type,omitempty"`
+}
+
+func (m *FileChange) Reset()                    { *m = FileChange{} }
+func (m *FileChange) String() string            { return proto.CompactTextString(m) }
+func (*FileChange) ProtoMessage()               {}
+func (*FileChange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *FileChange) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type FileChanges struct {
+	FileChange []*FileChange `protobuf:"bytes,1,rep,name=file_change,json=fileChange" json:"file_change,omitempty"`
+}
+
+func (m *FileChanges) Reset()                    { *m = FileChanges{} }
+func (m *FileChanges) String() string            { return proto.CompactTextString(m) }
+func (*FileChanges) ProtoMessage()               {}
+func (*FileChanges) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *FileChanges) GetFileChange() []*FileChange {
+	if m != nil {
+		return m.FileChange
+	}
+	return nil
+}
+
 type Shard struct {
 	FileNumber   uint64 `protobuf:"varint,1,opt,name=file_number,json=fileNumber" json:"file_number,omitempty"`
 	FileModulus  uint64 `protobuf:"varint,2,opt,name=file_modulus,json=fileModulus" json:"file_modulus,omitempty"`
@@ -605,18 +955,89 @@ type Shard struct {
 func (m *Shard) Reset()                    { *m = Shard{} }
 func (m *Shard) String() string            { return proto.CompactTextString(m) }
 func (*Shard) ProtoMessage()               {}
-func (*Shard) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*Shard) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+type ShardInfo struct {
+	Shard   uint64     `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	Address string     `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	State   ShardState `protobuf:"varint,3,opt,name=state,enum=pfs.ShardState" json:"state,omitempty"`
+}
+
+func (m *ShardInfo) Reset()                    { *m = ShardInfo{} }
+func (m *ShardInfo) String() string            { return proto.CompactTextString(m) }
+func (*ShardInfo) ProtoMessage()               {}
+func (*ShardInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+type ShardInfos struct {
+	ShardInfo []*ShardInfo `protobuf:"bytes,1,rep,name=shard_info,json=shardInfo" json:"shard_info,omitempty"`
+}
+
+func (m *ShardInfos) Reset()                    { *m = ShardInfos{} }
+func (m *ShardInfos) String() string            { return proto.CompactTextString(m) }
+func (*ShardInfos) ProtoMessage()               {}
+func (*ShardInfos) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *ShardInfos) GetShardInfo() []*ShardInfo {
+	if m != nil {
+		return m.ShardInfo
+	}
+	return nil
+}
+
+type ShardStat struct {
+	Shard   uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	Address string `protobuf:"bytes,2,opt,name=address" json:"address,omitempty"`
+	// size_bytes is the amount of data in the shard's diffs.
+	SizeBytes uint64 `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	// blocks is the number of distinct blocks the shard's diffs reference.
+	Blocks      uint64 `protobuf:"varint,4,opt,name=blocks" json:"blocks,omitempty"`
+	OpenCommits uint64 `protobuf:"varint,5,opt,name=open_commits,json=openCommits" json:"open_commits,omitempty"`
+	// unflushed_bytes is the amount of data in the shard's open commits.
+	UnflushedBytes uint64 `protobuf:"varint,6,opt,name=unflushed_bytes,json=unflushedBytes" json:"unflushed_bytes,omitempty"`
+	// corrupt_blocks is the number of the shard's blocks whose content didn't
+	// match their hash, or that couldn't be read at all, the last time they
+	// were scrubbed, it's always 0 if the server doesn't scrub.
+	CorruptBlocks uint64 `protobuf:"varint,7,opt,name=corrupt_blocks,json=corruptBlocks" json:"corrupt_blocks,omitempty"`
+	// waiting_operations is the number of reads and writes that are waiting
+	// for the shard because it's running as many as it may at once.
+	WaitingOperations uint64 `protobuf:"varint,8,opt,name=waiting_operations,json=waitingOperations" json:"waiting_operations,omitempty"`
+}
+
+func (m *ShardStat) Reset()                    { *m = ShardStat{} }
+func (m *ShardStat) String() string            { return proto.CompactTextString(m) }
+func (*ShardStat) ProtoMessage()               {}
+func (*ShardStat) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+type ShardStatsResponse struct {
+	ShardStat []*ShardStat `protobuf:"bytes,1,rep,name=shard_stat,json=shardStat" json:"shard_stat,omitempty"`
+}
+
+func (m *ShardStatsResponse) Reset()                    { *m = ShardStatsResponse{} }
+func (m *ShardStatsResponse) String() string            { return proto.CompactTextString(m) }
+func (*ShardStatsResponse) ProtoMessage()               {}
+func (*ShardStatsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *ShardStatsResponse) GetShardStat() []*ShardStat {
+	if m != nil {
+		return m.ShardStat
+	}
+	return nil
+}
 
 type CreateRepoRequest struct {
 	Repo       *Repo                       `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
 	Created    *google_protobuf2.Timestamp `protobuf:"bytes,2,opt,name=created" json:"created,omitempty"`
 	Provenance []*Repo                     `protobuf:"bytes,3,rep,name=provenance" json:"provenance,omitempty"`
+	// create_if_not_exists makes creating a repo that already exists succeed
+	// if it has the same provenance, it's still an error if the provenance
+	// differs.
+	CreateIfNotExists bool `protobuf:"varint,4,opt,name=create_if_not_exists,json=createIfNotExists" json:"create_if_not_exists,omitempty"`
 }
 
 func (m *CreateRepoRequest) Reset()                    { *m = CreateRepoRequest{} }
 func (m *CreateRepoRequest) String() string            { return proto.CompactTextString(m) }
 func (*CreateRepoRequest) ProtoMessage()               {}
-func (*CreateRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*CreateRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
 func (m *CreateRepoRequest) GetRepo() *Repo {
 	if m != nil {
@@ -640,13 +1061,20 @@ func (m *CreateRepoRequest) GetProvenance() []*Repo {
 }
 
 type InspectRepoRequest struct {
-	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	Repo       *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	IncludeDag bool  `protobuf:"varint,2,opt,name=include_dag,json=includeDag" json:"include_dag,omitempty"`
+	// dag_offset is the number of DAG nodes to skip.
+	DagOffset uint64 `protobuf:"varint,3,opt,name=dag_offset,json=dagOffset" json:"dag_offset,omitempty"`
+	// dag_limit is the maximum number of DAG nodes to return, 0 means the
+	// server's default.
+	DagLimit           uint64 `protobuf:"varint,4,opt,name=dag_limit,json=dagLimit" json:"dag_limit,omitempty"`
+	IncludeBranchHeads bool   `protobuf:"varint,5,opt,name=include_branch_heads,json=includeBranchHeads" json:"include_branch_heads,omitempty"`
 }
 
 func (m *InspectRepoRequest) Reset()                    { *m = InspectRepoRequest{} }
 func (m *InspectRepoRequest) String() string            { return proto.CompactTextString(m) }
 func (*InspectRepoRequest) ProtoMessage()               {}
-func (*InspectRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*InspectRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
 func (m *InspectRepoRequest) GetRepo() *Repo {
 	if m != nil {
@@ -662,7 +1090,7 @@ type ListRepoRequest struct {
 func (m *ListRepoRequest) Reset()                    { *m = ListRepoRequest{} }
 func (m *ListRepoRequest) String() string            { return proto.CompactTextString(m) }
 func (*ListRepoRequest) ProtoMessage()               {}
-func (*ListRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*ListRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
 
 func (m *ListRepoRequest) GetProvenance() []*Repo {
 	if m != nil {
@@ -678,7 +1106,7 @@ type DeleteRepoRequest struct {
 func (m *DeleteRepoRequest) Reset()                    { *m = DeleteRepoRequest{} }
 func (m *DeleteRepoRequest) String() string            { return proto.CompactTextString(m) }
 func (*DeleteRepoRequest) ProtoMessage()               {}
-func (*DeleteRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*DeleteRepoRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
 
 func (m *DeleteRepoRequest) GetRepo() *Repo {
 	if m != nil {
@@ -694,12 +1122,18 @@ type StartCommitRequest struct {
 	Branch     string                      `protobuf:"bytes,4,opt,name=branch" json:"branch,omitempty"`
 	Started    *google_protobuf2.Timestamp `protobuf:"bytes,5,opt,name=started" json:"started,omitempty"`
 	Provenance []*Commit                   `protobuf:"bytes,6,rep,name=provenance" json:"provenance,omitempty"`
+	// from_commit, if set, is a finished commit whose files the new commit
+	// starts out with in place of its parent's files.
+	FromCommit *Commit `protobuf:"bytes,7,opt,name=from_commit,json=fromCommit" json:"from_commit,omitempty"`
+	// reserved is set by the server when the caller passed in id, such ids
+	// must have been reserved with ReserveCommit.
+	Reserved bool `protobuf:"varint,8,opt,name=reserved" json:"reserved,omitempty"`
 }
 
 func (m *StartCommitRequest) Reset()                    { *m = StartCommitRequest{} }
 func (m *StartCommitRequest) String() string            { return proto.CompactTextString(m) }
 func (*StartCommitRequest) ProtoMessage()               {}
-func (*StartCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*StartCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
 
 func (m *StartCommitRequest) GetRepo() *Repo {
 	if m != nil {
@@ -722,16 +1156,68 @@ func (m *StartCommitRequest) GetProvenance() []*Commit {
 	return nil
 }
 
+func (m *StartCommitRequest) GetFromCommit() *Commit {
+	if m != nil {
+		return m.FromCommit
+	}
+	return nil
+}
+
+type ReserveCommitRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	// id is set by the server.
+	ID string `protobuf:"bytes,2,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *ReserveCommitRequest) Reset()                    { *m = ReserveCommitRequest{} }
+func (m *ReserveCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReserveCommitRequest) ProtoMessage()               {}
+func (*ReserveCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *ReserveCommitRequest) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+type UnstartCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *UnstartCommitRequest) Reset()                    { *m = UnstartCommitRequest{} }
+func (m *UnstartCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*UnstartCommitRequest) ProtoMessage()               {}
+func (*UnstartCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *UnstartCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
 type FinishCommitRequest struct {
 	Commit   *Commit                     `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
 	Cancel   bool                        `protobuf:"varint,2,opt,name=cancel" json:"cancel,omitempty"`
 	Finished *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=finished" json:"finished,omitempty"`
+	// description is a human readable description of the commit, like a git
+	// commit message.
+	Description string `protobuf:"bytes,4,opt,name=description" json:"description,omitempty"`
+	// annotations are key/value pairs describing the commit, e.g. the job
+	// that produced it. Commits can be listed by annotation.
+	Annotations map[string]string `protobuf:"bytes,5,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// expected_parent, if set, makes the finish conditional: it fails with a
+	// conflict unless commit's parent is expected_parent and commit is still
+	// the head of its branch, i.e. no other commit has been started on the
+	// branch since commit was.
+	ExpectedParent *Commit `protobuf:"bytes,6,opt,name=expected_parent,json=expectedParent" json:"expected_parent,omitempty"`
 }
 
 func (m *FinishCommitRequest) Reset()                    { *m = FinishCommitRequest{} }
 func (m *FinishCommitRequest) String() string            { return proto.CompactTextString(m) }
 func (*FinishCommitRequest) ProtoMessage()               {}
-func (*FinishCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+func (*FinishCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *FinishCommitRequest) GetCommit() *Commit {
 	if m != nil {
@@ -747,6 +1233,20 @@ func (m *FinishCommitRequest) GetFinished() *google_protobuf2.Timestamp {
 	return nil
 }
 
+func (m *FinishCommitRequest) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+func (m *FinishCommitRequest) GetExpectedParent() *Commit {
+	if m != nil {
+		return m.ExpectedParent
+	}
+	return nil
+}
+
 type InspectCommitRequest struct {
 	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
 }
@@ -754,7 +1254,7 @@ type InspectCommitRequest struct {
 func (m *InspectCommitRequest) Reset()                    { *m = InspectCommitRequest{} }
 func (m *InspectCommitRequest) String() string            { return proto.CompactTextString(m) }
 func (*InspectCommitRequest) ProtoMessage()               {}
-func (*InspectCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+func (*InspectCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
 
 func (m *InspectCommitRequest) GetCommit() *Commit {
 	if m != nil {
@@ -763,111 +1263,429 @@ func (m *InspectCommitRequest) GetCommit() *Commit {
 	return nil
 }
 
-type ListCommitRequest struct {
-	Repo       []*Repo    `protobuf:"bytes,1,rep,name=repo" json:"repo,omitempty"`
-	CommitType CommitType `protobuf:"varint,2,opt,name=commit_type,json=commitType,enum=pfs.CommitType" json:"commit_type,omitempty"`
-	FromCommit []*Commit  `protobuf:"bytes,3,rep,name=from_commit,json=fromCommit" json:"from_commit,omitempty"`
-	Provenance []*Commit  `protobuf:"bytes,4,rep,name=provenance" json:"provenance,omitempty"`
-	All        bool       `protobuf:"varint,5,opt,name=all" json:"all,omitempty"`
-	Block      bool       `protobuf:"varint,6,opt,name=block" json:"block,omitempty"`
+type CommitChangedFilesRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
 }
 
-func (m *ListCommitRequest) Reset()                    { *m = ListCommitRequest{} }
-func (m *ListCommitRequest) String() string            { return proto.CompactTextString(m) }
-func (*ListCommitRequest) ProtoMessage()               {}
-func (*ListCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+func (m *CommitChangedFilesRequest) Reset()                    { *m = CommitChangedFilesRequest{} }
+func (m *CommitChangedFilesRequest) String() string            { return proto.CompactTextString(m) }
+func (*CommitChangedFilesRequest) ProtoMessage()               {}
+func (*CommitChangedFilesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
 
-func (m *ListCommitRequest) GetRepo() []*Repo {
+func (m *CommitChangedFilesRequest) GetCommit() *Commit {
 	if m != nil {
-		return m.Repo
+		return m.Commit
 	}
 	return nil
 }
 
-func (m *ListCommitRequest) GetFromCommit() []*Commit {
+// CommitDiffRequest compares the files in two commits, they don't need to be
+// related.
+type CommitDiffRequest struct {
+	FromCommit *Commit `protobuf:"bytes,1,opt,name=from_commit,json=fromCommit" json:"from_commit,omitempty"`
+	ToCommit   *Commit `protobuf:"bytes,2,opt,name=to_commit,json=toCommit" json:"to_commit,omitempty"`
+}
+
+func (m *CommitDiffRequest) Reset()                    { *m = CommitDiffRequest{} }
+func (m *CommitDiffRequest) String() string            { return proto.CompactTextString(m) }
+func (*CommitDiffRequest) ProtoMessage()               {}
+func (*CommitDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *CommitDiffRequest) GetFromCommit() *Commit {
 	if m != nil {
 		return m.FromCommit
 	}
 	return nil
 }
 
-func (m *ListCommitRequest) GetProvenance() []*Commit {
+func (m *CommitDiffRequest) GetToCommit() *Commit {
 	if m != nil {
-		return m.Provenance
+		return m.ToCommit
 	}
 	return nil
 }
 
-type ListBranchRequest struct {
-	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+type CommitManifestRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
 }
 
-func (m *ListBranchRequest) Reset()                    { *m = ListBranchRequest{} }
-func (m *ListBranchRequest) String() string            { return proto.CompactTextString(m) }
-func (*ListBranchRequest) ProtoMessage()               {}
-func (*ListBranchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+func (m *CommitManifestRequest) Reset()                    { *m = CommitManifestRequest{} }
+func (m *CommitManifestRequest) String() string            { return proto.CompactTextString(m) }
+func (*CommitManifestRequest) ProtoMessage()               {}
+func (*CommitManifestRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
 
-func (m *ListBranchRequest) GetRepo() *Repo {
+func (m *CommitManifestRequest) GetCommit() *Commit {
 	if m != nil {
-		return m.Repo
+		return m.Commit
 	}
 	return nil
 }
 
-type DeleteCommitRequest struct {
-	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+type ManifestEntry struct {
+	File      *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	SizeBytes uint64 `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+	// block_refs_hash is the hex encoded SHA-256 of the file's block refs,
+	// it's not a hash of the content. Blocks are content addressed so files
+	// with the same block_refs_hash have the same content, but the same
+	// content written in different chunks, or packed by PackCommit, hashes
+	// differently.
+	BlockRefsHash string `protobuf:"bytes,3,opt,name=block_refs_hash,json=blockRefsHash" json:"block_refs_hash,omitempty"`
 }
 
-func (m *DeleteCommitRequest) Reset()                    { *m = DeleteCommitRequest{} }
-func (m *DeleteCommitRequest) String() string            { return proto.CompactTextString(m) }
-func (*DeleteCommitRequest) ProtoMessage()               {}
-func (*DeleteCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+func (m *ManifestEntry) Reset()                    { *m = ManifestEntry{} }
+func (m *ManifestEntry) String() string            { return proto.CompactTextString(m) }
+func (*ManifestEntry) ProtoMessage()               {}
+func (*ManifestEntry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
 
-func (m *DeleteCommitRequest) GetCommit() *Commit {
+func (m *ManifestEntry) GetFile() *File {
 	if m != nil {
-		return m.Commit
+		return m.File
 	}
 	return nil
 }
 
-type FlushCommitRequest struct {
-	Commit []*Commit `protobuf:"bytes,1,rep,name=commit" json:"commit,omitempty"`
-	ToRepo []*Repo   `protobuf:"bytes,2,rep,name=to_repo,json=toRepo" json:"to_repo,omitempty"`
+type PackCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	// max_file_size_bytes is the size of the largest file that gets packed,
+	// 0 means 64KB.
+	MaxFileSizeBytes uint64 `protobuf:"varint,2,opt,name=max_file_size_bytes,json=maxFileSizeBytes" json:"max_file_size_bytes,omitempty"`
 }
 
-func (m *FlushCommitRequest) Reset()                    { *m = FlushCommitRequest{} }
-func (m *FlushCommitRequest) String() string            { return proto.CompactTextString(m) }
-func (*FlushCommitRequest) ProtoMessage()               {}
-func (*FlushCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+func (m *PackCommitRequest) Reset()                    { *m = PackCommitRequest{} }
+func (m *PackCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*PackCommitRequest) ProtoMessage()               {}
+func (*PackCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
 
-func (m *FlushCommitRequest) GetCommit() []*Commit {
+func (m *PackCommitRequest) GetCommit() *Commit {
 	if m != nil {
 		return m.Commit
 	}
 	return nil
 }
 
-func (m *FlushCommitRequest) GetToRepo() []*Repo {
+type PackCommitResponse struct {
+	FilesPacked uint64 `protobuf:"varint,1,opt,name=files_packed,json=filesPacked" json:"files_packed,omitempty"`
+}
+
+func (m *PackCommitResponse) Reset()                    { *m = PackCommitResponse{} }
+func (m *PackCommitResponse) String() string            { return proto.CompactTextString(m) }
+func (*PackCommitResponse) ProtoMessage()               {}
+func (*PackCommitResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+type ListCommitRequest struct {
+	Repo       []*Repo    `protobuf:"bytes,1,rep,name=repo" json:"repo,omitempty"`
+	CommitType CommitType `protobuf:"varint,2,opt,name=commit_type,json=commitType,enum=pfs.CommitType" json:"commit_type,omitempty"`
+	FromCommit []*Commit  `protobuf:"bytes,3,rep,name=from_commit,json=fromCommit" json:"from_commit,omitempty"`
+	Provenance []*Commit  `protobuf:"bytes,4,rep,name=provenance" json:"provenance,omitempty"`
+	All        bool       `protobuf:"varint,5,opt,name=all" json:"all,omitempty"`
+	Block      bool       `protobuf:"varint,6,opt,name=block" json:"block,omitempty"`
+	// include_deleted causes soft deleted commits to be returned.
+	IncludeDeleted bool `protobuf:"varint,7,opt,name=include_deleted,json=includeDeleted" json:"include_deleted,omitempty"`
+	// annotations, if set, restricts the results to commits that have all of
+	// these annotations.
+	Annotations map[string]string `protobuf:"bytes,8,rep,name=annotations" json:"annotations,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// branch, if set, restricts the results to commits reachable from the head
+	// of branch.
+	Branch string `protobuf:"bytes,9,opt,name=branch" json:"branch,omitempty"`
+	// base_branch, if set, excludes commits reachable from the head of
+	// base_branch. Together with branch it lists the commits that are unique to
+	// branch.
+	BaseBranch string `protobuf:"bytes,10,opt,name=base_branch,json=baseBranch" json:"base_branch,omitempty"`
+}
+
+func (m *ListCommitRequest) Reset()                    { *m = ListCommitRequest{} }
+func (m *ListCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListCommitRequest) ProtoMessage()               {}
+func (*ListCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *ListCommitRequest) GetRepo() []*Repo {
 	if m != nil {
-		return m.ToRepo
+		return m.Repo
+	}
+	return nil
+}
+
+func (m *ListCommitRequest) GetFromCommit() []*Commit {
+	if m != nil {
+		return m.FromCommit
+	}
+	return nil
+}
+
+func (m *ListCommitRequest) GetProvenance() []*Commit {
+	if m != nil {
+		return m.Provenance
+	}
+	return nil
+}
+
+func (m *ListCommitRequest) GetAnnotations() map[string]string {
+	if m != nil {
+		return m.Annotations
+	}
+	return nil
+}
+
+type OpenCommitsRequest struct {
+}
+
+func (m *OpenCommitsRequest) Reset()                    { *m = OpenCommitsRequest{} }
+func (m *OpenCommitsRequest) String() string            { return proto.CompactTextString(m) }
+func (*OpenCommitsRequest) ProtoMessage()               {}
+func (*OpenCommitsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+type ListBranchRequest struct {
+	Repo *Repo `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+}
+
+func (m *ListBranchRequest) Reset()                    { *m = ListBranchRequest{} }
+func (m *ListBranchRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListBranchRequest) ProtoMessage()               {}
+func (*ListBranchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *ListBranchRequest) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+type InspectBranchRequest struct {
+	Repo   *Repo  `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	Branch string `protobuf:"bytes,2,opt,name=branch" json:"branch,omitempty"`
+}
+
+func (m *InspectBranchRequest) Reset()                    { *m = InspectBranchRequest{} }
+func (m *InspectBranchRequest) String() string            { return proto.CompactTextString(m) }
+func (*InspectBranchRequest) ProtoMessage()               {}
+func (*InspectBranchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *InspectBranchRequest) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+type DeleteCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	// soft causes the commit to be marked as deleted rather than removed, it
+	// can be restored with RestoreCommit until it's garbage collected.
+	Soft    bool                        `protobuf:"varint,2,opt,name=soft" json:"soft,omitempty"`
+	Deleted *google_protobuf2.Timestamp `protobuf:"bytes,3,opt,name=deleted" json:"deleted,omitempty"`
+}
+
+func (m *DeleteCommitRequest) Reset()                    { *m = DeleteCommitRequest{} }
+func (m *DeleteCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteCommitRequest) ProtoMessage()               {}
+func (*DeleteCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *DeleteCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *DeleteCommitRequest) GetDeleted() *google_protobuf2.Timestamp {
+	if m != nil {
+		return m.Deleted
+	}
+	return nil
+}
+
+type RestoreCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *RestoreCommitRequest) Reset()                    { *m = RestoreCommitRequest{} }
+func (m *RestoreCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*RestoreCommitRequest) ProtoMessage()               {}
+func (*RestoreCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *RestoreCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type FlushCommitRequest struct {
+	Commit []*Commit `protobuf:"bytes,1,rep,name=commit" json:"commit,omitempty"`
+	ToRepo []*Repo   `protobuf:"bytes,2,rep,name=to_repo,json=toRepo" json:"to_repo,omitempty"`
+}
+
+func (m *FlushCommitRequest) Reset()                    { *m = FlushCommitRequest{} }
+func (m *FlushCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*FlushCommitRequest) ProtoMessage()               {}
+func (*FlushCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *FlushCommitRequest) GetCommit() []*Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+func (m *FlushCommitRequest) GetToRepo() []*Repo {
+	if m != nil {
+		return m.ToRepo
+	}
+	return nil
+}
+
+type StageBlobRequest struct {
+	Value     []byte    `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Delimiter Delimiter `protobuf:"varint,2,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
+	// ttl_seconds is how long the blob stays staged, 0 means an hour.
+	TtlSeconds int64 `protobuf:"varint,3,opt,name=ttl_seconds,json=ttlSeconds" json:"ttl_seconds,omitempty"`
+	// handle is set by the server, clients leave it empty.
+	Handle string `protobuf:"bytes,4,opt,name=handle" json:"handle,omitempty"`
+}
+
+func (m *StageBlobRequest) Reset()                    { *m = StageBlobRequest{} }
+func (m *StageBlobRequest) String() string            { return proto.CompactTextString(m) }
+func (*StageBlobRequest) ProtoMessage()               {}
+func (*StageBlobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+// StagedBlob identifies data staged with StageBlob.
+type StagedBlob struct {
+	Handle    string `protobuf:"bytes,1,opt,name=handle" json:"handle,omitempty"`
+	SizeBytes uint64 `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+}
+
+func (m *StagedBlob) Reset()                    { *m = StagedBlob{} }
+func (m *StagedBlob) String() string            { return proto.CompactTextString(m) }
+func (*StagedBlob) ProtoMessage()               {}
+func (*StagedBlob) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+type InspectStagedBlobRequest struct {
+	Handle string `protobuf:"bytes,1,opt,name=handle" json:"handle,omitempty"`
+}
+
+func (m *InspectStagedBlobRequest) Reset()                    { *m = InspectStagedBlobRequest{} }
+func (m *InspectStagedBlobRequest) String() string            { return proto.CompactTextString(m) }
+func (*InspectStagedBlobRequest) ProtoMessage()               {}
+func (*InspectStagedBlobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+type PutFileStagedRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// handle is the handle of a StagedBlob whose data is appended to file.
+	Handle string `protobuf:"bytes,2,opt,name=handle" json:"handle,omitempty"`
+	// block_ref is set by the server, clients leave it empty.
+	BlockRef []*BlockRef `protobuf:"bytes,3,rep,name=block_ref,json=blockRef" json:"block_ref,omitempty"`
+}
+
+func (m *PutFileStagedRequest) Reset()                    { *m = PutFileStagedRequest{} }
+func (m *PutFileStagedRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutFileStagedRequest) ProtoMessage()               {}
+func (*PutFileStagedRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{52} }
+
+func (m *PutFileStagedRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *PutFileStagedRequest) GetBlockRef() []*BlockRef {
+	if m != nil {
+		return m.BlockRef
+	}
+	return nil
+}
+
+type PutFileURLRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// url is fetched by the server with a GET, http and https are supported.
+	// Objects in S3 can be fetched through a presigned URL. The server doesn't
+	// fetch from loopback, private or link local addresses unless it's started
+	// with DENY_PRIVATE_URLS=false.
+	Url string `protobuf:"bytes,2,opt,name=url" json:"url,omitempty"`
+	// headers are sent with the GET, e.g. to authenticate with the source.
+	Headers map[string]string `protobuf:"bytes,3,rep,name=headers" json:"headers,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// max_bytes is the most data that will be fetched, if the source has more
+	// the put fails and isn't applied. 0 means no limit.
+	MaxBytes  uint64    `protobuf:"varint,4,opt,name=max_bytes,json=maxBytes" json:"max_bytes,omitempty"`
+	Delimiter Delimiter `protobuf:"varint,5,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
+}
+
+func (m *PutFileURLRequest) Reset()                    { *m = PutFileURLRequest{} }
+func (m *PutFileURLRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutFileURLRequest) ProtoMessage()               {}
+func (*PutFileURLRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{53} }
+
+func (m *PutFileURLRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+func (m *PutFileURLRequest) GetHeaders() map[string]string {
+	if m != nil {
+		return m.Headers
+	}
+	return nil
+}
+
+type PutFileMultiRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// value is appended to the file in order, the values land next to each
+	// other even if other puts to the file are happening concurrently.
+	Value     [][]byte  `protobuf:"bytes,2,rep,name=value,proto3" json:"value,omitempty"`
+	Delimiter Delimiter `protobuf:"varint,3,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
+}
+
+func (m *PutFileMultiRequest) Reset()                    { *m = PutFileMultiRequest{} }
+func (m *PutFileMultiRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutFileMultiRequest) ProtoMessage()               {}
+func (*PutFileMultiRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{54} }
+
+func (m *PutFileMultiRequest) GetFile() *File {
+	if m != nil {
+		return m.File
 	}
 	return nil
 }
 
 type GetFileRequest struct {
-	File        *File   `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// offset_bytes is where in the file to start reading, a negative value
+	// counts back from the end of the file.
 	OffsetBytes int64   `protobuf:"varint,2,opt,name=offset_bytes,json=offsetBytes" json:"offset_bytes,omitempty"`
 	SizeBytes   int64   `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
 	Shard       *Shard  `protobuf:"bytes,4,opt,name=shard" json:"shard,omitempty"`
 	FromCommit  *Commit `protobuf:"bytes,5,opt,name=from_commit,json=fromCommit" json:"from_commit,omitempty"`
 	Unsafe      bool    `protobuf:"varint,6,opt,name=unsafe" json:"unsafe,omitempty"`
 	Handle      string  `protobuf:"bytes,7,opt,name=handle" json:"handle,omitempty"`
+	// base64 causes the content to be streamed base64 encoded, each message in
+	// the stream can be decoded on its own.
+	Base64 bool `protobuf:"varint,8,opt,name=base64" json:"base64,omitempty"`
+	// line_range, if set, restricts the content to a range of lines, lines
+	// are counted from the start of the file so offset_bytes must be 0.
+	LineRange *LineRange `protobuf:"bytes,9,opt,name=line_range,json=lineRange" json:"line_range,omitempty"`
+	// merge_commit, if set, reads file.path as the latest-wins merge of
+	// file.commit and these commits: the content comes from whichever commit
+	// changed the path most recently. If that change was a delete the file
+	// isn't found.
+	MergeCommit []*Commit `protobuf:"bytes,10,rep,name=merge_commit,json=mergeCommit" json:"merge_commit,omitempty"`
+	// diff_only reads just the content that file.commit itself wrote to
+	// file.path, not the content it inherited from its ancestors. The file
+	// isn't found if the commit didn't write to it.
+	DiffOnly bool `protobuf:"varint,11,opt,name=diff_only,json=diffOnly" json:"diff_only,omitempty"`
+	// source_encoding and target_encoding, if set, cause the content to be
+	// transcoded from the character encoding it's stored in to another, e.g.
+	// "iso-8859-1" to "utf-8". offset_bytes, size_bytes and line_range apply
+	// to the content before it's transcoded.
+	SourceEncoding string `protobuf:"bytes,12,opt,name=source_encoding,json=sourceEncoding" json:"source_encoding,omitempty"`
+	TargetEncoding string `protobuf:"bytes,13,opt,name=target_encoding,json=targetEncoding" json:"target_encoding,omitempty"`
 }
 
 func (m *GetFileRequest) Reset()                    { *m = GetFileRequest{} }
 func (m *GetFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*GetFileRequest) ProtoMessage()               {}
-func (*GetFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+func (*GetFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{55} }
 
 func (m *GetFileRequest) GetFile() *File {
 	if m != nil {
@@ -890,18 +1708,65 @@ func (m *GetFileRequest) GetFromCommit() *Commit {
 	return nil
 }
 
+func (m *GetFileRequest) GetLineRange() *LineRange {
+	if m != nil {
+		return m.LineRange
+	}
+	return nil
+}
+
+func (m *GetFileRequest) GetMergeCommit() []*Commit {
+	if m != nil {
+		return m.MergeCommit
+	}
+	return nil
+}
+
+type LineRange struct {
+	// start is the first line returned, the first line in the file is 0.
+	Start uint64 `protobuf:"varint,1,opt,name=start" json:"start,omitempty"`
+	// count is the number of lines returned, 0 means every line from start to
+	// the end of the file.
+	Count uint64 `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+}
+
+func (m *LineRange) Reset()                    { *m = LineRange{} }
+func (m *LineRange) String() string            { return proto.CompactTextString(m) }
+func (*LineRange) ProtoMessage()               {}
+func (*LineRange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{56} }
+
 type PutFileRequest struct {
 	File      *File     `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 	FileType  FileType  `protobuf:"varint,2,opt,name=file_type,json=fileType,enum=pfs.FileType" json:"file_type,omitempty"`
 	Value     []byte    `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
 	Handle    string    `protobuf:"bytes,4,opt,name=handle" json:"handle,omitempty"`
 	Delimiter Delimiter `protobuf:"varint,5,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
+	// idempotency_key identifies a put, if a put with the same key has already
+	// been applied to the same path in the same open commit the put is ignored.
+	IdempotencyKey string `protobuf:"bytes,6,opt,name=idempotency_key,json=idempotencyKey" json:"idempotency_key,omitempty"`
+	// expected_hash is the hex encoded SHA-256 of the data being put, if it's
+	// set and doesn't match the data the put fails and isn't applied.
+	ExpectedHash string `protobuf:"bytes,7,opt,name=expected_hash,json=expectedHash" json:"expected_hash,omitempty"`
+	// ttl_seconds is how long the file lives for, once it's up the file is
+	// deleted. The last put to a file determines when it expires, including
+	// what was put to it in earlier commits. 0 means the file never expires.
+	TtlSeconds uint64 `protobuf:"varint,8,opt,name=ttl_seconds,json=ttlSeconds" json:"ttl_seconds,omitempty"`
+	// offset_bytes, if set, makes the put write its data at this offset in the
+	// file, overwriting what's there, rather than appending it. Writing past
+	// the end of the file fills the gap with zeros.
+	OffsetBytes *google_protobuf3.UInt64Value `protobuf:"bytes,9,opt,name=offset_bytes,json=offsetBytes" json:"offset_bytes,omitempty"`
+	// xattrs are extended attributes to set on the file along with the put.
+	Xattrs map[string]string `protobuf:"bytes,10,rep,name=xattrs" json:"xattrs,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// content_type, if set, makes the put fail with "validation failed" if
+	// the data isn't valid for the type. The server must have a validator
+	// for the type, e.g. application/x-ndjson for JSON lines.
+	ContentType string `protobuf:"bytes,11,opt,name=content_type,json=contentType" json:"content_type,omitempty"`
 }
 
 func (m *PutFileRequest) Reset()                    { *m = PutFileRequest{} }
 func (m *PutFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*PutFileRequest) ProtoMessage()               {}
-func (*PutFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+func (*PutFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{57} }
 
 func (m *PutFileRequest) GetFile() *File {
 	if m != nil {
@@ -910,6 +1775,37 @@ func (m *PutFileRequest) GetFile() *File {
 	return nil
 }
 
+func (m *PutFileRequest) GetOffsetBytes() *google_protobuf3.UInt64Value {
+	if m != nil {
+		return m.OffsetBytes
+	}
+	return nil
+}
+
+func (m *PutFileRequest) GetXattrs() map[string]string {
+	if m != nil {
+		return m.Xattrs
+	}
+	return nil
+}
+
+type GetFileArchiveRequest struct {
+	// file lists the files to put in the archive, each is stored at its path.
+	File []*File `protobuf:"bytes,1,rep,name=file" json:"file,omitempty"`
+}
+
+func (m *GetFileArchiveRequest) Reset()                    { *m = GetFileArchiveRequest{} }
+func (m *GetFileArchiveRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetFileArchiveRequest) ProtoMessage()               {}
+func (*GetFileArchiveRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{58} }
+
+func (m *GetFileArchiveRequest) GetFile() []*File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
 type InspectFileRequest struct {
 	File       *File   `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 	Shard      *Shard  `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
@@ -921,7 +1817,7 @@ type InspectFileRequest struct {
 func (m *InspectFileRequest) Reset()                    { *m = InspectFileRequest{} }
 func (m *InspectFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*InspectFileRequest) ProtoMessage()               {}
-func (*InspectFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+func (*InspectFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{59} }
 
 func (m *InspectFileRequest) GetFile() *File {
 	if m != nil {
@@ -944,6 +1840,34 @@ func (m *InspectFileRequest) GetFromCommit() *Commit {
 	return nil
 }
 
+type FileTypeRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// unsafe causes writes to open commits to be considered.
+	Unsafe bool `protobuf:"varint,2,opt,name=unsafe" json:"unsafe,omitempty"`
+}
+
+func (m *FileTypeRequest) Reset()                    { *m = FileTypeRequest{} }
+func (m *FileTypeRequest) String() string            { return proto.CompactTextString(m) }
+func (*FileTypeRequest) ProtoMessage()               {}
+func (*FileTypeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{60} }
+
+func (m *FileTypeRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type FileTypeResponse struct {
+	// file_type is FILE_TYPE_NONE if the file doesn't exist.
+	FileType FileType `protobuf:"varint,1,opt,name=file_type,json=fileType,enum=pfs.FileType" json:"file_type,omitempty"`
+}
+
+func (m *FileTypeResponse) Reset()                    { *m = FileTypeResponse{} }
+func (m *FileTypeResponse) String() string            { return proto.CompactTextString(m) }
+func (*FileTypeResponse) ProtoMessage()               {}
+func (*FileTypeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{61} }
+
 type ListFileRequest struct {
 	File       *File   `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 	Shard      *Shard  `protobuf:"bytes,2,opt,name=shard" json:"shard,omitempty"`
@@ -951,12 +1875,20 @@ type ListFileRequest struct {
 	Recurse    bool    `protobuf:"varint,4,opt,name=recurse" json:"recurse,omitempty"`
 	Unsafe     bool    `protobuf:"varint,5,opt,name=unsafe" json:"unsafe,omitempty"`
 	Handle     string  `protobuf:"bytes,6,opt,name=handle" json:"handle,omitempty"`
+	// union lists every file that existed under file in any of the commits
+	// from file's commit back to from_commit (both inclusive), or in any of
+	// its ancestors if from_commit isn't set. Each file's existed_in is set.
+	Union bool `protobuf:"varint,7,opt,name=union" json:"union,omitempty"`
+	// include_deleted also lists the files under file that existed in the
+	// same range of commits as a union listing but have since been deleted.
+	// They have deleted and existed_in set. It can't be used with union.
+	IncludeDeleted bool `protobuf:"varint,8,opt,name=include_deleted,json=includeDeleted" json:"include_deleted,omitempty"`
 }
 
 func (m *ListFileRequest) Reset()                    { *m = ListFileRequest{} }
 func (m *ListFileRequest) String() string            { return proto.CompactTextString(m) }
 func (*ListFileRequest) ProtoMessage()               {}
-func (*ListFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+func (*ListFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{62} }
 
 func (m *ListFileRequest) GetFile() *File {
 	if m != nil {
@@ -979,193 +1911,2711 @@ func (m *ListFileRequest) GetFromCommit() *Commit {
 	return nil
 }
 
-type DeleteFileRequest struct {
-	File   *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
-	Unsafe bool   `protobuf:"varint,2,opt,name=unsafe" json:"unsafe,omitempty"`
-	Handle string `protobuf:"bytes,3,opt,name=handle" json:"handle,omitempty"`
+type ListFileMultiRequest struct {
+	// request has a listing for each target, each is listed as ListFile would
+	// list it.
+	Request []*ListFileRequest `protobuf:"bytes,1,rep,name=request" json:"request,omitempty"`
 }
 
-func (m *DeleteFileRequest) Reset()                    { *m = DeleteFileRequest{} }
-func (m *DeleteFileRequest) String() string            { return proto.CompactTextString(m) }
-func (*DeleteFileRequest) ProtoMessage()               {}
-func (*DeleteFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+func (m *ListFileMultiRequest) Reset()                    { *m = ListFileMultiRequest{} }
+func (m *ListFileMultiRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListFileMultiRequest) ProtoMessage()               {}
+func (*ListFileMultiRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{63} }
 
-func (m *DeleteFileRequest) GetFile() *File {
+func (m *ListFileMultiRequest) GetRequest() []*ListFileRequest {
 	if m != nil {
-		return m.File
+		return m.Request
 	}
 	return nil
 }
 
-type PutBlockRequest struct {
-	Value     []byte    `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
-	Delimiter Delimiter `protobuf:"varint,2,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
-}
-
-func (m *PutBlockRequest) Reset()                    { *m = PutBlockRequest{} }
-func (m *PutBlockRequest) String() string            { return proto.CompactTextString(m) }
-func (*PutBlockRequest) ProtoMessage()               {}
-func (*PutBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
-
-type GetBlockRequest struct {
-	Block       *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
-	OffsetBytes uint64 `protobuf:"varint,2,opt,name=offset_bytes,json=offsetBytes" json:"offset_bytes,omitempty"`
-	SizeBytes   uint64 `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+type ListFileMultiResponse struct {
+	// file_infos has the result of each of the request's listings, in the
+	// same order.
+	FileInfos []*FileInfos `protobuf:"bytes,1,rep,name=file_infos,json=fileInfos" json:"file_infos,omitempty"`
 }
 
-func (m *GetBlockRequest) Reset()                    { *m = GetBlockRequest{} }
-func (m *GetBlockRequest) String() string            { return proto.CompactTextString(m) }
-func (*GetBlockRequest) ProtoMessage()               {}
-func (*GetBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+func (m *ListFileMultiResponse) Reset()                    { *m = ListFileMultiResponse{} }
+func (m *ListFileMultiResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListFileMultiResponse) ProtoMessage()               {}
+func (*ListFileMultiResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{64} }
 
-func (m *GetBlockRequest) GetBlock() *Block {
+func (m *ListFileMultiResponse) GetFileInfos() []*FileInfos {
 	if m != nil {
-		return m.Block
+		return m.FileInfos
 	}
 	return nil
 }
 
-type DeleteBlockRequest struct {
-	Block *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+type SetImmutableRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 }
 
-func (m *DeleteBlockRequest) Reset()                    { *m = DeleteBlockRequest{} }
-func (m *DeleteBlockRequest) String() string            { return proto.CompactTextString(m) }
-func (*DeleteBlockRequest) ProtoMessage()               {}
-func (*DeleteBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+func (m *SetImmutableRequest) Reset()                    { *m = SetImmutableRequest{} }
+func (m *SetImmutableRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetImmutableRequest) ProtoMessage()               {}
+func (*SetImmutableRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{65} }
 
-func (m *DeleteBlockRequest) GetBlock() *Block {
+func (m *SetImmutableRequest) GetFile() *File {
 	if m != nil {
-		return m.Block
+		return m.File
 	}
 	return nil
 }
 
-type InspectBlockRequest struct {
-	Block *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+type CheckMutableRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
 }
 
-func (m *InspectBlockRequest) Reset()                    { *m = InspectBlockRequest{} }
-func (m *InspectBlockRequest) String() string            { return proto.CompactTextString(m) }
-func (*InspectBlockRequest) ProtoMessage()               {}
-func (*InspectBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+func (m *CheckMutableRequest) Reset()                    { *m = CheckMutableRequest{} }
+func (m *CheckMutableRequest) String() string            { return proto.CompactTextString(m) }
+func (*CheckMutableRequest) ProtoMessage()               {}
+func (*CheckMutableRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{66} }
 
-func (m *InspectBlockRequest) GetBlock() *Block {
+func (m *CheckMutableRequest) GetFile() *File {
 	if m != nil {
-		return m.Block
+		return m.File
 	}
 	return nil
 }
 
-type ListBlockRequest struct {
+type SetXattrRequest struct {
+	File *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	// value is the attribute's new value, an empty value removes it.
+	Value string `protobuf:"bytes,3,opt,name=value" json:"value,omitempty"`
 }
 
-func (m *ListBlockRequest) Reset()                    { *m = ListBlockRequest{} }
-func (m *ListBlockRequest) String() string            { return proto.CompactTextString(m) }
-func (*ListBlockRequest) ProtoMessage()               {}
-func (*ListBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+func (m *SetXattrRequest) Reset()                    { *m = SetXattrRequest{} }
+func (m *SetXattrRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetXattrRequest) ProtoMessage()               {}
+func (*SetXattrRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{67} }
 
-type InspectDiffRequest struct {
-	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+func (m *SetXattrRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
 }
 
-func (m *InspectDiffRequest) Reset()                    { *m = InspectDiffRequest{} }
-func (m *InspectDiffRequest) String() string            { return proto.CompactTextString(m) }
-func (*InspectDiffRequest) ProtoMessage()               {}
-func (*InspectDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+type FileCompareAndSwapRequest struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// expected_hash is the hex encoded SHA-256 of the content the file must
+	// have for it to be replaced, an empty hash means the file mustn't exist.
+	ExpectedHash string `protobuf:"bytes,2,opt,name=expected_hash,json=expectedHash" json:"expected_hash,omitempty"`
+	// value is the file's new content.
+	Value []byte `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+}
 
-func (m *InspectDiffRequest) GetDiff() *Diff {
+func (m *FileCompareAndSwapRequest) Reset()                    { *m = FileCompareAndSwapRequest{} }
+func (m *FileCompareAndSwapRequest) String() string            { return proto.CompactTextString(m) }
+func (*FileCompareAndSwapRequest) ProtoMessage()               {}
+func (*FileCompareAndSwapRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{68} }
+
+func (m *FileCompareAndSwapRequest) GetFile() *File {
 	if m != nil {
-		return m.Diff
+		return m.File
 	}
 	return nil
 }
 
-type ListDiffRequest struct {
-	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+type FileCompareAndSwapResponse struct {
+	// swapped is set if the file's content was replaced.
+	Swapped bool `protobuf:"varint,1,opt,name=swapped" json:"swapped,omitempty"`
+	// hash is the hex encoded SHA-256 of the file's content after the request,
+	// it's empty if the file doesn't exist.
+	Hash string `protobuf:"bytes,2,opt,name=hash" json:"hash,omitempty"`
 }
 
-func (m *ListDiffRequest) Reset()                    { *m = ListDiffRequest{} }
-func (m *ListDiffRequest) String() string            { return proto.CompactTextString(m) }
-func (*ListDiffRequest) ProtoMessage()               {}
-func (*ListDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+func (m *FileCompareAndSwapResponse) Reset()                    { *m = FileCompareAndSwapResponse{} }
+func (m *FileCompareAndSwapResponse) String() string            { return proto.CompactTextString(m) }
+func (*FileCompareAndSwapResponse) ProtoMessage()               {}
+func (*FileCompareAndSwapResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{69} }
 
-type DeleteDiffRequest struct {
-	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+type GetXattrRequest struct {
+	File   *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Name   string `protobuf:"bytes,2,opt,name=name" json:"name,omitempty"`
+	Unsafe bool   `protobuf:"varint,3,opt,name=unsafe" json:"unsafe,omitempty"`
 }
 
-func (m *DeleteDiffRequest) Reset()                    { *m = DeleteDiffRequest{} }
-func (m *DeleteDiffRequest) String() string            { return proto.CompactTextString(m) }
-func (*DeleteDiffRequest) ProtoMessage()               {}
-func (*DeleteDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+func (m *GetXattrRequest) Reset()                    { *m = GetXattrRequest{} }
+func (m *GetXattrRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetXattrRequest) ProtoMessage()               {}
+func (*GetXattrRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{70} }
 
-func (m *DeleteDiffRequest) GetDiff() *Diff {
+func (m *GetXattrRequest) GetFile() *File {
 	if m != nil {
-		return m.Diff
+		return m.File
 	}
 	return nil
 }
 
-func init() {
-	proto.RegisterType((*Repo)(nil), "pfs.Repo")
-	proto.RegisterType((*Commit)(nil), "pfs.Commit")
-	proto.RegisterType((*File)(nil), "pfs.File")
-	proto.RegisterType((*Block)(nil), "pfs.Block")
-	proto.RegisterType((*Diff)(nil), "pfs.Diff")
-	proto.RegisterType((*RepoInfo)(nil), "pfs.RepoInfo")
-	proto.RegisterType((*RepoInfos)(nil), "pfs.RepoInfos")
-	proto.RegisterType((*CommitInfo)(nil), "pfs.CommitInfo")
-	proto.RegisterType((*CommitInfos)(nil), "pfs.CommitInfos")
-	proto.RegisterType((*FileInfo)(nil), "pfs.FileInfo")
-	proto.RegisterType((*FileInfos)(nil), "pfs.FileInfos")
-	proto.RegisterType((*ByteRange)(nil), "pfs.ByteRange")
-	proto.RegisterType((*BlockRef)(nil), "pfs.BlockRef")
-	proto.RegisterType((*BlockRefs)(nil), "pfs.BlockRefs")
-	proto.RegisterType((*Append)(nil), "pfs.Append")
-	proto.RegisterType((*BlockInfo)(nil), "pfs.BlockInfo")
-	proto.RegisterType((*BlockInfos)(nil), "pfs.BlockInfos")
-	proto.RegisterType((*DiffInfo)(nil), "pfs.DiffInfo")
-	proto.RegisterType((*Shard)(nil), "pfs.Shard")
-	proto.RegisterType((*CreateRepoRequest)(nil), "pfs.CreateRepoRequest")
-	proto.RegisterType((*InspectRepoRequest)(nil), "pfs.InspectRepoRequest")
-	proto.RegisterType((*ListRepoRequest)(nil), "pfs.ListRepoRequest")
-	proto.RegisterType((*DeleteRepoRequest)(nil), "pfs.DeleteRepoRequest")
-	proto.RegisterType((*StartCommitRequest)(nil), "pfs.StartCommitRequest")
-	proto.RegisterType((*FinishCommitRequest)(nil), "pfs.FinishCommitRequest")
-	proto.RegisterType((*InspectCommitRequest)(nil), "pfs.InspectCommitRequest")
-	proto.RegisterType((*ListCommitRequest)(nil), "pfs.ListCommitRequest")
-	proto.RegisterType((*ListBranchRequest)(nil), "pfs.ListBranchRequest")
-	proto.RegisterType((*DeleteCommitRequest)(nil), "pfs.DeleteCommitRequest")
-	proto.RegisterType((*FlushCommitRequest)(nil), "pfs.FlushCommitRequest")
-	proto.RegisterType((*GetFileRequest)(nil), "pfs.GetFileRequest")
-	proto.RegisterType((*PutFileRequest)(nil), "pfs.PutFileRequest")
-	proto.RegisterType((*InspectFileRequest)(nil), "pfs.InspectFileRequest")
-	proto.RegisterType((*ListFileRequest)(nil), "pfs.ListFileRequest")
-	proto.RegisterType((*DeleteFileRequest)(nil), "pfs.DeleteFileRequest")
-	proto.RegisterType((*PutBlockRequest)(nil), "pfs.PutBlockRequest")
-	proto.RegisterType((*GetBlockRequest)(nil), "pfs.GetBlockRequest")
-	proto.RegisterType((*DeleteBlockRequest)(nil), "pfs.DeleteBlockRequest")
-	proto.RegisterType((*InspectBlockRequest)(nil), "pfs.InspectBlockRequest")
-	proto.RegisterType((*ListBlockRequest)(nil), "pfs.ListBlockRequest")
-	proto.RegisterType((*InspectDiffRequest)(nil), "pfs.InspectDiffRequest")
-	proto.RegisterType((*ListDiffRequest)(nil), "pfs.ListDiffRequest")
-	proto.RegisterType((*DeleteDiffRequest)(nil), "pfs.DeleteDiffRequest")
-	proto.RegisterEnum("pfs.CommitType", CommitType_name, CommitType_value)
-	proto.RegisterEnum("pfs.FileType", FileType_name, FileType_value)
-	proto.RegisterEnum("pfs.Delimiter", Delimiter_name, Delimiter_value)
+type DeleteFileRequest struct {
+	File   *File  `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	Unsafe bool   `protobuf:"varint,2,opt,name=unsafe" json:"unsafe,omitempty"`
+	Handle string `protobuf:"bytes,3,opt,name=handle" json:"handle,omitempty"`
 }
 
-// Reference imports to suppress errors if they are not otherwise used.
-var _ context.Context
-var _ grpc.ClientConn
+func (m *DeleteFileRequest) Reset()                    { *m = DeleteFileRequest{} }
+func (m *DeleteFileRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteFileRequest) ProtoMessage()               {}
+func (*DeleteFileRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{71} }
 
-// This is a compile-time assertion to ensure that this generated file
-// is compatible with the grpc package it is being compiled against.
-const _ = grpc.SupportPackageIsVersion2
+func (m *DeleteFileRequest) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type FilesExistRequest struct {
+	File   []*File `protobuf:"bytes,1,rep,name=file" json:"file,omitempty"`
+	Unsafe bool    `protobuf:"varint,2,opt,name=unsafe" json:"unsafe,omitempty"`
+}
+
+func (m *FilesExistRequest) Reset()                    { *m = FilesExistRequest{} }
+func (m *FilesExistRequest) String() string            { return proto.CompactTextString(m) }
+func (*FilesExistRequest) ProtoMessage()               {}
+func (*FilesExistRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{72} }
+
+func (m *FilesExistRequest) GetFile() []*File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type FilesExistResponse struct {
+	// exists has an entry for each file in the request, in the same order.
+	Exists []bool `protobuf:"varint,1,rep,name=exists" json:"exists,omitempty"`
+}
+
+func (m *FilesExistResponse) Reset()                    { *m = FilesExistResponse{} }
+func (m *FilesExistResponse) String() string            { return proto.CompactTextString(m) }
+func (*FilesExistResponse) ProtoMessage()               {}
+func (*FilesExistResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{73} }
+
+type DeleteFilesRequest struct {
+	File   []*File `protobuf:"bytes,1,rep,name=file" json:"file,omitempty"`
+	Unsafe bool    `protobuf:"varint,2,opt,name=unsafe" json:"unsafe,omitempty"`
+	Handle string  `protobuf:"bytes,3,opt,name=handle" json:"handle,omitempty"`
+}
+
+func (m *DeleteFilesRequest) Reset()                    { *m = DeleteFilesRequest{} }
+func (m *DeleteFilesRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteFilesRequest) ProtoMessage()               {}
+func (*DeleteFilesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{74} }
+
+func (m *DeleteFilesRequest) GetFile() []*File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+// DeleteFileResult is the outcome of deleting one of the files in a
+// DeleteFilesRequest.
+type DeleteFileResult struct {
+	File *File `protobuf:"bytes,1,opt,name=file" json:"file,omitempty"`
+	// error is why the file wasn't deleted, it's empty if the file was deleted.
+	Error string `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	// not_found is set if the file wasn't deleted because it doesn't exist.
+	NotFound bool `protobuf:"varint,3,opt,name=not_found,json=notFound" json:"not_found,omitempty"`
+}
+
+func (m *DeleteFileResult) Reset()                    { *m = DeleteFileResult{} }
+func (m *DeleteFileResult) String() string            { return proto.CompactTextString(m) }
+func (*DeleteFileResult) ProtoMessage()               {}
+func (*DeleteFileResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{75} }
+
+func (m *DeleteFileResult) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type DeleteFilesResponse struct {
+	// result has an entry for each file in the request, in the same order.
+	Result []*DeleteFileResult `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *DeleteFilesResponse) Reset()                    { *m = DeleteFilesResponse{} }
+func (m *DeleteFilesResponse) String() string            { return proto.CompactTextString(m) }
+func (*DeleteFilesResponse) ProtoMessage()               {}
+func (*DeleteFilesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{76} }
+
+func (m *DeleteFilesResponse) GetResult() []*DeleteFileResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+// Operation is a write that a client intends to make, see Validate.
+type Operation struct {
+	Type OperationType `protobuf:"varint,1,opt,name=type,enum=pfs.OperationType" json:"type,omitempty"`
+	File *File         `protobuf:"bytes,2,opt,name=file" json:"file,omitempty"`
+}
+
+func (m *Operation) Reset()                    { *m = Operation{} }
+func (m *Operation) String() string            { return proto.CompactTextString(m) }
+func (*Operation) ProtoMessage()               {}
+func (*Operation) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{77} }
+
+func (m *Operation) GetFile() *File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type ValidateRequest struct {
+	Operation []*Operation `protobuf:"bytes,1,rep,name=operation" json:"operation,omitempty"`
+}
+
+func (m *ValidateRequest) Reset()                    { *m = ValidateRequest{} }
+func (m *ValidateRequest) String() string            { return proto.CompactTextString(m) }
+func (*ValidateRequest) ProtoMessage()               {}
+func (*ValidateRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{78} }
+
+func (m *ValidateRequest) GetOperation() []*Operation {
+	if m != nil {
+		return m.Operation
+	}
+	return nil
+}
+
+// ValidateResult is the outcome of validating one of the operations in a
+// ValidateRequest.
+type ValidateResult struct {
+	Operation *Operation `protobuf:"bytes,1,opt,name=operation" json:"operation,omitempty"`
+	// shard and address are where the operation's file would be written.
+	Shard   uint64 `protobuf:"varint,2,opt,name=shard" json:"shard,omitempty"`
+	Address string `protobuf:"bytes,3,opt,name=address" json:"address,omitempty"`
+	// error is why the operation would fail, it's empty if it's valid.
+	Error string `protobuf:"bytes,4,opt,name=error" json:"error,omitempty"`
+}
+
+func (m *ValidateResult) Reset()                    { *m = ValidateResult{} }
+func (m *ValidateResult) String() string            { return proto.CompactTextString(m) }
+func (*ValidateResult) ProtoMessage()               {}
+func (*ValidateResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{79} }
+
+func (m *ValidateResult) GetOperation() *Operation {
+	if m != nil {
+		return m.Operation
+	}
+	return nil
+}
+
+type ValidateResponse struct {
+	// result has an entry for each operation in the request, in the same
+	// order.
+	Result []*ValidateResult `protobuf:"bytes,1,rep,name=result" json:"result,omitempty"`
+}
+
+func (m *ValidateResponse) Reset()                    { *m = ValidateResponse{} }
+func (m *ValidateResponse) String() string            { return proto.CompactTextString(m) }
+func (*ValidateResponse) ProtoMessage()               {}
+func (*ValidateResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{80} }
+
+func (m *ValidateResponse) GetResult() []*ValidateResult {
+	if m != nil {
+		return m.Result
+	}
+	return nil
+}
+
+type ExportCommitRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *ExportCommitRequest) Reset()                    { *m = ExportCommitRequest{} }
+func (m *ExportCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExportCommitRequest) ProtoMessage()               {}
+func (*ExportCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{81} }
+
+func (m *ExportCommitRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+// ExportRecord is a message in the stream returned by ExportCommit. A record
+// with file_info starts a new file, the file's content is the value of that
+// record followed by the values of the records after it, up to the next
+// record with file_info.
+type ExportRecord struct {
+	FileInfo *FileInfo `protobuf:"bytes,1,opt,name=file_info,json=fileInfo" json:"file_info,omitempty"`
+	Value    []byte    `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+}
+
+func (m *ExportRecord) Reset()                    { *m = ExportRecord{} }
+func (m *ExportRecord) String() string            { return proto.CompactTextString(m) }
+func (*ExportRecord) ProtoMessage()               {}
+func (*ExportRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{82} }
+
+func (m *ExportRecord) GetFileInfo() *FileInfo {
+	if m != nil {
+		return m.FileInfo
+	}
+	return nil
+}
+
+type ReadShardRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Shard  uint64  `protobuf:"varint,2,opt,name=shard" json:"shard,omitempty"`
+}
+
+func (m *ReadShardRequest) Reset()                    { *m = ReadShardRequest{} }
+func (m *ReadShardRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReadShardRequest) ProtoMessage()               {}
+func (*ReadShardRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{83} }
+
+func (m *ReadShardRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+// ExportToPathRequest writes the files in a finished commit to path, a
+// directory on the filesystem of the node that serves the request. path is
+// relative to the export root pachd was configured with, it can't refer to
+// anything outside of it.
+type ExportToPathRequest struct {
+	Commit *Commit `protobuf:"bytes,1,opt,name=commit" json:"commit,omitempty"`
+	Path   string  `protobuf:"bytes,2,opt,name=path" json:"path,omitempty"`
+}
+
+func (m *ExportToPathRequest) Reset()                    { *m = ExportToPathRequest{} }
+func (m *ExportToPathRequest) String() string            { return proto.CompactTextString(m) }
+func (*ExportToPathRequest) ProtoMessage()               {}
+func (*ExportToPathRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{84} }
+
+func (m *ExportToPathRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type ExportToPathResponse struct {
+	Files     uint64 `protobuf:"varint,1,opt,name=files" json:"files,omitempty"`
+	SizeBytes uint64 `protobuf:"varint,2,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+}
+
+func (m *ExportToPathResponse) Reset()                    { *m = ExportToPathResponse{} }
+func (m *ExportToPathResponse) String() string            { return proto.CompactTextString(m) }
+func (*ExportToPathResponse) ProtoMessage()               {}
+func (*ExportToPathResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{85} }
+
+type ImportCommitRequest struct {
+	// repo, parent_id and branch are read from the first request, they're used
+	// to start the commit that the records are imported into.
+	Repo     *Repo         `protobuf:"bytes,1,opt,name=repo" json:"repo,omitempty"`
+	ParentID string        `protobuf:"bytes,2,opt,name=parent_id,json=parentId" json:"parent_id,omitempty"`
+	Branch   string        `protobuf:"bytes,3,opt,name=branch" json:"branch,omitempty"`
+	Record   *ExportRecord `protobuf:"bytes,4,opt,name=record" json:"record,omitempty"`
+}
+
+func (m *ImportCommitRequest) Reset()                    { *m = ImportCommitRequest{} }
+func (m *ImportCommitRequest) String() string            { return proto.CompactTextString(m) }
+func (*ImportCommitRequest) ProtoMessage()               {}
+func (*ImportCommitRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{86} }
+
+func (m *ImportCommitRequest) GetRepo() *Repo {
+	if m != nil {
+		return m.Repo
+	}
+	return nil
+}
+
+func (m *ImportCommitRequest) GetRecord() *ExportRecord {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+type ListShardRequest struct {
+}
+
+func (m *ListShardRequest) Reset()                    { *m = ListShardRequest{} }
+func (m *ListShardRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListShardRequest) ProtoMessage()               {}
+func (*ListShardRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{87} }
+
+type ShardStatsRequest struct {
+}
+
+func (m *ShardStatsRequest) Reset()                    { *m = ShardStatsRequest{} }
+func (m *ShardStatsRequest) String() string            { return proto.CompactTextString(m) }
+func (*ShardStatsRequest) ProtoMessage()               {}
+func (*ShardStatsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{88} }
+
+type BlockReferencesRequest struct {
+	Block *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+}
+
+func (m *BlockReferencesRequest) Reset()                    { *m = BlockReferencesRequest{} }
+func (m *BlockReferencesRequest) String() string            { return proto.CompactTextString(m) }
+func (*BlockReferencesRequest) ProtoMessage()               {}
+func (*BlockReferencesRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{89} }
+
+func (m *BlockReferencesRequest) GetBlock() *Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+type BlockReferencesResponse struct {
+	// file is each file that refers to the block in the commit it was written
+	// to the file in.
+	File []*File `protobuf:"bytes,1,rep,name=file" json:"file,omitempty"`
+}
+
+func (m *BlockReferencesResponse) Reset()                    { *m = BlockReferencesResponse{} }
+func (m *BlockReferencesResponse) String() string            { return proto.CompactTextString(m) }
+func (*BlockReferencesResponse) ProtoMessage()               {}
+func (*BlockReferencesResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{90} }
+
+func (m *BlockReferencesResponse) GetFile() []*File {
+	if m != nil {
+		return m.File
+	}
+	return nil
+}
+
+type DumpShardRequest struct {
+	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+	// commit, if set, restricts the dump to the diff for that commit.
+	Commit *Commit `protobuf:"bytes,2,opt,name=commit" json:"commit,omitempty"`
+}
+
+func (m *DumpShardRequest) Reset()                    { *m = DumpShardRequest{} }
+func (m *DumpShardRequest) String() string            { return proto.CompactTextString(m) }
+func (*DumpShardRequest) ProtoMessage()               {}
+func (*DumpShardRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{91} }
+
+func (m *DumpShardRequest) GetCommit() *Commit {
+	if m != nil {
+		return m.Commit
+	}
+	return nil
+}
+
+type PutBlockRequest struct {
+	Value     []byte    `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Delimiter Delimiter `protobuf:"varint,2,opt,name=delimiter,enum=pfs.Delimiter" json:"delimiter,omitempty"`
+}
+
+func (m *PutBlockRequest) Reset()                    { *m = PutBlockRequest{} }
+func (m *PutBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*PutBlockRequest) ProtoMessage()               {}
+func (*PutBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{92} }
+
+type GetBlockRequest struct {
+	Block       *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+	OffsetBytes uint64 `protobuf:"varint,2,opt,name=offset_bytes,json=offsetBytes" json:"offset_bytes,omitempty"`
+	SizeBytes   uint64 `protobuf:"varint,3,opt,name=size_bytes,json=sizeBytes" json:"size_bytes,omitempty"`
+}
+
+func (m *GetBlockRequest) Reset()                    { *m = GetBlockRequest{} }
+func (m *GetBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetBlockRequest) ProtoMessage()               {}
+func (*GetBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{93} }
+
+func (m *GetBlockRequest) GetBlock() *Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+type DeleteBlockRequest struct {
+	Block *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+}
+
+func (m *DeleteBlockRequest) Reset()                    { *m = DeleteBlockRequest{} }
+func (m *DeleteBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteBlockRequest) ProtoMessage()               {}
+func (*DeleteBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{94} }
+
+func (m *DeleteBlockRequest) GetBlock() *Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+type InspectBlockRequest struct {
+	Block *Block `protobuf:"bytes,1,opt,name=block" json:"block,omitempty"`
+}
+
+func (m *InspectBlockRequest) Reset()                    { *m = InspectBlockRequest{} }
+func (m *InspectBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*InspectBlockRequest) ProtoMessage()               {}
+func (*InspectBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{95} }
+
+func (m *InspectBlockRequest) GetBlock() *Block {
+	if m != nil {
+		return m.Block
+	}
+	return nil
+}
+
+type ListBlockRequest struct {
+}
+
+func (m *ListBlockRequest) Reset()                    { *m = ListBlockRequest{} }
+func (m *ListBlockRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListBlockRequest) ProtoMessage()               {}
+func (*ListBlockRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{96} }
+
+type InspectDiffRequest struct {
+	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+}
+
+func (m *InspectDiffRequest) Reset()                    { *m = InspectDiffRequest{} }
+func (m *InspectDiffRequest) String() string            { return proto.CompactTextString(m) }
+func (*InspectDiffRequest) ProtoMessage()               {}
+func (*InspectDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{97} }
+
+func (m *InspectDiffRequest) GetDiff() *Diff {
+	if m != nil {
+		return m.Diff
+	}
+	return nil
+}
+
+type ListDiffRequest struct {
+	Shard uint64 `protobuf:"varint,1,opt,name=shard" json:"shard,omitempty"`
+}
+
+func (m *ListDiffRequest) Reset()                    { *m = ListDiffRequest{} }
+func (m *ListDiffRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListDiffRequest) ProtoMessage()               {}
+func (*ListDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{98} }
+
+type DeleteDiffRequest struct {
+	Diff *Diff `protobuf:"bytes,1,opt,name=diff" json:"diff,omitempty"`
+}
+
+func (m *DeleteDiffRequest) Reset()                    { *m = DeleteDiffRequest{} }
+func (m *DeleteDiffRequest) String() string            { return proto.CompactTextString(m) }
+func (*DeleteDiffRequest) ProtoMessage()               {}
+func (*DeleteDiffRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{99} }
+
+func (m *DeleteDiffRequest) GetDiff() *Diff {
+	if m != nil {
+		return m.Diff
+	}
+	return nil
+}
+
+func init() {
+	proto.RegisterType((*Repo)(nil), "pfs.Repo")
+	proto.RegisterType((*Commit)(nil), "pfs.Commit")
+	proto.RegisterType((*File)(nil), "pfs.File")
+	proto.RegisterType((*Block)(nil), "pfs.Block")
+	proto.RegisterType((*Diff)(nil), "pfs.Diff")
+	proto.RegisterType((*RepoInfo)(nil), "pfs.RepoInfo")
+	proto.RegisterType((*RepoInfos)(nil), "pfs.RepoInfos")
+	proto.RegisterType((*CommitNode)(nil), "pfs.CommitNode")
+	proto.RegisterType((*CommitInfo)(nil), "pfs.CommitInfo")
+	proto.RegisterType((*CommitInfos)(nil), "pfs.CommitInfos")
+	proto.RegisterType((*FileInfo)(nil), "pfs.FileInfo")
+	proto.RegisterType((*FileInfos)(nil), "pfs.FileInfos")
+	proto.RegisterType((*ByteRange)(nil), "pfs.ByteRange")
+	proto.RegisterType((*BlockRef)(nil), "pfs.BlockRef")
+	proto.RegisterType((*BlockRefs)(nil), "pfs.BlockRefs")
+	proto.RegisterType((*Append)(nil), "pfs.Append")
+	proto.RegisterType((*BlockInfo)(nil), "pfs.BlockInfo")
+	proto.RegisterType((*BlockInfos)(nil), "pfs.BlockInfos")
+	proto.RegisterType((*DiffInfo)(nil), "pfs.DiffInfo")
+	proto.RegisterType((*IdempotencyKeys)(nil), "pfs.IdempotencyKeys")
+	proto.RegisterType((*FileChange)(nil), "pfs.FileChange")
+	proto.RegisterType((*FileChanges)(nil), "pfs.FileChanges")
+	proto.RegisterType((*Shard)(nil), "pfs.Shard")
+	proto.RegisterType((*ShardInfo)(nil), "pfs.ShardInfo")
+	proto.RegisterType((*ShardInfos)(nil), "pfs.ShardInfos")
+	proto.RegisterType((*ShardStat)(nil), "pfs.ShardStat")
+	proto.RegisterType((*ShardStatsResponse)(nil), "pfs.ShardStatsResponse")
+	proto.RegisterType((*CreateRepoRequest)(nil), "pfs.CreateRepoRequest")
+	proto.RegisterType((*InspectRepoRequest)(nil), "pfs.InspectRepoRequest")
+	proto.RegisterType((*ListRepoRequest)(nil), "pfs.ListRepoRequest")
+	proto.RegisterType((*DeleteRepoRequest)(nil), "pfs.DeleteRepoRequest")
+	proto.RegisterType((*StartCommitRequest)(nil), "pfs.StartCommitRequest")
+	proto.RegisterType((*ReserveCommitRequest)(nil), "pfs.ReserveCommitRequest")
+	proto.RegisterType((*UnstartCommitRequest)(nil), "pfs.UnstartCommitRequest")
+	proto.RegisterType((*FinishCommitRequest)(nil), "pfs.FinishCommitRequest")
+	proto.RegisterType((*InspectCommitRequest)(nil), "pfs.InspectCommitRequest")
+	proto.RegisterType((*CommitChangedFilesRequest)(nil), "pfs.CommitChangedFilesRequest")
+	proto.RegisterType((*CommitDiffRequest)(nil), "pfs.CommitDiffRequest")
+	proto.RegisterType((*CommitManifestRequest)(nil), "pfs.CommitManifestRequest")
+	proto.RegisterType((*ManifestEntry)(nil), "pfs.ManifestEntry")
+	proto.RegisterType((*PackCommitRequest)(nil), "pfs.PackCommitRequest")
+	proto.RegisterType((*PackCommitResponse)(nil), "pfs.PackCommitResponse")
+	proto.RegisterType((*ListCommitRequest)(nil), "pfs.ListCommitRequest")
+	proto.RegisterType((*OpenCommitsRequest)(nil), "pfs.OpenCommitsRequest")
+	proto.RegisterType((*ListBranchRequest)(nil), "pfs.ListBranchRequest")
+	proto.RegisterType((*InspectBranchRequest)(nil), "pfs.InspectBranchRequest")
+	proto.RegisterType((*DeleteCommitRequest)(nil), "pfs.DeleteCommitRequest")
+	proto.RegisterType((*RestoreCommitRequest)(nil), "pfs.RestoreCommitRequest")
+	proto.RegisterType((*FlushCommitRequest)(nil), "pfs.FlushCommitRequest")
+	proto.RegisterType((*StageBlobRequest)(nil), "pfs.StageBlobRequest")
+	proto.RegisterType((*StagedBlob)(nil), "pfs.StagedBlob")
+	proto.RegisterType((*InspectStagedBlobRequest)(nil), "pfs.InspectStagedBlobRequest")
+	proto.RegisterType((*PutFileStagedRequest)(nil), "pfs.PutFileStagedRequest")
+	proto.RegisterType((*PutFileURLRequest)(nil), "pfs.PutFileURLRequest")
+	proto.RegisterType((*PutFileMultiRequest)(nil), "pfs.PutFileMultiRequest")
+	proto.RegisterType((*GetFileRequest)(nil), "pfs.GetFileRequest")
+	proto.RegisterType((*LineRange)(nil), "pfs.LineRange")
+	proto.RegisterType((*PutFileRequest)(nil), "pfs.PutFileRequest")
+	proto.RegisterType((*GetFileArchiveRequest)(nil), "pfs.GetFileArchiveRequest")
+	proto.RegisterType((*InspectFileRequest)(nil), "pfs.InspectFileRequest")
+	proto.RegisterType((*FileTypeRequest)(nil), "pfs.FileTypeRequest")
+	proto.RegisterType((*FileTypeResponse)(nil), "pfs.FileTypeResponse")
+	proto.RegisterType((*ListFileRequest)(nil), "pfs.ListFileRequest")
+	proto.RegisterType((*ListFileMultiRequest)(nil), "pfs.ListFileMultiRequest")
+	proto.RegisterType((*ListFileMultiResponse)(nil), "pfs.ListFileMultiResponse")
+	proto.RegisterType((*SetImmutableRequest)(nil), "pfs.SetImmutableRequest")
+	proto.RegisterType((*CheckMutableRequest)(nil), "pfs.CheckMutableRequest")
+	proto.RegisterType((*SetXattrRequest)(nil), "pfs.SetXattrRequest")
+	proto.RegisterType((*FileCompareAndSwapRequest)(nil), "pfs.FileCompareAndSwapRequest")
+	proto.RegisterType((*FileCompareAndSwapResponse)(nil), "pfs.FileCompareAndSwapResponse")
+	proto.RegisterType((*GetXattrRequest)(nil), "pfs.GetXattrRequest")
+	proto.RegisterType((*DeleteFileRequest)(nil), "pfs.DeleteFileRequest")
+	proto.RegisterType((*FilesExistRequest)(nil), "pfs.FilesExistRequest")
+	proto.RegisterType((*FilesExistResponse)(nil), "pfs.FilesExistResponse")
+	proto.RegisterType((*DeleteFilesRequest)(nil), "pfs.DeleteFilesRequest")
+	proto.RegisterType((*DeleteFileResult)(nil), "pfs.DeleteFileResult")
+	proto.RegisterType((*DeleteFilesResponse)(nil), "pfs.DeleteFilesResponse")
+	proto.RegisterType((*Operation)(nil), "pfs.Operation")
+	proto.RegisterType((*ValidateRequest)(nil), "pfs.ValidateRequest")
+	proto.RegisterType((*ValidateResult)(nil), "pfs.ValidateResult")
+	proto.RegisterType((*ValidateResponse)(nil), "pfs.ValidateResponse")
+	proto.RegisterType((*ExportCommitRequest)(nil), "pfs.ExportCommitRequest")
+	proto.RegisterType((*ExportRecord)(nil), "pfs.ExportRecord")
+	proto.RegisterType((*ReadShardRequest)(nil), "pfs.ReadShardRequest")
+	proto.RegisterType((*ExportToPathRequest)(nil), "pfs.ExportToPathRequest")
+	proto.RegisterType((*ExportToPathResponse)(nil), "pfs.ExportToPathResponse")
+	proto.RegisterType((*ImportCommitRequest)(nil), "pfs.ImportCommitRequest")
+	proto.RegisterType((*ListShardRequest)(nil), "pfs.ListShardRequest")
+	proto.RegisterType((*ShardStatsRequest)(nil), "pfs.ShardStatsRequest")
+	proto.RegisterType((*BlockReferencesRequest)(nil), "pfs.BlockReferencesRequest")
+	proto.RegisterType((*BlockReferencesResponse)(nil), "pfs.BlockReferencesResponse")
+	proto.RegisterType((*DumpShardRequest)(nil), "pfs.DumpShardRequest")
+	proto.RegisterType((*PutBlockRequest)(nil), "pfs.PutBlockRequest")
+	proto.RegisterType((*GetBlockRequest)(nil), "pfs.GetBlockRequest")
+	proto.RegisterType((*DeleteBlockRequest)(nil), "pfs.DeleteBlockRequest")
+	proto.RegisterType((*InspectBlockRequest)(nil), "pfs.InspectBlockRequest")
+	proto.RegisterType((*ListBlockRequest)(nil), "pfs.ListBlockRequest")
+	proto.RegisterType((*InspectDiffRequest)(nil), "pfs.InspectDiffRequest")
+	proto.RegisterType((*ListDiffRequest)(nil), "pfs.ListDiffRequest")
+	proto.RegisterType((*DeleteDiffRequest)(nil), "pfs.DeleteDiffRequest")
+	proto.RegisterEnum("pfs.CommitType", CommitType_name, CommitType_value)
+	proto.RegisterEnum("pfs.FileType", FileType_name, FileType_value)
+	proto.RegisterEnum("pfs.ChangeType", ChangeType_name, ChangeType_value)
+	proto.RegisterEnum("pfs.ShardState", ShardState_name, ShardState_value)
+	proto.RegisterEnum("pfs.Delimiter", Delimiter_name, Delimiter_value)
+	proto.RegisterEnum("pfs.OperationType", OperationType_name, OperationType_value)
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion2
+
+// Client API for API service
+
+type APIClient interface {
+	// Repo rpcs
+	// CreateRepo creates a new repo.
+	// An error is returned if the repo already exists.
+	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectRepo returns info about a repo.
+	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
+	// ListRepo returns info about all repos.
+	ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error)
+	// DeleteRepo deletes a repo.
+	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// Commit rpcs
+	// StartCommit creates a new write commit from a parent commit.
+	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error)
+	// ReserveCommit allocates a commit ID without starting the commit, the ID
+	// can then be passed to StartCommit, once. Reservations are held in
+	// memory, they're lost if a server restarts or its shards move, and they
+	// expire if they aren't used.
+	ReserveCommit(ctx context.Context, in *ReserveCommitRequest, opts ...grpc.CallOption) (*Commit, error)
+	// FinishCommit turns a write commit into a read commit.
+	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectCommit returns the info about a commit.
+	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
+	// ListCommit returns info about all commits.
+	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// OpenCommits returns info about every open commit in the cluster, in
+	// every repo.
+	OpenCommits(ctx context.Context, in *OpenCommitsRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// DeleteCommit deletes a commit.
+	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a soft deleted commit.
+	RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// FlushCommit waits for downstream commits to finish
+	FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// ListBranch returns info about the heads of branches.
+	ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// InspectBranch returns info about the head of a branch.
+	InspectBranch(ctx context.Context, in *InspectBranchRequest, opts ...grpc.CallOption) (*CommitInfo, error)
+	// CommitChangedFiles returns the regular files a commit added, modified or
+	// deleted.
+	CommitChangedFiles(ctx context.Context, in *CommitChangedFilesRequest, opts ...grpc.CallOption) (*FileChanges, error)
+	// CommitDiff returns the regular files that are in to_commit but not
+	// from_commit, in both but with different content, or in from_commit but
+	// not to_commit.
+	CommitDiff(ctx context.Context, in *CommitDiffRequest, opts ...grpc.CallOption) (*FileChanges, error)
+	// CommitManifest streams the path, size and block refs hash of every
+	// regular file in a commit, sorted by path, without their content.
+	CommitManifest(ctx context.Context, in *CommitManifestRequest, opts ...grpc.CallOption) (API_CommitManifestClient, error)
+	// PackCommit rewrites the small files in a finished commit so that they
+	// share blocks rather than each having their own. It only reduces the
+	// number of blocks the commit refers to, the blocks it stops referring to
+	// are still stored since there's no garbage collection of blocks.
+	PackCommit(ctx context.Context, in *PackCommitRequest, opts ...grpc.CallOption) (*PackCommitResponse, error)
+	// File rpcs
+	// PutFile writes the specified file to pfs.
+	PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error)
+	// PutFileURL writes the content at a URL to the specified file, the
+	// server fetches the content itself.
+	PutFileURL(ctx context.Context, in *PutFileURLRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// PutFileMulti appends several values to a file atomically, in order.
+	PutFileMulti(ctx context.Context, in *PutFileMultiRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StageBlob uploads data without putting it in a file, the returned handle
+	// can be passed to PutFileStaged until the blob expires. Staged blobs are
+	// held in memory, they're lost if a server restarts or its shards move.
+	StageBlob(ctx context.Context, opts ...grpc.CallOption) (API_StageBlobClient, error)
+	// PutFileStaged appends a staged blob to a file.
+	PutFileStaged(ctx context.Context, in *PutFileStagedRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// GetFile returns a byte stream of the contents of the file.
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error)
+	// GetFileArchive returns a byte stream of a zip archive containing a set
+	// of files.
+	GetFileArchive(ctx context.Context, in *GetFileArchiveRequest, opts ...grpc.CallOption) (API_GetFileArchiveClient, error)
+	// InspectFile returns info about a file.
+	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	// FileType returns just the type of a file, it's cheaper than InspectFile.
+	FileType(ctx context.Context, in *FileTypeRequest, opts ...grpc.CallOption) (*FileTypeResponse, error)
+	// ListFile returns info about all files.
+	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error)
+	// ListFileStream is like ListFile except the results are streamed as
+	// they're found, rather than returned all at once.
+	ListFileStream(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (API_ListFileStreamClient, error)
+	// ListFileMulti lists several paths, which may be in different repos and
+	// commits, in one call.
+	ListFileMulti(ctx context.Context, in *ListFileMultiRequest, opts ...grpc.CallOption) (*ListFileMultiResponse, error)
+	// FilesExist returns whether each of a list of files exists, it's cheaper
+	// than inspecting them.
+	FilesExist(ctx context.Context, in *FilesExistRequest, opts ...grpc.CallOption) (*FilesExistResponse, error)
+	// SetXattr sets an extended attribute on a file without rewriting its
+	// content.
+	SetXattr(ctx context.Context, in *SetXattrRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// FileCompareAndSwap replaces a file's content in an open commit if its
+	// current content has the expected hash, it's atomic with respect to
+	// every other write to the file.
+	FileCompareAndSwap(ctx context.Context, in *FileCompareAndSwapRequest, opts ...grpc.CallOption) (*FileCompareAndSwapResponse, error)
+	// SetImmutable makes a file immutable, its content can't be changed and it
+	// can't be deleted in the commit or in any of the commit's descendants.
+	// A file that was put with a TTL still expires.
+	SetImmutable(ctx context.Context, in *SetImmutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// GetXattr returns the value of one of a file's extended attributes.
+	GetXattr(ctx context.Context, in *GetXattrRequest, opts ...grpc.CallOption) (*google_protobuf3.StringValue, error)
+	// DeleteFile deletes a file.
+	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// DeleteFiles deletes many files, one file failing to be deleted doesn't
+	// stop the others from being deleted.
+	DeleteFiles(ctx context.Context, in *DeleteFilesRequest, opts ...grpc.CallOption) (*DeleteFilesResponse, error)
+	// Validate checks a batch of writes without making them, it returns
+	// whether each one would be accepted and where it would be routed.
+	Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error)
+	// ExportCommit streams every file in a finished commit, with its metadata
+	// and content.
+	ExportCommit(ctx context.Context, in *ExportCommitRequest, opts ...grpc.CallOption) (API_ExportCommitClient, error)
+	// ReadShard streams the regular files in a finished commit that belong to
+	// a shard, in the same format as ExportCommit.
+	ReadShard(ctx context.Context, in *ReadShardRequest, opts ...grpc.CallOption) (API_ReadShardClient, error)
+	// ImportCommit writes the files in a stream produced by ExportCommit to a
+	// new commit, the commit is finished once the stream ends.
+	ImportCommit(ctx context.Context, opts ...grpc.CallOption) (API_ImportCommitClient, error)
+	// ExportToPath writes the files in a finished commit to a directory tree
+	// on the node that serves it, one file at a time. PFS has no file modes so
+	// files are written with mode 0644, xattrs are set in the user namespace.
+	// It's disabled unless pachd has an export root.
+	ExportToPath(ctx context.Context, in *ExportToPathRequest, opts ...grpc.CallOption) (*ExportToPathResponse, error)
+	// Shard rpcs
+	// ListShard returns the location and state of every shard in the cluster.
+	ListShard(ctx context.Context, in *ListShardRequest, opts ...grpc.CallOption) (*ShardInfos, error)
+	// ShardStats returns storage stats for every shard in the cluster.
+	ShardStats(ctx context.Context, in *ShardStatsRequest, opts ...grpc.CallOption) (*ShardStatsResponse, error)
+	// BlockReferences returns the files that refer to a block, in every
+	// commit of every repo.
+	BlockReferences(ctx context.Context, in *BlockReferencesRequest, opts ...grpc.CallOption) (*BlockReferencesResponse, error)
+}
+
+type aPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewAPIClient(cc *grpc.ClientConn) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/CreateRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
+	out := new(RepoInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
+	out := new(RepoInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error) {
+	out := new(Commit)
+	err := grpc.Invoke(ctx, "/pfs.API/StartCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ReserveCommit(ctx context.Context, in *ReserveCommitRequest, opts ...grpc.CallOption) (*Commit, error) {
+	out := new(Commit)
+	err := grpc.Invoke(ctx, "/pfs.API/ReserveCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/FinishCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) OpenCommits(ctx context.Context, in *OpenCommitsRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/OpenCommits", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/RestoreCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/FlushCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListBranch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) InspectBranch(ctx context.Context, in *InspectBranchRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectBranch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CommitChangedFiles(ctx context.Context, in *CommitChangedFilesRequest, opts ...grpc.CallOption) (*FileChanges, error) {
+	out := new(FileChanges)
+	err := grpc.Invoke(ctx, "/pfs.API/CommitChangedFiles", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CommitDiff(ctx context.Context, in *CommitDiffRequest, opts ...grpc.CallOption) (*FileChanges, error) {
+	out := new(FileChanges)
+	err := grpc.Invoke(ctx, "/pfs.API/CommitDiff", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) CommitManifest(ctx context.Context, in *CommitManifestRequest, opts ...grpc.CallOption) (API_CommitManifestClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/pfs.API/CommitManifest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPICommitManifestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_CommitManifestClient interface {
+	Recv() (*ManifestEntry, error)
+	grpc.ClientStream
+}
+
+type aPICommitManifestClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPICommitManifestClient) Recv() (*ManifestEntry, error) {
+	m := new(ManifestEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) PackCommit(ctx context.Context, in *PackCommitRequest, opts ...grpc.CallOption) (*PackCommitResponse, error) {
+	out := new(PackCommitResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/PackCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[1], c.cc, "/pfs.API/PutFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIPutFileClient{stream}
+	return x, nil
+}
+
+type API_PutFileClient interface {
+	Send(*PutFileRequest) error
+	CloseAndRecv() (*google_protobuf1.Empty, error)
+	grpc.ClientStream
+}
+
+type aPIPutFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIPutFileClient) Send(m *PutFileRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(google_protobuf1.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) PutFileURL(ctx context.Context, in *PutFileURLRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/PutFileURL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) PutFileMulti(ctx context.Context, in *PutFileMultiRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/PutFileMulti", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) StageBlob(ctx context.Context, opts ...grpc.CallOption) (API_StageBlobClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[2], c.cc, "/pfs.API/StageBlob", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIStageBlobClient{stream}
+	return x, nil
+}
+
+type API_StageBlobClient interface {
+	Send(*StageBlobRequest) error
+	CloseAndRecv() (*StagedBlob, error)
+	grpc.ClientStream
+}
+
+type aPIStageBlobClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIStageBlobClient) Send(m *StageBlobRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIStageBlobClient) CloseAndRecv() (*StagedBlob, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StagedBlob)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) PutFileStaged(ctx context.Context, in *PutFileStagedRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/PutFileStaged", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[3], c.cc, "/pfs.API/GetFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIGetFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_GetFileClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type aPIGetFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) GetFileArchive(ctx context.Context, in *GetFileArchiveRequest, opts ...grpc.CallOption) (API_GetFileArchiveClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[4], c.cc, "/pfs.API/GetFileArchive", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIGetFileArchiveClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_GetFileArchiveClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type aPIGetFileArchiveClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIGetFileArchiveClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	out := new(FileInfo)
+	err := grpc.Invoke(ctx, "/pfs.API/InspectFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FileType(ctx context.Context, in *FileTypeRequest, opts ...grpc.CallOption) (*FileTypeResponse, error) {
+	out := new(FileTypeResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/FileType", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
+	out := new(FileInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListFileStream(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (API_ListFileStreamClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[5], c.cc, "/pfs.API/ListFileStream", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIListFileStreamClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_ListFileStreamClient interface {
+	Recv() (*FileInfo, error)
+	grpc.ClientStream
+}
+
+type aPIListFileStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIListFileStreamClient) Recv() (*FileInfo, error) {
+	m := new(FileInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) ListFileMulti(ctx context.Context, in *ListFileMultiRequest, opts ...grpc.CallOption) (*ListFileMultiResponse, error) {
+	out := new(ListFileMultiResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/ListFileMulti", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FilesExist(ctx context.Context, in *FilesExistRequest, opts ...grpc.CallOption) (*FilesExistResponse, error) {
+	out := new(FilesExistResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/FilesExist", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) SetXattr(ctx context.Context, in *SetXattrRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/SetXattr", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) FileCompareAndSwap(ctx context.Context, in *FileCompareAndSwapRequest, opts ...grpc.CallOption) (*FileCompareAndSwapResponse, error) {
+	out := new(FileCompareAndSwapResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/FileCompareAndSwap", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) SetImmutable(ctx context.Context, in *SetImmutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/SetImmutable", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) GetXattr(ctx context.Context, in *GetXattrRequest, opts ...grpc.CallOption) (*google_protobuf3.StringValue, error) {
+	out := new(google_protobuf3.StringValue)
+	err := grpc.Invoke(ctx, "/pfs.API/GetXattr", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteFile", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) DeleteFiles(ctx context.Context, in *DeleteFilesRequest, opts ...grpc.CallOption) (*DeleteFilesResponse, error) {
+	out := new(DeleteFilesResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/DeleteFiles", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Validate(ctx context.Context, in *ValidateRequest, opts ...grpc.CallOption) (*ValidateResponse, error) {
+	out := new(ValidateResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/Validate", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ExportCommit(ctx context.Context, in *ExportCommitRequest, opts ...grpc.CallOption) (API_ExportCommitClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[6], c.cc, "/pfs.API/ExportCommit", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIExportCommitClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_ExportCommitClient interface {
+	Recv() (*ExportRecord, error)
+	grpc.ClientStream
+}
+
+type aPIExportCommitClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIExportCommitClient) Recv() (*ExportRecord, error) {
+	m := new(ExportRecord)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) ReadShard(ctx context.Context, in *ReadShardRequest, opts ...grpc.CallOption) (API_ReadShardClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[7], c.cc, "/pfs.API/ReadShard", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIReadShardClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_ReadShardClient interface {
+	Recv() (*ExportRecord, error)
+	grpc.ClientStream
+}
+
+type aPIReadShardClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIReadShardClient) Recv() (*ExportRecord, error) {
+	m := new(ExportRecord)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) ImportCommit(ctx context.Context, opts ...grpc.CallOption) (API_ImportCommitClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[8], c.cc, "/pfs.API/ImportCommit", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIImportCommitClient{stream}
+	return x, nil
+}
+
+type API_ImportCommitClient interface {
+	Send(*ImportCommitRequest) error
+	CloseAndRecv() (*Commit, error)
+	grpc.ClientStream
+}
+
+type aPIImportCommitClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIImportCommitClient) Send(m *ImportCommitRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *aPIImportCommitClient) CloseAndRecv() (*Commit, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Commit)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *aPIClient) ExportToPath(ctx context.Context, in *ExportToPathRequest, opts ...grpc.CallOption) (*ExportToPathResponse, error) {
+	out := new(ExportToPathResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/ExportToPath", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListShard(ctx context.Context, in *ListShardRequest, opts ...grpc.CallOption) (*ShardInfos, error) {
+	out := new(ShardInfos)
+	err := grpc.Invoke(ctx, "/pfs.API/ListShard", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ShardStats(ctx context.Context, in *ShardStatsRequest, opts ...grpc.CallOption) (*ShardStatsResponse, error) {
+	out := new(ShardStatsResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/ShardStats", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) BlockReferences(ctx context.Context, in *BlockReferencesRequest, opts ...grpc.CallOption) (*BlockReferencesResponse, error) {
+	out := new(BlockReferencesResponse)
+	err := grpc.Invoke(ctx, "/pfs.API/BlockReferences", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for API service
+
+type APIServer interface {
+	// Repo rpcs
+	// CreateRepo creates a new repo.
+	// An error is returned if the repo already exists.
+	CreateRepo(context.Context, *CreateRepoRequest) (*google_protobuf1.Empty, error)
+	// InspectRepo returns info about a repo.
+	InspectRepo(context.Context, *InspectRepoRequest) (*RepoInfo, error)
+	// ListRepo returns info about all repos.
+	ListRepo(context.Context, *ListRepoRequest) (*RepoInfos, error)
+	// DeleteRepo deletes a repo.
+	DeleteRepo(context.Context, *DeleteRepoRequest) (*google_protobuf1.Empty, error)
+	// Commit rpcs
+	// StartCommit creates a new write commit from a parent commit.
+	StartCommit(context.Context, *StartCommitRequest) (*Commit, error)
+	// ReserveCommit allocates a commit ID without starting the commit, the ID
+	// can then be passed to StartCommit, once. Reservations are held in
+	// memory, they're lost if a server restarts or its shards move, and they
+	// expire if they aren't used.
+	ReserveCommit(context.Context, *ReserveCommitRequest) (*Commit, error)
+	// FinishCommit turns a write commit into a read commit.
+	FinishCommit(context.Context, *FinishCommitRequest) (*google_protobuf1.Empty, error)
+	// InspectCommit returns the info about a commit.
+	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
+	// ListCommit returns info about all commits.
+	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
+	// OpenCommits returns info about every open commit in the cluster, in
+	// every repo.
+	OpenCommits(context.Context, *OpenCommitsRequest) (*CommitInfos, error)
+	// DeleteCommit deletes a commit.
+	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a soft deleted commit.
+	RestoreCommit(context.Context, *RestoreCommitRequest) (*google_protobuf1.Empty, error)
+	// FlushCommit waits for downstream commits to finish
+	FlushCommit(context.Context, *FlushCommitRequest) (*CommitInfos, error)
+	// ListBranch returns info about the heads of branches.
+	ListBranch(context.Context, *ListBranchRequest) (*CommitInfos, error)
+	// InspectBranch returns info about the head of a branch.
+	InspectBranch(context.Context, *InspectBranchRequest) (*CommitInfo, error)
+	// CommitChangedFiles returns the regular files a commit added, modified or
+	// deleted.
+	CommitChangedFiles(context.Context, *CommitChangedFilesRequest) (*FileChanges, error)
+	// CommitDiff returns the regular files that are in to_commit but not
+	// from_commit, in both but with different content, or in from_commit but
+	// not to_commit.
+	CommitDiff(context.Context, *CommitDiffRequest) (*FileChanges, error)
+	// CommitManifest streams the path, size and block refs hash of every
+	// regular file in a commit, sorted by path, without their content.
+	CommitManifest(*CommitManifestRequest, API_CommitManifestServer) error
+	// PackCommit rewrites the small files in a finished commit so that they
+	// share blocks rather than each having their own. It only reduces the
+	// number of blocks the commit refers to, the blocks it stops referring to
+	// are still stored since there's no garbage collection of blocks.
+	PackCommit(context.Context, *PackCommitRequest) (*PackCommitResponse, error)
+	// File rpcs
+	// PutFile writes the specified file to pfs.
+	PutFile(API_PutFileServer) error
+	// PutFileURL writes the content at a URL to the specified file, the
+	// server fetches the content itself.
+	PutFileURL(context.Context, *PutFileURLRequest) (*google_protobuf1.Empty, error)
+	// PutFileMulti appends several values to a file atomically, in order.
+	PutFileMulti(context.Context, *PutFileMultiRequest) (*google_protobuf1.Empty, error)
+	// StageBlob uploads data without putting it in a file, the returned handle
+	// can be passed to PutFileStaged until the blob expires. Staged blobs are
+	// held in memory, they're lost if a server restarts or its shards move.
+	StageBlob(API_StageBlobServer) error
+	// PutFileStaged appends a staged blob to a file.
+	PutFileStaged(context.Context, *PutFileStagedRequest) (*google_protobuf1.Empty, error)
+	// GetFile returns a byte stream of the contents of the file.
+	GetFile(*GetFileRequest, API_GetFileServer) error
+	// GetFileArchive returns a byte stream of a zip archive containing a set
+	// of files.
+	GetFileArchive(*GetFileArchiveRequest, API_GetFileArchiveServer) error
+	// InspectFile returns info about a file.
+	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
+	// FileType returns just the type of a file, it's cheaper than InspectFile.
+	FileType(context.Context, *FileTypeRequest) (*FileTypeResponse, error)
+	// ListFile returns info about all files.
+	ListFile(context.Context, *ListFileRequest) (*FileInfos, error)
+	// ListFileStream is like ListFile except the results are streamed as
+	// they're found, rather than returned all at once.
+	ListFileStream(*ListFileRequest, API_ListFileStreamServer) error
+	// ListFileMulti lists several paths, which may be in different repos and
+	// commits, in one call.
+	ListFileMulti(context.Context, *ListFileMultiRequest) (*ListFileMultiResponse, error)
+	// FilesExist returns whether each of a list of files exists, it's cheaper
+	// than inspecting them.
+	FilesExist(context.Context, *FilesExistRequest) (*FilesExistResponse, error)
+	// SetXattr sets an extended attribute on a file without rewriting its
+	// content.
+	SetXattr(context.Context, *SetXattrRequest) (*google_protobuf1.Empty, error)
+	// FileCompareAndSwap replaces a file's content in an open commit if its
+	// current content has the expected hash, it's atomic with respect to
+	// every other write to the file.
+	FileCompareAndSwap(context.Context, *FileCompareAndSwapRequest) (*FileCompareAndSwapResponse, error)
+	// SetImmutable makes a file immutable, its content can't be changed and it
+	// can't be deleted in the commit or in any of the commit's descendants.
+	// A file that was put with a TTL still expires.
+	SetImmutable(context.Context, *SetImmutableRequest) (*google_protobuf1.Empty, error)
+	// GetXattr returns the value of one of a file's extended attributes.
+	GetXattr(context.Context, *GetXattrRequest) (*google_protobuf3.StringValue, error)
+	// DeleteFile deletes a file.
+	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+	// DeleteFiles deletes many files, one file failing to be deleted doesn't
+	// stop the others from being deleted.
+	DeleteFiles(context.Context, *DeleteFilesRequest) (*DeleteFilesResponse, error)
+	// Validate checks a batch of writes without making them, it returns
+	// whether each one would be accepted and where it would be routed.
+	Validate(context.Context, *ValidateRequest) (*ValidateResponse, error)
+	// ExportCommit streams every file in a finished commit, with its metadata
+	// and content.
+	ExportCommit(*ExportCommitRequest, API_ExportCommitServer) error
+	// ReadShard streams the regular files in a finished commit that belong to
+	// a shard, in the same format as ExportCommit.
+	ReadShard(*ReadShardRequest, API_ReadShardServer) error
+	// ImportCommit writes the files in a stream produced by ExportCommit to a
+	// new commit, the commit is finished once the stream ends.
+	ImportCommit(API_ImportCommitServer) error
+	// ExportToPath writes the files in a finished commit to a directory tree
+	// on the node that serves it, one file at a time. PFS has no file modes so
+	// files are written with mode 0644, xattrs are set in the user namespace.
+	// It's disabled unless pachd has an export root.
+	ExportToPath(context.Context, *ExportToPathRequest) (*ExportToPathResponse, error)
+	// Shard rpcs
+	// ListShard returns the location and state of every shard in the cluster.
+	ListShard(context.Context, *ListShardRequest) (*ShardInfos, error)
+	// ShardStats returns storage stats for every shard in the cluster.
+	ShardStats(context.Context, *ShardStatsRequest) (*ShardStatsResponse, error)
+	// BlockReferences returns the files that refer to a block, in every
+	// commit of every repo.
+	BlockReferences(context.Context, *BlockReferencesRequest) (*BlockReferencesResponse, error)
+}
+
+func RegisterAPIServer(s *grpc.Server, srv APIServer) {
+	s.RegisterService(&_API_serviceDesc, srv)
+}
+
+func _API_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).CreateRepo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/CreateRepo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).CreateRepo(ctx, req.(*CreateRepoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).InspectRepo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/InspectRepo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).InspectRepo(ctx, req.(*InspectRepoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListRepo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListRepo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListRepo(ctx, req.(*ListRepoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRepoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).DeleteRepo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/DeleteRepo",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).DeleteRepo(ctx, req.(*DeleteRepoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).StartCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/StartCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).StartCommit(ctx, req.(*StartCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ReserveCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ReserveCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ReserveCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ReserveCommit(ctx, req.(*ReserveCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).FinishCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/FinishCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).FinishCommit(ctx, req.(*FinishCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).InspectCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/InspectCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).InspectCommit(ctx, req.(*InspectCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListCommit(ctx, req.(*ListCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_OpenCommits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenCommitsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).OpenCommits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/OpenCommits",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).OpenCommits(ctx, req.(*OpenCommitsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).DeleteCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/DeleteCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).DeleteCommit(ctx, req.(*DeleteCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_RestoreCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).RestoreCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/RestoreCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).RestoreCommit(ctx, req.(*RestoreCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_FlushCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).FlushCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/FlushCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).FlushCommit(ctx, req.(*FlushCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListBranch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListBranch(ctx, req.(*ListBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_InspectBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectBranchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).InspectBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/InspectBranch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).InspectBranch(ctx, req.(*InspectBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_CommitChangedFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitChangedFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).CommitChangedFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/CommitChangedFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).CommitChangedFiles(ctx, req.(*CommitChangedFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_CommitDiff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitDiffRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).CommitDiff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/CommitDiff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).CommitDiff(ctx, req.(*CommitDiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_CommitManifest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CommitManifestRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).CommitManifest(m, &aPICommitManifestServer{stream})
+}
+
+type API_CommitManifestServer interface {
+	Send(*ManifestEntry) error
+	grpc.ServerStream
+}
+
+type aPICommitManifestServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPICommitManifestServer) Send(m *ManifestEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_PackCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PackCommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).PackCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/PackCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).PackCommit(ctx, req.(*PackCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).PutFile(&aPIPutFileServer{stream})
+}
+
+type API_PutFileServer interface {
+	SendAndClose(*google_protobuf1.Empty) error
+	Recv() (*PutFileRequest, error)
+	grpc.ServerStream
+}
+
+type aPIPutFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIPutFileServer) Recv() (*PutFileRequest, error) {
+	m := new(PutFileRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_PutFileURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileURLRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).PutFileURL(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/PutFileURL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).PutFileURL(ctx, req.(*PutFileURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_PutFileMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileMultiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).PutFileMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/PutFileMulti",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).PutFileMulti(ctx, req.(*PutFileMultiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_StageBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).StageBlob(&aPIStageBlobServer{stream})
+}
+
+type API_StageBlobServer interface {
+	SendAndClose(*StagedBlob) error
+	Recv() (*StageBlobRequest, error)
+	grpc.ServerStream
+}
+
+type aPIStageBlobServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIStageBlobServer) SendAndClose(m *StagedBlob) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIStageBlobServer) Recv() (*StageBlobRequest, error) {
+	m := new(StageBlobRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_PutFileStaged_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileStagedRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).PutFileStaged(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/PutFileStaged",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).PutFileStaged(ctx, req.(*PutFileStagedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).GetFile(m, &aPIGetFileServer{stream})
+}
+
+type API_GetFileServer interface {
+	Send(*google_protobuf3.BytesValue) error
+	grpc.ServerStream
+}
+
+type aPIGetFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_GetFileArchive_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileArchiveRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).GetFileArchive(m, &aPIGetFileArchiveServer{stream})
+}
+
+type API_GetFileArchiveServer interface {
+	Send(*google_protobuf3.BytesValue) error
+	grpc.ServerStream
+}
+
+type aPIGetFileArchiveServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIGetFileArchiveServer) Send(m *google_protobuf3.BytesValue) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).InspectFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/InspectFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).InspectFile(ctx, req.(*InspectFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_FileType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileTypeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).FileType(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/FileType",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).FileType(ctx, req.(*FileTypeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListFile(ctx, req.(*ListFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListFileStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ListFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).ListFileStream(m, &aPIListFileStreamServer{stream})
+}
+
+type API_ListFileStreamServer interface {
+	Send(*FileInfo) error
+	grpc.ServerStream
+}
+
+type aPIListFileStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIListFileStreamServer) Send(m *FileInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_ListFileMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFileMultiRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListFileMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListFileMulti",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListFileMulti(ctx, req.(*ListFileMultiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_FilesExist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilesExistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).FilesExist(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/FilesExist",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).FilesExist(ctx, req.(*FilesExistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_SetXattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetXattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).SetXattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/SetXattr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).SetXattr(ctx, req.(*SetXattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_FileCompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileCompareAndSwapRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).FileCompareAndSwap(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/FileCompareAndSwap",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).FileCompareAndSwap(ctx, req.(*FileCompareAndSwapRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_SetImmutable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetImmutableRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).SetImmutable(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/SetImmutable",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).SetImmutable(ctx, req.(*SetImmutableRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_GetXattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetXattrRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).GetXattr(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/GetXattr",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).GetXattr(ctx, req.(*GetXattrRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).DeleteFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/DeleteFile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_DeleteFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFilesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).DeleteFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/DeleteFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).DeleteFiles(ctx, req.(*DeleteFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Validate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Validate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/Validate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Validate(ctx, req.(*ValidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ExportCommit_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportCommitRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).ExportCommit(m, &aPIExportCommitServer{stream})
+}
+
+type API_ExportCommitServer interface {
+	Send(*ExportRecord) error
+	grpc.ServerStream
+}
+
+type aPIExportCommitServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIExportCommitServer) Send(m *ExportRecord) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_ReadShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadShardRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).ReadShard(m, &aPIReadShardServer{stream})
+}
+
+type API_ReadShardServer interface {
+	Send(*ExportRecord) error
+	grpc.ServerStream
+}
+
+type aPIReadShardServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIReadShardServer) Send(m *ExportRecord) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _API_ImportCommit_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(APIServer).ImportCommit(&aPIImportCommitServer{stream})
+}
+
+type API_ImportCommitServer interface {
+	SendAndClose(*Commit) error
+	Recv() (*ImportCommitRequest, error)
+	grpc.ServerStream
+}
+
+type aPIImportCommitServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIImportCommitServer) SendAndClose(m *Commit) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *aPIImportCommitServer) Recv() (*ImportCommitRequest, error) {
+	m := new(ImportCommitRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _API_ExportToPath_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportToPathRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ExportToPath(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ExportToPath",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ExportToPath(ctx, req.(*ExportToPathRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListShardRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListShard(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ListShard",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListShard(ctx, req.(*ListShardRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ShardStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ShardStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/ShardStats",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ShardStats(ctx, req.(*ShardStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_BlockReferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockReferencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).BlockReferences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.API/BlockReferences",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).BlockReferences(ctx, req.(*BlockReferencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _API_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "pfs.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateRepo",
+			Handler:    _API_CreateRepo_Handler,
+		},
+		{
+			MethodName: "InspectRepo",
+			Handler:    _API_InspectRepo_Handler,
+		},
+		{
+			MethodName: "ListRepo",
+			Handler:    _API_ListRepo_Handler,
+		},
+		{
+			MethodName: "DeleteRepo",
+			Handler:    _API_DeleteRepo_Handler,
+		},
+		{
+			MethodName: "StartCommit",
+			Handler:    _API_StartCommit_Handler,
+		},
+		{
+			MethodName: "ReserveCommit",
+			Handler:    _API_ReserveCommit_Handler,
+		},
+		{
+			MethodName: "FinishCommit",
+			Handler:    _API_FinishCommit_Handler,
+		},
+		{
+			MethodName: "InspectCommit",
+			Handler:    _API_InspectCommit_Handler,
+		},
+		{
+			MethodName: "ListCommit",
+			Handler:    _API_ListCommit_Handler,
+		},
+		{
+			MethodName: "OpenCommits",
+			Handler:    _API_OpenCommits_Handler,
+		},
+		{
+			MethodName: "DeleteCommit",
+			Handler:    _API_DeleteCommit_Handler,
+		},
+		{
+			MethodName: "RestoreCommit",
+			Handler:    _API_RestoreCommit_Handler,
+		},
+		{
+			MethodName: "FlushCommit",
+			Handler:    _API_FlushCommit_Handler,
+		},
+		{
+			MethodName: "ListBranch",
+			Handler:    _API_ListBranch_Handler,
+		},
+		{
+			MethodName: "InspectBranch",
+			Handler:    _API_InspectBranch_Handler,
+		},
+		{
+			MethodName: "CommitChangedFiles",
+			Handler:    _API_CommitChangedFiles_Handler,
+		},
+		{
+			MethodName: "CommitDiff",
+			Handler:    _API_CommitDiff_Handler,
+		},
+		{
+			MethodName: "PackCommit",
+			Handler:    _API_PackCommit_Handler,
+		},
+		{
+			MethodName: "PutFileURL",
+			Handler:    _API_PutFileURL_Handler,
+		},
+		{
+			MethodName: "PutFileMulti",
+			Handler:    _API_PutFileMulti_Handler,
+		},
+		{
+			MethodName: "PutFileStaged",
+			Handler:    _API_PutFileStaged_Handler,
+		},
+		{
+			MethodName: "InspectFile",
+			Handler:    _API_InspectFile_Handler,
+		},
+		{
+			MethodName: "FileType",
+			Handler:    _API_FileType_Handler,
+		},
+		{
+			MethodName: "ListFile",
+			Handler:    _API_ListFile_Handler,
+		},
+		{
+			MethodName: "ListFileMulti",
+			Handler:    _API_ListFileMulti_Handler,
+		},
+		{
+			MethodName: "FilesExist",
+			Handler:    _API_FilesExist_Handler,
+		},
+		{
+			MethodName: "SetXattr",
+			Handler:    _API_SetXattr_Handler,
+		},
+		{
+			MethodName: "FileCompareAndSwap",
+			Handler:    _API_FileCompareAndSwap_Handler,
+		},
+		{
+			MethodName: "SetImmutable",
+			Handler:    _API_SetImmutable_Handler,
+		},
+		{
+			MethodName: "GetXattr",
+			Handler:    _API_GetXattr_Handler,
+		},
+		{
+			MethodName: "DeleteFile",
+			Handler:    _API_DeleteFile_Handler,
+		},
+		{
+			MethodName: "DeleteFiles",
+			Handler:    _API_DeleteFiles_Handler,
+		},
+		{
+			MethodName: "Validate",
+			Handler:    _API_Validate_Handler,
+		},
+		{
+			MethodName: "ExportToPath",
+			Handler:    _API_ExportToPath_Handler,
+		},
+		{
+			MethodName: "ListShard",
+			Handler:    _API_ListShard_Handler,
+		},
+		{
+			MethodName: "ShardStats",
+			Handler:    _API_ShardStats_Handler,
+		},
+		{
+			MethodName: "BlockReferences",
+			Handler:    _API_BlockReferences_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CommitManifest",
+			Handler:       _API_CommitManifest_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutFile",
+			Handler:       _API_PutFile_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "StageBlob",
+			Handler:       _API_StageBlob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetFile",
+			Handler:       _API_GetFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetFileArchive",
+			Handler:       _API_GetFileArchive_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ListFileStream",
+			Handler:       _API_ListFileStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ExportCommit",
+			Handler:       _API_ExportCommit_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReadShard",
+			Handler:       _API_ReadShard_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportCommit",
+			Handler:       _API_ImportCommit_Handler,
+			ClientStreams: true,
+		},
+	},
+}
 
-// Client API for API service
+// Client API for InternalAPI service
 
-type APIClient interface {
+type InternalAPIClient interface {
 	// Repo rpcs
 	// CreateRepo creates a new repo.
 	// An error is returned if the repo already exists.
@@ -1178,179 +4628,596 @@ type APIClient interface {
 	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// Commit rpcs
 	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error)
+	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// ReserveCommit reserves a commit ID on this server.
+	ReserveCommit(ctx context.Context, in *ReserveCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// UnstartCommit undoes a StartCommit of a reserved commit on this server,
+	// the commit is removed and its ID is reserved again.
+	UnstartCommit(ctx context.Context, in *UnstartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// FinishCommit turns a write commit into a read commit.
 	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// InspectCommit returns the info about a commit.
 	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
 	// ListCommit returns info about all commits.
 	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// OpenCommits returns info about the open commits in the shards this
+	// server is responsible for.
+	OpenCommits(ctx context.Context, in *OpenCommitsRequest, opts ...grpc.CallOption) (*CommitInfos, error)
 	// DeleteCommit deletes a commit.
 	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a soft deleted commit.
+	RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
 	// FlushCommit waits for downstream commits to finish
 	FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
 	// ListBranch returns info about the heads of branches.
 	ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error)
+	// InspectBranch returns info about the head of a branch.
+	InspectBranch(ctx context.Context, in *InspectBranchRequest, opts ...grpc.CallOption) (*CommitInfo, error)
+	// CommitChangedFiles returns the regular files a commit added, modified or
+	// deleted.
+	CommitChangedFiles(ctx context.Context, in *CommitChangedFilesRequest, opts ...grpc.CallOption) (*FileChanges, error)
+	// CommitDiff returns the regular files that are in to_commit but not
+	// from_commit, in both but with different content, or in from_commit but
+	// not to_commit.
+	CommitDiff(ctx context.Context, in *CommitDiffRequest, opts ...grpc.CallOption) (*FileChanges, error)
+	// CommitManifest streams the manifest entries for the files in the shards
+	// this server is responsible for, sorted by path.
+	CommitManifest(ctx context.Context, in *CommitManifestRequest, opts ...grpc.CallOption) (InternalAPI_CommitManifestClient, error)
+	// PackCommit packs the small files in the shards this server is
+	// responsible for.
+	PackCommit(ctx context.Context, in *PackCommitRequest, opts ...grpc.CallOption) (*PackCommitResponse, error)
 	// File rpcs
 	// PutFile writes the specified file to pfs.
-	PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error)
+	PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error)
+	// PutFileURL writes the content at a URL to the specified file, the
+	// server fetches the content itself.
+	PutFileURL(ctx context.Context, in *PutFileURLRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// PutFileMulti appends several values to a file atomically, in order.
+	PutFileMulti(ctx context.Context, in *PutFileMultiRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// StageBlob uploads data without putting it in a file, the returned handle
+	// can be passed to PutFileStaged until the blob expires. Staged blobs are
+	// held in memory, they're lost if a server restarts or its shards move.
+	StageBlob(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_StageBlobClient, error)
+	// PutFileStaged appends a staged blob to a file.
+	PutFileStaged(ctx context.Context, in *PutFileStagedRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// InspectStagedBlob returns the block refs of a staged blob.
+	InspectStagedBlob(ctx context.Context, in *InspectStagedBlobRequest, opts ...grpc.CallOption) (*BlockRefs, error)
 	// GetFile returns a byte stream of the contents of the file.
-	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error)
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error)
 	// InspectFile returns info about a file.
 	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
+	// FileType returns just the type of a file, it's cheaper than InspectFile.
+	FileType(ctx context.Context, in *FileTypeRequest, opts ...grpc.CallOption) (*FileTypeResponse, error)
 	// ListFile returns info about all files.
 	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error)
+	// FilesExist returns whether each of a list of files exists, it's cheaper
+	// than inspecting them.
+	FilesExist(ctx context.Context, in *FilesExistRequest, opts ...grpc.CallOption) (*FilesExistResponse, error)
+	// SetXattr sets an extended attribute on a file without rewriting its
+	// content.
+	SetXattr(ctx context.Context, in *SetXattrRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// FileCompareAndSwap replaces a file's content in an open commit if its
+	// current content has the expected hash, it's atomic with respect to
+	// every other write to the file.
+	FileCompareAndSwap(ctx context.Context, in *FileCompareAndSwapRequest, opts ...grpc.CallOption) (*FileCompareAndSwapResponse, error)
+	// SetImmutable makes a file immutable, its content can't be changed and it
+	// can't be deleted in the commit or in any of the commit's descendants.
+	// A file that was put with a TTL still expires.
+	SetImmutable(ctx context.Context, in *SetImmutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// CheckMutable returns an error if a file, or any file under it, is
+	// immutable on one of the shards this server is responsible for.
+	CheckMutable(ctx context.Context, in *CheckMutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// GetXattr returns the value of one of a file's extended attributes.
+	GetXattr(ctx context.Context, in *GetXattrRequest, opts ...grpc.CallOption) (*google_protobuf3.StringValue, error)
 	// DeleteFile deletes a file.
 	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
+	// DeleteFiles deletes many files, one file failing to be deleted doesn't
+	// stop the others from being deleted.
+	DeleteFiles(ctx context.Context, in *DeleteFilesRequest, opts ...grpc.CallOption) (*DeleteFilesResponse, error)
+	// ExportCommit streams every file in a finished commit, with its metadata
+	// and content.
+	ExportCommit(ctx context.Context, in *ExportCommitRequest, opts ...grpc.CallOption) (InternalAPI_ExportCommitClient, error)
+	// ReadShard streams the regular files in a finished commit that belong to
+	// a shard, in the same format as ExportCommit.
+	ReadShard(ctx context.Context, in *ReadShardRequest, opts ...grpc.CallOption) (InternalAPI_ReadShardClient, error)
+	// Shard rpcs
+	// ListShard returns the state of the shards this server is responsible for.
+	ListShard(ctx context.Context, in *ListShardRequest, opts ...grpc.CallOption) (*ShardInfos, error)
+	// ShardStats returns storage stats for the shards this server is
+	// responsible for.
+	ShardStats(ctx context.Context, in *ShardStatsRequest, opts ...grpc.CallOption) (*ShardStatsResponse, error)
+	// BlockReferences returns the files that refer to a block in the shards
+	// this server is responsible for.
+	BlockReferences(ctx context.Context, in *BlockReferencesRequest, opts ...grpc.CallOption) (*BlockReferencesResponse, error)
+	// DumpShard streams the raw diffs this server has for a shard, repo by
+	// repo with parents before children. It's a read only diagnostic.
+	DumpShard(ctx context.Context, in *DumpShardRequest, opts ...grpc.CallOption) (InternalAPI_DumpShardClient, error)
+}
+
+type internalAPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewInternalAPIClient(cc *grpc.ClientConn) InternalAPIClient {
+	return &internalAPIClient{cc}
+}
+
+func (c *internalAPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CreateRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
+	out := new(RepoInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
+	out := new(RepoInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteRepo", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StartCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ReserveCommit(ctx context.Context, in *ReserveCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ReserveCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) UnstartCommit(ctx context.Context, in *UnstartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/UnstartCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FinishCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) OpenCommits(ctx context.Context, in *OpenCommitsRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/OpenCommits", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) RestoreCommit(ctx context.Context, in *RestoreCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/RestoreCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FlushCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
+	out := new(CommitInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListBranch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) InspectBranch(ctx context.Context, in *InspectBranchRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
+	out := new(CommitInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectBranch", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) CommitChangedFiles(ctx context.Context, in *CommitChangedFilesRequest, opts ...grpc.CallOption) (*FileChanges, error) {
+	out := new(FileChanges)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CommitChangedFiles", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) CommitDiff(ctx context.Context, in *CommitDiffRequest, opts ...grpc.CallOption) (*FileChanges, error) {
+	out := new(FileChanges)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CommitDiff", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) CommitManifest(ctx context.Context, in *CommitManifestRequest, opts ...grpc.CallOption) (InternalAPI_CommitManifestClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[0], c.cc, "/pfs.InternalAPI/CommitManifest", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPICommitManifestClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InternalAPI_CommitManifestClient interface {
+	Recv() (*ManifestEntry, error)
+	grpc.ClientStream
+}
+
+type internalAPICommitManifestClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPICommitManifestClient) Recv() (*ManifestEntry, error) {
+	m := new(ManifestEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *internalAPIClient) PackCommit(ctx context.Context, in *PackCommitRequest, opts ...grpc.CallOption) (*PackCommitResponse, error) {
+	out := new(PackCommitResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/PackCommit", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[1], c.cc, "/pfs.InternalAPI/PutFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIPutFileClient{stream}
+	return x, nil
+}
+
+type InternalAPI_PutFileClient interface {
+	Send(*PutFileRequest) error
+	CloseAndRecv() (*google_protobuf1.Empty, error)
+	grpc.ClientStream
+}
+
+type internalAPIPutFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPIPutFileClient) Send(m *PutFileRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *internalAPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(google_protobuf1.Empty)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *internalAPIClient) PutFileURL(ctx context.Context, in *PutFileURLRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/PutFileURL", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) PutFileMulti(ctx context.Context, in *PutFileMultiRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/PutFileMulti", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) StageBlob(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_StageBlobClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[2], c.cc, "/pfs.InternalAPI/StageBlob", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIStageBlobClient{stream}
+	return x, nil
+}
+
+type InternalAPI_StageBlobClient interface {
+	Send(*StageBlobRequest) error
+	CloseAndRecv() (*StagedBlob, error)
+	grpc.ClientStream
+}
+
+type internalAPIStageBlobClient struct {
+	grpc.ClientStream
 }
 
-type aPIClient struct {
-	cc *grpc.ClientConn
+func (x *internalAPIStageBlobClient) Send(m *StageBlobRequest) error {
+	return x.ClientStream.SendMsg(m)
 }
 
-func NewAPIClient(cc *grpc.ClientConn) APIClient {
-	return &aPIClient{cc}
+func (x *internalAPIStageBlobClient) CloseAndRecv() (*StagedBlob, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(StagedBlob)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (c *aPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+func (c *internalAPIClient) PutFileStaged(ctx context.Context, in *PutFileStagedRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/CreateRepo", in, out, c.cc, opts...)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/PutFileStaged", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
-	out := new(RepoInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectRepo", in, out, c.cc, opts...)
+func (c *internalAPIClient) InspectStagedBlob(ctx context.Context, in *InspectStagedBlobRequest, opts ...grpc.CallOption) (*BlockRefs, error) {
+	out := new(BlockRefs)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectStagedBlob", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
-	out := new(RepoInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListRepo", in, out, c.cc, opts...)
+func (c *internalAPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[3], c.cc, "/pfs.InternalAPI/GetFile", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIGetFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type InternalAPI_GetFileClient interface {
+	Recv() (*google_protobuf3.BytesValue, error)
+	grpc.ClientStream
+}
+
+type internalAPIGetFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
+	m := new(google_protobuf3.BytesValue)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *internalAPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
+	out := new(FileInfo)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteRepo", in, out, c.cc, opts...)
+func (c *internalAPIClient) FileType(ctx context.Context, in *FileTypeRequest, opts ...grpc.CallOption) (*FileTypeResponse, error) {
+	out := new(FileTypeResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FileType", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*Commit, error) {
-	out := new(Commit)
-	err := grpc.Invoke(ctx, "/pfs.API/StartCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
+	out := new(FileInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+func (c *internalAPIClient) FilesExist(ctx context.Context, in *FilesExistRequest, opts ...grpc.CallOption) (*FilesExistResponse, error) {
+	out := new(FilesExistResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FilesExist", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *internalAPIClient) SetXattr(ctx context.Context, in *SetXattrRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/FinishCommit", in, out, c.cc, opts...)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/SetXattr", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
-	out := new(CommitInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) FileCompareAndSwap(ctx context.Context, in *FileCompareAndSwapRequest, opts ...grpc.CallOption) (*FileCompareAndSwapResponse, error) {
+	out := new(FileCompareAndSwapResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FileCompareAndSwap", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) SetImmutable(ctx context.Context, in *SetImmutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/SetImmutable", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+func (c *internalAPIClient) CheckMutable(ctx context.Context, in *CheckMutableRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
 	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteCommit", in, out, c.cc, opts...)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CheckMutable", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/FlushCommit", in, out, c.cc, opts...)
+func (c *internalAPIClient) GetXattr(ctx context.Context, in *GetXattrRequest, opts ...grpc.CallOption) (*google_protobuf3.StringValue, error) {
+	out := new(google_protobuf3.StringValue)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/GetXattr", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListBranch", in, out, c.cc, opts...)
+func (c *internalAPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
+	out := new(google_protobuf1.Empty)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteFile", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (API_PutFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[0], c.cc, "/pfs.API/PutFile", opts...)
+func (c *internalAPIClient) DeleteFiles(ctx context.Context, in *DeleteFilesRequest, opts ...grpc.CallOption) (*DeleteFilesResponse, error) {
+	out := new(DeleteFilesResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteFiles", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &aPIPutFileClient{stream}
-	return x, nil
+	return out, nil
 }
 
-type API_PutFileClient interface {
-	Send(*PutFileRequest) error
-	CloseAndRecv() (*google_protobuf1.Empty, error)
-	grpc.ClientStream
+func (c *internalAPIClient) ExportCommit(ctx context.Context, in *ExportCommitRequest, opts ...grpc.CallOption) (InternalAPI_ExportCommitClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[4], c.cc, "/pfs.InternalAPI/ExportCommit", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIExportCommitClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
 }
 
-type aPIPutFileClient struct {
+type InternalAPI_ExportCommitClient interface {
+	Recv() (*ExportRecord, error)
 	grpc.ClientStream
 }
 
-func (x *aPIPutFileClient) Send(m *PutFileRequest) error {
-	return x.ClientStream.SendMsg(m)
+type internalAPIExportCommitClient struct {
+	grpc.ClientStream
 }
 
-func (x *aPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(google_protobuf1.Empty)
+func (x *internalAPIExportCommitClient) Recv() (*ExportRecord, error) {
+	m := new(ExportRecord)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *aPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (API_GetFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_API_serviceDesc.Streams[1], c.cc, "/pfs.API/GetFile", opts...)
+func (c *internalAPIClient) ReadShard(ctx context.Context, in *ReadShardRequest, opts ...grpc.CallOption) (InternalAPI_ReadShardClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[5], c.cc, "/pfs.InternalAPI/ReadShard", opts...)
 	if err != nil {
 		return nil, err
 	}
-	x := &aPIGetFileClient{stream}
+	x := &internalAPIReadShardClient{stream}
 	if err := x.ClientStream.SendMsg(in); err != nil {
 		return nil, err
 	}
@@ -1360,53 +5227,85 @@ func (c *aPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grp
 	return x, nil
 }
 
-type API_GetFileClient interface {
-	Recv() (*google_protobuf3.BytesValue, error)
+type InternalAPI_ReadShardClient interface {
+	Recv() (*ExportRecord, error)
 	grpc.ClientStream
 }
 
-type aPIGetFileClient struct {
+type internalAPIReadShardClient struct {
 	grpc.ClientStream
 }
 
-func (x *aPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
-	m := new(google_protobuf3.BytesValue)
+func (x *internalAPIReadShardClient) Recv() (*ExportRecord, error) {
+	m := new(ExportRecord)
 	if err := x.ClientStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *aPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
-	out := new(FileInfo)
-	err := grpc.Invoke(ctx, "/pfs.API/InspectFile", in, out, c.cc, opts...)
+func (c *internalAPIClient) ListShard(ctx context.Context, in *ListShardRequest, opts ...grpc.CallOption) (*ShardInfos, error) {
+	out := new(ShardInfos)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListShard", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
-	out := new(FileInfos)
-	err := grpc.Invoke(ctx, "/pfs.API/ListFile", in, out, c.cc, opts...)
+func (c *internalAPIClient) ShardStats(ctx context.Context, in *ShardStatsRequest, opts ...grpc.CallOption) (*ShardStatsResponse, error) {
+	out := new(ShardStatsResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ShardStats", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-func (c *aPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.API/DeleteFile", in, out, c.cc, opts...)
+func (c *internalAPIClient) BlockReferences(ctx context.Context, in *BlockReferencesRequest, opts ...grpc.CallOption) (*BlockReferencesResponse, error) {
+	out := new(BlockReferencesResponse)
+	err := grpc.Invoke(ctx, "/pfs.InternalAPI/BlockReferences", in, out, c.cc, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return out, nil
 }
 
-// Server API for API service
+func (c *internalAPIClient) DumpShard(ctx context.Context, in *DumpShardRequest, opts ...grpc.CallOption) (InternalAPI_DumpShardClient, error) {
+	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[6], c.cc, "/pfs.InternalAPI/DumpShard", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &internalAPIDumpShardClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
 
-type APIServer interface {
+type InternalAPI_DumpShardClient interface {
+	Recv() (*DiffInfo, error)
+	grpc.ClientStream
+}
+
+type internalAPIDumpShardClient struct {
+	grpc.ClientStream
+}
+
+func (x *internalAPIDumpShardClient) Recv() (*DiffInfo, error) {
+	m := new(DiffInfo)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Server API for InternalAPI service
+
+type InternalAPIServer interface {
 	// Repo rpcs
 	// CreateRepo creates a new repo.
 	// An error is returned if the repo already exists.
@@ -1419,995 +5318,939 @@ type APIServer interface {
 	DeleteRepo(context.Context, *DeleteRepoRequest) (*google_protobuf1.Empty, error)
 	// Commit rpcs
 	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(context.Context, *StartCommitRequest) (*Commit, error)
+	StartCommit(context.Context, *StartCommitRequest) (*google_protobuf1.Empty, error)
+	// ReserveCommit reserves a commit ID on this server.
+	ReserveCommit(context.Context, *ReserveCommitRequest) (*google_protobuf1.Empty, error)
+	// UnstartCommit undoes a StartCommit of a reserved commit on this server,
+	// the commit is removed and its ID is reserved again.
+	UnstartCommit(context.Context, *UnstartCommitRequest) (*google_protobuf1.Empty, error)
 	// FinishCommit turns a write commit into a read commit.
 	FinishCommit(context.Context, *FinishCommitRequest) (*google_protobuf1.Empty, error)
 	// InspectCommit returns the info about a commit.
 	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
 	// ListCommit returns info about all commits.
 	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
+	// OpenCommits returns info about the open commits in the shards this
+	// server is responsible for.
+	OpenCommits(context.Context, *OpenCommitsRequest) (*CommitInfos, error)
 	// DeleteCommit deletes a commit.
 	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
+	// RestoreCommit restores a soft deleted commit.
+	RestoreCommit(context.Context, *RestoreCommitRequest) (*google_protobuf1.Empty, error)
 	// FlushCommit waits for downstream commits to finish
 	FlushCommit(context.Context, *FlushCommitRequest) (*CommitInfos, error)
 	// ListBranch returns info about the heads of branches.
 	ListBranch(context.Context, *ListBranchRequest) (*CommitInfos, error)
+	// InspectBranch returns info about the head of a branch.
+	InspectBranch(context.Context, *InspectBranchRequest) (*CommitInfo, error)
+	// CommitChangedFiles returns the regular files a commit added, modified or
+	// deleted.
+	CommitChangedFiles(context.Context, *CommitChangedFilesRequest) (*FileChanges, error)
+	// CommitDiff returns the regular files that are in to_commit but not
+	// from_commit, in both but with different content, or in from_commit but
+	// not to_commit.
+	CommitDiff(context.Context, *CommitDiffRequest) (*FileChanges, error)
+	// CommitManifest streams the manifest entries for the files in the shards
+	// this server is responsible for, sorted by path.
+	CommitManifest(*CommitManifestRequest, InternalAPI_CommitManifestServer) error
+	// PackCommit packs the small files in the shards this server is
+	// responsible for.
+	PackCommit(context.Context, *PackCommitRequest) (*PackCommitResponse, error)
 	// File rpcs
 	// PutFile writes the specified file to pfs.
-	PutFile(API_PutFileServer) error
+	PutFile(InternalAPI_PutFileServer) error
+	// PutFileURL writes the content at a URL to the specified file, the
+	// server fetches the content itself.
+	PutFileURL(context.Context, *PutFileURLRequest) (*google_protobuf1.Empty, error)
+	// PutFileMulti appends several values to a file atomically, in order.
+	PutFileMulti(context.Context, *PutFileMultiRequest) (*google_protobuf1.Empty, error)
+	// StageBlob uploads data without putting it in a file, the returned handle
+	// can be passed to PutFileStaged until the blob expires. Staged blobs are
+	// held in memory, they're lost if a server restarts or its shards move.
+	StageBlob(InternalAPI_StageBlobServer) error
+	// PutFileStaged appends a staged blob to a file.
+	PutFileStaged(context.Context, *PutFileStagedRequest) (*google_protobuf1.Empty, error)
+	// InspectStagedBlob returns the block refs of a staged blob.
+	InspectStagedBlob(context.Context, *InspectStagedBlobRequest) (*BlockRefs, error)
 	// GetFile returns a byte stream of the contents of the file.
-	GetFile(*GetFileRequest, API_GetFileServer) error
+	GetFile(*GetFileRequest, InternalAPI_GetFileServer) error
 	// InspectFile returns info about a file.
 	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
+	// FileType returns just the type of a file, it's cheaper than InspectFile.
+	FileType(context.Context, *FileTypeRequest) (*FileTypeResponse, error)
 	// ListFile returns info about all files.
 	ListFile(context.Context, *ListFileRequest) (*FileInfos, error)
+	// FilesExist returns whether each of a list of files exists, it's cheaper
+	// than inspecting them.
+	FilesExist(context.Context, *FilesExistRequest) (*FilesExistResponse, error)
+	// SetXattr sets an extended attribute on a file without rewriting its
+	// content.
+	SetXattr(context.Context, *SetXattrRequest) (*google_protobuf1.Empty, error)
+	// FileCompareAndSwap replaces a file's content in an open commit if its
+	// current content has the expected hash, it's atomic with respect to
+	// every other write to the file.
+	FileCompareAndSwap(context.Context, *FileCompareAndSwapRequest) (*FileCompareAndSwapResponse, error)
+	// SetImmutable makes a file immutable, its content can't be changed and it
+	// can't be deleted in the commit or in any of the commit's descendants.
+	// A file that was put with a TTL still expires.
+	SetImmutable(context.Context, *SetImmutableRequest) (*google_protobuf1.Empty, error)
+	// CheckMutable returns an error if a file, or any file under it, is
+	// immutable on one of the shards this server is responsible for.
+	CheckMutable(context.Context, *CheckMutableRequest) (*google_protobuf1.Empty, error)
+	// GetXattr returns the value of one of a file's extended attributes.
+	GetXattr(context.Context, *GetXattrRequest) (*google_protobuf3.StringValue, error)
 	// DeleteFile deletes a file.
 	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+	// DeleteFiles deletes many files, one file failing to be deleted doesn't
+	// stop the others from being deleted.
+	DeleteFiles(context.Context, *DeleteFilesRequest) (*DeleteFilesResponse, error)
+	// ExportCommit streams every file in a finished commit, with its metadata
+	// and content.
+	ExportCommit(*ExportCommitRequest, InternalAPI_ExportCommitServer) error
+	// ReadShard streams the regular files in a finished commit that belong to
+	// a shard, in the same format as ExportCommit.
+	ReadShard(*ReadShardRequest, InternalAPI_ReadShardServer) error
+	// Shard rpcs
+	// ListShard returns the state of the shards this server is responsible for.
+	ListShard(context.Context, *ListShardRequest) (*ShardInfos, error)
+	// ShardStats returns storage stats for the shards this server is
+	// responsible for.
+	ShardStats(context.Context, *ShardStatsRequest) (*ShardStatsResponse, error)
+	// BlockReferences returns the files that refer to a block in the shards
+	// this server is responsible for.
+	BlockReferences(context.Context, *BlockReferencesRequest) (*BlockReferencesResponse, error)
+	// DumpShard streams the raw diffs this server has for a shard, repo by
+	// repo with parents before children. It's a read only diagnostic.
+	DumpShard(*DumpShardRequest, InternalAPI_DumpShardServer) error
 }
 
-func RegisterAPIServer(s *grpc.Server, srv APIServer) {
-	s.RegisterService(&_API_serviceDesc, srv)
+func RegisterInternalAPIServer(s *grpc.Server, srv InternalAPIServer) {
+	s.RegisterService(&_InternalAPI_serviceDesc, srv)
 }
 
-func _API_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _InternalAPI_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(CreateRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).CreateRepo(ctx, in)
+		return srv.(InternalAPIServer).CreateRepo(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/CreateRepo",
+		FullMethod: "/pfs.InternalAPI/CreateRepo",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).CreateRepo(ctx, req.(*CreateRepoRequest))
+		return srv.(InternalAPIServer).CreateRepo(ctx, req.(*CreateRepoRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+func _InternalAPI_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(InspectRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).InspectRepo(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pfs.API/InspectRepo",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).InspectRepo(ctx, req.(*InspectRepoRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _API_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListRepoRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(APIServer).ListRepo(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pfs.API/ListRepo",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).ListRepo(ctx, req.(*ListRepoRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _API_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteRepoRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(APIServer).DeleteRepo(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pfs.API/DeleteRepo",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).DeleteRepo(ctx, req.(*DeleteRepoRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _API_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(StartCommitRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(APIServer).StartCommit(ctx, in)
-	}
-	info := &grpc.UnaryServerInfo{
-		Server:     srv,
-		FullMethod: "/pfs.API/StartCommit",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).StartCommit(ctx, req.(*StartCommitRequest))
-	}
-	return interceptor(ctx, in, info, handler)
-}
-
-func _API_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(FinishCommitRequest)
-	if err := dec(in); err != nil {
-		return nil, err
-	}
-	if interceptor == nil {
-		return srv.(APIServer).FinishCommit(ctx, in)
+		return srv.(InternalAPIServer).InspectRepo(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/FinishCommit",
+		FullMethod: "/pfs.InternalAPI/InspectRepo",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).FinishCommit(ctx, req.(*FinishCommitRequest))
+		return srv.(InternalAPIServer).InspectRepo(ctx, req.(*InspectRepoRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InspectCommitRequest)
+func _InternalAPI_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).InspectCommit(ctx, in)
+		return srv.(InternalAPIServer).ListRepo(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/InspectCommit",
+		FullMethod: "/pfs.InternalAPI/ListRepo",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).InspectCommit(ctx, req.(*InspectCommitRequest))
+		return srv.(InternalAPIServer).ListRepo(ctx, req.(*ListRepoRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListCommitRequest)
+func _InternalAPI_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRepoRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).ListCommit(ctx, in)
+		return srv.(InternalAPIServer).DeleteRepo(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/ListCommit",
+		FullMethod: "/pfs.InternalAPI/DeleteRepo",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).ListCommit(ctx, req.(*ListCommitRequest))
+		return srv.(InternalAPIServer).DeleteRepo(ctx, req.(*DeleteRepoRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteCommitRequest)
+func _InternalAPI_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).DeleteCommit(ctx, in)
+		return srv.(InternalAPIServer).StartCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/DeleteCommit",
+		FullMethod: "/pfs.InternalAPI/StartCommit",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).DeleteCommit(ctx, req.(*DeleteCommitRequest))
+		return srv.(InternalAPIServer).StartCommit(ctx, req.(*StartCommitRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_FlushCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(FlushCommitRequest)
+func _InternalAPI_ReserveCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).FlushCommit(ctx, in)
+		return srv.(InternalAPIServer).ReserveCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/FlushCommit",
+		FullMethod: "/pfs.InternalAPI/ReserveCommit",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).FlushCommit(ctx, req.(*FlushCommitRequest))
+		return srv.(InternalAPIServer).ReserveCommit(ctx, req.(*ReserveCommitRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_ListBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListBranchRequest)
+func _InternalAPI_UnstartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UnstartCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).ListBranch(ctx, in)
+		return srv.(InternalAPIServer).UnstartCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/ListBranch",
+		FullMethod: "/pfs.InternalAPI/UnstartCommit",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).ListBranch(ctx, req.(*ListBranchRequest))
+		return srv.(InternalAPIServer).UnstartCommit(ctx, req.(*UnstartCommitRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(APIServer).PutFile(&aPIPutFileServer{stream})
-}
-
-type API_PutFileServer interface {
-	SendAndClose(*google_protobuf1.Empty) error
-	Recv() (*PutFileRequest, error)
-	grpc.ServerStream
-}
-
-type aPIPutFileServer struct {
-	grpc.ServerStream
-}
-
-func (x *aPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
-	return x.ServerStream.SendMsg(m)
-}
-
-func (x *aPIPutFileServer) Recv() (*PutFileRequest, error) {
-	m := new(PutFileRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
-
-func _API_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(GetFileRequest)
-	if err := stream.RecvMsg(m); err != nil {
-		return err
-	}
-	return srv.(APIServer).GetFile(m, &aPIGetFileServer{stream})
-}
-
-type API_GetFileServer interface {
-	Send(*google_protobuf3.BytesValue) error
-	grpc.ServerStream
-}
-
-type aPIGetFileServer struct {
-	grpc.ServerStream
-}
-
-func (x *aPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
-	return x.ServerStream.SendMsg(m)
-}
-
-func _API_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InspectFileRequest)
+func _InternalAPI_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FinishCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).InspectFile(ctx, in)
+		return srv.(InternalAPIServer).FinishCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/InspectFile",
+		FullMethod: "/pfs.InternalAPI/FinishCommit",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).InspectFile(ctx, req.(*InspectFileRequest))
+		return srv.(InternalAPIServer).FinishCommit(ctx, req.(*FinishCommitRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListFileRequest)
+func _InternalAPI_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).ListFile(ctx, in)
+		return srv.(InternalAPIServer).InspectCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/ListFile",
+		FullMethod: "/pfs.InternalAPI/InspectCommit",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).ListFile(ctx, req.(*ListFileRequest))
+		return srv.(InternalAPIServer).InspectCommit(ctx, req.(*InspectCommitRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _API_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteFileRequest)
+func _InternalAPI_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListCommitRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(APIServer).DeleteFile(ctx, in)
+		return srv.(InternalAPIServer).ListCommit(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.API/DeleteFile",
-	}
-	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(APIServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+		FullMethod: "/pfs.InternalAPI/ListCommit",
 	}
-	return interceptor(ctx, in, info, handler)
-}
-
-var _API_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "pfs.API",
-	HandlerType: (*APIServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "CreateRepo",
-			Handler:    _API_CreateRepo_Handler,
-		},
-		{
-			MethodName: "InspectRepo",
-			Handler:    _API_InspectRepo_Handler,
-		},
-		{
-			MethodName: "ListRepo",
-			Handler:    _API_ListRepo_Handler,
-		},
-		{
-			MethodName: "DeleteRepo",
-			Handler:    _API_DeleteRepo_Handler,
-		},
-		{
-			MethodName: "StartCommit",
-			Handler:    _API_StartCommit_Handler,
-		},
-		{
-			MethodName: "FinishCommit",
-			Handler:    _API_FinishCommit_Handler,
-		},
-		{
-			MethodName: "InspectCommit",
-			Handler:    _API_InspectCommit_Handler,
-		},
-		{
-			MethodName: "ListCommit",
-			Handler:    _API_ListCommit_Handler,
-		},
-		{
-			MethodName: "DeleteCommit",
-			Handler:    _API_DeleteCommit_Handler,
-		},
-		{
-			MethodName: "FlushCommit",
-			Handler:    _API_FlushCommit_Handler,
-		},
-		{
-			MethodName: "ListBranch",
-			Handler:    _API_ListBranch_Handler,
-		},
-		{
-			MethodName: "InspectFile",
-			Handler:    _API_InspectFile_Handler,
-		},
-		{
-			MethodName: "ListFile",
-			Handler:    _API_ListFile_Handler,
-		},
-		{
-			MethodName: "DeleteFile",
-			Handler:    _API_DeleteFile_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "PutFile",
-			Handler:       _API_PutFile_Handler,
-			ClientStreams: true,
-		},
-		{
-			StreamName:    "GetFile",
-			Handler:       _API_GetFile_Handler,
-			ServerStreams: true,
-		},
-	},
-}
-
-// Client API for InternalAPI service
-
-type InternalAPIClient interface {
-	// Repo rpcs
-	// CreateRepo creates a new repo.
-	// An error is returned if the repo already exists.
-	CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// InspectRepo returns info about a repo.
-	InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error)
-	// ListRepo returns info about all repos.
-	ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// Commit rpcs
-	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// FinishCommit turns a write commit into a read commit.
-	FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// InspectCommit returns the info about a commit.
-	InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error)
-	// ListCommit returns info about all commits.
-	ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
-	DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-	// FlushCommit waits for downstream commits to finish
-	FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error)
-	// ListBranch returns info about the heads of branches.
-	ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error)
-	// File rpcs
-	// PutFile writes the specified file to pfs.
-	PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error)
-	// GetFile returns a byte stream of the contents of the file.
-	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error)
-	// InspectFile returns info about a file.
-	InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error)
-	// DeleteFile deletes a file.
-	DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error)
-}
-
-type internalAPIClient struct {
-	cc *grpc.ClientConn
-}
-
-func NewInternalAPIClient(cc *grpc.ClientConn) InternalAPIClient {
-	return &internalAPIClient{cc}
-}
-
-func (c *internalAPIClient) CreateRepo(ctx context.Context, in *CreateRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/CreateRepo", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).ListCommit(ctx, req.(*ListCommitRequest))
 	}
-	return out, nil
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) InspectRepo(ctx context.Context, in *InspectRepoRequest, opts ...grpc.CallOption) (*RepoInfo, error) {
-	out := new(RepoInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectRepo", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_OpenCommits_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OpenCommitsRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).OpenCommits(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/OpenCommits",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).OpenCommits(ctx, req.(*OpenCommitsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) ListRepo(ctx context.Context, in *ListRepoRequest, opts ...grpc.CallOption) (*RepoInfos, error) {
-	out := new(RepoInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListRepo", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteCommitRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).DeleteCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/DeleteCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).DeleteCommit(ctx, req.(*DeleteCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) DeleteRepo(ctx context.Context, in *DeleteRepoRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteRepo", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_RestoreCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RestoreCommitRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).RestoreCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/RestoreCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).RestoreCommit(ctx, req.(*RestoreCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) StartCommit(ctx context.Context, in *StartCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/StartCommit", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_FlushCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FlushCommitRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).FlushCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/FlushCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).FlushCommit(ctx, req.(*FlushCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) FinishCommit(ctx context.Context, in *FinishCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FinishCommit", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_ListBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListBranchRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).ListBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/ListBranch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).ListBranch(ctx, req.(*ListBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) InspectCommit(ctx context.Context, in *InspectCommitRequest, opts ...grpc.CallOption) (*CommitInfo, error) {
-	out := new(CommitInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectCommit", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_InspectBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectBranchRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).InspectBranch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/InspectBranch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).InspectBranch(ctx, req.(*InspectBranchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) ListCommit(ctx context.Context, in *ListCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListCommit", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_CommitChangedFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitChangedFilesRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).CommitChangedFiles(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/CommitChangedFiles",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).CommitChangedFiles(ctx, req.(*CommitChangedFilesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) DeleteCommit(ctx context.Context, in *DeleteCommitRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteCommit", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_CommitDiff_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitDiffRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).CommitDiff(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/CommitDiff",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).CommitDiff(ctx, req.(*CommitDiffRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) FlushCommit(ctx context.Context, in *FlushCommitRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/FlushCommit", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func _InternalAPI_CommitManifest_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(CommitManifestRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	return out, nil
+	return srv.(InternalAPIServer).CommitManifest(m, &internalAPICommitManifestServer{stream})
 }
 
-func (c *internalAPIClient) ListBranch(ctx context.Context, in *ListBranchRequest, opts ...grpc.CallOption) (*CommitInfos, error) {
-	out := new(CommitInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListBranch", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
-	}
-	return out, nil
+type InternalAPI_CommitManifestServer interface {
+	Send(*ManifestEntry) error
+	grpc.ServerStream
 }
 
-func (c *internalAPIClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (InternalAPI_PutFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[0], c.cc, "/pfs.InternalAPI/PutFile", opts...)
-	if err != nil {
+type internalAPICommitManifestServer struct {
+	grpc.ServerStream
+}
+
+func (x *internalAPICommitManifestServer) Send(m *ManifestEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _InternalAPI_PackCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PackCommitRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	x := &internalAPIPutFileClient{stream}
-	return x, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).PackCommit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/PackCommit",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).PackCommit(ctx, req.(*PackCommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-type InternalAPI_PutFileClient interface {
-	Send(*PutFileRequest) error
-	CloseAndRecv() (*google_protobuf1.Empty, error)
-	grpc.ClientStream
+func _InternalAPI_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InternalAPIServer).PutFile(&internalAPIPutFileServer{stream})
 }
 
-type internalAPIPutFileClient struct {
-	grpc.ClientStream
+type InternalAPI_PutFileServer interface {
+	SendAndClose(*google_protobuf1.Empty) error
+	Recv() (*PutFileRequest, error)
+	grpc.ServerStream
 }
 
-func (x *internalAPIPutFileClient) Send(m *PutFileRequest) error {
-	return x.ClientStream.SendMsg(m)
+type internalAPIPutFileServer struct {
+	grpc.ServerStream
 }
 
-func (x *internalAPIPutFileClient) CloseAndRecv() (*google_protobuf1.Empty, error) {
-	if err := x.ClientStream.CloseSend(); err != nil {
-		return nil, err
-	}
-	m := new(google_protobuf1.Empty)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+func (x *internalAPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *internalAPIPutFileServer) Recv() (*PutFileRequest, error) {
+	m := new(PutFileRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *internalAPIClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (InternalAPI_GetFileClient, error) {
-	stream, err := grpc.NewClientStream(ctx, &_InternalAPI_serviceDesc.Streams[1], c.cc, "/pfs.InternalAPI/GetFile", opts...)
-	if err != nil {
+func _InternalAPI_PutFileURL_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileURLRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	x := &internalAPIGetFileClient{stream}
-	if err := x.ClientStream.SendMsg(in); err != nil {
-		return nil, err
+	if interceptor == nil {
+		return srv.(InternalAPIServer).PutFileURL(ctx, in)
 	}
-	if err := x.ClientStream.CloseSend(); err != nil {
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/PutFileURL",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).PutFileURL(ctx, req.(*PutFileURLRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _InternalAPI_PutFileMulti_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileMultiRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return x, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).PutFileMulti(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/PutFileMulti",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).PutFileMulti(ctx, req.(*PutFileMultiRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-type InternalAPI_GetFileClient interface {
-	Recv() (*google_protobuf3.BytesValue, error)
-	grpc.ClientStream
+func _InternalAPI_StageBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(InternalAPIServer).StageBlob(&internalAPIStageBlobServer{stream})
 }
 
-type internalAPIGetFileClient struct {
-	grpc.ClientStream
+type InternalAPI_StageBlobServer interface {
+	SendAndClose(*StagedBlob) error
+	Recv() (*StageBlobRequest, error)
+	grpc.ServerStream
 }
 
-func (x *internalAPIGetFileClient) Recv() (*google_protobuf3.BytesValue, error) {
-	m := new(google_protobuf3.BytesValue)
-	if err := x.ClientStream.RecvMsg(m); err != nil {
+type internalAPIStageBlobServer struct {
+	grpc.ServerStream
+}
+
+func (x *internalAPIStageBlobServer) SendAndClose(m *StagedBlob) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *internalAPIStageBlobServer) Recv() (*StageBlobRequest, error) {
+	m := new(StageBlobRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
 		return nil, err
 	}
 	return m, nil
 }
 
-func (c *internalAPIClient) InspectFile(ctx context.Context, in *InspectFileRequest, opts ...grpc.CallOption) (*FileInfo, error) {
-	out := new(FileInfo)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/InspectFile", in, out, c.cc, opts...)
-	if err != nil {
+func _InternalAPI_PutFileStaged_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PutFileStagedRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).PutFileStaged(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/PutFileStaged",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).PutFileStaged(ctx, req.(*PutFileStagedRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
-
-func (c *internalAPIClient) ListFile(ctx context.Context, in *ListFileRequest, opts ...grpc.CallOption) (*FileInfos, error) {
-	out := new(FileInfos)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/ListFile", in, out, c.cc, opts...)
-	if err != nil {
+
+func _InternalAPI_InspectStagedBlob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectStagedBlobRequest)
+	if err := dec(in); err != nil {
 		return nil, err
 	}
-	return out, nil
+	if interceptor == nil {
+		return srv.(InternalAPIServer).InspectStagedBlob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/pfs.InternalAPI/InspectStagedBlob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(InternalAPIServer).InspectStagedBlob(ctx, req.(*InspectStagedBlobRequest))
+	}
+	return interceptor(ctx, in, info, handler)
 }
 
-func (c *internalAPIClient) DeleteFile(ctx context.Context, in *DeleteFileRequest, opts ...grpc.CallOption) (*google_protobuf1.Empty, error) {
-	out := new(google_protobuf1.Empty)
-	err := grpc.Invoke(ctx, "/pfs.InternalAPI/DeleteFile", in, out, c.cc, opts...)
-	if err != nil {
-		return nil, err
+func _InternalAPI_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
 	}
-	return out, nil
+	return srv.(InternalAPIServer).GetFile(m, &internalAPIGetFileServer{stream})
 }
 
-// Server API for InternalAPI service
+type InternalAPI_GetFileServer interface {
+	Send(*google_protobuf3.BytesValue) error
+	grpc.ServerStream
+}
 
-type InternalAPIServer interface {
-	// Repo rpcs
-	// CreateRepo creates a new repo.
-	// An error is returned if the repo already exists.
-	CreateRepo(context.Context, *CreateRepoRequest) (*google_protobuf1.Empty, error)
-	// InspectRepo returns info about a repo.
-	InspectRepo(context.Context, *InspectRepoRequest) (*RepoInfo, error)
-	// ListRepo returns info about all repos.
-	ListRepo(context.Context, *ListRepoRequest) (*RepoInfos, error)
-	// DeleteRepo deletes a repo.
-	DeleteRepo(context.Context, *DeleteRepoRequest) (*google_protobuf1.Empty, error)
-	// Commit rpcs
-	// StartCommit creates a new write commit from a parent commit.
-	StartCommit(context.Context, *StartCommitRequest) (*google_protobuf1.Empty, error)
-	// FinishCommit turns a write commit into a read commit.
-	FinishCommit(context.Context, *FinishCommitRequest) (*google_protobuf1.Empty, error)
-	// InspectCommit returns the info about a commit.
-	InspectCommit(context.Context, *InspectCommitRequest) (*CommitInfo, error)
-	// ListCommit returns info about all commits.
-	ListCommit(context.Context, *ListCommitRequest) (*CommitInfos, error)
-	// DeleteCommit deletes a commit.
-	DeleteCommit(context.Context, *DeleteCommitRequest) (*google_protobuf1.Empty, error)
-	// FlushCommit waits for downstream commits to finish
-	FlushCommit(context.Context, *FlushCommitRequest) (*CommitInfos, error)
-	// ListBranch returns info about the heads of branches.
-	ListBranch(context.Context, *ListBranchRequest) (*CommitInfos, error)
-	// File rpcs
-	// PutFile writes the specified file to pfs.
-	PutFile(InternalAPI_PutFileServer) error
-	// GetFile returns a byte stream of the contents of the file.
-	GetFile(*GetFileRequest, InternalAPI_GetFileServer) error
-	// InspectFile returns info about a file.
-	InspectFile(context.Context, *InspectFileRequest) (*FileInfo, error)
-	// ListFile returns info about all files.
-	ListFile(context.Context, *ListFileRequest) (*FileInfos, error)
-	// DeleteFile deletes a file.
-	DeleteFile(context.Context, *DeleteFileRequest) (*google_protobuf1.Empty, error)
+type internalAPIGetFileServer struct {
+	grpc.ServerStream
 }
 
-func RegisterInternalAPIServer(s *grpc.Server, srv InternalAPIServer) {
-	s.RegisterService(&_InternalAPI_serviceDesc, srv)
+func (x *internalAPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
+	return x.ServerStream.SendMsg(m)
 }
 
-func _InternalAPI_CreateRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(CreateRepoRequest)
+func _InternalAPI_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).CreateRepo(ctx, in)
+		return srv.(InternalAPIServer).InspectFile(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/CreateRepo",
+		FullMethod: "/pfs.InternalAPI/InspectFile",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).CreateRepo(ctx, req.(*CreateRepoRequest))
+		return srv.(InternalAPIServer).InspectFile(ctx, req.(*InspectFileRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_InspectRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InspectRepoRequest)
+func _InternalAPI_FileType_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileTypeRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).InspectRepo(ctx, in)
+		return srv.(InternalAPIServer).FileType(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/InspectRepo",
+		FullMethod: "/pfs.InternalAPI/FileType",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).InspectRepo(ctx, req.(*InspectRepoRequest))
+		return srv.(InternalAPIServer).FileType(ctx, req.(*FileTypeRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_ListRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListRepoRequest)
+func _InternalAPI_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).ListRepo(ctx, in)
+		return srv.(InternalAPIServer).ListFile(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/ListRepo",
+		FullMethod: "/pfs.InternalAPI/ListFile",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).ListRepo(ctx, req.(*ListRepoRequest))
+		return srv.(InternalAPIServer).ListFile(ctx, req.(*ListFileRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_DeleteRepo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteRepoRequest)
+func _InternalAPI_FilesExist_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FilesExistRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).DeleteRepo(ctx, in)
+		return srv.(InternalAPIServer).FilesExist(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/DeleteRepo",
+		FullMethod: "/pfs.InternalAPI/FilesExist",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).DeleteRepo(ctx, req.(*DeleteRepoRequest))
+		return srv.(InternalAPIServer).FilesExist(ctx, req.(*FilesExistRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_StartCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(StartCommitRequest)
+func _InternalAPI_SetXattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetXattrRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).StartCommit(ctx, in)
+		return srv.(InternalAPIServer).SetXattr(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/StartCommit",
+		FullMethod: "/pfs.InternalAPI/SetXattr",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).StartCommit(ctx, req.(*StartCommitRequest))
+		return srv.(InternalAPIServer).SetXattr(ctx, req.(*SetXattrRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_FinishCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(FinishCommitRequest)
+func _InternalAPI_FileCompareAndSwap_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FileCompareAndSwapRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).FinishCommit(ctx, in)
+		return srv.(InternalAPIServer).FileCompareAndSwap(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/FinishCommit",
+		FullMethod: "/pfs.InternalAPI/FileCompareAndSwap",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).FinishCommit(ctx, req.(*FinishCommitRequest))
+		return srv.(InternalAPIServer).FileCompareAndSwap(ctx, req.(*FileCompareAndSwapRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_InspectCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InspectCommitRequest)
+func _InternalAPI_SetImmutable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetImmutableRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).InspectCommit(ctx, in)
+		return srv.(InternalAPIServer).SetImmutable(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/InspectCommit",
+		FullMethod: "/pfs.InternalAPI/SetImmutable",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).InspectCommit(ctx, req.(*InspectCommitRequest))
+		return srv.(InternalAPIServer).SetImmutable(ctx, req.(*SetImmutableRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_ListCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListCommitRequest)
+func _InternalAPI_CheckMutable_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CheckMutableRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).ListCommit(ctx, in)
+		return srv.(InternalAPIServer).CheckMutable(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/ListCommit",
+		FullMethod: "/pfs.InternalAPI/CheckMutable",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).ListCommit(ctx, req.(*ListCommitRequest))
+		return srv.(InternalAPIServer).CheckMutable(ctx, req.(*CheckMutableRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_DeleteCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteCommitRequest)
+func _InternalAPI_GetXattr_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetXattrRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).DeleteCommit(ctx, in)
+		return srv.(InternalAPIServer).GetXattr(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/DeleteCommit",
+		FullMethod: "/pfs.InternalAPI/GetXattr",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).DeleteCommit(ctx, req.(*DeleteCommitRequest))
+		return srv.(InternalAPIServer).GetXattr(ctx, req.(*GetXattrRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_FlushCommit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(FlushCommitRequest)
+func _InternalAPI_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFileRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).FlushCommit(ctx, in)
+		return srv.(InternalAPIServer).DeleteFile(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/FlushCommit",
+		FullMethod: "/pfs.InternalAPI/DeleteFile",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).FlushCommit(ctx, req.(*FlushCommitRequest))
+		return srv.(InternalAPIServer).DeleteFile(ctx, req.(*DeleteFileRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_ListBranch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListBranchRequest)
+func _InternalAPI_DeleteFiles_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteFilesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).ListBranch(ctx, in)
+		return srv.(InternalAPIServer).DeleteFiles(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/ListBranch",
+		FullMethod: "/pfs.InternalAPI/DeleteFiles",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).ListBranch(ctx, req.(*ListBranchRequest))
+		return srv.(InternalAPIServer).DeleteFiles(ctx, req.(*DeleteFilesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	return srv.(InternalAPIServer).PutFile(&internalAPIPutFileServer{stream})
+func _InternalAPI_ExportCommit_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportCommitRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InternalAPIServer).ExportCommit(m, &internalAPIExportCommitServer{stream})
 }
 
-type InternalAPI_PutFileServer interface {
-	SendAndClose(*google_protobuf1.Empty) error
-	Recv() (*PutFileRequest, error)
+type InternalAPI_ExportCommitServer interface {
+	Send(*ExportRecord) error
 	grpc.ServerStream
 }
 
-type internalAPIPutFileServer struct {
+type internalAPIExportCommitServer struct {
 	grpc.ServerStream
 }
 
-func (x *internalAPIPutFileServer) SendAndClose(m *google_protobuf1.Empty) error {
+func (x *internalAPIExportCommitServer) Send(m *ExportRecord) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func (x *internalAPIPutFileServer) Recv() (*PutFileRequest, error) {
-	m := new(PutFileRequest)
-	if err := x.ServerStream.RecvMsg(m); err != nil {
-		return nil, err
-	}
-	return m, nil
-}
-
-func _InternalAPI_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
-	m := new(GetFileRequest)
+func _InternalAPI_ReadShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReadShardRequest)
 	if err := stream.RecvMsg(m); err != nil {
 		return err
 	}
-	return srv.(InternalAPIServer).GetFile(m, &internalAPIGetFileServer{stream})
+	return srv.(InternalAPIServer).ReadShard(m, &internalAPIReadShardServer{stream})
 }
 
-type InternalAPI_GetFileServer interface {
-	Send(*google_protobuf3.BytesValue) error
+type InternalAPI_ReadShardServer interface {
+	Send(*ExportRecord) error
 	grpc.ServerStream
 }
 
-type internalAPIGetFileServer struct {
+type internalAPIReadShardServer struct {
 	grpc.ServerStream
 }
 
-func (x *internalAPIGetFileServer) Send(m *google_protobuf3.BytesValue) error {
+func (x *internalAPIReadShardServer) Send(m *ExportRecord) error {
 	return x.ServerStream.SendMsg(m)
 }
 
-func _InternalAPI_InspectFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(InspectFileRequest)
+func _InternalAPI_ListShard_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListShardRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).InspectFile(ctx, in)
+		return srv.(InternalAPIServer).ListShard(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/InspectFile",
+		FullMethod: "/pfs.InternalAPI/ListShard",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).InspectFile(ctx, req.(*InspectFileRequest))
+		return srv.(InternalAPIServer).ListShard(ctx, req.(*ListShardRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_ListFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(ListFileRequest)
+func _InternalAPI_ShardStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ShardStatsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).ListFile(ctx, in)
+		return srv.(InternalAPIServer).ShardStats(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/ListFile",
+		FullMethod: "/pfs.InternalAPI/ShardStats",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).ListFile(ctx, req.(*ListFileRequest))
+		return srv.(InternalAPIServer).ShardStats(ctx, req.(*ShardStatsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
-func _InternalAPI_DeleteFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(DeleteFileRequest)
+func _InternalAPI_BlockReferences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BlockReferencesRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
 	if interceptor == nil {
-		return srv.(InternalAPIServer).DeleteFile(ctx, in)
+		return srv.(InternalAPIServer).BlockReferences(ctx, in)
 	}
 	info := &grpc.UnaryServerInfo{
 		Server:     srv,
-		FullMethod: "/pfs.InternalAPI/DeleteFile",
+		FullMethod: "/pfs.InternalAPI/BlockReferences",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(InternalAPIServer).DeleteFile(ctx, req.(*DeleteFileRequest))
+		return srv.(InternalAPIServer).BlockReferences(ctx, req.(*BlockReferencesRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
 
+func _InternalAPI_DumpShard_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DumpShardRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(InternalAPIServer).DumpShard(m, &internalAPIDumpShardServer{stream})
+}
+
+type InternalAPI_DumpShardServer interface {
+	Send(*DiffInfo) error
+	grpc.ServerStream
+}
+
+type internalAPIDumpShardServer struct {
+	grpc.ServerStream
+}
+
+func (x *internalAPIDumpShardServer) Send(m *DiffInfo) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "pfs.InternalAPI",
 	HandlerType: (*InternalAPIServer)(nil),
@@ -2432,6 +6275,14 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "StartCommit",
 			Handler:    _InternalAPI_StartCommit_Handler,
 		},
+		{
+			MethodName: "ReserveCommit",
+			Handler:    _InternalAPI_ReserveCommit_Handler,
+		},
+		{
+			MethodName: "UnstartCommit",
+			Handler:    _InternalAPI_UnstartCommit_Handler,
+		},
 		{
 			MethodName: "FinishCommit",
 			Handler:    _InternalAPI_FinishCommit_Handler,
@@ -2444,10 +6295,18 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ListCommit",
 			Handler:    _InternalAPI_ListCommit_Handler,
 		},
+		{
+			MethodName: "OpenCommits",
+			Handler:    _InternalAPI_OpenCommits_Handler,
+		},
 		{
 			MethodName: "DeleteCommit",
 			Handler:    _InternalAPI_DeleteCommit_Handler,
 		},
+		{
+			MethodName: "RestoreCommit",
+			Handler:    _InternalAPI_RestoreCommit_Handler,
+		},
 		{
 			MethodName: "FlushCommit",
 			Handler:    _InternalAPI_FlushCommit_Handler,
@@ -2456,30 +6315,131 @@ var _InternalAPI_serviceDesc = grpc.ServiceDesc{
 			MethodName: "ListBranch",
 			Handler:    _InternalAPI_ListBranch_Handler,
 		},
+		{
+			MethodName: "InspectBranch",
+			Handler:    _InternalAPI_InspectBranch_Handler,
+		},
+		{
+			MethodName: "CommitChangedFiles",
+			Handler:    _InternalAPI_CommitChangedFiles_Handler,
+		},
+		{
+			MethodName: "CommitDiff",
+			Handler:    _InternalAPI_CommitDiff_Handler,
+		},
+		{
+			MethodName: "PackCommit",
+			Handler:    _InternalAPI_PackCommit_Handler,
+		},
+		{
+			MethodName: "PutFileURL",
+			Handler:    _InternalAPI_PutFileURL_Handler,
+		},
+		{
+			MethodName: "PutFileMulti",
+			Handler:    _InternalAPI_PutFileMulti_Handler,
+		},
+		{
+			MethodName: "PutFileStaged",
+			Handler:    _InternalAPI_PutFileStaged_Handler,
+		},
+		{
+			MethodName: "InspectStagedBlob",
+			Handler:    _InternalAPI_InspectStagedBlob_Handler,
+		},
 		{
 			MethodName: "InspectFile",
 			Handler:    _InternalAPI_InspectFile_Handler,
 		},
+		{
+			MethodName: "FileType",
+			Handler:    _InternalAPI_FileType_Handler,
+		},
 		{
 			MethodName: "ListFile",
 			Handler:    _InternalAPI_ListFile_Handler,
 		},
+		{
+			MethodName: "FilesExist",
+			Handler:    _InternalAPI_FilesExist_Handler,
+		},
+		{
+			MethodName: "SetXattr",
+			Handler:    _InternalAPI_SetXattr_Handler,
+		},
+		{
+			MethodName: "FileCompareAndSwap",
+			Handler:    _InternalAPI_FileCompareAndSwap_Handler,
+		},
+		{
+			MethodName: "SetImmutable",
+			Handler:    _InternalAPI_SetImmutable_Handler,
+		},
+		{
+			MethodName: "CheckMutable",
+			Handler:    _InternalAPI_CheckMutable_Handler,
+		},
+		{
+			MethodName: "GetXattr",
+			Handler:    _InternalAPI_GetXattr_Handler,
+		},
 		{
 			MethodName: "DeleteFile",
 			Handler:    _InternalAPI_DeleteFile_Handler,
 		},
+		{
+			MethodName: "DeleteFiles",
+			Handler:    _InternalAPI_DeleteFiles_Handler,
+		},
+		{
+			MethodName: "ListShard",
+			Handler:    _InternalAPI_ListShard_Handler,
+		},
+		{
+			MethodName: "ShardStats",
+			Handler:    _InternalAPI_ShardStats_Handler,
+		},
+		{
+			MethodName: "BlockReferences",
+			Handler:    _InternalAPI_BlockReferences_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "CommitManifest",
+			Handler:       _InternalAPI_CommitManifest_Handler,
+			ServerStreams: true,
+		},
 		{
 			StreamName:    "PutFile",
 			Handler:       _InternalAPI_PutFile_Handler,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "StageBlob",
+			Handler:       _InternalAPI_StageBlob_Handler,
+			ClientStreams: true,
+		},
 		{
 			StreamName:    "GetFile",
 			Handler:       _InternalAPI_GetFile_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "ExportCommit",
+			Handler:       _InternalAPI_ExportCommit_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ReadShard",
+			Handler:       _InternalAPI_ReadShard_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DumpShard",
+			Handler:       _InternalAPI_DumpShard_Handler,
+			ServerStreams: true,
+		},
 	},
 }
 
@@ -2900,134 +6860,301 @@ var _BlockAPI_serviceDesc = grpc.ServiceDesc{
 }
 
 var fileDescriptor0 = []byte{
-	// 2063 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xec, 0x59, 0x4b, 0x8f, 0xdb, 0xd6,
-	0x15, 0x36, 0x45, 0x4a, 0xa2, 0x8e, 0x66, 0x34, 0xe3, 0x6b, 0xc7, 0x9d, 0xd0, 0x6e, 0x32, 0x65,
-	0xd2, 0x36, 0x71, 0xd2, 0x99, 0x60, 0x9c, 0x17, 0x9c, 0xb6, 0xce, 0xd8, 0x23, 0x3b, 0x0a, 0xfc,
-	0x02, 0x3d, 0x69, 0xd1, 0x45, 0x21, 0x50, 0x1a, 0xca, 0x43, 0x58, 0xa2, 0x54, 0x92, 0x4a, 0x30,
-	0x5d, 0x16, 0xe8, 0xa2, 0xdd, 0x76, 0x5d, 0x74, 0xdd, 0x75, 0x17, 0xfd, 0x0f, 0xfe, 0x0b, 0x45,
-	0xd7, 0x5d, 0xf7, 0x1f, 0xf4, 0xdc, 0x73, 0x2f, 0xa9, 0x7b, 0x49, 0x3d, 0x03, 0xa4, 0x2d, 0x90,
-	0x2c, 0x12, 0xdf, 0xd7, 0x39, 0xf7, 0x3c, 0xbe, 0x73, 0xee, 0x27, 0x0e, 0x5c, 0xed, 0x0f, 0xc3,
-	0x20, 0x4a, 0x0f, 0x27, 0x83, 0x84, 0xff, 0x77, 0x30, 0x89, 0xc7, 0xe9, 0x98, 0x99, 0x38, 0x74,
-	0x6e, 0x3c, 0x1f, 0x8f, 0x9f, 0x0f, 0x83, 0x43, 0x7f, 0x12, 0x1e, 0xfa, 0x51, 0x34, 0x4e, 0xfd,
-	0x34, 0x1c, 0x47, 0xf2, 0x88, 0x73, 0x5d, 0xee, 0xd2, 0xac, 0x37, 0x1d, 0x1c, 0x06, 0xa3, 0x49,
-	0x7a, 0x21, 0x37, 0x5f, 0x2f, 0x6e, 0xa6, 0xe1, 0x28, 0x48, 0x52, 0x7f, 0x34, 0x91, 0x07, 0x5e,
-	0x2b, 0x1e, 0xf8, 0x2a, 0xf6, 0x27, 0x93, 0x20, 0xce, 0xb4, 0xdf, 0xc8, 0xcc, 0x7a, 0xf1, 0xfc,
-	0x30, 0x39, 0xf7, 0xe3, 0x33, 0xf1, 0x7f, 0xb1, 0xeb, 0x3a, 0x60, 0x79, 0xc1, 0x64, 0xcc, 0x18,
-	0x58, 0x91, 0x3f, 0x0a, 0xf6, 0x8c, 0x7d, 0xe3, 0xad, 0x86, 0x47, 0x63, 0xf7, 0x23, 0xa8, 0xdd,
-	0x1b, 0x8f, 0x46, 0x61, 0xca, 0xbe, 0x0f, 0x56, 0x8c, 0xa7, 0x68, 0xb7, 0x79, 0xd4, 0x38, 0xe0,
-	0xee, 0x71, 0x31, 0x8f, 0x96, 0x59, 0x0b, 0x2a, 0xe1, 0xd9, 0x5e, 0x85, 0x44, 0x71, 0xe4, 0xde,
-	0x01, 0xeb, 0x7e, 0x38, 0x0c, 0xd8, 0x1b, 0x50, 0xeb, 0x93, 0x02, 0x29, 0xd8, 0x24, 0x41, 0xa1,
-	0xd3, 0x93, 0x5b, 0xfc, 0xe6, 0x89, 0x9f, 0x9e, 0x4b, 0x71, 0x1a, 0xbb, 0xd7, 0xa1, 0x7a, 0x77,
-	0x38, 0xee, 0xbf, 0xe0, 0x9b, 0xe7, 0x7e, 0x72, 0x9e, 0x99, 0xc5, 0xc7, 0xee, 0x31, 0x58, 0x27,
-	0xe1, 0x60, 0xb0, 0x9e, 0xf6, 0xab, 0x50, 0x25, 0x77, 0x49, 0xbd, 0xe5, 0x89, 0x89, 0xfb, 0x57,
-	0x03, 0x6c, 0x6e, 0x7f, 0x27, 0x1a, 0x8c, 0x57, 0x39, 0xf7, 0x3e, 0xd4, 0xfb, 0x71, 0xe0, 0xa7,
-	0x81, 0xd0, 0xd1, 0x3c, 0x72, 0x0e, 0x44, 0xc4, 0x0f, 0xb2, 0x88, 0x1f, 0x9c, 0x66, 0x29, 0xf1,
-	0xb2, 0xa3, 0xa8, 0x14, 0x92, 0xf0, 0xb7, 0x41, 0xb7, 0x77, 0x91, 0x06, 0xc9, 0x9e, 0x49, 0x97,
-	0x37, 0xf8, 0xca, 0x5d, 0xbe, 0xc0, 0xde, 0x06, 0x40, 0xe9, 0x2f, 0x83, 0xc8, 0x8f, 0xfa, 0xc1,
-	0x9e, 0xb5, 0x6f, 0xea, 0x37, 0x2b, 0x9b, 0x98, 0x85, 0x46, 0x66, 0x6a, 0xc2, 0x6e, 0x42, 0x83,
-	0x1b, 0xd5, 0x0d, 0x71, 0x86, 0x06, 0x73, 0xb1, 0xed, 0x5c, 0x8c, 0x1f, 0xf1, 0xec, 0x58, 0x8e,
-	0xdc, 0xdf, 0x9b, 0x00, 0x22, 0x1a, 0xe4, 0xe6, 0x5a, 0xe1, 0xba, 0x06, 0xb5, 0x5e, 0x8c, 0xd7,
-	0x66, 0xe9, 0x90, 0x33, 0xf6, 0x1e, 0x34, 0xc5, 0x89, 0x6e, 0x7a, 0x31, 0x09, 0xc8, 0x9f, 0xd6,
-	0xd1, 0x8e, 0xa2, 0xe1, 0x14, 0x97, 0x3d, 0xe8, 0xe7, 0x63, 0x94, 0xd8, 0x9e, 0xf8, 0x31, 0x02,
-	0xaf, 0x2b, 0x6f, 0xb5, 0xca, 0xb7, 0x6e, 0x89, 0x13, 0x12, 0x64, 0x18, 0x68, 0x0c, 0x62, 0xcc,
-	0x03, 0x5d, 0x5d, 0x1d, 0x68, 0x79, 0x94, 0x7d, 0x08, 0xf6, 0x20, 0x8c, 0xc2, 0xe4, 0x1c, 0xc5,
-	0x6a, 0x2b, 0xc5, 0xf2, 0xb3, 0x85, 0x04, 0xd5, 0x8b, 0x09, 0xba, 0x01, 0x8d, 0x3e, 0x0f, 0xff,
-	0x70, 0x88, 0x7a, 0x6d, 0xdc, 0xb5, 0xbd, 0xd9, 0x02, 0x7b, 0x47, 0x4b, 0x5f, 0x83, 0xf2, 0xa0,
-	0x79, 0xa6, 0x26, 0xf0, 0x0e, 0x34, 0x67, 0x69, 0x48, 0x94, 0x50, 0x2a, 0x49, 0x54, 0x43, 0x49,
-	0x69, 0x94, 0xa1, 0xa4, 0x44, 0xfe, 0xa1, 0x02, 0x36, 0xaf, 0xa7, 0x0c, 0xad, 0x03, 0x1c, 0x6b,
-	0x68, 0xe5, 0x9b, 0x1e, 0x2d, 0x73, 0x80, 0xf0, 0x7f, 0x45, 0x9a, 0x2a, 0x94, 0xa6, 0xed, 0xfc,
-	0x0c, 0x25, 0xc9, 0x1e, 0xc8, 0xd1, 0x2a, 0x8c, 0x62, 0x64, 0x47, 0xe3, 0xb3, 0x70, 0x10, 0x62,
-	0x04, 0xac, 0xd5, 0x91, 0xcd, 0xce, 0x62, 0x1e, 0x77, 0xa4, 0x83, 0xb9, 0x78, 0xb5, 0x9c, 0xfb,
-	0x96, 0x38, 0xf3, 0x28, 0x93, 0xfa, 0x21, 0xd8, 0xfd, 0xf3, 0x70, 0x78, 0x86, 0x80, 0xc0, 0x3c,
-	0x9a, 0xba, 0x6f, 0xf9, 0x16, 0xaf, 0x86, 0x2c, 0x14, 0x49, 0xee, 0x6c, 0xa9, 0x1a, 0xb2, 0x23,
-	0xc2, 0x59, 0x0a, 0x22, 0x0a, 0x72, 0xb7, 0x3c, 0x3f, 0x7a, 0x1e, 0xf0, 0xae, 0x30, 0x1c, 0x7f,
-	0x15, 0xc4, 0x14, 0x45, 0xec, 0x0a, 0x34, 0xe1, 0xab, 0x53, 0xde, 0x39, 0xb3, 0x5e, 0x41, 0x13,
-	0xd7, 0x03, 0x9b, 0x7a, 0x91, 0x17, 0x0c, 0xd8, 0x3e, 0x54, 0x7b, 0x7c, 0x2c, 0xa3, 0x0f, 0x74,
-	0x99, 0xd8, 0x15, 0x1b, 0xec, 0x4d, 0xa8, 0xc6, 0xfc, 0x0a, 0xd9, 0x2b, 0x5a, 0xe2, 0x44, 0x76,
-	0xb1, 0x27, 0x36, 0xc9, 0x18, 0xa9, 0x93, 0xbc, 0x20, 0xd9, 0x6e, 0x1c, 0x0c, 0x34, 0x2f, 0xb2,
-	0x23, 0x9e, 0xdd, 0x93, 0x23, 0xf7, 0x2f, 0x16, 0xd4, 0x8e, 0xd1, 0xac, 0xe8, 0x8c, 0xbd, 0x0b,
-	0x90, 0x8b, 0x25, 0xf3, 0xe5, 0x1a, 0xbd, 0xfc, 0x92, 0x0f, 0x94, 0xf0, 0x56, 0xe8, 0xec, 0xab,
-	0x74, 0x56, 0x28, 0x3b, 0xb8, 0x27, 0xf7, 0xda, 0x51, 0x1a, 0x5f, 0xcc, 0xc2, 0xcd, 0x7e, 0x04,
-	0xf6, 0xd0, 0x4f, 0x52, 0x32, 0xcd, 0x2c, 0x27, 0xb1, 0xce, 0x37, 0x79, 0x60, 0xb0, 0x6f, 0x9c,
-	0x05, 0xc3, 0x20, 0x0d, 0x08, 0x29, 0xb6, 0x27, 0x67, 0xec, 0x08, 0xea, 0xe7, 0x7e, 0x74, 0x36,
-	0x44, 0x7c, 0x55, 0xe9, 0xd6, 0x3d, 0xf5, 0xd6, 0xcf, 0xc4, 0x96, 0xb8, 0x34, 0x3b, 0xc8, 0xda,
-	0xd0, 0x12, 0xc3, 0xae, 0x50, 0x92, 0x48, 0x3c, 0xbc, 0x56, 0x16, 0x3d, 0x11, 0x07, 0x84, 0x82,
-	0xed, 0x73, 0x75, 0x4d, 0xaf, 0x84, 0xfa, 0xd2, 0x4a, 0x70, 0x3e, 0x81, 0x6d, 0x2d, 0x02, 0x6c,
-	0x17, 0xcc, 0x17, 0xc1, 0x85, 0x7c, 0x76, 0xf8, 0x90, 0x83, 0xe3, 0x4b, 0x7f, 0x38, 0x15, 0x89,
-	0xb5, 0x3d, 0x31, 0xb9, 0x5d, 0xf9, 0xd8, 0x70, 0x3e, 0x87, 0x2d, 0xd5, 0x91, 0x39, 0xb2, 0x6f,
-	0xaa, 0xb2, 0x39, 0x28, 0xb2, 0xdc, 0xa8, 0xba, 0x3e, 0x05, 0x56, 0xf6, 0x6c, 0x13, 0x6b, 0xdc,
-	0xdf, 0x19, 0x12, 0x5b, 0xd4, 0x2d, 0x56, 0x03, 0xf6, 0x9b, 0x78, 0xde, 0xdc, 0x4f, 0x00, 0x72,
-	0x1b, 0x12, 0xf6, 0x93, 0x0c, 0xa9, 0x4a, 0x9d, 0x2a, 0x31, 0xa0, 0x42, 0x15, 0x50, 0xa5, 0x4a,
-	0x7d, 0x69, 0x82, 0xcd, 0x1f, 0xf8, 0xac, 0xdd, 0x61, 0x83, 0x18, 0x68, 0xed, 0x8e, 0x6f, 0x7a,
-	0xb4, 0x5c, 0x7e, 0x65, 0x2a, 0xab, 0x5e, 0x99, 0xd9, 0x0b, 0x67, 0x6a, 0x2f, 0x9c, 0xf2, 0xfa,
-	0x58, 0x5f, 0xef, 0xf5, 0xa9, 0x6e, 0xf0, 0xfa, 0xe0, 0x6d, 0x3e, 0x01, 0x39, 0x03, 0xb7, 0x93,
-	0x7b, 0xc6, 0xdd, 0x96, 0x28, 0xcf, 0x2a, 0x43, 0x1e, 0xfd, 0xef, 0xbd, 0x59, 0xce, 0x03, 0xd8,
-	0x52, 0x4d, 0x98, 0x83, 0xc0, 0x1f, 0xe8, 0x98, 0x6e, 0x2a, 0xc5, 0xa9, 0xc2, 0xf1, 0x4f, 0x06,
-	0x54, 0x9f, 0x71, 0xce, 0xc5, 0x5e, 0x87, 0x26, 0xd5, 0x63, 0x34, 0x1d, 0xf5, 0xf2, 0xce, 0x0b,
-	0x7c, 0xe9, 0x31, 0xad, 0xa0, 0xc6, 0x2d, 0x3a, 0x80, 0xaf, 0xc6, 0x74, 0x38, 0x4d, 0x64, 0x17,
-	0x26, 0xa1, 0x47, 0x62, 0x89, 0x1f, 0x11, 0x48, 0x92, 0x4a, 0x04, 0xf0, 0x9a, 0xb4, 0x26, 0xb5,
-	0xbc, 0x01, 0xdb, 0xe2, 0x48, 0xa6, 0xc6, 0xa2, 0x33, 0x42, 0x4e, 0xea, 0xe1, 0x56, 0x5d, 0xbe,
-	0x47, 0x50, 0x26, 0xba, 0x15, 0xfc, 0x66, 0x8a, 0x19, 0xfa, 0x66, 0x88, 0xa0, 0xce, 0xf4, 0xcc,
-	0x65, 0x4c, 0xef, 0x16, 0xb0, 0x4e, 0x94, 0x4c, 0x82, 0x7e, 0xba, 0xbe, 0x55, 0xee, 0x4f, 0x61,
-	0xe7, 0x61, 0x98, 0x68, 0x12, 0xfa, 0x95, 0xc6, 0xb2, 0x2b, 0x8f, 0xe0, 0xb2, 0xe8, 0x34, 0x1b,
-	0xdc, 0xf8, 0x0f, 0x03, 0xd8, 0x33, 0x8e, 0x7f, 0x89, 0x9b, 0xf5, 0xa2, 0x57, 0xf8, 0x8d, 0xc0,
-	0xae, 0x43, 0x43, 0x56, 0x2e, 0x2e, 0x8b, 0x52, 0xb4, 0xc5, 0x42, 0xe7, 0x4c, 0x29, 0x52, 0x6b,
-	0x51, 0x91, 0x6e, 0x40, 0x11, 0x75, 0xe4, 0xd7, 0x96, 0xb3, 0xb5, 0x3f, 0x1a, 0x70, 0xe5, 0x3e,
-	0x95, 0xa9, 0xee, 0xde, 0xba, 0xf4, 0x59, 0x14, 0x9c, 0xec, 0xcb, 0x72, 0xa6, 0xb5, 0x09, 0x73,
-	0xfd, 0x36, 0x81, 0x7d, 0xf4, 0xaa, 0x44, 0xc4, 0xe6, 0xc6, 0xb8, 0xff, 0x42, 0x90, 0x73, 0x68,
-	0x2c, 0x4a, 0x93, 0x39, 0x2f, 0x4d, 0x05, 0xa2, 0x5f, 0x59, 0x4d, 0xf4, 0xdf, 0xc5, 0xba, 0x8e,
-	0xc7, 0xa3, 0xac, 0x01, 0x9b, 0x73, 0xc2, 0xcb, 0xf7, 0x65, 0xfb, 0x7d, 0x67, 0xce, 0x0f, 0x9f,
-	0x45, 0xb9, 0xe0, 0x5d, 0xc7, 0x1f, 0x0e, 0x29, 0xd5, 0xb6, 0xc7, 0x87, 0xfc, 0xdd, 0x13, 0xef,
-	0x59, 0x4d, 0xbc, 0x7b, 0x34, 0xe1, 0x28, 0xe6, 0x8e, 0xde, 0x25, 0x90, 0xac, 0x89, 0xe2, 0xdb,
-	0x70, 0x45, 0x20, 0xff, 0x6b, 0x44, 0xf6, 0xd7, 0xc0, 0xee, 0x63, 0x1b, 0x59, 0x82, 0x10, 0x73,
-	0x11, 0x42, 0x5c, 0xa8, 0xa7, 0xe3, 0x2e, 0x19, 0x56, 0x29, 0x66, 0xa0, 0x96, 0x8e, 0xf9, 0xbf,
-	0xee, 0xbf, 0x0d, 0x68, 0x3d, 0x08, 0x52, 0x62, 0xbe, 0x33, 0x67, 0x96, 0xb1, 0x7e, 0xec, 0x8b,
-	0xe3, 0xc1, 0x20, 0x09, 0x52, 0xf9, 0x34, 0xf0, 0xb4, 0x99, 0x5e, 0x53, 0xac, 0x89, 0xc7, 0xa1,
-	0xfc, 0x62, 0x9b, 0xea, 0xdb, 0xb1, 0x9f, 0xfd, 0x4e, 0xb6, 0x14, 0xa2, 0x40, 0x8d, 0x5b, 0xfe,
-	0x66, 0x2e, 0xe6, 0x79, 0x0e, 0xa5, 0x57, 0xf3, 0x8c, 0x95, 0x30, 0x8d, 0x12, 0x7f, 0x10, 0xc8,
-	0x4c, 0xc9, 0x19, 0x5f, 0x17, 0x34, 0x8d, 0x9e, 0x2f, 0xac, 0x6c, 0x31, 0x73, 0xff, 0x8e, 0x3e,
-	0x3f, 0x9d, 0x6e, 0xe2, 0xf3, 0x26, 0xbf, 0x74, 0x72, 0xba, 0xc4, 0xfd, 0xde, 0x92, 0xef, 0x93,
-	0x62, 0x8b, 0xa5, 0xda, 0x82, 0x9e, 0x36, 0x90, 0x79, 0x86, 0xe8, 0x06, 0x3e, 0x31, 0x55, 0xd2,
-	0x2c, 0xe8, 0xca, 0x49, 0xb6, 0xea, 0xcd, 0x0e, 0xb8, 0x7f, 0x33, 0xf2, 0xb6, 0xbd, 0x81, 0xf5,
-	0xfb, 0xea, 0x77, 0x89, 0x75, 0xe2, 0x6d, 0xae, 0x1b, 0x6f, 0x6b, 0x41, 0xbc, 0xab, 0x5a, 0xbc,
-	0x5f, 0x1a, 0xe2, 0xdd, 0xf8, 0x1f, 0x9a, 0xbc, 0x07, 0xf5, 0x38, 0xe8, 0x4f, 0xe3, 0x24, 0xb3,
-	0x39, 0x9b, 0x2a, 0xce, 0x54, 0x17, 0x38, 0x53, 0xd3, 0x9c, 0xe9, 0x65, 0xaf, 0xd8, 0x06, 0xde,
-	0xcc, 0xee, 0xa8, 0x2c, 0xb8, 0xc3, 0xd4, 0xee, 0xf8, 0x02, 0x76, 0x10, 0x9f, 0x92, 0xb4, 0x8b,
-	0x1b, 0x72, 0x54, 0x19, 0x2a, 0xaa, 0x34, 0xf4, 0x54, 0x56, 0xa1, 0x67, 0x0a, 0x3b, 0x58, 0xea,
-	0x9a, 0xda, 0xd5, 0x9c, 0x7d, 0x5e, 0xb9, 0x5b, 0xab, 0xca, 0x5d, 0x23, 0xe8, 0x1f, 0x02, 0x13,
-	0x11, 0xdb, 0xec, 0x66, 0xfc, 0xe1, 0x7a, 0x45, 0x62, 0x7d, 0x43, 0x41, 0x06, 0xbb, 0xd4, 0xa2,
-	0x15, 0x29, 0x85, 0xef, 0x10, 0xa3, 0x9f, 0xe5, 0x6d, 0x09, 0xe3, 0x77, 0x7f, 0x2c, 0x70, 0xab,
-	0x4a, 0xe4, 0xdf, 0xf8, 0x0c, 0xf5, 0x1b, 0x5f, 0x4e, 0x6d, 0xd6, 0x57, 0x7e, 0xf3, 0x49, 0xf6,
-	0xc5, 0x4c, 0x76, 0x8d, 0xdd, 0x7b, 0x4f, 0x1e, 0x3d, 0xea, 0x9c, 0x76, 0x4f, 0x7f, 0xf5, 0xb4,
-	0xdd, 0x7d, 0xfc, 0xe4, 0x71, 0x7b, 0xf7, 0x52, 0x71, 0xd5, 0x6b, 0x1f, 0x9f, 0xec, 0x1a, 0xec,
-	0x15, 0x24, 0x94, 0xca, 0xea, 0x2f, 0xbd, 0xce, 0x69, 0x7b, 0xb7, 0x72, 0xf3, 0x33, 0xf1, 0xe5,
-	0x86, 0xd4, 0x31, 0x68, 0xdd, 0xef, 0x3c, 0x6c, 0x6b, 0xca, 0x50, 0x6c, 0xb6, 0xe6, 0xb5, 0x1f,
-	0x7c, 0xf1, 0xf0, 0xd8, 0x43, 0x6d, 0x97, 0x61, 0x7b, 0xb6, 0x7c, 0xd2, 0xf1, 0x50, 0xd3, 0xdb,
-	0xd0, 0xc8, 0x01, 0xc4, 0x6c, 0xb0, 0xa4, 0x02, 0x1c, 0x7d, 0xfe, 0xec, 0xc9, 0x63, 0x94, 0xc1,
-	0xd1, 0xc3, 0x0e, 0xae, 0x55, 0x8e, 0x5e, 0xd6, 0xc1, 0x3c, 0x7e, 0xda, 0x61, 0x3f, 0x47, 0x6f,
-	0x72, 0x92, 0xcb, 0xae, 0x89, 0x3a, 0x2c, 0xb2, 0x5e, 0xe7, 0x5a, 0x89, 0x89, 0xb4, 0xf9, 0xe7,
-	0x67, 0xf7, 0x12, 0xfb, 0x08, 0x9a, 0x0a, 0x1f, 0x65, 0xdf, 0x23, 0x05, 0x65, 0x86, 0xea, 0xe8,
-	0x5f, 0x20, 0x51, 0xf0, 0x08, 0xec, 0x8c, 0x93, 0xb2, 0xab, 0xb4, 0x59, 0xa0, 0xa8, 0x4e, 0x4b,
-	0x13, 0x49, 0x50, 0x06, 0x8d, 0x9d, 0x31, 0x51, 0x69, 0x6c, 0x89, 0x9a, 0x2e, 0x31, 0xf6, 0x03,
-	0x68, 0x2a, 0xa4, 0x54, 0x1a, 0x5b, 0xa6, 0xa9, 0x8e, 0xda, 0x8e, 0x50, 0xec, 0x2e, 0x6c, 0xa9,
-	0x6c, 0x8f, 0xed, 0xc9, 0x3e, 0x51, 0x22, 0x80, 0x4b, 0xae, 0xfe, 0x19, 0x6c, 0x6b, 0x2c, 0x8d,
-	0xbd, 0xaa, 0x46, 0x4a, 0xd7, 0x52, 0xfc, 0xd0, 0x87, 0xe2, 0x1f, 0x03, 0xcc, 0x68, 0x9a, 0xf4,
-	0xbc, 0xc4, 0xdb, 0x9c, 0xdd, 0x82, 0x60, 0x22, 0x8c, 0x57, 0x39, 0x8c, 0x34, 0x7e, 0x0e, 0xad,
-	0x59, 0x62, 0xfc, 0x6d, 0x68, 0x2a, 0x5c, 0x46, 0xc6, 0xad, 0xcc, 0x6e, 0xe6, 0xde, 0x2f, 0x2d,
-	0x17, 0xbc, 0x4b, 0xb1, 0x5c, 0x23, 0x62, 0x73, 0x25, 0x6f, 0x43, 0x5d, 0xbe, 0xf6, 0xec, 0x0a,
-	0x6d, 0xeb, 0x6f, 0xff, 0x62, 0x7b, 0xdf, 0x32, 0xd8, 0x1d, 0xa8, 0x4b, 0x76, 0x24, 0x65, 0x75,
-	0xae, 0xe4, 0x5c, 0x2f, 0xc9, 0x52, 0xcf, 0xfb, 0x05, 0xef, 0xce, 0xee, 0xa5, 0xf7, 0x0c, 0x05,
-	0xd7, 0xa4, 0x44, 0xc3, 0xb5, 0xaa, 0x48, 0xff, 0x96, 0x38, 0xc3, 0x35, 0x49, 0xcd, 0x70, 0xad,
-	0x8a, 0xb4, 0x34, 0x11, 0x0d, 0xd7, 0x24, 0xa5, 0xe2, 0x7a, 0x2d, 0x7f, 0x8f, 0xfe, 0x59, 0xe7,
-	0xd6, 0x62, 0xd1, 0x47, 0xfe, 0xf0, 0x5b, 0x57, 0xd4, 0x9f, 0xae, 0x59, 0xd4, 0x8b, 0x35, 0x7c,
-	0x57, 0xdf, 0xdf, 0xd5, 0xf7, 0xff, 0x75, 0x7d, 0xff, 0xd9, 0x92, 0x7f, 0x5f, 0xe0, 0xc5, 0xfd,
-	0x3e, 0xd8, 0x19, 0xc9, 0x94, 0x06, 0x14, 0x38, 0xa7, 0x53, 0xf8, 0x76, 0x4c, 0x01, 0x3b, 0x06,
-	0x3b, 0xe3, 0x90, 0x52, 0xaa, 0x40, 0x29, 0x57, 0x87, 0x0c, 0x0b, 0x4d, 0xe1, 0x83, 0x32, 0x64,
-	0x65, 0x86, 0xb8, 0x14, 0x67, 0x5b, 0x2a, 0x33, 0x94, 0x58, 0x9d, 0x43, 0x16, 0x9d, 0xc2, 0xa7,
-	0x5f, 0x7a, 0xbb, 0x1b, 0x39, 0x39, 0x64, 0xaf, 0xcc, 0x60, 0xa6, 0x4a, 0xed, 0xe8, 0x52, 0x09,
-	0x89, 0xc9, 0x56, 0x48, 0x7f, 0x0e, 0xde, 0xd6, 0xbe, 0xa0, 0xae, 0xd5, 0x01, 0x49, 0x4e, 0x83,
-	0x87, 0xc2, 0x15, 0x1d, 0x5d, 0x21, 0x0a, 0xde, 0x12, 0xf0, 0x20, 0xa9, 0x19, 0x3c, 0x96, 0x89,
-	0x60, 0x64, 0x73, 0x7c, 0x90, 0x98, 0x8a, 0x0f, 0x55, 0x70, 0xa1, 0xb5, 0xbd, 0x1a, 0xad, 0xdc,
-	0xfa, 0x4f, 0x00, 0x00, 0x00, 0xff, 0xff, 0x99, 0x5e, 0x40, 0x00, 0x5e, 0x20, 0x00, 0x00,
+	// 4722 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xec, 0x3c, 0xcb, 0x72, 0x1c, 0x47,
+	0x72, 0x9c, 0x07, 0x06, 0x3d, 0x39, 0x0f, 0x0c, 0x0a, 0x0f, 0x0e, 0x9b, 0xd4, 0x92, 0x6a, 0xad,
+	0x24, 0x8a, 0x92, 0x40, 0x9a, 0xa2, 0x48, 0x89, 0xdc, 0x15, 0x09, 0x70, 0x86, 0xc4, 0x48, 0xc4,
+	0x23, 0x1a, 0xe0, 0x3e, 0x6c, 0x6f, 0x4c, 0x34, 0xa6, 0x6b, 0x80, 0x0e, 0xce, 0x74, 0x8f, 0xbb,
+	0x7b, 0x44, 0x60, 0x8f, 0x8e, 0x3d, 0xd8, 0xbe, 0xf8, 0xe0, 0x3d, 0xf8, 0xe2, 0xa3, 0xbf, 0xc0,
+	0x7f, 0xe0, 0xf0, 0x27, 0x38, 0xc2, 0x07, 0x1f, 0x1c, 0x3e, 0x39, 0x7c, 0xf2, 0x1f, 0x38, 0x1c,
+	0xf5, 0xea, 0xae, 0xea, 0xee, 0x79, 0x91, 0xbb, 0x21, 0x7b, 0xcd, 0x83, 0x84, 0xae, 0xaa, 0xcc,
+	0xac, 0xac, 0xac, 0xcc, 0xac, 0xac, 0xcc, 0x1a, 0xc2, 0x7a, 0x6f, 0xe0, 0x60, 0x37, 0xbc, 0x3d,
+	0xea, 0x07, 0xe4, 0xbf, 0xad, 0x91, 0xef, 0x85, 0x1e, 0x2a, 0x8c, 0xfa, 0x81, 0x7e, 0xed, 0xd4,
+	0xf3, 0x4e, 0x07, 0xf8, 0xb6, 0x35, 0x72, 0x6e, 0x5b, 0xae, 0xeb, 0x85, 0x56, 0xe8, 0x78, 0x2e,
+	0x07, 0xd1, 0xaf, 0xf2, 0x51, 0xda, 0x3a, 0x19, 0xf7, 0x6f, 0xe3, 0xe1, 0x28, 0xbc, 0xe0, 0x83,
+	0xd7, 0x93, 0x83, 0xa1, 0x33, 0xc4, 0x41, 0x68, 0x0d, 0x47, 0x1c, 0xe0, 0x47, 0x49, 0x80, 0xd7,
+	0xbe, 0x35, 0x1a, 0x61, 0x5f, 0x50, 0xbf, 0x26, 0xd8, 0x7a, 0x75, 0x7a, 0x3b, 0x38, 0xb3, 0x7c,
+	0x9b, 0xfd, 0x9f, 0x8d, 0x1a, 0x3a, 0x14, 0x4d, 0x3c, 0xf2, 0x10, 0x82, 0xa2, 0x6b, 0x0d, 0x71,
+	0x33, 0x77, 0x23, 0x77, 0xb3, 0x6c, 0xd2, 0x6f, 0xe3, 0x01, 0x94, 0x9e, 0x7a, 0xc3, 0xa1, 0x13,
+	0xa2, 0xf7, 0xa0, 0xe8, 0xe3, 0x91, 0x47, 0x47, 0x2b, 0x77, 0xcb, 0x5b, 0x64, 0x79, 0x04, 0xcd,
+	0xa4, 0xdd, 0xa8, 0x0e, 0x79, 0xc7, 0x6e, 0xe6, 0x29, 0x6a, 0xde, 0xb1, 0x8d, 0xc7, 0x50, 0x7c,
+	0xe6, 0x0c, 0x30, 0xfa, 0x00, 0x4a, 0x3d, 0x4a, 0x80, 0x23, 0x56, 0x28, 0x22, 0xa3, 0x69, 0xf2,
+	0x21, 0x32, 0xf3, 0xc8, 0x0a, 0xcf, 0x38, 0x3a, 0xfd, 0x36, 0xae, 0xc2, 0xd2, 0xce, 0xc0, 0xeb,
+	0xbd, 0x22, 0x83, 0x67, 0x56, 0x70, 0x26, 0xd8, 0x22, 0xdf, 0xc6, 0x36, 0x14, 0x5b, 0x4e, 0xbf,
+	0x3f, 0x1f, 0xf5, 0x75, 0x58, 0xa2, 0xcb, 0xa5, 0xe4, 0x8b, 0x26, 0x6b, 0x18, 0x7f, 0x51, 0x00,
+	0x8d, 0xf0, 0xdf, 0x71, 0xfb, 0xde, 0xac, 0xc5, 0xdd, 0x83, 0xe5, 0x9e, 0x8f, 0xad, 0x10, 0x33,
+	0x1a, 0x95, 0xbb, 0xfa, 0x16, 0x93, 0xf8, 0x96, 0x90, 0xf8, 0xd6, 0xb1, 0xd8, 0x12, 0x53, 0x80,
+	0xa2, 0xf7, 0x00, 0x02, 0xe7, 0xd7, 0xb8, 0x7b, 0x72, 0x11, 0xe2, 0xa0, 0x59, 0xa0, 0x93, 0x97,
+	0x49, 0xcf, 0x0e, 0xe9, 0x40, 0x9f, 0x00, 0x8c, 0x7c, 0xef, 0x7b, 0xec, 0x5a, 0x6e, 0x0f, 0x37,
+	0x8b, 0x37, 0x0a, 0xea, 0xcc, 0xd2, 0x20, 0x7a, 0x1f, 0x0a, 0xb6, 0x75, 0xda, 0x5c, 0xa2, 0x30,
+	0x2b, 0xd2, 0x1a, 0xf7, 0x3d, 0x1b, 0x9b, 0x64, 0x0c, 0x7d, 0x04, 0x2b, 0xb6, 0x75, 0xda, 0x75,
+	0xf1, 0x79, 0xd8, 0xf5, 0xfa, 0xfd, 0x00, 0x87, 0xcd, 0x12, 0x9d, 0xb1, 0x66, 0x5b, 0xa7, 0xfb,
+	0xf8, 0x3c, 0x3c, 0xa0, 0x9d, 0x68, 0x1b, 0xaa, 0x27, 0xbe, 0xe5, 0xf6, 0xce, 0xba, 0x67, 0xd8,
+	0xb2, 0x83, 0xe6, 0x32, 0xa5, 0xf9, 0xa3, 0x68, 0x5e, 0x22, 0x8e, 0xad, 0x1d, 0x0a, 0xb1, 0x4b,
+	0x00, 0xda, 0x6e, 0xe8, 0x5f, 0x98, 0x95, 0x93, 0xb8, 0x47, 0x3f, 0x80, 0x46, 0x12, 0x00, 0x35,
+	0xa0, 0xf0, 0x0a, 0x5f, 0xf0, 0x3d, 0x22, 0x9f, 0xe8, 0x43, 0x58, 0xfa, 0xde, 0x1a, 0x8c, 0x31,
+	0x97, 0x98, 0xcc, 0x35, 0x99, 0xc3, 0x64, 0xa3, 0x0f, 0xf3, 0x5f, 0xe5, 0x8c, 0x07, 0x50, 0x16,
+	0x53, 0x07, 0xe8, 0x16, 0x94, 0x89, 0xcc, 0xbb, 0x8e, 0xdb, 0x27, 0xfb, 0x41, 0xb8, 0xab, 0x29,
+	0xdc, 0x99, 0x9a, 0xcf, 0xbf, 0x8c, 0xff, 0xc8, 0x01, 0xc4, 0x82, 0x98, 0x4f, 0x1b, 0xee, 0x40,
+	0x6d, 0x64, 0xf9, 0xd8, 0x0d, 0xbb, 0x1c, 0x36, 0x9f, 0x86, 0xad, 0x32, 0x08, 0xd6, 0x42, 0x9b,
+	0x50, 0x62, 0xcb, 0xa7, 0x7b, 0x58, 0x36, 0x79, 0x8b, 0x68, 0x45, 0x10, 0x5a, 0x3e, 0xd1, 0x8a,
+	0xe2, 0x6c, 0xad, 0xe0, 0xa0, 0x04, 0xcb, 0xc6, 0x03, 0x4c, 0xb0, 0x96, 0x66, 0x63, 0x71, 0x50,
+	0xe3, 0xdf, 0x8b, 0x62, 0xa5, 0x54, 0x5f, 0xe7, 0x5a, 0x69, 0xcc, 0x77, 0x5e, 0xe1, 0xfb, 0x0e,
+	0x54, 0x18, 0x44, 0x37, 0xbc, 0x18, 0x61, 0xba, 0xa8, 0xba, 0xb2, 0x3f, 0xc7, 0x17, 0x23, 0x6c,
+	0x42, 0x2f, 0xfa, 0x4e, 0xcb, 0xac, 0x38, 0x4b, 0x66, 0x92, 0x6c, 0x96, 0xe6, 0x97, 0xcd, 0x7d,
+	0xd0, 0xfa, 0x8e, 0xeb, 0x04, 0x67, 0xd8, 0x6e, 0x96, 0x66, 0xa2, 0x45, 0xb0, 0x09, 0x4b, 0x5b,
+	0x4e, 0x5a, 0xda, 0x35, 0x28, 0xf7, 0x88, 0x1d, 0x0d, 0x06, 0xd8, 0x6e, 0x6a, 0x37, 0x72, 0x37,
+	0x35, 0x33, 0xee, 0x40, 0x9f, 0x2a, 0x76, 0x58, 0xbe, 0x51, 0x48, 0xae, 0x4c, 0x1a, 0x96, 0x77,
+	0x0f, 0xe6, 0xde, 0x3d, 0x74, 0x03, 0x2a, 0x36, 0x0e, 0x7a, 0xbe, 0x33, 0x22, 0x3e, 0xbf, 0x59,
+	0xa1, 0xdb, 0x21, 0x77, 0xa1, 0x1d, 0xa8, 0x48, 0x87, 0x42, 0xb3, 0x4a, 0xb9, 0xb8, 0x91, 0xb0,
+	0x99, 0xad, 0xed, 0x18, 0x84, 0xdb, 0xa5, 0x84, 0xa4, 0x7f, 0x03, 0x8d, 0x24, 0x40, 0x86, 0x5d,
+	0xae, 0xcb, 0x76, 0x59, 0x96, 0xcd, 0xf0, 0x31, 0x54, 0xe2, 0xb9, 0x02, 0x49, 0x4d, 0x24, 0x53,
+	0x4c, 0x99, 0x31, 0xf4, 0xa2, 0x6f, 0xe3, 0x5f, 0x0b, 0xa0, 0x11, 0xa7, 0x2f, 0x5c, 0x6a, 0xdf,
+	0x19, 0x60, 0xc5, 0xa5, 0x92, 0x41, 0x93, 0x76, 0x13, 0x33, 0x27, 0x7f, 0x99, 0x0a, 0xe6, 0xa9,
+	0x0a, 0xd6, 0x22, 0x18, 0xaa, 0x80, 0x5a, 0x9f, 0x7f, 0xcd, 0x72, 0xa4, 0xf7, 0x41, 0x1b, 0x7a,
+	0xb6, 0xd3, 0x77, 0xe6, 0x32, 0xc4, 0x08, 0x16, 0xdd, 0x83, 0x15, 0xbe, 0xc0, 0x08, 0x7d, 0x29,
+	0xad, 0xd7, 0x75, 0x06, 0xb3, 0x27, 0xb0, 0x3e, 0x04, 0xad, 0x77, 0xe6, 0x0c, 0x6c, 0x1f, 0xbb,
+	0xcd, 0x92, 0xe4, 0xb4, 0xe9, 0xda, 0xa2, 0x21, 0x74, 0x0b, 0x00, 0x9f, 0x3b, 0x41, 0x88, 0xed,
+	0xae, 0xe3, 0x72, 0x2f, 0xab, 0xd0, 0x2d, 0xf3, 0xe1, 0x8e, 0x8b, 0xfe, 0x08, 0x4a, 0xe7, 0x56,
+	0x18, 0xfa, 0x41, 0x53, 0xa3, 0x70, 0x57, 0x22, 0x82, 0x74, 0xd7, 0x7f, 0x41, 0xc7, 0xd8, 0x86,
+	0x73, 0x40, 0xa2, 0xd2, 0xce, 0x70, 0x38, 0x0e, 0xad, 0x93, 0x01, 0xd1, 0x59, 0xaa, 0xd2, 0x51,
+	0x07, 0x6a, 0xaa, 0x5a, 0xaa, 0x45, 0x9a, 0xa8, 0x7f, 0x0d, 0x15, 0x89, 0xdc, 0x42, 0xea, 0xf1,
+	0x00, 0xca, 0x82, 0xa5, 0x20, 0xda, 0xbe, 0x94, 0x97, 0x16, 0x20, 0x6c, 0xfb, 0xa8, 0x5a, 0x3c,
+	0x80, 0x32, 0xd9, 0x28, 0xd3, 0x72, 0x4f, 0x31, 0xa1, 0x3f, 0xf0, 0x5e, 0x63, 0x9f, 0xce, 0x59,
+	0x34, 0x59, 0x83, 0xf4, 0x8e, 0x49, 0xc0, 0x22, 0x8e, 0x68, 0xda, 0x30, 0xfa, 0xa0, 0xd1, 0x10,
+	0xc0, 0xc4, 0x7d, 0x74, 0x03, 0x96, 0x4e, 0xc8, 0x37, 0xd7, 0x27, 0xa0, 0x93, 0xb1, 0x51, 0x36,
+	0x80, 0x7e, 0x0c, 0x4b, 0x3e, 0x99, 0x82, 0x3b, 0xf4, 0x3a, 0x83, 0x10, 0x13, 0x9b, 0x6c, 0x90,
+	0x44, 0x13, 0xb6, 0x15, 0x5a, 0x54, 0x8b, 0xaa, 0x26, 0xfd, 0xa6, 0x0c, 0xf2, 0x79, 0xe8, 0xca,
+	0x28, 0xbd, 0xae, 0x8f, 0xfb, 0xca, 0xca, 0x04, 0x88, 0xa9, 0x9d, 0xf0, 0x2f, 0xe3, 0xdf, 0x96,
+	0xa0, 0xb4, 0x3d, 0x1a, 0x61, 0xd7, 0x46, 0x9f, 0x01, 0x44, 0x68, 0x41, 0x36, 0x5e, 0xf9, 0x24,
+	0x9a, 0xe4, 0x4b, 0x49, 0x89, 0xf2, 0xd2, 0x9e, 0x33, 0x62, 0x5b, 0x4f, 0xf9, 0x18, 0xdb, 0xf3,
+	0x58, 0xa9, 0x3e, 0x02, 0x6d, 0x60, 0x05, 0x21, 0x65, 0xad, 0x90, 0x56, 0xd5, 0x65, 0x32, 0x48,
+	0x84, 0xb5, 0x09, 0x25, 0xb6, 0xe1, 0xd4, 0x1e, 0x34, 0x93, 0xb7, 0xd0, 0x5d, 0x58, 0x3e, 0xb3,
+	0x5c, 0x7b, 0x80, 0x03, 0x1e, 0x4b, 0x34, 0xe5, 0x59, 0x77, 0xd9, 0x10, 0x9b, 0x54, 0x00, 0xa2,
+	0x36, 0xd4, 0xd9, 0x67, 0x97, 0x11, 0x09, 0x9a, 0x25, 0x29, 0x64, 0x50, 0x50, 0x5b, 0x0c, 0x80,
+	0x11, 0xa8, 0x9d, 0xc9, 0x7d, 0xaa, 0xbd, 0x2f, 0x4f, 0xb7, 0xf7, 0x7b, 0xb0, 0x8c, 0xcf, 0x47,
+	0x8e, 0x8f, 0x83, 0xa6, 0x36, 0xd3, 0x9e, 0x05, 0x28, 0xba, 0x1d, 0x59, 0x11, 0xf3, 0xe1, 0x97,
+	0x65, 0x06, 0x67, 0xda, 0x10, 0x24, 0x6c, 0x48, 0x7f, 0x04, 0x35, 0x65, 0x1b, 0x66, 0xd9, 0x8a,
+	0x26, 0xd9, 0x8a, 0xfe, 0x2d, 0x54, 0x65, 0x69, 0x66, 0xe0, 0xfe, 0x58, 0x0d, 0x8f, 0xea, 0x8a,
+	0xaa, 0x04, 0x32, 0xad, 0x27, 0x80, 0xd2, 0xe2, 0x5d, 0x88, 0x9b, 0xb7, 0x30, 0xfa, 0x3f, 0xcf,
+	0x71, 0xdb, 0xa0, 0x3e, 0x7d, 0xb6, 0x11, 0xfe, 0x3e, 0x22, 0x65, 0xe3, 0x11, 0x40, 0xc4, 0x43,
+	0x80, 0x3e, 0x17, 0x96, 0x26, 0xf9, 0x1e, 0x49, 0x7c, 0x04, 0x88, 0x9b, 0x1a, 0xf9, 0x34, 0xfe,
+	0xb3, 0x04, 0x1a, 0xb9, 0x2b, 0x88, 0x43, 0xc9, 0x76, 0xfa, 0x7d, 0xe5, 0x50, 0x22, 0x83, 0x26,
+	0xed, 0xfe, 0xc1, 0x63, 0x43, 0x39, 0xfe, 0x59, 0x5a, 0x20, 0xfe, 0xb9, 0x07, 0xcb, 0x16, 0xd5,
+	0x73, 0x61, 0x9c, 0x7a, 0xb4, 0x32, 0x16, 0x37, 0xb0, 0x41, 0x6e, 0xd9, 0x1c, 0xf4, 0x7f, 0x7d,
+	0xd4, 0xa4, 0x13, 0x27, 0x89, 0x7b, 0xaf, 0x82, 0xf1, 0x90, 0x87, 0x4c, 0x51, 0x3b, 0x19, 0x51,
+	0x55, 0xd3, 0x11, 0xd5, 0x13, 0x35, 0xa2, 0xaa, 0x49, 0x4e, 0x2b, 0x96, 0xcb, 0xb4, 0x78, 0x0a,
+	0xed, 0x41, 0xc3, 0xb1, 0xf1, 0x70, 0xe4, 0x85, 0xd8, 0xed, 0x5d, 0x74, 0x5f, 0xe1, 0x8b, 0xa0,
+	0x59, 0xa7, 0x64, 0x0c, 0x95, 0x4c, 0x27, 0x86, 0xfa, 0x0e, 0x5f, 0x70, 0x52, 0x2b, 0x8e, 0xda,
+	0xab, 0x3f, 0x87, 0xaa, 0xbc, 0x0f, 0x19, 0x66, 0xf8, 0xbe, 0xea, 0x13, 0x2a, 0x92, 0x03, 0x93,
+	0xcd, 0xf9, 0x2d, 0xe3, 0x3c, 0xfd, 0x17, 0xb0, 0x9e, 0xc5, 0x71, 0x06, 0x8d, 0x5b, 0x2a, 0x43,
+	0xeb, 0x94, 0xa1, 0x04, 0xae, 0xec, 0x2d, 0x7e, 0x0d, 0x2b, 0x89, 0x51, 0x74, 0x5b, 0x10, 0x25,
+	0x72, 0x7b, 0x2f, 0x8b, 0xc0, 0xd6, 0x77, 0xf8, 0x82, 0x89, 0x8c, 0x40, 0xea, 0xf7, 0x41, 0x13,
+	0x1d, 0x8b, 0x38, 0x39, 0xe3, 0x57, 0x00, 0xe4, 0x28, 0x79, 0x7a, 0x46, 0x8f, 0xf9, 0x19, 0xd1,
+	0x27, 0x89, 0x6d, 0x29, 0xa0, 0x1c, 0x7f, 0xf2, 0xd8, 0x96, 0xf6, 0xf3, 0x2b, 0x50, 0xf4, 0x4d,
+	0x82, 0xe3, 0x98, 0x3c, 0x0d, 0x8e, 0xe9, 0x71, 0xc6, 0x20, 0x94, 0xe0, 0x38, 0x06, 0x33, 0xa1,
+	0x1f, 0x7d, 0x1b, 0x7f, 0x93, 0x83, 0xa5, 0x23, 0x92, 0x79, 0x40, 0xd7, 0x39, 0xae, 0x3b, 0x1e,
+	0x9e, 0x44, 0x81, 0x10, 0x05, 0xdd, 0xa7, 0x3d, 0xe8, 0x7d, 0xa8, 0x52, 0x80, 0xa1, 0x67, 0x8f,
+	0x07, 0xe3, 0x80, 0x07, 0x45, 0x14, 0x69, 0x8f, 0x75, 0x11, 0x10, 0xe6, 0x04, 0x39, 0x11, 0xe6,
+	0x33, 0x2b, 0xb4, 0x8f, 0x53, 0xf9, 0x00, 0x6a, 0x0c, 0x44, 0x90, 0x29, 0x52, 0x18, 0x86, 0xc7,
+	0xe9, 0x18, 0x27, 0x50, 0xa6, 0x4c, 0x51, 0xef, 0x18, 0x25, 0x4a, 0x72, 0x52, 0xa2, 0x84, 0x04,
+	0x93, 0x96, 0x6d, 0xfb, 0x38, 0x08, 0xb8, 0x2a, 0x89, 0x26, 0xb9, 0xe2, 0x07, 0xa1, 0x15, 0xaa,
+	0x57, 0x48, 0x4a, 0xee, 0x88, 0x74, 0x9b, 0x6c, 0x94, 0xb8, 0xef, 0x68, 0x0e, 0xea, 0xbe, 0x29,
+	0xdd, 0xb4, 0xfb, 0x8e, 0x80, 0xcc, 0x72, 0x20, 0x3e, 0x8d, 0xdf, 0xe6, 0xa1, 0x1c, 0x91, 0x5c,
+	0x98, 0xc3, 0x19, 0x37, 0x07, 0xe2, 0xbd, 0x89, 0x34, 0x84, 0x6c, 0x78, 0x8b, 0x48, 0xd7, 0x1b,
+	0x61, 0x97, 0x9f, 0x02, 0x01, 0xf5, 0xc5, 0x45, 0xb3, 0x42, 0xfa, 0x98, 0x77, 0x0b, 0xd0, 0xc7,
+	0xb0, 0x32, 0x76, 0xfb, 0x83, 0x31, 0xf1, 0xbf, 0x9c, 0x3c, 0xcb, 0xb7, 0xd4, 0xa3, 0x6e, 0x36,
+	0xc7, 0x87, 0x50, 0xef, 0x79, 0xbe, 0x3f, 0x1e, 0x85, 0x5d, 0x3e, 0x17, 0xf3, 0xb4, 0x35, 0xde,
+	0xbb, 0xc3, 0xa6, 0xfc, 0x1c, 0xd0, 0x6b, 0xcb, 0x09, 0x1d, 0xf7, 0xb4, 0xeb, 0x8d, 0xb0, 0xcf,
+	0xbd, 0x96, 0x46, 0x41, 0x57, 0xf9, 0xc8, 0x41, 0x34, 0x60, 0x3c, 0x05, 0x14, 0x49, 0x25, 0x30,
+	0x71, 0x30, 0xf2, 0xdc, 0x00, 0xc7, 0xb2, 0x25, 0x82, 0x4f, 0xcb, 0x96, 0x00, 0x73, 0xd9, 0x92,
+	0x4f, 0xe3, 0x9f, 0x72, 0xb0, 0xfa, 0x94, 0x1e, 0xc1, 0x34, 0xe3, 0x84, 0xff, 0x6c, 0x8c, 0x83,
+	0xf0, 0xf7, 0x93, 0x0b, 0x53, 0x93, 0x5d, 0x85, 0x69, 0xc9, 0xae, 0xdb, 0xb0, 0xce, 0xb0, 0xba,
+	0x4e, 0xbf, 0xeb, 0x7a, 0x61, 0x97, 0x5e, 0x94, 0x02, 0x1e, 0xca, 0xae, 0xb2, 0xb1, 0x4e, 0x7f,
+	0xdf, 0x0b, 0xdb, 0x74, 0xc0, 0xf8, 0xc7, 0x1c, 0xa0, 0x8e, 0x1b, 0x8c, 0x70, 0x2f, 0x5c, 0x60,
+	0x1d, 0xd7, 0xa1, 0xe2, 0xb8, 0xbd, 0xc1, 0xd8, 0xc6, 0x5d, 0x92, 0x5b, 0x63, 0xfe, 0x04, 0x78,
+	0x57, 0xcb, 0x3a, 0x25, 0xba, 0x43, 0x32, 0x6a, 0x3c, 0x99, 0xc6, 0x75, 0xc7, 0xb6, 0x4e, 0x79,
+	0x22, 0xed, 0x2a, 0x90, 0x46, 0x77, 0xe0, 0x88, 0x7c, 0x48, 0xd1, 0xd4, 0x6c, 0xeb, 0xf4, 0x85,
+	0xc3, 0x92, 0x4c, 0xeb, 0x82, 0xb8, 0x92, 0x6d, 0x5b, 0xa2, 0xb3, 0x20, 0x3e, 0x26, 0x65, 0xd1,
+	0x8c, 0x9f, 0xc0, 0xca, 0x0b, 0x27, 0x50, 0x16, 0xa0, 0xca, 0x2c, 0x37, 0x45, 0x66, 0xc6, 0x5d,
+	0x58, 0x65, 0xd1, 0xe1, 0xfc, 0x02, 0x30, 0xfe, 0x3e, 0x0f, 0xe8, 0x88, 0x04, 0x1e, 0xfc, 0xc0,
+	0x9e, 0x4f, 0x6c, 0x89, 0x3c, 0x2f, 0x11, 0x03, 0x0f, 0x99, 0x1c, 0x9b, 0xc7, 0x40, 0x1a, 0xeb,
+	0xe8, 0xd8, 0x52, 0x74, 0x54, 0x9c, 0x14, 0x1d, 0x2d, 0x90, 0x1d, 0x52, 0x43, 0x8e, 0xd2, 0xf4,
+	0x90, 0xe3, 0x33, 0xa8, 0xf4, 0x7d, 0x6f, 0x28, 0x02, 0xb9, 0xe5, 0x74, 0x20, 0x07, 0x64, 0x9c,
+	0x7d, 0x93, 0x50, 0xc3, 0xc7, 0x01, 0xf6, 0xbf, 0x8f, 0x42, 0x9d, 0xa8, 0x6d, 0xb4, 0x61, 0xdd,
+	0x64, 0xdf, 0x6f, 0x23, 0x28, 0xe3, 0x11, 0xac, 0xbf, 0x74, 0x83, 0xb4, 0xbc, 0xe7, 0x49, 0xe5,
+	0x19, 0xff, 0x95, 0x87, 0xb5, 0x67, 0x34, 0xda, 0x5b, 0x1c, 0x99, 0xec, 0x02, 0x8b, 0xdb, 0xb8,
+	0x92, 0xf3, 0x96, 0x12, 0x6d, 0x16, 0x16, 0x88, 0x36, 0x13, 0xb1, 0x57, 0x31, 0x1d, 0x7b, 0x7d,
+	0xa7, 0xc6, 0x5e, 0xec, 0xae, 0xf9, 0x09, 0x3f, 0x1d, 0x53, 0xab, 0x98, 0x11, 0x86, 0xdd, 0x83,
+	0x15, 0x7c, 0x4e, 0x8c, 0x1b, 0xdb, 0x5d, 0xa6, 0x59, 0xcd, 0x52, 0x7a, 0xb1, 0x75, 0x01, 0x73,
+	0x48, 0x41, 0xde, 0x3a, 0x19, 0xf6, 0x08, 0xd6, 0xb9, 0x4f, 0x79, 0x83, 0xed, 0x7a, 0x02, 0x57,
+	0x58, 0x0f, 0x3b, 0xfb, 0x6d, 0x12, 0x12, 0x04, 0x0b, 0x51, 0x78, 0x05, 0xab, 0xac, 0x87, 0xde,
+	0x4e, 0x38, 0x66, 0x42, 0xa7, 0x73, 0xd3, 0x75, 0xfa, 0x26, 0x94, 0x43, 0x6f, 0xca, 0x45, 0x46,
+	0x0b, 0x3d, 0xf6, 0x65, 0xfc, 0x04, 0x36, 0xd8, 0xd7, 0x9e, 0xe5, 0x3a, 0x7d, 0x1c, 0x2c, 0xb6,
+	0xd8, 0x31, 0xd4, 0x04, 0x1e, 0x13, 0xf3, 0x8c, 0xd8, 0x4b, 0x3d, 0x93, 0xf3, 0xc9, 0x33, 0xf9,
+	0x23, 0x58, 0x89, 0x13, 0x29, 0x5d, 0x5a, 0xf9, 0x61, 0x6e, 0xa5, 0x16, 0xa5, 0x4f, 0x76, 0x49,
+	0x09, 0xe8, 0x14, 0x56, 0x0f, 0xad, 0xde, 0xab, 0x37, 0xb0, 0x87, 0xcf, 0x61, 0x6d, 0x68, 0x9d,
+	0x77, 0x69, 0x88, 0x95, 0xe2, 0xa4, 0x31, 0xb4, 0xce, 0x09, 0xb3, 0x47, 0xd1, 0xed, 0xf3, 0x01,
+	0x20, 0x79, 0x22, 0x7e, 0xd4, 0xf2, 0x18, 0x2d, 0xe8, 0x8e, 0xac, 0xde, 0x2b, 0x2c, 0x02, 0x12,
+	0x1a, 0xa3, 0x05, 0x87, 0xb4, 0xcb, 0xf8, 0xe7, 0x02, 0xac, 0x12, 0x9f, 0x3e, 0xc9, 0x6d, 0x14,
+	0xb2, 0xdc, 0x46, 0x22, 0x39, 0x9f, 0x9f, 0x9d, 0x9c, 0x4f, 0x68, 0x45, 0x21, 0xc3, 0x2f, 0x4a,
+	0x5a, 0xf1, 0x69, 0x46, 0xd5, 0x69, 0xa2, 0x13, 0x6d, 0x40, 0xc1, 0x1a, 0x0c, 0xf8, 0xa9, 0x45,
+	0x3e, 0x89, 0xc1, 0xb0, 0x0c, 0x40, 0x89, 0xf6, 0xb1, 0x06, 0x09, 0x86, 0xa2, 0xb3, 0x94, 0xdf,
+	0xf3, 0x96, 0xe9, 0x78, 0x5d, 0x9c, 0xa7, 0xac, 0x17, 0x75, 0x54, 0xc7, 0xc0, 0xd2, 0x9d, 0x1f,
+	0xd3, 0xe9, 0x53, 0x92, 0x9a, 0xe1, 0x16, 0xe2, 0xb3, 0xa5, 0xac, 0x9c, 0x2d, 0xd7, 0xa1, 0x72,
+	0x62, 0x05, 0xe2, 0xdc, 0xa5, 0xf7, 0xcd, 0xb2, 0x09, 0xa4, 0x8b, 0x1d, 0xb7, 0x6f, 0xed, 0x19,
+	0xd6, 0x01, 0x1d, 0xc4, 0x81, 0x20, 0x67, 0x96, 0x9c, 0xc0, 0x64, 0x05, 0x6c, 0x8e, 0x39, 0x4f,
+	0xe0, 0xbd, 0xc8, 0xc7, 0x2c, 0x82, 0x36, 0xa9, 0xae, 0x63, 0xfc, 0x26, 0x07, 0x6b, 0x4c, 0xd0,
+	0x6f, 0x60, 0x14, 0x08, 0x8a, 0x81, 0xd7, 0x0f, 0xf9, 0x11, 0x41, 0xbf, 0xe5, 0x6b, 0x7b, 0x61,
+	0xfe, 0x52, 0xd5, 0x23, 0x7a, 0x5e, 0x86, 0x9e, 0xff, 0x06, 0x6c, 0x18, 0xbf, 0x02, 0xf4, 0x8c,
+	0x44, 0xcf, 0x93, 0x51, 0x0b, 0x93, 0x56, 0x60, 0xc0, 0x72, 0xe8, 0x75, 0xa9, 0xe0, 0xf2, 0x49,
+	0xdb, 0x2a, 0x85, 0x1e, 0xf9, 0x6b, 0xfc, 0x75, 0x0e, 0x1a, 0x47, 0xa1, 0x75, 0x8a, 0x77, 0x06,
+	0xde, 0x89, 0xa0, 0x1e, 0x6d, 0x75, 0x8e, 0xe6, 0x84, 0x59, 0x03, 0x7d, 0x06, 0x65, 0x1b, 0xd3,
+	0xe8, 0x8e, 0xa7, 0xa5, 0xeb, 0x3c, 0x94, 0x6e, 0x89, 0x5e, 0x33, 0x06, 0x20, 0x5a, 0x17, 0x86,
+	0x83, 0x6e, 0x80, 0x7b, 0x1e, 0xc9, 0xc2, 0x10, 0x71, 0x15, 0x4c, 0x08, 0xc3, 0xc1, 0x11, 0xeb,
+	0x21, 0x9b, 0xc6, 0x12, 0xa2, 0x22, 0x14, 0x62, 0x2d, 0xe3, 0x29, 0x00, 0x65, 0xc8, 0x26, 0x1c,
+	0x49, 0x50, 0x39, 0x19, 0x6a, 0x86, 0xcf, 0x34, 0xee, 0x42, 0x93, 0x2b, 0x52, 0x4c, 0x4b, 0xac,
+	0x6e, 0x02, 0x49, 0xe3, 0x02, 0xd6, 0x0f, 0xc7, 0x21, 0x75, 0x75, 0x14, 0x47, 0x52, 0xbe, 0x69,
+	0xde, 0x3b, 0x26, 0x97, 0x57, 0x38, 0x54, 0xd2, 0xe6, 0x85, 0xe9, 0x69, 0xf3, 0xbf, 0xca, 0xc3,
+	0x2a, 0x9f, 0xfb, 0xa5, 0xf9, 0x62, 0xce, 0x89, 0x1b, 0x50, 0x18, 0xfb, 0x03, 0x3e, 0x2b, 0xf9,
+	0x44, 0x3f, 0x85, 0x65, 0x12, 0x55, 0x63, 0x3f, 0xe0, 0x13, 0x7e, 0x40, 0x71, 0x52, 0x94, 0xb7,
+	0x76, 0x19, 0x94, 0x48, 0x6c, 0xb3, 0x16, 0x89, 0x5c, 0xc9, 0x31, 0xc0, 0x44, 0xca, 0x03, 0xf8,
+	0xa1, 0x75, 0xce, 0x4e, 0x21, 0x65, 0xf7, 0x97, 0x66, 0xec, 0xbe, 0xfe, 0x10, 0xaa, 0xf2, 0x1c,
+	0x0b, 0xb9, 0x93, 0x73, 0x58, 0xe3, 0x1c, 0xef, 0x8d, 0x07, 0xa1, 0x33, 0xa7, 0x34, 0x24, 0x7a,
+	0x85, 0x09, 0x3a, 0x5b, 0x98, 0xc1, 0xb5, 0xf1, 0x2f, 0x05, 0xa8, 0x3f, 0xc7, 0x74, 0xea, 0x39,
+	0x67, 0x25, 0xf7, 0x62, 0x7a, 0xfb, 0x91, 0x14, 0xb1, 0x60, 0x56, 0x58, 0x1f, 0x13, 0x5c, 0xfa,
+	0xc6, 0x5d, 0x90, 0x4f, 0xf7, 0x1b, 0xe2, 0x02, 0x5f, 0x94, 0x32, 0xc8, 0xf4, 0x72, 0x2a, 0x2e,
+	0xf3, 0x89, 0xe3, 0x6c, 0x69, 0x7a, 0x90, 0xb3, 0x09, 0xa5, 0xb1, 0x1b, 0x58, 0x7d, 0xcc, 0x0f,
+	0x24, 0xde, 0x92, 0xd4, 0x74, 0x59, 0x51, 0x53, 0xe2, 0x3b, 0xad, 0x00, 0xdf, 0xbf, 0xc7, 0xc3,
+	0x7c, 0xde, 0x22, 0x37, 0xe7, 0x81, 0xe3, 0xe2, 0x2e, 0xab, 0x20, 0x95, 0xa5, 0x9c, 0xfc, 0x0b,
+	0xc7, 0xe5, 0x15, 0xa4, 0xf2, 0x40, 0x7c, 0xa2, 0x2d, 0xa8, 0x0e, 0xb1, 0x7f, 0x8a, 0x05, 0x97,
+	0x90, 0x76, 0x4b, 0x15, 0x0a, 0xc0, 0xd9, 0x24, 0x97, 0x45, 0xa7, 0xdf, 0xef, 0x7a, 0xee, 0xe0,
+	0x82, 0xe6, 0x32, 0x35, 0x53, 0x23, 0x1d, 0x07, 0xee, 0xe0, 0x82, 0x9c, 0x9e, 0x81, 0x37, 0xf6,
+	0x7b, 0xb8, 0x8b, 0xdd, 0x9e, 0x67, 0x3b, 0xee, 0x29, 0xcf, 0x67, 0xd6, 0x59, 0x77, 0x9b, 0xf7,
+	0x12, 0xc0, 0xd0, 0xf2, 0x4f, 0x71, 0x18, 0x03, 0xd6, 0x18, 0x20, 0xeb, 0x16, 0x80, 0xa4, 0xa0,
+	0x15, 0xb1, 0x4d, 0x73, 0x26, 0xe4, 0xda, 0x11, 0xe5, 0x4c, 0x48, 0x83, 0xf4, 0xf6, 0xbc, 0xb1,
+	0x1b, 0x8a, 0x8a, 0x1b, 0x6d, 0x18, 0xff, 0x5d, 0x80, 0xfa, 0xe1, 0x78, 0x11, 0x95, 0x58, 0xa4,
+	0x8e, 0x1b, 0x29, 0x6d, 0x41, 0x76, 0xb4, 0x13, 0x3c, 0xe3, 0x62, 0x26, 0x48, 0x43, 0x10, 0x35,
+	0x59, 0x4b, 0x35, 0xa2, 0x6c, 0xd6, 0xd5, 0x3c, 0x2c, 0x49, 0x8b, 0x45, 0xd7, 0x09, 0x1a, 0x5d,
+	0x32, 0x05, 0xa9, 0x8a, 0x4e, 0x12, 0x5c, 0x26, 0xdd, 0x39, 0x4b, 0xc3, 0xc8, 0xee, 0xfc, 0x71,
+	0xc2, 0x12, 0x98, 0xc6, 0x5c, 0x4b, 0x9d, 0x8f, 0x2f, 0x3b, 0x6e, 0x78, 0xff, 0xde, 0xcf, 0xc8,
+	0x42, 0x55, 0x3b, 0x79, 0x10, 0x55, 0xab, 0x98, 0xee, 0x5c, 0x97, 0x7d, 0x97, 0x70, 0x5c, 0x59,
+	0x55, 0xab, 0xf7, 0xa1, 0xda, 0xf3, 0xdc, 0x90, 0xdc, 0xb8, 0xa9, 0xcc, 0xf9, 0x63, 0x02, 0xde,
+	0x47, 0xe4, 0xfc, 0x36, 0xf5, 0x9e, 0xfb, 0xb0, 0xc1, 0x5d, 0xc2, 0xb6, 0xdf, 0x3b, 0x73, 0xbe,
+	0xcf, 0x50, 0x83, 0x42, 0x86, 0x1a, 0x18, 0xff, 0x10, 0xe7, 0x60, 0x16, 0x50, 0x9e, 0x1b, 0xf2,
+	0xcb, 0xac, 0x79, 0xbc, 0x41, 0x61, 0x5e, 0x6f, 0x50, 0x9c, 0xe0, 0x0d, 0x96, 0x94, 0x33, 0x70,
+	0x17, 0x56, 0x22, 0x35, 0x9d, 0xfb, 0xf8, 0xe3, 0x33, 0xe4, 0xe5, 0x19, 0x8c, 0x6f, 0xa0, 0x11,
+	0x53, 0xe2, 0x57, 0x04, 0xc5, 0x34, 0x72, 0x53, 0x4d, 0xc3, 0xf8, 0xcb, 0x3c, 0xcb, 0xff, 0xfc,
+	0x80, 0xc2, 0x6b, 0xc2, 0xb2, 0x8f, 0x7b, 0x63, 0x3f, 0x10, 0xd2, 0x13, 0x4d, 0x69, 0xd1, 0x4b,
+	0x13, 0xc4, 0x5a, 0x52, 0x2c, 0x97, 0x54, 0xf3, 0x5d, 0x92, 0x1a, 0x60, 0x97, 0x00, 0xd6, 0xc8,
+	0xba, 0x24, 0x68, 0x59, 0x97, 0x04, 0xe3, 0x19, 0xac, 0x0b, 0x51, 0x28, 0x47, 0xe2, 0x16, 0x61,
+	0x90, 0x7e, 0x72, 0x2d, 0x5c, 0x8f, 0x2e, 0x0e, 0x92, 0xd8, 0x4c, 0x01, 0x64, 0x3c, 0x83, 0x8d,
+	0x04, 0x9d, 0x38, 0x4d, 0x1a, 0x3d, 0x5e, 0x08, 0x94, 0x34, 0x69, 0xf4, 0xc0, 0xc1, 0x2c, 0x8b,
+	0xe7, 0x0b, 0x81, 0x71, 0x0f, 0xd6, 0x8e, 0x70, 0xd8, 0x11, 0x95, 0xe1, 0xf9, 0xb6, 0x87, 0x60,
+	0x3d, 0x25, 0xd5, 0xaa, 0xbd, 0x85, 0xb0, 0xfe, 0x18, 0x56, 0x8e, 0x70, 0x48, 0xad, 0x77, 0x4e,
+	0x35, 0x10, 0xaf, 0x36, 0xf3, 0xf1, 0xab, 0x4d, 0xd5, 0xd1, 0x0a, 0xfb, 0x36, 0xc6, 0x70, 0x85,
+	0xe0, 0x3d, 0xf5, 0x86, 0x24, 0x8d, 0xb2, 0xed, 0xda, 0x47, 0xaf, 0xad, 0xd1, 0x9c, 0xb3, 0xa4,
+	0xbc, 0x66, 0x3e, 0xc3, 0x6b, 0x66, 0xfa, 0x77, 0xe3, 0x5b, 0xd0, 0xb3, 0xa6, 0xe5, 0x7b, 0xd1,
+	0x84, 0xe5, 0xe0, 0x35, 0xa9, 0x48, 0xb2, 0x2b, 0xb4, 0x66, 0x8a, 0x66, 0xf4, 0xee, 0x33, 0x2f,
+	0xbd, 0xfb, 0xfc, 0x53, 0x58, 0x79, 0xfe, 0xf6, 0xe2, 0x89, 0xf5, 0xb9, 0xa0, 0x18, 0xf1, 0x89,
+	0xc8, 0xa2, 0x2e, 0x60, 0x85, 0x13, 0x1c, 0x82, 0x64, 0x1b, 0x05, 0xc5, 0xe5, 0x7c, 0x0b, 0xab,
+	0x04, 0x3b, 0xa0, 0xb9, 0xeb, 0xf9, 0x9c, 0xeb, 0x44, 0xa7, 0xf3, 0x19, 0x20, 0x99, 0x16, 0x97,
+	0xe8, 0x26, 0x94, 0x78, 0xc6, 0x9c, 0x90, 0xd3, 0x4c, 0xde, 0x32, 0x7a, 0x80, 0xe2, 0xd5, 0x05,
+	0x6f, 0x37, 0xf5, 0xc4, 0xe5, 0xd9, 0xd0, 0x90, 0x45, 0x18, 0x8c, 0x07, 0xf3, 0x84, 0xb2, 0xd8,
+	0xf7, 0x3d, 0x5f, 0x1c, 0x46, 0xb4, 0x41, 0x22, 0x26, 0x92, 0xfb, 0xef, 0x7b, 0x63, 0xd7, 0xe6,
+	0xdb, 0xa4, 0xb9, 0x5e, 0xf8, 0x8c, 0xb4, 0x8d, 0x96, 0xb8, 0xe8, 0xf2, 0xa5, 0x44, 0x76, 0x5d,
+	0xf2, 0xe9, 0x94, 0x7c, 0x35, 0x1b, 0x22, 0x5c, 0x50, 0xf8, 0x31, 0x39, 0x90, 0x61, 0x42, 0x39,
+	0xaa, 0xa8, 0xa0, 0x8f, 0xa0, 0x28, 0xf9, 0x69, 0x44, 0x31, 0xa3, 0x51, 0xea, 0xac, 0xe9, 0x78,
+	0xb4, 0x98, 0x7c, 0xb6, 0xfd, 0x3e, 0x86, 0x95, 0x9f, 0x59, 0x03, 0xc7, 0xa6, 0x35, 0x15, 0x91,
+	0xb5, 0x2b, 0x47, 0x15, 0x1d, 0xc5, 0xd9, 0x44, 0xe4, 0xcd, 0x18, 0x80, 0x5c, 0xe2, 0xeb, 0x31,
+	0x05, 0x2a, 0xbf, 0x04, 0x81, 0xdc, 0x54, 0x02, 0xd9, 0xaf, 0x9d, 0xe5, 0x12, 0x59, 0x41, 0x2d,
+	0x91, 0x45, 0xe2, 0x2f, 0x4a, 0xe2, 0x37, 0x1e, 0x43, 0x43, 0xe2, 0x82, 0x89, 0xf7, 0xd3, 0x84,
+	0x78, 0xd7, 0x28, 0x13, 0x2a, 0xb3, 0x91, 0x70, 0x1f, 0xc2, 0x5a, 0xfb, 0x7c, 0xe4, 0xbd, 0x51,
+	0xb6, 0xfb, 0x10, 0xaa, 0x0c, 0xd7, 0xc4, 0x3d, 0xcf, 0xb7, 0x93, 0x8f, 0xcd, 0x72, 0x53, 0x1e,
+	0x9b, 0xa9, 0xa1, 0x4d, 0xe4, 0x83, 0xf6, 0xa0, 0x61, 0x62, 0xcb, 0x66, 0xa7, 0xe3, 0x02, 0xac,
+	0x4c, 0x78, 0x3b, 0xbe, 0x2f, 0x16, 0x77, 0xec, 0x1d, 0x5a, 0xe1, 0xd9, 0xa2, 0x89, 0x96, 0xd4,
+	0x5b, 0xf7, 0xef, 0x60, 0x5d, 0xa5, 0xc7, 0x25, 0xbe, 0x0e, 0x4b, 0x64, 0x61, 0x81, 0x08, 0xdd,
+	0x69, 0x63, 0x56, 0x32, 0xe0, 0xb7, 0x39, 0x58, 0xeb, 0x0c, 0xd3, 0xa2, 0x9f, 0x91, 0x55, 0x52,
+	0x0a, 0x39, 0xf9, 0x89, 0x85, 0x1c, 0xf5, 0x99, 0xcb, 0x27, 0x44, 0x25, 0xc8, 0x1e, 0xf1, 0xfb,
+	0xdc, 0x2a, 0xa5, 0x2a, 0x6f, 0x9e, 0xc9, 0x01, 0x0c, 0x04, 0x0d, 0x72, 0x1a, 0xcb, 0x5b, 0x60,
+	0xac, 0xc1, 0xaa, 0x5c, 0xc5, 0x64, 0x9d, 0x0f, 0x61, 0x53, 0xe4, 0x0c, 0xb0, 0x8f, 0xdd, 0x5e,
+	0xec, 0xab, 0x66, 0x3e, 0x3f, 0x32, 0xbe, 0x82, 0xcb, 0x29, 0x5c, 0x2e, 0xcb, 0x19, 0x01, 0xec,
+	0x1e, 0x34, 0x5a, 0xe3, 0xe1, 0x48, 0xd1, 0x90, 0xec, 0x6a, 0x73, 0xbc, 0xcb, 0xf9, 0xc9, 0x2a,
+	0xfc, 0x12, 0x56, 0x0e, 0xc7, 0x21, 0xe7, 0xe5, 0x77, 0x96, 0x66, 0x32, 0xc6, 0xf4, 0xfc, 0x53,
+	0xc8, 0xce, 0x14, 0x4a, 0xe6, 0xad, 0xbd, 0x38, 0xeb, 0xd6, 0xae, 0xa8, 0xd4, 0x7d, 0x71, 0x74,
+	0x2c, 0x36, 0xb3, 0xf1, 0x00, 0xd6, 0x44, 0x82, 0x73, 0x31, 0x44, 0xae, 0x2c, 0x32, 0x96, 0xf1,
+	0x45, 0x74, 0xc3, 0x90, 0x6b, 0x22, 0xd3, 0x5f, 0x74, 0x19, 0x1f, 0xb3, 0xb0, 0x5a, 0xc6, 0xc8,
+	0xdc, 0xd5, 0xb8, 0x82, 0x3a, 0x3f, 0xf1, 0x5b, 0x07, 0xe2, 0x4d, 0x3e, 0xbf, 0xdd, 0x36, 0x9e,
+	0x1e, 0xec, 0xed, 0x75, 0x8e, 0xbb, 0xc7, 0xbf, 0x3c, 0x6c, 0x77, 0xf7, 0x0f, 0xf6, 0xdb, 0x8d,
+	0x4b, 0xc9, 0x5e, 0xb3, 0xbd, 0xdd, 0x6a, 0xe4, 0xd0, 0x06, 0xac, 0xca, 0xbd, 0x3f, 0x37, 0x3b,
+	0xc7, 0xed, 0x46, 0xfe, 0xd6, 0x2e, 0x7b, 0x3f, 0x4d, 0xc9, 0x21, 0xa8, 0x3f, 0xeb, 0xbc, 0x68,
+	0x2b, 0xc4, 0x36, 0x60, 0x35, 0xee, 0x33, 0xdb, 0xcf, 0x5f, 0xbe, 0xd8, 0x36, 0x1b, 0x39, 0xb4,
+	0x0a, 0xb5, 0xb8, 0xbb, 0xd5, 0x31, 0x1b, 0xf9, 0x5b, 0x03, 0x80, 0xf8, 0x21, 0x0b, 0x65, 0x62,
+	0x77, 0x7b, 0xff, 0x79, 0x8a, 0x9a, 0xdc, 0xbb, 0xdd, 0x6a, 0xb5, 0x09, 0x6f, 0x4d, 0x58, 0x97,
+	0xbb, 0xf7, 0x0e, 0x5a, 0x9d, 0x67, 0x9d, 0x76, 0xab, 0x91, 0x47, 0x97, 0x61, 0x4d, 0x1e, 0x69,
+	0xb5, 0x5f, 0xb4, 0x8f, 0xdb, 0xad, 0x46, 0xe1, 0x96, 0x09, 0x10, 0xd9, 0x31, 0x9d, 0xed, 0x68,
+	0x77, 0xdb, 0x6c, 0x75, 0x8f, 0x8e, 0xb7, 0x8f, 0xa3, 0xd9, 0x2e, 0xc3, 0x9a, 0xdc, 0xfb, 0xe2,
+	0x60, 0xbb, 0xd5, 0xd9, 0x7f, 0xce, 0x64, 0x21, 0x0f, 0x10, 0x09, 0xfd, 0xb2, 0x91, 0xbf, 0xf5,
+	0x09, 0x94, 0x23, 0x13, 0x40, 0x1a, 0x14, 0x39, 0x19, 0x0d, 0x8a, 0xdf, 0x1e, 0x1d, 0xec, 0x37,
+	0x72, 0xe4, 0xeb, 0x45, 0x67, 0x9f, 0x88, 0xed, 0x4f, 0xa0, 0xa6, 0x1c, 0xd5, 0x64, 0xae, 0x83,
+	0xc3, 0xb6, 0xb9, 0x7d, 0xdc, 0x39, 0xd8, 0x57, 0x96, 0xbc, 0x09, 0x28, 0x31, 0x70, 0xf8, 0xf2,
+	0xb8, 0x91, 0x43, 0x57, 0x60, 0x23, 0xd1, 0xcf, 0x16, 0xd7, 0xc8, 0xdf, 0xfd, 0xcd, 0x65, 0x28,
+	0x6c, 0x1f, 0x76, 0xd0, 0x37, 0x00, 0xf1, 0x5b, 0x09, 0xb4, 0xc9, 0x8c, 0x3e, 0xf9, 0x78, 0x42,
+	0xdf, 0x4c, 0x65, 0x00, 0xda, 0xe4, 0x87, 0x5c, 0xc6, 0x25, 0xf4, 0x00, 0x2a, 0xd2, 0x23, 0x05,
+	0xc4, 0x5e, 0xa7, 0xa6, 0x9f, 0x2d, 0xe8, 0xea, 0x8f, 0x5d, 0x8c, 0x4b, 0xe8, 0x2e, 0x68, 0xe2,
+	0x65, 0x00, 0x8a, 0x6f, 0x3c, 0x32, 0x4a, 0x5d, 0x41, 0x09, 0x8c, 0x4b, 0x84, 0xd9, 0xf8, 0x3d,
+	0x00, 0x67, 0x36, 0xf5, 0x40, 0x60, 0x0a, 0xb3, 0x5f, 0x42, 0x45, 0x7a, 0x1a, 0xc0, 0x99, 0x4d,
+	0x3f, 0x16, 0xd0, 0x65, 0xdf, 0x67, 0x5c, 0x42, 0x5f, 0x43, 0x4d, 0x29, 0x95, 0xa3, 0x2b, 0x9c,
+	0xb3, 0x74, 0xf9, 0x3c, 0x89, 0xba, 0x03, 0x55, 0xb9, 0x34, 0x8c, 0x9a, 0x93, 0xaa, 0xc5, 0x53,
+	0xb8, 0xfe, 0x29, 0xd4, 0x94, 0x9a, 0x2d, 0x9f, 0x3e, 0xab, 0x8e, 0xab, 0x27, 0x7f, 0xc8, 0x60,
+	0x5c, 0x42, 0x5f, 0x01, 0xc4, 0x45, 0x28, 0x2e, 0xb4, 0x54, 0x55, 0x4a, 0x6f, 0x24, 0x10, 0x89,
+	0xb8, 0x1f, 0x42, 0x45, 0x2a, 0x09, 0x71, 0x71, 0xa5, 0x8b, 0x44, 0x99, 0xb8, 0x3b, 0x50, 0x95,
+	0x8b, 0x36, 0x7c, 0xe1, 0x19, 0x75, 0x9c, 0x29, 0x0b, 0x6f, 0x41, 0x4d, 0x29, 0xb9, 0xc4, 0x72,
+	0x4f, 0x95, 0x61, 0xa6, 0x50, 0x79, 0x08, 0x15, 0xa9, 0xf6, 0xc2, 0x57, 0x91, 0xae, 0xc6, 0x64,
+	0xae, 0x82, 0xcb, 0x8e, 0xd5, 0xb1, 0x24, 0xd9, 0x29, 0x85, 0xad, 0x4c, 0xcc, 0x78, 0xd3, 0x38,
+	0xb2, 0xb2, 0x69, 0x2a, 0x7e, 0xc6, 0xa6, 0xed, 0x02, 0x4a, 0x97, 0xda, 0xd1, 0x8f, 0x24, 0xc0,
+	0x8c, 0x1a, 0x3c, 0x67, 0x44, 0x7a, 0xd0, 0xc7, 0x96, 0x10, 0x97, 0xdc, 0x85, 0x81, 0x27, 0x6b,
+	0xf0, 0x99, 0x98, 0x2d, 0xa8, 0xab, 0xf5, 0x73, 0xa4, 0x4b, 0xd8, 0x89, 0xa2, 0xba, 0xce, 0xee,
+	0x16, 0x4a, 0xc9, 0xdc, 0xb8, 0x74, 0x27, 0x87, 0x1e, 0x03, 0xc4, 0x75, 0x66, 0x3e, 0x7f, 0xaa,
+	0xc2, 0xad, 0x5f, 0x4e, 0xf5, 0xb3, 0xf8, 0x86, 0xee, 0xdf, 0x32, 0xcf, 0x1f, 0xa2, 0xb5, 0x8c,
+	0x6c, 0xe2, 0xe4, 0x9d, 0xbf, 0x99, 0x23, 0x0e, 0x23, 0xae, 0x9b, 0x88, 0xc9, 0x93, 0x85, 0x94,
+	0x29, 0xba, 0xb3, 0x03, 0x55, 0xb9, 0x8a, 0xc1, 0xb5, 0x38, 0xa3, 0xb0, 0x31, 0xd5, 0x43, 0x96,
+	0xa3, 0xda, 0x1c, 0xda, 0x10, 0x2e, 0x47, 0xa9, 0xd5, 0xe9, 0x2b, 0x71, 0x37, 0xad, 0x72, 0x51,
+	0xe6, 0x5b, 0x50, 0x53, 0x4a, 0x59, 0x5c, 0x85, 0xb2, 0xca, 0x5b, 0x53, 0xa6, 0x7f, 0x0c, 0xcb,
+	0xcf, 0xb1, 0x2c, 0x3e, 0xb5, 0x36, 0xa2, 0x5f, 0x4d, 0x61, 0xd2, 0xe0, 0x88, 0xe6, 0x76, 0xe9,
+	0x06, 0xee, 0x41, 0x5d, 0xcd, 0x9d, 0x72, 0x35, 0xc8, 0x4c, 0xa8, 0xce, 0x26, 0x17, 0x1f, 0x18,
+	0x94, 0x27, 0xe5, 0xc0, 0x90, 0xf9, 0x52, 0xaf, 0x42, 0xd4, 0x0b, 0xc7, 0x51, 0xc4, 0xba, 0x9a,
+	0x70, 0xe4, 0x28, 0x1b, 0x89, 0xde, 0x48, 0x85, 0xf8, 0x59, 0x43, 0x27, 0xcc, 0xcc, 0xae, 0xe9,
+	0x89, 0x3c, 0x19, 0x9d, 0xae, 0x2e, 0x80, 0x8e, 0x42, 0x1f, 0x5b, 0xc3, 0x09, 0x98, 0x49, 0x3e,
+	0xef, 0xe4, 0xd0, 0x2e, 0xd4, 0x94, 0x0c, 0x1d, 0xdf, 0xb8, 0xac, 0xec, 0x9f, 0xae, 0x67, 0x0d,
+	0x45, 0x8c, 0x3f, 0x06, 0x88, 0x53, 0x21, 0x5c, 0x7f, 0x53, 0x79, 0x16, 0xfd, 0x72, 0xaa, 0x5f,
+	0x32, 0x1e, 0x4d, 0x24, 0xde, 0x38, 0xff, 0x89, 0x3c, 0xdc, 0x14, 0xcd, 0xf9, 0x39, 0xa0, 0x74,
+	0x86, 0x8b, 0xfb, 0xa0, 0x89, 0x19, 0x37, 0xfd, 0xfa, 0xc4, 0xf1, 0x88, 0xa9, 0x1d, 0xa8, 0xca,
+	0x99, 0x47, 0x6e, 0x55, 0x19, 0xc9, 0xc8, 0x29, 0xcc, 0x3d, 0x01, 0xed, 0xb9, 0xba, 0xb0, 0x44,
+	0x06, 0x4d, 0x4f, 0x57, 0x2d, 0x8e, 0x42, 0xdf, 0x71, 0x4f, 0xb9, 0x2a, 0xc6, 0xc1, 0x04, 0x55,
+	0x8b, 0xcd, 0x54, 0x52, 0x65, 0xb6, 0x6f, 0xa8, 0xc4, 0xe0, 0xe2, 0x74, 0x4c, 0xa7, 0xa2, 0xf4,
+	0x66, 0x7a, 0x20, 0x92, 0xc4, 0xd7, 0xa0, 0x89, 0x44, 0x03, 0x5f, 0x45, 0x22, 0xcd, 0xa2, 0x6f,
+	0x24, 0x7a, 0x25, 0xd5, 0xa8, 0xca, 0x99, 0x08, 0x2e, 0xc4, 0x8c, 0xe4, 0x84, 0x9e, 0xbe, 0xbd,
+	0x52, 0x2d, 0xfd, 0x1a, 0xca, 0x51, 0xf2, 0x80, 0xfb, 0xa5, 0x64, 0x32, 0x61, 0x32, 0x6a, 0xb5,
+	0x33, 0x4c, 0xcd, 0x9d, 0x71, 0x3b, 0x4f, 0x84, 0x43, 0x37, 0x73, 0xa8, 0x0d, 0x55, 0x39, 0x27,
+	0xa0, 0xb0, 0xad, 0xa4, 0x1d, 0xf4, 0x2b, 0x19, 0x23, 0xd1, 0xea, 0xbf, 0x84, 0x72, 0x74, 0xed,
+	0xe6, 0xcc, 0x27, 0xaf, 0xe1, 0xfa, 0x8a, 0xfa, 0xfc, 0x3a, 0x60, 0xf6, 0x14, 0xdf, 0xcc, 0xf9,
+	0x9e, 0xa7, 0xae, 0xea, 0xfa, 0xe5, 0x54, 0x7f, 0x34, 0xef, 0x3e, 0xac, 0x24, 0x6e, 0xe2, 0xe8,
+	0xaa, 0xf2, 0x1e, 0x40, 0xbd, 0xdb, 0xeb, 0xd7, 0xb2, 0x07, 0x05, 0xbd, 0xbb, 0x7f, 0xbb, 0x49,
+	0xdc, 0x61, 0x88, 0x7d, 0xd7, 0x1a, 0xfc, 0xbf, 0x0b, 0xc7, 0x9f, 0xcc, 0x19, 0x8e, 0xcf, 0x8a,
+	0x10, 0xe7, 0x8b, 0xcc, 0xa7, 0x52, 0x51, 0xde, 0xb0, 0x72, 0x2a, 0x59, 0xef, 0x5a, 0xa7, 0xc7,
+	0x0a, 0xef, 0x42, 0xfd, 0x77, 0xa1, 0xfe, 0xbb, 0x50, 0xff, 0x5d, 0xa8, 0xff, 0x03, 0x85, 0xfa,
+	0x2d, 0x58, 0x4d, 0xbd, 0x97, 0x43, 0xef, 0xc9, 0xca, 0x98, 0x7a, 0x47, 0xa7, 0x27, 0x7e, 0xa1,
+	0xfb, 0xbb, 0xb8, 0x30, 0xfc, 0x5f, 0x89, 0xf0, 0xdf, 0x05, 0xd7, 0x53, 0x6c, 0x41, 0x2e, 0xf2,
+	0x73, 0x1a, 0x19, 0x75, 0xff, 0x3f, 0xf8, 0x00, 0xfd, 0x87, 0x8c, 0xb2, 0xff, 0x40, 0x62, 0x5c,
+	0xb2, 0x8e, 0xa8, 0x06, 0xc5, 0xd7, 0x91, 0xac, 0x49, 0x71, 0x5f, 0x20, 0x7e, 0x7a, 0x4c, 0x96,
+	0x7f, 0xf7, 0xef, 0x8a, 0xfc, 0xdf, 0xc9, 0x20, 0x71, 0xf1, 0x3d, 0xd0, 0x44, 0xe1, 0x89, 0x6b,
+	0x53, 0xa2, 0x0e, 0x95, 0x76, 0x64, 0x37, 0x73, 0x68, 0x9b, 0xea, 0xa0, 0x8c, 0x95, 0x28, 0x33,
+	0xcd, 0x76, 0x66, 0x4f, 0x84, 0x12, 0x31, 0x2a, 0xb2, 0x12, 0x29, 0x84, 0xa6, 0x05, 0x25, 0x55,
+	0xb9, 0x5a, 0x24, 0x2e, 0x4b, 0xe9, 0x02, 0x92, 0x9e, 0xf8, 0xb9, 0x7f, 0x7c, 0xcd, 0x61, 0x88,
+	0xb1, 0x0a, 0x28, 0x58, 0x2b, 0x2a, 0x56, 0x40, 0xd1, 0xf8, 0x2d, 0x82, 0x06, 0x02, 0xaa, 0x6c,
+	0xe7, 0xba, 0x3c, 0x50, 0x3c, 0xc5, 0x71, 0xcb, 0x11, 0x44, 0x72, 0xb3, 0xd0, 0x17, 0xcc, 0xfb,
+	0x52, 0xac, 0xd8, 0xfb, 0x4e, 0x43, 0xb9, 0x93, 0x8b, 0xcd, 0x5b, 0x8a, 0x56, 0x52, 0xb5, 0xaa,
+	0xc9, 0xdc, 0x9e, 0x94, 0x68, 0xcf, 0x17, 0xff, 0x33, 0x00, 0x29, 0xf3, 0x0e, 0xa6, 0x9d, 0x4e,
+	0x00, 0x00,
 }
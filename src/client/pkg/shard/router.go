@@ -2,6 +2,7 @@ package shard
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/pachyderm/pachyderm/src/client/pkg/grpcutil"
 	"google.golang.org/grpc"
@@ -53,24 +54,40 @@ func (r *router) GetAllShards(version int64) (map[uint64]bool, error) {
 	return result, nil
 }
 
-func (r *router) GetClientConn(shard uint64, version int64) (*grpc.ClientConn, error) {
+func (r *router) GetAddress(shard uint64, version int64) (string, error) {
 	address, ok, err := r.sharder.GetAddress(shard, version)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	if !ok {
-		return nil, fmt.Errorf("no master found for %d", shard)
+		return "", fmt.Errorf("no master found for %d", shard)
+	}
+	return address, nil
+}
+
+func (r *router) GetClientConn(shard uint64, version int64) (*grpc.ClientConn, error) {
+	address, err := r.GetAddress(shard, version)
+	if err != nil {
+		return nil, err
 	}
 	return r.dialer.Dial(address)
 }
 
+// GetAllClientConns returns a ClientConn for each server in the cluster. The
+// ClientConns are always returned in the same order so that callers that
+// contact servers one at a time, contact them in a consistent order.
 func (r *router) GetAllClientConns(version int64) ([]*grpc.ClientConn, error) {
-	addresses, err := r.getAllAddresses(version)
+	addressSet, err := r.getAllAddresses(version)
 	if err != nil {
 		return nil, err
 	}
+	var addresses []string
+	for address := range addressSet {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
 	var clientConns []*grpc.ClientConn
-	for address := range addresses {
+	for _, address := range addresses {
 		// TODO: huge race, this whole thing is bad
 		clientConn, err := r.dialer.Dial(address)
 		if err != nil {
@@ -48,6 +48,7 @@ type Frontend interface {
 
 type Router interface {
 	GetShards(version int64) (map[uint64]bool, error)
+	GetAddress(shard uint64, version int64) (string, error)
 	GetClientConn(shard uint64, version int64) (*grpc.ClientConn, error)
 	GetAllClientConns(version int64) ([]*grpc.ClientConn, error)
 }
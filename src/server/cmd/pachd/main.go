@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/pachyderm/pachyderm/src/client"
 	pfsclient "github.com/pachyderm/pachyderm/src/client/pfs"
@@ -52,6 +53,75 @@ type appEnv struct {
 	Namespace       string `env:"NAMESPACE,default=default"`
 	Metrics         bool   `env:"METRICS,default=true"`
 	Init            bool   `env:"INIT,default=false"`
+	// MaxUnflushedBytes limits the amount of data that can be written to open
+	// commits in a shard before writes are rejected, 0 means no limit
+	MaxUnflushedBytes uint64 `env:"MAX_UNFLUSHED_BYTES,default=0"`
+	// DeletedCommitRetentionSeconds is how long soft deleted commits are kept
+	// before they're garbage collected, 0 means they're kept forever
+	DeletedCommitRetentionSeconds uint64 `env:"DELETED_COMMIT_RETENTION_SECONDS,default=0"`
+	// ExpiredFileSweepIntervalSeconds is how often files past their TTL are
+	// swept, 0 means they're never swept (they're still hidden)
+	ExpiredFileSweepIntervalSeconds uint64 `env:"EXPIRED_FILE_SWEEP_INTERVAL_SECONDS,default=0"`
+	// FlushIntervalSeconds is how often writes to open commits are flushed,
+	// 0 means they're only flushed when the commit is finished
+	FlushIntervalSeconds uint64 `env:"FLUSH_INTERVAL_SECONDS,default=0"`
+	// MaxOpenCommitsPerRepo limits the number of commits that can be open in
+	// a repo at once, 0 means no limit
+	MaxOpenCommitsPerRepo uint64 `env:"MAX_OPEN_COMMITS_PER_REPO,default=0"`
+	// OpenCommitTimeoutSeconds is how long a commit may stay open before
+	// it's cancelled, 0 means open commits are never cancelled
+	OpenCommitTimeoutSeconds uint64 `env:"OPEN_COMMIT_TIMEOUT_SECONDS,default=0"`
+	// CommitReservationTimeoutSeconds is how long a reserved commit ID stays
+	// reserved if it isn't started, 0 means forever
+	CommitReservationTimeoutSeconds uint64 `env:"COMMIT_RESERVATION_TIMEOUT_SECONDS,default=3600"`
+	// VerifyDiffs makes shards check the diffs they load against their
+	// checksums
+	VerifyDiffs bool `env:"VERIFY_DIFFS,default=false"`
+	// CaseInsensitiveRepoNames rejects repos whose names differ from an
+	// existing repo's only in case
+	CaseInsensitiveRepoNames bool `env:"CASE_INSENSITIVE_REPO_NAMES,default=false"`
+	// InlineFileSizeBytes is the size of the largest put that's stored in
+	// the commit's metadata rather than in a block, 0 means none are
+	InlineFileSizeBytes uint64 `env:"INLINE_FILE_SIZE_BYTES,default=0"`
+	// MirrorBlockAddress is the address of a block server that every write
+	// is mirrored to, writes aren't mirrored if it's empty
+	MirrorBlockAddress string `env:"MIRROR_BLOCK_ADDRESS,default="`
+	// MirrorAsync mirrors writes in the background rather than before they
+	// return
+	MirrorAsync bool `env:"MIRROR_ASYNC,default=false"`
+	// MirrorBestEffort logs writes the mirror fails rather than failing them
+	MirrorBestEffort bool `env:"MIRROR_BEST_EFFORT,default=false"`
+	// MirrorQueueBytes is the number of bytes of file content that async
+	// mirroring buffers, 0 means 64MiB
+	MirrorQueueBytes uint64 `env:"MIRROR_QUEUE_BYTES,default=0"`
+	// PutBlockParallelism is the number of blocks from a single write that
+	// are written to object storage at once
+	PutBlockParallelism int `env:"PUT_BLOCK_PARALLELISM,default=8"`
+	// MaxShardOperations is the number of file reads and writes that may run
+	// on a shard at once, 0 means no limit
+	MaxShardOperations int `env:"MAX_SHARD_OPERATIONS,default=0"`
+	// ShardSchedulePolicy is fifo, read or write, it decides whether reads or
+	// writes go first on a shard that's at MaxShardOperations
+	ShardSchedulePolicy string `env:"SHARD_SCHEDULE_POLICY,default=fifo"`
+	// VerifyBlocks makes reads check the blocks they read against their
+	// hashes
+	VerifyBlocks bool `env:"VERIFY_BLOCKS,default=false"`
+	// ScrubIntervalSeconds is how often blocks are read back and checked
+	// against their hashes, 0 means never
+	ScrubIntervalSeconds uint64 `env:"SCRUB_INTERVAL_SECONDS,default=0"`
+	// ScrubBytesPerSecond limits how fast blocks are scrubbed, 0 means no
+	// limit
+	ScrubBytesPerSecond uint64 `env:"SCRUB_BYTES_PER_SECOND,default=0"`
+	// DeltaPuts makes files that are put again only store what changed
+	// since the parent commit
+	DeltaPuts bool `env:"DELTA_PUTS,default=false"`
+	// ExportRoot is the directory that ExportToPath writes under, empty
+	// disables ExportToPath
+	ExportRoot string `env:"EXPORT_ROOT,default="`
+	// DenyPrivateURLs makes PutFileURL refuse URLs that resolve to loopback,
+	// private or link local addresses, it also stops PutFileURL from using
+	// proxies from the environment
+	DenyPrivateURLs bool `env:"DENY_PRIVATE_URLS,default=true"`
 }
 
 func main() {
@@ -124,11 +194,44 @@ func do(appEnvObj interface{}) error {
 			protolion.Printf("Error from sharder.AssignRoles: %s", err.Error())
 		}
 	}()
-	driver, err := drive.NewDriver(address)
+	shardSchedulePolicy, err := drive.ParseShardSchedulePolicy(appEnv.ShardSchedulePolicy)
 	if err != nil {
 		return err
 	}
-	apiServer := pfs_server.NewAPIServer(
+	driverOptions := drive.Options{
+		MaxUnflushedBytes:        appEnv.MaxUnflushedBytes,
+		DeletedCommitRetention:   time.Duration(appEnv.DeletedCommitRetentionSeconds) * time.Second,
+		ExpiredFileSweepInterval: time.Duration(appEnv.ExpiredFileSweepIntervalSeconds) * time.Second,
+		FlushInterval:            time.Duration(appEnv.FlushIntervalSeconds) * time.Second,
+		MaxOpenCommitsPerRepo:    appEnv.MaxOpenCommitsPerRepo,
+		OpenCommitTimeout:        time.Duration(appEnv.OpenCommitTimeoutSeconds) * time.Second,
+		CommitReservationTimeout: time.Duration(appEnv.CommitReservationTimeoutSeconds) * time.Second,
+		VerifyDiffs:              appEnv.VerifyDiffs,
+		CaseInsensitiveRepoNames: appEnv.CaseInsensitiveRepoNames,
+		InlineFileSize:           appEnv.InlineFileSizeBytes,
+		MaxShardOperations:       appEnv.MaxShardOperations,
+		ShardSchedulePolicy:      shardSchedulePolicy,
+		VerifyBlocks:             appEnv.VerifyBlocks,
+		ScrubInterval:            time.Duration(appEnv.ScrubIntervalSeconds) * time.Second,
+		ScrubBytesPerSecond:      appEnv.ScrubBytesPerSecond,
+		DeltaPuts:                appEnv.DeltaPuts,
+	}
+	driver, err := drive.NewDriverWithOptions(address, driverOptions)
+	if err != nil {
+		return err
+	}
+	if appEnv.MirrorBlockAddress != "" {
+		mirror, err := drive.NewDriverWithOptions(appEnv.MirrorBlockAddress, driverOptions)
+		if err != nil {
+			return err
+		}
+		driver = drive.NewTeeDriver(driver, mirror, drive.TeeOptions{
+			Async:      appEnv.MirrorAsync,
+			BestEffort: appEnv.MirrorBestEffort,
+			QueueBytes: appEnv.MirrorQueueBytes,
+		})
+	}
+	apiServer := pfs_server.NewAPIServerWithOptions(
 		pfsmodel.NewHasher(
 			appEnv.NumShards,
 			1,
@@ -140,13 +243,16 @@ func do(appEnvObj interface{}) error {
 			),
 			address,
 		),
+		pfs_server.APIServerOptions{
+			ExportRoot: appEnv.ExportRoot,
+		},
 	)
 	go func() {
 		if err := sharder.RegisterFrontends(nil, address, []shard.Frontend{apiServer}); err != nil {
 			protolion.Printf("Error from sharder.RegisterFrontend %s", err.Error())
 		}
 	}()
-	internalAPIServer := pfs_server.NewInternalAPIServer(
+	internalAPIServer := pfs_server.NewInternalAPIServerWithOptions(
 		pfsmodel.NewHasher(
 			appEnv.NumShards,
 			1,
@@ -159,6 +265,9 @@ func do(appEnvObj interface{}) error {
 			address,
 		),
 		driver,
+		pfs_server.InternalAPIServerOptions{
+			AllowPrivateURLs: !appEnv.DenyPrivateURLs,
+		},
 	)
 	ppsAPIServer := pps_server.NewAPIServer(
 		ppsserver.NewHasher(appEnv.NumShards, appEnv.NumShards),
@@ -171,7 +280,8 @@ func do(appEnvObj interface{}) error {
 			protolion.Printf("Error from sharder.Register %s", err.Error())
 		}
 	}()
-	blockAPIServer, err := pfs_server.NewBlockAPIServer(appEnv.StorageRoot, appEnv.StorageBackend)
+	blockAPIServer, err := pfs_server.NewBlockAPIServerWithOptions(appEnv.StorageRoot, appEnv.StorageBackend,
+		pfs_server.BlockAPIServerOptions{PutBlockParallelism: appEnv.PutBlockParallelism})
 	if err != nil {
 		return err
 	}
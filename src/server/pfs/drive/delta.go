@@ -0,0 +1,137 @@
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"path"
+
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+const (
+	// deltaChunkSize is roughly how much content is compared against the
+	// parent's at a time, line delimited chunks run on to the end of a line.
+	deltaChunkSize = 64 * 1024
+	// deltaMaxPending is how much changed content is buffered before it's
+	// written to the block server.
+	deltaMaxPending = 8 * 1024 * 1024
+)
+
+// deltaParent returns the block refs of the parent commit's version of a
+// file that's being put again, i.e. one that's been deleted in its commit
+// and hasn't been written since. It returns nil if there's no such version.
+// deltaParent assumes that the lock is being held
+func (d *driver) deltaParent(file *pfs.File, shard uint64) []*pfs.BlockRef {
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return nil
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok || diffInfo.ParentCommit == nil {
+		return nil
+	}
+	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+	if !ok || !_append.Delete || len(_append.BlockRefs) > 0 || len(_append.Handles) > 0 {
+		return nil
+	}
+	parentFile := client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path)
+	fileInfo, blockRefs, err := d.inspectFile(parentFile, nil, shard, nil, false, true, "")
+	if err != nil || fileInfo.FileType != pfs.FileType_FILE_TYPE_REGULAR {
+		return nil
+	}
+	return blockRefs
+}
+
+// putDelta writes the content in reader to the block server and returns
+// block refs for it. Chunks of content that match parentBlockRefs' content
+// at the same offset refer to the parent's blocks rather than being written
+// again, so a small edit to a big file only stores the chunks around it.
+// Content is compared by offset, an edit that changes the file's length
+// doesn't share anything after it with the parent.
+func (d *driver) putDelta(_client client.APIClient, delimiter pfs.Delimiter, reader io.Reader,
+	parentBlockRefs []*pfs.BlockRef) ([]*pfs.BlockRef, error) {
+	parentReader := newFileReader(_client.BlockAPIClient, parentBlockRefs, 0, int64(blockRefsSize(parentBlockRefs)), d.options.VerifyBlocks)
+	bufioReader := bufio.NewReader(reader)
+	var result []*pfs.BlockRef
+	var pending bytes.Buffer
+	var offset uint64
+	// [sharedFrom, offset) is the run of unchanged content we're in
+	sharedFrom := offset
+	flushShared := func() {
+		result = append(result, sliceBlockRefs(parentBlockRefs, sharedFrom, offset)...)
+		sharedFrom = offset
+	}
+	flushPending := func() error {
+		if pending.Len() == 0 {
+			return nil
+		}
+		blockRefs, err := _client.PutBlock(delimiter, &pending)
+		if err != nil {
+			return err
+		}
+		result = append(result, blockRefs.BlockRef...)
+		pending.Reset()
+		return nil
+	}
+	parentChunk := make([]byte, deltaChunkSize)
+	for {
+		chunk, err := readDeltaChunk(bufioReader, delimiter)
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		if cap(parentChunk) < len(chunk) {
+			parentChunk = make([]byte, len(chunk))
+		}
+		n, err := io.ReadFull(parentReader, parentChunk[:len(chunk)])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		if n == len(chunk) && bytes.Equal(chunk, parentChunk[:n]) {
+			if err := flushPending(); err != nil {
+				return nil, err
+			}
+		} else {
+			flushShared()
+			pending.Write(chunk)
+			if pending.Len() >= deltaMaxPending {
+				if err := flushPending(); err != nil {
+					return nil, err
+				}
+			}
+			sharedFrom = offset + uint64(len(chunk))
+		}
+		offset += uint64(len(chunk))
+	}
+	flushShared()
+	if err := flushPending(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readDeltaChunk reads about deltaChunkSize bytes, line delimited chunks
+// end at the end of a line. It returns an empty chunk once reader is done.
+func readDeltaChunk(reader *bufio.Reader, delimiter pfs.Delimiter) ([]byte, error) {
+	chunk := make([]byte, deltaChunkSize)
+	n, err := io.ReadFull(reader, chunk)
+	chunk = chunk[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return chunk, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if delimiter == pfs.Delimiter_LINE && chunk[n-1] != '\n' {
+		rest, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		chunk = append(chunk, rest...)
+	}
+	return chunk, nil
+}
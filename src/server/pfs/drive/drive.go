@@ -5,6 +5,7 @@ package drive
 
 import (
 	"io"
+	"time"
 
 	"go.pedge.io/pb/go/google/protobuf"
 
@@ -13,29 +14,207 @@ import (
 
 // Driver represents a low-level pfs storage driver.
 type Driver interface {
-	CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, provenance []*pfs.Repo, shards map[uint64]bool) error
-	InspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoInfo, error)
+	CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, provenance []*pfs.Repo, createIfNotExists bool, shards map[uint64]bool) error
+	InspectRepo(repo *pfs.Repo, includeDAG bool, includeBranchHeads bool, shards map[uint64]bool) (*pfs.RepoInfo, error)
 	ListRepo(provenance []*pfs.Repo, shards map[uint64]bool) ([]*pfs.RepoInfo, error)
 	DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error
-	StartCommit(repo *pfs.Repo, commitID string, parentID string, branch string, started *google_protobuf.Timestamp, provenance []*pfs.Commit, shards map[uint64]bool) error
-	FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, cancel bool, shards map[uint64]bool) error
+	StartCommit(repo *pfs.Repo, commitID string, parentID string, branch string, started *google_protobuf.Timestamp,
+		provenance []*pfs.Commit, fromCommit *pfs.Commit, reserved bool, shards map[uint64]bool) error
+	ReserveCommit(commit *pfs.Commit, shards map[uint64]bool) error
+	UnstartCommit(commit *pfs.Commit, shards map[uint64]bool) error
+	FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, cancel bool,
+		options FinishCommitOptions, shards map[uint64]bool) error
 	InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error)
 	ListCommit(repo []*pfs.Repo, commitType pfs.CommitType, fromCommit []*pfs.Commit,
-		provenance []*pfs.Commit, all bool, shards map[uint64]bool) ([]*pfs.CommitInfo, error)
+		provenance []*pfs.Commit, all bool, options ListCommitOptions, shards map[uint64]bool) ([]*pfs.CommitInfo, error)
+	OpenCommits(shards map[uint64]bool) ([]*pfs.CommitInfo, error)
 	ListBranch(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.CommitInfo, error)
+	InspectBranch(repo *pfs.Repo, branch string, shards map[uint64]bool) (*pfs.CommitInfo, error)
+	CommitChangedFiles(commit *pfs.Commit, shards map[uint64]bool) ([]*pfs.FileChange, error)
+	CommitDiff(from *pfs.Commit, to *pfs.Commit, shards map[uint64]bool) ([]*pfs.FileChange, error)
+	CommitManifest(commit *pfs.Commit, shard uint64) ([]*pfs.ManifestEntry, error)
+	PackCommit(commit *pfs.Commit, maxFileSize uint64, shards map[uint64]bool) (uint64, error)
 	DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error
-	PutFile(file *pfs.File, handle string, delimiter pfs.Delimiter, shard uint64, reader io.Reader) error
+	SoftDeleteCommit(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error
+	RestoreCommit(commit *pfs.Commit, shards map[uint64]bool) error
+	PutFile(file *pfs.File, handle string, delimiter pfs.Delimiter, options PutFileOptions, shard uint64, reader io.Reader) error
+	PutFileAt(file *pfs.File, offset uint64, expectedHash string, expires *google_protobuf.Timestamp,
+		xattrs map[string]string, shard uint64, reader io.Reader) error
+	StageBlob(handle string, delimiter pfs.Delimiter, expires time.Time, shard uint64, reader io.Reader) (uint64, error)
+	StagedBlob(handle string, shard uint64) ([]*pfs.BlockRef, error)
+	PutFileBlockRefs(file *pfs.File, blockRefs []*pfs.BlockRef, shard uint64) error
+	SetXattr(file *pfs.File, name string, value string, shard uint64) error
+	FileCompareAndSwap(file *pfs.File, expectedHash string, value []byte, shard uint64) (bool, string, error)
+	SetImmutable(file *pfs.File, shard uint64) error
+	CheckMutable(file *pfs.File, shard uint64) error
 	MakeDirectory(file *pfs.File, shard uint64) error
 	GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64,
 		size int64, from *pfs.Commit, shard uint64, unsafe bool, handle string) (io.ReadCloser, error)
+	GetFileMerged(file *pfs.File, mergeCommits []*pfs.Commit, filterShard *pfs.Shard, offset int64,
+		size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error)
+	GetFileDiff(file *pfs.File, filterShard *pfs.Shard, offset int64,
+		size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error)
+	FileType(file *pfs.File, shard uint64, unsafe bool) (pfs.FileType, error)
 	InspectFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, unsafe bool, handle string) (*pfs.FileInfo, error)
 	ListFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error)
+	FilesExist(files []*pfs.File, shard uint64, unsafe bool) ([]bool, error)
+	ListFileUnion(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error)
+	ListFileWithDeleted(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error)
 	DeleteFile(file *pfs.File, shard uint64, unsafe bool, handle string) error
+	DeleteFiles(files []*pfs.File, shard uint64, unsafe bool, handle string) []error
 	AddShard(shard uint64) error
 	DeleteShard(shard uint64) error
+	DumpShard(shard uint64, commit *pfs.Commit) ([]*pfs.DiffInfo, error)
+	ShardStats(shard uint64) (*pfs.ShardStat, error)
+	BlockReferences(block *pfs.Block, shards map[uint64]bool) ([]*pfs.File, error)
 	Dump()
+	// OnStaleCommitCancelled sets a function that's called with each commit
+	// that the driver cancels because it was open for longer than
+	// OpenCommitTimeout, along with the shards it was cancelled in. It
+	// replaces the function from any previous call.
+	OnStaleCommitCancelled(f func(commit *pfs.Commit, shards map[uint64]bool))
+	// Close stops the driver's background sweepers, the driver can still be
+	// used after it's closed.
+	Close() error
+}
+
+// Options are optional settings for a Driver, the zero value gives the
+// default behavior.
+type Options struct {
+	// MaxUnflushedBytes is the number of bytes that may be written to the
+	// open commits in a single shard before PutFile starts rejecting writes
+	// to that shard. The bytes are flushed when the commits are finished.
+	// 0 means no limit.
+	MaxUnflushedBytes uint64
+	// DeletedCommitRetention is how long soft deleted commits are kept
+	// around (and can be restored) before they're garbage collected.
+	// Deleted commits are collected every DeletedCommitRetention so a
+	// commit is collected between one and two retentions after it was
+	// deleted.
+	// 0 means soft deleted commits are never garbage collected.
+	DeletedCommitRetention time.Duration
+	// ExpiredFileSweepInterval is how often files whose TTL is up are
+	// removed from finished commits. Expired files are hidden whether or
+	// not they've been swept, sweeping reclaims their space.
+	// 0 means expired files are never swept.
+	ExpiredFileSweepInterval time.Duration
+	// FlushInterval is how often writes to open commits are flushed to the
+	// block server, it bounds how much of an open commit is lost if the
+	// server crashes before the commit is finished.
+	// 0 means open commits are only flushed when they're finished.
+	FlushInterval time.Duration
+	// MaxOpenCommitsPerRepo is the number of commits that may be open in a
+	// repo at once, StartCommit fails once a repo has this many.
+	// 0 means no limit.
+	MaxOpenCommitsPerRepo uint64
+	// OpenCommitTimeout is how long a commit may stay open before it's
+	// cancelled, as though CancelCommit had been called on it. Open commits
+	// are checked every OpenCommitTimeout so a commit is cancelled between
+	// one and two timeouts after it was started.
+	// 0 means open commits are never cancelled.
+	OpenCommitTimeout time.Duration
+	// CommitReservationTimeout is how long a commit ID reserved with
+	// ReserveCommit stays reserved if it isn't started.
+	// 0 means reservations don't expire.
+	CommitReservationTimeout time.Duration
+	// VerifyDiffs makes AddShard check every diff it loads against the
+	// checksum it was persisted with, AddShard fails with ErrDivergentData
+	// if one doesn't match. Diffs persisted without a checksum aren't
+	// checked.
+	VerifyDiffs bool
+	// CaseInsensitiveRepoNames makes CreateRepo reject a repo whose name
+	// differs from an existing repo's only in case, e.g. Foo and foo. This
+	// is useful when repos are stored on a case insensitive backend.
+	CaseInsensitiveRepoNames bool
+	// InlineFileSize is the size of the largest put that PutFile stores in
+	// the diff itself rather than in a block, which saves a round trip to
+	// the block server when the file is read.
+	// 0 means content is never inlined.
+	InlineFileSize uint64
+	// MaxShardOperations is the number of file reads and writes that may
+	// run on a single shard at once, the rest wait and are run in the order
+	// given by ShardSchedulePolicy. A read holds on to its slot until its
+	// reader is closed.
+	// 0 means no limit, in which case ShardSchedulePolicy has no effect.
+	MaxShardOperations int
+	// ShardSchedulePolicy decides which waiting operation runs next on a
+	// shard that's at MaxShardOperations.
+	ShardSchedulePolicy ShardSchedulePolicy
+	// VerifyBlocks makes reads check each block they read against its hash,
+	// a read fails with ErrBlockCorrupt if one doesn't match. Blocks have to
+	// be read whole to be checked so a small read of a big block costs a
+	// lot more with this set.
+	VerifyBlocks bool
+	// ScrubInterval is how often every block referenced by the driver's
+	// shards is read back and checked against its hash, ShardStats reports
+	// the corrupt, missing and unreadable blocks the last scrub found.
+	// 0 means blocks are never scrubbed.
+	ScrubInterval time.Duration
+	// ScrubBytesPerSecond limits how fast a scrub reads blocks so that it
+	// doesn't compete with reads, 0 means no limit.
+	ScrubBytesPerSecond uint64
+	// DeltaPuts makes a put to a file that's been deleted in its commit
+	// compare the new content with the parent commit's version, unchanged
+	// parts refer to the parent's blocks so only the changes are stored.
+	// The parent's version has to be read to compare it, so these puts cost
+	// more reads.
+	DeltaPuts bool
+}
+
+// FinishCommitOptions are optional settings for FinishCommit, the zero value
+// gives the default behavior.
+type FinishCommitOptions struct {
+	// Description is a human readable description of the commit.
+	Description string
+	// Annotations are key/value pairs describing the commit.
+	Annotations map[string]string
+	// ExpectedParent, if set, makes the finish fail with a conflict unless
+	// the commit's parent is ExpectedParent and the commit is still the head
+	// of its branch.
+	ExpectedParent *pfs.Commit
+}
+
+// ListCommitOptions are optional settings for ListCommit, the zero value
+// gives the default behavior.
+type ListCommitOptions struct {
+	// IncludeDeleted causes soft deleted commits to be returned.
+	IncludeDeleted bool
+	// Annotations, if set, restricts the results to commits that have all of
+	// these annotations.
+	Annotations map[string]string
+	// Branch, if set, restricts the results to commits reachable from the
+	// head of Branch.
+	Branch string
+	// BaseBranch, if set, excludes commits reachable from the head of
+	// BaseBranch.
+	BaseBranch string
+}
+
+// PutFileOptions are optional settings for PutFile, the zero value gives
+// the default behavior.
+type PutFileOptions struct {
+	// IdempotencyKey identifies the put, if a put with the same key has
+	// already been applied to the same path in the same open commit the put
+	// is ignored.
+	IdempotencyKey string
+	// ExpectedHash is the hex encoded SHA-256 of the data being put, if it's
+	// set and doesn't match the data the put fails and isn't applied.
+	ExpectedHash string
+	// ContentType, if set, makes the put fail unless the data is valid for
+	// the type, see RegisterContentValidator.
+	ContentType string
+	// Expires is when the data expires, nil means it doesn't.
+	Expires *google_protobuf.Timestamp
+	// Xattrs are extended attributes to set on the file along with the put.
+	Xattrs map[string]string
 }
 
 func NewDriver(blockAddress string) (Driver, error) {
-	return newDriver(blockAddress)
+	return newDriver(blockAddress, Options{})
+}
+
+// NewDriverWithOptions is like NewDriver except it lets you configure the
+// driver.
+func NewDriverWithOptions(blockAddress string, options Options) (Driver, error) {
+	return newDriver(blockAddress, options)
 }
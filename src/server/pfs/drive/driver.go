@@ -1,45 +1,130 @@
 package drive
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"path"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/golang/protobuf/proto"
 	"github.com/pachyderm/pachyderm/src/client"
 	"github.com/pachyderm/pachyderm/src/client/pfs"
 	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/dag"
 	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
+	"go.pedge.io/lion/proto"
 	"go.pedge.io/pb/go/google/protobuf"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+)
+
+var (
+	grpcErrorf = grpc.Errorf // needed to get passed govet
 )
 
 type driver struct {
 	blockAddress    string
 	blockClient     pfs.BlockAPIClient
 	blockClientOnce sync.Once
+	options         Options
 	diffs           diffMap
 	dags            map[string]*dag.DAG
 	branches        map[string]map[string]string
 	lock            sync.RWMutex
 	// used for signaling the completion (i.e. finishing) of a commit
 	commitConds map[string]*sync.Cond
+	// finishing is the set of commits that FinishCommit is in progress for,
+	// it's used to make sure that only one FinishCommit proceeds per commit
+	finishing map[string]bool
+	// unflushedBytes is the number of bytes written to open commits, by shard
+	unflushedBytes map[uint64]uint64
+	// dirtyDiffs is the set of open commits' diffs that have been written to
+	// since they were last flushed
+	dirtyDiffs map[*pfs.DiffInfo]bool
+	// flushLock is held for writing while open commits are flushed, and for
+	// reading while FinishCommit and DeleteRepo write out the diffs they
+	// change, so that a flush can't land after them. It's acquired after
+	// the scheduler and before lock.
+	flushLock sync.RWMutex
+	// stagedBlobs is the blobs that have been staged with StageBlob, by
+	// shard and then handle
+	stagedBlobs map[uint64]map[string]*stagedBlob
+	// openCommits is the set of open commits, by repo, so that StartCommit
+	// can check MaxOpenCommitsPerRepo without scanning the diffs
+	openCommits map[string]map[string]bool
+	// reservedCommits is the set of commit IDs that have been reserved with
+	// ReserveCommit but not yet started, keyed like appliedPuts, mapped to
+	// when the reservation expires. The zero time means it doesn't expire.
+	reservedCommits map[string]time.Time
+	// scheduler decides the order in which reads and writes to a shard run,
+	// it's acquired before lock
+	scheduler *shardScheduler
+	// corruptBlocks is the set of blocks that the last scrub found don't
+	// match their hash or couldn't read, by shard, it's protected by scrubLock rather than
+	// lock
+	corruptBlocks map[uint64]map[string]bool
+	scrubLock     sync.Mutex
+	// blockIndex is protected by lock
+	blockIndex blockIndex
+	// staleCommitCancelled is set by OnStaleCommitCancelled, it's protected
+	// by lock
+	staleCommitCancelled func(commit *pfs.Commit, shards map[uint64]bool)
+	// closed is closed by Close to stop the background sweepers
+	closed    chan struct{}
+	closeOnce sync.Once
 }
 
-func newDriver(blockAddress string) (Driver, error) {
-	return &driver{
+func newDriver(blockAddress string, options Options) (Driver, error) {
+	d := &driver{
 		blockAddress:    blockAddress,
 		blockClient:     nil,
 		blockClientOnce: sync.Once{},
+		options:         options,
 		diffs:           make(diffMap),
 		dags:            make(map[string]*dag.DAG),
 		branches:        make(map[string]map[string]string),
 		lock:            sync.RWMutex{},
 		commitConds:     make(map[string]*sync.Cond),
-	}, nil
+		finishing:       make(map[string]bool),
+		unflushedBytes:  make(map[uint64]uint64),
+		dirtyDiffs:      make(map[*pfs.DiffInfo]bool),
+		stagedBlobs:     make(map[uint64]map[string]*stagedBlob),
+		openCommits:     make(map[string]map[string]bool),
+		reservedCommits: make(map[string]time.Time),
+		scheduler:       newShardScheduler(options.MaxShardOperations, options.ShardSchedulePolicy),
+		corruptBlocks:   make(map[uint64]map[string]bool),
+		closed:          make(chan struct{}),
+	}
+	if options.DeletedCommitRetention > 0 {
+		go d.collectDeletedCommitsForever()
+	}
+	if options.ExpiredFileSweepInterval > 0 {
+		go d.sweepExpiredFilesForever()
+	}
+	if options.FlushInterval > 0 {
+		go d.flushOpenCommitsForever()
+	}
+	if options.OpenCommitTimeout > 0 {
+		go d.cancelStaleCommitsForever()
+	}
+	if options.ScrubInterval > 0 {
+		go d.scrubForever()
+	}
+	go d.sweepExpiredBlobsForever()
+	return d, nil
 }
 
 func (d *driver) getBlockClient() (pfs.BlockAPIClient, error) {
@@ -70,15 +155,28 @@ func validateRepoName(name string) error {
 }
 
 func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp,
-	provenance []*pfs.Repo, shards map[uint64]bool) error {
+	provenance []*pfs.Repo, createIfNotExists bool, shards map[uint64]bool) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	if _, ok := d.diffs[repo.Name]; ok {
-		return fmt.Errorf("repo %s exists", repo.Name)
+		if !createIfNotExists {
+			return fmt.Errorf("repo %s exists", repo.Name)
+		}
+		if !d.repoProvenanceEqual(repo, provenance, shards) {
+			return grpcErrorf(codes.AlreadyExists, "repo %s exists with different provenance", repo.Name)
+		}
+		return nil
 	}
 	if err := validateRepoName(repo.Name); err != nil {
 		return err
 	}
+	if d.options.CaseInsensitiveRepoNames {
+		for repoName := range d.diffs {
+			if strings.EqualFold(repoName, repo.Name) {
+				return fmt.Errorf("repo %s collides with existing repo %s", repo.Name, repoName)
+			}
+		}
+	}
 	for _, provRepo := range provenance {
 		if _, err := d.inspectRepo(provRepo, shards); err != nil {
 			return nil
@@ -107,7 +205,7 @@ func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp,
 		}
 		go func() {
 			defer wg.Done()
-			if _, err := blockClient.CreateDiff(context.Background(), diffInfo); err != nil {
+			if err := createDiff(blockClient, diffInfo); err != nil {
 				select {
 				case errCh <- err:
 				default:
@@ -125,10 +223,56 @@ func (d *driver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp,
 	return nil
 }
 
-func (d *driver) InspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoInfo, error) {
+func (d *driver) InspectRepo(repo *pfs.Repo, includeDAG bool, includeBranchHeads bool, shards map[uint64]bool) (*pfs.RepoInfo, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
-	return d.inspectRepo(repo, shards)
+	repoInfo, err := d.inspectRepo(repo, shards)
+	if err != nil {
+		return nil, err
+	}
+	if includeDAG {
+		repoInfo.Dag = d.commitNodes(repo, shards)
+	}
+	if includeBranchHeads {
+		// the heads are read under the same lock so none of them can move
+		// while we're reading the others
+		for branch, commitID := range d.branches[repo.Name] {
+			commitInfo, err := d.inspectCommit(client.NewCommit(repo.Name, commitID), shards)
+			if err != nil {
+				return nil, err
+			}
+			if repoInfo.BranchHeads == nil {
+				repoInfo.BranchHeads = make(map[string]*pfs.CommitInfo)
+			}
+			repoInfo.BranchHeads[branch] = commitInfo
+		}
+	}
+	return repoInfo, nil
+}
+
+// commitNodes returns a node for each of repo's commits in shards, in no
+// particular order. Every commit has a diff in every shard so each commit
+// appears once no matter how many shards we have.
+// commitNodes assumes that the lock is being held
+func (d *driver) commitNodes(repo *pfs.Repo, shards map[uint64]bool) []*pfs.CommitNode {
+	seen := make(map[string]bool)
+	var result []*pfs.CommitNode
+	for shard := range shards {
+		for commitID, diffInfo := range d.diffs[repo.Name][shard] {
+			if commitID == "" || seen[commitID] {
+				continue
+			}
+			seen[commitID] = true
+			result = append(result, &pfs.CommitNode{
+				Commit:       diffInfo.Diff.Commit,
+				ParentCommit: diffInfo.ParentCommit,
+				Branch:       diffInfo.Branch,
+				Started:      diffInfo.Started,
+				Deleted:      diffInfo.Deleted,
+			})
+		}
+	}
+	return result
 }
 
 func (d *driver) ListRepo(provenance []*pfs.Repo, shards map[uint64]bool) ([]*pfs.RepoInfo, error) {
@@ -197,15 +341,28 @@ func (d *driver) DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error {
 		for shard := range shards {
 			for _, diffInfo := range d.diffs[repo.Name][shard] {
 				diffInfos = append(diffInfos, diffInfo)
+				if diffInfo.Finished == nil {
+					d.releaseUnflushedBytes(shard, diffInfo.SizeBytes)
+				}
+				delete(d.dirtyDiffs, diffInfo)
 			}
 		}
 		delete(d.diffs, repo.Name)
+		delete(d.openCommits, repo.Name)
+		d.blockIndex.unindexRepo(repo.Name)
+		for commitKey := range d.reservedCommits {
+			if path.Dir(commitKey) == repo.Name {
+				delete(d.reservedCommits, commitKey)
+			}
+		}
 		return nil
 	}()
 	if err != nil {
 		return err
 	}
 
+	d.flushLock.RLock()
+	defer d.flushLock.RUnlock()
 	blockClient, err := d.getBlockClient()
 	if err != nil {
 		return err
@@ -239,10 +396,25 @@ func (d *driver) DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error {
 }
 
 func (d *driver) StartCommit(repo *pfs.Repo, commitID string, parentID string, branch string,
-	started *google_protobuf.Timestamp, provenance []*pfs.Commit, shards map[uint64]bool) error {
+	started *google_protobuf.Timestamp, provenance []*pfs.Commit, fromCommit *pfs.Commit, reserved bool, shards map[uint64]bool) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	commitKey := path.Join(repo.Name, commitID)
+	if reserved {
+		expires, ok := d.reservedCommits[commitKey]
+		if !ok {
+			return fmt.Errorf("commit %s/%s has not been reserved, or has already been started", repo.Name, commitID)
+		}
+		if reservationExpired(expires, time.Now()) {
+			delete(d.reservedCommits, commitKey)
+			return fmt.Errorf("the reservation for commit %s/%s has expired", repo.Name, commitID)
+		}
+	}
+	if err := d.checkOpenCommits(repo); err != nil {
+		return err
+	}
+
 	// make sure that the parent commit exists
 	if parentID != "" {
 		_, err := d.inspectCommit(client.NewCommit(repo.Name, parentID), shards)
@@ -250,6 +422,45 @@ func (d *driver) StartCommit(repo *pfs.Repo, commitID string, parentID string, b
 			return err
 		}
 	}
+	if fromCommit != nil {
+		fromCommitInfo, err := d.inspectCommit(fromCommit, shards)
+		if err != nil {
+			return err
+		}
+		if fromCommitInfo.CommitType != pfs.CommitType_COMMIT_TYPE_READ {
+			return fmt.Errorf("cannot start a commit from %s/%s because it's not finished", fromCommit.Repo.Name, fromCommit.ID)
+		}
+	}
+
+	// the head of branch is resolved before any of the commit's diffs are
+	// inserted, afterwards branch resolves to the commit itself
+	var branchHead *pfs.Commit
+	if branch != "" {
+		var err error
+		if branchHead, err = d.branchParent(client.NewCommit(repo.Name, commitID), branch); err != nil {
+			return err
+		}
+		if branchHead != nil && parentID != "" {
+			return fmt.Errorf("branch %s already exists as %s, can't create with %s as parent",
+				branch, branchHead.ID, parentID)
+		}
+	}
+	if fromCommit != nil {
+		// seeding replaces the parent's files, immutable files can't be
+		// replaced
+		parentCommit := branchHead
+		if parentCommit == nil && parentID != "" {
+			parentCommit = client.NewCommit(repo.Name, parentID)
+		}
+		if parentCommit != nil {
+			for shard := range shards {
+				if err := d.checkMutable(client.NewFile(repo.Name, parentCommit.ID, "."), shard, true); err != nil {
+					return fmt.Errorf("cannot start a commit from %s/%s on top of %s/%s: %s",
+						fromCommit.Repo.Name, fromCommit.ID, repo.Name, parentCommit.ID, err.Error())
+				}
+			}
+		}
+	}
 
 	for shard := range shards {
 		if len(provenance) != 0 {
@@ -266,42 +477,138 @@ func (d *driver) StartCommit(repo *pfs.Repo, commitID string, parentID string, b
 			}
 		}
 		diffInfo := &pfs.DiffInfo{
-			Diff:       client.NewDiff(repo.Name, commitID, shard),
-			Started:    started,
-			Appends:    make(map[string]*pfs.Append),
-			Branch:     branch,
-			Provenance: provenance,
-		}
-		if branch != "" {
-			parentCommit, err := d.branchParent(client.NewCommit(repo.Name, commitID), branch)
-			if err != nil {
-				return err
-			}
-			if parentCommit != nil && parentID != "" {
-				return fmt.Errorf("branch %s already exists as %s, can't create with %s as parent",
-					branch, parentCommit.ID, parentID)
-			}
-			diffInfo.ParentCommit = parentCommit
+			Diff:         client.NewDiff(repo.Name, commitID, shard),
+			Started:      started,
+			Appends:      make(map[string]*pfs.Append),
+			Branch:       branch,
+			Provenance:   provenance,
+			ParentCommit: branchHead,
 		}
 		if diffInfo.ParentCommit == nil && parentID != "" {
 			diffInfo.ParentCommit = client.NewCommit(repo.Name, parentID)
 		}
+		if diffInfo.ParentCommit != nil {
+			parentDiffInfo, ok := d.diffs.get(client.NewDiff(repo.Name, diffInfo.ParentCommit.ID, shard))
+			if ok && parentDiffInfo.Deleted != nil {
+				return fmt.Errorf("parent commit %s/%s has been deleted", repo.Name, diffInfo.ParentCommit.ID)
+			}
+		}
+		if fromCommit != nil {
+			if err := d.seedDiffInfo(diffInfo, fromCommit, shard); err != nil {
+				return err
+			}
+		}
 		if err := d.insertDiffInfo(diffInfo); err != nil {
 			return err
 		}
+		d.dirtyDiffs[diffInfo] = true
 	}
 	d.commitConds[commitID] = sync.NewCond(&d.lock)
+	delete(d.reservedCommits, commitKey)
+	return nil
+}
+
+// ReserveCommit sets aside commit.ID so that it can be passed to StartCommit
+// later. Reservations are only held in memory, they don't survive a restart
+// and they don't move with the shards when the shards are moved to another
+// server. They expire after Options.CommitReservationTimeout.
+func (d *driver) ReserveCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := time.Now()
+	for commitKey, expires := range d.reservedCommits {
+		if reservationExpired(expires, now) {
+			delete(d.reservedCommits, commitKey)
+		}
+	}
+	commitKey := path.Join(commit.Repo.Name, commit.ID)
+	if _, ok := d.reservedCommits[commitKey]; ok {
+		return fmt.Errorf("commit %s/%s has already been reserved", commit.Repo.Name, commit.ID)
+	}
+	for shard := range shards {
+		if _, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, "", shard)); !ok {
+			return pfsserver.NewErrRepoNotFound(commit.Repo.Name)
+		}
+		if _, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard)); ok {
+			return fmt.Errorf("commit %s/%s already exists", commit.Repo.Name, commit.ID)
+		}
+	}
+	d.reservedCommits[commitKey] = d.reservationExpiry(now)
+	return nil
+}
+
+// UnstartCommit undoes a StartCommit of a reserved commit, the commit is
+// removed and its ID is reserved again. It's used to clean up after a
+// StartCommit that failed on some servers, writes that were made to the
+// commit in the meantime are lost.
+func (d *driver) UnstartCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	// held so that a flush can't persist the diffs after they're deleted
+	d.flushLock.RLock()
+	defer d.flushLock.RUnlock()
+	var diffs []*pfs.Diff
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		var diffInfos []*pfs.DiffInfo
+		for shard := range shards {
+			diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+			if !ok {
+				return pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+			}
+			if diffInfo.Finished != nil {
+				return pfsserver.NewErrCommitFinished(commit.Repo.Name, commit.ID)
+			}
+			diffInfos = append(diffInfos, diffInfo)
+		}
+		for _, diffInfo := range diffInfos {
+			d.releaseUnflushedBytes(diffInfo.Diff.Shard, diffInfo.SizeBytes)
+			delete(d.dirtyDiffs, diffInfo)
+		}
+		diffs = d.removeCommit(commit.Repo.Name, commit.ID)
+		commitKey := path.Join(commit.Repo.Name, commit.ID)
+		delete(d.commitConds, commit.ID)
+		d.reservedCommits[commitKey] = d.reservationExpiry(time.Now())
+		return nil
+	}(); err != nil {
+		return err
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	for _, diff := range diffs {
+		if _, err := blockClient.DeleteDiff(context.Background(), &pfs.DeleteDiffRequest{Diff: diff}); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// reservationExpiry returns when a reservation made at now expires.
+func (d *driver) reservationExpiry(now time.Time) time.Time {
+	if d.options.CommitReservationTimeout == 0 {
+		return time.Time{}
+	}
+	return now.Add(d.options.CommitReservationTimeout)
+}
+
+func reservationExpired(expires time.Time, now time.Time) bool {
+	return !expires.IsZero() && now.After(expires)
+}
+
 // FinishCommit blocks until its parent has been finished/cancelled
-func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, cancel bool, shards map[uint64]bool) error {
+func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, cancel bool,
+	options FinishCommitOptions, shards map[uint64]bool) error {
 	canonicalCommit, err := d.canonicalCommit(commit)
 	if err != nil {
 		return err
 	}
-	// closure so we can defer Unlock
-	var diffInfos []*pfs.DiffInfo
+	if err := d.startFinishing(canonicalCommit, shards); err != nil {
+		return err
+	}
+	defer d.stopFinishing(canonicalCommit)
+	// Wait for the parent to finish before the shards are scheduled, holding
+	// on to them while waiting would keep the parent from finishing.
 	if err := func() error {
 		d.lock.Lock()
 		defer d.lock.Unlock()
@@ -315,7 +622,6 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 				if !ok {
 					return pfsserver.NewErrParentCommitNotFound(canonicalCommit.Repo.Name, diffInfo.ParentCommit.ID)
 				}
-				// Wait for parent to finish
 				for parentDiffInfo.Finished == nil {
 					cond, ok := d.commitConds[diffInfo.ParentCommit.ID]
 					if !ok {
@@ -323,10 +629,49 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 					}
 					cond.Wait()
 				}
-
+			}
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	release := d.scheduler.acquireAll(shards, shardWrite)
+	defer release()
+	// closure so we can defer Unlock
+	var diffInfos []*pfs.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		if options.ExpectedParent != nil {
+			// checked before any shard is modified so a conflict leaves the
+			// commit open
+			if err := d.checkExpectedParent(canonicalCommit, options.ExpectedParent, shards); err != nil {
+				return err
+			}
+		}
+		for shard := range shards {
+			diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+			if !ok {
+				return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+			}
+			if diffInfo.ParentCommit != nil {
+				parentDiffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, diffInfo.ParentCommit.ID, shard))
+				if !ok || parentDiffInfo.Finished == nil {
+					return pfsserver.NewErrParentCommitNotFound(canonicalCommit.Repo.Name, diffInfo.ParentCommit.ID)
+				}
 				diffInfo.Cancelled = parentDiffInfo.Cancelled
 			}
+			if diffInfo.Finished == nil {
+				d.releaseUnflushedBytes(shard, diffInfo.SizeBytes)
+			}
+			// we persist the finished diff ourselves below
+			delete(d.dirtyDiffs, diffInfo)
+			delete(d.openCommits[canonicalCommit.Repo.Name], canonicalCommit.ID)
 			diffInfo.Finished = finished
+			diffInfo.Description = options.Description
+			diffInfo.Annotations = options.Annotations
+			// idempotency keys only live as long as the commit is open
+			diffInfo.IdempotencyKeys = nil
 			for _, _append := range diffInfo.Appends {
 				coalesceHandles(_append)
 			}
@@ -337,32 +682,12 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 	}(); err != nil {
 		return err
 	}
-	blockClient, err := d.getBlockClient()
+	d.flushLock.RLock()
+	err = d.persistDiffInfos(diffInfos)
+	d.flushLock.RUnlock()
 	if err != nil {
 		return err
 	}
-	var wg sync.WaitGroup
-	errCh := make(chan error, 1)
-	for _, diffInfo := range diffInfos {
-		diffInfo := diffInfo
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if _, err := blockClient.CreateDiff(context.Background(), diffInfo); err != nil {
-				select {
-				case errCh <- err:
-				default:
-				}
-				return
-			}
-		}()
-	}
-	wg.Wait()
-	select {
-	case err := <-errCh:
-		return err
-	default:
-	}
 
 	d.lock.Lock()
 	defer d.lock.Unlock()
@@ -376,6 +701,125 @@ func (d *driver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Time
 	return nil
 }
 
+// seedDiffInfo makes diffInfo start out with the files in from rather than
+// the files in its parent. from's files are referenced, not copied, each of
+// them gets an append that refers to its blocks and ignores whatever came
+// before it, and the parent's files that aren't in from are deleted. Files
+// that are immutable in from stay immutable, StartCommit has already checked
+// that the parent has no immutable files to replace.
+// seedDiffInfo assumes that the lock is being held
+func (d *driver) seedDiffInfo(diffInfo *pfs.DiffInfo, from *pfs.Commit, shard uint64) error {
+	seen := make(map[string]bool)
+	if err := d.walkFiles(client.NewFile(from.Repo.Name, from.ID, "."), shard,
+		func(fileInfo *pfs.FileInfo, blockRefs []*pfs.BlockRef) {
+			filePath := path.Clean(fileInfo.File.Path)
+			seen[filePath] = true
+			_append := newAppend(fileInfo.FileType)
+			_append.Delete = true
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				_append.Children = make(map[string]bool)
+				for _, child := range fileInfo.Children {
+					_append.Children[path.Clean(child.Path)] = true
+				}
+			} else {
+				_append.BlockRefs = blockRefs
+				_append.Xattrs = fileInfo.Xattrs
+				_append.Immutable = fileInfo.Immutable
+			}
+			diffInfo.Appends[filePath] = _append
+		}); err != nil {
+		return err
+	}
+	if diffInfo.ParentCommit == nil {
+		return nil
+	}
+	return d.walkFiles(client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, "."), shard,
+		func(fileInfo *pfs.FileInfo, blockRefs []*pfs.BlockRef) {
+			filePath := path.Clean(fileInfo.File.Path)
+			if !seen[filePath] {
+				_append := newAppend(pfs.FileType_FILE_TYPE_NONE)
+				_append.Delete = true
+				diffInfo.Appends[filePath] = _append
+			}
+		})
+}
+
+// walkFiles calls f with file, and if it's a directory with everything
+// beneath it, as of file.Commit.
+// walkFiles assumes that the lock is being held
+func (d *driver) walkFiles(file *pfs.File, shard uint64, f func(*pfs.FileInfo, []*pfs.BlockRef)) error {
+	fileInfo, blockRefs, err := d.inspectFile(file, nil, shard, nil, false, false, "")
+	if _, ok := err.(*pfsserver.ErrFileNotFound); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	f(fileInfo, blockRefs)
+	for _, child := range fileInfo.Children {
+		if err := d.walkFiles(client.NewFile(file.Commit.Repo.Name, file.Commit.ID, child.Path), shard, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startFinishing marks commit as being finished, it returns an error if the
+// commit is already finished or another FinishCommit is in progress for it.
+// Callers must call stopFinishing once they're done.
+func (d *driver) startFinishing(commit *pfs.Commit, shards map[uint64]bool) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	key := path.Join(commit.Repo.Name, commit.ID)
+	if d.finishing[key] {
+		return fmt.Errorf("commit %s/%s is already being finished", commit.Repo.Name, commit.ID)
+	}
+	for shard := range shards {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		if diffInfo.Finished != nil {
+			return pfsserver.NewErrCommitFinished(commit.Repo.Name, commit.ID)
+		}
+	}
+	d.finishing[key] = true
+	return nil
+}
+
+func (d *driver) stopFinishing(commit *pfs.Commit) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	delete(d.finishing, path.Join(commit.Repo.Name, commit.ID))
+}
+
+// checkExpectedParent returns a conflict error unless commit's parent is
+// expectedParent and commit is still the head of its branch. expectedParent
+// may be a branch name. The caller must hold d.lock.
+func (d *driver) checkExpectedParent(commit *pfs.Commit, expectedParent *pfs.Commit, shards map[uint64]bool) error {
+	expectedParent, err := d.canonicalCommit(expectedParent)
+	if err != nil {
+		return err
+	}
+	for shard := range shards {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		if diffInfo.ParentCommit == nil || diffInfo.ParentCommit.ID != expectedParent.ID {
+			return grpcErrorf(codes.Aborted, "conflict: commit %s/%s does not have %s as its parent",
+				commit.Repo.Name, commit.ID, expectedParent.ID)
+		}
+		if diffInfo.Branch != "" && d.branches[commit.Repo.Name][diffInfo.Branch] != commit.ID {
+			return grpcErrorf(codes.Aborted, "conflict: branch %s has moved to %s since commit %s/%s was started",
+				diffInfo.Branch, d.branches[commit.Repo.Name][diffInfo.Branch], commit.Repo.Name, commit.ID)
+		}
+		// every shard of a commit has the same parent and branch
+		return nil
+	}
+	return nil
+}
+
 func (d *driver) InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs.CommitInfo, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
@@ -383,7 +827,7 @@ func (d *driver) InspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs
 }
 
 func (d *driver) ListCommit(repos []*pfs.Repo, commitType pfs.CommitType, fromCommit []*pfs.Commit,
-	provenance []*pfs.Commit, all bool, shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
+	provenance []*pfs.Commit, all bool, options ListCommitOptions, shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
 	repoSet := repoSet(repos)
 	var canonicalProvenance []*pfs.Commit
 	for _, provCommit := range provenance {
@@ -408,7 +852,26 @@ func (d *driver) ListCommit(repos []*pfs.Repo, commitType pfs.CommitType, fromCo
 		if !ok {
 			return nil, pfsserver.NewErrRepoNotFound(repo.Name)
 		}
-		for _, commitID := range d.dags[repo.Name].Leaves() {
+		heads := d.dags[repo.Name].Leaves()
+		if options.Branch != "" {
+			commitID, ok := d.branches[repo.Name][options.Branch]
+			if !ok {
+				return nil, pfsserver.NewErrBranchNotFound(repo.Name, options.Branch)
+			}
+			heads = []string{commitID}
+		}
+		if options.BaseBranch != "" {
+			commitID, ok := d.branches[repo.Name][options.BaseBranch]
+			if !ok {
+				return nil, pfsserver.NewErrBranchNotFound(repo.Name, options.BaseBranch)
+			}
+			// commits reachable from BaseBranch are treated like fromCommits
+			// so the walks below stop when they reach them
+			if err := d.addAncestors(client.NewCommit(repo.Name, commitID), breakCommitIDs, shards); err != nil {
+				return nil, err
+			}
+		}
+		for _, commitID := range heads {
 			commit := &pfs.Commit{
 				Repo: repo,
 				ID:   commitID,
@@ -424,9 +887,15 @@ func (d *driver) ListCommit(repos []*pfs.Repo, commitType pfs.CommitType, fromCo
 				if commitInfo.Cancelled && !all {
 					continue
 				}
+				if commitInfo.Deleted != nil && !options.IncludeDeleted {
+					continue
+				}
 				if !MatchProvenance(canonicalProvenance, commitInfo.Provenance) {
 					continue
 				}
+				if !MatchAnnotations(options.Annotations, commitInfo.Annotations) {
+					continue
+				}
 				if commitType != pfs.CommitType_COMMIT_TYPE_NONE &&
 					commitType != commitInfo.CommitType {
 					continue
@@ -438,6 +907,19 @@ func (d *driver) ListCommit(repos []*pfs.Repo, commitType pfs.CommitType, fromCo
 	return result, nil
 }
 
+// addAncestors adds commit and all of its ancestors to commitIDs.
+func (d *driver) addAncestors(commit *pfs.Commit, commitIDs map[string]bool, shards map[uint64]bool) error {
+	for commit != nil && !commitIDs[commit.ID] {
+		commitIDs[commit.ID] = true
+		commitInfo, err := d.inspectCommit(commit, shards)
+		if err != nil {
+			return err
+		}
+		commit = commitInfo.ParentCommit
+	}
+	return nil
+}
+
 func MatchProvenance(want []*pfs.Commit, have []*pfs.Commit) bool {
 	repoToCommit := make(map[string]*pfs.Commit)
 	for _, haveCommit := range have {
@@ -452,6 +934,17 @@ func MatchProvenance(want []*pfs.Commit, have []*pfs.Commit) bool {
 	return true
 }
 
+// MatchAnnotations returns true if have contains every key/value pair in
+// want.
+func MatchAnnotations(want map[string]string, have map[string]string) bool {
+	for key, value := range want {
+		if haveValue, ok := have[key]; !ok || haveValue != value {
+			return false
+		}
+	}
+	return true
+}
+
 func (d *driver) ListBranch(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
 	var result []*pfs.CommitInfo
 
@@ -470,21 +963,1178 @@ func (d *driver) ListBranch(repo *pfs.Repo, shards map[uint64]bool) ([]*pfs.Comm
 	return result, nil
 }
 
-func (d *driver) DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error {
-	return fmt.Errorf("DeleteCommit is not implemented")
+// InspectBranch returns the info of the commit at the head of branch. The
+// head is looked up and inspected under the same lock so it can't move in
+// between.
+func (d *driver) InspectBranch(repo *pfs.Repo, branch string, shards map[uint64]bool) (*pfs.CommitInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	branches, ok := d.branches[repo.Name]
+	if !ok {
+		return nil, pfsserver.NewErrRepoNotFound(repo.Name)
+	}
+	commitID, ok := branches[branch]
+	if !ok {
+		return nil, pfsserver.NewErrBranchNotFound(repo.Name, branch)
+	}
+	return d.inspectCommit(client.NewCommit(repo.Name, commitID), shards)
 }
 
-func (d *driver) PutFile(file *pfs.File, handle string,
-	delimiter pfs.Delimiter, shard uint64, reader io.Reader) (retErr error) {
-	blockClient, err := d.getBlockClient()
+// CommitChangedFiles returns the regular files that commit added or modified
+// and the paths it deleted, which may be directories. Changes are found by
+// comparing commit's diff on each shard with its parent, a path that was
+// created and deleted within commit isn't returned. The same path may be
+// returned once per shard.
+func (d *driver) CommitChangedFiles(commit *pfs.Commit, shards map[uint64]bool) ([]*pfs.FileChange, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	canonicalCommit, err := d.canonicalCommit(commit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	_client := client.APIClient{BlockAPIClient: blockClient}
-	blockRefs, err := _client.PutBlock(delimiter, reader)
+	var result []*pfs.FileChange
+	for shard := range shards {
+		diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+		if !ok {
+			return nil, pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+		}
+		for filePath, _append := range diffInfo.Appends {
+			if _append.FileType == pfs.FileType_FILE_TYPE_DIR {
+				continue
+			}
+			parentFileType := pfs.FileType_FILE_TYPE_NONE
+			if diffInfo.ParentCommit != nil {
+				parentFileType, err = d.getFileType(client.NewFile(canonicalCommit.Repo.Name, diffInfo.ParentCommit.ID, filePath), shard)
+				if err != nil {
+					return nil, err
+				}
+			}
+			changeType := pfs.ChangeType_CHANGE_TYPE_NONE
+			switch {
+			case _append.FileType == pfs.FileType_FILE_TYPE_NONE && parentFileType != pfs.FileType_FILE_TYPE_NONE:
+				changeType = pfs.ChangeType_CHANGE_TYPE_DELETED
+			case _append.FileType == pfs.FileType_FILE_TYPE_REGULAR && parentFileType == pfs.FileType_FILE_TYPE_REGULAR:
+				changeType = pfs.ChangeType_CHANGE_TYPE_MODIFIED
+			case _append.FileType == pfs.FileType_FILE_TYPE_REGULAR:
+				changeType = pfs.ChangeType_CHANGE_TYPE_ADDED
+			}
+			if changeType == pfs.ChangeType_CHANGE_TYPE_NONE {
+				continue
+			}
+			result = append(result, &pfs.FileChange{
+				File:       client.NewFile(canonicalCommit.Repo.Name, canonicalCommit.ID, filePath),
+				ChangeType: changeType,
+			})
+		}
+	}
+	return result, nil
+}
+
+// CommitDiff returns the regular files in shards that differ between from
+// and to. Files that are only in to are added, files that are only in from
+// are deleted and files in both whose content differs are modified. Each
+// commit's files are compared as a whole, so from and to don't need to share
+// an ancestor. Deleted files are returned in from, the rest in to.
+func (d *driver) CommitDiff(from *pfs.Commit, to *pfs.Commit, shards map[uint64]bool) ([]*pfs.FileChange, error) {
+	var result []*pfs.FileChange
+	// files whose block refs differ but whose content might not, their
+	// content is compared once the lock has been released
+	type candidate struct {
+		path                     string
+		fromBlockRefs, blockRefs []*pfs.BlockRef
+	}
+	var candidates []candidate
+	var canonicalFrom, canonicalTo *pfs.Commit
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		var err error
+		if canonicalFrom, err = d.canonicalCommit(from); err != nil {
+			return err
+		}
+		if canonicalTo, err = d.canonicalCommit(to); err != nil {
+			return err
+		}
+		for shard := range shards {
+			fromFiles, err := d.commitFiles(canonicalFrom, shard)
+			if err != nil {
+				return err
+			}
+			toFiles, err := d.commitFiles(canonicalTo, shard)
+			if err != nil {
+				return err
+			}
+			for filePath, blockRefs := range toFiles {
+				fromBlockRefs, ok := fromFiles[filePath]
+				switch {
+				case !ok:
+					result = append(result, &pfs.FileChange{
+						File:       client.NewFile(canonicalTo.Repo.Name, canonicalTo.ID, filePath),
+						ChangeType: pfs.ChangeType_CHANGE_TYPE_ADDED,
+					})
+				case sameBlockRefs(fromBlockRefs, blockRefs):
+					// unchanged
+				case blockRefsSize(fromBlockRefs) != blockRefsSize(blockRefs):
+					result = append(result, &pfs.FileChange{
+						File:       client.NewFile(canonicalTo.Repo.Name, canonicalTo.ID, filePath),
+						ChangeType: pfs.ChangeType_CHANGE_TYPE_MODIFIED,
+					})
+				default:
+					candidates = append(candidates, candidate{filePath, fromBlockRefs, blockRefs})
+				}
+			}
+			for filePath := range fromFiles {
+				if _, ok := toFiles[filePath]; !ok {
+					result = append(result, &pfs.FileChange{
+						File:       client.NewFile(canonicalFrom.Repo.Name, canonicalFrom.ID, filePath),
+						ChangeType: pfs.ChangeType_CHANGE_TYPE_DELETED,
+					})
+				}
+			}
+		}
+		return nil
+	}(); err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return result, nil
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range candidates {
+		fromHash, err := d.contentHash(blockClient, candidate.fromBlockRefs)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := d.contentHash(blockClient, candidate.blockRefs)
+		if err != nil {
+			return nil, err
+		}
+		if fromHash != hash {
+			result = append(result, &pfs.FileChange{
+				File:       client.NewFile(canonicalTo.Repo.Name, canonicalTo.ID, candidate.path),
+				ChangeType: pfs.ChangeType_CHANGE_TYPE_MODIFIED,
+			})
+		}
+	}
+	return result, nil
+}
+
+// commitFiles returns the block refs of every regular file in commit that
+// lives in shard, keyed by path.
+// commitFiles assumes that the lock is being held
+func (d *driver) commitFiles(commit *pfs.Commit, shard uint64) (map[string][]*pfs.BlockRef, error) {
+	if _, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard)); !ok {
+		return nil, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+	}
+	result := make(map[string][]*pfs.BlockRef)
+	if err := d.walkFiles(client.NewFile(commit.Repo.Name, commit.ID, "."), shard,
+		func(fileInfo *pfs.FileInfo, blockRefs []*pfs.BlockRef) {
+			if fileInfo.FileType == pfs.FileType_FILE_TYPE_REGULAR {
+				result[path.Clean(fileInfo.File.Path)] = blockRefs
+			}
+		}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CommitManifest returns a ManifestEntry for every regular file in commit
+// that lives in shard, sorted by path.
+func (d *driver) CommitManifest(commit *pfs.Commit, shard uint64) ([]*pfs.ManifestEntry, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	var result []*pfs.ManifestEntry
+	if err := d.manifestDir(client.NewFile(commit.Repo.Name, commit.ID, ""), shard, &result); err != nil {
+		return nil, err
+	}
+	pfsserver.SortManifestEntries(result)
+	return result, nil
+}
+
+// manifestDir appends a ManifestEntry for every regular file under dir to
+// result. manifestDir assumes that the lock is being held
+func (d *driver) manifestDir(dir *pfs.File, shard uint64, result *[]*pfs.ManifestEntry) error {
+	fileInfos, err := d.listFile(dir, nil, nil, shard, false, false, "")
+	if _, ok := err.(*pfsserver.ErrFileNotFound); ok {
+		// nothing under dir lives in this shard
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		// the infos we get back may refer to the commits the files were
+		// last changed in, the manifest is for dir's commit
+		file := client.NewFile(dir.Commit.Repo.Name, dir.Commit.ID, fileInfo.File.Path)
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if err := d.manifestDir(file, shard, result); err != nil {
+				return err
+			}
+			continue
+		}
+		// listFile doesn't return block refs so we inspect the file
+		_, blockRefs, err := d.inspectFile(file, nil, shard, nil, false, false, "")
+		if err != nil {
+			return err
+		}
+		hash := sha256.New()
+		for _, blockRef := range blockRefs {
+			fmt.Fprintf(hash, "%s %d %d\n", blockRef.Block.Hash, blockRef.Range.Lower, blockRef.Range.Upper)
+		}
+		*result = append(*result, &pfs.ManifestEntry{
+			File:          file,
+			SizeBytes:     fileInfo.SizeBytes,
+			BlockRefsHash: hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+	return nil
+}
+
+// defaultPackMaxFileSize is the size of the largest file PackCommit packs
+// when the caller doesn't say.
+const defaultPackMaxFileSize = 64 * 1024
+
+// PackCommit rewrites the appends in commit that are no bigger than
+// maxFileSize so that each shard's appends share blocks, the new block refs
+// point at each file's range within the packed blocks. It returns the
+// number of files that were packed.
+// The blocks the files were in aren't deleted, blocks are shared between
+// commits (and servers) by hash so there's no telling from here that nothing
+// else refers to them. They stay in the block store since nothing garbage
+// collects blocks yet.
+func (d *driver) PackCommit(commit *pfs.Commit, maxFileSize uint64, shards map[uint64]bool) (uint64, error) {
+	if maxFileSize == 0 {
+		maxFileSize = defaultPackMaxFileSize
+	}
+	var result uint64
+	for shard := range shards {
+		packed, err := d.packShard(commit, maxFileSize, shard)
+		if err != nil {
+			return 0, err
+		}
+		result += packed
+	}
+	return result, nil
+}
+
+func (d *driver) packShard(commit *pfs.Commit, maxFileSize uint64, shard uint64) (uint64, error) {
+	// we read the candidates under the lock but copy their content without
+	// it, blocks are immutable so the content can't change underneath us
+	var diff *pfs.Diff
+	var paths []string
+	candidates := make(map[string][]*pfs.BlockRef)
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		canonicalCommit, err := d.canonicalCommit(commit)
+		if err != nil {
+			return err
+		}
+		diff = client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard)
+		diffInfo, ok := d.diffs.get(diff)
+		if !ok {
+			return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+		}
+		if diffInfo.Finished == nil {
+			return fmt.Errorf("commit %s/%s is open, finish it before packing it", canonicalCommit.Repo.Name, canonicalCommit.ID)
+		}
+		for filePath, _append := range diffInfo.Appends {
+			// inline content already doesn't have a block of its own
+			if len(_append.BlockRefs) == 0 || blockRefsSize(_append.BlockRefs) > maxFileSize ||
+				hasInlineData(_append.BlockRefs) {
+				continue
+			}
+			paths = append(paths, filePath)
+			candidates[filePath] = _append.BlockRefs
+		}
+		return nil
+	}(); err != nil {
+		return 0, err
+	}
+	// there's nothing to gain from packing a single file
+	if len(paths) < 2 {
+		return 0, nil
+	}
+	sort.Strings(paths)
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return 0, err
+	}
+	var readers []io.Reader
+	for _, filePath := range paths {
+		blockRefs := candidates[filePath]
+		readers = append(readers, newFileReader(blockClient, blockRefs, 0, int64(blockRefsSize(blockRefs)), d.options.VerifyBlocks))
+	}
+	_client := client.APIClient{BlockAPIClient: blockClient}
+	packedBlockRefs, err := _client.PutBlock(pfs.Delimiter_NONE, io.MultiReader(readers...))
+	if err != nil {
+		return 0, err
+	}
+	var result uint64
+	var diffInfo *pfs.DiffInfo
+	func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		var ok bool
+		diffInfo, ok = d.diffs.get(diff)
+		if !ok {
+			// the commit was deleted while we were packing it
+			return
+		}
+		var offset uint64
+		for _, filePath := range paths {
+			blockRefs := candidates[filePath]
+			size := blockRefsSize(blockRefs)
+			// files that were rewritten while we were packing them keep
+			// their new content
+			if _append, ok := diffInfo.Appends[filePath]; ok && sameBlockRefs(_append.BlockRefs, blockRefs) {
+				_append.BlockRefs = sliceBlockRefs(packedBlockRefs.BlockRef, offset, offset+size)
+				d.blockIndex.indexFile(diffInfo, filePath)
+				result++
+			}
+			offset += size
+		}
+	}()
+	if result == 0 {
+		return 0, nil
+	}
+	if err := d.persistDiffInfos([]*pfs.DiffInfo{diffInfo}); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+func sameBlockRefs(a []*pfs.BlockRef, b []*pfs.BlockRef) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !proto.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (d *driver) DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	return fmt.Errorf("DeleteCommit is not implemented")
+}
+
+// SoftDeleteCommit marks a commit as deleted. Deleted commits are hidden from
+// ListCommit and can be restored with RestoreCommit until they're garbage
+// collected, which happens once they're older than
+// Options.DeletedCommitRetention and have no children.
+func (d *driver) SoftDeleteCommit(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	return d.setDeleted(commit, deleted, shards)
+}
+
+// RestoreCommit undoes a SoftDeleteCommit.
+func (d *driver) RestoreCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	return d.setDeleted(commit, nil, shards)
+}
+
+func (d *driver) setDeleted(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	var diffInfos []*pfs.DiffInfo
+	if err := func() error {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		canonicalCommit, err := d.canonicalCommit(commit)
+		if err != nil {
+			return err
+		}
+		for shard := range shards {
+			diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+			if !ok {
+				return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+			}
+			if diffInfo.Finished == nil {
+				return fmt.Errorf("commit %s/%s is open, finish or cancel it before deleting it", canonicalCommit.Repo.Name, canonicalCommit.ID)
+			}
+			if deleted == nil && diffInfo.Deleted == nil {
+				return fmt.Errorf("commit %s/%s has not been deleted", canonicalCommit.Repo.Name, canonicalCommit.ID)
+			}
+			diffInfos = append(diffInfos, diffInfo)
+		}
+		for _, diffInfo := range diffInfos {
+			diffInfo.Deleted = deleted
+		}
+		return nil
+	}(); err != nil {
+		return err
+	}
+	return d.persistDiffInfos(diffInfos)
+}
+
+// forever calls f every interval until the driver is closed. Ticks that
+// come in while f is running are dropped rather than queued up.
+func (d *driver) forever(interval time.Duration, f func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			f()
+		case <-d.closed:
+			return
+		}
+	}
+}
+
+// collectDeletedCommitsForever garbage collects expired soft deleted commits
+// every DeletedCommitRetention, until the driver is closed. A commit that
+// was deleted just after a collection isn't expired until just after the
+// next one, so it's collected up to two retentions after it was deleted.
+func (d *driver) collectDeletedCommitsForever() {
+	d.forever(d.options.DeletedCommitRetention, func() {
+		if err := d.collectDeletedCommits(time.Now()); err != nil {
+			protolion.Errorf("error garbage collecting deleted commits: %s", err.Error())
+		}
+	})
+}
+
+// collectDeletedCommits removes the commits that were soft deleted more than
+// DeletedCommitRetention before now. Only commits without children are
+// removed, so removing a commit may make its parent collectable, we keep
+// going until there's nothing left to collect. Commits that are the
+// provenance of other commits are kept.
+func (d *driver) collectDeletedCommits(now time.Time) error {
+	var diffs []*pfs.Diff
+	func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		provenance := make(map[string]bool)
+		for _, shardMap := range d.diffs {
+			for _, commitToDiffInfo := range shardMap {
+				for _, diffInfo := range commitToDiffInfo {
+					for _, provCommit := range diffInfo.Provenance {
+						provenance[path.Join(provCommit.Repo.Name, provCommit.ID)] = true
+					}
+				}
+			}
+		}
+		for repoName, dag := range d.dags {
+			for {
+				var collected bool
+				for _, commitID := range dag.Leaves() {
+					if provenance[path.Join(repoName, commitID)] || !d.deletedBefore(repoName, commitID, now) {
+						continue
+					}
+					diffs = append(diffs, d.removeCommit(repoName, commitID)...)
+					collected = true
+				}
+				if !collected {
+					break
+				}
+			}
+		}
+	}()
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	for _, diff := range diffs {
+		diff := diff
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := blockClient.DeleteDiff(context.Background(), &pfs.DeleteDiffRequest{Diff: diff}); err != nil {
+				select {
+				case errCh <- err:
+				default:
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	return nil
+}
+
+// OpenCommits returns info about the open commits in shards, in every repo.
+func (d *driver) OpenCommits(shards map[uint64]bool) ([]*pfs.CommitInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	// a commit is only inspected in the shards it's in
+	keyToCommit := make(map[string]*pfs.Commit)
+	commitToShards := make(map[string]map[uint64]bool)
+	for repoName, shardMap := range d.diffs {
+		for shard, commitToDiffInfo := range shardMap {
+			if !shards[shard] {
+				continue
+			}
+			for commitID, diffInfo := range commitToDiffInfo {
+				if commitID == "" || diffInfo.Finished != nil {
+					continue
+				}
+				key := path.Join(repoName, commitID)
+				if _, ok := commitToShards[key]; !ok {
+					keyToCommit[key] = client.NewCommit(repoName, commitID)
+					commitToShards[key] = make(map[uint64]bool)
+				}
+				commitToShards[key][shard] = true
+			}
+		}
+	}
+	var result []*pfs.CommitInfo
+	for key, commitShards := range commitToShards {
+		commitInfo, err := d.inspectCommit(keyToCommit[key], commitShards)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, commitInfo)
+	}
+	return result, nil
+}
+
+// cancelStaleCommitsForever cancels stale commits every OpenCommitTimeout,
+// until the driver is closed.
+func (d *driver) cancelStaleCommitsForever() {
+	d.forever(d.options.OpenCommitTimeout, func() {
+		if err := d.cancelStaleCommits(time.Now()); err != nil {
+			protolion.Errorf("error cancelling stale commits: %s", err.Error())
+		}
+	})
+}
+
+func (d *driver) OnStaleCommitCancelled(f func(commit *pfs.Commit, shards map[uint64]bool)) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	d.staleCommitCancelled = f
+}
+
+// staleCommit is an open commit that was started more than
+// OpenCommitTimeout ago, along with the shards it's in.
+type staleCommit struct {
+	commit  *pfs.Commit
+	started time.Time
+	shards  map[uint64]bool
+}
+
+// cancelStaleCommits cancels the commits that were started more than
+// OpenCommitTimeout before now and are still open. Commits are cancelled
+// oldest first because FinishCommit waits for a commit's parent to finish.
+// An error is returned for commits that were finished by someone else in the
+// meantime, the remaining commits are still cancelled. The function set with
+// OnStaleCommitCancelled is called for each commit that's cancelled.
+func (d *driver) cancelStaleCommits(now time.Time) error {
+	var staleCommits []*staleCommit
+	var staleCommitCancelled func(commit *pfs.Commit, shards map[uint64]bool)
+	func() {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		staleCommitCancelled = d.staleCommitCancelled
+		commitToStaleCommit := make(map[string]*staleCommit)
+		for repoName, shardMap := range d.diffs {
+			for shard, commitToDiffInfo := range shardMap {
+				for commitID, diffInfo := range commitToDiffInfo {
+					if diffInfo.Finished != nil || diffInfo.Started == nil {
+						continue
+					}
+					started := prototime.TimestampToTime(diffInfo.Started)
+					if now.Sub(started) < d.options.OpenCommitTimeout {
+						continue
+					}
+					key := path.Join(repoName, commitID)
+					if _, ok := commitToStaleCommit[key]; !ok {
+						commitToStaleCommit[key] = &staleCommit{
+							commit:  client.NewCommit(repoName, commitID),
+							started: started,
+							shards:  make(map[uint64]bool),
+						}
+					}
+					commitToStaleCommit[key].shards[shard] = true
+				}
+			}
+		}
+		for _, staleCommit := range commitToStaleCommit {
+			staleCommits = append(staleCommits, staleCommit)
+		}
+	}()
+	sort.Sort(sortStaleCommits(staleCommits))
+	finished := prototime.TimeToTimestamp(now)
+	var retErr error
+	for _, staleCommit := range staleCommits {
+		if err := d.FinishCommit(staleCommit.commit, finished, true, FinishCommitOptions{}, staleCommit.shards); err != nil {
+			if retErr == nil {
+				retErr = err
+			}
+			continue
+		}
+		if staleCommitCancelled != nil {
+			staleCommitCancelled(staleCommit.commit, staleCommit.shards)
+		}
+	}
+	return retErr
+}
+
+type sortStaleCommits []*staleCommit
+
+func (a sortStaleCommits) Len() int {
+	return len(a)
+}
+
+func (a sortStaleCommits) Less(i, j int) bool {
+	return a[i].started.Before(a[j].started)
+}
+func (a sortStaleCommits) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+// flushOpenCommitsForever flushes open commits every FlushInterval, until
+// the driver is closed.
+func (d *driver) flushOpenCommitsForever() {
+	d.forever(d.options.FlushInterval, func() {
+		if err := d.flushOpenCommits(); err != nil {
+			protolion.Errorf("error flushing open commits: %s", err.Error())
+		}
+	})
+}
+
+// flushOpenCommits persists the diffs of open commits that have been written
+// to since they were last flushed. The diffs are copied under the lock and
+// written without it so that reads and writes aren't held up by the block
+// server. flushLock keeps a flush from landing after FinishCommit or
+// DeleteRepo and putting back a stale open diff. Diffs that fail to persist
+// stay dirty.
+func (d *driver) flushOpenCommits() error {
+	// the shards being flushed are scheduled as writes, diffs that are
+	// dirtied in other shards in the meantime are left for the next flush
+	shards := make(map[uint64]bool)
+	func() {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		for diffInfo := range d.dirtyDiffs {
+			shards[diffInfo.Diff.Shard] = true
+		}
+	}()
+	if len(shards) == 0 {
+		return nil
+	}
+	release := d.scheduler.acquireAll(shards, shardWrite)
+	defer release()
+	d.flushLock.Lock()
+	defer d.flushLock.Unlock()
+	var diffInfos []*pfs.DiffInfo
+	var flushed []*pfs.DiffInfo
+	func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		for diffInfo := range d.dirtyDiffs {
+			if !shards[diffInfo.Diff.Shard] {
+				continue
+			}
+			diffInfos = append(diffInfos, proto.Clone(diffInfo).(*pfs.DiffInfo))
+			flushed = append(flushed, diffInfo)
+			delete(d.dirtyDiffs, diffInfo)
+		}
+	}()
+	if err := d.persistDiffInfos(diffInfos); err != nil {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		for _, diffInfo := range flushed {
+			// diffs that were finished or deleted in the meantime have been
+			// taken care of
+			if current, ok := d.diffs.get(diffInfo.Diff); ok && current == diffInfo && diffInfo.Finished == nil {
+				d.dirtyDiffs[diffInfo] = true
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// sweepExpiredFilesForever sweeps expired files every
+// ExpiredFileSweepInterval, until the driver is closed.
+func (d *driver) sweepExpiredFilesForever() {
+	d.forever(d.options.ExpiredFileSweepInterval, func() {
+		if err := d.sweepExpiredFiles(time.Now()); err != nil {
+			protolion.Errorf("error sweeping expired files: %s", err.Error())
+		}
+	})
+}
+
+// sweepExpiredFiles turns the appends in finished commits that expired
+// before now into deletes, dropping their blocks, and persists the result.
+// Appends in open commits are left alone until the commit is finished,
+// inspectFile hides them in the meantime. Immutable files are swept like any
+// other, a TTL set when a file was put outlives making it immutable.
+func (d *driver) sweepExpiredFiles(now time.Time) error {
+	var diffInfos []*pfs.DiffInfo
+	func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		for _, shardMap := range d.diffs {
+			for _, commitToDiffInfo := range shardMap {
+				for _, diffInfo := range commitToDiffInfo {
+					if diffInfo.Finished == nil {
+						continue
+					}
+					var swept bool
+					for filePath, _append := range diffInfo.Appends {
+						if !appendExpired(_append, now) {
+							continue
+						}
+						size := blockRefsSize(_append.BlockRefs)
+						for _, blockRefs := range _append.Handles {
+							size += blockRefsSize(blockRefs.BlockRef)
+						}
+						// an append that replaced the file's content, e.g. a
+						// put over content that would have expired, holds
+						// block refs that were counted towards the commits
+						// they were put in
+						if size > diffInfo.SizeBytes {
+							size = diffInfo.SizeBytes
+						}
+						diffInfo.SizeBytes -= size
+						_append.BlockRefs = nil
+						_append.Handles = nil
+						_append.FileType = pfs.FileType_FILE_TYPE_NONE
+						_append.Delete = true
+						_append.Expires = nil
+						d.blockIndex.indexFile(diffInfo, filePath)
+						if dirAppend, ok := diffInfo.Appends[path.Dir(filePath)]; ok && dirAppend.Children != nil {
+							dirAppend.Children[filePath] = false
+						}
+						swept = true
+					}
+					if swept {
+						diffInfos = append(diffInfos, diffInfo)
+					}
+				}
+			}
+		}
+	}()
+	return d.persistDiffInfos(diffInfos)
+}
+
+func appendExpired(_append *pfs.Append, now time.Time) bool {
+	return _append.Expires != nil && !now.Before(prototime.TimestampToTime(_append.Expires))
+}
+
+// deletedBefore returns true if commit was soft deleted more than
+// DeletedCommitRetention before now.
+// deletedBefore assumes that the lock is being held
+func (d *driver) deletedBefore(repoName string, commitID string, now time.Time) bool {
+	var seen bool
+	for _, commitToDiffInfo := range d.diffs[repoName] {
+		diffInfo, ok := commitToDiffInfo[commitID]
+		if !ok {
+			continue
+		}
+		if diffInfo.Deleted == nil ||
+			now.Sub(prototime.TimestampToTime(diffInfo.Deleted)) < d.options.DeletedCommitRetention {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// removeCommit removes all traces of a commit from memory, it returns the
+// diffs that were removed so they can be deleted from the block server.
+// removeCommit assumes that the lock is being held
+func (d *driver) removeCommit(repoName string, commitID string) []*pfs.Diff {
+	var diffs []*pfs.Diff
+	var parentCommit *pfs.Commit
+	for shard := range d.diffs[repoName] {
+		if diffInfo := d.diffs.pop(client.NewDiff(repoName, commitID, shard)); diffInfo != nil {
+			d.blockIndex.unindexDiff(diffInfo)
+			diffs = append(diffs, diffInfo.Diff)
+			parentCommit = diffInfo.ParentCommit
+		}
+	}
+	d.dags[repoName].RemoveNode(commitID)
+	delete(d.openCommits[repoName], commitID)
+	for branch, headID := range d.branches[repoName] {
+		if headID != commitID {
+			continue
+		}
+		// move the branch back to the parent if it's on the same branch
+		delete(d.branches[repoName], branch)
+		if parentCommit == nil {
+			continue
+		}
+		for _, commitToDiffInfo := range d.diffs[repoName] {
+			if parentDiffInfo, ok := commitToDiffInfo[parentCommit.ID]; ok {
+				if parentDiffInfo.Branch == branch {
+					d.branches[repoName][branch] = parentCommit.ID
+				}
+				break
+			}
+		}
+	}
+	return diffs
+}
+
+func (d *driver) PutFile(file *pfs.File, handle string,
+	delimiter pfs.Delimiter, options PutFileOptions, shard uint64, reader io.Reader) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	var validator ContentValidator
+	if options.ContentType != "" {
+		var err error
+		if validator, err = getContentValidator(options.ContentType); err != nil {
+			return err
+		}
+	}
+	// check for backpressure and replays before we write any blocks so
+	// that rejected writes are cheap
+	applied, err := func() (bool, error) {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		if err := d.checkUnflushedBytes(shard); err != nil {
+			return false, err
+		}
+		return d.putApplied(file, options.IdempotencyKey, shard)
+	}()
+	if err != nil {
+		return err
+	}
+	if applied {
+		return nil
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	_client := client.APIClient{BlockAPIClient: blockClient}
+	hash := sha256.New()
+	reader = io.TeeReader(reader, hash)
+	var validation *contentValidation
+	if validator != nil {
+		validation = newContentValidation(validator)
+		defer validation.wait()
+		reader = io.TeeReader(reader, validation)
+	}
+	var blockRefs []*pfs.BlockRef
+	// JSON content goes through the block server regardless of size since
+	// that's where it's validated
+	if d.options.InlineFileSize > 0 && delimiter != pfs.Delimiter_JSON {
+		// we read one byte more than we'd inline to tell whether the
+		// content fits
+		data, err := ioutil.ReadAll(io.LimitReader(reader, int64(d.options.InlineFileSize)+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > 0 && uint64(len(data)) <= d.options.InlineFileSize {
+			blockRefs = []*pfs.BlockRef{inlineBlockRef(data)}
+		} else {
+			reader = io.MultiReader(bytes.NewReader(data), reader)
+		}
+	}
+	// JSON content is validated by the block server so it can't be
+	// written in pieces
+	if blockRefs == nil && d.options.DeltaPuts && handle == "" && delimiter != pfs.Delimiter_JSON {
+		d.lock.RLock()
+		parentBlockRefs := d.deltaParent(file, shard)
+		d.lock.RUnlock()
+		if len(parentBlockRefs) > 0 {
+			if blockRefs, err = d.putDelta(_client, delimiter, reader, parentBlockRefs); err != nil {
+				return err
+			}
+		}
+	}
+	if blockRefs == nil {
+		putBlockRefs, err := _client.PutBlock(delimiter, reader)
+		if err != nil {
+			return err
+		}
+		blockRefs = putBlockRefs.BlockRef
+	}
+	// we check the hash before touching the commit, that way a mismatched put
+	// leaves the commit as it was, the blocks we wrote are just unreferenced
+	if options.ExpectedHash != "" {
+		if actualHash := hex.EncodeToString(hash.Sum(nil)); actualHash != options.ExpectedHash {
+			return fmt.Errorf("hash mismatch for %s: expected %s but got %s", file.Path, options.ExpectedHash, actualHash)
+		}
+	}
+	if validation != nil {
+		if err := validation.wait(); err != nil {
+			return pfsserver.NewErrValidationFailed(file.Path, options.ContentType, err)
+		}
+	}
+	return d.putBlockRefs(file, handle, options.IdempotencyKey, options.Expires, options.Xattrs, shard, blockRefs)
+}
+
+// inlineBlockRef returns a block ref that carries data itself. Its block
+// has the hash the block server would have given data so anything that
+// looks at blocks, e.g. sharding by block, treats it like any other ref.
+func inlineBlockRef(data []byte) *pfs.BlockRef {
+	return &pfs.BlockRef{
+		Block: client.NewBlock(blockHash(data)),
+		Range: &pfs.ByteRange{Lower: 0, Upper: uint64(len(data))},
+		Data:  data,
+	}
+}
+
+// blockHash returns the hash the block server gives a block with data as
+// its content.
+func blockHash(data []byte) string {
+	hash := sha512.Sum512(data)
+	return base64.URLEncoding.EncodeToString(hash[:])
+}
+
+func hasInlineData(blockRefs []*pfs.BlockRef) bool {
+	for _, blockRef := range blockRefs {
+		if blockRef.Data != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// putBlockRefs appends blockRefs, which have already been written to the
+// block server, to file.
+func (d *driver) putBlockRefs(file *pfs.File, handle string, idempotencyKey string,
+	expires *google_protobuf.Timestamp, xattrs map[string]string, shard uint64, blockRefs []*pfs.BlockRef) (retErr error) {
+	defer func() {
+		if retErr == nil {
+			metrics.AddFiles(1)
+			for _, blockRef := range blockRefs {
+				metrics.AddBytes(int64(blockRef.Range.Upper - blockRef.Range.Lower))
+			}
+		}
+	}()
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	fileType, err := d.getFileType(file, shard)
+	if err != nil {
+		return err
+	}
+
+	if fileType == pfs.FileType_FILE_TYPE_DIR {
+		return fmt.Errorf("%s is a directory", file.Path)
+	}
+	if err := d.checkMutable(file, shard, false); err != nil {
+		return err
+	}
+
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		// This is a weird case since the commit existed above, it means someone
+		// deleted the commit while the above code was running
+		return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if diffInfo.Finished != nil {
+		return pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	// the last put to a file decides when it expires, so if what the file
+	// has from earlier commits would expire with an earlier put it's copied
+	// into this commit's append, which then ignores whatever came before it
+	var inherited *pfs.FileInfo
+	var inheritedBlockRefs []*pfs.BlockRef
+	if existing, ok := diffInfo.Appends[path.Clean(file.Path)]; diffInfo.ParentCommit != nil && (!ok || !existing.Delete) {
+		parentFile := client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path)
+		if d.inheritsExpiry(parentFile, shard) {
+			inherited, inheritedBlockRefs, err = d.inspectFile(parentFile, nil, shard, nil, false, true, "")
+			if _, ok := err.(*pfsserver.ErrFileNotFound); err != nil && !ok {
+				return err
+			}
+		}
+	}
+	if idempotencyKey != "" {
+		// a replay may have raced with us while we were writing blocks
+		if keyApplied(diffInfo, file, idempotencyKey) {
+			return nil
+		}
+		cleanPath := path.Clean(file.Path)
+		if diffInfo.IdempotencyKeys == nil {
+			diffInfo.IdempotencyKeys = make(map[string]*pfs.IdempotencyKeys)
+		}
+		if diffInfo.IdempotencyKeys[cleanPath] == nil {
+			diffInfo.IdempotencyKeys[cleanPath] = &pfs.IdempotencyKeys{Key: make(map[string]bool)}
+		}
+		diffInfo.IdempotencyKeys[cleanPath].Key[idempotencyKey] = true
+	}
+	d.addDirs(diffInfo, file, shard)
+	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+	if !ok {
+		_append = newAppend(pfs.FileType_FILE_TYPE_REGULAR)
+	} else {
+		_append.FileType = pfs.FileType_FILE_TYPE_REGULAR
+	}
+	if diffInfo.ParentCommit != nil {
+		_append.LastRef = d.lastRef(
+			client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path),
+			shard,
+		)
+	}
+	if inherited != nil {
+		ownXattrs := _append.Xattrs
+		_append.Xattrs = nil
+		setXattrs(_append, inherited.Xattrs)
+		setXattrs(_append, ownXattrs)
+		_append.BlockRefs = append(inheritedBlockRefs, _append.BlockRefs...)
+		_append.Delete = true
+	}
+	diffInfo.Appends[path.Clean(file.Path)] = _append
+	// the last put to a file decides when it expires
+	_append.Expires = expires
+	setXattrs(_append, xattrs)
+	if handle == "" {
+		_append.BlockRefs = append(_append.BlockRefs, blockRefs...)
+	} else {
+		handleBlockRefs, ok := _append.Handles[handle]
+		if !ok {
+			handleBlockRefs = &pfs.BlockRefs{}
+			_append.Handles[handle] = handleBlockRefs
+		}
+		handleBlockRefs.BlockRef = append(handleBlockRefs.BlockRef, blockRefs...)
+	}
+	d.blockIndex.indexFile(diffInfo, path.Clean(file.Path))
+	for _, blockRef := range blockRefs {
+		diffInfo.SizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+		d.unflushedBytes[shard] += blockRef.Range.Upper - blockRef.Range.Lower
+	}
+	d.dirtyDiffs[diffInfo] = true
+	return nil
+}
+
+// stagedBlobSweepInterval is how often staged blobs that have expired are
+// forgotten.
+const stagedBlobSweepInterval = time.Minute
+
+// stagedBlob is data that's been written to the block server by StageBlob
+// but may not be in any file yet.
+type stagedBlob struct {
+	blockRefs []*pfs.BlockRef
+	expires   time.Time
+}
+
+// StageBlob writes the data in reader to the block server and keeps track
+// of it under handle until expires, it returns the number of bytes written.
+// Staged blobs are only kept in memory, they aren't persisted with the
+// diffs, so they're lost if the server restarts or the shard moves and have
+// to be staged again. The blocks of expired blobs aren't deleted, blocks are
+// content addressed so they may also be referenced by files.
+func (d *driver) StageBlob(handle string, delimiter pfs.Delimiter, expires time.Time, shard uint64, reader io.Reader) (uint64, error) {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return 0, err
+	}
+	_client := client.APIClient{BlockAPIClient: blockClient}
+	blockRefs, err := _client.PutBlock(delimiter, reader)
+	if err != nil {
+		return 0, err
+	}
+	var sizeBytes uint64
+	for _, blockRef := range blockRefs.BlockRef {
+		sizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+	}
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.stagedBlobs[shard] == nil {
+		d.stagedBlobs[shard] = make(map[string]*stagedBlob)
+	}
+	d.stagedBlobs[shard][handle] = &stagedBlob{
+		blockRefs: blockRefs.BlockRef,
+		expires:   expires,
+	}
+	return sizeBytes, nil
+}
+
+// sweepExpiredBlobsForever forgets expired staged blobs every
+// stagedBlobSweepInterval, until the driver is closed.
+func (d *driver) sweepExpiredBlobsForever() {
+	d.forever(stagedBlobSweepInterval, func() {
+		d.sweepExpiredBlobs(time.Now())
+	})
+}
+
+// sweepExpiredBlobs forgets the staged blobs that have expired by now so that
+// they don't pile up, expired blobs can't be used whether or not they've been
+// swept.
+func (d *driver) sweepExpiredBlobs(now time.Time) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	for _, handleToBlob := range d.stagedBlobs {
+		for handle, blob := range handleToBlob {
+			if !now.Before(blob.expires) {
+				delete(handleToBlob, handle)
+			}
+		}
+	}
+}
+
+// StagedBlob returns the block refs of a blob staged with StageBlob.
+func (d *driver) StagedBlob(handle string, shard uint64) ([]*pfs.BlockRef, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	blob, ok := d.stagedBlobs[shard][handle]
+	if !ok || !time.Now().Before(blob.expires) {
+		return nil, fmt.Errorf("staged blob %s not found, it may have expired", handle)
+	}
+	return blob.blockRefs, nil
+}
+
+// PutFileBlockRefs appends blockRefs, which have already been written to
+// the block server, to file.
+func (d *driver) PutFileBlockRefs(file *pfs.File, blockRefs []*pfs.BlockRef, shard uint64) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		return d.checkUnflushedBytes(shard)
+	}(); err != nil {
+		return err
+	}
+	return d.putBlockRefs(file, "", "", nil, nil, shard, blockRefs)
+}
+
+// PutFileAt writes the data in reader to file at offset, overwriting what's
+// there. Writing past the end of the file fills the gap with zeros. The write
+// is recorded as an append that replaces the file's block refs with the
+// existing ones spliced around the new data, no existing data is copied.
+func (d *driver) PutFileAt(file *pfs.File, offset uint64, expectedHash string,
+	expires *google_protobuf.Timestamp, xattrs map[string]string, shard uint64, reader io.Reader) (retErr error) {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		return d.checkUnflushedBytes(shard)
+	}(); err != nil {
+		return err
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	_client := client.APIClient{BlockAPIClient: blockClient}
+	hash := sha256.New()
+	blockRefs, err := _client.PutBlock(pfs.Delimiter_NONE, io.TeeReader(reader, hash))
 	if err != nil {
 		return err
 	}
+	if expectedHash != "" {
+		if actualHash := hex.EncodeToString(hash.Sum(nil)); actualHash != expectedHash {
+			return fmt.Errorf("hash mismatch for %s: expected %s but got %s", file.Path, expectedHash, actualHash)
+		}
+	}
 	defer func() {
 		if retErr == nil {
 			metrics.AddFiles(1)
@@ -493,17 +2143,327 @@ func (d *driver) PutFile(file *pfs.File, handle string,
 			}
 		}
 	}()
+	// We only know how many zeros we need once we hold the lock, but we
+	// don't want to write blocks while we hold it. So we write as many zeros
+	// as the file was short by and try again, the zeros we have can fill any
+	// smaller gap.
+	var zeroBlockRefs []*pfs.BlockRef
+	for {
+		gap, err := d.putFileAt(file, offset, blockRefs.BlockRef, zeroBlockRefs, expires, xattrs, shard)
+		if err != nil {
+			return err
+		}
+		if gap == 0 {
+			return nil
+		}
+		zeros, err := _client.PutBlock(pfs.Delimiter_NONE, io.LimitReader(zeroReader{}, int64(gap)))
+		if err != nil {
+			return err
+		}
+		zeroBlockRefs = zeros.BlockRef
+	}
+}
+
+// putFileAt splices blockRefs into file at offset. If the file ends before
+// offset and zeroBlockRefs is too short to fill the gap nothing is written
+// and the size of the gap is returned.
+func (d *driver) putFileAt(file *pfs.File, offset uint64, blockRefs []*pfs.BlockRef,
+	zeroBlockRefs []*pfs.BlockRef, expires *google_protobuf.Timestamp, xattrs map[string]string, shard uint64) (uint64, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	fileType, err := d.getFileType(file, shard)
+	if err != nil {
+		return 0, err
+	}
+	if fileType == pfs.FileType_FILE_TYPE_DIR {
+		return 0, fmt.Errorf("%s is a directory", file.Path)
+	}
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return 0, err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		return 0, pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if diffInfo.Finished != nil {
+		return 0, pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if err := d.checkMutable(file, shard, false); err != nil {
+		return 0, err
+	}
+	cleanPath := path.Clean(file.Path)
+	if _append, ok := diffInfo.Appends[cleanPath]; ok && len(_append.Handles) > 0 {
+		// writes to handles aren't ordered until the commit is finished, so
+		// there's no way to know where offset falls
+		return 0, fmt.Errorf("%s has writes to handles in commit %s/%s, it can't be written at an offset until the commit is finished",
+			file.Path, canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	var fileSize uint64
+	var fileXattrs map[string]string
+	fileInfo, fileBlockRefs, err := d.inspectFile(file, nil, shard, nil, false, true, "")
+	if _, ok := err.(*pfsserver.ErrFileNotFound); err != nil && !ok {
+		return 0, err
+	}
+	if err == nil {
+		fileSize = fileInfo.SizeBytes
+		fileXattrs = fileInfo.Xattrs
+	}
+
+	// only the bytes that were put count towards the commit's size, the
+	// zeros that fill a gap don't, otherwise they'd be counted again when
+	// they're written over
+	var spliced []*pfs.BlockRef
+	if offset > fileSize {
+		gap := offset - fileSize
+		if gap > blockRefsSize(zeroBlockRefs) {
+			return gap, nil
+		}
+		spliced = append(spliced, fileBlockRefs...)
+		spliced = append(spliced, sliceBlockRefs(zeroBlockRefs, 0, gap)...)
+	} else {
+		spliced = sliceBlockRefs(fileBlockRefs, 0, offset)
+	}
+	spliced = append(spliced, blockRefs...)
+	end := offset + blockRefsSize(blockRefs)
+	if end < fileSize {
+		spliced = append(spliced, sliceBlockRefs(fileBlockRefs, end, fileSize)...)
+	}
+	written := blockRefsSize(blockRefs)
+
+	d.addDirs(diffInfo, file, shard)
+	// the spliced block refs are the whole file so the append ignores
+	// whatever came before it
+	_append := newAppend(pfs.FileType_FILE_TYPE_REGULAR)
+	_append.Delete = true
+	_append.BlockRefs = spliced
+	_append.Expires = expires
+	if existing, ok := diffInfo.Appends[cleanPath]; ok {
+		// the new append replaces this commit's, it keeps its flags
+		_append.Immutable = existing.Immutable
+	}
+	setXattrs(_append, fileXattrs)
+	setXattrs(_append, xattrs)
+	diffInfo.Appends[cleanPath] = _append
+	d.blockIndex.indexFile(diffInfo, cleanPath)
+	diffInfo.SizeBytes += written
+	d.unflushedBytes[shard] += written
+	d.dirtyDiffs[diffInfo] = true
+	return 0, nil
+}
+
+// FileCompareAndSwap replaces the content of a regular file in an open
+// commit with value if the hex encoded SHA-256 of its current content is
+// expectedHash, an empty expectedHash means the file mustn't exist. It
+// returns whether the content was replaced and the hash of the file's
+// content afterwards.
+func (d *driver) FileCompareAndSwap(file *pfs.File, expectedHash string, value []byte, shard uint64) (bool, string, error) {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	if err := func() error {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		return d.checkUnflushedBytes(shard)
+	}(); err != nil {
+		return false, "", err
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return false, "", err
+	}
+	// the new content is written before we know whether it's needed so that
+	// no blocks are written while we hold the lock
+	var blockRefs []*pfs.BlockRef
+	if d.options.InlineFileSize > 0 && uint64(len(value)) <= d.options.InlineFileSize {
+		if len(value) > 0 {
+			blockRefs = []*pfs.BlockRef{inlineBlockRef(value)}
+		}
+	} else {
+		_client := client.APIClient{BlockAPIClient: blockClient}
+		putBlockRefs, err := _client.PutBlock(pfs.Delimiter_NONE, bytes.NewReader(value))
+		if err != nil {
+			return false, "", err
+		}
+		blockRefs = putBlockRefs.BlockRef
+	}
+	valueHash := sha256.Sum256(value)
+	// The current content is hashed without the lock, the swap only happens
+	// if the file hasn't been written since. If it has we hash it again.
+	for {
+		d.lock.RLock()
+		fileInfo, fileBlockRefs, err := d.casFile(file, shard)
+		d.lock.RUnlock()
+		if err != nil {
+			return false, "", err
+		}
+		var currentHash string
+		if fileInfo != nil {
+			if currentHash, err = d.contentHash(blockClient, fileBlockRefs); err != nil {
+				return false, "", err
+			}
+		}
+		if currentHash != expectedHash {
+			return false, currentHash, nil
+		}
+		swapped, err := d.swapBlockRefs(file, fileInfo != nil, fileBlockRefs, blockRefs, shard)
+		if err != nil {
+			return false, "", err
+		}
+		if swapped {
+			metrics.AddFiles(1)
+			metrics.AddBytes(int64(len(value)))
+			return true, hex.EncodeToString(valueHash[:]), nil
+		}
+	}
+}
+
+// casFile returns file's info and block refs, a nil info means file doesn't
+// exist. It returns an error if file can't be compared and swapped.
+// casFile assumes that the lock is being held
+func (d *driver) casFile(file *pfs.File, shard uint64) (*pfs.FileInfo, []*pfs.BlockRef, error) {
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return nil, nil, err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		return nil, nil, pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if diffInfo.Finished != nil {
+		return nil, nil, pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if _append, ok := diffInfo.Appends[path.Clean(file.Path)]; ok && len(_append.Handles) > 0 {
+		// writes to handles aren't ordered until the commit is finished, so
+		// the file's content isn't settled
+		return nil, nil, fmt.Errorf("%s has writes to handles in commit %s/%s, it can't be compared and swapped until the commit is finished",
+			file.Path, canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	fileInfo, blockRefs, err := d.inspectFile(file, nil, shard, nil, false, true, "")
+	if _, ok := err.(*pfsserver.ErrFileNotFound); ok {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		return nil, nil, fmt.Errorf("%s is a directory", file.Path)
+	}
+	if err := d.checkMutable(file, shard, false); err != nil {
+		return nil, nil, err
+	}
+	return fileInfo, blockRefs, nil
+}
+
+// contentHash returns the hex encoded SHA-256 of the data blockRefs refer to.
+func (d *driver) contentHash(blockClient pfs.BlockAPIClient, blockRefs []*pfs.BlockRef) (string, error) {
+	hash := sha256.New()
+	reader := newFileReader(blockClient, blockRefs, 0, int64(blockRefsSize(blockRefs)), d.options.VerifyBlocks)
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// swapBlockRefs replaces file's content with blockRefs if it still exists
+// and has the block refs it had when it was compared, it returns whether it
+// did.
+func (d *driver) swapBlockRefs(file *pfs.File, existed bool, fileBlockRefs []*pfs.BlockRef,
+	blockRefs []*pfs.BlockRef, shard uint64) (bool, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	fileInfo, currentBlockRefs, err := d.casFile(file, shard)
+	if err != nil {
+		return false, err
+	}
+	if (fileInfo != nil) != existed || !sameBlockRefs(currentBlockRefs, fileBlockRefs) {
+		return false, nil
+	}
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return false, err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		return false, pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	cleanPath := path.Clean(file.Path)
+	var xattrs map[string]string
+	if fileInfo != nil {
+		xattrs = fileInfo.Xattrs
+	}
+	d.addDirs(diffInfo, file, shard)
+	// the block refs are the whole file so the append ignores whatever came
+	// before it
+	_append := newAppend(pfs.FileType_FILE_TYPE_REGULAR)
+	_append.Delete = true
+	_append.BlockRefs = blockRefs
+	setXattrs(_append, xattrs)
+	diffInfo.Appends[cleanPath] = _append
+	d.blockIndex.indexFile(diffInfo, cleanPath)
+	size := blockRefsSize(blockRefs)
+	diffInfo.SizeBytes += size
+	d.unflushedBytes[shard] += size
+	d.dirtyDiffs[diffInfo] = true
+	return true, nil
+}
+
+// SetXattr sets an extended attribute on a regular file in an open commit,
+// the file's content is left as it is. An empty value removes the attribute.
+func (d *driver) SetXattr(file *pfs.File, name string, value string, shard uint64) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
-	fileType, err := d.getFileType(file, shard)
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
 	if err != nil {
 		return err
 	}
-
-	if fileType == pfs.FileType_FILE_TYPE_DIR {
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if diffInfo.Finished != nil {
+		return pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	fileInfo, _, err := d.inspectFile(file, nil, shard, nil, false, true, "")
+	if err != nil {
+		return err
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
 		return fmt.Errorf("%s is a directory", file.Path)
 	}
+	d.addDirs(diffInfo, file, shard)
+	cleanPath := path.Clean(file.Path)
+	_append, ok := diffInfo.Appends[cleanPath]
+	if !ok {
+		// an append with no block refs leaves the content as it was
+		_append = newAppend(pfs.FileType_FILE_TYPE_REGULAR)
+		if diffInfo.ParentCommit != nil {
+			_append.LastRef = d.lastRef(
+				client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path),
+				shard,
+			)
+		}
+		diffInfo.Appends[cleanPath] = _append
+	}
+	setXattrs(_append, map[string]string{name: value})
+	d.dirtyDiffs[diffInfo] = true
+	return nil
+}
+
+// SetImmutable makes a regular file immutable. From then on PutFile and
+// DeleteFile reject the file, and deleting a directory that contains it, in
+// the commit and all of its descendants. Immutable files can't be deleted so
+// there's no way for a descendant to drop the flag. A file that was put with
+// a TTL still expires once it's immutable.
+func (d *driver) SetImmutable(file *pfs.File, shard uint64) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	d.lock.Lock()
+	defer d.lock.Unlock()
 
 	canonicalCommit, err := d.canonicalCommit(file.Commit)
 	if err != nil {
@@ -511,44 +2471,87 @@ func (d *driver) PutFile(file *pfs.File, handle string,
 	}
 	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
 	if !ok {
-		// This is a weird case since the commit existed above, it means someone
-		// deleted the commit while the above code was running
 		return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
 	}
 	if diffInfo.Finished != nil {
-		return fmt.Errorf("commit %s/%s has already been finished", canonicalCommit.Repo.Name, canonicalCommit.ID)
+		return pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
 	}
-	d.addDirs(diffInfo, file, shard)
-	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+	fileInfo, _, err := d.inspectFile(file, nil, shard, nil, false, true, "")
+	if err != nil {
+		return err
+	}
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		return fmt.Errorf("%s is a directory", file.Path)
+	}
+	cleanPath := path.Clean(file.Path)
+	_append, ok := diffInfo.Appends[cleanPath]
 	if !ok {
+		// an append with no block refs leaves the content as it was
 		_append = newAppend(pfs.FileType_FILE_TYPE_REGULAR)
-	} else {
-		_append.FileType = pfs.FileType_FILE_TYPE_REGULAR
+		if diffInfo.ParentCommit != nil {
+			_append.LastRef = d.lastRef(
+				client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path),
+				shard,
+			)
+		}
+		diffInfo.Appends[cleanPath] = _append
 	}
-	if diffInfo.ParentCommit != nil {
-		_append.LastRef = d.lastRef(
-			client.NewFile(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID, file.Path),
-			shard,
-		)
+	_append.Immutable = true
+	d.dirtyDiffs[diffInfo] = true
+	return nil
+}
+
+// CheckMutable returns an error if file, or any file under it, has been made
+// immutable in file.Commit or one of its ancestors.
+func (d *driver) CheckMutable(file *pfs.File, shard uint64) error {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.checkMutable(file, shard, true)
+}
+
+// checkMutable returns an error if file has been made immutable in
+// file.Commit or one of its ancestors. If recurse is set files under file are
+// checked as well, which means looking at every append in the ancestry.
+// checkMutable assumes that the lock is being held
+func (d *driver) checkMutable(file *pfs.File, shard uint64, recurse bool) error {
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return err
 	}
-	diffInfo.Appends[path.Clean(file.Path)] = _append
-	if handle == "" {
-		_append.BlockRefs = append(_append.BlockRefs, blockRefs.BlockRef...)
-	} else {
-		handleBlockRefs, ok := _append.Handles[handle]
+	cleanPath := path.Clean(file.Path)
+	for commit != nil {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
 		if !ok {
-			handleBlockRefs = &pfs.BlockRefs{}
-			_append.Handles[handle] = handleBlockRefs
+			return pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
 		}
-		handleBlockRefs.BlockRef = append(handleBlockRefs.BlockRef, blockRefs.BlockRef...)
-	}
-	for _, blockRef := range blockRefs.BlockRef {
-		diffInfo.SizeBytes += blockRef.Range.Upper - blockRef.Range.Lower
+		if _append, ok := diffInfo.Appends[cleanPath]; ok && _append.Immutable {
+			return fmt.Errorf("%s is immutable", cleanPath)
+		}
+		if recurse {
+			for filePath, _append := range diffInfo.Appends {
+				if _append.Immutable && pathUnder(filePath, cleanPath) {
+					return fmt.Errorf("%s is immutable", filePath)
+				}
+			}
+		}
+		commit = diffInfo.ParentCommit
 	}
 	return nil
 }
 
+// pathUnder returns true if filePath is somewhere under the directory dir.
+func pathUnder(filePath string, dir string) bool {
+	if dir == "." || dir == "/" {
+		return true
+	}
+	return strings.HasPrefix(filePath, strings.TrimSuffix(dir, "/")+"/")
+}
+
 func (d *driver) MakeDirectory(file *pfs.File, shard uint64) (retErr error) {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
 	defer func() {
 		if retErr == nil {
 			metrics.AddFiles(1)
@@ -577,7 +2580,7 @@ func (d *driver) MakeDirectory(file *pfs.File, shard uint64) (retErr error) {
 		return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
 	}
 	if diffInfo.Finished != nil {
-		return fmt.Errorf("commit %s/%s has already been finished", canonicalCommit.Repo.Name, canonicalCommit.ID)
+		return pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
 	}
 	d.addDirs(diffInfo, file, shard)
 	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
@@ -597,16 +2600,72 @@ func (d *driver) MakeDirectory(file *pfs.File, shard uint64) (retErr error) {
 		)
 	}
 	diffInfo.Appends[path.Clean(file.Path)] = _append
+	d.blockIndex.indexFile(diffInfo, path.Clean(file.Path))
 	// The fact that this is a directory is signified by setting Children
 	// to non-nil
 	_append.Children = make(map[string]bool)
+	d.dirtyDiffs[diffInfo] = true
 	return nil
 }
 
 func (d *driver) GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64,
 	size int64, from *pfs.Commit, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	reader, err := func() (io.ReadCloser, error) {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		return d.getFile(file, filterShard, offset, size, from, shard, unsafe, handle)
+	}()
+	return scheduledRead(reader, err, release)
+}
+
+// scheduledRead makes reader hold on to its slot in the shard's scheduler
+// until it's closed, or releases the slot right away if there's no reader.
+func scheduledRead(reader io.ReadCloser, err error, release func()) (io.ReadCloser, error) {
+	if err != nil {
+		release()
+		return nil, err
+	}
+	return &scheduledReadCloser{ReadCloser: reader, release: release}, nil
+}
+
+// GetFileMerged reads file.Path as the latest-wins merge of file.Commit and
+// mergeCommits. The content comes from whichever commit changed the path
+// most recently, going by when the change's commit finished, if the most
+// recent change is a delete the file isn't found.
+func (d *driver) GetFileMerged(file *pfs.File, mergeCommits []*pfs.Commit, filterShard *pfs.Shard, offset int64,
+	size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	reader, err := d.getFileMerged(file, mergeCommits, filterShard, offset, size, shard, unsafe, handle)
+	return scheduledRead(reader, err, release)
+}
+
+func (d *driver) getFileMerged(file *pfs.File, mergeCommits []*pfs.Commit, filterShard *pfs.Shard, offset int64,
+	size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	var newest *pfs.Commit
+	var newestModified time.Time
+	var newestDeleted bool
+	for _, commit := range append([]*pfs.Commit{file.Commit}, mergeCommits...) {
+		modified, deleted, ok, err := d.lastChange(client.NewFile(commit.Repo.Name, commit.ID, file.Path), shard, unsafe)
+		if err != nil {
+			return nil, err
+		}
+		if ok && (newest == nil || modified.After(newestModified)) {
+			newest = commit
+			newestModified = modified
+			newestDeleted = deleted
+		}
+	}
+	if newest == nil || newestDeleted {
+		return nil, pfsserver.NewErrFileNotFound(file.Path, file.Commit.Repo.Name, file.Commit.ID)
+	}
+	return d.getFile(client.NewFile(newest.Repo.Name, newest.ID, file.Path), filterShard, offset, size, nil, shard, unsafe, handle)
+}
+
+func (d *driver) getFile(file *pfs.File, filterShard *pfs.Shard, offset int64,
+	size int64, from *pfs.Commit, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
 	fileInfo, blockRefs, err := d.inspectFile(file, filterShard, shard, from, false, unsafe, handle)
 	if err != nil {
 		return nil, err
@@ -618,19 +2677,233 @@ func (d *driver) GetFile(file *pfs.File, filterShard *pfs.Shard, offset int64,
 	if err != nil {
 		return nil, err
 	}
-	return newFileReader(blockClient, blockRefs, offset, size), nil
+	return newFileReader(blockClient, blockRefs, suffixOffset(offset, fileInfo.SizeBytes), size, d.options.VerifyBlocks), nil
+}
+
+// suffixOffset resolves a negative offset, which counts back from the end of
+// the file like an HTTP suffix range. A suffix longer than the file is the
+// whole file.
+func suffixOffset(offset int64, sizeBytes uint64) int64 {
+	if offset >= 0 {
+		return offset
+	}
+	offset += int64(sizeBytes)
+	if offset < 0 {
+		return 0
+	}
+	return offset
+}
+
+// GetFileDiff reads the content that file.Commit's own diff wrote to
+// file.Path, as opposed to the content of the file as of file.Commit.
+func (d *driver) GetFileDiff(file *pfs.File, filterShard *pfs.Shard, offset int64,
+	size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	reader, err := d.getFileDiff(file, filterShard, offset, size, shard, unsafe, handle)
+	return scheduledRead(reader, err, release)
+}
+
+func (d *driver) getFileDiff(file *pfs.File, filterShard *pfs.Shard, offset int64,
+	size int64, shard uint64, unsafe bool, handle string) (io.ReadCloser, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return nil, err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+	if !ok {
+		return nil, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+	}
+	if !unsafe && diffInfo.Finished == nil {
+		return nil, fmt.Errorf("commit %s/%s is open, its diff can only be read unsafe", commit.Repo.Name, commit.ID)
+	}
+	_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+	if ok && _append.FileType == pfs.FileType_FILE_TYPE_DIR {
+		return nil, fmt.Errorf("file %s/%s/%s is directory", file.Commit.Repo.Name, file.Commit.ID, file.Path)
+	}
+	// an append without a type is a delete, which wrote no content
+	if !ok || _append.FileType != pfs.FileType_FILE_TYPE_REGULAR || appendExpired(_append, time.Now()) ||
+		!pfsserver.FileInShard(filterShard, file) {
+		return nil, pfsserver.NewErrFileNotFound(file.Path, file.Commit.Repo.Name, file.Commit.ID)
+	}
+	blockRefs := filterBlockRefs(filterShard, _append.BlockRefs)
+	if handle == "" {
+		for _, handleBlockRefs := range _append.Handles {
+			blockRefs = append(blockRefs, filterBlockRefs(filterShard, handleBlockRefs.BlockRef)...)
+		}
+	} else if handleBlockRefs, ok := _append.Handles[handle]; ok {
+		blockRefs = append(blockRefs, filterBlockRefs(filterShard, handleBlockRefs.BlockRef)...)
+	}
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return nil, err
+	}
+	return newFileReader(blockClient, blockRefs, suffixOffset(offset, blockRefsSize(blockRefs)), size, d.options.VerifyBlocks), nil
 }
 
 func (d *driver) InspectFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, unsafe bool, handle string) (*pfs.FileInfo, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
 	d.lock.RLock()
 	defer d.lock.RUnlock()
 	fileInfo, _, err := d.inspectFile(file, filterShard, shard, from, false, unsafe, handle)
 	return fileInfo, err
 }
 
+// FileType returns the type of file, or FILE_TYPE_NONE if it doesn't exist.
+// It's cheaper than InspectFile because it stops at the most recent change to
+// file rather than reading all of its appends. If unsafe is set writes to open
+// commits are considered.
+func (d *driver) FileType(file *pfs.File, shard uint64, unsafe bool) (pfs.FileType, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return pfs.FileType_FILE_TYPE_NONE, err
+	}
+	now := time.Now()
+	for commit != nil {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return pfs.FileType_FILE_TYPE_NONE, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		if !unsafe && diffInfo.Finished == nil {
+			commit = diffInfo.ParentCommit
+			continue
+		}
+		if _append, ok := diffInfo.Appends[path.Clean(file.Path)]; ok {
+			// an expired append is a delete that hasn't been swept yet
+			if appendExpired(_append, now) {
+				return pfs.FileType_FILE_TYPE_NONE, nil
+			}
+			return _append.FileType, nil
+		}
+		commit = diffInfo.ParentCommit
+	}
+	return pfs.FileType_FILE_TYPE_NONE, nil
+}
+
 func (d *driver) ListFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.listFile(file, filterShard, from, shard, recurse, unsafe, handle)
+}
+
+// ListFileUnion lists every file that existed under file in any of the
+// commits from file.Commit back to from, inclusive, or in any of
+// file.Commit's ancestors if from is nil. For each file the most recent
+// FileInfo is returned with ExistedIn set to the commits it existed in.
+func (d *driver) ListFileUnion(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	return d.listFileUnion(file, filterShard, from, shard, recurse, unsafe, handle)
+}
+
+// ListFileWithDeleted is like ListFile except that it also returns the files
+// that a union listing would but that don't exist in file.Commit, i.e. the
+// ones that have been deleted, with Deleted set.
+func (d *driver) ListFileWithDeleted(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
 	d.lock.RLock()
 	defer d.lock.RUnlock()
+	// file itself may have been deleted, in which case everything that was
+	// under it is a tombstone
+	liveFileInfos, err := d.listFile(file, filterShard, nil, shard, recurse, unsafe, handle)
+	_, notFound := err.(*pfsserver.ErrFileNotFound)
+	if err != nil && !notFound {
+		return nil, err
+	}
+	notFoundErr := err
+	live := make(map[string]bool)
+	for _, fileInfo := range liveFileInfos {
+		live[path.Clean(fileInfo.File.Path)] = true
+	}
+	result := liveFileInfos
+	if from != nil && !notFound {
+		// files that are live but haven't changed since from aren't listed
+		if result, err = d.listFile(file, filterShard, from, shard, recurse, unsafe, handle); err != nil {
+			return nil, err
+		}
+	}
+	unionFileInfos, err := d.listFileUnion(file, filterShard, from, shard, recurse, unsafe, handle)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInfo := range unionFileInfos {
+		if live[path.Clean(fileInfo.File.Path)] {
+			continue
+		}
+		fileInfo.Deleted = true
+		result = append(result, fileInfo)
+	}
+	if len(result) == 0 && notFound {
+		return nil, notFoundErr
+	}
+	return result, nil
+}
+
+// listFileUnion assumes that the lock is being held
+func (d *driver) listFileUnion(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error) {
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return nil, err
+	}
+	if from != nil {
+		if from, err = d.canonicalCommit(from); err != nil {
+			return nil, err
+		}
+	}
+	fileInfos := make(map[string]*pfs.FileInfo)
+	var result []*pfs.FileInfo
+	for {
+		if commit == nil {
+			if from != nil {
+				return nil, fmt.Errorf("%s/%s is not an ancestor of %s/%s", from.Repo.Name, from.ID, file.Commit.Repo.Name, file.Commit.ID)
+			}
+			break
+		}
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return nil, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		// open commits don't have a state of their own unless we're unsafe
+		if unsafe || diffInfo.Finished != nil {
+			commitFileInfos, err := d.listFile(client.NewFile(commit.Repo.Name, commit.ID, file.Path),
+				filterShard, nil, shard, recurse, unsafe, handle)
+			if _, ok := err.(*pfsserver.ErrFileNotFound); err != nil && !ok {
+				return nil, err
+			}
+			for _, commitFileInfo := range commitFileInfos {
+				filePath := path.Clean(commitFileInfo.File.Path)
+				fileInfo, ok := fileInfos[filePath]
+				if !ok {
+					// we go from newest to oldest so the first one we see is
+					// the most recent
+					fileInfo = commitFileInfo
+					fileInfos[filePath] = fileInfo
+					result = append(result, fileInfo)
+				}
+				fileInfo.ExistedIn = append(fileInfo.ExistedIn, commit)
+			}
+		}
+		if from != nil && commit.ID == from.ID {
+			break
+		}
+		commit = diffInfo.ParentCommit
+	}
+	return result, nil
+}
+
+// listFile assumes that the lock is being held
+func (d *driver) listFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Commit, shard uint64, recurse bool, unsafe bool, handle string) ([]*pfs.FileInfo, error) {
 	fileInfo, _, err := d.inspectFile(file, filterShard, shard, from, false, unsafe, handle)
 	if err != nil {
 		return nil, err
@@ -656,6 +2929,13 @@ func (d *driver) ListFile(file *pfs.File, filterShard *pfs.Shard, from *pfs.Comm
 }
 
 func (d *driver) DeleteFile(file *pfs.File, shard uint64, unsafe bool, handle string) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	return d.deleteFileTree(file, shard, unsafe, handle)
+}
+
+// deleteFileTree deletes file and, if it's a directory, everything under it.
+func (d *driver) deleteFileTree(file *pfs.File, shard uint64, unsafe bool, handle string) error {
 	d.lock.RLock()
 	// We don't want to be able to delete files that are only added in the current
 	// commit, which is why we set unsafe to false.
@@ -664,10 +2944,18 @@ func (d *driver) DeleteFile(file *pfs.File, shard uint64, unsafe bool, handle st
 		d.lock.RUnlock()
 		return err
 	}
+	// we check the whole directory before deleting any of it, deleteFile
+	// checks again under the write lock in case SetImmutable runs in between
+	if err := d.checkMutable(file, shard, fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR); err != nil {
+		d.lock.RUnlock()
+		return err
+	}
 	d.lock.RUnlock()
 
 	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
-		fileInfos, err := d.ListFile(file, nil, nil, shard, false, unsafe, handle)
+		d.lock.RLock()
+		fileInfos, err := d.listFile(file, nil, nil, shard, false, unsafe, handle)
+		d.lock.RUnlock()
 		if err != nil {
 			return err
 		}
@@ -676,16 +2964,84 @@ func (d *driver) DeleteFile(file *pfs.File, shard uint64, unsafe bool, handle st
 			// We are deleting the file from the current commit, not whatever
 			// commit they were last modified in
 			info.File.Commit = file.Commit
-			if err := d.DeleteFile(info.File, shard, unsafe, handle); err != nil {
+			if err := d.deleteFileTree(info.File, shard, unsafe, handle); err != nil {
 				return err
 			}
 		}
 	}
 
-	return d.deleteFile(file, shard, unsafe, handle)
+	return d.deleteFile(file, shard, unsafe, handle, fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR)
+}
+
+// FilesExist returns whether each of files exists in shard. Unlike
+// inspectFile it stops at the most recent append to each file, it doesn't
+// gather the file's blocks or children.
+func (d *driver) FilesExist(files []*pfs.File, shard uint64, unsafe bool) ([]bool, error) {
+	release := d.scheduler.acquire(shard, shardRead)
+	defer release()
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	result := make([]bool, len(files))
+	for i, file := range files {
+		exists, err := d.fileExists(file, shard, unsafe)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = exists
+	}
+	return result, nil
+}
+
+// fileExists assumes that the lock is being held
+func (d *driver) fileExists(file *pfs.File, shard uint64, unsafe bool) (bool, error) {
+	now := time.Now()
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return false, err
+	}
+	for commit != nil {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return false, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		if !unsafe && diffInfo.Finished == nil {
+			commit = diffInfo.ParentCommit
+			continue
+		}
+		_append, ok := diffInfo.Appends[path.Clean(file.Path)]
+		if !ok {
+			commit = diffInfo.ParentCommit
+			continue
+		}
+		if appendExpired(_append, now) {
+			return false, nil
+		}
+		if _append.FileType != pfs.FileType_FILE_TYPE_NONE {
+			return true, nil
+		}
+		if _append.Delete {
+			return false, nil
+		}
+		commit = _append.LastRef
+	}
+	return false, nil
+}
+
+// DeleteFiles deletes each of files from shard, it returns an error for each
+// file, nil if the file was deleted.
+func (d *driver) DeleteFiles(files []*pfs.File, shard uint64, unsafe bool, handle string) []error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
+	errs := make([]error, len(files))
+	for i, file := range files {
+		errs[i] = d.deleteFileTree(file, shard, unsafe, handle)
+	}
+	return errs
 }
 
-func (d *driver) deleteFile(file *pfs.File, shard uint64, unsafe bool, handle string) error {
+// deleteFile deletes file, recurse should be set if file is a directory so
+// that the files under it are checked for immutability.
+func (d *driver) deleteFile(file *pfs.File, shard uint64, unsafe bool, handle string, recurse bool) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	canonicalCommit, err := d.canonicalCommit(file.Commit)
@@ -699,9 +3055,16 @@ func (d *driver) deleteFile(file *pfs.File, shard uint64, unsafe bool, handle st
 		return pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
 	}
 	if diffInfo.Finished != nil {
-		return fmt.Errorf("commit %s/%s has already been finished", canonicalCommit.Repo.Name, canonicalCommit.ID)
+		return pfsserver.NewErrCommitFinished(canonicalCommit.Repo.Name, canonicalCommit.ID)
+	}
+	if err := d.checkMutable(file, shard, recurse); err != nil {
+		return err
 	}
 
+	// Deletes are recorded as tombstones (Append.Delete) in the commit's diff
+	// rather than by removing anything, this way they're persisted by
+	// CreateDiff along with the rest of the commit and get applied again when
+	// a shard is rebuilt from ListDiff in AddShard.
 	cleanPath := path.Clean(file.Path)
 	if _append, ok := diffInfo.Appends[cleanPath]; !ok {
 		// we have no append for this file, we create on so that we can set the
@@ -720,12 +3083,16 @@ func (d *driver) deleteFile(file *pfs.File, shard uint64, unsafe bool, handle st
 	} else {
 		diffInfo.Appends[cleanPath].HandleDeletes[handle] = true
 	}
+	d.blockIndex.indexFile(diffInfo, cleanPath)
 	d.deleteFromDir(diffInfo, file, shard)
+	d.dirtyDiffs[diffInfo] = true
 
 	return nil
 }
 
 func (d *driver) AddShard(shard uint64) error {
+	release := d.scheduler.acquire(shard, shardWrite)
+	defer release()
 	blockClient, err := d.getBlockClient()
 	if err != nil {
 		return err
@@ -747,6 +3114,17 @@ func (d *driver) AddShard(shard uint64) error {
 		if diffInfo.Diff == nil || diffInfo.Diff.Commit == nil || diffInfo.Diff.Commit.Repo == nil {
 			return fmt.Errorf("broken diff info: %v; this is likely a bug", diffInfo)
 		}
+		if d.options.VerifyDiffs && diffInfo.Checksum != "" {
+			checksum, err := diffInfoChecksum(diffInfo)
+			if err != nil {
+				return err
+			}
+			if checksum != diffInfo.Checksum {
+				err := pfsserver.NewErrDivergentData(diffInfo.Diff.Commit.Repo.Name, diffInfo.Diff.Commit.ID, shard)
+				protolion.Errorf("error adding shard %d: %s", shard, err.Error())
+				return err
+			}
+		}
 		repoName := diffInfo.Diff.Commit.Repo.Name
 		if _, ok := diffInfos[repoName]; !ok {
 			diffInfos[repoName] = make(map[uint64]map[string]*pfs.DiffInfo)
@@ -768,11 +3146,25 @@ func (d *driver) AddShard(shard uint64) error {
 		for _, commitID := range dag.Sorted() {
 			d.createRepoState(client.NewRepo(repoName))
 			if diffInfo, ok := diffInfos.get(client.NewDiff(repoName, commitID, shard)); ok {
-				if err := d.insertDiffInfo(diffInfo); err != nil {
-					return err
+				if commitID == "" {
+					// the repo's diff isn't a commit so it doesn't belong
+					// in the DAG, see CreateRepo
+					if err := d.diffs.insert(diffInfo); err != nil {
+						return err
+					}
+					continue
 				}
 				if diffInfo.Finished == nil {
-					return fmt.Errorf("diff %s/%s/%d is not finished; this is likely a bug", repoName, commitID, shard)
+					// this is an open commit that was flushed before it was
+					// finished, it can be written to and finished as usual
+					initOpenDiffInfo(diffInfo)
+					if _, ok := d.commitConds[commitID]; !ok {
+						d.commitConds[commitID] = sync.NewCond(&d.lock)
+					}
+					d.unflushedBytes[shard] += diffInfo.SizeBytes
+				}
+				if err := d.insertDiffInfo(diffInfo); err != nil {
+					return err
 				}
 			} else {
 				return fmt.Errorf("diff %s/%s/%d not found; this is likely a bug", repoName, commitID, shard)
@@ -786,8 +3178,97 @@ func (d *driver) DeleteShard(shard uint64) error {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 	for _, shardMap := range d.diffs {
+		for _, diffInfo := range shardMap[shard] {
+			delete(d.dirtyDiffs, diffInfo)
+		}
 		delete(shardMap, shard)
 	}
+	d.blockIndex.unindexShard(shard)
+	// the shard's open commits may still be open in our other shards
+	d.openCommits = make(map[string]map[string]bool)
+	for _, shardMap := range d.diffs {
+		for _, commitToDiffInfo := range shardMap {
+			for _, diffInfo := range commitToDiffInfo {
+				d.addOpenCommit(diffInfo)
+			}
+		}
+	}
+	delete(d.unflushedBytes, shard)
+	delete(d.stagedBlobs, shard)
+	d.scrubLock.Lock()
+	defer d.scrubLock.Unlock()
+	delete(d.corruptBlocks, shard)
+	return nil
+}
+
+// DumpShard returns copies of the diffs in shard. They're grouped by repo,
+// with each repo's own diff first followed by its commits' diffs, parents
+// before children, this is an order in which they can be added back. If
+// commit is set only its diff is returned.
+func (d *driver) DumpShard(shard uint64, commit *pfs.Commit) ([]*pfs.DiffInfo, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	if commit != nil {
+		canonicalCommit, err := d.canonicalCommit(commit)
+		if err != nil {
+			return nil, err
+		}
+		diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+		if !ok {
+			return nil, pfsserver.NewErrCommitNotFound(canonicalCommit.Repo.Name, canonicalCommit.ID)
+		}
+		return []*pfs.DiffInfo{proto.Clone(diffInfo).(*pfs.DiffInfo)}, nil
+	}
+	var repoNames []string
+	for repoName := range d.diffs {
+		repoNames = append(repoNames, repoName)
+	}
+	sort.Strings(repoNames)
+	var result []*pfs.DiffInfo
+	for _, repoName := range repoNames {
+		commitToDiffInfo := d.diffs[repoName][shard]
+		if diffInfo, ok := commitToDiffInfo[""]; ok {
+			result = append(result, proto.Clone(diffInfo).(*pfs.DiffInfo))
+		}
+		for _, commitID := range d.dags[repoName].Sorted() {
+			if diffInfo, ok := commitToDiffInfo[commitID]; ok {
+				result = append(result, proto.Clone(diffInfo).(*pfs.DiffInfo))
+			}
+		}
+	}
+	return result, nil
+}
+
+// ShardStats returns storage stats for shard, computed from the diffs we
+// have in memory.
+func (d *driver) ShardStats(shard uint64) (*pfs.ShardStat, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	result := &pfs.ShardStat{
+		Shard:          shard,
+		UnflushedBytes: d.unflushedBytes[shard],
+	}
+	for _, shardToDiffInfo := range d.diffs {
+		for commitID, diffInfo := range shardToDiffInfo[shard] {
+			if commitID == "" {
+				continue
+			}
+			result.SizeBytes += diffInfo.SizeBytes
+			if diffInfo.Finished == nil {
+				result.OpenCommits++
+			}
+		}
+	}
+	result.Blocks = uint64(len(d.shardBlocks(shard)))
+	d.scrubLock.Lock()
+	defer d.scrubLock.Unlock()
+	result.CorruptBlocks = uint64(len(d.corruptBlocks[shard]))
+	result.WaitingOperations = uint64(d.scheduler.waiting(shard))
+	return result, nil
+}
+
+func (d *driver) Close() error {
+	d.closeOnce.Do(func() { close(d.closed) })
 	return nil
 }
 
@@ -809,6 +3290,247 @@ func (d *driver) Dump() {
 	}
 }
 
+// putApplied returns true if a put with idempotencyKey has already been
+// applied to file.
+// putApplied assumes that the lock is being held
+func (d *driver) putApplied(file *pfs.File, idempotencyKey string, shard uint64) (bool, error) {
+	if idempotencyKey == "" {
+		return false, nil
+	}
+	canonicalCommit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return false, err
+	}
+	diffInfo, ok := d.diffs.get(client.NewDiff(canonicalCommit.Repo.Name, canonicalCommit.ID, shard))
+	if !ok {
+		return false, nil
+	}
+	return keyApplied(diffInfo, file, idempotencyKey), nil
+}
+
+// keyApplied returns true if diffInfo has a put to file with idempotencyKey.
+func keyApplied(diffInfo *pfs.DiffInfo, file *pfs.File, idempotencyKey string) bool {
+	keys, ok := diffInfo.IdempotencyKeys[path.Clean(file.Path)]
+	return ok && keys.Key[idempotencyKey]
+}
+
+// checkUnflushedBytes returns a ResourceExhausted error if the open commits
+// in shard have more unflushed data than we allow.
+// checkUnflushedBytes assumes that the lock is being held
+func (d *driver) checkUnflushedBytes(shard uint64) error {
+	if d.options.MaxUnflushedBytes == 0 {
+		return nil
+	}
+	if d.unflushedBytes[shard] >= d.options.MaxUnflushedBytes {
+		return grpcErrorf(codes.ResourceExhausted,
+			"shard %d has %d unflushed bytes which exceeds the limit of %d, finish open commits before writing more data",
+			shard, d.unflushedBytes[shard], d.options.MaxUnflushedBytes)
+	}
+	return nil
+}
+
+// checkOpenCommits returns an error if repo has as many open commits as
+// it's allowed.
+// checkOpenCommits assumes that the lock is being held
+func (d *driver) checkOpenCommits(repo *pfs.Repo) error {
+	if d.options.MaxOpenCommitsPerRepo == 0 {
+		return nil
+	}
+	if uint64(len(d.openCommits[repo.Name])) >= d.options.MaxOpenCommitsPerRepo {
+		return grpcErrorf(codes.ResourceExhausted,
+			"repo %s has too many open commits, the limit is %d, finish open commits before starting more",
+			repo.Name, d.options.MaxOpenCommitsPerRepo)
+	}
+	return nil
+}
+
+// releaseUnflushedBytes assumes that the lock is being held
+func (d *driver) releaseUnflushedBytes(shard uint64, sizeBytes uint64) {
+	if d.unflushedBytes[shard] <= sizeBytes {
+		delete(d.unflushedBytes, shard)
+		return
+	}
+	d.unflushedBytes[shard] -= sizeBytes
+}
+
+// persistDiffInfos writes diffInfos to the block server
+func (d *driver) persistDiffInfos(diffInfos []*pfs.DiffInfo) error {
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	for _, diffInfo := range diffInfos {
+		diffInfo := diffInfo
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := createDiff(blockClient, diffInfo); err != nil {
+				select {
+				case errCh <- err:
+				default:
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	return nil
+}
+
+// createDiff persists diffInfo along with its checksum.
+func createDiff(blockClient pfs.BlockAPIClient, diffInfo *pfs.DiffInfo) error {
+	checksum, err := diffInfoChecksum(diffInfo)
+	if err != nil {
+		return err
+	}
+	// we don't set the checksum on diffInfo itself since it may be written
+	// to once we let go of it
+	persisted := *diffInfo
+	persisted.Checksum = checksum
+	_, err = blockClient.CreateDiff(context.Background(), &persisted)
+	return err
+}
+
+// diffInfoChecksum returns a hash of everything in diffInfo except its
+// checksum. Maps are marshalled in random order so we hash their entries
+// sorted by key.
+func diffInfoChecksum(diffInfo *pfs.DiffInfo) (string, error) {
+	hash := sha256.New()
+	writeUvarint := func(n uint64) {
+		var buf [binary.MaxVarintLen64]byte
+		hash.Write(buf[:binary.PutUvarint(buf[:], n)])
+	}
+	write := func(data []byte) {
+		writeUvarint(uint64(len(data)))
+		hash.Write(data)
+	}
+	writeBool := func(b bool) {
+		if b {
+			writeUvarint(1)
+		} else {
+			writeUvarint(0)
+		}
+	}
+	marshal := func(message proto.Message) error {
+		data, err := proto.Marshal(message)
+		if err != nil {
+			return err
+		}
+		write(data)
+		return nil
+	}
+	writeStrings := func(m map[string]string) {
+		var keys []string
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		writeUvarint(uint64(len(keys)))
+		for _, key := range keys {
+			write([]byte(key))
+			write([]byte(m[key]))
+		}
+	}
+	header := *diffInfo
+	header.Appends = nil
+	header.Annotations = nil
+	header.Checksum = ""
+	if err := marshal(&header); err != nil {
+		return "", err
+	}
+	// diffs without annotations hash the way they did before annotations
+	// existed, so their persisted checksums still match
+	if len(diffInfo.Annotations) > 0 {
+		writeStrings(diffInfo.Annotations)
+	}
+	var paths []string
+	for filePath := range diffInfo.Appends {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+	writeUvarint(uint64(len(paths)))
+	for _, filePath := range paths {
+		_append := diffInfo.Appends[filePath]
+		write([]byte(filePath))
+		flat := *_append
+		flat.Children = nil
+		flat.Handles = nil
+		flat.HandleDeletes = nil
+		flat.Xattrs = nil
+		if err := marshal(&flat); err != nil {
+			return "", err
+		}
+		children := sortedKeys(_append.Children)
+		writeUvarint(uint64(len(children)))
+		for _, child := range children {
+			write([]byte(child))
+			writeBool(_append.Children[child])
+		}
+		var handles []string
+		for handle := range _append.Handles {
+			handles = append(handles, handle)
+		}
+		sort.Strings(handles)
+		writeUvarint(uint64(len(handles)))
+		for _, handle := range handles {
+			write([]byte(handle))
+			if err := marshal(_append.Handles[handle]); err != nil {
+				return "", err
+			}
+		}
+		handleDeletes := sortedKeys(_append.HandleDeletes)
+		writeUvarint(uint64(len(handleDeletes)))
+		for _, handle := range handleDeletes {
+			write([]byte(handle))
+			writeBool(_append.HandleDeletes[handle])
+		}
+		writeStrings(_append.Xattrs)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// repoProvenanceEqual returns true if the provenance repo was created with
+// is the same set of repos as provenance.
+// repoProvenanceEqual assumes that the lock is being held
+func (d *driver) repoProvenanceEqual(repo *pfs.Repo, provenance []*pfs.Repo, shards map[uint64]bool) bool {
+	for shard := range shards {
+		diffInfo, ok := d.diffs.get(client.NewDiff(repo.Name, "", shard))
+		if !ok {
+			continue
+		}
+		existing := make(map[string]bool)
+		for _, provCommit := range diffInfo.Provenance {
+			existing[provCommit.Repo.Name] = true
+		}
+		requested := make(map[string]bool)
+		for _, provRepo := range provenance {
+			if !existing[provRepo.Name] {
+				return false
+			}
+			requested[provRepo.Name] = true
+		}
+		return len(requested) == len(existing)
+	}
+	// we don't have any of the repo's diffs so there's nothing to compare
+	return true
+}
+
 // inspectRepo assumes that the lock is being held
 func (d *driver) inspectRepo(repo *pfs.Repo, shards map[uint64]bool) (*pfs.RepoInfo, error) {
 	result := &pfs.RepoInfo{
@@ -916,6 +3638,9 @@ func (d *driver) inspectCommit(commit *pfs.Commit, shards map[uint64]bool) (*pfs
 		commitInfo.Finished = diffInfo.Finished
 		commitInfo.SizeBytes = diffInfo.SizeBytes
 		commitInfo.Cancelled = diffInfo.Cancelled
+		commitInfo.Deleted = diffInfo.Deleted
+		commitInfo.Description = diffInfo.Description
+		commitInfo.Annotations = diffInfo.Annotations
 		commitInfos = append(commitInfos, commitInfo)
 	}
 	commitInfo := pfsserver.ReduceCommitInfos(commitInfos)
@@ -966,6 +3691,40 @@ func (d *driver) getFileType(file *pfs.File, shard uint64) (pfs.FileType, error)
 	return pfs.FileType_FILE_TYPE_NONE, nil
 }
 
+// lastChange finds the most recent change to file in file.Commit and its
+// ancestors. It returns when the change's commit finished, or started if it's
+// still open, and whether the change deleted the file. ok is false if the
+// file has never been changed.
+func (d *driver) lastChange(file *pfs.File, shard uint64, unsafe bool) (time.Time, bool, bool, error) {
+	commit, err := d.canonicalCommit(file.Commit)
+	if err != nil {
+		return time.Time{}, false, false, err
+	}
+	now := time.Now()
+	for commit != nil {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return time.Time{}, false, false, pfsserver.NewErrCommitNotFound(commit.Repo.Name, commit.ID)
+		}
+		if !unsafe && diffInfo.Finished == nil {
+			commit = diffInfo.ParentCommit
+			continue
+		}
+		if _append, ok := diffInfo.Appends[path.Clean(file.Path)]; ok {
+			modified := diffInfo.Finished
+			if modified == nil {
+				modified = diffInfo.Started
+			}
+			// an append with no file type is a delete, an expired append is a
+			// delete that hasn't been swept yet
+			deleted := _append.FileType == pfs.FileType_FILE_TYPE_NONE || appendExpired(_append, now)
+			return prototime.TimestampToTime(modified), deleted, true, nil
+		}
+		commit = diffInfo.ParentCommit
+	}
+	return time.Time{}, false, false, nil
+}
+
 // If recurse is set to true, and if the file being inspected is a directory,
 // its children will have the correct sizes.  If recurse is false and the file
 // is a directory, its children will have size of 0.
@@ -974,8 +3733,10 @@ func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint6
 	from *pfs.Commit, recurse bool, unsafe bool, handle string) (*pfs.FileInfo, []*pfs.BlockRef, error) {
 	fileInfo := &pfs.FileInfo{File: file}
 	var blockRefs []*pfs.BlockRef
+	now := time.Now()
 	children := make(map[string]bool)
 	deletedChildren := make(map[string]bool)
+	xattrs := make(map[string]string)
 	commit, err := d.canonicalCommit(file.Commit)
 	if err != nil {
 		return nil, nil, err
@@ -990,6 +3751,10 @@ func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint6
 			continue
 		}
 		if _append, ok := diffInfo.Appends[path.Clean(file.Path)]; ok {
+			// An expired append is a delete that hasn't been swept yet
+			if appendExpired(_append, now) {
+				break
+			}
 			if _append.FileType == pfs.FileType_FILE_TYPE_NONE && !_append.Delete && len(_append.HandleDeletes) == 0 {
 				return nil, nil, fmt.Errorf("the append for %s has file type NONE, this is likely a bug", path.Clean(file.Path))
 			}
@@ -1021,6 +3786,14 @@ func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint6
 				for _, blockRef := range filtered {
 					fileInfo.SizeBytes += (blockRef.Range.Upper - blockRef.Range.Lower)
 				}
+				// we're walking back from the newest append so the first
+				// value we see for an attribute is the current one
+				for name, value := range _append.Xattrs {
+					if _, ok := xattrs[name]; !ok {
+						xattrs[name] = value
+					}
+				}
+				fileInfo.Immutable = fileInfo.Immutable || _append.Immutable
 			} else if _append.FileType == pfs.FileType_FILE_TYPE_DIR {
 				if fileInfo.FileType == pfs.FileType_FILE_TYPE_REGULAR {
 					return nil, nil,
@@ -1045,10 +3818,13 @@ func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint6
 									Commit: file.Commit,
 									Path:   child,
 								}, filterShard, shard, from, recurse, unsafe, handle)
-								if err != nil {
+								_, ok := err.(*pfsserver.ErrFileNotFound)
+								if err != nil && !ok {
 									return nil, nil, err
 								}
-								fileInfo.SizeBytes += childFileInfo.SizeBytes
+								if !ok {
+									fileInfo.SizeBytes += childFileInfo.SizeBytes
+								}
 							}
 						}
 					}
@@ -1071,10 +3847,42 @@ func (d *driver) inspectFile(file *pfs.File, filterShard *pfs.Shard, shard uint6
 	if fileInfo.FileType == pfs.FileType_FILE_TYPE_NONE {
 		return nil, nil, pfsserver.NewErrFileNotFound(file.Path, file.Commit.Repo.Name, file.Commit.ID)
 	}
+	for name, value := range xattrs {
+		if value == "" {
+			continue
+		}
+		if fileInfo.Xattrs == nil {
+			fileInfo.Xattrs = make(map[string]string)
+		}
+		fileInfo.Xattrs[name] = value
+	}
 	return fileInfo, blockRefs, nil
 }
 
 // lastRef assumes the diffInfo file exists in finished
+// inheritsExpiry returns true if file's content as of file.Commit comes from
+// an append that has an expiry.
+// inheritsExpiry assumes that the lock is being held
+func (d *driver) inheritsExpiry(file *pfs.File, shard uint64) bool {
+	commit := file.Commit
+	for commit != nil {
+		diffInfo, ok := d.diffs.get(client.NewDiff(commit.Repo.Name, commit.ID, shard))
+		if !ok {
+			return false
+		}
+		if _append, ok := diffInfo.Appends[path.Clean(file.Path)]; ok {
+			if _append.Expires != nil {
+				return true
+			}
+			if _append.Delete {
+				return false
+			}
+		}
+		commit = diffInfo.ParentCommit
+	}
+	return false
+}
+
 func (d *driver) lastRef(file *pfs.File, shard uint64) *pfs.Commit {
 	commit := file.Commit
 	for commit != nil {
@@ -1134,6 +3942,19 @@ func (d *driver) branchParent(commit *pfs.Commit, branch string) (*pfs.Commit, e
 	return canonicalCommit, nil
 }
 
+// addOpenCommit adds diffInfo's commit to openCommits if it's open.
+// addOpenCommit assumes that the lock is being held
+func (d *driver) addOpenCommit(diffInfo *pfs.DiffInfo) {
+	commit := diffInfo.Diff.Commit
+	if commit.ID == "" || diffInfo.Finished != nil {
+		return
+	}
+	if d.openCommits[commit.Repo.Name] == nil {
+		d.openCommits[commit.Repo.Name] = make(map[string]bool)
+	}
+	d.openCommits[commit.Repo.Name][commit.ID] = true
+}
+
 func (d *driver) insertDiffInfo(diffInfo *pfs.DiffInfo) error {
 	commit := diffInfo.Diff.Commit
 	updateIndexes := true
@@ -1146,6 +3967,8 @@ func (d *driver) insertDiffInfo(diffInfo *pfs.DiffInfo) error {
 	if err := d.diffs.insert(diffInfo); err != nil {
 		return err
 	}
+	d.blockIndex.indexDiff(diffInfo)
+	d.addOpenCommit(diffInfo)
 	if updateIndexes {
 		if diffInfo.Branch != "" {
 			if _, ok := d.diffs[commit.Repo.Name][diffInfo.Diff.Shard][diffInfo.Branch]; ok {
@@ -1175,6 +3998,10 @@ func (d *driver) addDirs(diffInfo *pfs.DiffInfo, child *pfs.File, shard uint64)
 			_append = newAppend(pfs.FileType_FILE_TYPE_DIR)
 			diffInfo.Appends[dirPath] = _append
 		}
+		if _append.FileType == pfs.FileType_FILE_TYPE_NONE {
+			// the dir was deleted earlier in this commit
+			_append.FileType = pfs.FileType_FILE_TYPE_DIR
+		}
 		if _append.Children == nil {
 			_append.Children = make(map[string]bool)
 		}
@@ -1222,45 +4049,122 @@ func (d *driver) deleteFromDir(diffInfo *pfs.DiffInfo, child *pfs.File, shard ui
 
 type fileReader struct {
 	blockClient pfs.BlockAPIClient
-	blockRefs   []*pfs.BlockRef
+	blockRanges []*blockRange
 	index       int
 	reader      io.Reader
-	offset      int64
-	size        int64
-	ctx         context.Context
-	cancel      context.CancelFunc
+	// verify makes the reader read each block whole and check it against
+	// its hash before returning any of it
+	verify bool
+}
+
+// blockRange is a range of bytes within a block
+type blockRange struct {
+	hash   string
+	offset uint64
+	size   uint64
+	// data is the block's content if it's stored inline
+	data []byte
 }
 
-func newFileReader(blockClient pfs.BlockAPIClient, blockRefs []*pfs.BlockRef, offset int64, size int64) *fileReader {
+func newFileReader(blockClient pfs.BlockAPIClient, blockRefs []*pfs.BlockRef, offset int64, size int64, verify bool) *fileReader {
 	return &fileReader{
 		blockClient: blockClient,
-		blockRefs:   blockRefs,
-		offset:      offset,
-		size:        size,
+		blockRanges: getBlockRanges(blockRefs, uint64(offset), uint64(size)),
+		verify:      verify,
+	}
+}
+
+// sliceBlockRefs returns block refs for the bytes in [lower, upper) of the
+// data referred to by blockRefs
+func sliceBlockRefs(blockRefs []*pfs.BlockRef, lower uint64, upper uint64) []*pfs.BlockRef {
+	if upper <= lower {
+		return nil
+	}
+	var result []*pfs.BlockRef
+	for _, blockRange := range getBlockRanges(blockRefs, lower, upper-lower) {
+		result = append(result, &pfs.BlockRef{
+			Block: &pfs.Block{Hash: blockRange.hash},
+			Range: &pfs.ByteRange{Lower: blockRange.offset, Upper: blockRange.offset + blockRange.size},
+			Data:  blockRange.data,
+		})
+	}
+	return result
+}
+
+func blockRefsSize(blockRefs []*pfs.BlockRef) uint64 {
+	var result uint64
+	for _, blockRef := range blockRefs {
+		result += pfsserver.ByteRangeSize(blockRef.Range)
+	}
+	return result
+}
+
+// zeroReader is an endless stream of zeros
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
 	}
+	return len(p), nil
 }
 
-func (r *fileReader) blockRef() *pfs.BlockRef {
-	return r.blockRefs[r.index]
+// getBlockRanges maps a range of bytes in a file to the ranges of the
+// file's blocks that contain it, blocks that are entirely outside the range
+// are skipped so reading a small range of a big file only touches the blocks
+// it needs.
+func getBlockRanges(blockRefs []*pfs.BlockRef, offset uint64, size uint64) []*blockRange {
+	var result []*blockRange
+	for _, blockRef := range blockRefs {
+		if size == 0 {
+			break
+		}
+		blockRefSize := pfsserver.ByteRangeSize(blockRef.Range)
+		if offset >= blockRefSize {
+			offset -= blockRefSize
+			continue
+		}
+		readSize := blockRefSize - offset
+		if readSize > size {
+			readSize = size
+		}
+		result = append(result, &blockRange{
+			hash:   blockRef.Block.Hash,
+			offset: blockRef.Range.Lower + offset,
+			size:   readSize,
+			data:   blockRef.Data,
+		})
+		offset = 0
+		size -= readSize
+	}
+	return result
 }
 
 func (r *fileReader) Read(data []byte) (int, error) {
 	if r.reader == nil {
-		// skip blocks as long as our offset is past the end of the current block
-		for r.offset != 0 && r.index < len(r.blockRefs) && r.offset >= int64(pfsserver.ByteRangeSize(r.blockRef().Range)) {
-			r.offset -= int64(pfsserver.ByteRangeSize(r.blockRef().Range))
-			r.index++
-		}
-		if r.index == len(r.blockRefs) {
+		if r.index == len(r.blockRanges) {
 			return 0, io.EOF
 		}
-		var err error
-		client := client.APIClient{BlockAPIClient: r.blockClient}
-		r.reader, err = client.GetBlock(r.blockRef().Block.Hash, uint64(r.offset), uint64(r.size))
-		if err != nil {
-			return 0, err
+		blockRange := r.blockRanges[r.index]
+		if blockRange.data != nil {
+			r.reader = bytes.NewReader(blockRange.data[blockRange.offset : blockRange.offset+blockRange.size])
+		} else if r.verify {
+			data, err := readVerifiedBlock(r.blockClient, blockRange.hash)
+			if err != nil {
+				return 0, err
+			}
+			if uint64(len(data)) < blockRange.offset+blockRange.size {
+				return 0, pfsserver.NewErrBlockCorrupt(blockRange.hash)
+			}
+			r.reader = bytes.NewReader(data[blockRange.offset : blockRange.offset+blockRange.size])
+		} else {
+			var err error
+			client := client.APIClient{BlockAPIClient: r.blockClient}
+			r.reader, err = client.GetBlock(blockRange.hash, blockRange.offset, blockRange.size)
+			if err != nil {
+				return 0, err
+			}
 		}
-		r.offset = 0
 		r.index++
 	}
 	size, err := r.reader.Read(data)
@@ -1269,15 +4173,29 @@ func (r *fileReader) Read(data []byte) (int, error) {
 	}
 	if err == io.EOF {
 		r.reader = nil
+		if size == 0 {
+			return r.Read(data)
+		}
+	}
+	return size, nil
+}
+
+// readVerifiedBlock reads the whole of a block and checks that it hashes to
+// hash.
+func readVerifiedBlock(blockClient pfs.BlockAPIClient, hash string) ([]byte, error) {
+	client := client.APIClient{BlockAPIClient: blockClient}
+	reader, err := client.GetBlock(hash, 0, 0)
+	if err != nil {
+		return nil, err
 	}
-	r.size -= int64(size)
-	if r.size == 0 {
-		return size, io.EOF
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
 	}
-	if r.size < 0 {
-		return 0, fmt.Errorf("read more than we need; this is likely a bug")
+	if blockHash(data) != hash {
+		return nil, pfsserver.NewErrBlockCorrupt(hash)
 	}
-	return size, nil
+	return data, nil
 }
 
 func (r *fileReader) Close() error {
@@ -1345,6 +4263,16 @@ func coalesceHandles(_append *pfs.Append) {
 	_append.HandleDeletes = nil
 }
 
+// setXattrs records xattrs in _append, overriding any it already has.
+func setXattrs(_append *pfs.Append, xattrs map[string]string) {
+	for name, value := range xattrs {
+		if _append.Xattrs == nil {
+			_append.Xattrs = make(map[string]string)
+		}
+		_append.Xattrs[name] = value
+	}
+}
+
 func repoSet(repos []*pfs.Repo) map[string]bool {
 	result := make(map[string]bool)
 	for _, repo := range repos {
@@ -1368,3 +4296,19 @@ func newAppend(filetype pfs.FileType) *pfs.Append {
 		FileType:      filetype,
 	}
 }
+
+// initOpenDiffInfo restores the empty maps that an open commit's diff needs
+// to be written to, they don't survive being marshalled.
+func initOpenDiffInfo(diffInfo *pfs.DiffInfo) {
+	if diffInfo.Appends == nil {
+		diffInfo.Appends = make(map[string]*pfs.Append)
+	}
+	for _, _append := range diffInfo.Appends {
+		if _append.Handles == nil {
+			_append.Handles = make(map[string]*pfs.BlockRefs)
+		}
+		if _append.HandleDeletes == nil {
+			_append.HandleDeletes = make(map[string]bool)
+		}
+	}
+}
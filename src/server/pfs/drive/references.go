@@ -0,0 +1,125 @@
+package drive
+
+import (
+	"github.com/pachyderm/pachyderm/src/client"
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// blockReference is a file whose appends in a commit refer to a block.
+type blockReference struct {
+	repo   string
+	commit string
+	path   string
+	shard  uint64
+}
+
+// blockIndex maps the hashes of blocks to the files that refer to them. It's
+// protected by the driver's lock and updated along with the appends, every
+// change to an append's block refs has to be followed by indexFile.
+type blockIndex struct {
+	references map[string]map[blockReference]bool
+	// blocks is the hashes of the blocks that each file refers to, so that
+	// its references can be found when it changes
+	blocks map[blockReference]map[string]bool
+}
+
+// indexFile replaces the references of the file at filePath in diffInfo with
+// the ones in its current append, if it has one.
+func (i *blockIndex) indexFile(diffInfo *pfs.DiffInfo, filePath string) {
+	reference := blockReference{
+		repo:   diffInfo.Diff.Commit.Repo.Name,
+		commit: diffInfo.Diff.Commit.ID,
+		path:   filePath,
+		shard:  diffInfo.Diff.Shard,
+	}
+	i.unindex(reference)
+	_append, ok := diffInfo.Appends[filePath]
+	if !ok || reference.commit == "" {
+		return
+	}
+	if i.references == nil {
+		i.references = make(map[string]map[blockReference]bool)
+		i.blocks = make(map[blockReference]map[string]bool)
+	}
+	addBlockRefs := func(blockRefs []*pfs.BlockRef) {
+		for _, blockRef := range blockRefs {
+			if blockRef.Data != nil {
+				continue
+			}
+			hash := blockRef.Block.Hash
+			if i.references[hash] == nil {
+				i.references[hash] = make(map[blockReference]bool)
+			}
+			i.references[hash][reference] = true
+			if i.blocks[reference] == nil {
+				i.blocks[reference] = make(map[string]bool)
+			}
+			i.blocks[reference][hash] = true
+		}
+	}
+	addBlockRefs(_append.BlockRefs)
+	for _, blockRefs := range _append.Handles {
+		addBlockRefs(blockRefs.BlockRef)
+	}
+}
+
+// indexDiff adds the references of every file in diffInfo.
+func (i *blockIndex) indexDiff(diffInfo *pfs.DiffInfo) {
+	for filePath := range diffInfo.Appends {
+		i.indexFile(diffInfo, filePath)
+	}
+}
+
+// unindexDiff removes the references of every file in diffInfo.
+func (i *blockIndex) unindexDiff(diffInfo *pfs.DiffInfo) {
+	i.unindexIf(func(reference blockReference) bool {
+		return reference.repo == diffInfo.Diff.Commit.Repo.Name &&
+			reference.commit == diffInfo.Diff.Commit.ID &&
+			reference.shard == diffInfo.Diff.Shard
+	})
+}
+
+// unindexRepo removes the references of every file in repoName.
+func (i *blockIndex) unindexRepo(repoName string) {
+	i.unindexIf(func(reference blockReference) bool { return reference.repo == repoName })
+}
+
+// unindexShard removes the references of every file in shard.
+func (i *blockIndex) unindexShard(shard uint64) {
+	i.unindexIf(func(reference blockReference) bool { return reference.shard == shard })
+}
+
+func (i *blockIndex) unindexIf(f func(reference blockReference) bool) {
+	for reference := range i.blocks {
+		if f(reference) {
+			i.unindex(reference)
+		}
+	}
+}
+
+func (i *blockIndex) unindex(reference blockReference) {
+	for hash := range i.blocks[reference] {
+		delete(i.references[hash], reference)
+		if len(i.references[hash]) == 0 {
+			delete(i.references, hash)
+		}
+	}
+	delete(i.blocks, reference)
+}
+
+// BlockReferences returns the files in shards whose appends refer to block,
+// i.e. the commits in which block was written to each file. A file that
+// still has block's content in a later commit isn't returned for that
+// commit, it refers to the block through its parent. Inline data isn't on
+// the block server so it isn't returned.
+func (d *driver) BlockReferences(block *pfs.Block, shards map[uint64]bool) ([]*pfs.File, error) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	var result []*pfs.File
+	for reference := range d.blockIndex.references[block.Hash] {
+		if shards[reference.shard] {
+			result = append(result, client.NewFile(reference.repo, reference.commit, reference.path))
+		}
+	}
+	return result, nil
+}
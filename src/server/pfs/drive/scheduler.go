@@ -0,0 +1,179 @@
+package drive
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// ShardSchedulePolicy decides which of the operations waiting on a shard
+// runs next once the shard is running Options.MaxShardOperations at once.
+type ShardSchedulePolicy int
+
+const (
+	// ShardScheduleFIFO runs waiting operations in the order they arrived.
+	ShardScheduleFIFO ShardSchedulePolicy = iota
+	// ShardScheduleReadPriority runs waiting reads before waiting writes,
+	// it's meant for nodes that are serving traffic.
+	ShardScheduleReadPriority
+	// ShardScheduleWritePriority runs waiting writes before waiting reads,
+	// it's meant for nodes that are catching up on writes.
+	ShardScheduleWritePriority
+)
+
+// ParseShardSchedulePolicy parses "fifo", "read" or "write" into a
+// ShardSchedulePolicy, "" is the same as "fifo".
+func ParseShardSchedulePolicy(s string) (ShardSchedulePolicy, error) {
+	switch s {
+	case "", "fifo":
+		return ShardScheduleFIFO, nil
+	case "read":
+		return ShardScheduleReadPriority, nil
+	case "write":
+		return ShardScheduleWritePriority, nil
+	default:
+		return ShardScheduleFIFO, fmt.Errorf("unknown shard schedule policy %q, expected fifo, read or write", s)
+	}
+}
+
+type shardOp int
+
+const (
+	shardRead shardOp = iota
+	shardWrite
+)
+
+type shardWaiter struct {
+	op    shardOp
+	ready chan struct{}
+}
+
+type shardQueue struct {
+	running int
+	waiters []*shardWaiter
+}
+
+// shardScheduler limits the number of operations running on each shard and
+// decides which of the waiting operations runs next according to a policy.
+// A preferred operation always goes before the others so a steady stream of
+// them can starve the others.
+type shardScheduler struct {
+	limit  int
+	policy ShardSchedulePolicy
+	lock   sync.Mutex
+	queues map[uint64]*shardQueue
+}
+
+func newShardScheduler(limit int, policy ShardSchedulePolicy) *shardScheduler {
+	return &shardScheduler{
+		limit:  limit,
+		policy: policy,
+		queues: make(map[uint64]*shardQueue),
+	}
+}
+
+// acquire blocks until op may run on shard and returns a function that must
+// be called once op is done, calling it more than once is harmless.
+func (s *shardScheduler) acquire(shard uint64, op shardOp) func() {
+	if s.limit <= 0 {
+		return func() {}
+	}
+	var once sync.Once
+	release := func() { once.Do(func() { s.release(shard) }) }
+	s.lock.Lock()
+	queue, ok := s.queues[shard]
+	if !ok {
+		queue = &shardQueue{}
+		s.queues[shard] = queue
+	}
+	if queue.running < s.limit && len(queue.waiters) == 0 {
+		queue.running++
+		s.lock.Unlock()
+		return release
+	}
+	waiter := &shardWaiter{op: op, ready: make(chan struct{})}
+	queue.waiters = append(queue.waiters, waiter)
+	s.lock.Unlock()
+	<-waiter.ready
+	return release
+}
+
+// acquireAll is like acquire for an operation that runs on all of shards,
+// it acquires them in order so that two such operations can't deadlock.
+func (s *shardScheduler) acquireAll(shards map[uint64]bool, op shardOp) func() {
+	var sortedShards []int
+	for shard := range shards {
+		sortedShards = append(sortedShards, int(shard))
+	}
+	sort.Ints(sortedShards)
+	var releases []func()
+	for _, shard := range sortedShards {
+		releases = append(releases, s.acquire(uint64(shard), op))
+	}
+	return func() {
+		for _, release := range releases {
+			release()
+		}
+	}
+}
+
+// waiting returns the number of operations waiting on shard.
+func (s *shardScheduler) waiting(shard uint64) int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if queue, ok := s.queues[shard]; ok {
+		return len(queue.waiters)
+	}
+	return 0
+}
+
+func (s *shardScheduler) release(shard uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	queue := s.queues[shard]
+	if len(queue.waiters) == 0 {
+		queue.running--
+		if queue.running == 0 {
+			delete(s.queues, shard)
+		}
+		return
+	}
+	// the slot is handed straight to the next waiter so running is
+	// unchanged
+	i := s.next(queue.waiters)
+	waiter := queue.waiters[i]
+	queue.waiters = append(queue.waiters[:i], queue.waiters[i+1:]...)
+	close(waiter.ready)
+}
+
+// next returns the index of the waiter that should run next.
+func (s *shardScheduler) next(waiters []*shardWaiter) int {
+	var preferred shardOp
+	switch s.policy {
+	case ShardScheduleReadPriority:
+		preferred = shardRead
+	case ShardScheduleWritePriority:
+		preferred = shardWrite
+	default:
+		return 0
+	}
+	for i, waiter := range waiters {
+		if waiter.op == preferred {
+			return i
+		}
+	}
+	return 0
+}
+
+// scheduledReadCloser holds on to a read's slot until it's closed since the
+// blocks are only read once the caller reads from it.
+type scheduledReadCloser struct {
+	io.ReadCloser
+	release func()
+}
+
+func (r *scheduledReadCloser) Close() error {
+	defer r.release()
+	return r.ReadCloser.Close()
+}
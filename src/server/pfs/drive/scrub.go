@@ -0,0 +1,122 @@
+package drive
+
+import (
+	"time"
+
+	"go.pedge.io/lion/proto"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// scrubForever scrubs the blocks every ScrubInterval, until the driver is
+// closed.
+func (d *driver) scrubForever() {
+	d.forever(d.options.ScrubInterval, func() {
+		if err := d.scrub(); err != nil {
+			protolion.Errorf("error scrubbing blocks: %s", err.Error())
+		}
+	})
+}
+
+// scrub reads every block referenced by the shards we have and checks it
+// against its hash, the blocks it finds corrupt, missing or otherwise
+// unreadable are reported by ShardStats until a later scrub reads them
+// intact. Blocks are read at no more than ScrubBytesPerSecond and the lock
+// isn't held while they're read. Shards that are deleted while they're being
+// scrubbed aren't reported.
+func (d *driver) scrub() error {
+	shardToBlocks := make(map[uint64]map[string]bool)
+	func() {
+		d.lock.RLock()
+		defer d.lock.RUnlock()
+		for _, shardToDiffInfo := range d.diffs {
+			for shard := range shardToDiffInfo {
+				if _, ok := shardToBlocks[shard]; !ok {
+					shardToBlocks[shard] = d.shardBlocks(shard)
+				}
+			}
+		}
+	}()
+	blockClient, err := d.getBlockClient()
+	if err != nil {
+		return err
+	}
+	// blocks can be shared between shards, each is only read once per scrub
+	// and the reason it's bad, if it is, is kept
+	bad := make(map[string]error)
+	for shard, blocks := range shardToBlocks {
+		shardCorrupt := make(map[string]bool)
+		for hash := range blocks {
+			badErr, ok := bad[hash]
+			if !ok {
+				data, err := readVerifiedBlock(blockClient, hash)
+				bad[hash] = err
+				badErr = err
+				d.throttleScrub(len(data))
+			}
+			if badErr != nil {
+				protolion.Errorf("block %s in shard %d is bad: %s", hash, shard, badErr.Error())
+				shardCorrupt[hash] = true
+			}
+		}
+		d.setCorruptBlocks(shard, shardCorrupt)
+	}
+	return nil
+}
+
+// setCorruptBlocks records the bad blocks a scrub found in shard, unless
+// shard has been deleted since the scrub started.
+func (d *driver) setCorruptBlocks(shard uint64, corruptBlocks map[string]bool) {
+	d.lock.RLock()
+	defer d.lock.RUnlock()
+	owned := false
+	for _, shardMap := range d.diffs {
+		if _, ok := shardMap[shard]; ok {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return
+	}
+	d.scrubLock.Lock()
+	defer d.scrubLock.Unlock()
+	d.corruptBlocks[shard] = corruptBlocks
+}
+
+// throttleScrub sleeps for as long as reading size bytes should take at
+// ScrubBytesPerSecond.
+func (d *driver) throttleScrub(size int) {
+	if d.options.ScrubBytesPerSecond == 0 {
+		return
+	}
+	time.Sleep(time.Duration(uint64(size) * uint64(time.Second) / d.options.ScrubBytesPerSecond))
+}
+
+// shardBlocks returns the hashes of the blocks referenced by shard, inline
+// data doesn't take up a block so it isn't included.
+// shardBlocks assumes that the lock is being held
+func (d *driver) shardBlocks(shard uint64) map[string]bool {
+	blocks := make(map[string]bool)
+	addBlockRefs := func(blockRefs []*pfs.BlockRef) {
+		for _, blockRef := range blockRefs {
+			if blockRef.Data == nil {
+				blocks[blockRef.Block.Hash] = true
+			}
+		}
+	}
+	for _, shardToDiffInfo := range d.diffs {
+		for commitID, diffInfo := range shardToDiffInfo[shard] {
+			if commitID == "" {
+				continue
+			}
+			for _, _append := range diffInfo.Appends {
+				addBlockRefs(_append.BlockRefs)
+				for _, blockRefs := range _append.Handles {
+					addBlockRefs(blockRefs.BlockRef)
+				}
+			}
+		}
+	}
+	return blocks
+}
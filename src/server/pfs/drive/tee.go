@@ -0,0 +1,537 @@
+package drive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"go.pedge.io/lion/proto"
+	"go.pedge.io/pb/go/google/protobuf"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+const (
+	// defaultTeeQueueSize is the number of writes an async tee driver
+	// buffers for its secondary when TeeOptions.QueueSize isn't set.
+	defaultTeeQueueSize = 1024
+	// defaultTeeQueueBytes is the number of bytes an async tee driver
+	// buffers for its secondary when TeeOptions.QueueBytes isn't set.
+	defaultTeeQueueBytes = 64 * 1024 * 1024
+)
+
+// TeeOptions configure a tee driver.
+type TeeOptions struct {
+	// Async makes writes return as soon as the primary has applied them, the
+	// secondary applies them in the background in the order they were made.
+	// Async writes are best effort since there's no caller left to fail.
+	Async bool
+	// BestEffort makes writes that the secondary fails get logged rather
+	// than returned. Writes always fail if the primary fails them.
+	// Without it a sync write that the secondary fails returns an error even
+	// though the primary has applied it, the error says so. Callers that
+	// retry such a write should make it idempotent, e.g. with an
+	// idempotency key.
+	BestEffort bool
+	// QueueSize is the number of writes an async tee driver buffers for the
+	// secondary before writes start blocking, 0 means 1024.
+	QueueSize int
+	// QueueBytes is the number of bytes of file content an async tee driver
+	// buffers for the secondary before writes start blocking, 0 means 64MiB.
+	// A single write that's bigger than this isn't buffered, it's streamed
+	// to the secondary once the secondary has caught up with the writes
+	// before it, so it's held up for that long.
+	QueueBytes uint64
+}
+
+type teeDriver struct {
+	// Driver is the primary, reads only go to it
+	Driver
+	secondary Driver
+	options   TeeOptions
+	queue     chan func()
+	// queuedBytes is the number of bytes of content buffered in queue, it's
+	// protected by queueLock
+	queuedBytes uint64
+	queueLock   sync.Mutex
+	queueCond   *sync.Cond
+	// closed is set by Close, once it's set nothing more is sent to queue,
+	// it's protected by closeLock
+	closed    bool
+	closeLock sync.RWMutex
+	// drained is closed once everything in queue has been applied
+	drained chan struct{}
+}
+
+// NewTeeDriver returns a Driver that applies every write to primary and then
+// mirrors it to secondary. Reads are served by primary.
+// Staged blobs are referenced by block, so secondary should be backed by a
+// single block server for the whole cluster, as primary is.
+// secondary's background sweepers are stopped so that it only changes when
+// primary does. The commits that primary cancels for being open too long are
+// cancelled on secondary too, but primary's other sweeps aren't mirrored:
+// secondary keeps soft deleted commits and expired files (hidden, as they
+// are before a sweep) and only flushes open commits when they're finished.
+func NewTeeDriver(primary Driver, secondary Driver, options TeeOptions) Driver {
+	t := &teeDriver{
+		Driver:    primary,
+		secondary: secondary,
+		options:   options,
+	}
+	t.queueCond = sync.NewCond(&t.queueLock)
+	if err := secondary.Close(); err != nil {
+		protolion.Errorf("error stopping the secondary's sweepers: %s", err.Error())
+	}
+	t.OnStaleCommitCancelled(nil)
+	if options.Async {
+		queueSize := options.QueueSize
+		if queueSize == 0 {
+			queueSize = defaultTeeQueueSize
+		}
+		t.queue = make(chan func(), queueSize)
+		t.drained = make(chan struct{})
+		go t.drainQueue()
+	}
+	return t
+}
+
+func (t *teeDriver) drainQueue() {
+	defer close(t.drained)
+	for f := range t.queue {
+		f()
+	}
+}
+
+// Close stops the primary's background sweepers. An async tee driver also
+// waits for the writes queued for the secondary to be applied, writes made
+// after it's closed are applied to the primary but fail to be mirrored.
+func (t *teeDriver) Close() error {
+	if t.options.Async {
+		t.closeLock.Lock()
+		if !t.closed {
+			t.closed = true
+			close(t.queue)
+		}
+		t.closeLock.Unlock()
+		<-t.drained
+	}
+	return t.Driver.Close()
+}
+
+// mirror applies a write to the secondary with f.
+func (t *teeDriver) mirror(name string, f func() error) error {
+	if t.options.Async {
+		t.closeLock.RLock()
+		defer t.closeLock.RUnlock()
+		if t.closed {
+			return fmt.Errorf("%s was applied to the primary but the tee driver is closed so it can't be mirrored", name)
+		}
+		t.queue <- func() {
+			if err := f(); err != nil {
+				protolion.Errorf("error mirroring %s: %s", name, err.Error())
+			}
+		}
+		return nil
+	}
+	if err := f(); err != nil {
+		if t.options.BestEffort {
+			protolion.Errorf("error mirroring %s: %s", name, err.Error())
+			return nil
+		}
+		return fmt.Errorf("%s was applied to the primary but mirroring it failed: %s", name, err.Error())
+	}
+	return nil
+}
+
+// mirrorBuffered is like mirror for an async write that holds size bytes
+// of buffered content, it waits for there to be room for them in the queue.
+func (t *teeDriver) mirrorBuffered(name string, size uint64, f func() error) error {
+	t.queueLock.Lock()
+	for t.queuedBytes > 0 && t.queuedBytes+size > t.queueBytes() {
+		t.queueCond.Wait()
+	}
+	t.queuedBytes += size
+	t.queueLock.Unlock()
+	release := func() {
+		t.queueLock.Lock()
+		defer t.queueLock.Unlock()
+		t.queuedBytes -= size
+		t.queueCond.Broadcast()
+	}
+	if err := t.mirror(name, func() error {
+		defer release()
+		return f()
+	}); err != nil {
+		release()
+		return err
+	}
+	return nil
+}
+
+func (t *teeDriver) queueBytes() uint64 {
+	if t.options.QueueBytes == 0 {
+		return defaultTeeQueueBytes
+	}
+	return t.options.QueueBytes
+}
+
+// mirrorReader is like mirror for writes that consume a reader. In sync mode
+// the reader is streamed to both drivers at once, in async mode it's
+// buffered in memory until the secondary gets to it, up to QueueBytes.
+func (t *teeDriver) mirrorReader(name string, reader io.Reader,
+	primary func(io.Reader) error, secondary func(io.Reader) error) error {
+	if t.options.Async {
+		buffer := &teeBuffer{
+			t:         t,
+			name:      name,
+			secondary: secondary,
+		}
+		return buffer.close(primary(io.TeeReader(reader, buffer)))
+	}
+	pipeReader, pipeWriter := io.Pipe()
+	errCh := make(chan error, 1)
+	go func() {
+		err := secondary(pipeReader)
+		// keep reading so that the primary isn't stuck writing to the pipe
+		// if the secondary gave up early
+		io.Copy(ioutil.Discard, pipeReader)
+		errCh <- err
+	}()
+	err := primary(io.TeeReader(reader, pipeWriter))
+	// a nil error closes the pipe normally
+	pipeWriter.CloseWithError(err)
+	secondaryErr := <-errCh
+	if err != nil {
+		return err
+	}
+	return t.mirror(name, func() error { return secondaryErr })
+}
+
+// teeBuffer holds what an async write's primary has read for the secondary.
+// Once it holds more than QueueBytes it hands the write to the queue and
+// streams the rest of it through a pipe, so the primary is held up until the
+// secondary has caught up and is reading the pipe.
+type teeBuffer struct {
+	t          *teeDriver
+	name       string
+	secondary  func(io.Reader) error
+	buffer     bytes.Buffer
+	pipeWriter *io.PipeWriter
+	// mirrorErr is set if the write couldn't be handed to the queue
+	mirrorErr error
+}
+
+func (b *teeBuffer) Write(p []byte) (int, error) {
+	if b.pipeWriter == nil && uint64(b.buffer.Len()+len(p)) > b.t.queueBytes() {
+		pipeReader, pipeWriter := io.Pipe()
+		b.pipeWriter = pipeWriter
+		buffered := b.buffer.Bytes()
+		if err := b.t.mirror(b.name, func() error {
+			err := b.secondary(io.MultiReader(bytes.NewReader(buffered), pipeReader))
+			// keep reading so that the primary isn't stuck writing to the
+			// pipe if the secondary gave up early
+			io.Copy(ioutil.Discard, pipeReader)
+			return err
+		}); err != nil {
+			// nothing will read the pipe, writing to it fails straight away
+			pipeReader.CloseWithError(err)
+			b.mirrorErr = err
+		}
+	}
+	if b.pipeWriter != nil {
+		// the secondary's errors are logged when it's done, they don't fail
+		// the primary
+		b.pipeWriter.Write(p)
+		return len(p), nil
+	}
+	return b.buffer.Write(p)
+}
+
+// close finishes handing the write to the secondary, err is the primary's
+// error. The secondary doesn't get writes that the primary failed, or it
+// gets them with err if they were already being streamed.
+func (b *teeBuffer) close(err error) error {
+	if b.pipeWriter != nil {
+		// a nil error closes the pipe normally
+		b.pipeWriter.CloseWithError(err)
+		if err != nil {
+			return err
+		}
+		return b.mirrorErr
+	}
+	if err != nil {
+		return err
+	}
+	return b.t.mirrorBuffered(b.name, uint64(b.buffer.Len()), func() error { return b.secondary(&b.buffer) })
+}
+
+// OnStaleCommitCancelled mirrors the commits that the primary cancels to the
+// secondary before calling f.
+func (t *teeDriver) OnStaleCommitCancelled(f func(commit *pfs.Commit, shards map[uint64]bool)) {
+	t.Driver.OnStaleCommitCancelled(func(commit *pfs.Commit, shards map[uint64]bool) {
+		if err := t.mirror("FinishCommit", func() error {
+			commitInfo, err := t.Driver.InspectCommit(commit, shards)
+			if err != nil {
+				return err
+			}
+			return t.secondary.FinishCommit(commit, commitInfo.Finished, true, FinishCommitOptions{}, shards)
+		}); err != nil {
+			protolion.Errorf("error mirroring cancelled commit %s/%s: %s", commit.Repo.Name, commit.ID, err.Error())
+		}
+		if f != nil {
+			f(commit, shards)
+		}
+	})
+}
+
+func (t *teeDriver) CreateRepo(repo *pfs.Repo, created *google_protobuf.Timestamp, provenance []*pfs.Repo, createIfNotExists bool, shards map[uint64]bool) error {
+	if err := t.Driver.CreateRepo(repo, created, provenance, createIfNotExists, shards); err != nil {
+		return err
+	}
+	return t.mirror("CreateRepo", func() error {
+		return t.secondary.CreateRepo(repo, created, provenance, createIfNotExists, shards)
+	})
+}
+
+func (t *teeDriver) DeleteRepo(repo *pfs.Repo, shards map[uint64]bool) error {
+	if err := t.Driver.DeleteRepo(repo, shards); err != nil {
+		return err
+	}
+	return t.mirror("DeleteRepo", func() error {
+		return t.secondary.DeleteRepo(repo, shards)
+	})
+}
+
+func (t *teeDriver) StartCommit(repo *pfs.Repo, commitID string, parentID string, branch string, started *google_protobuf.Timestamp,
+	provenance []*pfs.Commit, fromCommit *pfs.Commit, reserved bool, shards map[uint64]bool) error {
+	if err := t.Driver.StartCommit(repo, commitID, parentID, branch, started, provenance, fromCommit, reserved, shards); err != nil {
+		return err
+	}
+	return t.mirror("StartCommit", func() error {
+		return t.secondary.StartCommit(repo, commitID, parentID, branch, started, provenance, fromCommit, reserved, shards)
+	})
+}
+
+func (t *teeDriver) ReserveCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	if err := t.Driver.ReserveCommit(commit, shards); err != nil {
+		return err
+	}
+	return t.mirror("ReserveCommit", func() error {
+		return t.secondary.ReserveCommit(commit, shards)
+	})
+}
+
+func (t *teeDriver) UnstartCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	if err := t.Driver.UnstartCommit(commit, shards); err != nil {
+		return err
+	}
+	return t.mirror("UnstartCommit", func() error {
+		return t.secondary.UnstartCommit(commit, shards)
+	})
+}
+
+func (t *teeDriver) FinishCommit(commit *pfs.Commit, finished *google_protobuf.Timestamp, cancel bool,
+	options FinishCommitOptions, shards map[uint64]bool) error {
+	if err := t.Driver.FinishCommit(commit, finished, cancel, options, shards); err != nil {
+		return err
+	}
+	return t.mirror("FinishCommit", func() error {
+		return t.secondary.FinishCommit(commit, finished, cancel, options, shards)
+	})
+}
+
+func (t *teeDriver) PackCommit(commit *pfs.Commit, maxFileSize uint64, shards map[uint64]bool) (uint64, error) {
+	result, err := t.Driver.PackCommit(commit, maxFileSize, shards)
+	if err != nil {
+		return 0, err
+	}
+	return result, t.mirror("PackCommit", func() error {
+		_, err := t.secondary.PackCommit(commit, maxFileSize, shards)
+		return err
+	})
+}
+
+func (t *teeDriver) DeleteCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	if err := t.Driver.DeleteCommit(commit, shards); err != nil {
+		return err
+	}
+	return t.mirror("DeleteCommit", func() error {
+		return t.secondary.DeleteCommit(commit, shards)
+	})
+}
+
+func (t *teeDriver) SoftDeleteCommit(commit *pfs.Commit, deleted *google_protobuf.Timestamp, shards map[uint64]bool) error {
+	if err := t.Driver.SoftDeleteCommit(commit, deleted, shards); err != nil {
+		return err
+	}
+	return t.mirror("SoftDeleteCommit", func() error {
+		return t.secondary.SoftDeleteCommit(commit, deleted, shards)
+	})
+}
+
+func (t *teeDriver) RestoreCommit(commit *pfs.Commit, shards map[uint64]bool) error {
+	if err := t.Driver.RestoreCommit(commit, shards); err != nil {
+		return err
+	}
+	return t.mirror("RestoreCommit", func() error {
+		return t.secondary.RestoreCommit(commit, shards)
+	})
+}
+
+func (t *teeDriver) PutFile(file *pfs.File, handle string, delimiter pfs.Delimiter, options PutFileOptions,
+	shard uint64, reader io.Reader) error {
+	return t.mirrorReader("PutFile", reader,
+		func(reader io.Reader) error {
+			return t.Driver.PutFile(file, handle, delimiter, options, shard, reader)
+		},
+		func(reader io.Reader) error {
+			return t.secondary.PutFile(file, handle, delimiter, options, shard, reader)
+		},
+	)
+}
+
+func (t *teeDriver) PutFileAt(file *pfs.File, offset uint64, expectedHash string, expires *google_protobuf.Timestamp,
+	xattrs map[string]string, shard uint64, reader io.Reader) error {
+	return t.mirrorReader("PutFileAt", reader,
+		func(reader io.Reader) error {
+			return t.Driver.PutFileAt(file, offset, expectedHash, expires, xattrs, shard, reader)
+		},
+		func(reader io.Reader) error {
+			return t.secondary.PutFileAt(file, offset, expectedHash, expires, xattrs, shard, reader)
+		},
+	)
+}
+
+func (t *teeDriver) StageBlob(handle string, delimiter pfs.Delimiter, expires time.Time, shard uint64, reader io.Reader) (uint64, error) {
+	var result uint64
+	if err := t.mirrorReader("StageBlob", reader,
+		func(reader io.Reader) error {
+			var err error
+			result, err = t.Driver.StageBlob(handle, delimiter, expires, shard, reader)
+			return err
+		},
+		func(reader io.Reader) error {
+			_, err := t.secondary.StageBlob(handle, delimiter, expires, shard, reader)
+			return err
+		},
+	); err != nil {
+		return 0, err
+	}
+	return result, nil
+}
+
+// PutFileBlockRefs relies on blocks being content addressed, the blob was
+// staged on the secondary as well so it has the same blocks.
+func (t *teeDriver) PutFileBlockRefs(file *pfs.File, blockRefs []*pfs.BlockRef, shard uint64) error {
+	if err := t.Driver.PutFileBlockRefs(file, blockRefs, shard); err != nil {
+		return err
+	}
+	return t.mirror("PutFileBlockRefs", func() error {
+		return t.secondary.PutFileBlockRefs(file, blockRefs, shard)
+	})
+}
+
+func (t *teeDriver) SetXattr(file *pfs.File, name string, value string, shard uint64) error {
+	if err := t.Driver.SetXattr(file, name, value, shard); err != nil {
+		return err
+	}
+	return t.mirror("SetXattr", func() error {
+		return t.secondary.SetXattr(file, name, value, shard)
+	})
+}
+
+// FileCompareAndSwap only mirrors swaps that the primary made, the
+// secondary is expected to swap as well since it has the same content.
+func (t *teeDriver) FileCompareAndSwap(file *pfs.File, expectedHash string, value []byte, shard uint64) (bool, string, error) {
+	swapped, hash, err := t.Driver.FileCompareAndSwap(file, expectedHash, value, shard)
+	if err != nil || !swapped {
+		return swapped, hash, err
+	}
+	return swapped, hash, t.mirror("FileCompareAndSwap", func() error {
+		swapped, _, err := t.secondary.FileCompareAndSwap(file, expectedHash, value, shard)
+		if err != nil {
+			return err
+		}
+		if !swapped {
+			return fmt.Errorf("the secondary's content for %s didn't match the expected hash", file.Path)
+		}
+		return nil
+	})
+}
+
+func (t *teeDriver) SetImmutable(file *pfs.File, shard uint64) error {
+	if err := t.Driver.SetImmutable(file, shard); err != nil {
+		return err
+	}
+	return t.mirror("SetImmutable", func() error {
+		return t.secondary.SetImmutable(file, shard)
+	})
+}
+
+func (t *teeDriver) MakeDirectory(file *pfs.File, shard uint64) error {
+	if err := t.Driver.MakeDirectory(file, shard); err != nil {
+		return err
+	}
+	return t.mirror("MakeDirectory", func() error {
+		return t.secondary.MakeDirectory(file, shard)
+	})
+}
+
+func (t *teeDriver) DeleteFile(file *pfs.File, shard uint64, unsafe bool, handle string) error {
+	if err := t.Driver.DeleteFile(file, shard, unsafe, handle); err != nil {
+		return err
+	}
+	return t.mirror("DeleteFile", func() error {
+		return t.secondary.DeleteFile(file, shard, unsafe, handle)
+	})
+}
+
+// DeleteFiles only mirrors the deletes that the primary applied.
+func (t *teeDriver) DeleteFiles(files []*pfs.File, shard uint64, unsafe bool, handle string) []error {
+	errs := t.Driver.DeleteFiles(files, shard, unsafe, handle)
+	var deleted []*pfs.File
+	var indexes []int
+	for i, err := range errs {
+		if err == nil {
+			deleted = append(deleted, files[i])
+			indexes = append(indexes, i)
+		}
+	}
+	if len(deleted) == 0 {
+		return errs
+	}
+	if err := t.mirror("DeleteFiles", func() error {
+		for _, err := range t.secondary.DeleteFiles(deleted, shard, unsafe, handle) {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		for _, i := range indexes {
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+func (t *teeDriver) AddShard(shard uint64) error {
+	if err := t.Driver.AddShard(shard); err != nil {
+		return err
+	}
+	return t.mirror("AddShard", func() error {
+		return t.secondary.AddShard(shard)
+	})
+}
+
+func (t *teeDriver) DeleteShard(shard uint64) error {
+	if err := t.Driver.DeleteShard(shard); err != nil {
+		return err
+	}
+	return t.mirror("DeleteShard", func() error {
+		return t.secondary.DeleteShard(shard)
+	})
+}
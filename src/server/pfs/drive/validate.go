@@ -0,0 +1,107 @@
+package drive
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// JSONLinesContentType is the content type of data made of one JSON value
+// per line, blank lines are allowed.
+const JSONLinesContentType = "application/x-ndjson"
+
+// ContentValidator checks that content is of the type it claims to be.
+type ContentValidator interface {
+	// Validate returns an error describing the first problem it finds in
+	// reader's content, nil if there are none.
+	Validate(reader io.Reader) error
+}
+
+var (
+	contentValidatorsLock sync.RWMutex
+	contentValidators     = map[string]ContentValidator{
+		JSONLinesContentType: jsonLinesValidator{},
+	}
+)
+
+// RegisterContentValidator makes PutFile validate content put with
+// contentType using validator, it replaces any validator already
+// registered for contentType.
+func RegisterContentValidator(contentType string, validator ContentValidator) {
+	contentValidatorsLock.Lock()
+	defer contentValidatorsLock.Unlock()
+	contentValidators[contentType] = validator
+}
+
+func getContentValidator(contentType string) (ContentValidator, error) {
+	contentValidatorsLock.RLock()
+	defer contentValidatorsLock.RUnlock()
+	validator, ok := contentValidators[contentType]
+	if !ok {
+		return nil, fmt.Errorf("no validator is registered for content type %s", contentType)
+	}
+	return validator, nil
+}
+
+type jsonLinesValidator struct{}
+
+func (jsonLinesValidator) Validate(reader io.Reader) error {
+	bufioReader := bufio.NewReader(reader)
+	for lineNumber := 1; ; lineNumber++ {
+		line, err := bufioReader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			var value interface{}
+			if err := json.Unmarshal(line, &value); err != nil {
+				return fmt.Errorf("line %d isn't valid JSON: %s", lineNumber, err.Error())
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// contentValidation runs a validator over content as it's written to it.
+type contentValidation struct {
+	pipeWriter *io.PipeWriter
+	errCh      chan error
+	once       sync.Once
+	err        error
+}
+
+func newContentValidation(validator ContentValidator) *contentValidation {
+	pipeReader, pipeWriter := io.Pipe()
+	v := &contentValidation{
+		pipeWriter: pipeWriter,
+		errCh:      make(chan error, 1),
+	}
+	go func() {
+		err := validator.Validate(pipeReader)
+		// keep reading so that writes don't get stuck if the validator
+		// gave up early
+		io.Copy(ioutil.Discard, pipeReader)
+		v.errCh <- err
+	}()
+	return v
+}
+
+func (v *contentValidation) Write(p []byte) (int, error) {
+	return v.pipeWriter.Write(p)
+}
+
+// wait ends the content and returns the validator's verdict, it may be
+// called more than once.
+func (v *contentValidation) wait() error {
+	v.once.Do(func() {
+		v.pipeWriter.Close()
+		v.err = <-v.errCh
+	})
+	return v.err
+}
@@ -24,6 +24,11 @@ func (s *Hasher) HashFile(file *pfs.File) uint64 {
 	return uint64(adler32.Checksum([]byte(path.Clean(file.Path)))) % s.FileModulus
 }
 
+// HashStagedBlob returns the shard that a staged blob is kept in.
+func (s *Hasher) HashStagedBlob(handle string) uint64 {
+	return uint64(adler32.Checksum([]byte(handle))) % s.FileModulus
+}
+
 func (s *Hasher) HashBlock(block *pfs.Block) uint64 {
 	return uint64(adler32.Checksum([]byte(block.Hash))) % s.BlockModulus
 }
@@ -22,6 +22,26 @@ type ErrParentCommitNotFound struct {
 	error
 }
 
+type ErrBranchNotFound struct {
+	error
+}
+
+type ErrDivergentData struct {
+	error
+}
+
+type ErrValidationFailed struct {
+	error
+}
+
+type ErrBlockCorrupt struct {
+	error
+}
+
+type ErrCommitFinished struct {
+	error
+}
+
 func NewErrFileNotFound(file string, repo string, commitID string) *ErrFileNotFound {
 	return &ErrFileNotFound{
 		error: fmt.Errorf("File %v not found in repo %v at commit %v", file, repo, commitID),
@@ -40,12 +60,44 @@ func NewErrCommitNotFound(repo string, commitID string) *ErrCommitNotFound {
 	}
 }
 
+// NewErrCommitFinished is returned by writes to a commit that has already
+// been finished.
+func NewErrCommitFinished(repo string, commitID string) *ErrCommitFinished {
+	return &ErrCommitFinished{
+		error: fmt.Errorf("commit %s/%s has already been finished", repo, commitID),
+	}
+}
+
 func NewErrParentCommitNotFound(repo string, commitID string) *ErrParentCommitNotFound {
 	return &ErrParentCommitNotFound{
 		error: fmt.Errorf("Parent commit %v not found in repo %v", commitID, repo),
 	}
 }
 
+func NewErrBranchNotFound(repo string, branch string) *ErrBranchNotFound {
+	return &ErrBranchNotFound{
+		error: fmt.Errorf("Branch %v not found in repo %v", branch, repo),
+	}
+}
+
+func NewErrDivergentData(repo string, commitID string, shard uint64) *ErrDivergentData {
+	return &ErrDivergentData{
+		error: fmt.Errorf("Diff for commit %v in repo %v on shard %v doesn't match its checksum", commitID, repo, shard),
+	}
+}
+
+func NewErrValidationFailed(file string, contentType string, err error) *ErrValidationFailed {
+	return &ErrValidationFailed{
+		error: fmt.Errorf("File %v validation failed for content type %v: %v", file, contentType, err),
+	}
+}
+
+func NewErrBlockCorrupt(hash string) *ErrBlockCorrupt {
+	return &ErrBlockCorrupt{
+		error: fmt.Errorf("Block %v corrupt, its content doesn't match its hash", hash),
+	}
+}
+
 func ByteRangeSize(byteRange *pfs.ByteRange) uint64 {
 	return byteRange.Upper - byteRange.Lower
 }
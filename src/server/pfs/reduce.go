@@ -1,6 +1,9 @@
 package pfs
 
 import (
+	"container/heap"
+	"io"
+	"path"
 	"sort"
 
 	"github.com/pachyderm/pachyderm/src/client/pfs"
@@ -17,15 +20,44 @@ func ReduceRepoInfos(repoInfos []*pfs.RepoInfo) []*pfs.RepoInfo {
 		}
 		reducedRepoInfo.SizeBytes += repoInfo.SizeBytes
 		reducedRepoInfo.Provenance = repoInfo.Provenance
+		reducedRepoInfo.Dag = append(reducedRepoInfo.Dag, repoInfo.Dag...)
+		reducedRepoInfo.BranchHeads = reduceBranchHeads(reducedRepoInfo.BranchHeads, repoInfo.BranchHeads)
 	}
 	var result []*pfs.RepoInfo
 	for _, repoInfo := range reducedRepoInfos {
+		if len(repoInfo.Dag) > 0 {
+			repoInfo.Dag = ReduceCommitNodes(repoInfo.Dag)
+		}
 		result = append(result, repoInfo)
 	}
 	sort.Sort(sortRepoInfos(result))
 	return result
 }
 
+// reduceBranchHeads merges the branch heads that 2 nodes returned. Each node
+// reads its heads consistently but a commit may have been started in between
+// the nodes' reads, in which case the newer head wins.
+func reduceBranchHeads(a map[string]*pfs.CommitInfo, b map[string]*pfs.CommitInfo) map[string]*pfs.CommitInfo {
+	if a == nil {
+		return b
+	}
+	for branch, bHead := range b {
+		aHead, ok := a[branch]
+		if !ok {
+			a[branch] = bHead
+			continue
+		}
+		if aHead.Commit.ID == bHead.Commit.ID {
+			a[branch] = ReduceCommitInfos([]*pfs.CommitInfo{aHead, bHead})[0]
+			continue
+		}
+		if prototime.TimestampToTime(bHead.Started).After(prototime.TimestampToTime(aHead.Started)) {
+			a[branch] = bHead
+		}
+	}
+	return a
+}
+
 func ReduceCommitInfos(commitInfos []*pfs.CommitInfo) []*pfs.CommitInfo {
 	reducedCommitInfos := make(map[string]*pfs.CommitInfo)
 	for _, commitInfo := range commitInfos {
@@ -48,6 +80,10 @@ func ReduceCommitInfos(commitInfos []*pfs.CommitInfo) []*pfs.CommitInfo {
 	return result
 }
 
+// ReduceFileInfos merges the FileInfos returned by different shards for the
+// same path. Directories are scattered across shards so each shard only
+// knows about part of a directory, the result doesn't depend on the order in
+// which the shards responded.
 func ReduceFileInfos(fileInfos []*pfs.FileInfo) []*pfs.FileInfo {
 	reducedFileInfos := make(map[string]*pfs.FileInfo)
 	for _, fileInfo := range fileInfos {
@@ -56,20 +92,267 @@ func ReduceFileInfos(fileInfos []*pfs.FileInfo) []*pfs.FileInfo {
 			reducedFileInfos[fileInfo.File.Path] = fileInfo
 			continue
 		}
-		if prototime.TimestampToTime(fileInfo.Modified).
-			After(prototime.TimestampToTime(reducedFileInfo.Modified)) {
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			reducedFileInfo.SizeBytes += fileInfo.SizeBytes
+		}
+		if modifiedAfter(fileInfo, reducedFileInfo) {
 			reducedFileInfo.Modified = fileInfo.Modified
 			reducedFileInfo.CommitModified = fileInfo.CommitModified
 		}
 		reducedFileInfo.Children = append(reducedFileInfo.Children, fileInfo.Children...)
+		reducedFileInfo.ExistedIn = unionCommits(reducedFileInfo.ExistedIn, fileInfo.ExistedIn)
+		// a directory is only deleted if it's deleted in every shard
+		reducedFileInfo.Deleted = reducedFileInfo.Deleted && fileInfo.Deleted
 	}
 	var result []*pfs.FileInfo
 	for _, reducedFileInfo := range reducedFileInfos {
+		reducedFileInfo.Children = reduceFiles(reducedFileInfo.Children)
+		sort.Sort(sortCommits(reducedFileInfo.ExistedIn))
 		result = append(result, reducedFileInfo)
 	}
+	sort.Sort(sortFileInfos(result))
+	return result
+}
+
+// modifiedAfter returns true if a was modified after b, commits modified at
+// the same time are ordered by ID.
+func modifiedAfter(a *pfs.FileInfo, b *pfs.FileInfo) bool {
+	aModified := prototime.TimestampToTime(a.Modified)
+	bModified := prototime.TimestampToTime(b.Modified)
+	if !aModified.Equal(bModified) {
+		return aModified.After(bModified)
+	}
+	if a.CommitModified == nil || b.CommitModified == nil {
+		return b.CommitModified == nil && a.CommitModified != nil
+	}
+	return a.CommitModified.ID > b.CommitModified.ID
+}
+
+// reduceFiles removes duplicate files and sorts them by path.
+func reduceFiles(files []*pfs.File) []*pfs.File {
+	seen := make(map[string]bool)
+	var result []*pfs.File
+	for _, file := range files {
+		if !seen[file.Path] {
+			seen[file.Path] = true
+			result = append(result, file)
+		}
+	}
+	sort.Sort(sortFiles(result))
+	return result
+}
+
+// unionCommits returns the commits that are in a or b
+func unionCommits(a []*pfs.Commit, b []*pfs.Commit) []*pfs.Commit {
+	seen := make(map[string]bool)
+	for _, commit := range a {
+		seen[commit.ID] = true
+	}
+	for _, commit := range b {
+		if !seen[commit.ID] {
+			seen[commit.ID] = true
+			a = append(a, commit)
+		}
+	}
+	return a
+}
+
+// ReduceCommitNodes removes duplicate nodes and sorts them so that parents
+// come before their children. Among the nodes whose parents have been placed
+// the one that was started first goes next, so new commits land at the end
+// and offsets into the result stay stable as a repo grows.
+func ReduceCommitNodes(commitNodes []*pfs.CommitNode) []*pfs.CommitNode {
+	nodes := make(map[string]*pfs.CommitNode)
+	for _, commitNode := range commitNodes {
+		if _, ok := nodes[commitNode.Commit.ID]; !ok {
+			nodes[commitNode.Commit.ID] = commitNode
+		}
+	}
+	children := make(map[string][]*pfs.CommitNode)
+	ready := &commitNodeHeap{}
+	for _, commitNode := range nodes {
+		if commitNode.ParentCommit != nil {
+			if _, ok := nodes[commitNode.ParentCommit.ID]; ok {
+				children[commitNode.ParentCommit.ID] = append(children[commitNode.ParentCommit.ID], commitNode)
+				continue
+			}
+		}
+		heap.Push(ready, commitNode)
+	}
+	var result []*pfs.CommitNode
+	for ready.Len() > 0 {
+		commitNode := heap.Pop(ready).(*pfs.CommitNode)
+		result = append(result, commitNode)
+		for _, child := range children[commitNode.Commit.ID] {
+			heap.Push(ready, child)
+		}
+	}
+	return result
+}
+
+// ReduceFilesExistResponses merges the responses to the same
+// FilesExistRequest from different shards, a file exists if it exists in any
+// shard.
+func ReduceFilesExistResponses(responses []*pfs.FilesExistResponse) *pfs.FilesExistResponse {
+	result := &pfs.FilesExistResponse{}
+	for _, response := range responses {
+		if result.Exists == nil {
+			result.Exists = response.Exists
+			continue
+		}
+		for i, exists := range response.Exists {
+			result.Exists[i] = result.Exists[i] || exists
+		}
+	}
+	return result
+}
+
+// ReduceDeleteFilesResponses merges the responses to the same
+// DeleteFilesRequest from different shards. A file was deleted if it was
+// deleted from any shard and nothing else went wrong, it's only not found if
+// it wasn't found in any shard.
+func ReduceDeleteFilesResponses(responses []*pfs.DeleteFilesResponse) *pfs.DeleteFilesResponse {
+	result := &pfs.DeleteFilesResponse{}
+	for _, response := range responses {
+		if result.Result == nil {
+			result.Result = response.Result
+			continue
+		}
+		for i, deleteFileResult := range response.Result {
+			reduced := result.Result[i]
+			switch {
+			case reduced.Error != "" && !reduced.NotFound:
+				// a real error sticks
+			case deleteFileResult.Error != "" && !deleteFileResult.NotFound:
+				result.Result[i] = deleteFileResult
+			case deleteFileResult.Error == "":
+				result.Result[i] = deleteFileResult
+			}
+		}
+	}
 	return result
 }
 
+func ReduceShardInfos(shardInfos []*pfs.ShardInfo) []*pfs.ShardInfo {
+	reducedShardInfos := make(map[uint64]*pfs.ShardInfo)
+	for _, shardInfo := range shardInfos {
+		reducedShardInfo, ok := reducedShardInfos[shardInfo.Shard]
+		if !ok {
+			reducedShardInfos[shardInfo.Shard] = shardInfo
+			continue
+		}
+		// a shard should only be reported by its master, if we see it twice
+		// we report the least ready state
+		if shardInfo.State < reducedShardInfo.State {
+			reducedShardInfo.State = shardInfo.State
+		}
+	}
+	var result []*pfs.ShardInfo
+	for _, shardInfo := range reducedShardInfos {
+		result = append(result, shardInfo)
+	}
+	sort.Sort(sortShardInfos(result))
+	return result
+}
+
+// ReduceShardStats removes duplicate stats, a shard should only be reported
+// by its master so we keep the first report, and sorts them by shard.
+func ReduceShardStats(shardStats []*pfs.ShardStat) []*pfs.ShardStat {
+	reducedShardStats := make(map[uint64]*pfs.ShardStat)
+	for _, shardStat := range shardStats {
+		if _, ok := reducedShardStats[shardStat.Shard]; !ok {
+			reducedShardStats[shardStat.Shard] = shardStat
+		}
+	}
+	var result []*pfs.ShardStat
+	for _, shardStat := range reducedShardStats {
+		result = append(result, shardStat)
+	}
+	sort.Sort(sortShardStats(result))
+	return result
+}
+
+// ReduceBlockReferences removes duplicate references to a block and sorts
+// them by repo, commit and path.
+func ReduceBlockReferences(files []*pfs.File) []*pfs.File {
+	seen := make(map[string]bool)
+	var result []*pfs.File
+	for _, file := range files {
+		key := path.Join(file.Commit.Repo.Name, file.Commit.ID, file.Path)
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, file)
+		}
+	}
+	sort.Sort(sortBlockReferences(result))
+	return result
+}
+
+// ReduceFileChanges removes duplicate changes, which come from paths that
+// were deleted on several shards, and sorts the changes by path.
+func ReduceFileChanges(fileChanges []*pfs.FileChange) []*pfs.FileChange {
+	seen := make(map[string]bool)
+	var result []*pfs.FileChange
+	for _, fileChange := range fileChanges {
+		if seen[fileChange.File.Path] {
+			continue
+		}
+		seen[fileChange.File.Path] = true
+		result = append(result, fileChange)
+	}
+	sort.Sort(sortFileChanges(result))
+	return result
+}
+
+// SortManifestEntries sorts manifestEntries by path.
+func SortManifestEntries(manifestEntries []*pfs.ManifestEntry) {
+	sort.Sort(sortManifestEntries(manifestEntries))
+}
+
+// ManifestEntryStream is a stream of manifest entries sorted by path, Recv
+// returns io.EOF once the stream is done.
+type ManifestEntryStream interface {
+	Recv() (*pfs.ManifestEntry, error)
+}
+
+// MergeManifestEntries merges streams into a single stream of entries sorted
+// by path, calling f on each entry, so that manifests can be compared line by
+// line. Duplicate entries are dropped. Only one entry per stream is held at a
+// time.
+func MergeManifestEntries(streams []ManifestEntryStream, f func(*pfs.ManifestEntry) error) error {
+	h := &manifestEntryHeap{}
+	next := func(stream int) error {
+		manifestEntry, err := streams[stream].Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		heap.Push(h, &manifestEntryHead{manifestEntry: manifestEntry, stream: stream})
+		return nil
+	}
+	for i := range streams {
+		if err := next(i); err != nil {
+			return err
+		}
+	}
+	var lastPath *string
+	for h.Len() > 0 {
+		head := heap.Pop(h).(*manifestEntryHead)
+		if lastPath == nil || *lastPath != head.manifestEntry.File.Path {
+			if err := f(head.manifestEntry); err != nil {
+				return err
+			}
+			lastPath = &head.manifestEntry.File.Path
+		}
+		if err := next(head.stream); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 type sortRepoInfos []*pfs.RepoInfo
 
 func (a sortRepoInfos) Len() int {
@@ -106,3 +389,196 @@ func (a sortCommitInfos) Swap(i, j int) {
 	a[i] = a[j]
 	a[j] = tmp
 }
+
+type sortFileInfos []*pfs.FileInfo
+
+func (a sortFileInfos) Len() int {
+	return len(a)
+}
+
+func (a sortFileInfos) Less(i, j int) bool {
+	return a[i].File.Path < a[j].File.Path
+}
+func (a sortFileInfos) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortFiles []*pfs.File
+
+func (a sortFiles) Len() int {
+	return len(a)
+}
+
+func (a sortFiles) Less(i, j int) bool {
+	return a[i].Path < a[j].Path
+}
+func (a sortFiles) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortBlockReferences []*pfs.File
+
+func (a sortBlockReferences) Len() int {
+	return len(a)
+}
+
+func (a sortBlockReferences) Less(i, j int) bool {
+	if a[i].Commit.Repo.Name != a[j].Commit.Repo.Name {
+		return a[i].Commit.Repo.Name < a[j].Commit.Repo.Name
+	}
+	if a[i].Commit.ID != a[j].Commit.ID {
+		return a[i].Commit.ID < a[j].Commit.ID
+	}
+	return a[i].Path < a[j].Path
+}
+func (a sortBlockReferences) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortCommits []*pfs.Commit
+
+func (a sortCommits) Len() int {
+	return len(a)
+}
+
+func (a sortCommits) Less(i, j int) bool {
+	return a[i].ID < a[j].ID
+}
+func (a sortCommits) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortShardInfos []*pfs.ShardInfo
+
+func (a sortShardInfos) Len() int {
+	return len(a)
+}
+
+func (a sortShardInfos) Less(i, j int) bool {
+	return a[i].Shard < a[j].Shard
+}
+func (a sortShardInfos) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortShardStats []*pfs.ShardStat
+
+func (a sortShardStats) Len() int {
+	return len(a)
+}
+
+func (a sortShardStats) Less(i, j int) bool {
+	return a[i].Shard < a[j].Shard
+}
+func (a sortShardStats) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortFileChanges []*pfs.FileChange
+
+func (a sortFileChanges) Len() int {
+	return len(a)
+}
+
+func (a sortFileChanges) Less(i, j int) bool {
+	return a[i].File.Path < a[j].File.Path
+}
+func (a sortFileChanges) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+type sortManifestEntries []*pfs.ManifestEntry
+
+func (a sortManifestEntries) Len() int {
+	return len(a)
+}
+
+func (a sortManifestEntries) Less(i, j int) bool {
+	return a[i].File.Path < a[j].File.Path
+}
+func (a sortManifestEntries) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+
+type commitNodeHeap []*pfs.CommitNode
+
+func (h commitNodeHeap) Len() int {
+	return len(h)
+}
+
+func (h commitNodeHeap) Less(i, j int) bool {
+	iTime := prototime.TimestampToTime(h[i].Started)
+	jTime := prototime.TimestampToTime(h[j].Started)
+	if !iTime.Equal(jTime) {
+		return iTime.Before(jTime)
+	}
+	return h[i].Commit.ID < h[j].Commit.ID
+}
+func (h commitNodeHeap) Swap(i, j int) {
+	tmp := h[i]
+	h[i] = h[j]
+	h[j] = tmp
+}
+
+func (h *commitNodeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*pfs.CommitNode))
+}
+
+func (h *commitNodeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+// manifestEntryHead is the next entry from one of the streams being merged
+// by MergeManifestEntries.
+type manifestEntryHead struct {
+	manifestEntry *pfs.ManifestEntry
+	stream        int
+}
+
+type manifestEntryHeap []*manifestEntryHead
+
+func (h manifestEntryHeap) Len() int {
+	return len(h)
+}
+
+func (h manifestEntryHeap) Less(i, j int) bool {
+	return h[i].manifestEntry.File.Path < h[j].manifestEntry.File.Path
+}
+func (h manifestEntryHeap) Swap(i, j int) {
+	tmp := h[i]
+	h[i] = h[j]
+	h[j] = tmp
+}
+
+func (h *manifestEntryHeap) Push(x interface{}) {
+	*h = append(*h, x.(*manifestEntryHead))
+}
+
+func (h *manifestEntryHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"archive/zip"
+	"bufio"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -16,19 +20,26 @@ import (
 	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pkg/metrics"
 
+	"go.pedge.io/lion/proto"
 	"go.pedge.io/pb/go/google/protobuf"
 	"go.pedge.io/proto/rpclog"
 	"go.pedge.io/proto/stream"
 	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
 )
 
+// defaultDAGLimit is the number of DAG nodes InspectRepo returns when the
+// request doesn't set a limit
+const defaultDAGLimit = 1000
+
 type apiServer struct {
 	protorpclog.Logger
-	hasher *pfsserver.Hasher
-	router shard.Router
+	hasher  *pfsserver.Hasher
+	router  shard.Router
+	options APIServerOptions
 
 	// versionLock protects the version field.
 	// versionLock must be held BEFORE reading from version and UNTIL all
@@ -43,11 +54,13 @@ type apiServer struct {
 func newAPIServer(
 	hasher *pfsserver.Hasher,
 	router shard.Router,
+	options APIServerOptions,
 ) *apiServer {
 	return &apiServer{
 		Logger:          protorpclog.NewLogger("pachyderm.pfsserver.API"),
 		hasher:          hasher,
 		router:          router,
+		options:         options,
 		versionLock:     sync.RWMutex{},
 		version:         shard.InvalidVersion,
 		versionChanLock: sync.RWMutex{},
@@ -140,8 +153,26 @@ func (a *apiServer) InspectRepo(ctx context.Context, request *pfs.InspectRepoReq
 	if len(repoInfos) != 1 || repoInfos[0].Repo.Name != request.Repo.Name {
 		return nil, fmt.Errorf("incorrect repo returned (this is likely a bug)")
 	}
+	repoInfo := repoInfos[0]
+	if request.IncludeDag {
+		// the DAG is assembled in full and then paged, paging only bounds
+		// the size of the response
+		limit := request.DagLimit
+		if limit == 0 {
+			limit = defaultDAGLimit
+		}
+		offset := request.DagOffset
+		if offset > uint64(len(repoInfo.Dag)) {
+			offset = uint64(len(repoInfo.Dag))
+		}
+		repoInfo.Dag = repoInfo.Dag[offset:]
+		if uint64(len(repoInfo.Dag)) > limit {
+			repoInfo.Dag = repoInfo.Dag[:limit]
+			repoInfo.DagNextOffset = offset + limit
+		}
+	}
 
-	return repoInfos[0], nil
+	return repoInfo, nil
 }
 
 func (a *apiServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest) (response *pfs.RepoInfos, retErr error) {
@@ -223,6 +254,77 @@ func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitReq
 			metrics.AddCommits(1)
 		}
 	}()
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	// a caller supplied ID must have been reserved, the internal servers
+	// check that it was
+	request.Reserved = request.ID != ""
+	if request.ID == "" {
+		request.ID = uuid.NewWithoutDashes()
+	}
+	request.Started = prototime.TimeToTimestamp(time.Now())
+	commit := client.NewCommit(request.Repo.Name, request.ID)
+	if request.FromCommit != nil {
+		// the servers check again, but only for their own shards, checking
+		// here first means the commit isn't started on some servers only
+		if err := a.checkSeedable(ctx, request, clientConns); err != nil {
+			return nil, err
+		}
+	}
+	for i, clientConn := range clientConns {
+		defer clientConn.Close()
+		if _, err := pfs.NewInternalAPIClient(clientConn).StartCommit(ctx, request); err != nil {
+			if request.Reserved {
+				// undo the start on the servers that it succeeded on so
+				// that the reservation can be started again
+				for _, clientConn := range clientConns[:i] {
+					if _, err := pfs.NewInternalAPIClient(clientConn).UnstartCommit(ctx, &pfs.UnstartCommitRequest{Commit: commit}); err != nil {
+						protolion.Errorf("error unstarting commit %s/%s: %s", commit.Repo.Name, commit.ID, err.Error())
+					}
+				}
+			}
+			return nil, err
+		}
+	}
+	return commit, nil
+}
+
+// checkSeedable returns an error if the parent of the commit that request
+// starts has immutable files, starting a commit from another commit replaces
+// the parent's files.
+func (a *apiServer) checkSeedable(ctx context.Context, request *pfs.StartCommitRequest, clientConns []*grpc.ClientConn) error {
+	parentID := request.ParentID
+	if parentID == "" && request.Branch != "" {
+		headInfo, err := a.InspectBranch(ctx, &pfs.InspectBranchRequest{Repo: request.Repo, Branch: request.Branch})
+		if err != nil {
+			// the branch doesn't exist yet so there's no parent
+			return nil
+		}
+		parentID = headInfo.Commit.ID
+	}
+	if parentID == "" {
+		return nil
+	}
+	for _, clientConn := range clientConns {
+		if _, err := pfs.NewInternalAPIClient(clientConn).CheckMutable(ctx, &pfs.CheckMutableRequest{
+			File: client.NewFile(request.Repo.Name, parentID, "."),
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) ReserveCommit(ctx context.Context, request *pfs.ReserveCommitRequest) (response *pfs.Commit, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
 	clientConns, err := a.router.GetAllClientConns(a.version)
 	if err != nil {
 		return nil, err
@@ -231,10 +333,11 @@ func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitReq
 		return nil, fmt.Errorf("request.ID should be empty")
 	}
 	request.ID = uuid.NewWithoutDashes()
-	request.Started = prototime.TimeToTimestamp(time.Now())
+	// if some servers fail, the reservations made on the others are never
+	// returned to anyone, they're dropped when they expire
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
-		if _, err := pfs.NewInternalAPIClient(clientConn).StartCommit(ctx, request); err != nil {
+		if _, err := pfs.NewInternalAPIClient(clientConn).ReserveCommit(ctx, request); err != nil {
 			return nil, err
 		}
 	}
@@ -243,6 +346,14 @@ func (a *apiServer) StartCommit(ctx context.Context, request *pfs.StartCommitReq
 
 func (a *apiServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.ExpectedParent != nil {
+		// the servers check again when they finish the commit, but only for
+		// their own shards, checking here first means that a conflict that's
+		// already happened doesn't finish the commit on some servers
+		if err := a.checkExpectedParent(ctx, request.Commit, request.ExpectedParent); err != nil {
+			return nil, err
+		}
+	}
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
 
@@ -263,6 +374,36 @@ func (a *apiServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitR
 	return google_protobuf.EmptyInstance, nil
 }
 
+// checkExpectedParent returns a conflict error unless commit's parent is
+// expectedParent and commit is still the head of its branch. expectedParent
+// may be a branch name, which is resolved to the branch's head the same way
+// the driver's canonicalCommit does.
+func (a *apiServer) checkExpectedParent(ctx context.Context, commit *pfs.Commit, expectedParent *pfs.Commit) error {
+	commitInfo, err := a.InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: commit})
+	if err != nil {
+		return err
+	}
+	expectedParentID := expectedParent.ID
+	if headInfo, err := a.InspectBranch(ctx, &pfs.InspectBranchRequest{Repo: expectedParent.Repo, Branch: expectedParent.ID}); err == nil {
+		expectedParentID = headInfo.Commit.ID
+	}
+	if commitInfo.ParentCommit == nil || commitInfo.ParentCommit.ID != expectedParentID {
+		return grpcErrorf(codes.Aborted, "conflict: commit %s/%s does not have %s as its parent",
+			commit.Repo.Name, commit.ID, expectedParent.ID)
+	}
+	if commitInfo.Branch != "" {
+		headInfo, err := a.InspectBranch(ctx, &pfs.InspectBranchRequest{Repo: commit.Repo, Branch: commitInfo.Branch})
+		if err != nil {
+			return err
+		}
+		if headInfo.Commit.ID != commitInfo.Commit.ID {
+			return grpcErrorf(codes.Aborted, "conflict: branch %s has moved to %s since commit %s/%s was started",
+				commitInfo.Branch, headInfo.Commit.ID, commit.Repo.Name, commit.ID)
+		}
+	}
+	return nil
+}
+
 func (a *apiServer) InspectCommit(ctx context.Context, request *pfs.InspectCommitRequest) (response *pfs.CommitInfo, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
@@ -361,7 +502,7 @@ func (a *apiServer) ListCommit(ctx context.Context, request *pfs.ListCommitReque
 	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
 }
 
-func (a *apiServer) ListBranch(ctx context.Context, request *pfs.ListBranchRequest) (response *pfs.CommitInfos, retErr error) {
+func (a *apiServer) CommitChangedFiles(ctx context.Context, request *pfs.CommitChangedFilesRequest) (response *pfs.FileChanges, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -375,14 +516,14 @@ func (a *apiServer) ListBranch(ctx context.Context, request *pfs.ListBranchReque
 	}
 	var wg sync.WaitGroup
 	var lock sync.Mutex
-	var commitInfos []*pfs.CommitInfo
+	var fileChanges []*pfs.FileChange
 	errCh := make(chan error, 1)
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
 		wg.Add(1)
 		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).ListBranch(ctx, request)
+			subFileChanges, err := pfs.NewInternalAPIClient(clientConn).CommitChangedFiles(ctx, request)
 			if err != nil {
 				select {
 				case errCh <- err:
@@ -394,7 +535,7 @@ func (a *apiServer) ListBranch(ctx context.Context, request *pfs.ListBranchReque
 			}
 			lock.Lock()
 			defer lock.Unlock()
-			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
+			fileChanges = append(fileChanges, subFileChanges.FileChange...)
 		}(clientConn)
 	}
 	wg.Wait()
@@ -403,10 +544,10 @@ func (a *apiServer) ListBranch(ctx context.Context, request *pfs.ListBranchReque
 		return nil, err
 	default:
 	}
-	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
+	return &pfs.FileChanges{FileChange: pfsserver.ReduceFileChanges(fileChanges)}, nil
 }
 
-func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) CommitDiff(ctx context.Context, request *pfs.CommitDiffRequest) (response *pfs.FileChanges, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -418,16 +559,66 @@ func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitR
 	if err != nil {
 		return nil, err
 	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var fileChanges []*pfs.FileChange
+	errCh := make(chan error, 1)
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
-		if _, err := pfs.NewInternalAPIClient(clientConn).DeleteCommit(ctx, request); err != nil {
-			return nil, err
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subFileChanges, err := pfs.NewInternalAPIClient(clientConn).CommitDiff(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			fileChanges = append(fileChanges, subFileChanges.FileChange...)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.FileChanges{FileChange: pfsserver.ReduceFileChanges(fileChanges)}, nil
+}
+
+func (a *apiServer) CommitManifest(request *pfs.CommitManifestRequest, commitManifestServer pfs.API_CommitManifestServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(commitManifestServer.Context())
+	defer close(done)
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return err
+	}
+	// each server streams its entries sorted by path, they're merged as
+	// they come in so that the manifest is never held in memory
+	var streams []pfsserver.ManifestEntryStream
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		stream, err := pfs.NewInternalAPIClient(clientConn).CommitManifest(ctx, request)
+		if err != nil {
+			return err
 		}
+		streams = append(streams, stream)
 	}
-	return google_protobuf.EmptyInstance, nil
+	return pfsserver.MergeManifestEntries(streams, commitManifestServer.Send)
 }
 
-func (a *apiServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitRequest) (response *pfs.CommitInfos, retErr error) {
+func (a *apiServer) PackCommit(ctx context.Context, request *pfs.PackCommitRequest) (response *pfs.PackCommitResponse, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -441,14 +632,14 @@ func (a *apiServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitReq
 	}
 	var wg sync.WaitGroup
 	var lock sync.Mutex
-	var commitInfos []*pfs.CommitInfo
+	var filesPacked uint64
 	errCh := make(chan error, 1)
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
 		wg.Add(1)
 		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).FlushCommit(ctx, request)
+			subResponse, err := pfs.NewInternalAPIClient(clientConn).PackCommit(ctx, request)
 			if err != nil {
 				select {
 				case errCh <- err:
@@ -460,7 +651,7 @@ func (a *apiServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitReq
 			}
 			lock.Lock()
 			defer lock.Unlock()
-			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
+			filesPacked += subResponse.FilesPacked
 		}(clientConn)
 	}
 	wg.Wait()
@@ -469,114 +660,32 @@ func (a *apiServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitReq
 		return nil, err
 	default:
 	}
-	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
+	return &pfs.PackCommitResponse{FilesPacked: filesPacked}, nil
 }
 
-func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error) {
-	var request *pfs.PutFileRequest
-	var err error
-	defer func(start time.Time) {
-		if request != nil {
-			request.Value = nil // we set the value to nil so as not to spam logs
-		}
-		a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start))
-	}(time.Now())
-	defer drainFileServer(putFileServer)
+func (a *apiServer) OpenCommits(ctx context.Context, request *pfs.OpenCommitsRequest) (response *pfs.CommitInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
 
-	ctx, done := a.getVersionContext(putFileServer.Context())
+	ctx, done := a.getVersionContext(ctx)
 	defer close(done)
 
-	defer func() {
-		if err := putFileServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
-			retErr = err
-		}
-	}()
-	request, err = putFileServer.Recv()
-	if err != nil && err != io.EOF {
-		return err
-	}
-	if err == io.EOF {
-		// tolerate people calling and immediately hanging up
-		return nil
-	}
-	if strings.HasPrefix(request.File.Path, "/") {
-		// This is a subtle error case, the paths foo and /foo will hash to
-		// different shards but will produce the same change once they get to
-		// those shards due to how path.Join. This can go wrong in a number of
-		// ways so we forbid leading slashes.
-		return fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
 	}
-
 	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var commitInfos []*pfs.CommitInfo
 	errCh := make(chan error, 1)
-	requests := []*pfs.PutFileRequest{request}
-	// For a file like foo/bar/buzz, we want to send two requests to create two
-	// directories foo/ and foo/bar/
-	dirs := dirs(request.File.Path)
-	for _, dir := range dirs {
-		dirReq := &pfs.PutFileRequest{
-			File: &pfs.File{
-				Path:   dir,
-				Commit: request.File.Commit,
-			},
-			FileType: pfs.FileType_FILE_TYPE_DIR,
-		}
-		requests = append(requests, dirReq)
-	}
-
-	sendReq := func(request *pfs.PutFileRequest) (retErr error) {
-		clientConn, err := a.getClientConnForFile(request.File, a.version)
-		if err != nil {
-			return err
-		}
+	for _, clientConn := range clientConns {
 		defer clientConn.Close()
-		putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
-		if err != nil {
-			return err
-		}
-		if request.FileType == pfs.FileType_FILE_TYPE_DIR {
-			if len(request.Value) > 0 {
-				return fmt.Errorf("PutFileRequest shouldn't have type dir and a value")
-			}
-			if err := putFileClient.Send(request); err != nil {
-				return err
-			}
-			if _, err := putFileClient.CloseAndRecv(); err != nil {
-				return err
-			}
-			return nil
-		}
-		defer func() {
-			if _, err := putFileClient.CloseAndRecv(); err != nil && retErr == nil {
-				retErr = err
-			}
-		}()
-		if err := putFileClient.Send(request); err != nil {
-			return err
-		}
-		for {
-			request, err := putFileServer.Recv()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				return err
-			}
-			if err := putFileClient.Send(request); err != nil {
-				return err
-			}
-		}
-		return nil
-	}
-
-	for _, req := range requests {
 		wg.Add(1)
-		req := req
-		go func() {
+		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			if err := sendReq(req); err != nil {
+			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).OpenCommits(ctx, request)
+			if err != nil {
 				select {
 				case errCh <- err:
 					// error reported
@@ -585,54 +694,1165 @@ func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error)
 				}
 				return
 			}
-		}()
+			lock.Lock()
+			defer lock.Unlock()
+			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
+		}(clientConn)
 	}
-
 	wg.Wait()
-
 	select {
 	case err := <-errCh:
-		return err
+		return nil, err
 	default:
-		return nil
-	}
-}
-
-func dirs(path string) []string {
-	var ancestors []string
-	for {
-		path = filepath.Dir(path)
-		if path == "." {
-			break
-		}
-		ancestors = append(ancestors, path)
 	}
-	return ancestors
+	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
 }
 
-func (a *apiServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.API_GetFileServer) (retErr error) {
-	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+func (a *apiServer) ListBranch(ctx context.Context, request *pfs.ListBranchRequest) (response *pfs.CommitInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
 
-	ctx, done := a.getVersionContext(apiGetFileServer.Context())
+	ctx, done := a.getVersionContext(ctx)
 	defer close(done)
 
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	clientConns, err := a.router.GetAllClientConns(a.version)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer clientConn.Close()
-
-	fileGetClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, request)
-	if err != nil {
-		return err
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var commitInfos []*pfs.CommitInfo
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).ListBranch(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
+}
+
+func (a *apiServer) InspectBranch(ctx context.Context, request *pfs.InspectBranchRequest) (response *pfs.CommitInfo, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var commitInfos []*pfs.CommitInfo
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			commitInfo, err := pfs.NewInternalAPIClient(clientConn).InspectBranch(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			commitInfos = append(commitInfos, commitInfo)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+
+	// if a commit was started on the branch while we were asking, the
+	// servers may disagree about the head
+	commitInfos = pfsserver.ReduceCommitInfos(commitInfos)
+	if len(commitInfos) != 1 {
+		return nil, fmt.Errorf("the head of branch %s moved while it was being inspected, try again", request.Branch)
+	}
+
+	return commitInfos[0], nil
+}
+
+func (a *apiServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if request.Soft {
+		request.Deleted = prototime.TimeToTimestamp(time.Now())
+	}
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		if _, err := pfs.NewInternalAPIClient(clientConn).DeleteCommit(ctx, request); err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *apiServer) RestoreCommit(ctx context.Context, request *pfs.RestoreCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		if _, err := pfs.NewInternalAPIClient(clientConn).RestoreCommit(ctx, request); err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *apiServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitRequest) (response *pfs.CommitInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var commitInfos []*pfs.CommitInfo
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subCommitInfos, err := pfs.NewInternalAPIClient(clientConn).FlushCommit(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			commitInfos = append(commitInfos, subCommitInfos.CommitInfo...)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.CommitInfos{CommitInfo: pfsserver.ReduceCommitInfos(commitInfos)}, nil
+}
+
+func (a *apiServer) PutFile(putFileServer pfs.API_PutFileServer) (retErr error) {
+	var request *pfs.PutFileRequest
+	var err error
+	defer func(start time.Time) {
+		if request != nil {
+			request.Value = nil // we set the value to nil so as not to spam logs
+		}
+		a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start))
+	}(time.Now())
+	defer drainFileServer(putFileServer)
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(putFileServer.Context())
+	defer close(done)
+
+	defer func() {
+		if err := putFileServer.SendAndClose(google_protobuf.EmptyInstance); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	request, err = putFileServer.Recv()
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if err == io.EOF {
+		// tolerate people calling and immediately hanging up
+		return nil
+	}
+	if err := checkFilePath(request.File); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	requests := []*pfs.PutFileRequest{request}
+	// For a file like foo/bar/buzz, we want to send two requests to create two
+	// directories foo/ and foo/bar/
+	dirs := dirs(request.File.Path)
+	for _, dir := range dirs {
+		dirReq := &pfs.PutFileRequest{
+			File: &pfs.File{
+				Path:   dir,
+				Commit: request.File.Commit,
+			},
+			FileType: pfs.FileType_FILE_TYPE_DIR,
+		}
+		requests = append(requests, dirReq)
+	}
+
+	sendReq := func(request *pfs.PutFileRequest) (retErr error) {
+		clientConn, err := a.getClientConnForFile(request.File, a.version)
+		if err != nil {
+			return err
+		}
+		defer clientConn.Close()
+		putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
+		if err != nil {
+			return err
+		}
+		if request.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if len(request.Value) > 0 {
+				return fmt.Errorf("PutFileRequest shouldn't have type dir and a value")
+			}
+			if err := putFileClient.Send(request); err != nil {
+				return err
+			}
+			if _, err := putFileClient.CloseAndRecv(); err != nil {
+				return err
+			}
+			return nil
+		}
+		defer func() {
+			if _, err := putFileClient.CloseAndRecv(); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+		if err := putFileClient.Send(request); err != nil {
+			return err
+		}
+		for {
+			request, err := putFileServer.Recv()
+			if err != nil {
+				if err == io.EOF {
+					break
+				}
+				return err
+			}
+			if err := putFileClient.Send(request); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, req := range requests {
+		wg.Add(1)
+		req := req
+		go func() {
+			defer wg.Done()
+			if err := sendReq(req); err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
+	}
+}
+
+// checkFilePath returns an error if file can't be written to because of
+// its path, it's checked by the writes before they're routed and by Validate.
+func checkFilePath(file *pfs.File) error {
+	if file == nil || file.Commit == nil || file.Commit.Repo == nil {
+		return fmt.Errorf("pachyderm: file, commit and repo must be set")
+	}
+	if strings.HasPrefix(file.Path, "/") {
+		// This is a subtle error case, the paths foo and /foo will hash to
+		// different shards but will produce the same change once they get to
+		// those shards due to how path.Join. This can go wrong in a number of
+		// ways so we forbid leading slashes.
+		return fmt.Errorf("pachyderm: leading slash in path: %s", file.Path)
+	}
+	if cleanPath := filepath.Clean(file.Path); cleanPath == ".." || strings.HasPrefix(cleanPath, "../") {
+		return fmt.Errorf("pachyderm: path %s is outside of the commit", file.Path)
+	}
+	return nil
+}
+
+func dirs(path string) []string {
+	var ancestors []string
+	for {
+		path = filepath.Dir(path)
+		if path == "." {
+			break
+		}
+		ancestors = append(ancestors, path)
+	}
+	return ancestors
+}
+
+func (a *apiServer) PutFileURL(ctx context.Context, request *pfs.PutFileURLRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) {
+		request.Headers = nil // headers may hold credentials, keep them out of the logs
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if err := checkFilePath(request.File); err != nil {
+		return nil, err
+	}
+
+	// the server that owns the file's shard fetches the url
+	if err := a.putFileAndDirs(ctx, request.File, func(internalAPIClient pfs.InternalAPIClient) error {
+		_, err := internalAPIClient.PutFileURL(ctx, request)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *apiServer) PutFileMulti(ctx context.Context, request *pfs.PutFileMultiRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) {
+		request.Value = nil // we set the value to nil so as not to spam logs
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if err := checkFilePath(request.File); err != nil {
+		return nil, err
+	}
+
+	// all of the values go to the server that owns the file's shard in one
+	// request, that's what keeps them contiguous
+	if err := a.putFileAndDirs(ctx, request.File, func(internalAPIClient pfs.InternalAPIClient) error {
+		_, err := internalAPIClient.PutFileMulti(ctx, request)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+// putFileAndDirs calls put with a client for the server that owns file's
+// shard, meanwhile it creates file's parent directories like PutFile does.
+func (a *apiServer) putFileAndDirs(ctx context.Context, file *pfs.File, put func(pfs.InternalAPIClient) error) error {
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+			// error reported
+		default:
+			// not the first error
+		}
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		clientConn, err := a.getClientConnForFile(file, a.version)
+		if err != nil {
+			sendErr(err)
+			return
+		}
+		defer clientConn.Close()
+		if err := put(pfs.NewInternalAPIClient(clientConn)); err != nil {
+			sendErr(err)
+		}
+	}()
+	for _, dir := range dirs(file.Path) {
+		dir := dir
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := a.makeDirectory(ctx, client.NewFile(file.Commit.Repo.Name, file.Commit.ID, dir)); err != nil {
+				sendErr(err)
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	return nil
+}
+
+func (a *apiServer) StageBlob(stageBlobServer pfs.API_StageBlobServer) (retErr error) {
+	var request *pfs.StageBlobRequest
+	var response *pfs.StagedBlob
+	defer func(start time.Time) {
+		if request != nil {
+			request.Value = nil // we set the value to nil so as not to spam logs
+		}
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(stageBlobServer.Context())
+	defer close(done)
+
+	request, err := stageBlobServer.Recv()
+	if err != nil {
+		return err
+	}
+	// the blob is kept by the server that owns the shard its handle hashes
+	// to, that's how PutFileStaged finds it
+	request.Handle = uuid.NewWithoutDashes()
+	clientConn, err := a.router.GetClientConn(a.hasher.HashStagedBlob(request.Handle), a.version)
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+	stageBlobClient, err := pfs.NewInternalAPIClient(clientConn).StageBlob(ctx)
+	if err != nil {
+		return err
+	}
+	if err := stageBlobClient.Send(request); err != nil {
+		return err
+	}
+	for {
+		request, err := stageBlobServer.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := stageBlobClient.Send(request); err != nil {
+			return err
+		}
+	}
+	response, err = stageBlobClient.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	return stageBlobServer.SendAndClose(response)
+}
+
+func (a *apiServer) PutFileStaged(ctx context.Context, request *pfs.PutFileStagedRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if err := checkFilePath(request.File); err != nil {
+		return nil, err
+	}
+	blobClientConn, err := a.router.GetClientConn(a.hasher.HashStagedBlob(request.Handle), a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer blobClientConn.Close()
+	blockRefs, err := pfs.NewInternalAPIClient(blobClientConn).InspectStagedBlob(ctx, &pfs.InspectStagedBlobRequest{Handle: request.Handle})
+	if err != nil {
+		return nil, err
+	}
+	internalRequest := &pfs.PutFileStagedRequest{
+		File:     request.File,
+		Handle:   request.Handle,
+		BlockRef: blockRefs.BlockRef,
+	}
+
+	if err := a.putFileAndDirs(ctx, request.File, func(internalAPIClient pfs.InternalAPIClient) error {
+		_, err := internalAPIClient.PutFileStaged(ctx, internalRequest)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+// makeDirectory creates a directory on the server that owns its shard
+func (a *apiServer) makeDirectory(ctx context.Context, file *pfs.File) error {
+	clientConn, err := a.getClientConnForFile(file, a.version)
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+	putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(ctx)
+	if err != nil {
+		return err
+	}
+	if err := putFileClient.Send(&pfs.PutFileRequest{
+		File:     file,
+		FileType: pfs.FileType_FILE_TYPE_DIR,
+	}); err != nil {
+		return err
+	}
+	_, err = putFileClient.CloseAndRecv()
+	return err
+}
+
+func (a *apiServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.API_GetFileServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, google_protobuf.EmptyInstance, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(apiGetFileServer.Context())
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return err
+	}
+	defer clientConn.Close()
+
+	fileGetClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	return protostream.RelayFromStreamingBytesClient(fileGetClient, apiGetFileServer)
+}
+
+func (a *apiServer) GetFileArchive(request *pfs.GetFileArchiveRequest, getFileArchiveServer pfs.API_GetFileArchiveServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(getFileArchiveServer.Context())
+	defer close(done)
+
+	// we read the files shard by shard so that we only need one connection
+	// per shard
+	var archiveFiles []archiveFile
+	for _, file := range request.File {
+		if err := checkFilePath(file); err != nil {
+			return err
+		}
+		archiveFiles = append(archiveFiles, archiveFile{file: file, shard: a.hasher.HashFile(file)})
+	}
+	sort.Sort(sortArchiveFiles(archiveFiles))
+	paths := make(map[string]bool)
+	for _, archiveFile := range archiveFiles {
+		if paths[archiveFile.file.Path] {
+			return fmt.Errorf("%s appears more than once in the archive", archiveFile.file.Path)
+		}
+		paths[archiveFile.file.Path] = true
+	}
+
+	writer := bufio.NewWriterSize(protostream.NewStreamingBytesWriter(getFileArchiveServer), exportChunkSize)
+	zipWriter := zip.NewWriter(writer)
+	var clientConn *grpc.ClientConn
+	defer func() {
+		if clientConn != nil {
+			clientConn.Close()
+		}
+	}()
+	for i, archiveFile := range archiveFiles {
+		if i == 0 || archiveFile.shard != archiveFiles[i-1].shard {
+			if clientConn != nil {
+				clientConn.Close()
+				clientConn = nil
+			}
+			var err error
+			if clientConn, err = a.router.GetClientConn(archiveFile.shard, a.version); err != nil {
+				return err
+			}
+		}
+		getFileClient, err := pfs.NewInternalAPIClient(clientConn).GetFile(ctx, &pfs.GetFileRequest{
+			File:      archiveFile.file,
+			SizeBytes: math.MaxInt64,
+		})
+		if err != nil {
+			return err
+		}
+		entryWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:   archiveFile.file.Path,
+			Method: zip.Deflate,
+		})
+		if err != nil {
+			return err
+		}
+		if err := protostream.WriteFromStreamingBytesClient(getFileClient, entryWriter); err != nil {
+			return err
+		}
+	}
+	if err := zipWriter.Close(); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// archiveFile is a file in a GetFileArchive request along with its shard
+type archiveFile struct {
+	file  *pfs.File
+	shard uint64
+}
+
+type sortArchiveFiles []archiveFile
+
+func (a sortArchiveFiles) Len() int {
+	return len(a)
+}
+
+func (a sortArchiveFiles) Less(i, j int) bool {
+	if a[i].shard != a[j].shard {
+		return a[i].shard < a[j].shard
+	}
+	return a[i].file.Path < a[j].file.Path
+}
+func (a sortArchiveFiles) Swap(i, j int) {
+	tmp := a[i]
+	a[i] = a[j]
+	a[j] = tmp
+}
+
+func (a *apiServer) FileType(ctx context.Context, request *pfs.FileTypeRequest) (response *pfs.FileTypeResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+
+	return pfs.NewInternalAPIClient(clientConn).FileType(ctx, request)
+}
+
+func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+
+	fileInfo, err := pfs.NewInternalAPIClient(clientConn).InspectFile(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		// If it's a directory, chances are that its children are scattered around
+		// the cluster, so we can't get the complete list of children without
+		// doing a multicast.  So instead of returning a partial list of children,
+		// we'd rather return no children at all.  To get the complete list of
+		// children, the caller is expected to use ListFile
+		fileInfo.Children = nil
+	}
+
+	return fileInfo, nil
+}
+
+func (a *apiServer) SetXattr(ctx context.Context, request *pfs.SetXattrRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if request.Name == "" {
+		return nil, fmt.Errorf("xattr name can't be empty")
+	}
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+	return pfs.NewInternalAPIClient(clientConn).SetXattr(ctx, request)
+}
+
+func (a *apiServer) FileCompareAndSwap(ctx context.Context, request *pfs.FileCompareAndSwapRequest) (response *pfs.FileCompareAndSwapResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+	return pfs.NewInternalAPIClient(clientConn).FileCompareAndSwap(ctx, request)
+}
+
+func (a *apiServer) SetImmutable(ctx context.Context, request *pfs.SetImmutableRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+	return pfs.NewInternalAPIClient(clientConn).SetImmutable(ctx, request)
+}
+
+func (a *apiServer) GetXattr(ctx context.Context, request *pfs.GetXattrRequest) (response *google_protobuf.StringValue, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+	return pfs.NewInternalAPIClient(clientConn).GetXattr(ctx, request)
+}
+
+func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	return a.listFile(ctx, request)
+}
+
+// ListFileMulti lists each of the request's targets concurrently, each one
+// fans out to the shards as ListFile does.
+func (a *apiServer) ListFileMulti(ctx context.Context, request *pfs.ListFileMultiRequest) (response *pfs.ListFileMultiResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	fileInfos := make([]*pfs.FileInfos, len(request.Request))
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	for i, listFileRequest := range request.Request {
+		wg.Add(1)
+		go func(i int, listFileRequest *pfs.ListFileRequest) {
+			defer wg.Done()
+			subFileInfos, err := a.listFile(ctx, listFileRequest)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			fileInfos[i] = subFileInfos
+		}(i, listFileRequest)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.ListFileMultiResponse{FileInfos: fileInfos}, nil
+}
+
+// listFile assumes that the version lock is being held
+func (a *apiServer) listFile(ctx context.Context, request *pfs.ListFileRequest) (*pfs.FileInfos, error) {
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var fileInfos []*pfs.FileInfo
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subFileInfos, err := pfs.NewInternalAPIClient(clientConn).ListFile(ctx, request)
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			// Directories are spread across shards so the same directory
+			// may be returned by several servers, ReduceFileInfos merges
+			// them keeping the most recent modification.
+			fileInfos = append(fileInfos, subFileInfos.FileInfo...)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.FileInfos{
+		FileInfo: pfsserver.ReduceFileInfos(fileInfos),
+	}, nil
+}
+
+func (a *apiServer) ListFileStream(request *pfs.ListFileRequest, listFileStreamServer pfs.API_ListFileStreamServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(listFileStreamServer.Context())
+	defer close(done)
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return err
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	// Regular files only live in one shard so they're sent as soon as a
+	// server returns them. Directories are spread across shards and need to
+	// be merged with ReduceFileInfos, so they're held until every server has
+	// responded.
+	seenFiles := make(map[string]bool)
+	var dirInfos []*pfs.FileInfo
+	errCh := make(chan error, 1)
+	sendErr := func(err error) {
+		select {
+		case errCh <- err:
+			// error reported
+		default:
+			// not the first error
+		}
+	}
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			subFileInfos, err := pfs.NewInternalAPIClient(clientConn).ListFile(ctx, request)
+			if err != nil {
+				sendErr(err)
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			for _, fileInfo := range subFileInfos.FileInfo {
+				if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+					dirInfos = append(dirInfos, fileInfo)
+					continue
+				}
+				if seenFiles[fileInfo.File.Path] {
+					continue
+				}
+				seenFiles[fileInfo.File.Path] = true
+				if err := listFileStreamServer.Send(fileInfo); err != nil {
+					sendErr(err)
+					return
+				}
+			}
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+	for _, dirInfo := range pfsserver.ReduceFileInfos(dirInfos) {
+		if err := listFileStreamServer.Send(dirInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	fileInfo, err := a.InspectFile(ctx, &pfs.InspectFileRequest{
+		File:   request.File,
+		Unsafe: request.Unsafe,
+		Handle: request.Handle,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if fileInfo.FileType == pfs.FileType_FILE_TYPE_REGULAR {
+		clientConn, err := a.getClientConnForFile(request.File, a.version)
+		if err != nil {
+			return nil, err
+		}
+		defer clientConn.Close()
+		return pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+	}
+
+	// If we are deleting a directory, we multicast it to the entire cluster
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	// the directory is deleted server by server, we check for immutable
+	// files on all of them first so the directory isn't left half deleted
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		if _, err := pfs.NewInternalAPIClient(clientConn).CheckMutable(ctx, &pfs.CheckMutableRequest{File: request.File}); err != nil {
+			return nil, err
+		}
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		wg.Add(1)
+		clientConn := clientConn
+		go func() {
+			defer wg.Done()
+			_, err := pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *apiServer) FilesExist(ctx context.Context, request *pfs.FilesExistRequest) (response *pfs.FilesExistResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	for _, file := range request.File {
+		if strings.HasPrefix(file.Path, "/") {
+			// see PutFile for why leading slashes are forbidden
+			return nil, fmt.Errorf("pachyderm: leading slash in path: %s", file.Path)
+		}
+	}
+	if len(request.File) == 0 {
+		return &pfs.FilesExistResponse{}, nil
+	}
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var responses []*pfs.FilesExistResponse
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			internalResponse, err := pfs.NewInternalAPIClient(clientConn).FilesExist(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			responses = append(responses, internalResponse)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+
+	response = pfsserver.ReduceFilesExistResponses(responses)
+	if len(response.Exists) != len(request.File) {
+		return nil, fmt.Errorf("expected %d results but got %d (this is likely a bug)", len(request.File), len(response.Exists))
+	}
+	return response, nil
+}
+
+func (a *apiServer) DeleteFiles(ctx context.Context, request *pfs.DeleteFilesRequest) (response *pfs.DeleteFilesResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	response = &pfs.DeleteFilesResponse{}
+	// files that fail validation get their result here, the rest are sent
+	// to every server, indexes maps them back to their place in the request
+	internalRequest := &pfs.DeleteFilesRequest{
+		Unsafe: request.Unsafe,
+		Handle: request.Handle,
+	}
+	var indexes []int
+	for i, file := range request.File {
+		deleteFileResult := &pfs.DeleteFileResult{File: file}
+		response.Result = append(response.Result, deleteFileResult)
+		if err := checkFilePath(file); err != nil {
+			deleteFileResult.Error = err.Error()
+			continue
+		}
+		internalRequest.File = append(internalRequest.File, file)
+		indexes = append(indexes, i)
+	}
+	if len(internalRequest.File) == 0 {
+		return response, nil
+	}
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return nil, err
+	}
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var responses []*pfs.DeleteFilesResponse
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			internalResponse, err := pfs.NewInternalAPIClient(clientConn).DeleteFiles(ctx, internalRequest)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			responses = append(responses, internalResponse)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
 	}
 
-	return protostream.RelayFromStreamingBytesClient(fileGetClient, apiGetFileServer)
+	reduced := pfsserver.ReduceDeleteFilesResponses(responses)
+	if len(reduced.Result) != len(indexes) {
+		return nil, fmt.Errorf("expected %d results but got %d (this is likely a bug)", len(indexes), len(reduced.Result))
+	}
+	for i, deleteFileResult := range reduced.Result {
+		response.Result[indexes[i]] = deleteFileResult
+	}
+	return response, nil
 }
 
-func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
+func (a *apiServer) Validate(ctx context.Context, request *pfs.ValidateRequest) (response *pfs.ValidateResponse, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -640,30 +1860,335 @@ func (a *apiServer) InspectFile(ctx context.Context, request *pfs.InspectFileReq
 	ctx, done := a.getVersionContext(ctx)
 	defer close(done)
 
-	clientConn, err := a.getClientConnForFile(request.File, a.version)
+	response = &pfs.ValidateResponse{}
+	// many operations are usually against the same commit, we only inspect
+	// each commit once
+	commitErrs := make(map[string]error)
+	for _, operation := range request.Operation {
+		validateResult := &pfs.ValidateResult{Operation: operation}
+		response.Result = append(response.Result, validateResult)
+		if err := a.validateOperation(ctx, operation, validateResult, commitErrs); err != nil {
+			validateResult.Error = err.Error()
+		}
+	}
+	return response, nil
+}
+
+// validateOperation runs the checks that the write path would run on
+// operation, filling in where it would be routed as it goes. The path is
+// checked like the writes check it and the commit gets the error a write to
+// it would get.
+func (a *apiServer) validateOperation(ctx context.Context, operation *pfs.Operation,
+	validateResult *pfs.ValidateResult, commitErrs map[string]error) error {
+	if operation.Type != pfs.OperationType_OPERATION_TYPE_PUT && operation.Type != pfs.OperationType_OPERATION_TYPE_DELETE {
+		return fmt.Errorf("pachyderm: unknown operation type %v", operation.Type)
+	}
+	file := operation.File
+	if err := checkFilePath(file); err != nil {
+		return err
+	}
+	validateResult.Shard = a.hasher.HashFile(file)
+	address, err := a.router.GetAddress(validateResult.Shard, a.version)
+	if err != nil {
+		return err
+	}
+	validateResult.Address = address
+	commitKey := filepath.Join(file.Commit.Repo.Name, file.Commit.ID)
+	commitErr, ok := commitErrs[commitKey]
+	if !ok {
+		commitInfo, err := a.InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: file.Commit})
+		if err != nil {
+			commitErr = err
+		} else if commitInfo.Finished != nil {
+			commitErr = pfsserver.NewErrCommitFinished(file.Commit.Repo.Name, file.Commit.ID)
+		}
+		commitErrs[commitKey] = commitErr
+	}
+	return commitErr
+}
+
+func (a *apiServer) ExportCommit(request *pfs.ExportCommitRequest, exportCommitServer pfs.API_ExportCommitServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(exportCommitServer.Context())
+	defer close(done)
+
+	return a.exportCommit(ctx, request.Commit, exportCommitServer.Send)
+}
+
+func (a *apiServer) ExportToPath(ctx context.Context, request *pfs.ExportToPathRequest) (response *pfs.ExportToPathResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if a.options.ExportRoot == "" {
+		return nil, fmt.Errorf("ExportToPath is disabled, pachd doesn't have an export root")
+	}
+	if request.Path == "" {
+		return nil, fmt.Errorf("a path to export to must be specified")
+	}
+	exporter, err := newPathExporter(a.options.ExportRoot, request.Path)
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err := exporter.closeFile(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	if err := a.exportCommit(ctx, request.Commit, exporter.write); err != nil {
+		return nil, err
+	}
+	return &pfs.ExportToPathResponse{
+		Files:     exporter.files,
+		SizeBytes: exporter.sizeBytes,
+	}, nil
+}
+
+// exportCommit calls send with each record of a finished commit's export.
+func (a *apiServer) exportCommit(ctx context.Context, commit *pfs.Commit, send func(*pfs.ExportRecord) error) error {
+	commitInfo, err := a.InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: commit})
+	if err != nil {
+		return err
+	}
+	if commitInfo.Finished == nil {
+		return fmt.Errorf("commit %s/%s isn't finished, it can't be exported", commit.Repo.Name, commit.ID)
+	}
+	// export the canonical commit so that the records don't refer to a branch
+	request := &pfs.ExportCommitRequest{Commit: commitInfo.Commit}
+
+	clientConns, err := a.router.GetAllClientConns(a.version)
+	if err != nil {
+		return err
+	}
+	// The servers are streamed one after the other so that only one file's
+	// content is in flight at a time. Directories are in every server, we
+	// only send each one once.
+	sentDirs := make(map[string]bool)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		exportCommitClient, err := pfs.NewInternalAPIClient(clientConn).ExportCommit(ctx, request)
+		if err != nil {
+			return err
+		}
+		for {
+			record, err := exportCommitClient.Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if record.FileInfo != nil && record.FileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+				if sentDirs[record.FileInfo.File.Path] {
+					continue
+				}
+				sentDirs[record.FileInfo.File.Path] = true
+			}
+			if err := send(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (a *apiServer) ReadShard(request *pfs.ReadShardRequest, readShardServer pfs.API_ReadShardServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(readShardServer.Context())
+	defer close(done)
+
+	commitInfo, err := a.InspectCommit(ctx, &pfs.InspectCommitRequest{Commit: request.Commit})
+	if err != nil {
+		return err
+	}
+	if commitInfo.Finished == nil {
+		return fmt.Errorf("commit %s/%s isn't finished, its shards can't be read", request.Commit.Repo.Name, request.Commit.ID)
+	}
+	request = &pfs.ReadShardRequest{Commit: commitInfo.Commit, Shard: request.Shard}
+
+	clientConn, err := a.router.GetClientConn(request.Shard, a.version)
+	if err != nil {
+		return err
+	}
 	defer clientConn.Close()
+	readShardClient, err := pfs.NewInternalAPIClient(clientConn).ReadShard(ctx, request)
+	if err != nil {
+		return err
+	}
+	for {
+		record, err := readShardClient.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := readShardServer.Send(record); err != nil {
+			return err
+		}
+	}
+}
 
-	fileInfo, err := pfs.NewInternalAPIClient(clientConn).InspectFile(ctx, request)
+func (a *apiServer) ImportCommit(importCommitServer pfs.API_ImportCommitServer) (retErr error) {
+	var request *pfs.ImportCommitRequest
+	var commit *pfs.Commit
+	defer func(start time.Time) {
+		if request != nil {
+			request.Record = nil // records hold file content, keep it out of the logs
+		}
+		a.Log(request, commit, retErr, time.Since(start))
+	}(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(importCommitServer.Context())
+	defer close(done)
+
+	request, err := importCommitServer.Recv()
 	if err != nil {
-		return nil, err
+		return err
+	}
+	// The import always gets a new commit, the IDs in the records are
+	// ignored so they can't conflict with commits that are already there.
+	commit, err = a.StartCommit(ctx, &pfs.StartCommitRequest{
+		Repo:     request.Repo,
+		ParentID: request.ParentID,
+		Branch:   request.Branch,
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if retErr != nil {
+			// don't leave a partial import open, ctx may have been
+			// cancelled by the client giving up so we don't use it
+			a.FinishCommit(context.Background(), &pfs.FinishCommitRequest{Commit: commit, Cancel: true})
+		}
+	}()
+	importer := &commitImporter{
+		apiServer: a,
+		ctx:       ctx,
+		commit:    commit,
+		madeDirs:  make(map[string]bool),
+	}
+	defer func() {
+		if err := importer.closeFile(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	for record := request.Record; ; {
+		if record != nil {
+			if err := importer.importRecord(record); err != nil {
+				return err
+			}
+		}
+		nextRequest, err := importCommitServer.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		record = nextRequest.Record
+	}
+	if err := importer.closeFile(); err != nil {
+		return err
+	}
+	if _, err := a.FinishCommit(ctx, &pfs.FinishCommitRequest{Commit: commit}); err != nil {
+		return err
 	}
+	return importCommitServer.SendAndClose(commit)
+}
 
-	if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
-		// If it's a directory, chances are that its children are scattered around
-		// the cluster, so we can't get the complete list of children without
-		// doing a multicast.  So instead of returning a partial list of children,
-		// we'd rather return no children at all.  To get the complete list of
-		// children, the caller is expected to use ListFile
-		fileInfo.Children = nil
+// commitImporter writes the records of an export to a commit, a file's
+// content is streamed to the server that owns it as the records arrive.
+type commitImporter struct {
+	apiServer     *apiServer
+	ctx           context.Context
+	commit        *pfs.Commit
+	madeDirs      map[string]bool
+	clientConn    *grpc.ClientConn
+	putFileClient pfs.InternalAPI_PutFileClient
+}
+
+func (i *commitImporter) importRecord(record *pfs.ExportRecord) error {
+	if record.FileInfo == nil {
+		if i.putFileClient == nil {
+			if len(record.Value) > 0 {
+				return fmt.Errorf("ExportRecord has a value but no file to write it to")
+			}
+			return nil
+		}
+		return i.putFileClient.Send(&pfs.PutFileRequest{Value: record.Value})
+	}
+	if err := i.closeFile(); err != nil {
+		return err
+	}
+	filePath := record.FileInfo.File.Path
+	file := client.NewFile(i.commit.Repo.Name, i.commit.ID, filePath)
+	if err := checkFilePath(file); err != nil {
+		return err
+	}
+	toMake := dirs(filePath)
+	if record.FileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+		toMake = append(toMake, filePath)
+	}
+	for _, dir := range toMake {
+		if i.madeDirs[dir] {
+			continue
+		}
+		if err := i.apiServer.makeDirectory(i.ctx, client.NewFile(i.commit.Repo.Name, i.commit.ID, dir)); err != nil {
+			return err
+		}
+		i.madeDirs[dir] = true
+	}
+	if record.FileInfo.FileType != pfs.FileType_FILE_TYPE_REGULAR {
+		return nil
+	}
+	clientConn, err := i.apiServer.getClientConnForFile(file, i.apiServer.version)
+	if err != nil {
+		return err
 	}
+	putFileClient, err := pfs.NewInternalAPIClient(clientConn).PutFile(i.ctx)
+	if err != nil {
+		clientConn.Close()
+		return err
+	}
+	i.clientConn = clientConn
+	i.putFileClient = putFileClient
+	return putFileClient.Send(&pfs.PutFileRequest{
+		File:      file,
+		Value:     record.Value,
+		Delimiter: pfs.Delimiter_LINE,
+		Xattrs:    record.FileInfo.Xattrs,
+	})
+}
 
-	return fileInfo, nil
+// closeFile finishes writing the file that's currently being imported, if
+// there is one.
+func (i *commitImporter) closeFile() error {
+	if i.putFileClient == nil {
+		return nil
+	}
+	defer func() {
+		i.clientConn.Close()
+		i.clientConn = nil
+		i.putFileClient = nil
+	}()
+	_, err := i.putFileClient.CloseAndRecv()
+	return err
 }
 
-func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
+func (a *apiServer) ListShard(ctx context.Context, request *pfs.ListShardRequest) (response *pfs.ShardInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -677,17 +2202,14 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 	}
 	var wg sync.WaitGroup
 	var lock sync.Mutex
-	var fileInfos []*pfs.FileInfo
-	seenDirectories := make(map[string]bool)
+	var shardInfos []*pfs.ShardInfo
 	errCh := make(chan error, 1)
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
 		wg.Add(1)
 		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			subFileInfos, err := pfs.NewInternalAPIClient(clientConn).ListFile(ctx, request)
-			lock.Lock()
-			defer lock.Unlock()
+			subShardInfos, err := pfs.NewInternalAPIClient(clientConn).ListShard(ctx, request)
 			if err != nil {
 				select {
 				case errCh <- err:
@@ -697,15 +2219,9 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 				}
 				return
 			}
-			for _, fileInfo := range subFileInfos.FileInfo {
-				if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
-					if seenDirectories[fileInfo.File.Path] {
-						continue
-					}
-					seenDirectories[fileInfo.File.Path] = true
-				}
-				fileInfos = append(fileInfos, fileInfo)
-			}
+			lock.Lock()
+			defer lock.Unlock()
+			shardInfos = append(shardInfos, subShardInfos.ShardInfo...)
 		}(clientConn)
 	}
 	wg.Wait()
@@ -714,12 +2230,17 @@ func (a *apiServer) ListFile(ctx context.Context, request *pfs.ListFileRequest)
 		return nil, err
 	default:
 	}
-	return &pfs.FileInfos{
-		FileInfo: pfsserver.ReduceFileInfos(fileInfos),
-	}, nil
+	for _, shardInfo := range shardInfos {
+		address, err := a.router.GetAddress(shardInfo.Shard, a.version)
+		if err != nil {
+			return nil, err
+		}
+		shardInfo.Address = address
+	}
+	return &pfs.ShardInfos{ShardInfo: pfsserver.ReduceShardInfos(shardInfos)}, nil
 }
 
-func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *apiServer) ShardStats(ctx context.Context, request *pfs.ShardStatsRequest) (response *pfs.ShardStatsResponse, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	a.versionLock.RLock()
 	defer a.versionLock.RUnlock()
@@ -727,38 +2248,75 @@ func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileReque
 	ctx, done := a.getVersionContext(ctx)
 	defer close(done)
 
-	fileInfo, err := a.InspectFile(ctx, &pfs.InspectFileRequest{
-		File:   request.File,
-		Unsafe: request.Unsafe,
-		Handle: request.Handle,
-	})
+	clientConns, err := a.router.GetAllClientConns(a.version)
 	if err != nil {
 		return nil, err
 	}
-
-	if fileInfo.FileType == pfs.FileType_FILE_TYPE_REGULAR {
-		clientConn, err := a.getClientConnForFile(request.File, a.version)
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var shardStats []*pfs.ShardStat
+	errCh := make(chan error, 1)
+	for _, clientConn := range clientConns {
+		defer clientConn.Close()
+		wg.Add(1)
+		go func(clientConn *grpc.ClientConn) {
+			defer wg.Done()
+			response, err := pfs.NewInternalAPIClient(clientConn).ShardStats(ctx, request)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			shardStats = append(shardStats, response.ShardStat...)
+		}(clientConn)
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	for _, shardStat := range shardStats {
+		address, err := a.router.GetAddress(shardStat.Shard, a.version)
 		if err != nil {
 			return nil, err
 		}
-		defer clientConn.Close()
-		return pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+		shardStat.Address = address
 	}
+	return &pfs.ShardStatsResponse{ShardStat: pfsserver.ReduceShardStats(shardStats)}, nil
+}
 
-	// If we are deleting a directory, we multicast it to the entire cluster
+func (a *apiServer) BlockReferences(ctx context.Context, request *pfs.BlockReferencesRequest) (response *pfs.BlockReferencesResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	a.versionLock.RLock()
+	defer a.versionLock.RUnlock()
+
+	ctx, done := a.getVersionContext(ctx)
+	defer close(done)
+
+	if request.Block == nil || request.Block.Hash == "" {
+		return nil, fmt.Errorf("a block must be specified")
+	}
 	clientConns, err := a.router.GetAllClientConns(a.version)
 	if err != nil {
 		return nil, err
 	}
 	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var files []*pfs.File
 	errCh := make(chan error, 1)
 	for _, clientConn := range clientConns {
 		defer clientConn.Close()
 		wg.Add(1)
-		clientConn := clientConn
-		go func() {
+		go func(clientConn *grpc.ClientConn) {
 			defer wg.Done()
-			_, err := pfs.NewInternalAPIClient(clientConn).DeleteFile(ctx, request)
+			response, err := pfs.NewInternalAPIClient(clientConn).BlockReferences(ctx, request)
 			if err != nil {
 				select {
 				case errCh <- err:
@@ -768,7 +2326,10 @@ func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileReque
 				}
 				return
 			}
-		}()
+			lock.Lock()
+			defer lock.Unlock()
+			files = append(files, response.File...)
+		}(clientConn)
 	}
 	wg.Wait()
 	select {
@@ -776,7 +2337,7 @@ func (a *apiServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileReque
 		return nil, err
 	default:
 	}
-	return google_protobuf.EmptyInstance, nil
+	return &pfs.BlockReferencesResponse{File: pfsserver.ReduceBlockReferences(files)}, nil
 }
 
 func (a *apiServer) Version(version int64) error {
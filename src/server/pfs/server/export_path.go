@@ -0,0 +1,139 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pachyderm/pachyderm/src/client/pfs"
+)
+
+// pathExporter writes the records of an exported commit to a directory tree
+// on the local filesystem, one file at a time. PFS has no file modes or
+// symlinks, files are written with mode 0644 and directories with 0755.
+// Nothing is written outside of exportRoot, including through symlinks that
+// are already on disk.
+type pathExporter struct {
+	// exportRoot is the export root with its symlinks resolved
+	exportRoot string
+	root       string
+	file       *os.File
+	files      uint64
+	sizeBytes  uint64
+}
+
+// newPathExporter returns a pathExporter that writes to path, which is
+// relative to exportRoot. exportRoot must already exist.
+func newPathExporter(exportRoot string, path string) (*pathExporter, error) {
+	exportRoot, err := filepath.EvalSymlinks(exportRoot)
+	if err != nil {
+		return nil, err
+	}
+	cleanPath := filepath.Clean(path)
+	if filepath.IsAbs(path) || cleanPath == ".." || strings.HasPrefix(cleanPath, ".."+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%s isn't a path within the export root", path)
+	}
+	e := &pathExporter{
+		exportRoot: exportRoot,
+		root:       filepath.Join(exportRoot, cleanPath),
+	}
+	if err := e.mkdirAll(e.root); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// localPath maps a path in PFS to one under the root, cleaning it first so
+// that it can't escape the root.
+func (e *pathExporter) localPath(pfsPath string) string {
+	return filepath.Join(e.root, filepath.Clean("/"+pfsPath))
+}
+
+// checkConfined returns an error if localPath, or the closest of its parents
+// that exists, resolves to somewhere outside of the export root.
+func (e *pathExporter) checkConfined(localPath string) error {
+	existing := localPath
+	for {
+		_, err := os.Lstat(existing)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if resolved != e.exportRoot && !strings.HasPrefix(resolved, e.exportRoot+string(filepath.Separator)) {
+		return fmt.Errorf("%s is outside of the export root", localPath)
+	}
+	return nil
+}
+
+// mkdirAll is os.MkdirAll except that it won't create directories outside
+// of the export root.
+func (e *pathExporter) mkdirAll(dir string) error {
+	if err := e.checkConfined(dir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// a symlink may have been swapped in while the directories were made
+	return e.checkConfined(dir)
+}
+
+func (e *pathExporter) write(record *pfs.ExportRecord) error {
+	if record.FileInfo != nil {
+		if err := e.closeFile(); err != nil {
+			return err
+		}
+		fileInfo := record.FileInfo
+		localPath := e.localPath(fileInfo.File.Path)
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if err := e.mkdirAll(localPath); err != nil {
+				return err
+			}
+			return setXattrs(localPath, fileInfo.Xattrs)
+		}
+		if err := e.mkdirAll(filepath.Dir(localPath)); err != nil {
+			return err
+		}
+		if info, err := os.Lstat(localPath); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%s is a symlink, it won't be written through", localPath)
+		}
+		file, err := os.OpenFile(localPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+		if err != nil {
+			return err
+		}
+		e.file = file
+		e.files++
+		if err := setXattrs(localPath, fileInfo.Xattrs); err != nil {
+			return err
+		}
+	}
+	if len(record.Value) == 0 {
+		return nil
+	}
+	if e.file == nil {
+		return fmt.Errorf("export record has a value but no file to write it to (this is likely a bug)")
+	}
+	if _, err := e.file.Write(record.Value); err != nil {
+		return err
+	}
+	e.sizeBytes += uint64(len(record.Value))
+	return nil
+}
+
+func (e *pathExporter) closeFile() error {
+	if e.file == nil {
+		return nil
+	}
+	err := e.file.Close()
+	e.file = nil
+	return err
+}
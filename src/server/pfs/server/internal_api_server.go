@@ -1,16 +1,23 @@
 package server
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"math"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"go.pedge.io/lion/proto"
 	"go.pedge.io/pb/go/google/protobuf"
 	"go.pedge.io/proto/rpclog"
 	"go.pedge.io/proto/stream"
+	"go.pedge.io/proto/time"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/metadata"
@@ -33,21 +40,36 @@ type internalAPIServer struct {
 	driver            drive.Driver
 	commitWaiters     map[*commitWait]bool
 	commitWaitersLock sync.Mutex
+	shardStates       map[uint64]pfs.ShardState
+	shardStatesLock   sync.Mutex
+	urlFetcher        *urlFetcher
 }
 
 func newInternalAPIServer(
 	hasher *pfsserver.Hasher,
 	router shard.Router,
 	driver drive.Driver,
+	options InternalAPIServerOptions,
 ) *internalAPIServer {
-	return &internalAPIServer{
+	a := &internalAPIServer{
 		Logger:            protorpclog.NewLogger("pachyderm.pfsserver.InternalAPI"),
 		hasher:            hasher,
 		router:            router,
 		driver:            driver,
 		commitWaiters:     make(map[*commitWait]bool),
 		commitWaitersLock: sync.Mutex{},
-	}
+		shardStates:       make(map[uint64]pfs.ShardState),
+		shardStatesLock:   sync.Mutex{},
+		urlFetcher:        newURLFetcher(!options.AllowPrivateURLs),
+	}
+	// the driver cancels stale commits on its own, the callers waiting on
+	// them need to hear about it like they would from FinishCommit
+	driver.OnStaleCommitCancelled(func(commit *pfs.Commit, shards map[uint64]bool) {
+		if err := a.pulseCommitWaiters(commit, pfs.CommitType_COMMIT_TYPE_READ, shards); err != nil {
+			protolion.Errorf("error notifying waiters of cancelled commit %s/%s: %s", commit.Repo.Name, commit.ID, err.Error())
+		}
+	})
+	return a
 }
 
 func (a *internalAPIServer) CreateRepo(ctx context.Context, request *pfs.CreateRepoRequest) (response *google_protobuf.Empty, retErr error) {
@@ -60,7 +82,7 @@ func (a *internalAPIServer) CreateRepo(ctx context.Context, request *pfs.CreateR
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.CreateRepo(request.Repo, request.Created, request.Provenance, shards); err != nil {
+	if err := a.driver.CreateRepo(request.Repo, request.Created, request.Provenance, request.CreateIfNotExists, shards); err != nil {
 		return nil, err
 	}
 	return google_protobuf.EmptyInstance, nil
@@ -76,7 +98,7 @@ func (a *internalAPIServer) InspectRepo(ctx context.Context, request *pfs.Inspec
 	if err != nil {
 		return nil, err
 	}
-	return a.driver.InspectRepo(request.Repo, shards)
+	return a.driver.InspectRepo(request.Repo, request.IncludeDag, request.IncludeBranchHeads, shards)
 }
 
 func (a *internalAPIServer) ListRepo(ctx context.Context, request *pfs.ListRepoRequest) (response *pfs.RepoInfos, retErr error) {
@@ -124,7 +146,7 @@ func (a *internalAPIServer) StartCommit(ctx context.Context, request *pfs.StartC
 		return nil, err
 	}
 	if err := a.driver.StartCommit(request.Repo, request.ID, request.ParentID,
-		request.Branch, request.Started, request.Provenance, shards); err != nil {
+		request.Branch, request.Started, request.Provenance, request.FromCommit, request.Reserved, shards); err != nil {
 		return nil, err
 	}
 	if err := a.pulseCommitWaiters(client.NewCommit(request.Repo.Name, request.ID), pfs.CommitType_COMMIT_TYPE_WRITE, shards); err != nil {
@@ -133,6 +155,38 @@ func (a *internalAPIServer) StartCommit(ctx context.Context, request *pfs.StartC
 	return google_protobuf.EmptyInstance, nil
 }
 
+func (a *internalAPIServer) ReserveCommit(ctx context.Context, request *pfs.ReserveCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.ReserveCommit(client.NewCommit(request.Repo.Name, request.ID), shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) UnstartCommit(ctx context.Context, request *pfs.UnstartCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.UnstartCommit(request.Commit, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
 func (a *internalAPIServer) FinishCommit(ctx context.Context, request *pfs.FinishCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
@@ -143,7 +197,11 @@ func (a *internalAPIServer) FinishCommit(ctx context.Context, request *pfs.Finis
 	if err != nil {
 		return nil, err
 	}
-	if err := a.driver.FinishCommit(request.Commit, request.Finished, request.Cancel, shards); err != nil {
+	if err := a.driver.FinishCommit(request.Commit, request.Finished, request.Cancel, drive.FinishCommitOptions{
+		Description:    request.Description,
+		Annotations:    request.Annotations,
+		ExpectedParent: request.ExpectedParent,
+	}, shards); err != nil {
 		return nil, err
 	}
 	if err := a.pulseCommitWaiters(request.Commit, pfs.CommitType_COMMIT_TYPE_READ, shards); err != nil {
@@ -167,6 +225,10 @@ func (a *internalAPIServer) InspectCommit(ctx context.Context, request *pfs.Insp
 
 func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCommitRequest) (response *pfs.CommitInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.Block && (request.Branch != "" || request.BaseBranch != "") {
+		// commitWaiters don't track branch heads
+		return nil, fmt.Errorf("block can't be used with branch or base_branch")
+	}
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
@@ -176,7 +238,7 @@ func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCom
 		return nil, err
 	}
 	commitInfos, err := a.driver.ListCommit(request.Repo, request.CommitType,
-		request.FromCommit, request.Provenance, request.All, shards)
+		request.FromCommit, request.Provenance, request.All, listCommitOptions(request), shards)
 	_, ok := err.(*pfsserver.ErrRepoNotFound)
 	if err != nil && (!request.Block || !ok) {
 		return nil, err
@@ -198,6 +260,124 @@ func (a *internalAPIServer) ListCommit(ctx context.Context, request *pfs.ListCom
 	}, nil
 }
 
+// listCommitOptions returns the driver options for request.
+func listCommitOptions(request *pfs.ListCommitRequest) drive.ListCommitOptions {
+	return drive.ListCommitOptions{
+		IncludeDeleted: request.IncludeDeleted,
+		Annotations:    request.Annotations,
+		Branch:         request.Branch,
+		BaseBranch:     request.BaseBranch,
+	}
+}
+
+func (a *internalAPIServer) CommitChangedFiles(ctx context.Context, request *pfs.CommitChangedFilesRequest) (response *pfs.FileChanges, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	fileChanges, err := a.driver.CommitChangedFiles(request.Commit, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileChanges{FileChange: fileChanges}, nil
+}
+
+func (a *internalAPIServer) CommitDiff(ctx context.Context, request *pfs.CommitDiffRequest) (response *pfs.FileChanges, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	fileChanges, err := a.driver.CommitDiff(request.FromCommit, request.ToCommit, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileChanges{FileChange: fileChanges}, nil
+}
+
+func (a *internalAPIServer) CommitManifest(request *pfs.CommitManifestRequest, commitManifestServer pfs.InternalAPI_CommitManifestServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(commitManifestServer.Context())
+	if err != nil {
+		return err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return err
+	}
+	// the driver returns each shard's entries sorted by path, they're merged
+	// so that this server's stream is sorted too
+	var streams []pfsserver.ManifestEntryStream
+	for shard := range shards {
+		shardManifestEntries, err := a.driver.CommitManifest(request.Commit, shard)
+		if err != nil {
+			return err
+		}
+		streams = append(streams, &manifestEntrySlice{manifestEntries: shardManifestEntries})
+	}
+	return pfsserver.MergeManifestEntries(streams, commitManifestServer.Send)
+}
+
+// manifestEntrySlice is a ManifestEntryStream of entries that are already in
+// memory.
+type manifestEntrySlice struct {
+	manifestEntries []*pfs.ManifestEntry
+}
+
+func (s *manifestEntrySlice) Recv() (*pfs.ManifestEntry, error) {
+	if len(s.manifestEntries) == 0 {
+		return nil, io.EOF
+	}
+	manifestEntry := s.manifestEntries[0]
+	s.manifestEntries = s.manifestEntries[1:]
+	return manifestEntry, nil
+}
+
+func (a *internalAPIServer) PackCommit(ctx context.Context, request *pfs.PackCommitRequest) (response *pfs.PackCommitResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	filesPacked, err := a.driver.PackCommit(request.Commit, request.MaxFileSizeBytes, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.PackCommitResponse{FilesPacked: filesPacked}, nil
+}
+
+func (a *internalAPIServer) OpenCommits(ctx context.Context, request *pfs.OpenCommitsRequest) (response *pfs.CommitInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	commitInfos, err := a.driver.OpenCommits(shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.CommitInfos{
+		CommitInfo: commitInfos,
+	}, nil
+}
+
 func (a *internalAPIServer) ListBranch(ctx context.Context, request *pfs.ListBranchRequest) (response *pfs.CommitInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
@@ -217,6 +397,19 @@ func (a *internalAPIServer) ListBranch(ctx context.Context, request *pfs.ListBra
 	}, nil
 }
 
+func (a *internalAPIServer) InspectBranch(ctx context.Context, request *pfs.InspectBranchRequest) (response *pfs.CommitInfo, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	return a.driver.InspectBranch(request.Repo, request.Branch, shards)
+}
+
 func (a *internalAPIServer) DeleteCommit(ctx context.Context, request *pfs.DeleteCommitRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
@@ -227,6 +420,12 @@ func (a *internalAPIServer) DeleteCommit(ctx context.Context, request *pfs.Delet
 	if err != nil {
 		return nil, err
 	}
+	if request.Soft {
+		if err := a.driver.SoftDeleteCommit(request.Commit, request.Deleted, shards); err != nil {
+			return nil, err
+		}
+		return google_protobuf.EmptyInstance, nil
+	}
 	if err := a.driver.DeleteCommit(request.Commit, shards); err != nil {
 		return nil, err
 	}
@@ -234,6 +433,22 @@ func (a *internalAPIServer) DeleteCommit(ctx context.Context, request *pfs.Delet
 	return google_protobuf.EmptyInstance, nil
 }
 
+func (a *internalAPIServer) RestoreCommit(ctx context.Context, request *pfs.RestoreCommitRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.RestoreCommit(request.Commit, shards); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
 func (a *internalAPIServer) FlushCommit(ctx context.Context, request *pfs.FlushCommitRequest) (response *pfs.CommitInfos, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
@@ -257,7 +472,7 @@ func (a *internalAPIServer) FlushCommit(ctx context.Context, request *pfs.FlushC
 	repoWhiteList := make(map[string]bool)
 	for _, toRepo := range request.ToRepo {
 		repoWhiteList[toRepo.Name] = true
-		repoInfo, err := a.driver.InspectRepo(toRepo, shards)
+		repoInfo, err := a.driver.InspectRepo(toRepo, false, false, shards)
 		if err != nil {
 			return nil, err
 		}
@@ -367,115 +582,450 @@ func (a *internalAPIServer) PutFile(putFileServer pfs.InternalAPI_PutFileServer)
 		if err != nil {
 			return err
 		}
-		if err := a.driver.PutFile(request.File, request.Handle, request.Delimiter, shard, &reader); err != nil {
+		var expires *google_protobuf.Timestamp
+		if request.TtlSeconds > 0 {
+			expires = prototime.TimeToTimestamp(time.Now().Add(time.Duration(request.TtlSeconds) * time.Second))
+		}
+		if request.OffsetBytes != nil {
+			if request.Handle != "" {
+				return fmt.Errorf("PutFileRequest shouldn't have a handle and an offset")
+			}
+			if request.ContentType != "" {
+				return fmt.Errorf("PutFileRequest shouldn't have a content type and an offset")
+			}
+			if err := a.driver.PutFileAt(request.File, request.OffsetBytes.Value, request.ExpectedHash,
+				expires, request.Xattrs, shard, &reader); err != nil {
+				return err
+			}
+			return nil
+		}
+		if err := a.driver.PutFile(request.File, request.Handle, request.Delimiter, drive.PutFileOptions{
+			IdempotencyKey: request.IdempotencyKey,
+			ExpectedHash:   request.ExpectedHash,
+			ContentType:    request.ContentType,
+			Expires:        expires,
+			Xattrs:         request.Xattrs,
+		}, shard, &reader); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (a *internalAPIServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.InternalAPI_GetFileServer) (retErr error) {
-	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
-	version, err := a.getVersion(apiGetFileServer.Context())
+func (a *internalAPIServer) PutFileURL(ctx context.Context, request *pfs.PutFileURLRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) {
+		request.Headers = nil // headers may hold credentials, keep them out of the logs
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
+	version, err := a.getVersion(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	shard, err := a.getShardForFile(request.File, version)
+	if strings.HasPrefix(request.File.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	file, err := a.driver.GetFile(request.File, request.Shard, request.OffsetBytes, request.SizeBytes,
-		request.FromCommit, shard, request.Unsafe, request.Handle)
+	httpResponse, err := a.urlFetcher.get(ctx, request.Url, request.Headers)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer func() {
-		if err := file.Close(); err != nil && retErr == nil {
-			retErr = err
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error fetching %s: %s", request.Url, httpResponse.Status)
+	}
+	// the body is streamed into blocks, it's never held in memory
+	var reader io.Reader = httpResponse.Body
+	if request.MaxBytes > 0 {
+		if httpResponse.ContentLength > 0 && uint64(httpResponse.ContentLength) > request.MaxBytes {
+			return nil, fmt.Errorf("%s has %d bytes which exceeds the limit of %d", request.Url, httpResponse.ContentLength, request.MaxBytes)
 		}
-	}()
-	return protostream.WriteToStreamingBytesServer(file, apiGetFileServer)
+		// ContentLength may be missing or wrong so we count as well
+		reader = &maxBytesReader{reader: reader, remaining: request.MaxBytes, url: request.Url, max: request.MaxBytes}
+	}
+	if err := a.driver.PutFile(request.File, "", request.Delimiter, drive.PutFileOptions{}, shard, reader); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *internalAPIServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
-	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+func (a *internalAPIServer) PutFileMulti(ctx context.Context, request *pfs.PutFileMultiRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) {
+		request.Value = nil // we set the value to nil so as not to spam logs
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	shard, err := a.getShardForFile(request.File, version)
+	if strings.HasPrefix(request.File.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
 	if err != nil {
 		return nil, err
 	}
-	return a.driver.InspectFile(request.File, request.Shard, request.FromCommit, shard, request.Unsafe, request.Handle)
+	var readers []io.Reader
+	for _, value := range request.Value {
+		readers = append(readers, bytes.NewReader(value))
+	}
+	// the driver adds all of a put's blocks to the file while holding its
+	// lock, so putting the values together keeps them contiguous
+	if err := a.driver.PutFile(request.File, "", request.Delimiter, drive.PutFileOptions{}, shard, io.MultiReader(readers...)); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *internalAPIServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
+// defaultStagedBlobTTL is how long a blob stays staged if the request
+// doesn't say.
+const defaultStagedBlobTTL = time.Hour
+
+func (a *internalAPIServer) StageBlob(stageBlobServer pfs.InternalAPI_StageBlobServer) (retErr error) {
+	var request *pfs.StageBlobRequest
+	var response *pfs.StagedBlob
+	defer func(start time.Time) {
+		if request != nil {
+			request.Value = nil // we set the value to nil so as not to spam logs
+		}
+		a.Log(request, response, retErr, time.Since(start))
+	}(time.Now())
+	defer drainStageBlobServer(stageBlobServer)
+	version, err := a.getVersion(stageBlobServer.Context())
+	if err != nil {
+		return err
+	}
+	request, err = stageBlobServer.Recv()
+	if err != nil {
+		return err
+	}
+	shard := a.hasher.HashStagedBlob(request.Handle)
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return err
+	}
+	if !shards[shard] {
+		return fmt.Errorf("pachyderm: shard %d not found locally", shard)
+	}
+	ttl := defaultStagedBlobTTL
+	if request.TtlSeconds > 0 {
+		ttl = time.Duration(request.TtlSeconds) * time.Second
+	}
+	reader := stageBlobReader{
+		server: stageBlobServer,
+	}
+	reader.buffer.Write(request.Value)
+	sizeBytes, err := a.driver.StageBlob(request.Handle, request.Delimiter, time.Now().Add(ttl), shard, &reader)
+	if err != nil {
+		return err
+	}
+	response = &pfs.StagedBlob{
+		Handle:    request.Handle,
+		SizeBytes: sizeBytes,
+	}
+	return stageBlobServer.SendAndClose(response)
+}
+
+func (a *internalAPIServer) InspectStagedBlob(ctx context.Context, request *pfs.InspectStagedBlobRequest) (response *pfs.BlockRefs, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
+	shard := a.hasher.HashStagedBlob(request.Handle)
 	shards, err := a.router.GetShards(version)
 	if err != nil {
 		return nil, err
 	}
-	var wg sync.WaitGroup
-	var lock sync.Mutex
-	var fileInfos []*pfs.FileInfo
-	errCh := make(chan error, 1)
-	for shard := range shards {
-		shard := shard
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			subFileInfos, err := a.driver.ListFile(request.File, request.Shard,
-				request.FromCommit, shard, request.Recurse, request.Unsafe, request.Handle)
-			_, ok := err.(*pfsserver.ErrFileNotFound)
-			if err != nil && !ok {
-				select {
-				case errCh <- err:
-					// error reported
-				default:
-					// not the first error
-				}
-				return
-			}
-			lock.Lock()
-			defer lock.Unlock()
-			fileInfos = append(fileInfos, subFileInfos...)
-		}()
+	if !shards[shard] {
+		return nil, fmt.Errorf("pachyderm: shard %d not found locally", shard)
 	}
-	wg.Wait()
-	select {
-	case err := <-errCh:
+	blockRefs, err := a.driver.StagedBlob(request.Handle, shard)
+	if err != nil {
 		return nil, err
-	default:
 	}
-	return &pfs.FileInfos{
-		FileInfo: fileInfos,
-	}, nil
+	return &pfs.BlockRefs{BlockRef: blockRefs}, nil
 }
 
-func (a *internalAPIServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+func (a *internalAPIServer) PutFileStaged(ctx context.Context, request *pfs.PutFileStagedRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
 	version, err := a.getVersion(ctx)
 	if err != nil {
 		return nil, err
 	}
-	shards, err := a.router.GetShards(version)
+	if strings.HasPrefix(request.File.Path, "/") {
+		return nil, fmt.Errorf("pachyderm: leading slash in path: %s", request.File.Path)
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
 	if err != nil {
 		return nil, err
 	}
-	var wg sync.WaitGroup
-	errCh := make(chan error, 1)
-	for shard := range shards {
-		shard := shard
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			err := a.driver.DeleteFile(request.File, shard, request.Unsafe, request.Handle)
-			// We are ignoring ErrFileNotFound because the file being
+	if err := a.driver.PutFileBlockRefs(request.File, request.BlockRef, shard); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) GetFile(request *pfs.GetFileRequest, apiGetFileServer pfs.InternalAPI_GetFileServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(apiGetFileServer.Context())
+	if err != nil {
+		return err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return err
+	}
+	if (request.SourceEncoding == "") != (request.TargetEncoding == "") {
+		return fmt.Errorf("GetFileRequest should have both a source and a target encoding or neither")
+	}
+	sizeBytes := request.SizeBytes
+	if request.LineRange != nil {
+		if request.OffsetBytes != 0 {
+			return fmt.Errorf("GetFileRequest shouldn't have a line range and an offset")
+		}
+		// we don't know where the lines end until we've read them
+		sizeBytes = math.MaxInt64
+	}
+	var file io.ReadCloser
+	if len(request.MergeCommit) > 0 {
+		if request.FromCommit != nil {
+			return fmt.Errorf("GetFileRequest shouldn't have a from commit and merge commits")
+		}
+		file, err = a.driver.GetFileMerged(request.File, request.MergeCommit, request.Shard, request.OffsetBytes,
+			sizeBytes, shard, request.Unsafe, request.Handle)
+	} else if request.DiffOnly {
+		if request.FromCommit != nil {
+			return fmt.Errorf("GetFileRequest shouldn't have a from commit and diff only")
+		}
+		file, err = a.driver.GetFileDiff(request.File, request.Shard, request.OffsetBytes,
+			sizeBytes, shard, request.Unsafe, request.Handle)
+	} else {
+		file, err = a.driver.GetFile(request.File, request.Shard, request.OffsetBytes, sizeBytes,
+			request.FromCommit, shard, request.Unsafe, request.Handle)
+	}
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := file.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	var reader io.Reader = file
+	if request.LineRange != nil {
+		reader = newLineRangeReader(file, request.LineRange.Start, request.LineRange.Count)
+	}
+	if request.SourceEncoding != "" {
+		if reader, err = newTranscodingReader(reader, request.SourceEncoding, request.TargetEncoding); err != nil {
+			return err
+		}
+	}
+	if request.Base64 {
+		return writeBase64ToStreamingBytesServer(reader, apiGetFileServer)
+	}
+	return protostream.WriteToStreamingBytesServer(reader, apiGetFileServer)
+}
+
+func (a *internalAPIServer) InspectFile(ctx context.Context, request *pfs.InspectFileRequest) (response *pfs.FileInfo, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	return a.driver.InspectFile(request.File, request.Shard, request.FromCommit, shard, request.Unsafe, request.Handle)
+}
+
+func (a *internalAPIServer) FileType(ctx context.Context, request *pfs.FileTypeRequest) (response *pfs.FileTypeResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	fileType, err := a.driver.FileType(request.File, shard, request.Unsafe)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileTypeResponse{FileType: fileType}, nil
+}
+
+func (a *internalAPIServer) SetXattr(ctx context.Context, request *pfs.SetXattrRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.SetXattr(request.File, request.Name, request.Value, shard); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) FileCompareAndSwap(ctx context.Context, request *pfs.FileCompareAndSwapRequest) (response *pfs.FileCompareAndSwapResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	swapped, hash, err := a.driver.FileCompareAndSwap(request.File, request.ExpectedHash, request.Value, shard)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.FileCompareAndSwapResponse{
+		Swapped: swapped,
+		Hash:    hash,
+	}, nil
+}
+
+func (a *internalAPIServer) SetImmutable(ctx context.Context, request *pfs.SetImmutableRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getMasterShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.driver.SetImmutable(request.File, shard); err != nil {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) CheckMutable(ctx context.Context, request *pfs.CheckMutableRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	for shard := range shards {
+		if err := a.driver.CheckMutable(request.File, shard); err != nil {
+			return nil, err
+		}
+	}
+	return google_protobuf.EmptyInstance, nil
+}
+
+func (a *internalAPIServer) GetXattr(ctx context.Context, request *pfs.GetXattrRequest) (response *google_protobuf.StringValue, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shard, err := a.getShardForFile(request.File, version)
+	if err != nil {
+		return nil, err
+	}
+	fileInfo, err := a.driver.InspectFile(request.File, nil, nil, shard, request.Unsafe, "")
+	if err != nil {
+		return nil, err
+	}
+	value, ok := fileInfo.Xattrs[request.Name]
+	if !ok {
+		return nil, fmt.Errorf("%s has no xattr %s", request.File.Path, request.Name)
+	}
+	return &google_protobuf.StringValue{Value: value}, nil
+}
+
+func (a *internalAPIServer) ListFile(ctx context.Context, request *pfs.ListFileRequest) (response *pfs.FileInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	if request.Union && request.IncludeDeleted {
+		return nil, fmt.Errorf("union can't be used with include_deleted")
+	}
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	var fileInfos []*pfs.FileInfo
+	errCh := make(chan error, 1)
+	for shard := range shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			listFile := a.driver.ListFile
+			if request.Union {
+				listFile = a.driver.ListFileUnion
+			}
+			if request.IncludeDeleted {
+				listFile = a.driver.ListFileWithDeleted
+			}
+			subFileInfos, err := listFile(request.File, request.Shard,
+				request.FromCommit, shard, request.Recurse, request.Unsafe, request.Handle)
+			_, ok := err.(*pfsserver.ErrFileNotFound)
+			if err != nil && !ok {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			fileInfos = append(fileInfos, subFileInfos...)
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return &pfs.FileInfos{
+		FileInfo: fileInfos,
+	}, nil
+}
+
+func (a *internalAPIServer) DeleteFile(ctx context.Context, request *pfs.DeleteFileRequest) (response *google_protobuf.Empty, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	var wg sync.WaitGroup
+	errCh := make(chan error, 1)
+	for shard := range shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := a.driver.DeleteFile(request.File, shard, request.Unsafe, request.Handle)
+			// We are ignoring ErrFileNotFound because the file being
 			// deleted can be a directory, and directory is scattered
 			// across many DiffInfos across many shards.  Yet not all
 			// shards necessarily contain DiffInfos that contain the
@@ -501,14 +1051,303 @@ func (a *internalAPIServer) DeleteFile(ctx context.Context, request *pfs.DeleteF
 	return google_protobuf.EmptyInstance, nil
 }
 
-func (a *internalAPIServer) AddShard(shard uint64) error {
+func (a *internalAPIServer) FilesExist(ctx context.Context, request *pfs.FilesExistRequest) (response *pfs.FilesExistResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	// A regular file only exists in its own shard but a directory exists in
+	// every shard that has something under it, so we check every shard.
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var responses []*pfs.FilesExistResponse
+	errCh := make(chan error, 1)
+	for shard := range shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			exists, err := a.driver.FilesExist(request.File, shard, request.Unsafe)
+			if err != nil {
+				select {
+				case errCh <- err:
+					// error reported
+				default:
+					// not the first error
+				}
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			responses = append(responses, &pfs.FilesExistResponse{Exists: exists})
+		}()
+	}
+	wg.Wait()
+	select {
+	case err := <-errCh:
+		return nil, err
+	default:
+	}
+	return pfsserver.ReduceFilesExistResponses(responses), nil
+}
+
+func (a *internalAPIServer) DeleteFiles(ctx context.Context, request *pfs.DeleteFilesRequest) (response *pfs.DeleteFilesResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	// Like DeleteFile we delete from every shard, the files may be
+	// directories which are scattered across shards.
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var responses []*pfs.DeleteFilesResponse
+	for shard := range shards {
+		shard := shard
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs := a.driver.DeleteFiles(request.File, shard, request.Unsafe, request.Handle)
+			response := &pfs.DeleteFilesResponse{}
+			for i, err := range errs {
+				deleteFileResult := &pfs.DeleteFileResult{File: request.File[i]}
+				if err != nil {
+					_, ok := err.(*pfsserver.ErrFileNotFound)
+					deleteFileResult.Error = err.Error()
+					deleteFileResult.NotFound = ok
+				}
+				response.Result = append(response.Result, deleteFileResult)
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			responses = append(responses, response)
+		}()
+	}
+	wg.Wait()
+	return pfsserver.ReduceDeleteFilesResponses(responses), nil
+}
+
+func (a *internalAPIServer) ExportCommit(request *pfs.ExportCommitRequest, exportCommitServer pfs.InternalAPI_ExportCommitServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(exportCommitServer.Context())
+	if err != nil {
+		return err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return err
+	}
+	// directories are in many shards, we only send each one once
+	sentDirs := make(map[string]bool)
+	for shard := range shards {
+		if err := a.exportDir(client.NewFile(request.Commit.Repo.Name, request.Commit.ID, ""),
+			shard, sentDirs, exportCommitServer.Send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *internalAPIServer) ReadShard(request *pfs.ReadShardRequest, readShardServer pfs.InternalAPI_ReadShardServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(readShardServer.Context())
+	if err != nil {
+		return err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return err
+	}
+	if !shards[request.Shard] {
+		return fmt.Errorf("shard %d isn't served by this server", request.Shard)
+	}
+	return a.exportDir(client.NewFile(request.Commit.Repo.Name, request.Commit.ID, ""),
+		request.Shard, nil, readShardServer.Send)
+}
+
+// exportDir sends a record for everything beneath dir in shard to send,
+// regular files are followed by their content. Directories are only sent if
+// sentDirs is non-nil.
+func (a *internalAPIServer) exportDir(dir *pfs.File, shard uint64, sentDirs map[string]bool,
+	send func(*pfs.ExportRecord) error) error {
+	fileInfos, err := a.driver.ListFile(dir, nil, nil, shard, false, false, "")
+	if _, ok := err.(*pfsserver.ErrFileNotFound); ok {
+		// nothing under dir lives in this shard
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, fileInfo := range fileInfos {
+		// the infos we get back may refer to the commits the files were
+		// last changed in, we export them as part of dir's commit
+		file := client.NewFile(dir.Commit.Repo.Name, dir.Commit.ID, fileInfo.File.Path)
+		fileInfo.File = file
+		fileInfo.Children = nil
+		if fileInfo.FileType == pfs.FileType_FILE_TYPE_DIR {
+			if sentDirs != nil && !sentDirs[file.Path] {
+				sentDirs[file.Path] = true
+				if err := send(&pfs.ExportRecord{FileInfo: fileInfo}); err != nil {
+					return err
+				}
+			}
+			if err := a.exportDir(file, shard, sentDirs, send); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := send(&pfs.ExportRecord{FileInfo: fileInfo}); err != nil {
+			return err
+		}
+		if err := a.exportFileContent(file, shard, send); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportChunkSize is the most file content that's sent in a single
+// ExportRecord.
+const exportChunkSize = 1024 * 1024
+
+func (a *internalAPIServer) exportFileContent(file *pfs.File, shard uint64,
+	send func(*pfs.ExportRecord) error) (retErr error) {
+	reader, err := a.driver.GetFile(file, nil, 0, math.MaxInt64, nil, shard, false, "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := reader.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	buffer := make([]byte, exportChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		if n > 0 {
+			if err := send(&pfs.ExportRecord{Value: buffer[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (a *internalAPIServer) ListShard(ctx context.Context, request *pfs.ListShardRequest) (response *pfs.ShardInfos, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	a.shardStatesLock.Lock()
+	defer a.shardStatesLock.Unlock()
+	var shardInfos []*pfs.ShardInfo
+	for shard := range shards {
+		shardInfos = append(shardInfos, &pfs.ShardInfo{
+			Shard: shard,
+			State: a.shardStates[shard],
+		})
+	}
+	return &pfs.ShardInfos{ShardInfo: shardInfos}, nil
+}
+
+func (a *internalAPIServer) ShardStats(ctx context.Context, request *pfs.ShardStatsRequest) (response *pfs.ShardStatsResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	response = &pfs.ShardStatsResponse{}
+	for shard := range shards {
+		shardStat, err := a.driver.ShardStats(shard)
+		if err != nil {
+			return nil, err
+		}
+		response.ShardStat = append(response.ShardStat, shardStat)
+	}
+	return response, nil
+}
+
+func (a *internalAPIServer) BlockReferences(ctx context.Context, request *pfs.BlockReferencesRequest) (response *pfs.BlockReferencesResponse, retErr error) {
+	defer func(start time.Time) { a.Log(request, response, retErr, time.Since(start)) }(time.Now())
+	version, err := a.getVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+	shards, err := a.router.GetShards(version)
+	if err != nil {
+		return nil, err
+	}
+	files, err := a.driver.BlockReferences(request.Block, shards)
+	if err != nil {
+		return nil, err
+	}
+	return &pfs.BlockReferencesResponse{File: files}, nil
+}
+
+func (a *internalAPIServer) DumpShard(request *pfs.DumpShardRequest, dumpShardServer pfs.InternalAPI_DumpShardServer) (retErr error) {
+	defer func(start time.Time) { a.Log(request, nil, retErr, time.Since(start)) }(time.Now())
+	diffInfos, err := a.driver.DumpShard(request.Shard, request.Commit)
+	if err != nil {
+		return err
+	}
+	for _, diffInfo := range diffInfos {
+		if err := dumpShardServer.Send(diffInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *internalAPIServer) AddShard(shard uint64) (retErr error) {
+	a.setShardState(shard, pfs.ShardState_SHARD_STATE_LOADING)
+	defer func() {
+		if retErr != nil {
+			a.setShardState(shard, pfs.ShardState_SHARD_STATE_NONE)
+		} else {
+			a.setShardState(shard, pfs.ShardState_SHARD_STATE_READY)
+		}
+	}()
 	return a.driver.AddShard(shard)
 }
 
 func (a *internalAPIServer) DeleteShard(shard uint64) error {
+	a.setShardState(shard, pfs.ShardState_SHARD_STATE_NONE)
 	return a.driver.DeleteShard(shard)
 }
 
+func (a *internalAPIServer) setShardState(shard uint64, state pfs.ShardState) {
+	a.shardStatesLock.Lock()
+	defer a.shardStatesLock.Unlock()
+	if state == pfs.ShardState_SHARD_STATE_NONE {
+		delete(a.shardStates, shard)
+		return
+	}
+	a.shardStates[shard] = state
+}
+
 func (a *internalAPIServer) getMasterShardForFile(file *pfs.File, version int64) (uint64, error) {
 	shard := a.hasher.HashFile(file)
 	shards, err := a.router.GetShards(version)
@@ -535,6 +1374,90 @@ func (a *internalAPIServer) getShardForFile(file *pfs.File, version int64) (uint
 	return shard, nil
 }
 
+// base64ChunkSize is the number of bytes that get encoded into each message
+// of a base64 GetFile stream. It's a multiple of 3 so that only the last
+// message in the stream can contain padding.
+const base64ChunkSize = 3 * 64 * 1024
+
+// writeBase64ToStreamingBytesServer writes the data from reader to server
+// base64 encoded, each message sent is independently decodable.
+func writeBase64ToStreamingBytesServer(reader io.Reader, server protostream.StreamingBytesServer) error {
+	buffer := make([]byte, base64ChunkSize)
+	for {
+		n, err := io.ReadFull(reader, buffer)
+		if n > 0 {
+			if err := server.Send(&google_protobuf.BytesValue{
+				Value: []byte(base64.StdEncoding.EncodeToString(buffer[:n])),
+			}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// lineRangeReader reads count lines from reader after skipping the first
+// start lines, count 0 means it reads to the end. A final line that's
+// missing its newline still counts as a line.
+type lineRangeReader struct {
+	reader    *bufio.Reader
+	skip      uint64
+	remaining uint64
+	unlimited bool
+	// pending is the part of the current line that hasn't been read yet, it
+	// points into reader's buffer so it's only valid until reader is read
+	// from again.
+	pending []byte
+}
+
+func newLineRangeReader(reader io.Reader, start uint64, count uint64) *lineRangeReader {
+	return &lineRangeReader{
+		reader:    bufio.NewReader(reader),
+		skip:      start,
+		remaining: count,
+		unlimited: count == 0,
+	}
+}
+
+func (r *lineRangeReader) Read(p []byte) (int, error) {
+	for r.skip > 0 {
+		_, err := r.reader.ReadSlice('\n')
+		if err == bufio.ErrBufferFull {
+			// the line is longer than the buffer, keep skipping it
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.skip--
+	}
+	if len(r.pending) == 0 {
+		if !r.unlimited && r.remaining == 0 {
+			return 0, io.EOF
+		}
+		line, err := r.reader.ReadSlice('\n')
+		switch {
+		case err == bufio.ErrBufferFull:
+			// the rest of the line comes in the next slice
+		case err == nil || (err == io.EOF && len(line) > 0):
+			if !r.unlimited {
+				r.remaining--
+			}
+		default:
+			return 0, err
+		}
+		r.pending = line
+	}
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
 type putFileReader struct {
 	server pfs.InternalAPI_PutFileServer
 	buffer bytes.Buffer
@@ -552,6 +1475,41 @@ func (r *putFileReader) Read(p []byte) (int, error) {
 	return r.buffer.Read(p)
 }
 
+type stageBlobReader struct {
+	server pfs.InternalAPI_StageBlobServer
+	buffer bytes.Buffer
+}
+
+func (r *stageBlobReader) Read(p []byte) (int, error) {
+	if r.buffer.Len() == 0 {
+		request, err := r.server.Recv()
+		if err != nil {
+			return 0, err
+		}
+		//buffer.Write cannot error
+		r.buffer.Write(request.Value)
+	}
+	return r.buffer.Read(p)
+}
+
+// maxBytesReader is like io.LimitReader except it fails rather than
+// truncating once more than max bytes have been read.
+type maxBytesReader struct {
+	reader    io.Reader
+	remaining uint64
+	url       string
+	max       uint64
+}
+
+func (r *maxBytesReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if uint64(n) > r.remaining {
+		return 0, fmt.Errorf("%s has more than %d bytes", r.url, r.max)
+	}
+	r.remaining -= uint64(n)
+	return n, err
+}
+
 func (a *internalAPIServer) getVersion(ctx context.Context) (int64, error) {
 	md, ok := metadata.FromContext(ctx)
 	if !ok {
@@ -571,6 +1529,7 @@ func (a *internalAPIServer) getVersion(ctx context.Context) (int64, error) {
 type commitWait struct {
 	repos          []*pfs.Repo
 	provenance     []*pfs.Commit
+	annotations    map[string]string
 	commitType     pfs.CommitType
 	all            bool
 	commitInfoChan chan []*pfs.CommitInfo
@@ -580,6 +1539,7 @@ func (a *internalAPIServer) newCommitWait(request *pfs.ListCommitRequest, shards
 	result := &commitWait{
 		repos:          request.Repo,
 		provenance:     request.Provenance,
+		annotations:    request.Annotations,
 		commitType:     request.CommitType,
 		all:            request.All,
 		commitInfoChan: make(chan []*pfs.CommitInfo, 1),
@@ -589,7 +1549,7 @@ func (a *internalAPIServer) newCommitWait(request *pfs.ListCommitRequest, shards
 	// We need to redo the call to ListCommit because commits may have been
 	// created between then and now.
 	commitInfos, err := a.driver.ListCommit(request.Repo, request.CommitType,
-		request.FromCommit, request.Provenance, request.All, shards)
+		request.FromCommit, request.Provenance, request.All, listCommitOptions(request), shards)
 	_, ok := err.(*pfsserver.ErrRepoNotFound)
 	if err != nil && !ok {
 		return nil, err
@@ -620,7 +1580,8 @@ WaitersLoop:
 	for commitWaiter := range a.commitWaiters {
 		if (commitWaiter.commitType == pfs.CommitType_COMMIT_TYPE_NONE || commitType == commitWaiter.commitType) &&
 			(commitWaiter.all || !commitInfo.Cancelled) &&
-			drive.MatchProvenance(commitWaiter.provenance, commitInfo.Provenance) {
+			drive.MatchProvenance(commitWaiter.provenance, commitInfo.Provenance) &&
+			drive.MatchAnnotations(commitWaiter.annotations, commitInfo.Annotations) {
 			for _, repo := range commitWaiter.repos {
 				if repo.Name == commit.Repo.Name {
 					commitWaiter.commitInfoChan <- []*pfs.CommitInfo{commitInfo}
@@ -636,6 +1597,16 @@ WaitersLoop:
 	return nil
 }
 
+func drainStageBlobServer(stageBlobServer interface {
+	Recv() (*pfs.StageBlobRequest, error)
+}) {
+	for {
+		if _, err := stageBlobServer.Recv(); err != nil {
+			break
+		}
+	}
+}
+
 func drainFileServer(putFileServer interface {
 	Recv() (*pfs.PutFileRequest, error)
 }) {
@@ -94,7 +94,16 @@ func (s *localBlockAPIServer) GetBlock(request *pfsclient.GetBlockRequest, getBl
 			retErr = err
 		}
 	}()
-	reader := io.NewSectionReader(file, int64(request.OffsetBytes), int64(request.SizeBytes))
+	var reader io.Reader
+	if request.SizeBytes == 0 {
+		// 0 means the rest of the block
+		if _, err := file.Seek(int64(request.OffsetBytes), 0); err != nil {
+			return err
+		}
+		reader = file
+	} else {
+		reader = io.NewSectionReader(file, int64(request.OffsetBytes), int64(request.SizeBytes))
+	}
 	return protostream.WriteToStreamingBytesServer(reader, getBlockServer)
 }
 
@@ -132,7 +141,24 @@ func (s *localBlockAPIServer) CreateDiff(ctx context.Context, request *pfsclient
 	if err := os.MkdirAll(path.Dir(s.diffPath(request.Diff)), 0777); err != nil {
 		return nil, err
 	}
-	if err := ioutil.WriteFile(s.diffPath(request.Diff), data, 0666); err != nil {
+	// Diffs of open commits get rewritten by periodic flushes, so we write
+	// to a temporary file and rename it into place; that way a reader never
+	// sees a partially written diff.
+	file, err := ioutil.TempFile(s.tmpDir(), "diff")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if err := os.Rename(file.Name(), s.diffPath(request.Diff)); err != nil {
+		os.Remove(file.Name())
 		return nil, err
 	}
 	return google_protobuf.EmptyInstance, nil
@@ -169,7 +195,12 @@ func (s *localBlockAPIServer) ListDiff(request *pfsclient.ListDiffRequest, listD
 
 func (s *localBlockAPIServer) DeleteDiff(ctx context.Context, request *pfsclient.DeleteDiffRequest) (response *google_protobuf.Empty, retErr error) {
 	defer func(start time.Time) { s.Log(request, response, retErr, time.Since(start)) }(time.Now())
-	return google_protobuf.EmptyInstance, os.Remove(s.diffPath(request.Diff))
+	// the diff may already have been deleted by another server that shares
+	// this directory
+	if err := os.Remove(s.diffPath(request.Diff)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return google_protobuf.EmptyInstance, nil
 }
 
 func (s *localBlockAPIServer) tmpDir() string {
@@ -20,27 +20,37 @@ import (
 	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
 )
 
+// defaultPutBlockParallelism is the number of blocks a single PutBlock writes
+// to object storage at once when BlockAPIServerOptions doesn't set it.
+const defaultPutBlockParallelism = 8
+
 type objBlockAPIServer struct {
 	protorpclog.Logger
-	dir         string
-	localServer *localBlockAPIServer
-	objClient   obj.Client
+	dir                 string
+	localServer         *localBlockAPIServer
+	objClient           obj.Client
+	putBlockParallelism int
 }
 
-func newObjBlockAPIServer(dir string, objClient obj.Client) (*objBlockAPIServer, error) {
+func newObjBlockAPIServer(dir string, objClient obj.Client, options BlockAPIServerOptions) (*objBlockAPIServer, error) {
 	localServer, err := newLocalBlockAPIServer(dir)
 	if err != nil {
 		return nil, err
 	}
+	putBlockParallelism := options.PutBlockParallelism
+	if putBlockParallelism <= 0 {
+		putBlockParallelism = defaultPutBlockParallelism
+	}
 	return &objBlockAPIServer{
-		Logger:      protorpclog.NewLogger("pachyderm.pfsclient.objBlockAPIServer"),
-		dir:         dir,
-		localServer: localServer,
-		objClient:   objClient,
+		Logger:              protorpclog.NewLogger("pachyderm.pfsclient.objBlockAPIServer"),
+		dir:                 dir,
+		localServer:         localServer,
+		objClient:           objClient,
+		putBlockParallelism: putBlockParallelism,
 	}, nil
 }
 
-func newAmazonBlockAPIServer(dir string) (*objBlockAPIServer, error) {
+func newAmazonBlockAPIServer(dir string, options BlockAPIServerOptions) (*objBlockAPIServer, error) {
 	bucket, err := ioutil.ReadFile("/amazon-secret/bucket")
 	if err != nil {
 		return nil, err
@@ -65,10 +75,10 @@ func newAmazonBlockAPIServer(dir string) (*objBlockAPIServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newObjBlockAPIServer(dir, objClient)
+	return newObjBlockAPIServer(dir, objClient, options)
 }
 
-func newGoogleBlockAPIServer(dir string) (*objBlockAPIServer, error) {
+func newGoogleBlockAPIServer(dir string, options BlockAPIServerOptions) (*objBlockAPIServer, error) {
 	bucket, err := ioutil.ReadFile("/google-secret/bucket")
 	if err != nil {
 		return nil, err
@@ -77,7 +87,7 @@ func newGoogleBlockAPIServer(dir string) (*objBlockAPIServer, error) {
 	if err != nil {
 		return nil, err
 	}
-	return newObjBlockAPIServer(dir, objClient)
+	return newObjBlockAPIServer(dir, objClient, options)
 }
 
 func (s *objBlockAPIServer) PutBlock(putBlockServer pfsclient.BlockAPI_PutBlockServer) (retErr error) {
@@ -95,40 +105,45 @@ func (s *objBlockAPIServer) PutBlock(putBlockServer pfsclient.BlockAPI_PutBlockS
 		server: putBlockServer,
 		buffer: bytes.NewBuffer(putBlockRequest.Value),
 	})
+	// Blocks are written concurrently, at most putBlockParallelism at a
+	// time, result.BlockRef is appended to as blocks are read so it's in
+	// file order regardless of the order the writes finish in.
 	var wg sync.WaitGroup
-	errCh := make(chan error, 1)
+	var lock sync.Mutex
+	var putErr error
+	setErr := func(err error) {
+		lock.Lock()
+		defer lock.Unlock()
+		if putErr == nil {
+			putErr = err
+		}
+	}
+	failed := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return putErr != nil
+	}
+	sem := make(chan struct{}, s.putBlockParallelism)
 	decoder := json.NewDecoder(reader)
 	for {
 		blockRef, data, err := readBlock(putBlockRequest.Delimiter, reader, decoder)
 		if err != nil {
-			return err
+			setErr(err)
+			break
 		}
 		result.BlockRef = append(result.BlockRef, blockRef)
+		sem <- struct{}{}
+		// don't start any more writes once one has failed
+		if failed() {
+			<-sem
+			break
+		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			writer, err := s.objClient.Writer(s.localServer.blockPath(blockRef.Block))
-			if err != nil {
-				select {
-				case errCh <- err:
-				default:
-				}
-				return
-			}
-			defer func() {
-				if err := writer.Close(); err != nil {
-					select {
-					case errCh <- err:
-					default:
-					}
-					return
-				}
-			}()
-			if _, err := writer.Write(data); err != nil {
-				select {
-				case errCh <- err:
-				default:
-				}
+			defer func() { <-sem }()
+			if err := s.writeBlock(s.localServer.blockPath(blockRef.Block), data); err != nil {
+				setErr(err)
 			}
 		}()
 		if (blockRef.Range.Upper - blockRef.Range.Lower) < uint64(blockSize) {
@@ -136,14 +151,30 @@ func (s *objBlockAPIServer) PutBlock(putBlockServer pfsclient.BlockAPI_PutBlockS
 		}
 	}
 	wg.Wait()
-	select {
-	case err := <-errCh:
-		return err
-	default:
+	if putErr != nil {
+		// The blocks that were written are left where they are. Blocks are
+		// content addressed and object stores overwrite existing objects,
+		// so a block this request wrote may be one that committed files
+		// already refer to, deleting it would lose their data.
+		return putErr
 	}
 	return putBlockServer.SendAndClose(result)
 }
 
+func (s *objBlockAPIServer) writeBlock(path string, data []byte) (retErr error) {
+	writer, err := s.objClient.Writer(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := writer.Close(); err != nil && retErr == nil {
+			retErr = err
+		}
+	}()
+	_, err = writer.Write(data)
+	return err
+}
+
 func (s *objBlockAPIServer) GetBlock(request *pfsclient.GetBlockRequest, getBlockServer pfsclient.BlockAPI_GetBlockServer) (retErr error) {
 	defer func(start time.Time) { s.Log(request, nil, retErr, time.Since(start)) }(time.Now())
 	reader, err := s.objClient.Reader(s.localServer.blockPath(request.Block), request.OffsetBytes, request.SizeBytes)
@@ -28,11 +28,43 @@ type InternalAPIServer interface {
 }
 
 func NewAPIServer(hasher *pfsserver.Hasher, router shard.Router) APIServer {
-	return newAPIServer(hasher, router)
+	return newAPIServer(hasher, router, APIServerOptions{})
+}
+
+// NewAPIServerWithOptions is like NewAPIServer except it lets you configure
+// the server.
+func NewAPIServerWithOptions(hasher *pfsserver.Hasher, router shard.Router, options APIServerOptions) APIServer {
+	return newAPIServer(hasher, router, options)
+}
+
+// APIServerOptions are optional settings for an APIServer, the zero value
+// gives the default behavior.
+type APIServerOptions struct {
+	// ExportRoot is the directory that ExportToPath writes under, ExportToPath
+	// is disabled if it's empty.
+	ExportRoot string
 }
 
 func NewInternalAPIServer(hasher *pfsserver.Hasher, router shard.Router, driver drive.Driver) InternalAPIServer {
-	return newInternalAPIServer(hasher, router, driver)
+	return newInternalAPIServer(hasher, router, driver, InternalAPIServerOptions{})
+}
+
+// NewInternalAPIServerWithOptions is like NewInternalAPIServer except it lets
+// you configure the server.
+func NewInternalAPIServerWithOptions(hasher *pfsserver.Hasher, router shard.Router, driver drive.Driver,
+	options InternalAPIServerOptions) InternalAPIServer {
+	return newInternalAPIServer(hasher, router, driver, options)
+}
+
+// InternalAPIServerOptions are optional settings for an InternalAPIServer,
+// the zero value gives the default behavior.
+type InternalAPIServerOptions struct {
+	// AllowPrivateURLs lets PutFileURL fetch from loopback, private and link
+	// local addresses, by default it refuses to. With it anyone who can call
+	// PutFileURL can have the server fetch from services on its network
+	// that they can't reach themselves, and read the responses back out of
+	// PFS.
+	AllowPrivateURLs bool
 }
 
 func NewLocalBlockAPIServer(dir string) (pfsclient.BlockAPIServer, error) { // SJ: also bad naming
@@ -40,21 +72,42 @@ func NewLocalBlockAPIServer(dir string) (pfsclient.BlockAPIServer, error) { // S
 }
 
 func NewObjBlockAPIServer(dir string, objClient obj.Client) (pfsclient.BlockAPIServer, error) { // SJ: Also bad naming
-	return newObjBlockAPIServer(dir, objClient)
+	return newObjBlockAPIServer(dir, objClient, BlockAPIServerOptions{})
+}
+
+// NewObjBlockAPIServerWithOptions is like NewObjBlockAPIServer except it lets
+// you configure the server.
+func NewObjBlockAPIServerWithOptions(dir string, objClient obj.Client, options BlockAPIServerOptions) (pfsclient.BlockAPIServer, error) {
+	return newObjBlockAPIServer(dir, objClient, options)
+}
+
+// BlockAPIServerOptions are optional settings for a BlockAPIServer, the zero
+// value gives the default behavior.
+type BlockAPIServerOptions struct {
+	// PutBlockParallelism is the number of blocks from a single PutBlock
+	// that are written to object storage at once, 0 means 8.
+	// It doesn't apply to local storage.
+	PutBlockParallelism int
 }
 
 // NewBlockAPIServer creates a BlockAPIServer using the credentials it finds in
 // the environment
 func NewBlockAPIServer(dir string, backend string) (pfsclient.BlockAPIServer, error) {
+	return NewBlockAPIServerWithOptions(dir, backend, BlockAPIServerOptions{})
+}
+
+// NewBlockAPIServerWithOptions is like NewBlockAPIServer except it lets you
+// configure the server.
+func NewBlockAPIServerWithOptions(dir string, backend string, options BlockAPIServerOptions) (pfsclient.BlockAPIServer, error) {
 	switch backend {
 	case AmazonBackendEnvVar:
-		blockAPIServer, err := newAmazonBlockAPIServer(dir)
+		blockAPIServer, err := newAmazonBlockAPIServer(dir, options)
 		if err != nil {
 			return nil, err
 		}
 		return blockAPIServer, nil
 	case GoogleBackendEnvVar:
-		blockAPIServer, err := newGoogleBlockAPIServer(dir)
+		blockAPIServer, err := newGoogleBlockAPIServer(dir, options)
 		if err != nil {
 			return nil, err
 		}
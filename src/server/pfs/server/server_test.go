@@ -1,20 +1,38 @@
 package server
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unicode/utf16"
 
 	"golang.org/x/net/context"
 
+	"github.com/golang/protobuf/proto"
 	"go.pedge.io/proto/server"
+	"go.pedge.io/proto/stream"
+	"go.pedge.io/proto/time"
 	"google.golang.org/grpc"
 
 	pclient "github.com/pachyderm/pachyderm/src/client"
@@ -26,6 +44,7 @@ import (
 	"github.com/pachyderm/pachyderm/src/client/version"
 	pfsserver "github.com/pachyderm/pachyderm/src/server/pfs"
 	"github.com/pachyderm/pachyderm/src/server/pfs/drive"
+	"github.com/pachyderm/pachyderm/src/server/pkg/obj"
 )
 
 const (
@@ -182,6 +201,39 @@ func TestBranch(t *testing.T) {
 	require.Equal(t, "master", branches[0].Branch)
 }
 
+func TestStartBranchOnParent(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		_, err = client.PutFile(repo, commit1.ID, fmt.Sprintf("file%d", i), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// a new branch can start on an explicit parent, it has to work on every
+	// shard not just the first
+	commit2, err := client.StartCommit(repo, commit1.ID, "feature")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	commitInfo, err := client.InspectCommit(repo, commit2.ID)
+	require.NoError(t, err)
+	require.Equal(t, commit1.ID, commitInfo.ParentCommit.ID)
+	fileInfos, err := client.ListFile(repo, commit2.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 10, len(fileInfos))
+
+	// an existing branch can't be given a parent, even its own head
+	_, err = client.StartCommit(repo, commit1.ID, "feature")
+	require.YesError(t, err)
+	_, err = client.StartCommit(repo, commit2.ID, "feature")
+	require.YesError(t, err)
+}
+
 func TestDisallowReadsDuringCommit(t *testing.T) {
 	t.Parallel()
 	client, server := getClientAndServer(t)
@@ -352,6 +404,147 @@ func TestDeleteRepo(t *testing.T) {
 	require.Equal(t, len(repoInfos), numRepos-reposToRemove)
 }
 
+func TestSoftDeleteCommit(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServerWithOptions(t, drive.Options{DeletedCommitRetention: time.Second})
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	// open commits can't be deleted
+	require.YesError(t, client.SoftDeleteCommit(repo, commit2.ID))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	listCommit := func(includeDeleted bool) []*pfsclient.CommitInfo {
+		commitInfos, err := client.PfsAPIClient.ListCommit(
+			context.Background(),
+			&pfsclient.ListCommitRequest{
+				Repo:           []*pfsclient.Repo{pclient.NewRepo(repo)},
+				IncludeDeleted: includeDeleted,
+			},
+		)
+		require.NoError(t, err)
+		return commitInfos.CommitInfo
+	}
+
+	require.NoError(t, client.SoftDeleteCommit(repo, commit2.ID))
+	commitInfos := listCommit(false)
+	require.Equal(t, 1, len(commitInfos))
+	require.Equal(t, commit1.ID, commitInfos[0].Commit.ID)
+	require.Equal(t, 2, len(listCommit(true)))
+	commitInfo, err := client.InspectCommit(repo, commit2.ID)
+	require.NoError(t, err)
+	require.NotNil(t, commitInfo.Deleted)
+	// deleted commits can't be used as parents
+	_, err = client.StartCommit(repo, commit2.ID, "")
+	require.YesError(t, err)
+
+	// soft deletes are persisted with the diffs
+	restartServer(server, t)
+	require.Equal(t, 1, len(listCommit(false)))
+
+	require.NoError(t, client.RestoreCommit(repo, commit2.ID))
+	require.Equal(t, 2, len(listCommit(false)))
+	commitInfo, err = client.InspectCommit(repo, commit2.ID)
+	require.NoError(t, err)
+	require.Nil(t, commitInfo.Deleted)
+	require.YesError(t, client.RestoreCommit(repo, commit2.ID))
+
+	// once the retention period is up the commit is garbage collected
+	require.NoError(t, client.SoftDeleteCommit(repo, commit2.ID))
+	for i := 0; ; i++ {
+		if _, err := client.InspectCommit(repo, commit2.ID); err != nil {
+			break
+		}
+		require.True(t, i < 30, "commit %s/%s wasn't garbage collected", repo, commit2.ID)
+		time.Sleep(time.Second)
+	}
+	require.Equal(t, 1, len(listCommit(true)))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+	restartServer(server, t)
+	require.Equal(t, 1, len(listCommit(true)))
+}
+
+func TestStartCommitFromCommit(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "a", strings.NewReader("a\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/b", strings.NewReader("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "c", strings.NewReader("c\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "a", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	// commit3 is a child of commit2 but starts out with commit1's files
+	commit3, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo(repo),
+			ParentID:   commit2.ID,
+			FromCommit: commit1,
+		},
+	)
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "a", strings.NewReader("a2\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "dir/d", strings.NewReader("d\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	commitInfo, err := client.InspectCommit(repo, commit3.ID)
+	require.NoError(t, err)
+	require.Equal(t, commit2.ID, commitInfo.ParentCommit.ID)
+
+	check := func() {
+		fileInfos, err := client.ListFile(repo, commit3.ID, "", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(fileInfos))
+		fileInfos, err = client.ListFile(repo, commit3.ID, "dir", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(fileInfos))
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit3.ID, "a", 0, 0, "", nil, &buffer))
+		require.Equal(t, "a\na2\n", buffer.String())
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit3.ID, "dir/b", 0, 0, "", nil, &buffer))
+		require.Equal(t, "b\n", buffer.String())
+		_, err = client.InspectFile(repo, commit3.ID, "c", "", nil)
+		require.YesError(t, err)
+	}
+	check()
+	restartServer(server, t)
+	check()
+
+	// open commits can't be used as a base
+	commit4, err := client.StartCommit(repo, commit3.ID, "")
+	require.NoError(t, err)
+	_, err = client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo(repo),
+			FromCommit: commit4,
+		},
+	)
+	require.YesError(t, err)
+}
+
 func TestInspectCommit(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
@@ -491,882 +684,4348 @@ func TestPutFile(t *testing.T) {
 	require.YesError(t, client.GetFile(repo, commit4.ID, "dir2", 0, 0, "", nil, &buffer))
 }
 
-func TestListFileTwoCommits(t *testing.T) {
+func TestPutFileIdempotencyKey(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	numFiles := 5
-
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
 
-	for i := 0; i < numFiles; i++ {
-		_, err = client.PutFile(repo, commit1.ID, fmt.Sprintf("file%d", i), strings.NewReader("foo\n"))
+	putFile := func(commitID string, path string, key string, value string) {
+		putFileClient, err := client.PfsAPIClient.PutFile(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, putFileClient.Send(&pfsclient.PutFileRequest{
+			File:           pclient.NewFile(repo, commitID, path),
+			FileType:       pfsclient.FileType_FILE_TYPE_REGULAR,
+			Value:          []byte(value),
+			IdempotencyKey: key,
+		}))
+		_, err = putFileClient.CloseAndRecv()
 		require.NoError(t, err)
 	}
-
+	putFile(commit1.ID, "foo", "key1", "foo\n")
+	// replaying the put is a noop
+	putFile(commit1.ID, "foo", "key1", "foo\n")
+	// but a different key, or the same key on a different path, isn't
+	putFile(commit1.ID, "foo", "key2", "bar\n")
+	putFile(commit1.ID, "bar", "key1", "bar\n")
 	require.NoError(t, client.FinishCommit(repo, commit1.ID))
 
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nbar\n", buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit1.ID, "bar", 0, 0, "", nil, &buffer))
+	require.Equal(t, "bar\n", buffer.String())
+
+	// keys are scoped to a commit, so they can be reused in the next one
 	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-
-	for i := 0; i < numFiles; i++ {
-		_, err = client.PutFile(repo, commit2.ID, fmt.Sprintf("file2-%d", i), strings.NewReader("foo\n"))
-		require.NoError(t, err)
-	}
-
+	putFile(commit2.ID, "foo", "key1", "foo\n")
 	require.NoError(t, client.FinishCommit(repo, commit2.ID))
-
-	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
-	require.NoError(t, err)
-	require.Equal(t, numFiles, len(fileInfos))
-
-	fileInfos, err = client.ListFile(repo, commit2.ID, "", "", nil, false)
-	require.NoError(t, err)
-	require.Equal(t, 2*numFiles, len(fileInfos))
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nbar\nfoo\n", buffer.String())
 }
 
-func TestPutSameFileInParallel(t *testing.T) {
+func TestPutFileExpectedHash(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
 	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	var wg sync.WaitGroup
-	for i := 0; i < 3; i++ {
-		wg.Add(1)
-		go func() {
-			_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
-			require.NoError(t, err)
-			wg.Done()
-		}()
+
+	putFile := func(path string, value string, expectedHash string) error {
+		putFileClient, err := client.PfsAPIClient.PutFile(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, putFileClient.Send(&pfsclient.PutFileRequest{
+			File:         pclient.NewFile(repo, commit.ID, path),
+			FileType:     pfsclient.FileType_FILE_TYPE_REGULAR,
+			Value:        []byte(value),
+			ExpectedHash: expectedHash,
+		}))
+		_, err = putFileClient.CloseAndRecv()
+		return err
 	}
-	wg.Wait()
+	hash := func(value string) string {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	require.NoError(t, putFile("foo", "foo\n", hash("foo\n")))
+	err = putFile("foo", "bar\n", hash("buzz\n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "hash mismatch"))
+	// a mismatched put into a new file doesn't create it
+	require.YesError(t, putFile("bar", "bar\n", hash("buzz\n")))
 	require.NoError(t, client.FinishCommit(repo, commit.ID))
 
 	var buffer bytes.Buffer
 	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
-	require.Equal(t, "foo\nfoo\nfoo\n", buffer.String())
+	require.Equal(t, "foo\n", buffer.String())
+	_, err = client.InspectFile(repo, commit.ID, "bar", "", nil)
+	require.YesError(t, err)
+	commitInfo, err := client.InspectCommit(repo, commit.ID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len("foo\n")), commitInfo.SizeBytes)
 }
 
-func TestInspectFile(t *testing.T) {
+func TestPutFileContentType(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	fileContent1 := "foo\n"
-	commit1, err := client.StartCommit(repo, "", "")
-	require.NoError(t, err)
-	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(fileContent1))
-	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
-
-	fileInfo, err := client.InspectFile(repo, commit1.ID, "foo", "", nil)
-	require.NoError(t, err)
-	require.Equal(t, commit1, fileInfo.CommitModified)
-	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
-	require.Equal(t, len(fileContent1), int(fileInfo.SizeBytes))
-
-	fileContent2 := "barbar\n"
-	commit2, err := client.StartCommit(repo, commit1.ID, "")
-	require.NoError(t, err)
-	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader(fileContent2))
+	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	fileInfo, err = client.InspectFile(repo, commit2.ID, "foo", commit1.ID, nil)
+	valid := "{\"foo\": 1}\n\n[1, 2]\n\"bar\"\n"
+	_, err = client.PutFileWithContentType(repo, commit.ID, "valid", drive.JSONLinesContentType, strings.NewReader(valid))
 	require.NoError(t, err)
-	require.Equal(t, commit2, fileInfo.CommitModified)
-	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
-	require.Equal(t, len(fileContent2), int(fileInfo.SizeBytes))
+	_, err = client.PutFileWithContentType(repo, commit.ID, "invalid", drive.JSONLinesContentType,
+		strings.NewReader("{\"foo\": 1}\n{\"foo\": \n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "validation failed"))
+	// an invalid put to an existing file leaves it as it was
+	_, err = client.PutFileWithContentType(repo, commit.ID, "valid", drive.JSONLinesContentType, strings.NewReader("foo\n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "validation failed"))
+	// content types without a validator are rejected
+	_, err = client.PutFileWithContentType(repo, commit.ID, "csv", "text/csv", strings.NewReader("a,b\n"))
+	require.YesError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
 
-	fileInfo, err = client.InspectFile(repo, commit2.ID, "foo", "", nil)
-	require.NoError(t, err)
-	require.Equal(t, commit2, fileInfo.CommitModified)
-	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
-	require.Equal(t, len(fileContent1)+len(fileContent2), int(fileInfo.SizeBytes))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "valid", 0, 0, "", nil, &buffer))
+	require.Equal(t, valid, buffer.String())
+	_, err = client.InspectFile(repo, commit.ID, "invalid", "", nil)
+	require.YesError(t, err)
+	_, err = client.InspectFile(repo, commit.ID, "csv", "", nil)
+	require.YesError(t, err)
+}
 
-	fileContent3 := "bar\n"
-	commit3, err := client.StartCommit(repo, commit2.ID, "")
-	require.NoError(t, err)
-	_, err = client.PutFile(repo, commit3.ID, "bar", strings.NewReader(fileContent3))
-	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+// upperCaseValidator only accepts content without lower case letters
+type upperCaseValidator struct{}
 
-	fileInfos, err := client.ListFile(repo, commit3.ID, "", "", nil, false)
-	require.NoError(t, err)
-	require.Equal(t, len(fileInfos), 2)
+func (upperCaseValidator) Validate(reader io.Reader) error {
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	if strings.ToUpper(string(data)) != string(data) {
+		return fmt.Errorf("found lower case letters")
+	}
+	return nil
 }
 
-func TestListFile(t *testing.T) {
+func TestPutFileRegisteredContentValidator(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
+	drive.RegisterContentValidator("text/x-upper-case", upperCaseValidator{})
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
 	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-
-	fileContent1 := "foo\n"
-	_, err = client.PutFile(repo, commit.ID, "dir/foo", strings.NewReader(fileContent1))
-	require.NoError(t, err)
-
-	fileContent2 := "bar\n"
-	_, err = client.PutFile(repo, commit.ID, "dir/bar", strings.NewReader(fileContent2))
+	_, err = client.PutFileWithContentType(repo, commit.ID, "foo", "text/x-upper-case", strings.NewReader("FOO\n"))
 	require.NoError(t, err)
-
+	_, err = client.PutFileWithContentType(repo, commit.ID, "bar", "text/x-upper-case", strings.NewReader("bar\n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "found lower case letters"))
 	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	_, err = client.InspectFile(repo, commit.ID, "bar", "", nil)
+	require.YesError(t, err)
+}
 
-	fileInfos, err := client.ListFile(repo, commit.ID, "dir", "", nil, true)
+func putFileWithTTL(t *testing.T, client pclient.APIClient, repo string, commitID string, path string, value string, ttlSeconds uint64) {
+	putFileClient, err := client.PfsAPIClient.PutFile(context.Background())
 	require.NoError(t, err)
-	require.Equal(t, 2, len(fileInfos))
-	require.True(t, fileInfos[0].File.Path == "dir/foo" && fileInfos[1].File.Path == "dir/bar" || fileInfos[0].File.Path == "dir/bar" && fileInfos[1].File.Path == "dir/foo")
-	require.True(t, fileInfos[0].SizeBytes == fileInfos[1].SizeBytes && fileInfos[0].SizeBytes == uint64(len(fileContent1)))
-
-	fileInfos, err = client.ListFile(repo, commit.ID, "dir/foo", "", nil, false)
+	require.NoError(t, putFileClient.Send(&pfsclient.PutFileRequest{
+		File:       pclient.NewFile(repo, commitID, path),
+		FileType:   pfsclient.FileType_FILE_TYPE_REGULAR,
+		Value:      []byte(value),
+		TtlSeconds: ttlSeconds,
+	}))
+	_, err = putFileClient.CloseAndRecv()
 	require.NoError(t, err)
-	require.Equal(t, 1, len(fileInfos))
-	require.True(t, fileInfos[0].File.Path == "dir/foo")
 }
 
-func TestDeleteFile(t *testing.T) {
+func TestPutFileTTL(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	// Commit 1: Add two files; delete one file within the commit
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-
-	fileContent1 := "foo\n"
-	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(fileContent1))
-	require.NoError(t, err)
-
-	fileContent2 := "bar\n"
-	_, err = client.PutFile(repo, commit1.ID, "bar", strings.NewReader(fileContent2))
-	require.NoError(t, err)
-
-	// The deletion should fail because the file did not exist before this commit,
-	// and files written in the current commit should not be visible yet.
-	require.YesError(t, client.DeleteFile(repo, commit1.ID, "foo", false, ""))
-
+	putFileWithTTL(t, client, repo, commit1.ID, "dir/foo", "foo\n", 1)
+	putFileWithTTL(t, client, repo, commit1.ID, "dir/bar", "bar\n", 0)
 	require.NoError(t, client.FinishCommit(repo, commit1.ID))
-
-	// foo should still be here because we can't remove a file that we are adding
-	// in the same commit
-	_, err = client.InspectFile(repo, commit1.ID, "foo", "", nil)
+	_, err = client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
 	require.NoError(t, err)
 
-	// Should see two files
-	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
+	time.Sleep(time.Second)
+	// expired files are hidden even though nothing has swept them
+	_, err = client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
+	require.YesError(t, err)
+	fileInfos, err := client.ListFile(repo, commit1.ID, "dir", "", nil, false)
 	require.NoError(t, err)
-	require.Equal(t, 2, len(fileInfos))
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, "dir/bar", fileInfos[0].File.Path)
 
-	// Empty commit
+	// putting to an expired file starts it over
 	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
+	putFileWithTTL(t, client, repo, commit2.ID, "dir/foo", "foo2\n", 0)
 	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo2\n", buffer.String())
+}
 
-	// Should still see two files
-	fileInfos, err = client.ListFile(repo, commit2.ID, "", "", nil, false)
-	require.NoError(t, err)
-	require.Equal(t, 2, len(fileInfos))
+func TestPutFileTTLSweep(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServerWithOptions(t, drive.Options{ExpiredFileSweepInterval: 100 * time.Millisecond})
 
-	// Delete foo
-	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	require.NoError(t, client.DeleteFile(repo, commit3.ID, "foo", false, ""))
-	require.NoError(t, client.FinishCommit(repo, commit3.ID))
-
-	// Should see one file
-	fileInfos, err = client.ListFile(repo, commit3.ID, "", "", nil, false)
+	putFileWithTTL(t, client, repo, commit.ID, "foo", "foo\n", 1)
+	putFileWithTTL(t, client, repo, commit.ID, "bar", "bar\n", 0)
+	// making foo immutable doesn't stop it from expiring
+	require.NoError(t, client.SetImmutable(repo, commit.ID, "foo"))
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	commitInfo, err := client.InspectCommit(repo, commit.ID)
 	require.NoError(t, err)
-	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, uint64(8), commitInfo.SizeBytes)
 
-	// The removed file should not exist
-	_, err = client.InspectFile(repo, commit3.ID, "foo", "", nil)
+	// once foo is swept its blocks no longer count toward the commit
+	for i := 0; ; i++ {
+		commitInfo, err = client.InspectCommit(repo, commit.ID)
+		require.NoError(t, err)
+		if commitInfo.SizeBytes == 4 {
+			break
+		}
+		require.True(t, i < 50, "foo wasn't swept")
+		time.Sleep(100 * time.Millisecond)
+	}
+	// and the sweep is persisted, sweeps are persisted after they're visible
+	// so we give them a moment to land
+	time.Sleep(time.Second)
+	restartServer(server, t)
+	commitInfo, err = client.InspectCommit(repo, commit.ID)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), commitInfo.SizeBytes)
+	_, err = client.InspectFile(repo, commit.ID, "foo", "", nil)
 	require.YesError(t, err)
+	fileInfos, err := client.ListFile(repo, commit.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, "bar", fileInfos[0].File.Path)
 }
 
-func TestInspectDir(t *testing.T) {
+func TestPutFileTTLLastPut(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	client, _ := getClientAndServerWithOptions(t, drive.Options{ExpiredFileSweepInterval: 100 * time.Millisecond})
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-
-	fileContent1 := "foo\n"
-	_, err = client.PutFile(repo, commit1.ID, "dir/foo", strings.NewReader(fileContent1))
+	putFileWithTTL(t, client, repo, commit1.ID, "foo", "foo\n", 1)
+	putFileWithTTL(t, client, repo, commit1.ID, "bar", "bar\n", 0)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	// the last put to a file decides when it expires, foo no longer does and
+	// bar now does
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
+	putFileWithTTL(t, client, repo, commit2.ID, "foo", "foo2\n", 0)
+	putFileWithTTL(t, client, repo, commit2.ID, "bar", "bar2\n", 1)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	// once commit1's foo and commit2's bar have been swept
+	for i := 0; ; i++ {
+		commitInfo1, err := client.InspectCommit(repo, commit1.ID)
+		require.NoError(t, err)
+		commitInfo2, err := client.InspectCommit(repo, commit2.ID)
+		require.NoError(t, err)
+		if commitInfo1.SizeBytes == 4 && commitInfo2.SizeBytes == 5 {
+			break
+		}
+		require.True(t, i < 50, "foo and bar weren't swept")
+		time.Sleep(100 * time.Millisecond)
+	}
+	_, err = client.InspectFile(repo, commit1.ID, "foo", "", nil)
+	require.YesError(t, err)
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "bar", 0, 0, "", nil, &buffer))
+	require.Equal(t, "bar\n", buffer.String())
+	// commit2 still has all of foo and none of bar
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nfoo2\n", buffer.String())
+	_, err = client.InspectFile(repo, commit2.ID, "bar", "", nil)
+	require.YesError(t, err)
+}
 
-	_, err = client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
+func TestInspectRepoDAG(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	commit3, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	commit4, err := client.StartCommit(repo, "", "master")
 	require.NoError(t, err)
 
-	_, err = client.InspectFile(repo, commit1.ID, "dir", "", nil)
+	// the DAG is only returned when asked for
+	repoInfo, err := client.InspectRepo(repo)
 	require.NoError(t, err)
+	require.Equal(t, 0, len(repoInfo.Dag))
 
-	// This is a limitation in our system: we cannot inspect .
-	// . is assumed to be a directory
-	// In order to be able to inspect the root directory, we have to have each
-	// PutFile send a concurrent request to create an entry for ".", which is
-	// a price we are not willing to pay.
-	_, err = client.InspectFile(repo, commit1.ID, "", "", nil)
-	require.YesError(t, err)
+	repoInfo, err = client.InspectRepoDAG(repo, 0, 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(repoInfo.Dag))
+	require.Equal(t, uint64(0), repoInfo.DagNextOffset)
+	parents := make(map[string]string)
+	branches := make(map[string]string)
+	position := make(map[string]int)
+	for i, commitNode := range repoInfo.Dag {
+		if commitNode.ParentCommit != nil {
+			parents[commitNode.Commit.ID] = commitNode.ParentCommit.ID
+			_, ok := position[commitNode.ParentCommit.ID]
+			require.True(t, ok, "child %s came before its parent", commitNode.Commit.ID)
+		}
+		branches[commitNode.Commit.ID] = commitNode.Branch
+		position[commitNode.Commit.ID] = i
+	}
+	require.Equal(t, map[string]string{
+		commit2.ID: commit1.ID,
+		commit3.ID: commit1.ID,
+		commit4.ID: commit2.ID,
+	}, parents)
+	require.Equal(t, map[string]string{
+		commit1.ID: "master",
+		commit2.ID: "master",
+		commit3.ID: "",
+		commit4.ID: "master",
+	}, branches)
+
+	// pages put back together give the whole DAG
+	var paged []*pfsclient.CommitNode
+	var offset uint64
+	for pages := 0; ; pages++ {
+		require.True(t, pages < 4)
+		repoInfo, err := client.InspectRepoDAG(repo, offset, 3)
+		require.NoError(t, err)
+		paged = append(paged, repoInfo.Dag...)
+		if repoInfo.DagNextOffset == 0 {
+			break
+		}
+		offset = repoInfo.DagNextOffset
+	}
+	require.Equal(t, len(repoInfo.Dag), len(paged))
+	for i := range paged {
+		require.Equal(t, repoInfo.Dag[i].Commit.ID, paged[i].Commit.ID)
+	}
+	repoInfo, err = client.InspectRepoDAG(repo, 10, 3)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(repoInfo.Dag))
 }
 
-func TestDeleteDir(t *testing.T) {
+func TestInspectRepoBranchHeads(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	// Commit 1: Add two files into the same directory; delete the directory
-	commit1, err := client.StartCommit(repo, "", "")
+	// a repo with no commits has no heads
+	repoInfo, err := client.InspectRepoBranchHeads(repo)
 	require.NoError(t, err)
+	require.Equal(t, 0, len(repoInfo.BranchHeads))
 
-	_, err = client.PutFile(repo, commit1.ID, "dir/foo", strings.NewReader("foo1"))
+	heads := make(map[string]*pfsclient.Commit)
+	for i := 0; i < 3; i++ {
+		for _, branch := range []string{"master", "foo", "bar"} {
+			commit, err := client.StartCommit(repo, "", branch)
+			require.NoError(t, err)
+			_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("file%d", i), strings.NewReader(branch))
+			require.NoError(t, err)
+			require.NoError(t, client.FinishCommit(repo, commit.ID))
+			heads[branch] = commit
+		}
+	}
+	// the heads are only returned when asked for
+	repoInfo, err = client.InspectRepo(repo)
 	require.NoError(t, err)
+	require.Equal(t, 0, len(repoInfo.BranchHeads))
 
-	_, err = client.PutFile(repo, commit1.ID, "dir/bar", strings.NewReader("bar1"))
+	repoInfo, err = client.InspectRepoBranchHeads(repo)
 	require.NoError(t, err)
+	require.Equal(t, len(heads), len(repoInfo.BranchHeads))
+	for branch, commit := range heads {
+		commitInfo, ok := repoInfo.BranchHeads[branch]
+		require.True(t, ok, "missing head for %s", branch)
+		require.Equal(t, commit, commitInfo.Commit)
+		require.Equal(t, branch, commitInfo.Branch)
+		// the heads should be merged across shards
+		inspected, err := client.InspectCommit(repo, commit.ID)
+		require.NoError(t, err)
+		require.Equal(t, inspected.SizeBytes, commitInfo.SizeBytes)
+	}
+}
 
-	// Since the directory did not exist before this commit, this should error
-	require.YesError(t, client.DeleteFile(repo, commit1.ID, "dir", false, ""))
+func TestFlushOpenCommits(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServerWithOptions(t, drive.Options{FlushInterval: 100 * time.Millisecond})
 
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.MakeDirectory(repo, commit.ID, "dir"))
+	putKeyed := func() {
+		putFileClient, err := client.PfsAPIClient.PutFile(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, putFileClient.Send(&pfsclient.PutFileRequest{
+			File:           pclient.NewFile(repo, commit.ID, "keyed"),
+			FileType:       pfsclient.FileType_FILE_TYPE_REGULAR,
+			Value:          []byte("keyed\n"),
+			IdempotencyKey: "key",
+		}))
+		_, err = putFileClient.CloseAndRecv()
+		require.NoError(t, err)
+	}
+	putKeyed()
 
-	// Should see one directory
-	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
+	// once the interval has elapsed the open commit survives a restart
+	time.Sleep(500 * time.Millisecond)
+	restartServer(server, t)
+	commitInfo, err := client.InspectCommit(repo, commit.ID)
 	require.NoError(t, err)
-	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_WRITE, commitInfo.CommitType)
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFileUnsafe(repo, commit.ID, "foo", 0, 0, "", nil, "", &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+	fileInfo, err := client.InspectFileUnsafe(repo, commit.ID, "dir", "", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_DIR, fileInfo.FileType)
 
-	// dir should not exist
-	_, err = client.InspectFile(repo, commit1.ID, "dir", "", nil)
+	// and can be written to and finished as usual, its idempotency keys
+	// survive too so a replay is still ignored
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("bar\n"))
 	require.NoError(t, err)
+	putKeyed()
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nbar\n", buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "keyed", 0, 0, "", nil, &buffer))
+	require.Equal(t, "keyed\n", buffer.String())
 
-	// Commit 2: Delete the directory and add the same two files
-	// The two files should reflect the new content
-	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	// a stale flush mustn't undo the finish
+	time.Sleep(500 * time.Millisecond)
+	restartServer(server, t)
+	commitInfo, err = client.InspectCommit(repo, commit.ID)
 	require.NoError(t, err)
+	require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_READ, commitInfo.CommitType)
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nbar\n", buffer.String())
+}
 
-	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir", false, ""))
+func TestPutFileAt(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
 
-	_, err = client.PutFile(repo, commit2.ID, "dir/foo", strings.NewReader("foo2"))
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
+	// expected is what the file should contain, we apply the same writes to it
+	var expected []byte
+	commitID := commit1.ID
+	writeAt := func(offset int, data string) {
+		for len(expected) < offset+len(data) {
+			expected = append(expected, 0)
+		}
+		copy(expected[offset:], data)
+		_, err := client.PutFileAt(repo, commitID, "file", uint64(offset), strings.NewReader(data))
+		require.NoError(t, err)
+	}
+	checkFile := func(commitID string, unsafe bool) {
+		var buffer bytes.Buffer
+		if unsafe {
+			require.NoError(t, client.GetFileUnsafe(repo, commitID, "file", 0, 0, "", nil, "", &buffer))
+		} else {
+			require.NoError(t, client.GetFile(repo, commitID, "file", 0, 0, "", nil, &buffer))
+		}
+		require.Equal(t, expected, buffer.Bytes())
+	}
 
-	_, err = client.PutFile(repo, commit2.ID, "dir/bar", strings.NewReader("bar2"))
+	// writing past the end of an empty file zero fills it
+	writeAt(1000, "foo")
+	checkFile(commit1.ID, true)
+	require.Equal(t, 1003, len(expected))
+	writeAt(10, "bar")
+	writeAt(1001, "buzz")
+	writeAt(0, "fizz")
+	writeAt(2000, "end")
+	writeAt(1998, "overlap")
+	checkFile(commit1.ID, true)
+	// a regular put still appends
+	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("tail"))
+	require.NoError(t, err)
+	expected = append(expected, "tail"...)
+	checkFile(commit1.ID, true)
+	writeAt(len(expected)-2, "xx")
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	checkFile(commit1.ID, false)
+	fileInfo, err := client.InspectFile(repo, commit1.ID, "file", "", nil)
 	require.NoError(t, err)
+	require.Equal(t, uint64(len(expected)), fileInfo.SizeBytes)
 
+	// offset writes in a child commit leave the parent alone
+	parentExpected := append([]byte{}, expected...)
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	commitID = commit2.ID
+	writeAt(5, "child")
 	require.NoError(t, client.FinishCommit(repo, commit2.ID))
-
-	// Should see two files
-	fileInfos, err = client.ListFile(repo, commit2.ID, "dir", "", nil, false)
+	checkFile(commit2.ID, false)
+	commitInfo, err := client.InspectCommit(repo, commit2.ID)
 	require.NoError(t, err)
-	require.Equal(t, 2, len(fileInfos))
-
+	require.Equal(t, uint64(len("child")), commitInfo.SizeBytes)
 	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/foo", 0, 0, "", nil, &buffer))
-	require.Equal(t, "foo2", buffer.String())
-
-	var buffer2 bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/bar", 0, 0, "", nil, &buffer2))
-	require.Equal(t, "bar2", buffer2.String())
+	require.NoError(t, client.GetFile(repo, commit1.ID, "file", 0, 0, "", nil, &buffer))
+	require.Equal(t, parentExpected, buffer.Bytes())
 
-	// Commit 3: delete the directory
+	// directories can't be written at an offset
 	commit3, err := client.StartCommit(repo, commit2.ID, "")
 	require.NoError(t, err)
+	require.NoError(t, client.MakeDirectory(repo, commit3.ID, "dir"))
+	_, err = client.PutFileAt(repo, commit3.ID, "dir", 0, strings.NewReader("foo"))
+	require.YesError(t, err)
 
-	require.NoError(t, client.DeleteFile(repo, commit3.ID, "dir", false, ""))
-
+	// the zeros that fill a gap don't count towards the commit's size
+	_, err = client.PutFileAt(repo, commit3.ID, "hole", 1000, strings.NewReader("foo"))
+	require.NoError(t, err)
+	_, err = client.PutFileAt(repo, commit3.ID, "hole", 500, strings.NewReader("bar"))
+	require.NoError(t, err)
 	require.NoError(t, client.FinishCommit(repo, commit3.ID))
-
-	// Should see zero files
-	fileInfos, err = client.ListFile(repo, commit3.ID, "", "", nil, false)
+	commitInfo, err = client.InspectCommit(repo, commit3.ID)
 	require.NoError(t, err)
-	require.Equal(t, 0, len(fileInfos))
-
-	// TODO: test deleting "."
+	require.Equal(t, uint64(len("foobar")), commitInfo.SizeBytes)
 }
 
-func TestListCommit(t *testing.T) {
+func TestXattrs(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	client, server := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
 
-	commit, err := client.StartCommit(repo, "", "")
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-
-	fileContent1 := "foo\n"
-	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader(fileContent1))
+	_, err = client.PutFileWithXattrs(repo, commit1.ID, "dir/foo",
+		map[string]string{"user.a": "1", "user.b": "2"}, strings.NewReader("foo\n"))
 	require.NoError(t, err)
+	require.NoError(t, client.SetXattr(repo, commit1.ID, "dir/foo", "user.c", "3"))
+	require.YesError(t, client.SetXattr(repo, commit1.ID, "dir", "user.a", "1"))
+	require.YesError(t, client.SetXattr(repo, commit1.ID, "bar", "user.a", "1"))
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
 
-	require.NoError(t, client.FinishCommit(repo, commit.ID))
-
-	commitInfos, err := client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+	fileInfo, err := client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
 	require.NoError(t, err)
-	require.Equal(t, 1, len(commitInfos))
-
-	// test the block behaviour
-	ch := make(chan bool)
-	go func() {
-		_, err = client.ListCommit([]string{repo}, []string{commit.ID}, pclient.CommitTypeNone, true, false, nil)
-		close(ch)
-	}()
-
-	time.Sleep(time.Second)
-	select {
-	case <-ch:
-		t.Fatal("ListCommit should not have returned")
-	default:
-	}
-
-	commit2, err := client.StartCommit(repo, commit.ID, "")
+	require.Equal(t, map[string]string{"user.a": "1", "user.b": "2", "user.c": "3"}, fileInfo.Xattrs)
+	value, err := client.GetXattr(repo, commit1.ID, "dir/foo", "user.a")
 	require.NoError(t, err)
+	require.Equal(t, "1", value)
+	_, err = client.GetXattr(repo, commit1.ID, "dir/foo", "user.d")
+	require.YesError(t, err)
 
+	// setting xattrs doesn't touch the content, and appending content doesn't
+	// touch the xattrs
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.SetXattr(repo, commit2.ID, "dir/foo", "user.a", "10"))
+	require.NoError(t, client.SetXattr(repo, commit2.ID, "dir/foo", "user.b", ""))
 	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "dir/foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
 
-	time.Sleep(time.Second)
-	select {
-	case <-ch:
-	default:
-		t.Fatal("ListCommit should have returned")
+	checkXattrs := func() {
+		fileInfo, err := client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"user.a": "1", "user.b": "2", "user.c": "3"}, fileInfo.Xattrs)
+		fileInfo, err = client.InspectFile(repo, commit2.ID, "dir/foo", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, map[string]string{"user.a": "10", "user.c": "3"}, fileInfo.Xattrs)
+		require.Equal(t, uint64(4), fileInfo.SizeBytes)
+		fileInfos, err := client.ListFile(repo, commit3.ID, "dir", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(fileInfos))
+		require.Equal(t, map[string]string{"user.a": "10", "user.c": "3"}, fileInfos[0].Xattrs)
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit3.ID, "dir/foo", 0, 0, "", nil, &buffer))
+		require.Equal(t, "foo\nfoo\n", buffer.String())
 	}
+	checkXattrs()
 
-	// test that cancelled commits are not listed
-	commit3, err := client.StartCommit(repo, commit2.ID, "")
-	require.NoError(t, err)
+	// xattrs are stored with the diffs so they survive a restart
+	restartServer(server, t)
+	checkXattrs()
 
-	require.NoError(t, client.CancelCommit(repo, commit3.ID))
-	commitInfos, err = client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+	// a deleted file loses its xattrs
+	commit4, err := client.StartCommit(repo, commit3.ID, "")
 	require.NoError(t, err)
-	require.Equal(t, 2, len(commitInfos))
-	commitInfos, err = client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, true, nil)
+	require.NoError(t, client.DeleteFile(repo, commit4.ID, "dir/foo", false, ""))
+	_, err = client.PutFile(repo, commit4.ID, "dir/foo", strings.NewReader("foo\n"))
 	require.NoError(t, err)
-	require.Equal(t, 3, len(commitInfos))
-	require.Equal(t, commit3, commitInfos[0].Commit)
+	require.NoError(t, client.FinishCommit(repo, commit4.ID))
+	fileInfo, err = client.InspectFile(repo, commit4.ID, "dir/foo", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fileInfo.Xattrs))
 }
 
-func TestOffsetRead(t *testing.T) {
+func TestExportCommit(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
-	repo := "TestOffsetRead"
+
+	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-	_, err := client.StartCommit(repo, "", "master")
+
+	expected := make(map[string]string)
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	fileData := "foo\n"
-	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	for i := 0; i < 10; i++ {
+		filePath := fmt.Sprintf("dir%d/sub/file%d", i%3, i)
+		content := fmt.Sprintf("content %d\n", i)
+		_, err = client.PutFile(repo, commit1.ID, filePath, strings.NewReader(content))
+		require.NoError(t, err)
+		expected[filePath] = content
+	}
+	require.NoError(t, client.MakeDirectory(repo, commit1.ID, "empty"))
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	// big enough to be sent in several records
+	big := strings.Repeat("big\n", 512*1024)
+	_, err = client.PutFileWithXattrs(repo, commit2.ID, "big", map[string]string{"user.a": "1"}, strings.NewReader(big))
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, "master"))
-	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, "master", "foo", int64(len(fileData)*2)+1, 0, "", nil, &buffer))
-	require.Equal(t, "", buffer.String())
+	expected["big"] = big
+	_, err = client.PutFile(repo, commit2.ID, "dir0/sub/file0", strings.NewReader("more\n"))
+	require.NoError(t, err)
+	expected["dir0/sub/file0"] += "more\n"
+	require.YesError(t, client.ExportCommit(repo, commit2.ID, func(*pfsclient.ExportRecord) error { return nil }))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	files := make(map[string]*bytes.Buffer)
+	fileInfos := make(map[string]*pfsclient.FileInfo)
+	var current *bytes.Buffer
+	require.NoError(t, client.ExportCommit(repo, commit2.ID, func(record *pfsclient.ExportRecord) error {
+		if record.FileInfo != nil {
+			filePath := record.FileInfo.File.Path
+			require.Equal(t, commit2.ID, record.FileInfo.File.Commit.ID)
+			_, ok := fileInfos[filePath]
+			require.False(t, ok)
+			fileInfos[filePath] = record.FileInfo
+			current = nil
+			if record.FileInfo.FileType == pfsclient.FileType_FILE_TYPE_REGULAR {
+				current = &bytes.Buffer{}
+				files[filePath] = current
+			}
+		}
+		if len(record.Value) > 0 {
+			require.True(t, current != nil)
+			current.Write(record.Value)
+		}
+		return nil
+	}))
+	require.Equal(t, len(expected), len(files))
+	for filePath, content := range expected {
+		require.Equal(t, content, files[filePath].String())
+	}
+	for _, dir := range []string{"dir0", "dir1", "dir2", "dir0/sub", "dir1/sub", "dir2/sub", "empty"} {
+		fileInfo, ok := fileInfos[dir]
+		require.True(t, ok)
+		require.Equal(t, pfsclient.FileType_FILE_TYPE_DIR, fileInfo.FileType)
+	}
+	require.Equal(t, len(expected)+7, len(fileInfos))
+	require.Equal(t, map[string]string{"user.a": "1"}, fileInfos["big"].Xattrs)
 }
 
-func TestUnsafeOperations(t *testing.T) {
+func TestFileCompareAndSwap(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
-	repo := "TestUnsafeOperations"
-	require.NoError(t, client.CreateRepo(repo))
-
-	_, err := client.StartCommit(repo, "", "master")
-	require.NoError(t, err)
 
-	fileData := "foo"
-	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
+	hashOf := func(value string) string {
+		hash := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(hash[:])
+	}
+	getFile := func() string {
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFileUnsafe(repo, commit.ID, "lock", 0, 0, "", nil, "", &buffer))
+		return buffer.String()
+	}
 
-	// A safe read should not be able to see the file
-	var buffer bytes.Buffer
-	require.YesError(t, client.GetFile(repo, "master", "foo", 0, 0, "", nil, &buffer))
-
-	// An unsafe read should
-	var buffer2 bytes.Buffer
-	require.NoError(t, client.GetFileUnsafe(repo, "master", "foo", 0, 0, "", nil, "", &buffer2))
-	require.Equal(t, "foo", buffer2.String())
-
-	fileInfo, err := client.InspectFile(repo, "master", "foo", "", nil)
-	require.YesError(t, err)
-
-	fileInfo, err = client.InspectFileUnsafe(repo, "master", "foo", "", nil, "")
+	// an empty hash only matches a file that doesn't exist
+	swapped, hash, err := client.FileCompareAndSwap(repo, commit.ID, "lock", "", []byte("a"))
 	require.NoError(t, err)
-	require.Equal(t, 3, int(fileInfo.SizeBytes))
-
-	fileInfos, err := client.ListFile(repo, "master", "", "", nil, true)
+	require.True(t, swapped)
+	require.Equal(t, hashOf("a"), hash)
+	swapped, hash, err = client.FileCompareAndSwap(repo, commit.ID, "lock", "", []byte("b"))
 	require.NoError(t, err)
-	require.Equal(t, 0, len(fileInfos))
+	require.False(t, swapped)
+	require.Equal(t, hashOf("a"), hash)
+	require.Equal(t, "a", getFile())
 
-	fileInfos, err = client.ListFileUnsafe(repo, "master", "", "", nil, true, "")
+	// the content is replaced, not appended to
+	swapped, hash, err = client.FileCompareAndSwap(repo, commit.ID, "lock", hashOf("a"), []byte("bb"))
 	require.NoError(t, err)
-	require.Equal(t, 1, len(fileInfos))
+	require.True(t, swapped)
+	require.Equal(t, hashOf("bb"), hash)
+	require.Equal(t, "bb", getFile())
 
-	require.NoError(t, client.FinishCommit(repo, "master"))
+	// only one of many racing swaps from the same content succeeds
+	var wg sync.WaitGroup
+	var successes int32
+	winner := make(chan string, 1)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			value := fmt.Sprintf("owner %d", i)
+			swapped, _, err := client.FileCompareAndSwap(repo, commit.ID, "lock", hashOf("bb"), []byte(value))
+			require.NoError(t, err)
+			if swapped {
+				atomic.AddInt32(&successes, 1)
+				winner <- value
+			}
+		}(i)
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), successes)
+	require.Equal(t, <-winner, getFile())
+
+	// directories and finished commits can't be swapped
+	_, err = client.PutFile(repo, commit.ID, "dir/file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, _, err = client.FileCompareAndSwap(repo, commit.ID, "dir", "", []byte("a"))
+	require.YesError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	_, _, err = client.FileCompareAndSwap(repo, commit.ID, "lock", hashOf(getFile()), []byte("a"))
+	require.YesError(t, err)
 }
 
-// FinishCommit should block until the parent has been finished
-func TestFinishCommit(t *testing.T) {
+func TestBlockReferences(t *testing.T) {
 	t.Parallel()
+	client, servers := getClientAndServer(t)
 
-	client, _ := getClientAndServer(t)
-	repo := "TestFinishCommit"
-
-	require.NoError(t, client.CreateRepo(repo))
+	// references returns the references to content's block as
+	// repo/commit/path
+	references := func(content string) []string {
+		hash := sha512.Sum512([]byte(content))
+		files, err := client.BlockReferences(base64.URLEncoding.EncodeToString(hash[:]))
+		require.NoError(t, err)
+		var result []string
+		for _, file := range files {
+			result = append(result, path.Join(file.Commit.Repo.Name, file.Commit.ID, file.Path))
+		}
+		sort.Strings(result)
+		return result
+	}
+	require.Equal(t, 0, len(references("foo\n")))
 
-	commit1, err := client.StartCommit(repo, "", "")
+	// files with the same content share a block
+	require.NoError(t, client.CreateRepo("repo1"))
+	require.NoError(t, client.CreateRepo("repo2"))
+	commit1, err := client.StartCommit("repo1", "", "")
 	require.NoError(t, err)
-
-	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	commit2, err := client.StartCommit("repo2", "", "")
 	require.NoError(t, err)
-
-	ch := make(chan bool)
-	go func() {
-		require.NoError(t, client.FinishCommit(repo, commit2.ID))
-		close(ch)
-	}()
-
-	time.Sleep(time.Second * 2)
-	select {
-	case <-ch:
-		t.Fatalf("should block, since the parent commit has not been finished")
-	default:
+	for _, filePath := range []string{"a", "dir/b"} {
+		_, err = client.PutFile("repo1", commit1.ID, filePath, strings.NewReader("foo\n"))
+		require.NoError(t, err)
 	}
-
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
-
-	time.Sleep(time.Second * 2)
-	select {
-	case <-ch:
-	default:
-		t.Fatalf("should not block, since the parent commit has been finished")
+	_, err = client.PutFile("repo2", commit2.ID, "c", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile("repo2", commit2.ID, "d", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	expected := []string{
+		path.Join("repo1", commit1.ID, "a"),
+		path.Join("repo1", commit1.ID, "dir/b"),
+		path.Join("repo2", commit2.ID, "c"),
+	}
+	sort.Strings(expected)
+	require.Equal(t, expected, references("foo\n"))
+	require.Equal(t, []string{path.Join("repo2", commit2.ID, "d")}, references("bar\n"))
+
+	// replacing a file's content in its commit drops its reference
+	fooHash := sha256.Sum256([]byte("foo\n"))
+	swapped, _, err := client.FileCompareAndSwap("repo1", commit1.ID, "a", hex.EncodeToString(fooHash[:]), []byte("bar\n"))
+	require.NoError(t, err)
+	require.True(t, swapped)
+	require.Equal(t, expected[1:], references("foo\n"))
+	require.Equal(t, 2, len(references("bar\n")))
+	require.NoError(t, client.FinishCommit("repo1", commit1.ID))
+	require.NoError(t, client.FinishCommit("repo2", commit2.ID))
+
+	// deleting a file in a later commit doesn't, the earlier commit still
+	// needs the block
+	commit3, err := client.StartCommit("repo2", commit2.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile("repo2", commit3.ID, "c", false, ""))
+	require.NoError(t, client.FinishCommit("repo2", commit3.ID))
+	require.Equal(t, expected[1:], references("foo\n"))
+
+	// writing the content again in a later commit adds a reference in that
+	// commit
+	commit4, err := client.StartCommit("repo1", commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile("repo1", commit4.ID, "e", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	expected = append(expected[1:], path.Join("repo1", commit4.ID, "e"))
+	sort.Strings(expected)
+	require.Equal(t, expected, references("foo\n"))
+
+	// an unsafe delete in the same commit drops the reference
+	require.NoError(t, client.DeleteFile("repo1", commit4.ID, "e", true, ""))
+	expected = []string{
+		path.Join("repo1", commit1.ID, "dir/b"),
+		path.Join("repo2", commit2.ID, "c"),
 	}
+	sort.Strings(expected)
+	require.Equal(t, expected, references("foo\n"))
+	require.NoError(t, client.FinishCommit("repo1", commit4.ID))
+
+	// references survive the shards being reloaded
+	restartServer(servers, t)
+	require.Equal(t, expected, references("foo\n"))
+
+	// and so does deleting the repo
+	require.NoError(t, client.DeleteRepo("repo2"))
+	require.Equal(t, []string{path.Join("repo1", commit1.ID, "dir/b")}, references("foo\n"))
+	require.Equal(t, []string{path.Join("repo1", commit1.ID, "a")}, references("bar\n"))
 }
 
-func TestStartCommitWithNonexistentParent(t *testing.T) {
+func TestGetFileTranscoded(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
-	repo := "TestStartCommitWithNonexistentParent"
+
+	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-	_, err := client.StartCommit(repo, "nonexistent", "")
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	// characters of every UTF-8 length, the odd first byte makes them
+	// straddle the boundaries between the chunks the content is read in
+	text := "a" + strings.Repeat("é€😀\n", 64*1024)
+	var utf16le bytes.Buffer
+	for _, u := range utf16.Encode([]rune(text)) {
+		require.NoError(t, binary.Write(&utf16le, binary.LittleEndian, u))
+	}
+	var latin1 []byte
+	var latin1Text []rune
+	for i := 0; i < 256*1024; i++ {
+		latin1 = append(latin1, byte(i))
+		latin1Text = append(latin1Text, rune(byte(i)))
+	}
+	for filePath, content := range map[string]string{
+		"utf8":    text,
+		"utf16le": utf16le.String(),
+		"latin1":  string(latin1),
+	} {
+		_, err = client.PutFileWithDelimiter(repo, commit.ID, filePath, pfsclient.Delimiter_NONE, strings.NewReader(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	getFile := func(filePath string, sourceEncoding string, targetEncoding string) (string, error) {
+		var buffer bytes.Buffer
+		err := client.GetFileTranscoded(repo, commit.ID, filePath, sourceEncoding, targetEncoding, &buffer)
+		return buffer.String(), err
+	}
+	content, err := getFile("utf8", "utf-8", "UTF-16LE")
+	require.NoError(t, err)
+	require.Equal(t, utf16le.String(), content)
+	content, err = getFile("utf16le", "utf-16le", "utf-8")
+	require.NoError(t, err)
+	require.Equal(t, text, content)
+	content, err = getFile("latin1", "latin1", "utf-8")
+	require.NoError(t, err)
+	require.Equal(t, string(latin1Text), content)
+	content, err = getFile("latin1", "latin1", "utf-16be")
+	require.NoError(t, err)
+	require.Equal(t, 2*len(latin1), len(content))
+
+	// content that isn't valid or can't be represented is an error
+	_, err = getFile("latin1", "utf-8", "utf-16le")
+	require.YesError(t, err)
+	_, err = getFile("utf8", "utf-8", "iso-8859-1")
+	require.YesError(t, err)
+	_, err = getFile("utf8", "utf-8", "ebcdic")
+	require.YesError(t, err)
+	_, err = getFile("utf8", "utf-8", "")
 	require.YesError(t, err)
 }
 
-// If a commit's parent has been cancelled, the commit should be cancelled too
-func TestFinishCommitParentCancelled(t *testing.T) {
+func TestOpenCommits(t *testing.T) {
 	t.Parallel()
-
 	client, _ := getClientAndServer(t)
-	repo := "TestFinishCommitParentCancelled"
 
-	require.NoError(t, client.CreateRepo(repo))
-
-	commit1, err := client.StartCommit(repo, "", "")
+	commitInfos, err := client.OpenCommits()
 	require.NoError(t, err)
+	require.Equal(t, 0, len(commitInfos))
 
-	commit2, err := client.StartCommit(repo, commit1.ID, "")
-	require.NoError(t, err)
+	// each repo has a finished commit and two open ones
+	openCommits := make(map[string]string)
+	for _, repo := range []string{"repo1", "repo2", "repo3"} {
+		require.NoError(t, client.CreateRepo(repo))
+		commit, err := client.StartCommit(repo, "", "master")
+		require.NoError(t, err)
+		_, err = client.PutFile(repo, commit.ID, "file", strings.NewReader("foo\n"))
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit(repo, commit.ID))
+		for i := 0; i < 2; i++ {
+			commit, err := client.StartCommit(repo, "", "")
+			require.NoError(t, err)
+			_, err = client.PutFile(repo, commit.ID, "file", strings.NewReader("foo\n"))
+			require.NoError(t, err)
+			openCommits[commit.ID] = repo
+		}
+	}
 
-	ch := make(chan bool)
-	go func() {
-		require.NoError(t, client.FinishCommit(repo, commit2.ID))
-		close(ch)
-	}()
+	commitInfos, err = client.OpenCommits()
+	require.NoError(t, err)
+	require.Equal(t, len(openCommits), len(commitInfos))
+	for i, commitInfo := range commitInfos {
+		require.Equal(t, openCommits[commitInfo.Commit.ID], commitInfo.Commit.Repo.Name)
+		require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_WRITE, commitInfo.CommitType)
+		require.True(t, commitInfo.Started != nil)
+		// the file is in one shard, the sizes from every server are summed
+		require.Equal(t, uint64(4), commitInfo.SizeBytes)
+		if i > 0 {
+			require.False(t, prototime.TimestampToTime(commitInfo.Started).After(prototime.TimestampToTime(commitInfos[i-1].Started)))
+		}
+	}
 
-	require.NoError(t, client.CancelCommit(repo, commit1.ID))
+	// finished commits stop being listed
+	for commitID, repo := range openCommits {
+		require.NoError(t, client.FinishCommit(repo, commitID))
+		delete(openCommits, commitID)
+		break
+	}
+	commitInfos, err = client.OpenCommits()
+	require.NoError(t, err)
+	require.Equal(t, len(openCommits), len(commitInfos))
+}
 
-	time.Sleep(time.Second * 2)
-	select {
-	case <-ch:
-	default:
-		t.Fatalf("should not block, since the parent commit has been finished")
+func TestExportToPath(t *testing.T) {
+	t.Parallel()
+	exportRoot := uniqueString("/tmp/pach_test/export")
+	require.NoError(t, os.MkdirAll(exportRoot, 0755))
+	defer os.RemoveAll(exportRoot)
+	client, _ := getClientAndServerWithAPIOptions(t, APIServerOptions{ExportRoot: exportRoot})
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	expected := map[string]string{
+		"a":         "a\n",
+		"dir/b":     "b\n",
+		"dir/sub/c": strings.Repeat("c\n", 512*1024),
+		"empty":     "dir",
+		"dir":       "dir",
+		"dir/sub":   "dir",
+	}
+	for _, filePath := range []string{"a", "dir/b", "dir/sub/c"} {
+		_, err = client.PutFile(repo, commit.ID, filePath, strings.NewReader(expected[filePath]))
+		require.NoError(t, err)
 	}
+	require.NoError(t, client.MakeDirectory(repo, commit.ID, "empty"))
+	_, err = client.ExportToPath(repo, commit.ID, "out")
+	require.YesError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
 
-	commit2Info, err := client.InspectCommit(repo, commit2.ID)
-	require.True(t, commit2Info.Cancelled)
+	response, err := client.ExportToPath(repo, commit.ID, "out")
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), response.Files)
+	require.Equal(t, uint64(len(expected["a"])+len(expected["dir/b"])+len(expected["dir/sub/c"])), response.SizeBytes)
 
-	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	// the tree on disk maps directories to "dir" and files to their content
+	root := filepath.Join(exportRoot, "out")
+	exported := make(map[string]string)
+	require.NoError(t, filepath.Walk(root, func(localPath string, info os.FileInfo, err error) error {
+		if err != nil || localPath == root {
+			return err
+		}
+		relPath, err := filepath.Rel(root, localPath)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			exported[relPath] = "dir"
+			return nil
+		}
+		content, err := ioutil.ReadFile(localPath)
+		if err != nil {
+			return err
+		}
+		exported[relPath] = string(content)
+		return nil
+	}))
+	require.Equal(t, expected, exported)
+
+	// nothing can be written outside of the export root
+	outside := uniqueString("/tmp/pach_test/outside")
+	require.NoError(t, os.MkdirAll(outside, 0755))
+	defer os.RemoveAll(outside)
+	for _, path := range []string{outside, "../outside", "out/../../outside"} {
+		_, err = client.ExportToPath(repo, commit.ID, path)
+		require.YesError(t, err)
+	}
+	require.NoError(t, os.Symlink(outside, filepath.Join(exportRoot, "link")))
+	_, err = client.ExportToPath(repo, commit.ID, "link")
+	require.YesError(t, err)
+	_, err = client.ExportToPath(repo, commit.ID, "link/sub")
+	require.YesError(t, err)
+	// a symlink already in the tree isn't written through either
+	require.NoError(t, os.MkdirAll(filepath.Join(exportRoot, "out2"), 0755))
+	require.NoError(t, os.Symlink(outside, filepath.Join(exportRoot, "out2", "dir")))
+	_, err = client.ExportToPath(repo, commit.ID, "out2")
+	require.YesError(t, err)
+	entries, err := ioutil.ReadDir(outside)
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit3.ID))
-	commit3Info, err := client.InspectCommit(repo, commit3.ID)
-	require.True(t, commit3Info.Cancelled)
+	require.Equal(t, 0, len(entries))
+
+	// ExportToPath is disabled without an export root
+	client, _ = getClientAndServer(t)
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err = client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	_, err = client.ExportToPath(repo, commit.ID, "out")
+	require.YesError(t, err)
 }
 
-func TestHandleRace(t *testing.T) {
+func TestImportCommit(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-	commit, err := client.StartCommit(repo, "", "")
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	writer1, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle1")
+	for i := 0; i < 10; i++ {
+		_, err = client.PutFile(repo, commit1.ID, fmt.Sprintf("dir%d/sub/file%d", i%3, i), strings.NewReader(fmt.Sprintf("content %d\n", i)))
+		require.NoError(t, err)
+	}
+	_, err = client.PutFileWithXattrs(repo, commit1.ID, "big", map[string]string{"user.a": "1"},
+		strings.NewReader(strings.Repeat("big\n", 512*1024)))
 	require.NoError(t, err)
-	_, err = writer1.Write([]byte("foo"))
+	require.NoError(t, client.MakeDirectory(repo, commit1.ID, "empty"))
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// exportedFiles returns the files in commit, directories map to
+	// "dir" and regular files to their xattrs and content
+	exportedFiles := func(repo string, commitID string) map[string]string {
+		files := make(map[string]string)
+		var current string
+		require.NoError(t, client.ExportCommit(repo, commitID, func(record *pfsclient.ExportRecord) error {
+			if record.FileInfo != nil {
+				current = record.FileInfo.File.Path
+				if record.FileInfo.FileType == pfsclient.FileType_FILE_TYPE_DIR {
+					files[current] = "dir"
+				} else {
+					files[current] = fmt.Sprintf("%v ", record.FileInfo.Xattrs)
+				}
+			}
+			files[current] += string(record.Value)
+			return nil
+		}))
+		return files
+	}
+	expected := exportedFiles(repo, commit1.ID)
+	require.Equal(t, 18, len(expected))
+
+	require.NoError(t, client.CreateRepo("imported"))
+	commit, err := client.ImportCommit("imported", "", "master", func(send func(*pfsclient.ExportRecord) error) error {
+		return client.ExportCommit(repo, commit1.ID, send)
+	})
 	require.NoError(t, err)
-	writer2, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle2")
+	commitInfo, err := client.InspectBranch("imported", "master")
 	require.NoError(t, err)
-	_, err = writer2.Write([]byte("bar"))
+	require.Equal(t, commit.ID, commitInfo.Commit.ID)
+	require.True(t, commitInfo.Finished != nil)
+	require.Equal(t, expected, exportedFiles("imported", commit.ID))
+	fileInfo, err := client.InspectFile("imported", commit.ID, "big", "", nil)
 	require.NoError(t, err)
-	require.NoError(t, writer2.Close())
-	_, err = writer1.Write([]byte("foo"))
+	require.Equal(t, map[string]string{"user.a": "1"}, fileInfo.Xattrs)
+
+	// importing into the repo the export came from gets a new commit rather
+	// than conflicting with the exported one
+	commit2, err := client.ImportCommit(repo, commit1.ID, "", func(send func(*pfsclient.ExportRecord) error) error {
+		return client.ExportCommit(repo, commit1.ID, send)
+	})
 	require.NoError(t, err)
-	require.NoError(t, writer1.Close())
-	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	require.True(t, commit2.ID != commit1.ID)
 	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
-	require.EqualOneOf(t, []interface{}{"foofoobar", "barfoofoo"}, buffer.String())
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir0/sub/file0", 0, 0, "", nil, &buffer))
+	require.Equal(t, "content 0\ncontent 0\n", buffer.String())
+
+	// a failed import cancels its commit
+	_, err = client.ImportCommit("imported", commit.ID, "", func(send func(*pfsclient.ExportRecord) error) error {
+		for _, filePath := range []string{"partial", "/leading-slash"} {
+			if err := send(&pfsclient.ExportRecord{
+				FileInfo: &pfsclient.FileInfo{
+					File:     pclient.NewFile(repo, commit1.ID, filePath),
+					FileType: pfsclient.FileType_FILE_TYPE_REGULAR,
+				},
+				Value: []byte("partial\n"),
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.YesError(t, err)
+	commitInfos, err := client.ListCommit([]string{"imported"}, nil, pfsclient.CommitType_COMMIT_TYPE_NONE, false, true, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+	for _, commitInfo := range commitInfos {
+		require.True(t, commitInfo.Finished != nil)
+		require.Equal(t, commitInfo.Commit.ID != commit.ID, commitInfo.Cancelled)
+	}
+
+	// records can't write outside of the commit
+	_, err = client.ImportCommit("imported", commit.ID, "", func(send func(*pfsclient.ExportRecord) error) error {
+		return send(&pfsclient.ExportRecord{
+			FileInfo: &pfsclient.FileInfo{
+				File:     pclient.NewFile(repo, commit1.ID, "dir/../../escape"),
+				FileType: pfsclient.FileType_FILE_TYPE_REGULAR,
+			},
+			Value: []byte("escape\n"),
+		})
+	})
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "outside of the commit"))
 }
 
-func Test0Modulus(t *testing.T) {
+func TestFilesExist(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
+
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-	commit, err := client.StartCommit(repo, "", "")
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	for _, filePath := range []string{"a", "dir/b"} {
+		_, err = client.PutFile(repo, commit1.ID, filePath, strings.NewReader(filePath))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	paths := []string{"a", "c", "dir", "dir/b", "dir/nope", "dir2", "dir2/d", "nope"}
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit.ID))
-	zeroModulusShard := &pfsclient.Shard{}
-	fileInfo, err := client.InspectFile(repo, commit.ID, "foo", "", zeroModulusShard)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "a", false, ""))
+	for _, filePath := range []string{"c", "dir2/d"} {
+		_, err = client.PutFile(repo, commit2.ID, filePath, strings.NewReader(filePath))
+		require.NoError(t, err)
+	}
+	// commit2 is open so we still see commit1
+	exists, err := client.FilesExist(repo, commit2.ID, paths)
 	require.NoError(t, err)
-	require.Equal(t, uint64(4), fileInfo.SizeBytes)
-	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", zeroModulusShard, &buffer))
-	require.Equal(t, 4, buffer.Len())
-	fileInfos, err := client.ListFile(repo, commit.ID, "", "", zeroModulusShard, false)
+	require.Equal(t, []bool{true, false, true, true, false, false, false, false}, exists)
+
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	exists, err = client.FilesExist(repo, commit2.ID, paths)
 	require.NoError(t, err)
-	require.Equal(t, 1, len(fileInfos))
-	require.Equal(t, uint64(4), fileInfos[0].SizeBytes)
+	require.Equal(t, []bool{false, true, true, true, false, true, true, false}, exists)
+	// and we agree with InspectFile
+	for i, filePath := range paths {
+		_, err := client.InspectFile(repo, commit2.ID, filePath, "", nil)
+		require.Equal(t, exists[i], err == nil, filePath)
+	}
+
+	_, err = client.FilesExist(repo, commit2.ID, []string{"a", "/a"})
+	require.YesError(t, err)
 }
 
-func TestProvenance(t *testing.T) {
+func TestDeleteFiles(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
-	require.NoError(t, client.CreateRepo("A"))
-	_, err := client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
-		Repo:       pclient.NewRepo("B"),
-		Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
-	})
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
-		Repo:       pclient.NewRepo("C"),
-		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
-	})
+	for _, filePath := range []string{"a", "b", "c", "dir/d", "dir/e"} {
+		_, err = client.PutFile(repo, commit1.ID, filePath, strings.NewReader(filePath))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-	repoInfo, err := client.InspectRepo("B")
+	results, err := client.DeleteFiles(repo, commit2.ID, []string{"a", "nope", "/b", "dir", "c"}, false, "")
 	require.NoError(t, err)
-	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("A")}, repoInfo.Provenance)
-	repoInfo, err = client.InspectRepo("C")
+	require.Equal(t, 5, len(results))
+	for i, filePath := range []string{"a", "nope", "/b", "dir", "c"} {
+		require.Equal(t, filePath, results[i].File.Path)
+	}
+	require.Equal(t, "", results[0].Error)
+	require.True(t, results[1].NotFound)
+	require.True(t, results[1].Error != "")
+	require.False(t, results[2].NotFound)
+	require.True(t, results[2].Error != "")
+	require.Equal(t, "", results[3].Error)
+	require.Equal(t, "", results[4].Error)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	fileInfos, err := client.ListFile(repo, commit2.ID, "", "", nil, false)
 	require.NoError(t, err)
-	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("B"), pclient.NewRepo("A")}, repoInfo.Provenance)
-	ACommit, err := client.StartCommit("A", "", "")
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, "b", fileInfos[0].File.Path)
+	// the parent still has everything
+	fileInfos, err = client.ListFile(repo, commit1.ID, "", "", nil, false)
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("A", ACommit.ID))
-	BCommit, err := client.PfsAPIClient.StartCommit(
-		context.Background(),
-		&pfsclient.StartCommitRequest{
-			Repo:       pclient.NewRepo("B"),
-			Provenance: []*pfsclient.Commit{ACommit},
+	require.Equal(t, 4, len(fileInfos))
+}
+
+func TestInspectBranch(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	_, err := client.InspectBranch(repo, "master")
+	require.YesError(t, err)
+	require.NoError(t, client.CreateRepo(repo))
+	_, err = client.InspectBranch(repo, "master")
+	require.YesError(t, err)
+
+	var parent *pfsclient.Commit
+	for i := 0; i < 3; i++ {
+		commit, err := client.StartCommit(repo, "", "master")
+		require.NoError(t, err)
+		commitInfo, err := client.InspectBranch(repo, "master")
+		require.NoError(t, err)
+		require.Equal(t, commit.ID, commitInfo.Commit.ID)
+		require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_WRITE, commitInfo.CommitType)
+		require.Equal(t, parent, commitInfo.ParentCommit)
+
+		require.NoError(t, client.FinishCommit(repo, commit.ID))
+		commitInfo, err = client.InspectBranch(repo, "master")
+		require.NoError(t, err)
+		require.Equal(t, commit.ID, commitInfo.Commit.ID)
+		require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_READ, commitInfo.CommitType)
+		parent = commit
+	}
+	_, err = client.InspectBranch(repo, "foo")
+	require.YesError(t, err)
+}
+
+func TestPutFileURL(t *testing.T) {
+	t.Parallel()
+	// the test server listens on loopback
+	client, _ := getClientAndServerWithInternalAPIOptions(t, InternalAPIServerOptions{AllowPrivateURLs: true})
+
+	var content bytes.Buffer
+	for i := 0; i < 10000; i++ {
+		fmt.Fprintf(&content, "line %d\n", i)
+	}
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == "/chunked" {
+			// flushing before we're done writing means there's no
+			// Content-Length
+			w.Write(content.Bytes()[:100])
+			w.(http.Flusher).Flush()
+			w.Write(content.Bytes()[100:])
+			return
+		}
+		w.Write(content.Bytes())
+	}))
+	defer source.Close()
+	auth := map[string]string{"Authorization": "Bearer secret"}
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "file", source.URL, nil, 0))
+	require.NoError(t, client.PutFileURL(repo, commit.ID, "dir/file", source.URL, auth, 0))
+	require.NoError(t, client.PutFileURL(repo, commit.ID, "exact", source.URL+"/chunked", auth, uint64(content.Len())))
+	// too big, whether or not the source tells us up front
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "big", source.URL, auth, 1000))
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "big", source.URL+"/chunked", auth, 1000))
+	// only http and https are fetched
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "big", "file:///etc/passwd", nil, 0))
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "big", strings.Replace(source.URL, "http", "ftp", 1), auth, 0))
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	for _, filePath := range []string{"dir/file", "exact"} {
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit.ID, filePath, 0, 0, "", nil, &buffer))
+		require.Equal(t, content.String(), buffer.String())
+	}
+	fileInfos, err := client.ListFile(repo, commit.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+	_, err = client.InspectFile(repo, commit.ID, "big", "", nil)
+	require.YesError(t, err)
+}
+
+func TestPutFileURLDenyPrivate(t *testing.T) {
+	t.Parallel()
+	// private addresses are denied by default
+	client, _ := getClientAndServer(t)
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo\n"))
+	}))
+	defer source.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(source.URL, http.StatusFound))
+	defer redirect.Close()
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	// the test servers listen on loopback
+	err = client.PutFileURL(repo, commit.ID, "file", source.URL, nil, 0)
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "private"))
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "file", strings.Replace(source.URL, "127.0.0.1", "localhost", 1), nil, 0))
+	require.YesError(t, client.PutFileURL(repo, commit.ID, "file", redirect.URL, nil, 0))
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	fileInfos, err := client.ListFile(repo, commit.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fileInfos))
+
+	// a proxy would be checked rather than the target, so none is used
+	require.True(t, newURLFetcher(true).client.Transport.(*http.Transport).Proxy == nil)
+	require.True(t, newURLFetcher(false).client.Transport.(*http.Transport).Proxy != nil)
+}
+
+func TestGetFileRange(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	// each put creates its own block
+	content := ""
+	for _, value := range []string{"foo\n", "bar\n", "buzz\n"} {
+		_, err = client.PutFile(repo, commit.ID, "file", strings.NewReader(value))
+		require.NoError(t, err)
+		content += value
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	for offset := 0; offset <= len(content); offset++ {
+		for size := 1; offset+size <= len(content); size++ {
+			var buffer bytes.Buffer
+			require.NoError(t, client.GetFile(repo, commit.ID, "file", int64(offset), int64(size), "", nil, &buffer))
+			require.Equal(t, content[offset:offset+size], buffer.String())
+		}
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "file", 6, 0, "", nil, &buffer))
+	require.Equal(t, content[6:], buffer.String())
+}
+
+// benchmarkGetFileRangeSetup puts a 64MB file made of 8 blocks and returns
+// the commit it's in
+func benchmarkGetFileRangeSetup(b *testing.B, client pclient.APIClient, repo string) *pfsclient.Commit {
+	require.NoError(b, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(b, err)
+	line := strings.Repeat("a", 1023) + "\n"
+	for i := 0; i < 8; i++ {
+		_, err = client.PutFile(repo, commit.ID, "file", strings.NewReader(strings.Repeat(line, 8*1024)))
+		require.NoError(b, err)
+	}
+	require.NoError(b, client.FinishCommit(repo, commit.ID))
+	return commit
+}
+
+// BenchmarkGetFileWholeFile reads 1KB from the end of a large file by reading
+// the whole file and seeking within it
+func BenchmarkGetFileWholeFile(b *testing.B) {
+	client, _ := getClientAndServer(b)
+	repo := "test"
+	commit := benchmarkGetFileRangeSetup(b, client, repo)
+	offset, size := int64(60*1024*1024), int64(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buffer bytes.Buffer
+		require.NoError(b, client.GetFile(repo, commit.ID, "file", 0, 0, "", nil, &buffer))
+		data, err := ioutil.ReadAll(io.NewSectionReader(bytes.NewReader(buffer.Bytes()), offset, size))
+		require.NoError(b, err)
+		require.Equal(b, int(size), len(data))
+	}
+}
+
+// BenchmarkGetFileBlockRange reads 1KB from the end of a large file with a
+// ranged GetFile, which only reads the block containing the range
+func BenchmarkGetFileBlockRange(b *testing.B) {
+	client, _ := getClientAndServer(b)
+	repo := "test"
+	commit := benchmarkGetFileRangeSetup(b, client, repo)
+	offset, size := int64(60*1024*1024), int64(1024)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var buffer bytes.Buffer
+		require.NoError(b, client.GetFile(repo, commit.ID, "file", offset, size, "", nil, &buffer))
+		require.Equal(b, int(size), buffer.Len())
+	}
+}
+
+func TestListFileMulti(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	var files []*pfsclient.File
+	for i, repo := range []string{"A", "B", "C"} {
+		require.NoError(t, client.CreateRepo(repo))
+		commit, err := client.StartCommit(repo, "", "")
+		require.NoError(t, err)
+		// each repo has a different number of files under dir
+		for j := 0; j <= i; j++ {
+			_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir/%s%d", repo, j), strings.NewReader("foo\n"))
+			require.NoError(t, err)
+		}
+		_, err = client.PutFile(repo, commit.ID, "other", strings.NewReader("foo\n"))
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit(repo, commit.ID))
+		files = append(files, pclient.NewFile(repo, commit.ID, "dir"))
+	}
+
+	results, err := client.ListFileMulti(files, false)
+	require.NoError(t, err)
+	require.Equal(t, len(files), len(results))
+	for i, fileInfos := range results {
+		repo := files[i].Commit.Repo.Name
+		expected, err := client.ListFile(repo, files[i].Commit.ID, "dir", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, i+1, len(fileInfos))
+		require.Equal(t, expected, fileInfos)
+		for _, fileInfo := range fileInfos {
+			require.Equal(t, repo, fileInfo.File.Commit.Repo.Name)
+			require.True(t, strings.HasPrefix(fileInfo.File.Path, "dir/"+repo))
+		}
+	}
+
+	// a target that doesn't exist fails the whole call
+	_, err = client.ListFileMulti(append(files, pclient.NewFile("D", "master", "dir")), false)
+	require.YesError(t, err)
+}
+
+func TestListFileUnion(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "a", strings.NewReader("a\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/x", strings.NewReader("x\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "b", strings.NewReader("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "a", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "dir/y", strings.NewReader("y\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit3.ID, "dir/x", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	commit4, err := client.StartCommit(repo, commit3.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit4.ID, "c", strings.NewReader("c\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit4.ID))
+
+	listFileUnion := func(dir string, from *pfsclient.Commit) map[string][]string {
+		fileInfos, err := client.PfsAPIClient.ListFile(
+			context.Background(),
+			&pfsclient.ListFileRequest{
+				File:       pclient.NewFile(repo, commit4.ID, dir),
+				FromCommit: from,
+				Union:      true,
+			},
+		)
+		require.NoError(t, err)
+		result := make(map[string][]string)
+		for _, fileInfo := range fileInfos.FileInfo {
+			var commitIDs []string
+			for _, commit := range fileInfo.ExistedIn {
+				commitIDs = append(commitIDs, commit.ID)
+			}
+			sort.Strings(commitIDs)
+			result[fileInfo.File.Path] = commitIDs
+		}
+		return result
+	}
+	sorted := func(commits ...*pfsclient.Commit) []string {
+		var result []string
+		for _, commit := range commits {
+			result = append(result, commit.ID)
+		}
+		sort.Strings(result)
+		return result
+	}
+
+	require.Equal(t, map[string][]string{
+		"a":   sorted(commit1),
+		"b":   sorted(commit2, commit3, commit4),
+		"c":   sorted(commit4),
+		"dir": sorted(commit1, commit2, commit3, commit4),
+	}, listFileUnion("", nil))
+	require.Equal(t, map[string][]string{
+		"b":   sorted(commit2, commit3, commit4),
+		"c":   sorted(commit4),
+		"dir": sorted(commit2, commit3, commit4),
+	}, listFileUnion("", commit2))
+	require.Equal(t, map[string][]string{
+		"dir/x": sorted(commit1, commit2),
+		"dir/y": sorted(commit3, commit4),
+	}, listFileUnion("dir", nil))
+	require.Equal(t, map[string][]string{
+		"dir/y": sorted(commit3, commit4),
+	}, listFileUnion("dir", commit3))
+}
+
+func TestListFileIncludeDeleted(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	for _, file := range []string{"dir/a", "dir/b", "dir/sub/c"} {
+		_, err = client.PutFile(repo, commit1.ID, file, strings.NewReader(file))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir/a", false, ""))
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir/sub", false, ""))
+	_, err = client.PutFile(repo, commit2.ID, "dir/d", strings.NewReader("dir/d"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	listFile := func(dir string) map[string]bool {
+		fileInfos, err := client.PfsAPIClient.ListFile(
+			context.Background(),
+			&pfsclient.ListFileRequest{
+				File:           pclient.NewFile(repo, commit2.ID, dir),
+				IncludeDeleted: true,
+			},
+		)
+		require.NoError(t, err)
+		result := make(map[string]bool)
+		for _, fileInfo := range fileInfos.FileInfo {
+			result[fileInfo.File.Path] = fileInfo.Deleted
+		}
+		return result
+	}
+	require.Equal(t, map[string]bool{
+		"dir/a":   true,
+		"dir/b":   false,
+		"dir/d":   false,
+		"dir/sub": true,
+	}, listFile("dir"))
+	// everything under a deleted directory is deleted
+	require.Equal(t, map[string]bool{
+		"dir/sub/c": true,
+	}, listFile("dir/sub"))
+
+	// a tombstone's info is from the last commit the file existed in
+	fileInfos, err := client.PfsAPIClient.ListFile(
+		context.Background(),
+		&pfsclient.ListFileRequest{
+			File:           pclient.NewFile(repo, commit2.ID, "dir/a"),
+			IncludeDeleted: true,
 		},
 	)
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("B", BCommit.ID))
-	commitInfo, err := client.InspectCommit("B", BCommit.ID)
+	require.Equal(t, 1, len(fileInfos.FileInfo))
+	require.True(t, fileInfos.FileInfo[0].Deleted)
+	require.Equal(t, uint64(len("dir/a")), fileInfos.FileInfo[0].SizeBytes)
+	require.Equal(t, commit1.ID, fileInfos.FileInfo[0].ExistedIn[0].ID)
+
+	// plain listings don't include deleted files
+	plainFileInfos, err := client.ListFile(repo, commit2.ID, "dir", "", nil, false)
 	require.NoError(t, err)
-	require.Equal(t, []*pfsclient.Commit{ACommit}, commitInfo.Provenance)
-	CCommit, err := client.PfsAPIClient.StartCommit(
+	require.Equal(t, 2, len(plainFileInfos))
+
+	_, err = client.PfsAPIClient.ListFile(
 		context.Background(),
-		&pfsclient.StartCommitRequest{
-			Repo:       pclient.NewRepo("C"),
-			Provenance: []*pfsclient.Commit{BCommit},
+		&pfsclient.ListFileRequest{
+			File:           pclient.NewFile(repo, commit2.ID, "dir"),
+			Union:          true,
+			IncludeDeleted: true,
 		},
 	)
+	require.YesError(t, err)
+}
+
+func TestListFileTwoCommits(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	numFiles := 5
+
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("C", CCommit.ID))
-	commitInfo, err = client.InspectCommit("C", CCommit.ID)
+
+	for i := 0; i < numFiles; i++ {
+		_, err = client.PutFile(repo, commit1.ID, fmt.Sprintf("file%d", i), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+
+	for i := 0; i < numFiles; i++ {
+		_, err = client.PutFile(repo, commit2.ID, fmt.Sprintf("file2-%d", i), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, numFiles, len(fileInfos))
+
+	fileInfos, err = client.ListFile(repo, commit2.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 2*numFiles, len(fileInfos))
+}
+
+func TestPutSameFileInParallel(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+			require.NoError(t, err)
+			wg.Done()
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nfoo\nfoo\n", buffer.String())
+}
+
+func TestGetFileLines(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	var lines []string
+	for i := 0; i < 2000; i++ {
+		line := fmt.Sprintf("line %d\n", i)
+		if i == 5 {
+			// longer than the reader's buffer
+			line = strings.Repeat("x", 10000) + "\n"
+		}
+		lines = append(lines, line)
+	}
+	_, err = client.PutFile(repo, commit.ID, "file", strings.NewReader(strings.Join(lines, "")))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "no-newline", strings.NewReader("foo\nbar"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	getLines := func(path string, start uint64, count uint64) string {
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFileLines(repo, commit.ID, path, start, count, &buffer))
+		return buffer.String()
+	}
+	require.Equal(t, strings.Join(lines[1000:1100], ""), getLines("file", 1000, 100))
+	require.Equal(t, lines[0], getLines("file", 0, 1))
+	require.Equal(t, strings.Join(lines[4:7], ""), getLines("file", 4, 3))
+	require.Equal(t, strings.Join(lines[6:8], ""), getLines("file", 6, 2))
+	require.Equal(t, strings.Join(lines[1995:], ""), getLines("file", 1995, 0))
+	require.Equal(t, strings.Join(lines[1995:], ""), getLines("file", 1995, 100))
+	require.Equal(t, "", getLines("file", 5000, 10))
+	require.Equal(t, "bar", getLines("no-newline", 1, 1))
+	require.Equal(t, "foo\nbar", getLines("no-newline", 0, 0))
+}
+
+func TestGetFileBase64(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	// big enough to be spread over several messages, and not a multiple of 3
+	content := generateRandomString(500000)
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	getFileClient, err := client.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfsclient.GetFileRequest{
+			File:      pclient.NewFile(repo, commit.ID, "foo"),
+			SizeBytes: math.MaxInt64,
+			Base64:    true,
+		},
+	)
+	require.NoError(t, err)
+	var buffer bytes.Buffer
+	var messages int
+	for {
+		value, err := getFileClient.Recv()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		// each message should decode on its own
+		decoded, err := base64.StdEncoding.DecodeString(string(value.Value))
+		require.NoError(t, err)
+		buffer.Write(decoded)
+		messages++
+	}
+	require.True(t, messages > 1)
+	require.Equal(t, content, buffer.String())
+}
+
+func TestInspectFile(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	fileContent1 := "foo\n"
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(fileContent1))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	fileInfo, err := client.InspectFile(repo, commit1.ID, "foo", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, commit1, fileInfo.CommitModified)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
+	require.Equal(t, len(fileContent1), int(fileInfo.SizeBytes))
+
+	fileContent2 := "barbar\n"
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader(fileContent2))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	fileInfo, err = client.InspectFile(repo, commit2.ID, "foo", commit1.ID, nil)
+	require.NoError(t, err)
+	require.Equal(t, commit2, fileInfo.CommitModified)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
+	require.Equal(t, len(fileContent2), int(fileInfo.SizeBytes))
+
+	fileInfo, err = client.InspectFile(repo, commit2.ID, "foo", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, commit2, fileInfo.CommitModified)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileInfo.FileType)
+	require.Equal(t, len(fileContent1)+len(fileContent2), int(fileInfo.SizeBytes))
+
+	fileContent3 := "bar\n"
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "bar", strings.NewReader(fileContent3))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+
+	fileInfos, err := client.ListFile(repo, commit3.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, len(fileInfos), 2)
+}
+
+func TestListFile(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	fileContent1 := "foo\n"
+	_, err = client.PutFile(repo, commit.ID, "dir/foo", strings.NewReader(fileContent1))
+	require.NoError(t, err)
+
+	fileContent2 := "bar\n"
+	_, err = client.PutFile(repo, commit.ID, "dir/bar", strings.NewReader(fileContent2))
+	require.NoError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	fileInfos, err := client.ListFile(repo, commit.ID, "dir", "", nil, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+	require.True(t, fileInfos[0].File.Path == "dir/foo" && fileInfos[1].File.Path == "dir/bar" || fileInfos[0].File.Path == "dir/bar" && fileInfos[1].File.Path == "dir/foo")
+	require.True(t, fileInfos[0].SizeBytes == fileInfos[1].SizeBytes && fileInfos[0].SizeBytes == uint64(len(fileContent1)))
+
+	fileInfos, err = client.ListFile(repo, commit.ID, "dir/foo", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.True(t, fileInfos[0].File.Path == "dir/foo")
+}
+
+func TestListFileMergeIsDeterministic(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	var totalSize uint64
+	for i := 0; i < 20; i++ {
+		content := strings.Repeat("a", i+1)
+		_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir%d/file%d", i%2, i), strings.NewReader(content))
+		require.NoError(t, err)
+		totalSize += uint64(len(content))
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	// dir0 and dir1 are spread across many shards, every listing should
+	// merge them into the same FileInfos
+	var expected []*pfsclient.FileInfo
+	for i := 0; i < 10; i++ {
+		fileInfos, err := client.ListFile(repo, commit.ID, "", "", nil, true)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(fileInfos))
+		require.Equal(t, "dir0", fileInfos[0].File.Path)
+		require.Equal(t, "dir1", fileInfos[1].File.Path)
+		require.Equal(t, totalSize, fileInfos[0].SizeBytes+fileInfos[1].SizeBytes)
+		for _, fileInfo := range fileInfos {
+			require.Equal(t, 10, len(fileInfo.Children))
+			for j := 1; j < len(fileInfo.Children); j++ {
+				require.True(t, fileInfo.Children[j-1].Path < fileInfo.Children[j].Path)
+			}
+		}
+		if expected == nil {
+			expected = fileInfos
+			continue
+		}
+		require.Equal(t, expected, fileInfos)
+	}
+}
+
+func TestListFileCommitModified(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "dir/bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+
+	fileInfos, err := client.ListFile(repo, commit3.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(fileInfos))
+	expected := map[string]string{
+		"foo": commit1.ID,
+		"bar": commit2.ID,
+		"dir": commit3.ID,
+	}
+	for _, fileInfo := range fileInfos {
+		require.Equal(t, expected[fileInfo.File.Path], fileInfo.CommitModified.ID)
+	}
+}
+
+func TestDeleteFile(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	// Commit 1: Add two files; delete one file within the commit
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	fileContent1 := "foo\n"
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(fileContent1))
+	require.NoError(t, err)
+
+	fileContent2 := "bar\n"
+	_, err = client.PutFile(repo, commit1.ID, "bar", strings.NewReader(fileContent2))
+	require.NoError(t, err)
+
+	// The deletion should fail because the file did not exist before this commit,
+	// and files written in the current commit should not be visible yet.
+	require.YesError(t, client.DeleteFile(repo, commit1.ID, "foo", false, ""))
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// foo should still be here because we can't remove a file that we are adding
+	// in the same commit
+	_, err = client.InspectFile(repo, commit1.ID, "foo", "", nil)
+	require.NoError(t, err)
+
+	// Should see two files
+	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+
+	// Empty commit
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	// Should still see two files
+	fileInfos, err = client.ListFile(repo, commit2.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+
+	// Delete foo
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit3.ID, "foo", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+
+	// Should see one file
+	fileInfos, err = client.ListFile(repo, commit3.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+
+	// The removed file should not exist
+	_, err = client.InspectFile(repo, commit3.ID, "foo", "", nil)
+	require.YesError(t, err)
+}
+
+func TestDeleteFileSurvivesRestart(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "foo", false, ""))
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	test := func() {
+		_, err := client.InspectFile(repo, commit2.ID, "foo", "", nil)
+		require.YesError(t, err)
+		_, err = client.InspectFile(repo, commit2.ID, "dir/bar", "", nil)
+		require.YesError(t, err)
+		fileInfos, err := client.ListFile(repo, commit2.ID, "", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, 0, len(fileInfos))
+		// the files are still intact in the parent commit
+		fileInfos, err = client.ListFile(repo, commit1.ID, "", "", nil, false)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(fileInfos))
+	}
+
+	test()
+
+	// the deletes should be reapplied when shards are rebuilt from the
+	// persisted diffs
+	restartServer(server, t)
+
+	test()
+}
+
+func TestInspectDir(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	fileContent1 := "foo\n"
+	_, err = client.PutFile(repo, commit1.ID, "dir/foo", strings.NewReader(fileContent1))
+	require.NoError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	_, err = client.InspectFile(repo, commit1.ID, "dir/foo", "", nil)
+	require.NoError(t, err)
+
+	_, err = client.InspectFile(repo, commit1.ID, "dir", "", nil)
+	require.NoError(t, err)
+
+	// This is a limitation in our system: we cannot inspect .
+	// . is assumed to be a directory
+	// In order to be able to inspect the root directory, we have to have each
+	// PutFile send a concurrent request to create an entry for ".", which is
+	// a price we are not willing to pay.
+	_, err = client.InspectFile(repo, commit1.ID, "", "", nil)
+	require.YesError(t, err)
+}
+
+func TestDeleteDir(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	// Commit 1: Add two files into the same directory; delete the directory
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	_, err = client.PutFile(repo, commit1.ID, "dir/foo", strings.NewReader("foo1"))
+	require.NoError(t, err)
+
+	_, err = client.PutFile(repo, commit1.ID, "dir/bar", strings.NewReader("bar1"))
+	require.NoError(t, err)
+
+	// Since the directory did not exist before this commit, this should error
+	require.YesError(t, client.DeleteFile(repo, commit1.ID, "dir", false, ""))
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// Should see one directory
+	fileInfos, err := client.ListFile(repo, commit1.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+
+	// dir should not exist
+	_, err = client.InspectFile(repo, commit1.ID, "dir", "", nil)
+	require.NoError(t, err)
+
+	// Commit 2: Delete the directory and add the same two files
+	// The two files should reflect the new content
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir", false, ""))
+
+	_, err = client.PutFile(repo, commit2.ID, "dir/foo", strings.NewReader("foo2"))
+	require.NoError(t, err)
+
+	_, err = client.PutFile(repo, commit2.ID, "dir/bar", strings.NewReader("bar2"))
+	require.NoError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	// Should see two files
+	fileInfos, err = client.ListFile(repo, commit2.ID, "dir", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo2", buffer.String())
+
+	var buffer2 bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/bar", 0, 0, "", nil, &buffer2))
+	require.Equal(t, "bar2", buffer2.String())
+
+	// Commit 3: delete the directory
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+
+	require.NoError(t, client.DeleteFile(repo, commit3.ID, "dir", false, ""))
+
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+
+	// Should see zero files
+	fileInfos, err = client.ListFile(repo, commit3.ID, "", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fileInfos))
+
+	// TODO: test deleting "."
+}
+
+func TestListCommit(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	fileContent1 := "foo\n"
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader(fileContent1))
+	require.NoError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	commitInfos, err := client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfos))
+
+	// test the block behaviour
+	ch := make(chan bool)
+	go func() {
+		_, err = client.ListCommit([]string{repo}, []string{commit.ID}, pclient.CommitTypeNone, true, false, nil)
+		close(ch)
+	}()
+
+	time.Sleep(time.Second)
+	select {
+	case <-ch:
+		t.Fatal("ListCommit should not have returned")
+	default:
+	}
+
+	commit2, err := client.StartCommit(repo, commit.ID, "")
+	require.NoError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	time.Sleep(time.Second)
+	select {
+	case <-ch:
+	default:
+		t.Fatal("ListCommit should have returned")
+	}
+
+	// test that cancelled commits are not listed
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+
+	require.NoError(t, client.CancelCommit(repo, commit3.ID))
+	commitInfos, err = client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+	commitInfos, err = client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, true, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(commitInfos))
+	require.Equal(t, commit3, commitInfos[0].Commit)
+}
+
+func TestOffsetRead(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	repo := "TestOffsetRead"
+	require.NoError(t, client.CreateRepo(repo))
+	_, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	fileData := "foo\n"
+	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, "master"))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, "master", "foo", int64(len(fileData)*2)+1, 0, "", nil, &buffer))
+	require.Equal(t, "", buffer.String())
+}
+
+func TestUnsafeOperations(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	repo := "TestUnsafeOperations"
+	require.NoError(t, client.CreateRepo(repo))
+
+	_, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+
+	fileData := "foo"
+	_, err = client.PutFile(repo, "master", "foo", strings.NewReader(fileData))
+	require.NoError(t, err)
+
+	// A safe read should not be able to see the file
+	var buffer bytes.Buffer
+	require.YesError(t, client.GetFile(repo, "master", "foo", 0, 0, "", nil, &buffer))
+
+	// An unsafe read should
+	var buffer2 bytes.Buffer
+	require.NoError(t, client.GetFileUnsafe(repo, "master", "foo", 0, 0, "", nil, "", &buffer2))
+	require.Equal(t, "foo", buffer2.String())
+
+	fileInfo, err := client.InspectFile(repo, "master", "foo", "", nil)
+	require.YesError(t, err)
+
+	fileInfo, err = client.InspectFileUnsafe(repo, "master", "foo", "", nil, "")
+	require.NoError(t, err)
+	require.Equal(t, 3, int(fileInfo.SizeBytes))
+
+	fileInfos, err := client.ListFile(repo, "master", "", "", nil, true)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(fileInfos))
+
+	fileInfos, err = client.ListFileUnsafe(repo, "master", "", "", nil, true, "")
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+
+	require.NoError(t, client.FinishCommit(repo, "master"))
+}
+
+// FinishCommit should block until the parent has been finished
+func TestFinishCommit(t *testing.T) {
+	t.Parallel()
+
+	client, _ := getClientAndServer(t)
+	repo := "TestFinishCommit"
+
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+
+	ch := make(chan bool)
+	go func() {
+		require.NoError(t, client.FinishCommit(repo, commit2.ID))
+		close(ch)
+	}()
+
+	time.Sleep(time.Second * 2)
+	select {
+	case <-ch:
+		t.Fatalf("should block, since the parent commit has not been finished")
+	default:
+	}
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	time.Sleep(time.Second * 2)
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("should not block, since the parent commit has been finished")
+	}
+}
+
+func TestFinishCommitRace(t *testing.T) {
+	t.Parallel()
+
+	client, _ := getClientAndServer(t)
+	repo := "TestFinishCommitRace"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+
+	// both finishes block on the parent, so they're guaranteed to overlap
+	errCh := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errCh <- client.FinishCommit(repo, commit2.ID)
+		}()
+	}
+	time.Sleep(time.Second)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	var errs []error
+	for i := 0; i < 2; i++ {
+		if err := <-errCh; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	require.Equal(t, 1, len(errs))
+	require.Matches(t, "already being finished", errs[0].Error())
+
+	// finishing again after the fact also fails
+	err = client.FinishCommit(repo, commit2.ID)
+	require.YesError(t, err)
+	require.Matches(t, "already been finished", err.Error())
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+}
+
+func TestStartCommitWithNonexistentParent(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	repo := "TestStartCommitWithNonexistentParent"
+	require.NoError(t, client.CreateRepo(repo))
+	_, err := client.StartCommit(repo, "nonexistent", "")
+	require.YesError(t, err)
+}
+
+// If a commit's parent has been cancelled, the commit should be cancelled too
+func TestFinishCommitParentCancelled(t *testing.T) {
+	t.Parallel()
+
+	client, _ := getClientAndServer(t)
+	repo := "TestFinishCommitParentCancelled"
+
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+
+	ch := make(chan bool)
+	go func() {
+		require.NoError(t, client.FinishCommit(repo, commit2.ID))
+		close(ch)
+	}()
+
+	require.NoError(t, client.CancelCommit(repo, commit1.ID))
+
+	time.Sleep(time.Second * 2)
+	select {
+	case <-ch:
+	default:
+		t.Fatalf("should not block, since the parent commit has been finished")
+	}
+
+	commit2Info, err := client.InspectCommit(repo, commit2.ID)
+	require.True(t, commit2Info.Cancelled)
+
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	commit3Info, err := client.InspectCommit(repo, commit3.ID)
+	require.True(t, commit3Info.Cancelled)
+}
+
+func TestHandleRace(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	writer1, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle1")
+	require.NoError(t, err)
+	_, err = writer1.Write([]byte("foo"))
+	require.NoError(t, err)
+	writer2, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle2")
+	require.NoError(t, err)
+	_, err = writer2.Write([]byte("bar"))
+	require.NoError(t, err)
+	require.NoError(t, writer2.Close())
+	_, err = writer1.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, writer1.Close())
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", nil, &buffer))
+	require.EqualOneOf(t, []interface{}{"foofoobar", "barfoofoo"}, buffer.String())
+}
+
+func Test0Modulus(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	zeroModulusShard := &pfsclient.Shard{}
+	fileInfo, err := client.InspectFile(repo, commit.ID, "foo", "", zeroModulusShard)
+	require.NoError(t, err)
+	require.Equal(t, uint64(4), fileInfo.SizeBytes)
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "foo", 0, 0, "", zeroModulusShard, &buffer))
+	require.Equal(t, 4, buffer.Len())
+	fileInfos, err := client.ListFile(repo, commit.ID, "", "", zeroModulusShard, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(fileInfos))
+	require.Equal(t, uint64(4), fileInfos[0].SizeBytes)
+}
+
+func TestProvenance(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	require.NoError(t, client.CreateRepo("A"))
+	_, err := client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
+		Repo:       pclient.NewRepo("B"),
+		Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
+	})
+	require.NoError(t, err)
+	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
+		Repo:       pclient.NewRepo("C"),
+		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
+	})
+	require.NoError(t, err)
+	repoInfo, err := client.InspectRepo("B")
+	require.NoError(t, err)
+	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("A")}, repoInfo.Provenance)
+	repoInfo, err = client.InspectRepo("C")
+	require.NoError(t, err)
+	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("B"), pclient.NewRepo("A")}, repoInfo.Provenance)
+	ACommit, err := client.StartCommit("A", "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("A", ACommit.ID))
+	BCommit, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo("B"),
+			Provenance: []*pfsclient.Commit{ACommit},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("B", BCommit.ID))
+	commitInfo, err := client.InspectCommit("B", BCommit.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*pfsclient.Commit{ACommit}, commitInfo.Provenance)
+	CCommit, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo("C"),
+			Provenance: []*pfsclient.Commit{BCommit},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("C", CCommit.ID))
+	commitInfo, err = client.InspectCommit("C", CCommit.ID)
+	require.NoError(t, err)
+	require.Equal(t, []*pfsclient.Commit{BCommit, ACommit}, commitInfo.Provenance)
+
+	// Test that we prevent provenant commits that aren't from provenant repos.
+	_, err = client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo("C"),
+			Provenance: []*pfsclient.Commit{ACommit},
+		},
+	)
+	require.YesError(t, err)
+
+	// Test ListRepo using provenance filtering
+	repoInfos, err := client.PfsAPIClient.ListRepo(
+		context.Background(),
+		&pfsclient.ListRepoRequest{
+			Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
+		},
+	)
+	require.NoError(t, err)
+	var repos []*pfsclient.Repo
+	for _, repoInfo := range repoInfos.RepoInfo {
+		repos = append(repos, repoInfo.Repo)
+	}
+	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("C")}, repos)
+
+	// Test ListRepo using provenance filtering
+	repoInfos, err = client.PfsAPIClient.ListRepo(
+		context.Background(),
+		&pfsclient.ListRepoRequest{
+			Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
+		},
+	)
+	require.NoError(t, err)
+	repos = nil
+	for _, repoInfo := range repoInfos.RepoInfo {
+		repos = append(repos, repoInfo.Repo)
+	}
+	require.EqualOneOf(t, []interface{}{
+		[]*pfsclient.Repo{pclient.NewRepo("B"), pclient.NewRepo("C")},
+		[]*pfsclient.Repo{pclient.NewRepo("C"), pclient.NewRepo("B")},
+	}, repos)
+
+	// Test ListCommit using provenance filtering
+	commitInfos, err := client.PfsAPIClient.ListCommit(
+		context.Background(),
+		&pfsclient.ListCommitRequest{
+			Repo:       []*pfsclient.Repo{pclient.NewRepo("C")},
+			Provenance: []*pfsclient.Commit{ACommit},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfos.CommitInfo))
+	require.Equal(t, CCommit, commitInfos.CommitInfo[0].Commit)
+
+	// Negative test ListCommit using provenance filtering
+	commitInfos, err = client.PfsAPIClient.ListCommit(
+		context.Background(),
+		&pfsclient.ListCommitRequest{
+			Repo:       []*pfsclient.Repo{pclient.NewRepo("A")},
+			Provenance: []*pfsclient.Commit{BCommit},
+		},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 0, len(commitInfos.CommitInfo))
+
+	// Test Blocking ListCommit using provenance filtering
+	ACommit2, err := client.StartCommit("A", "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("A", ACommit2.ID))
+	commitInfosCh := make(chan *pfsclient.CommitInfos)
+	go func() {
+		commitInfos, err := client.PfsAPIClient.ListCommit(
+			context.Background(),
+			&pfsclient.ListCommitRequest{
+				Repo:       []*pfsclient.Repo{pclient.NewRepo("B")},
+				Provenance: []*pfsclient.Commit{ACommit2},
+				CommitType: pfsclient.CommitType_COMMIT_TYPE_READ,
+				Block:      true,
+			},
+		)
+		require.NoError(t, err)
+		commitInfosCh <- commitInfos
+	}()
+	BCommit2, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo("B"),
+			Provenance: []*pfsclient.Commit{ACommit2},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("B", BCommit2.ID))
+	select {
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for commit")
+	case commitInfos := <-commitInfosCh:
+		require.Equal(t, 1, len(commitInfos.CommitInfo))
+		require.Equal(t, BCommit2, commitInfos.CommitInfo[0].Commit)
+	}
+	go func() {
+		commitInfos, err := client.PfsAPIClient.ListCommit(
+			context.Background(),
+			&pfsclient.ListCommitRequest{
+				Repo:       []*pfsclient.Repo{pclient.NewRepo("C")},
+				Provenance: []*pfsclient.Commit{ACommit2},
+				CommitType: pfsclient.CommitType_COMMIT_TYPE_READ,
+				Block:      true,
+			},
+		)
+		require.NoError(t, err)
+		commitInfosCh <- commitInfos
+	}()
+	CCommit2, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo("C"),
+			Provenance: []*pfsclient.Commit{BCommit2},
+		},
+	)
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("C", CCommit2.ID))
+	select {
+	case <-time.After(5 * time.Second):
+		t.Errorf("timeout waiting for commit")
+	case commitInfos := <-commitInfosCh:
+		require.Equal(t, 1, len(commitInfos.CommitInfo))
+		require.Equal(t, CCommit2, commitInfos.CommitInfo[0].Commit)
+	}
+}
+
+func TestFlush(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+	require.NoError(t, client.CreateRepo("A"))
+	_, err := client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
+		Repo:       pclient.NewRepo("B"),
+		Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
+	})
+	require.NoError(t, err)
+	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
+		Repo:       pclient.NewRepo("C"),
+		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
+	})
+	require.NoError(t, err)
+	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
+		Repo:       pclient.NewRepo("D"),
+		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
+	})
+	require.NoError(t, err)
+	ACommit, err := client.StartCommit("A", "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("A", ACommit.ID))
+
+	// do the other commits in a goro so we can block for them
+	go func() {
+		BCommit, err := client.PfsAPIClient.StartCommit(
+			context.Background(),
+			&pfsclient.StartCommitRequest{
+				Repo:       pclient.NewRepo("B"),
+				Provenance: []*pfsclient.Commit{ACommit},
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit("B", BCommit.ID))
+		CCommit, err := client.PfsAPIClient.StartCommit(
+			context.Background(),
+			&pfsclient.StartCommitRequest{
+				Repo:       pclient.NewRepo("C"),
+				Provenance: []*pfsclient.Commit{BCommit},
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit("C", CCommit.ID))
+		DCommit, err := client.PfsAPIClient.StartCommit(
+			context.Background(),
+			&pfsclient.StartCommitRequest{
+				Repo:       pclient.NewRepo("D"),
+				Provenance: []*pfsclient.Commit{BCommit},
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit("D", DCommit.ID))
+	}()
+
+	// Flush ACommit
+	commitInfos, err := client.FlushCommit([]*pfsclient.Commit{pclient.NewCommit("A", ACommit.ID)}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(commitInfos))
+	commitInfos, err = client.FlushCommit(
+		[]*pfsclient.Commit{pclient.NewCommit("A", ACommit.ID)},
+		[]*pfsclient.Repo{pclient.NewRepo("C")},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(commitInfos))
+
+	// Now test what happens if one of the commits gets cancelled
+	ACommit2, err := client.StartCommit("A", "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("A", ACommit2.ID))
+
+	// do the other commits in a goro so we can block for them
+	go func() {
+		BCommit2, err := client.PfsAPIClient.StartCommit(
+			context.Background(),
+			&pfsclient.StartCommitRequest{
+				Repo:       pclient.NewRepo("B"),
+				Provenance: []*pfsclient.Commit{ACommit2},
+			},
+		)
+		require.NoError(t, err)
+		require.NoError(t, client.CancelCommit("B", BCommit2.ID))
+	}()
+
+	// Flush ACommit2
+	_, err = client.FlushCommit(
+		[]*pfsclient.Commit{pclient.NewCommit("A", ACommit2.ID)},
+		[]*pfsclient.Repo{pclient.NewRepo("C")},
+	)
+	require.YesError(t, err)
+}
+
+func TestShardingInTopLevel(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	folders := 4
+	filesPerFolder := 10
+
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	for i := 0; i < folders; i++ {
+		for j := 0; j < filesPerFolder; j++ {
+			_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir%d/file%d", i, j), strings.NewReader("foo\n"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	totalFiles := 0
+	for i := 0; i < folders; i++ {
+		shard := &pfsclient.Shard{
+			FileNumber:  uint64(i),
+			FileModulus: uint64(folders),
+		}
+		for j := 0; j < folders; j++ {
+			// You should either see all files in the folder (if the folder
+			// matches the shard), or no files in the folder (if the folder
+			// does not match the shard).
+			fileInfos, err := client.ListFile(repo, commit.ID, fmt.Sprintf("dir%d", j), "", shard, false)
+			require.NoError(t, err)
+			require.EqualOneOf(t, []interface{}{filesPerFolder, 0}, len(fileInfos))
+			totalFiles += len(fileInfos)
+		}
+	}
+	require.Equal(t, folders*filesPerFolder, totalFiles)
+}
+
+func TestCreate(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	w, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	_, err = client.InspectFileUnsafe(repo, commit.ID, "foo", "", nil, "handle")
+	require.NoError(t, err)
+}
+
+func TestGetFileInvalidCommit(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "file", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+	err = client.GetFile(repo, "aninvalidcommitid", "file", 0, 0, "", nil, &buffer)
+	require.YesError(t, err)
+
+	require.Equal(t, fmt.Sprintf("Commit %v not found in repo %v", "aninvalidcommitid", repo), err.Error())
+}
+
+func TestScrubbedErrorStrings(t *testing.T) {
+
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	err := client.CreateRepo("foo||@&#$TYX")
+	require.Equal(t, "Repo name (foo||@&#$TYX) invalid. Only alphanumeric and underscore characters allowed.", err.Error())
+
+	_, err = client.StartCommit("zzzzz", "", "")
+	require.Equal(t, "Repo zzzzz not found", err.Error())
+
+	_, err = client.ListCommit([]string{"zzzzz"}, []string{}, pclient.CommitTypeNone, false, true, []*pfsclient.Commit{})
+	require.Equal(t, "Repo zzzzz not found", err.Error())
+
+	_, err = client.InspectRepo("bogusrepo")
+	require.Equal(t, "Repo bogusrepo not found", err.Error())
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	_, err = client.PutFile("sdf", commit1.ID, "file", strings.NewReader("foo\n"))
+	require.Equal(t, "Repo sdf not found", err.Error())
+
+	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+
+	err = client.FinishCommit("zzzzzz", commit1.ID)
+	require.Equal(t, "Repo zzzzzz not found", err.Error())
+
+	err = client.FinishCommit(repo, "bogus")
+	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+
+	err = client.CancelCommit(repo, "bogus")
+	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+
+	_, err = client.InspectCommit(repo, "bogus")
+	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+
+	_, err = client.ListBranch("blah")
+	require.Equal(t, "Repo blah not found", err.Error())
+
+	_, err = client.InspectFile(repo, commit1.ID, "file", "", nil)
+	require.Equal(t, fmt.Sprintf("File file not found in repo %v at commit %v", repo, commit1.ID), err.Error())
+
+	err = client.MakeDirectory(repo, "sdf", "foo")
+	require.Equal(t, "Commit sdf not found in repo test", err.Error())
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "file", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+	err = client.GetFile(repo, "aninvalidcommitid", "file", 0, 0, "", nil, &buffer)
+	require.YesError(t, err)
+
+	require.Equal(t, fmt.Sprintf("Commit %v not found in repo %v", "aninvalidcommitid", repo), err.Error())
+}
+
+func TestATonOfPuts(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	rawMessage := `{
+		"level":"debug",
+		"message":{
+			"thing":"foo"
+		},
+		"timing":[1,3,34,6,7]
+	}`
+	numObjs := 5000
+	var expectedOutput []byte
+	for i := 0; i < numObjs; i++ {
+		_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(rawMessage))
+		require.NoError(t, err)
+		expectedOutput = append(expectedOutput, []byte(rawMessage)...)
+	}
+
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, string(expectedOutput), buffer.String())
+}
+
+func TestPutFileWithJSONDelimiter(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	rawMessage := `{
+		"level":"debug",
+		"timestamp":"345",
+		"message":{
+			"thing":"foo"
+		},
+		"timing":[1,3,34,6,7]
+	}`
+
+	var expectedOutput []byte
+	for !(len(expectedOutput) > 9*1024*1024) {
+		expectedOutput = append(expectedOutput, []byte(rawMessage)...)
+	}
+	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_JSON, bytes.NewReader(expectedOutput))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// Make sure all the content is there
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, len(expectedOutput), buffer.Len())
+	require.Equal(t, string(expectedOutput), buffer.String())
+
+	// Now verify that each block contains only valid JSON objects
+	bigModulus := 10 // Make it big to make it less likely that I return both blocks together
+	for b := 0; b < bigModulus; b++ {
+		blockFilter := &pfsclient.Shard{
+			BlockNumber:  uint64(b),
+			BlockModulus: uint64(bigModulus),
+		}
+
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", blockFilter, &buffer))
+
+		// If any single block returns content of size equal to the total, we
+		// got a block collision and we're not testing anything
+		require.NotEqual(t, buffer.Len(), len(expectedOutput))
+
+		var value json.RawMessage
+		decoder := json.NewDecoder(&buffer)
+		for {
+			err = decoder.Decode(&value)
+			if err != nil {
+				if err == io.EOF {
+					break
+				} else {
+					require.NoError(t, err)
+				}
+			}
+			require.Equal(t, rawMessage, string(value))
+		}
+	}
+}
+
+func TestPutFileWithNoDelimiter(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	rawMessage := "Some\ncontent\nthat\nshouldnt\nbe\nline\ndelimited.\n"
+
+	// Write a big blob that would normally not fit in a block
+	var expectedOutputA []byte
+	for !(len(expectedOutputA) > 9*1024*1024) {
+		expectedOutputA = append(expectedOutputA, []byte(rawMessage)...)
+	}
+	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_NONE, bytes.NewReader(expectedOutputA))
+	require.NoError(t, err)
+
+	// Write another big block
+	var expectedOutputB []byte
+	for !(len(expectedOutputB) > 18*1024*1024) {
+		expectedOutputB = append(expectedOutputB, []byte(rawMessage)...)
+	}
+	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_NONE, bytes.NewReader(expectedOutputB))
+	require.NoError(t, err)
+
+	// Finish the commit so I can read the data
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	// Make sure all the content is there
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, len(expectedOutputA)+len(expectedOutputB), buffer.Len())
+	require.Equal(t, string(append(expectedOutputA, expectedOutputB...)), buffer.String())
+
+	// Now verify that each block only contains objects of the size we've written
+	bigModulus := 10 // Make it big to make it less likely that I return both blocks together
+	blockLengths := []interface{}{len(expectedOutputA), len(expectedOutputB)}
+	for b := 0; b < bigModulus; b++ {
+		blockFilter := &pfsclient.Shard{
+			BlockNumber:  uint64(b),
+			BlockModulus: uint64(bigModulus),
+		}
+
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", blockFilter, &buffer))
+
+		// If any single block returns content of size equal to the total, we
+		// got a block collision and we're not testing anything
+		require.NotEqual(t, buffer.Len(), len(expectedOutputA)+len(expectedOutputB))
+		if buffer.Len() == 0 {
+			continue
+		}
+		require.EqualOneOf(t, blockLengths, buffer.Len())
+	}
+
+}
+
+func TestListShard(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	shardInfos, err := client.ListShard()
+	require.NoError(t, err)
+	require.Equal(t, shards, len(shardInfos))
+	addresses := make(map[string]bool)
+	for i, shardInfo := range shardInfos {
+		require.Equal(t, uint64(i), shardInfo.Shard)
+		require.Equal(t, pfsclient.ShardState_SHARD_STATE_READY, shardInfo.State)
+		addresses[shardInfo.Address] = true
+	}
+	// the local sharder spreads the shards over all of the servers
+	require.Equal(t, servers, len(addresses))
+}
+
+func TestDumpShard(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	for _, repo := range []string{"test", "test2"} {
+		require.NoError(t, client.CreateRepo(repo))
+		commit1, err := client.StartCommit(repo, "", "")
+		require.NoError(t, err)
+		_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+		require.NoError(t, err)
+		_, err = client.PutFile(repo, commit1.ID, "dir/bar", strings.NewReader("bar\n"))
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommit(repo, commit1.ID))
+		commit2, err := client.StartCommit(repo, commit1.ID, "")
+		require.NoError(t, err)
+		_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader("foo\n"))
+		require.NoError(t, err)
+		require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir/bar", false, ""))
+		require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	}
+
+	// dump every shard from the server that's responsible for it
+	shardInfos, err := client.ListShard()
+	require.NoError(t, err)
+	dump := make(map[uint64][]*pfsclient.DiffInfo)
+	for _, shardInfo := range shardInfos {
+		clientConn, err := grpc.Dial(shardInfo.Address, grpc.WithInsecure())
+		require.NoError(t, err)
+		dumpShardClient, err := pfsclient.NewInternalAPIClient(clientConn).DumpShard(
+			context.Background(),
+			&pfsclient.DumpShardRequest{Shard: shardInfo.Shard},
+		)
+		require.NoError(t, err)
+		seen := make(map[string]bool)
+		for {
+			diffInfo, err := dumpShardClient.Recv()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			require.Equal(t, shardInfo.Shard, diffInfo.Diff.Shard)
+			if diffInfo.ParentCommit != nil {
+				require.True(t, seen[path.Join(diffInfo.ParentCommit.Repo.Name, diffInfo.ParentCommit.ID)])
+			}
+			seen[path.Join(diffInfo.Diff.Commit.Repo.Name, diffInfo.Diff.Commit.ID)] = true
+			dump[shardInfo.Shard] = append(dump[shardInfo.Shard], diffInfo)
+		}
+		// 2 repos with a repo diff and 2 commits each
+		require.Equal(t, 6, len(dump[shardInfo.Shard]))
+
+		// a single commit can be dumped too
+		commit := dump[shardInfo.Shard][2].Diff.Commit
+		dumpShardClient, err = pfsclient.NewInternalAPIClient(clientConn).DumpShard(
+			context.Background(),
+			&pfsclient.DumpShardRequest{Shard: shardInfo.Shard, Commit: commit},
+		)
+		require.NoError(t, err)
+		diffInfo, err := dumpShardClient.Recv()
+		require.NoError(t, err)
+		require.True(t, proto.Equal(dump[shardInfo.Shard][2], diffInfo))
+		_, err = dumpShardClient.Recv()
+		require.Equal(t, io.EOF, err)
+		require.NoError(t, clientConn.Close())
+	}
+
+	// writing the dump to an empty block server and loading it reconstructs
+	// the same state
+	blockAPIServer, err := NewLocalBlockAPIServer(uniqueString("/tmp/pach_test/run"))
+	require.NoError(t, err)
+	for _, diffInfos := range dump {
+		for _, diffInfo := range diffInfos {
+			_, err := blockAPIServer.CreateDiff(context.Background(), diffInfo)
+			require.NoError(t, err)
+		}
+	}
+	blockPort := atomic.AddInt32(&port, 1)
+	ready := make(chan bool)
+	go func() {
+		require.NoError(t, protoserver.Serve(
+			func(s *grpc.Server) {
+				pfsclient.RegisterBlockAPIServer(s, blockAPIServer)
+				close(ready)
+			},
+			protoserver.ServeOptions{Version: version.Version},
+			protoserver.ServeEnv{GRPCPort: uint16(blockPort)},
+		))
+	}()
+	<-ready
+	driver, err := drive.NewDriver(fmt.Sprintf("localhost:%d", blockPort))
+	require.NoError(t, err)
+	for shard, diffInfos := range dump {
+		require.NoError(t, driver.AddShard(shard))
+		reconstructed, err := driver.DumpShard(shard, nil)
+		require.NoError(t, err)
+		require.Equal(t, len(diffInfos), len(reconstructed))
+		expected := make(map[string]*pfsclient.DiffInfo)
+		for _, diffInfo := range diffInfos {
+			expected[path.Join(diffInfo.Diff.Commit.Repo.Name, diffInfo.Diff.Commit.ID)] = diffInfo
+		}
+		for _, diffInfo := range reconstructed {
+			require.True(t, proto.Equal(expected[path.Join(diffInfo.Diff.Commit.Repo.Name, diffInfo.Diff.Commit.ID)], diffInfo))
+		}
+	}
+}
+
+func TestPutFileMulti(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+
+	callers := 8
+	values := 50
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var batch [][]byte
+			for j := 0; j < values; j++ {
+				batch = append(batch, []byte(fmt.Sprintf("%d %d\n", i, j)))
+			}
+			require.NoError(t, client.PutFileMulti(repo, commit.ID, "dir/log", batch))
+		}()
+	}
+	wg.Wait()
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "dir/log", 0, 0, "", nil, &buffer))
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	require.Equal(t, callers*values, len(lines))
+	// each caller's batch is contiguous and in order
+	seen := make(map[int]bool)
+	for k := 0; k < len(lines); k += values {
+		var caller int
+		_, err := fmt.Sscanf(lines[k], "%d", &caller)
+		require.NoError(t, err)
+		require.False(t, seen[caller])
+		seen[caller] = true
+		for j := 0; j < values; j++ {
+			require.Equal(t, fmt.Sprintf("%d %d", caller, j), lines[k+j])
+		}
+	}
+	require.Equal(t, callers, len(seen))
+}
+
+func TestShardStats(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	totals := func() (sizeBytes uint64, blocks uint64, openCommits uint64) {
+		shardStats, err := client.ShardStats()
+		require.NoError(t, err)
+		require.Equal(t, shards, len(shardStats))
+		for i, shardStat := range shardStats {
+			require.Equal(t, uint64(i), shardStat.Shard)
+			require.True(t, shardStat.Address != "")
+			sizeBytes += shardStat.SizeBytes
+			blocks += shardStat.Blocks
+			openCommits += shardStat.OpenCommits
+		}
+		return sizeBytes, blocks, openCommits
+	}
+	sizeBytes, blocks, openCommits := totals()
+	require.Equal(t, uint64(0), sizeBytes)
+	require.Equal(t, uint64(0), blocks)
+	require.Equal(t, uint64(0), openCommits)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, _, openCommits = totals()
+	// the commit is open on every shard
+	require.Equal(t, uint64(shards), openCommits)
+
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	sizeBytes, blocks, _ = totals()
+	require.Equal(t, uint64(8), sizeBytes)
+	require.Equal(t, uint64(2), blocks)
+
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	sizeBytes, _, openCommits = totals()
+	require.Equal(t, uint64(8), sizeBytes)
+	require.Equal(t, uint64(0), openCommits)
+}
+
+func TestGetFileArchive(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	var paths []string
+	for i := 0; i < 20; i++ {
+		filePath := fmt.Sprintf("dir%d/file%d", i%3, i)
+		paths = append(paths, filePath)
+		_, err = client.PutFile(repo, commit.ID, filePath, strings.NewReader(strings.Repeat(fmt.Sprintf("%d\n", i), i)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFileArchive(repo, commit.ID, paths, &buffer))
+	zipReader, err := zip.NewReader(bytes.NewReader(buffer.Bytes()), int64(buffer.Len()))
+	require.NoError(t, err)
+	require.Equal(t, len(paths), len(zipReader.File))
+	seen := make(map[string]bool)
+	for _, zipFile := range zipReader.File {
+		seen[zipFile.Name] = true
+		reader, err := zipFile.Open()
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		var expected bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit.ID, zipFile.Name, 0, 0, "", nil, &expected))
+		require.Equal(t, expected.String(), string(content))
+	}
+	for _, filePath := range paths {
+		require.True(t, seen[filePath])
+	}
+
+	// a path can only appear once
+	require.YesError(t, client.GetFileArchive(repo, commit.ID, []string{"dir0/file0", "dir0/file0"}, &buffer))
+	// and paths can't have a leading slash
+	require.YesError(t, client.GetFileArchive(repo, commit.ID, []string{"/dir0/file0"}, &buffer))
+}
+
+func TestCreateRepoIfNotExists(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	require.NoError(t, client.CreateRepo("prov1"))
+	require.NoError(t, client.CreateRepo("prov2"))
+	require.NoError(t, client.CreateRepoIfNotExists("repo", []string{"prov1", "prov2"}))
+	// creating it again with the same provenance is a no op
+	require.NoError(t, client.CreateRepoIfNotExists("repo", []string{"prov2", "prov1"}))
+	repoInfos, err := client.ListRepo(nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, len(repoInfos))
+	// without the flag it's still an error
+	require.YesError(t, client.CreateRepo("repo"))
+
+	// different provenance is a conflict
+	err = client.CreateRepoIfNotExists("repo", []string{"prov1"})
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "different provenance"))
+	err = client.CreateRepoIfNotExists("prov1", []string{"prov2"})
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "different provenance"))
+	repoInfo, err := client.InspectRepo("repo")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(repoInfo.Provenance))
+}
+
+func TestReadShard(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	expected := make(map[string]string)
+	for i := 0; i < 50; i++ {
+		filePath := fmt.Sprintf("dir%d/file%d", i%5, i)
+		expected[filePath] = strings.Repeat(fmt.Sprintf("%d\n", i), i+1)
+		_, err = client.PutFile(repo, commit.ID, filePath, strings.NewReader(expected[filePath]))
+		require.NoError(t, err)
+	}
+	// shards can't be read until the commit is finished
+	require.YesError(t, client.ReadShard(repo, commit.ID, 0, func(*pfsclient.ExportRecord) error { return nil }))
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	actual := make(map[string]string)
+	hasher := pfsserver.NewHasher(shards, 1)
+	for shard := uint64(0); shard < shards; shard++ {
+		var current string
+		require.NoError(t, client.ReadShard(repo, commit.ID, shard, func(record *pfsclient.ExportRecord) error {
+			if record.FileInfo != nil {
+				require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, record.FileInfo.FileType)
+				require.Equal(t, shard, hasher.HashFile(record.FileInfo.File))
+				current = record.FileInfo.File.Path
+				_, ok := actual[current]
+				require.False(t, ok)
+				actual[current] = ""
+			}
+			actual[current] += string(record.Value)
+			return nil
+		}))
+	}
+	require.Equal(t, expected, actual)
+}
+
+func TestCaseInsensitiveRepoNames(t *testing.T) {
+	t.Parallel()
+	// by default repo names are case sensitive
+	client, _ := getClientAndServer(t)
+	require.NoError(t, client.CreateRepo("foo"))
+	require.NoError(t, client.CreateRepo("Foo"))
+	repoInfos, err := client.ListRepo(nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(repoInfos))
+
+	client, _ = getClientAndServerWithOptions(t, drive.Options{CaseInsensitiveRepoNames: true})
+	require.NoError(t, client.CreateRepo("foo"))
+	err = client.CreateRepo("Foo")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "collides"))
+	require.YesError(t, client.CreateRepo("FOO"))
+	require.NoError(t, client.CreateRepo("bar"))
+	repoInfos, err = client.ListRepo(nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(repoInfos))
+}
+
+func TestPutFileIntermediateDirs(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	checkDir := func(commitID string, dir string, expected ...string) {
+		fileInfos, err := client.ListFile(repo, commitID, dir, "", nil, false)
+		require.NoError(t, err)
+		var paths []string
+		for _, fileInfo := range fileInfos {
+			paths = append(paths, fileInfo.File.Path)
+		}
+		require.Equal(t, expected, paths)
+	}
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "a/b/c/d.txt", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	// the directory a/b already exists, putting beneath it doesn't add a
+	// second entry
+	_, err = client.PutFile(repo, commit1.ID, "a/b/e.txt", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	for _, dir := range []string{"a", "a/b", "a/b/c"} {
+		fileInfo, err := client.InspectFile(repo, commit1.ID, dir, "", nil)
+		require.NoError(t, err)
+		require.Equal(t, pfsclient.FileType_FILE_TYPE_DIR, fileInfo.FileType)
+	}
+	checkDir(commit1.ID, "", "a")
+	checkDir(commit1.ID, "a", "a/b")
+	checkDir(commit1.ID, "a/b", "a/b/c", "a/b/e.txt")
+	checkDir(commit1.ID, "a/b/c", "a/b/c/d.txt")
+
+	// directories that exist in the parent commit aren't duplicated either
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "a/b/c/f.txt", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	checkDir(commit2.ID, "", "a")
+	checkDir(commit2.ID, "a", "a/b")
+	checkDir(commit2.ID, "a/b", "a/b/c", "a/b/e.txt")
+	checkDir(commit2.ID, "a/b/c", "a/b/c/d.txt", "a/b/c/f.txt")
+}
+
+func TestListFileStream(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir/file%d", i), strings.NewReader(strings.Repeat("foo\n", i)))
+		require.NoError(t, err)
+		_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir/sub%d/file", i%5), strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	for _, recurse := range []bool{false, true} {
+		fileInfos, err := client.ListFile(repo, commit.ID, "dir", "", nil, recurse)
+		require.NoError(t, err)
+		expected := make(map[string]*pfsclient.FileInfo)
+		for _, fileInfo := range fileInfos {
+			expected[fileInfo.File.Path] = fileInfo
+		}
+		actual := make(map[string]*pfsclient.FileInfo)
+		require.NoError(t, client.ListFileStream(repo, commit.ID, "dir", "", nil, recurse, func(fileInfo *pfsclient.FileInfo) error {
+			_, ok := actual[fileInfo.File.Path]
+			require.False(t, ok)
+			actual[fileInfo.File.Path] = fileInfo
+			return nil
+		}))
+		require.Equal(t, len(expected), len(actual))
+		for filePath, expectedInfo := range expected {
+			actualInfo, ok := actual[filePath]
+			require.True(t, ok)
+			require.Equal(t, expectedInfo.FileType, actualInfo.FileType)
+			require.Equal(t, expectedInfo.SizeBytes, actualInfo.SizeBytes)
+		}
+	}
+}
+
+func TestCommitDescription(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommitWithDescription(repo, commit1.ID, "add foo"))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	checkDescriptions := func() {
+		commitInfo, err := client.InspectCommit(repo, commit1.ID)
+		require.NoError(t, err)
+		require.Equal(t, "add foo", commitInfo.Description)
+		commitInfo, err = client.InspectCommit(repo, commit2.ID)
+		require.NoError(t, err)
+		require.Equal(t, "", commitInfo.Description)
+		commitInfos, err := client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+		require.NoError(t, err)
+		require.Equal(t, 2, len(commitInfos))
+		for _, commitInfo := range commitInfos {
+			if commitInfo.Commit.ID == commit1.ID {
+				require.Equal(t, "add foo", commitInfo.Description)
+			} else {
+				require.Equal(t, "", commitInfo.Description)
+			}
+		}
+	}
+	checkDescriptions()
+	// descriptions are persisted with the diffs
+	restartServer(server, t)
+	checkDescriptions()
+}
+
+func TestCommitAnnotations(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	finish := func(parentID string, annotations map[string]string) *pfsclient.Commit {
+		commit, err := client.StartCommit(repo, parentID, "")
+		require.NoError(t, err)
+		require.NoError(t, client.FinishCommitWithAnnotations(repo, commit.ID, annotations))
+		return commit
+	}
+	commit1 := finish("", map[string]string{"job": "1", "source": "a"})
+	commit2 := finish(commit1.ID, map[string]string{"job": "2", "source": "a"})
+	commit3 := finish(commit2.ID, nil)
+
+	commitInfo, err := client.InspectCommit(repo, commit2.ID)
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"job": "2", "source": "a"}, commitInfo.Annotations)
+
+	checkList := func(annotations map[string]string, expected ...*pfsclient.Commit) {
+		commitInfos, err := client.ListCommitByAnnotation([]string{repo}, annotations)
+		require.NoError(t, err)
+		var actual []string
+		for _, commitInfo := range commitInfos {
+			actual = append(actual, commitInfo.Commit.ID)
+		}
+		var expectedIDs []string
+		for _, commit := range expected {
+			expectedIDs = append(expectedIDs, commit.ID)
+		}
+		sort.Strings(actual)
+		sort.Strings(expectedIDs)
+		require.Equal(t, expectedIDs, actual)
+	}
+	checkAll := func() {
+		checkList(map[string]string{"job": "1"}, commit1)
+		checkList(map[string]string{"source": "a"}, commit1, commit2)
+		checkList(map[string]string{"source": "a", "job": "2"}, commit2)
+		checkList(map[string]string{"source": "b"})
+		checkList(nil, commit1, commit2, commit3)
+	}
+	checkAll()
+	// annotations are persisted with the diffs
+	restartServer(server, t)
+	checkAll()
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	openCommit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	finishedCommit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, finishedCommit.ID))
+
+	put := pfsclient.OperationType_OPERATION_TYPE_PUT
+	del := pfsclient.OperationType_OPERATION_TYPE_DELETE
+	operation := func(operationType pfsclient.OperationType, commitID string, path string) *pfsclient.Operation {
+		return &pfsclient.Operation{Type: operationType, File: pclient.NewFile(repo, commitID, path)}
+	}
+	operations := []*pfsclient.Operation{
+		operation(put, openCommit.ID, "foo"),
+		operation(del, openCommit.ID, "dir/bar"),
+		operation(put, openCommit.ID, "/foo"),
+		operation(put, openCommit.ID, "../foo"),
+		operation(put, openCommit.ID, "dir/../../foo"),
+		operation(put, finishedCommit.ID, "foo"),
+		operation(del, "nonexistent", "foo"),
+		{Type: put},
+		operation(pfsclient.OperationType_OPERATION_TYPE_NONE, openCommit.ID, "foo"),
+	}
+	results, err := client.Validate(operations)
+	require.NoError(t, err)
+	require.Equal(t, len(operations), len(results))
+	shardInfos, err := client.ListShard()
+	require.NoError(t, err)
+	hasher := pfsserver.NewHasher(shards, 1)
+	for i := 0; i < 2; i++ {
+		require.Equal(t, "", results[i].Error)
+		shard := hasher.HashFile(operations[i].File)
+		require.Equal(t, shard, results[i].Shard)
+		require.Equal(t, shardInfos[shard].Address, results[i].Address)
+	}
+	for _, result := range results[2:] {
+		require.True(t, result.Error != "")
+	}
+	require.True(t, strings.Contains(results[2].Error, "leading slash"))
+	require.True(t, strings.Contains(results[3].Error, "outside"))
+	require.True(t, strings.Contains(results[4].Error, "outside"))
+	require.True(t, strings.Contains(results[5].Error, "finished"))
+
+	// nothing was written
+	_, err = client.InspectFileUnsafe(repo, openCommit.ID, "foo", "", nil, "")
+	require.YesError(t, err)
+
+	// the writes fail the same way
+	_, err = client.PutFile(repo, openCommit.ID, "../foo", strings.NewReader("foo\n"))
+	require.YesError(t, err)
+	require.Matches(t, "outside", err.Error())
+	_, err = client.PutFile(repo, finishedCommit.ID, "foo", strings.NewReader("foo\n"))
+	require.YesError(t, err)
+	require.Matches(t, "finished", err.Error())
+}
+
+func TestReadYourWrites(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	parent, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, parent.ID, "dir/file", strings.NewReader("parent\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, parent.ID))
+
+	commit, err := client.StartCommit(repo, parent.ID, "")
+	require.NoError(t, err)
+	// two writers share the open commit, each with its own handle
+	for _, handle := range []string{"a", "b"} {
+		writer, err := client.PutFileWriter(repo, commit.ID, "dir/file", pfsclient.Delimiter_LINE, handle)
+		require.NoError(t, err)
+		_, err = writer.Write([]byte(handle + "\n"))
+		require.NoError(t, err)
+		require.NoError(t, writer.Close())
+	}
+	writer, err := client.PutFileWriter(repo, commit.ID, "dir/new", pfsclient.Delimiter_LINE, "a")
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("a\n"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	getFile := func(unsafe bool, handle string) string {
+		var buffer bytes.Buffer
+		if unsafe {
+			require.NoError(t, client.GetFileUnsafe(repo, commit.ID, "dir/file", 0, 0, "", nil, handle, &buffer))
+		} else {
+			require.NoError(t, client.GetFile(repo, commit.ID, "dir/file", 0, 0, "", nil, &buffer))
+		}
+		return buffer.String()
+	}
+	// each writer sees its own writes on top of the parent, not the other's
+	require.Equal(t, "parent\na\n", getFile(true, "a"))
+	require.Equal(t, "parent\nb\n", getFile(true, "b"))
+	fileInfo, err := client.InspectFileUnsafe(repo, commit.ID, "dir/new", "", nil, "a")
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), fileInfo.SizeBytes)
+	fileInfos, err := client.ListFileUnsafe(repo, commit.ID, "dir", "", nil, false, "a")
+	require.NoError(t, err)
+	require.Equal(t, 2, len(fileInfos))
+	// safe reads see the parent's state until the commit is finished
+	require.Equal(t, "parent\n", getFile(false, ""))
+	_, err = client.InspectFile(repo, commit.ID, "dir/new", "", nil)
+	require.YesError(t, err)
+
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	content := getFile(false, "")
+	require.True(t, content == "parent\na\nb\n" || content == "parent\nb\na\n")
+	_, err = client.InspectFile(repo, commit.ID, "dir/new", "", nil)
+	require.NoError(t, err)
+}
+
+func TestStageBlob(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	// the blob is uploaded before the commit exists
+	content := strings.Repeat("foo\n", 10000)
+	stagedBlob, err := client.StageBlob(strings.NewReader(content), 0)
+	require.NoError(t, err)
+	require.Equal(t, uint64(len(content)), stagedBlob.SizeBytes)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "dir/file", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	// a staged blob can be put in several files
+	require.NoError(t, client.PutFileStaged(repo, commit.ID, "dir/file", stagedBlob.Handle))
+	require.NoError(t, client.PutFileStaged(repo, commit.ID, "other/file", stagedBlob.Handle))
+	require.YesError(t, client.PutFileStaged(repo, commit.ID, "dir/file2", "nonexistent"))
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "dir/file", 0, 0, "", nil, &buffer))
+	require.Equal(t, "bar\n"+content, buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "other/file", 0, 0, "", nil, &buffer))
+	require.Equal(t, content, buffer.String())
+	fileInfo, err := client.InspectFile(repo, commit.ID, "other", "", nil)
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_DIR, fileInfo.FileType)
+
+	// staged blobs expire
+	stagedBlob, err = client.StageBlob(strings.NewReader("baz\n"), 1)
+	require.NoError(t, err)
+	time.Sleep(1100 * time.Millisecond)
+	commit, err = client.StartCommit(repo, commit.ID, "")
+	require.NoError(t, err)
+	require.YesError(t, client.PutFileStaged(repo, commit.ID, "dir/file", stagedBlob.Handle))
+}
+
+func TestListCommitOnBranch(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commitA, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commitA.ID))
+	commitB, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commitB.ID))
+	// feature branches off of master at commitA
+	commitC, err := client.StartCommit(repo, commitA.ID, "feature")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commitC.ID))
+	commitD, err := client.StartCommit(repo, "", "feature")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commitD.ID))
+
+	commitIDs := func(commitInfos []*pfsclient.CommitInfo) []string {
+		var result []string
+		for _, commitInfo := range commitInfos {
+			result = append(result, commitInfo.Commit.ID)
+		}
+		return result
+	}
+	commitInfos, err := client.ListCommitOnBranch(repo, "feature", "master")
+	require.NoError(t, err)
+	require.EqualOneOf(t, []interface{}{
+		[]string{commitC.ID, commitD.ID},
+		[]string{commitD.ID, commitC.ID},
+	}, commitIDs(commitInfos))
+	commitInfos, err = client.ListCommitOnBranch(repo, "master", "feature")
+	require.NoError(t, err)
+	require.Equal(t, []string{commitB.ID}, commitIDs(commitInfos))
+	commitInfos, err = client.ListCommitOnBranch(repo, "feature", "")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(commitInfos))
+	commitInfos, err = client.ListCommitOnBranch(repo, "feature", "feature")
+	require.NoError(t, err)
+	require.Equal(t, 0, len(commitInfos))
+	_, err = client.ListCommitOnBranch(repo, "feature", "nonexistent")
+	require.YesError(t, err)
+}
+
+func TestFinishCommitIfParent(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commit2, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	err = client.FinishCommitIfParent(repo, commit2.ID, "nonexistent")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "conflict"))
+	require.NoError(t, client.FinishCommitIfParent(repo, commit2.ID, commit1.ID))
+
+	commit3, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	// another writer starts a commit on master while commit3 is open
+	racingCommit, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	err = client.FinishCommitIfParent(repo, commit3.ID, commit2.ID)
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "conflict"))
+	// the failed finish left commit3 open
+	commitInfo, err := client.InspectCommit(repo, commit3.ID)
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_WRITE, commitInfo.CommitType)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	require.NoError(t, client.FinishCommitIfParent(repo, racingCommit.ID, commit3.ID))
+
+	// the expected parent can be a branch
+	commit4, err := client.StartCommit(repo, racingCommit.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommitIfParent(repo, commit4.ID, "master"))
+	commit5, err := client.StartCommit(repo, racingCommit.ID, "")
+	require.NoError(t, err)
+	commit6, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	err = client.FinishCommitIfParent(repo, commit5.ID, "master")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "conflict"))
+	commitInfo, err = client.InspectCommit(repo, commit5.ID)
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_WRITE, commitInfo.CommitType)
+	require.NoError(t, client.FinishCommit(repo, commit6.ID))
+}
+
+func TestGetFileDiff(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("a\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	commit4, err := client.StartCommit(repo, commit3.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit4.ID, "foo", false, ""))
+	_, err = client.PutFile(repo, commit4.ID, "foo", strings.NewReader("c\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit4.ID))
+
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, "a\nb\n", buffer.String())
+	for _, check := range []struct {
+		commitID string
+		value    string
+	}{
+		{commit1.ID, "a\n"},
+		{commit2.ID, "b\n"},
+		{commit4.ID, "c\n"},
+	} {
+		buffer.Reset()
+		require.NoError(t, client.GetFileDiff(repo, check.commitID, "foo", &buffer))
+		require.Equal(t, check.value, buffer.String())
+	}
+	// commit3 didn't touch foo
+	require.YesError(t, client.GetFileDiff(repo, commit3.ID, "foo", &buffer))
+	require.YesError(t, client.GetFileDiff(repo, commit3.ID, "nonexistent", &buffer))
+
+	// the diff of an open commit can only be read unsafe
+	commit5, err := client.StartCommit(repo, commit4.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit5.ID, "foo", strings.NewReader("d\n"))
+	require.NoError(t, err)
+	require.YesError(t, client.GetFileDiff(repo, commit5.ID, "foo", &buffer))
+	getFileClient, err := client.PfsAPIClient.GetFile(
+		context.Background(),
+		&pfsclient.GetFileRequest{
+			File:      pclient.NewFile(repo, commit5.ID, "foo"),
+			SizeBytes: math.MaxInt64,
+			Unsafe:    true,
+			DiffOnly:  true,
+		},
+	)
+	require.NoError(t, err)
+	buffer.Reset()
+	require.NoError(t, protostream.WriteFromStreamingBytesClient(getFileClient, &buffer))
+	require.Equal(t, "d\n", buffer.String())
+}
+
+func TestGetFileMerged(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "a", strings.NewReader("master1\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "b", strings.NewReader("master1\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	commits := []*pfsclient.Commit{pclient.NewCommit(repo, "master"), pclient.NewCommit(repo, "feature")}
+	getFileMerged := func(path string) (string, error) {
+		var buffer bytes.Buffer
+		err := client.GetFileMerged(commits, path, &buffer)
+		return buffer.String(), err
+	}
+
+	// feature changes a after master did
+	commit2, err := client.StartCommit(repo, commit1.ID, "feature")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "a", false, ""))
+	_, err = client.PutFile(repo, commit2.ID, "a", strings.NewReader("feature2\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	content, err := getFileMerged("a")
+	require.NoError(t, err)
+	require.Equal(t, "feature2\n", content)
+	// b was last changed in commit1, which both branches share
+	content, err = getFileMerged("b")
+	require.NoError(t, err)
+	require.Equal(t, "master1\n", content)
+
+	// then master changes it again
+	commit3, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "a", strings.NewReader("master3\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	content, err = getFileMerged("a")
+	require.NoError(t, err)
+	require.Equal(t, "master1\nmaster3\n", content)
+
+	// then feature deletes it
+	commit4, err := client.StartCommit(repo, "", "feature")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit4.ID, "a", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit4.ID))
+	_, err = getFileMerged("a")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "not found"))
+
+	_, err = getFileMerged("c")
+	require.YesError(t, err)
+}
+
+func TestCommitChangedFiles(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	for _, path := range []string{"a", "b", "c", "dir/d"} {
+		_, err = client.PutFile(repo, commit1.ID, path, strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	fileChanges, err := client.CommitChangedFiles(repo, commit1.ID)
+	require.NoError(t, err)
+	require.Equal(t, 4, len(fileChanges))
+	for _, fileChange := range fileChanges {
+		require.Equal(t, pfsclient.ChangeType_CHANGE_TYPE_ADDED, fileChange.ChangeType)
+	}
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "a", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "b", false, ""))
+	// replacing a file is a modification
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "c", false, ""))
+	_, err = client.PutFile(repo, commit2.ID, "c", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "dir", false, ""))
+	_, err = client.PutFile(repo, commit2.ID, "e", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	// a file that's added and deleted in the same commit isn't a change
+	_, err = client.PutFile(repo, commit2.ID, "f", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "f", true, ""))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	fileChanges, err = client.CommitChangedFiles(repo, commit2.ID)
+	require.NoError(t, err)
+	var changes []string
+	for _, fileChange := range fileChanges {
+		require.Equal(t, commit2.ID, fileChange.File.Commit.ID)
+		changes = append(changes, fmt.Sprintf("%s %s", fileChange.File.Path, fileChange.ChangeType))
+	}
+	require.Equal(t, []string{
+		"a CHANGE_TYPE_MODIFIED",
+		"b CHANGE_TYPE_DELETED",
+		"c CHANGE_TYPE_MODIFIED",
+		"dir CHANGE_TYPE_DELETED",
+		"dir/d CHANGE_TYPE_DELETED",
+		"e CHANGE_TYPE_ADDED",
+	}, changes)
+}
+
+func TestCommitDiff(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	// putFiles writes a commit on branch with a file per path, a content
+	// made of several strings is written with a put per string
+	putFiles := func(branch string, files map[string][]string) *pfsclient.Commit {
+		commit, err := client.StartCommit(repo, "", branch)
+		require.NoError(t, err)
+		for filePath, contents := range files {
+			for _, content := range contents {
+				_, err = client.PutFile(repo, commit.ID, filePath, strings.NewReader(content))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, client.FinishCommit(repo, commit.ID))
+		return commit
+	}
+	// changes returns the diff from fromCommit to toCommit as strings
+	changes := func(fromCommit *pfsclient.Commit, toCommit *pfsclient.Commit) []string {
+		fileChanges, err := client.CommitDiff(repo, fromCommit.ID, toCommit.ID)
+		require.NoError(t, err)
+		var result []string
+		for _, fileChange := range fileChanges {
+			commit := toCommit
+			if fileChange.ChangeType == pfsclient.ChangeType_CHANGE_TYPE_DELETED {
+				commit = fromCommit
+			}
+			require.Equal(t, commit.ID, fileChange.File.Commit.ID)
+			result = append(result, fmt.Sprintf("%s %s", fileChange.File.Path, fileChange.ChangeType))
+		}
+		return result
+	}
+	master := putFiles("master", map[string][]string{
+		"a":     {"a\n"},
+		"b":     {"b\n"},
+		"dir/c": {"c\n"},
+		"same":  {"same\n"},
+	})
+	// other has no common ancestor with master
+	other := putFiles("other", map[string][]string{
+		"b":     {"bb\n"},
+		"dir/c": {"c\n"},
+		"d":     {"d\n"},
+		// the same content in different blocks isn't a change
+		"same": {"sa", "me\n"},
+	})
+	require.Equal(t, []string{
+		"a CHANGE_TYPE_DELETED",
+		"b CHANGE_TYPE_MODIFIED",
+		"d CHANGE_TYPE_ADDED",
+	}, changes(master, other))
+	require.Equal(t, []string{
+		"a CHANGE_TYPE_ADDED",
+		"b CHANGE_TYPE_MODIFIED",
+		"d CHANGE_TYPE_DELETED",
+	}, changes(other, master))
+	require.Equal(t, 0, len(changes(master, master)))
+
+	// a change that keeps a file's size is found by its content
+	commit, err := client.StartCommit(repo, "", "master")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit.ID, "a", false, ""))
+	_, err = client.PutFile(repo, commit.ID, "a", strings.NewReader("z\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	require.Equal(t, []string{"a CHANGE_TYPE_MODIFIED"}, changes(master, commit))
+	// branch names are resolved to their heads
+	fileChanges, err := client.CommitDiff(repo, "other", "master")
+	require.NoError(t, err)
+	require.Equal(t, 3, len(fileChanges))
+	require.Equal(t, commit.ID, fileChanges[0].File.Commit.ID)
+
+	_, err = client.CommitDiff(repo, master.ID, "nonexistent")
+	require.YesError(t, err)
+}
+
+func TestFileType(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/subdir/file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	// writes to open commits aren't visible
+	fileType, err := client.FileType(repo, commit1.ID, "file")
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_NONE, fileType)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+
+	for path, expected := range map[string]pfsclient.FileType{
+		"file":            pfsclient.FileType_FILE_TYPE_REGULAR,
+		"dir":             pfsclient.FileType_FILE_TYPE_DIR,
+		"dir/subdir":      pfsclient.FileType_FILE_TYPE_DIR,
+		"dir/subdir/file": pfsclient.FileType_FILE_TYPE_REGULAR,
+		"nonexistent":     pfsclient.FileType_FILE_TYPE_NONE,
+		"dir/nonexistent": pfsclient.FileType_FILE_TYPE_NONE,
+	} {
+		fileType, err := client.FileType(repo, commit1.ID, path)
+		require.NoError(t, err)
+		require.Equal(t, expected, fileType, path)
+	}
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "file", false, ""))
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+	fileType, err = client.FileType(repo, commit2.ID, "file")
 	require.NoError(t, err)
-	require.Equal(t, []*pfsclient.Commit{BCommit, ACommit}, commitInfo.Provenance)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_NONE, fileType)
+	fileType, err = client.FileType(repo, commit2.ID, "dir/subdir/file")
+	require.NoError(t, err)
+	require.Equal(t, pfsclient.FileType_FILE_TYPE_REGULAR, fileType)
 
-	// Test that we prevent provenant commits that aren't from provenant repos.
-	_, err = client.PfsAPIClient.StartCommit(
-		context.Background(),
-		&pfsclient.StartCommitRequest{
-			Repo:       pclient.NewRepo("C"),
-			Provenance: []*pfsclient.Commit{ACommit},
-		},
-	)
+	_, err = client.FileType(repo, "nonexistent", "file")
 	require.YesError(t, err)
+}
 
-	// Test ListRepo using provenance filtering
-	repoInfos, err := client.PfsAPIClient.ListRepo(
-		context.Background(),
-		&pfsclient.ListRepoRequest{
-			Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
-		},
-	)
-	require.NoError(t, err)
-	var repos []*pfsclient.Repo
-	for _, repoInfo := range repoInfos.RepoInfo {
-		repos = append(repos, repoInfo.Repo)
-	}
-	require.Equal(t, []*pfsclient.Repo{pclient.NewRepo("C")}, repos)
+func TestOpenCommitTimeout(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServerWithOptions(t, drive.Options{OpenCommitTimeout: 200 * time.Millisecond})
 
-	// Test ListRepo using provenance filtering
-	repoInfos, err = client.PfsAPIClient.ListRepo(
-		context.Background(),
-		&pfsclient.ListRepoRequest{
-			Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
-		},
-	)
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	repos = nil
-	for _, repoInfo := range repoInfos.RepoInfo {
-		repos = append(repos, repoInfo.Repo)
-	}
-	require.EqualOneOf(t, []interface{}{
-		[]*pfsclient.Repo{pclient.NewRepo("B"), pclient.NewRepo("C")},
-		[]*pfsclient.Repo{pclient.NewRepo("C"), pclient.NewRepo("B")},
-	}, repos)
-
-	// Test ListCommit using provenance filtering
-	commitInfos, err := client.PfsAPIClient.ListCommit(
-		context.Background(),
-		&pfsclient.ListCommitRequest{
-			Repo:       []*pfsclient.Repo{pclient.NewRepo("C")},
-			Provenance: []*pfsclient.Commit{ACommit},
-		},
-	)
+	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
 	require.NoError(t, err)
-	require.Equal(t, 1, len(commitInfos.CommitInfo))
-	require.Equal(t, CCommit, commitInfos.CommitInfo[0].Commit)
-
-	// Negative test ListCommit using provenance filtering
-	commitInfos, err = client.PfsAPIClient.ListCommit(
-		context.Background(),
-		&pfsclient.ListCommitRequest{
-			Repo:       []*pfsclient.Repo{pclient.NewRepo("A")},
-			Provenance: []*pfsclient.Commit{BCommit},
-		},
-	)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-	require.Equal(t, 0, len(commitInfos.CommitInfo))
-
-	// Test Blocking ListCommit using provenance filtering
-	ACommit2, err := client.StartCommit("A", "", "")
+	_, err = client.PutFile(repo, commit2.ID, "file", strings.NewReader("bar\n"))
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("A", ACommit2.ID))
-	commitInfosCh := make(chan *pfsclient.CommitInfos)
+	commit3, err := client.StartCommit(repo, commit2.ID, "")
+	require.NoError(t, err)
+	// a blocking ListCommit hears about the cancelled commits
+	listed := make(chan []*pfsclient.CommitInfo, 1)
 	go func() {
 		commitInfos, err := client.PfsAPIClient.ListCommit(
 			context.Background(),
 			&pfsclient.ListCommitRequest{
-				Repo:       []*pfsclient.Repo{pclient.NewRepo("B")},
-				Provenance: []*pfsclient.Commit{ACommit2},
+				Repo:       []*pfsclient.Repo{pclient.NewRepo(repo)},
 				CommitType: pfsclient.CommitType_COMMIT_TYPE_READ,
+				FromCommit: []*pfsclient.Commit{commit1},
 				Block:      true,
+				All:        true,
 			},
 		)
 		require.NoError(t, err)
-		commitInfosCh <- commitInfos
+		listed <- commitInfos.CommitInfo
 	}()
-	BCommit2, err := client.PfsAPIClient.StartCommit(
-		context.Background(),
-		&pfsclient.StartCommitRequest{
-			Repo:       pclient.NewRepo("B"),
-			Provenance: []*pfsclient.Commit{ACommit2},
-		},
-	)
-	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("B", BCommit2.ID))
 	select {
-	case <-time.After(5 * time.Second):
-		t.Errorf("timeout waiting for commit")
-	case commitInfos := <-commitInfosCh:
-		require.Equal(t, 1, len(commitInfos.CommitInfo))
-		require.Equal(t, BCommit2, commitInfos.CommitInfo[0].Commit)
+	case commitInfos := <-listed:
+		require.True(t, len(commitInfos) > 0)
+		require.True(t, commitInfos[0].Cancelled)
+	case <-time.After(2 * time.Second):
+		t.Fatal("blocking ListCommit didn't return after its commit was cancelled")
 	}
-	go func() {
-		commitInfos, err := client.PfsAPIClient.ListCommit(
-			context.Background(),
-			&pfsclient.ListCommitRequest{
-				Repo:       []*pfsclient.Repo{pclient.NewRepo("C")},
-				Provenance: []*pfsclient.Commit{ACommit2},
-				CommitType: pfsclient.CommitType_COMMIT_TYPE_READ,
-				Block:      true,
-			},
-		)
-		require.NoError(t, err)
-		commitInfosCh <- commitInfos
-	}()
-	CCommit2, err := client.PfsAPIClient.StartCommit(
-		context.Background(),
-		&pfsclient.StartCommitRequest{
-			Repo:       pclient.NewRepo("C"),
-			Provenance: []*pfsclient.Commit{BCommit2},
-		},
-	)
+
+	// commits are cancelled within two timeouts
+	time.Sleep(800 * time.Millisecond)
+	commitInfo, err := client.InspectCommit(repo, commit1.ID)
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("C", CCommit2.ID))
-	select {
-	case <-time.After(5 * time.Second):
-		t.Errorf("timeout waiting for commit")
-	case commitInfos := <-commitInfosCh:
-		require.Equal(t, 1, len(commitInfos.CommitInfo))
-		require.Equal(t, CCommit2, commitInfos.CommitInfo[0].Commit)
+	require.False(t, commitInfo.Cancelled)
+	for _, commit := range []*pfsclient.Commit{commit2, commit3} {
+		commitInfo, err = client.InspectCommit(repo, commit.ID)
+		require.NoError(t, err)
+		require.Equal(t, pfsclient.CommitType_COMMIT_TYPE_READ, commitInfo.CommitType)
+		require.True(t, commitInfo.Cancelled)
 	}
+	require.YesError(t, client.FinishCommit(repo, commit2.ID))
+	commitInfos, err := client.ListCommit([]string{repo}, nil, pclient.CommitTypeNone, false, false, nil)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(commitInfos))
 }
 
-func TestFlush(t *testing.T) {
+func TestSetImmutable(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
-	require.NoError(t, client.CreateRepo("A"))
-	_, err := client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
-		Repo:       pclient.NewRepo("B"),
-		Provenance: []*pfsclient.Repo{pclient.NewRepo("A")},
-	})
-	require.NoError(t, err)
-	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
-		Repo:       pclient.NewRepo("C"),
-		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
-	})
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	_, err = client.PfsAPIClient.CreateRepo(context.Background(), &pfsclient.CreateRepoRequest{
-		Repo:       pclient.NewRepo("D"),
-		Provenance: []*pfsclient.Repo{pclient.NewRepo("B")},
-	})
+	_, err = client.PutFile(repo, commit1.ID, "dir/file", strings.NewReader("foo\n"))
 	require.NoError(t, err)
-	ACommit, err := client.StartCommit("A", "", "")
+	_, err = client.PutFile(repo, commit1.ID, "dir/other", strings.NewReader("foo\n"))
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("A", ACommit.ID))
-
-	// do the other commits in a goro so we can block for them
-	go func() {
-		BCommit, err := client.PfsAPIClient.StartCommit(
-			context.Background(),
-			&pfsclient.StartCommitRequest{
-				Repo:       pclient.NewRepo("B"),
-				Provenance: []*pfsclient.Commit{ACommit},
-			},
-		)
-		require.NoError(t, err)
-		require.NoError(t, client.FinishCommit("B", BCommit.ID))
-		CCommit, err := client.PfsAPIClient.StartCommit(
-			context.Background(),
-			&pfsclient.StartCommitRequest{
-				Repo:       pclient.NewRepo("C"),
-				Provenance: []*pfsclient.Commit{BCommit},
-			},
-		)
-		require.NoError(t, err)
-		require.NoError(t, client.FinishCommit("C", CCommit.ID))
-		DCommit, err := client.PfsAPIClient.StartCommit(
-			context.Background(),
-			&pfsclient.StartCommitRequest{
-				Repo:       pclient.NewRepo("D"),
-				Provenance: []*pfsclient.Commit{BCommit},
-			},
-		)
-		require.NoError(t, err)
-		require.NoError(t, client.FinishCommit("D", DCommit.ID))
-	}()
+	require.NoError(t, client.SetImmutable(repo, commit1.ID, "dir/file"))
+	require.YesError(t, client.SetImmutable(repo, commit1.ID, "dir"))
+	require.YesError(t, client.SetImmutable(repo, commit1.ID, "nonexistent"))
+	_, err = client.PutFile(repo, commit1.ID, "dir/file", strings.NewReader("bar\n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
 
-	// Flush ACommit
-	commitInfos, err := client.FlushCommit([]*pfsclient.Commit{pclient.NewCommit("A", ACommit.ID)}, nil)
+	fileInfo, err := client.InspectFile(repo, commit1.ID, "dir/file", "", nil)
 	require.NoError(t, err)
-	require.Equal(t, 3, len(commitInfos))
-	commitInfos, err = client.FlushCommit(
-		[]*pfsclient.Commit{pclient.NewCommit("A", ACommit.ID)},
-		[]*pfsclient.Repo{pclient.NewRepo("C")},
-	)
+	require.True(t, fileInfo.Immutable)
+	fileInfo, err = client.InspectFile(repo, commit1.ID, "dir/other", "", nil)
 	require.NoError(t, err)
-	require.Equal(t, 2, len(commitInfos))
+	require.False(t, fileInfo.Immutable)
 
-	// Now test what happens if one of the commits gets cancelled
-	ACommit2, err := client.StartCommit("A", "", "")
+	// the file stays immutable in later commits
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit("A", ACommit2.ID))
+	_, err = client.PutFile(repo, commit2.ID, "dir/file", strings.NewReader("bar\n"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	err = client.DeleteFile(repo, commit2.ID, "dir/file", false, "")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	err = client.DeleteFile(repo, commit2.ID, "dir", false, "")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	_, err = client.PutFileAt(repo, commit2.ID, "dir/file", 0, strings.NewReader("XX"))
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	_, err = client.PutFile(repo, commit2.ID, "dir/other", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	// do the other commits in a goro so we can block for them
-	go func() {
-		BCommit2, err := client.PfsAPIClient.StartCommit(
-			context.Background(),
-			&pfsclient.StartCommitRequest{
-				Repo:       pclient.NewRepo("B"),
-				Provenance: []*pfsclient.Commit{ACommit2},
-			},
-		)
-		require.NoError(t, err)
-		require.NoError(t, client.CancelCommit("B", BCommit2.ID))
-	}()
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/file", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\n", buffer.String())
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/other", 0, 0, "", nil, &buffer))
+	require.Equal(t, "foo\nbar\n", buffer.String())
 
-	// Flush ACommit2
-	_, err = client.FlushCommit(
-		[]*pfsclient.Commit{pclient.NewCommit("A", ACommit2.ID)},
-		[]*pfsclient.Repo{pclient.NewRepo("C")},
+	// a commit started from another commit would replace its parent's files,
+	// so the parent can't have immutable files
+	_, err = client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo(repo),
+			ParentID:   commit2.ID,
+			FromCommit: commit1,
+		},
+	)
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	// and the files it starts out with stay immutable
+	commit3, err := client.PfsAPIClient.StartCommit(
+		context.Background(),
+		&pfsclient.StartCommitRequest{
+			Repo:       pclient.NewRepo(repo),
+			FromCommit: commit1,
+		},
 	)
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit3.ID, "dir/file", strings.NewReader("bar\n"))
 	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "immutable"))
+	require.NoError(t, client.FinishCommit(repo, commit3.ID))
+	fileInfo, err = client.InspectFile(repo, commit3.ID, "dir/file", "", nil)
+	require.NoError(t, err)
+	require.True(t, fileInfo.Immutable)
+}
+
+func TestSetImmutableWhileDeleting(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		filePath := fmt.Sprintf("file%d", i)
+		_, err = client.PutFile(repo, commit.ID, filePath, strings.NewReader("foo\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	commit2, err := client.StartCommit(repo, commit.ID, "")
+	require.NoError(t, err)
+	// a file can be made immutable or deleted but not both, whichever
+	// happens second fails
+	for i := 0; i < 50; i++ {
+		filePath := fmt.Sprintf("file%d", i)
+		var wg sync.WaitGroup
+		var setErr, deleteErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			setErr = client.SetImmutable(repo, commit2.ID, filePath)
+		}()
+		go func() {
+			defer wg.Done()
+			deleteErr = client.DeleteFile(repo, commit2.ID, filePath, false, "")
+		}()
+		wg.Wait()
+		require.True(t, (setErr == nil) != (deleteErr == nil), "set: %v, delete: %v", setErr, deleteErr)
+	}
+}
+
+func TestGetFileSuffix(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	contents := make(map[string]string)
+	for _, size := range []int{1, 99, 100, 101, 10000} {
+		var buffer bytes.Buffer
+		for i := 0; buffer.Len() < size; i++ {
+			fmt.Fprintf(&buffer, "%d\n", i)
+		}
+		path := fmt.Sprintf("file%d", size)
+		contents[path] = buffer.String()[:size]
+		_, err = client.PutFile(repo, commit.ID, path, strings.NewReader(contents[path]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
+
+	for path, content := range contents {
+		suffix := content
+		if len(suffix) > 100 {
+			suffix = suffix[len(suffix)-100:]
+		}
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit.ID, path, -100, 0, "", nil, &buffer))
+		require.Equal(t, suffix, buffer.String())
+		// size still limits how much is read
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit.ID, path, -100, 10, "", nil, &buffer))
+		if len(suffix) > 10 {
+			suffix = suffix[:10]
+		}
+		require.Equal(t, suffix, buffer.String())
+	}
 }
 
-func TestShardingInTopLevel(t *testing.T) {
+func TestCommitManifest(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	folders := 4
-	filesPerFolder := 10
-
-	commit, err := client.StartCommit(repo, "", "")
+	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	for i := 0; i < folders; i++ {
-		for j := 0; j < filesPerFolder; j++ {
-			_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir%d/file%d", i, j), strings.NewReader("foo\n"))
-			require.NoError(t, err)
-		}
+	paths := []string{"a", "b", "dir/c", "dir/subdir/d", "e"}
+	for _, path := range paths {
+		_, err = client.PutFile(repo, commit1.ID, path, strings.NewReader(path+"\n"))
+		require.NoError(t, err)
 	}
-	require.NoError(t, client.FinishCommit(repo, commit.ID))
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "dir/c", strings.NewReader("changed\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	totalFiles := 0
-	for i := 0; i < folders; i++ {
-		shard := &pfsclient.Shard{
-			FileNumber:  uint64(i),
-			FileModulus: uint64(folders),
-		}
-		for j := 0; j < folders; j++ {
-			// You should either see all files in the folder (if the folder
-			// matches the shard), or no files in the folder (if the folder
-			// does not match the shard).
-			fileInfos, err := client.ListFile(repo, commit.ID, fmt.Sprintf("dir%d", j), "", shard, false)
-			require.NoError(t, err)
-			require.EqualOneOf(t, []interface{}{filesPerFolder, 0}, len(fileInfos))
-			totalFiles += len(fileInfos)
+	manifest1, err := client.CommitManifest(repo, commit1.ID)
+	require.NoError(t, err)
+	manifest2, err := client.CommitManifest(repo, commit2.ID)
+	require.NoError(t, err)
+	require.Equal(t, len(paths), len(manifest1))
+	require.Equal(t, len(paths), len(manifest2))
+	var changed []string
+	for i, path := range paths {
+		require.Equal(t, path, manifest1[i].File.Path)
+		require.Equal(t, commit1.ID, manifest1[i].File.Commit.ID)
+		require.Equal(t, uint64(len(path)+1), manifest1[i].SizeBytes)
+		require.Equal(t, path, manifest2[i].File.Path)
+		if manifest1[i].BlockRefsHash != manifest2[i].BlockRefsHash {
+			changed = append(changed, path)
 		}
 	}
-	require.Equal(t, folders*filesPerFolder, totalFiles)
+	require.Equal(t, []string{"dir/c"}, changed)
+	require.Equal(t, uint64(len("dir/c\nchanged\n")), manifest2[2].SizeBytes)
 }
 
-func TestCreate(t *testing.T) {
+func TestPackCommit(t *testing.T) {
 	t.Parallel()
 	client, _ := getClientAndServer(t)
 
@@ -1374,249 +5033,840 @@ func TestCreate(t *testing.T) {
 	require.NoError(t, client.CreateRepo(repo))
 	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	w, err := client.PutFileWriter(repo, commit.ID, "foo", pfsclient.Delimiter_LINE, "handle")
+	numFiles := 50
+	for i := 0; i < numFiles; i++ {
+		_, err = client.PutFile(repo, commit.ID, fmt.Sprintf("dir/file%d", i), strings.NewReader(fmt.Sprintf("content %d\n", i)))
+		require.NoError(t, err)
+	}
+	big := strings.Repeat("a", 1024)
+	_, err = client.PutFile(repo, commit.ID, "big", strings.NewReader(big))
 	require.NoError(t, err)
-	require.NoError(t, w.Close())
+
+	// open commits can't be packed
+	_, err = client.PackCommit(repo, commit.ID, 0)
+	require.YesError(t, err)
 	require.NoError(t, client.FinishCommit(repo, commit.ID))
-	_, err = client.InspectFileUnsafe(repo, commit.ID, "foo", "", nil, "handle")
+	manifest, err := client.CommitManifest(repo, commit.ID)
+	require.NoError(t, err)
+
+	filesPacked, err := client.PackCommit(repo, commit.ID, 1023)
+	require.NoError(t, err)
+	// files are only packed with other files in their shard
+	require.True(t, filesPacked > uint64(numFiles-shards))
+	require.True(t, filesPacked <= uint64(numFiles))
+
+	for i := 0; i < numFiles; i++ {
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit.ID, fmt.Sprintf("dir/file%d", i), 0, 0, "", nil, &buffer))
+		require.Equal(t, fmt.Sprintf("content %d\n", i), buffer.String())
+	}
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit.ID, "big", 0, 0, "", nil, &buffer))
+	require.Equal(t, big, buffer.String())
+	// a partial read resolves to the right part of the packed block
+	buffer.Reset()
+	require.NoError(t, client.GetFile(repo, commit.ID, "dir/file7", 2, 3, "", nil, &buffer))
+	require.Equal(t, "nte", buffer.String())
+	fileInfos, err := client.ListFile(repo, commit.ID, "dir", "", nil, false)
+	require.NoError(t, err)
+	require.Equal(t, numFiles, len(fileInfos))
+	packedManifest, err := client.CommitManifest(repo, commit.ID)
+	require.NoError(t, err)
+	require.Equal(t, len(manifest), len(packedManifest))
+	var repacked int
+	for i := range manifest {
+		require.Equal(t, manifest[i].SizeBytes, packedManifest[i].SizeBytes)
+		if manifest[i].BlockRefsHash != packedManifest[i].BlockRefsHash {
+			repacked++
+		}
+	}
+	// the packed files, and only those, refer to new blocks
+	require.Equal(t, int(filesPacked), repacked)
+
+	// packing again doesn't change anything
+	_, err = client.PackCommit(repo, commit.ID, 1023)
+	require.NoError(t, err)
+	repackedManifest, err := client.CommitManifest(repo, commit.ID)
 	require.NoError(t, err)
+	require.Equal(t, packedManifest, repackedManifest)
 }
 
-func TestGetFileInvalidCommit(t *testing.T) {
+func TestInlineFiles(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	client, server := getClientAndServerWithOptions(t, drive.Options{InlineFileSize: 16})
+
+	totals := func() (sizeBytes uint64, blocks uint64) {
+		shardStats, err := client.ShardStats()
+		require.NoError(t, err)
+		for _, shardStat := range shardStats {
+			sizeBytes += shardStat.SizeBytes
+			blocks += shardStat.Blocks
+		}
+		return sizeBytes, blocks
+	}
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	big := strings.Repeat("a", 17)
+	_, err = client.PutFile(repo, commit1.ID, "big", strings.NewReader(big))
 	require.NoError(t, err)
 	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	// foo is inlined, big gets a block
+	sizeBytes, blocks := totals()
+	require.Equal(t, uint64(21), sizeBytes)
+	require.Equal(t, uint64(1), blocks)
+
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
+
+	check := func() {
+		var buffer bytes.Buffer
+		require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
+		require.Equal(t, "foo\n", buffer.String())
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+		require.Equal(t, "foo\nbar\n", buffer.String())
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 2, 4, "", nil, &buffer))
+		require.Equal(t, "o\nba", buffer.String())
+		buffer.Reset()
+		require.NoError(t, client.GetFile(repo, commit2.ID, "big", 0, 0, "", nil, &buffer))
+		require.Equal(t, big, buffer.String())
+		fileInfo, err := client.InspectFile(repo, commit2.ID, "foo", "", nil)
+		require.NoError(t, err)
+		require.Equal(t, uint64(8), fileInfo.SizeBytes)
+	}
+	check()
+	_, blocks = totals()
+	require.Equal(t, uint64(1), blocks)
+
+	// inline content is persisted with the diffs
+	restartServer(server, t)
+	check()
+}
+
+func TestReserveCommit(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServer(t)
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	reserved, err := client.ReserveCommit(repo)
+	require.NoError(t, err)
+	require.True(t, reserved.ID != "")
+	other, err := client.ReserveCommit(repo)
+	require.NoError(t, err)
+	require.True(t, reserved.ID != other.ID)
+	// reserved commits don't exist until they're started
+	_, err = client.InspectCommit(repo, reserved.ID)
+	require.YesError(t, err)
 
+	commit, err := client.StartReservedCommit(repo, reserved.ID, "", "master")
+	require.NoError(t, err)
+	require.Equal(t, reserved.ID, commit.ID)
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
 	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit1.ID, "file", 0, 0, "", nil, &buffer))
+	require.NoError(t, client.GetFile(repo, "master", "foo", 0, 0, "", nil, &buffer))
 	require.Equal(t, "foo\n", buffer.String())
-	err = client.GetFile(repo, "aninvalidcommitid", "file", 0, 0, "", nil, &buffer)
-	require.YesError(t, err)
 
-	require.Equal(t, fmt.Sprintf("Commit %v not found in repo %v", "aninvalidcommitid", repo), err.Error())
+	// a reservation can only be used once
+	_, err = client.StartReservedCommit(repo, reserved.ID, "", "")
+	require.YesError(t, err)
+	// ids that weren't reserved can't be used
+	_, err = client.StartReservedCommit(repo, "unreserved", "", "")
+	require.YesError(t, err)
+	_, err = client.ReserveCommit("nonexistent")
+	require.YesError(t, err)
 }
 
-func TestScrubbedErrorStrings(t *testing.T) {
+func TestReserveCommitExpires(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServerWithOptions(t, drive.Options{CommitReservationTimeout: 100 * time.Millisecond})
+
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	reserved, err := client.ReserveCommit(repo)
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	_, err = client.StartReservedCommit(repo, reserved.ID, "", "")
+	require.YesError(t, err)
+	reserved, err = client.ReserveCommit(repo)
+	require.NoError(t, err)
+	_, err = client.StartReservedCommit(repo, reserved.ID, "", "")
+	require.NoError(t, err)
+}
 
+func TestUnstartCommit(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	driver, err := drive.NewDriver(runBlockServer(t))
+	require.NoError(t, err)
+	allShards := make(map[uint64]bool)
+	for i := 0; i < shards; i++ {
+		require.NoError(t, driver.AddShard(uint64(i)))
+		allShards[uint64(i)] = true
+	}
 
-	err := client.CreateRepo("foo||@&#$TYX")
-	require.Equal(t, "Repo name (foo||@&#$TYX) invalid. Only alphanumeric and underscore characters allowed.", err.Error())
+	repo := pclient.NewRepo("test")
+	require.NoError(t, driver.CreateRepo(repo, prototime.TimeToTimestamp(time.Now()), nil, false, allShards))
+	commit := pclient.NewCommit(repo.Name, "reserved")
+	require.NoError(t, driver.ReserveCommit(commit, allShards))
+	require.NoError(t, driver.StartCommit(repo, commit.ID, "", "", prototime.TimeToTimestamp(time.Now()), nil, nil, true, allShards))
+	require.YesError(t, driver.StartCommit(repo, commit.ID, "", "", prototime.TimeToTimestamp(time.Now()), nil, nil, true, allShards))
 
-	_, err = client.StartCommit("zzzzz", "", "")
-	require.Equal(t, "Repo zzzzz not found", err.Error())
+	// unstarting the commit removes it and reserves its ID again
+	require.NoError(t, driver.UnstartCommit(commit, allShards))
+	_, err = driver.InspectCommit(commit, allShards)
+	require.YesError(t, err)
+	require.NoError(t, driver.StartCommit(repo, commit.ID, "", "", prototime.TimeToTimestamp(time.Now()), nil, nil, true, allShards))
+	require.NoError(t, driver.FinishCommit(commit, prototime.TimeToTimestamp(time.Now()), false, drive.FinishCommitOptions{}, allShards))
+	// finished commits can't be unstarted
+	require.YesError(t, driver.UnstartCommit(commit, allShards))
+}
 
-	_, err = client.ListCommit([]string{"zzzzz"}, []string{}, pclient.CommitTypeNone, false, true, []*pfsclient.Commit{})
-	require.Equal(t, "Repo zzzzz not found", err.Error())
+func TestTeeDriverSync(t *testing.T) {
+	t.Parallel()
+	testTeeDriver(t, drive.TeeOptions{})
+}
 
-	_, err = client.InspectRepo("bogusrepo")
-	require.Equal(t, "Repo bogusrepo not found", err.Error())
+func TestTeeDriverAsync(t *testing.T) {
+	t.Parallel()
+	testTeeDriver(t, drive.TeeOptions{Async: true})
+}
+
+func TestTeeDriverAsyncStreamed(t *testing.T) {
+	t.Parallel()
+	// every file is bigger than the queue so they're all streamed
+	testTeeDriver(t, drive.TeeOptions{Async: true, QueueBytes: 2})
+}
+
+func testTeeDriver(t *testing.T, options drive.TeeOptions) {
+	secondaryAddress := runBlockServer(t)
+	var teeDrivers []drive.Driver
+	client, _ := getClientAndServerWithDrivers(t, func(address string) (drive.Driver, error) {
+		primary, err := drive.NewDriver(address)
+		if err != nil {
+			return nil, err
+		}
+		secondary, err := drive.NewDriver(secondaryAddress)
+		if err != nil {
+			return nil, err
+		}
+		teeDriver := drive.NewTeeDriver(primary, secondary, options)
+		teeDrivers = append(teeDrivers, teeDriver)
+		return teeDriver, nil
+	})
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit1.ID, "dir/bar", strings.NewReader("bar\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	require.NoError(t, client.DeleteFile(repo, commit2.ID, "foo", false, ""))
+	_, err = client.PutFile(repo, commit2.ID, "dir/bar", strings.NewReader("baz\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	_, err = client.PutFile("sdf", commit1.ID, "file", strings.NewReader("foo\n"))
-	require.Equal(t, "Repo sdf not found", err.Error())
+	// the primary is unaffected
+	var buffer bytes.Buffer
+	require.NoError(t, client.GetFile(repo, commit2.ID, "dir/bar", 0, 0, "", nil, &buffer))
+	require.Equal(t, "bar\nbaz\n", buffer.String())
+
+	// a fresh driver reads what was mirrored back from the secondary's
+	// block server
+	hasher := pfsserver.NewHasher(shards, 1)
+	allShards := make(map[uint64]bool)
+	for i := 0; i < shards; i++ {
+		allShards[uint64(i)] = true
+	}
+	getFile := func(driver drive.Driver, commitID string, path string) (string, error) {
+		file := pclient.NewFile(repo, commitID, path)
+		reader, err := driver.GetFile(file, nil, 0, math.MaxInt64, nil, hasher.HashFile(file), false, "")
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		return string(data), err
+	}
+	checkSecondary := func() error {
+		driver, err := drive.NewDriver(secondaryAddress)
+		if err != nil {
+			return err
+		}
+		for shard := range allShards {
+			if err := driver.AddShard(shard); err != nil {
+				return err
+			}
+		}
+		commitInfo, err := driver.InspectCommit(commit2, allShards)
+		if err != nil {
+			return err
+		}
+		if commitInfo.CommitType != pfsclient.CommitType_COMMIT_TYPE_READ {
+			return fmt.Errorf("commit2 isn't finished on the secondary")
+		}
+		for _, check := range []struct {
+			commitID string
+			path     string
+			value    string
+		}{
+			{commit1.ID, "foo", "foo\n"},
+			{commit1.ID, "dir/bar", "bar\n"},
+			{commit2.ID, "dir/bar", "bar\nbaz\n"},
+		} {
+			value, err := getFile(driver, check.commitID, check.path)
+			if err != nil {
+				return err
+			}
+			if value != check.value {
+				return fmt.Errorf("expected %q for %s@%s but got %q", check.value, check.path, check.commitID, value)
+			}
+		}
+		if _, err := getFile(driver, commit2.ID, "foo"); err == nil {
+			return fmt.Errorf("foo wasn't deleted on the secondary")
+		}
+		return nil
+	}
+	if !options.Async {
+		require.NoError(t, checkSecondary())
+		return
+	}
+	// closing an async driver waits for its queued writes to be mirrored
+	for _, teeDriver := range teeDrivers {
+		require.NoError(t, teeDriver.Close())
+	}
+	require.NoError(t, checkSecondary())
+	// and writes made after that aren't
+	_, err = client.StartCommit(repo, commit2.ID, "")
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "closed"))
+}
 
-	_, err = client.PutFile(repo, commit1.ID, "file", strings.NewReader("foo\n"))
+// putBlockData returns numBlocks blocks worth of distinct lines
+func putBlockData(numBlocks int) []byte {
+	var buffer bytes.Buffer
+	for buffer.Len() < numBlocks*blockSize {
+		fmt.Fprintf(&buffer, "%08d%s\n", buffer.Len(), strings.Repeat("a", 1015))
+	}
+	return buffer.Bytes()
+}
+
+func TestPutBlockParallel(t *testing.T) {
+	t.Parallel()
+	objClient := newMemObjClient(time.Millisecond)
+	client := runObjBlockServer(t, objClient, BlockAPIServerOptions{PutBlockParallelism: 4})
+	data := putBlockData(8)
+	blockRefs, err := client.PutBlock(pfsclient.Delimiter_LINE, bytes.NewReader(data))
+	require.NoError(t, err)
+	require.True(t, len(blockRefs.BlockRef) > 1)
+	require.Equal(t, len(blockRefs.BlockRef), objClient.len())
+	// the refs should be in file order no matter which write finished first
+	var buffer bytes.Buffer
+	for _, blockRef := range blockRefs.BlockRef {
+		reader, err := client.GetBlock(blockRef.Block.Hash, 0, 0)
+		require.NoError(t, err)
+		_, err = io.Copy(&buffer, reader)
+		require.NoError(t, err)
+	}
+	require.Equal(t, string(data), buffer.String())
+}
+
+func TestPutBlockPartialFailure(t *testing.T) {
+	t.Parallel()
+	objClient := newMemObjClient(time.Millisecond)
+	client := runObjBlockServer(t, objClient, BlockAPIServerOptions{PutBlockParallelism: 4})
+	data := putBlockData(8)
+	blockRefs, err := client.PutBlock(pfsclient.Delimiter_LINE, bytes.NewReader(data))
+	require.NoError(t, err)
+	// putting the same content again rewrites the same blocks, failing part
+	// way through mustn't take the existing blocks with it
+	atomic.StoreInt32(&objClient.failWrite, atomic.LoadInt32(&objClient.writes)+3)
+	_, err = client.PutBlock(pfsclient.Delimiter_LINE, bytes.NewReader(data))
+	require.YesError(t, err)
+	require.Equal(t, len(blockRefs.BlockRef), objClient.len())
+	var buffer bytes.Buffer
+	for _, blockRef := range blockRefs.BlockRef {
+		reader, err := client.GetBlock(blockRef.Block.Hash, 0, 0)
+		require.NoError(t, err)
+		_, err = io.Copy(&buffer, reader)
+		require.NoError(t, err)
+	}
+	require.Equal(t, string(data), buffer.String())
+}
+
+func benchmarkPutBlock(b *testing.B, parallelism int) {
+	objClient := newMemObjClient(50 * time.Millisecond)
+	client := runObjBlockServer(b, objClient, BlockAPIServerOptions{PutBlockParallelism: parallelism})
+	data := putBlockData(8)
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := client.PutBlock(pfsclient.Delimiter_LINE, bytes.NewReader(data))
+		require.NoError(b, err)
+		b.StopTimer()
+		objClient.reset()
+		b.StartTimer()
+	}
+}
+
+// BenchmarkPutBlockSequential writes a 64MB file to an object store with
+// 50ms of latency one block at a time
+func BenchmarkPutBlockSequential(b *testing.B) {
+	benchmarkPutBlock(b, 1)
+}
+
+// BenchmarkPutBlockParallel writes the same file as
+// BenchmarkPutBlockSequential with all of its blocks written at once
+func BenchmarkPutBlockParallel(b *testing.B) {
+	benchmarkPutBlock(b, 8)
+}
+
+func TestShardScheduleReadPriority(t *testing.T) {
+	t.Parallel()
+	driver, err := drive.NewDriverWithOptions(runBlockServer(t), drive.Options{
+		MaxShardOperations:  1,
+		ShardSchedulePolicy: drive.ShardScheduleReadPriority,
+	})
 	require.NoError(t, err)
+	shard := uint64(0)
+	shardSet := map[uint64]bool{shard: true}
+	require.NoError(t, driver.AddShard(shard))
+	repo := pclient.NewRepo("test")
+	now := prototime.TimeToTimestamp(time.Now())
+	require.NoError(t, driver.CreateRepo(repo, now, nil, false, shardSet))
+	require.NoError(t, driver.StartCommit(repo, "commit1", "", "", now, nil, nil, false, shardSet))
+	file := pclient.NewFile("test", "commit1", "foo")
+	require.NoError(t, driver.PutFile(file, "", pfsclient.Delimiter_LINE, drive.PutFileOptions{}, shard, strings.NewReader("foo\n")))
+	require.NoError(t, driver.FinishCommit(file.Commit, now, false, drive.FinishCommitOptions{}, shardSet))
+	require.NoError(t, driver.StartCommit(repo, "commit2", "commit1", "", now, nil, nil, false, shardSet))
+
+	// waitForWaiting waits for n operations to be waiting on the shard
+	waitForWaiting := func(n uint64) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			shardStat, err := driver.ShardStats(shard)
+			require.NoError(t, err)
+			if shardStat.WaitingOperations == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d waiting operations but got %d", n, shardStat.WaitingOperations)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
 
-	err = client.FinishCommit("zzzzzz", commit1.ID)
-	require.Equal(t, "Repo zzzzzz not found", err.Error())
+	// an open reader holds on to the shard's only slot
+	reader, err := driver.GetFile(file, nil, 0, math.MaxInt64, nil, shard, false, "")
+	require.NoError(t, err)
+	order := make(chan string, 2)
+	go func() {
+		require.NoError(t, driver.PutFile(pclient.NewFile("test", "commit2", "bar"), "", pfsclient.Delimiter_LINE,
+			drive.PutFileOptions{}, shard, strings.NewReader("bar\n")))
+		order <- "write"
+	}()
+	// make sure the write is waiting before the read shows up
+	waitForWaiting(1)
+	go func() {
+		_, err := driver.InspectFile(file, nil, nil, shard, false, "")
+		require.NoError(t, err)
+		order <- "read"
+	}()
+	waitForWaiting(2)
+	select {
+	case op := <-order:
+		t.Fatalf("%s ran while the shard was busy", op)
+	default:
+	}
+	require.NoError(t, reader.Close())
+	// the read arrived last but goes first
+	require.Equal(t, "read", <-order)
+	require.Equal(t, "write", <-order)
 
-	err = client.FinishCommit(repo, "bogus")
-	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+	// finishing a commit is a write too, it waits for the open reader
+	reader, err = driver.GetFile(file, nil, 0, math.MaxInt64, nil, shard, false, "")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, driver.FinishCommit(pclient.NewCommit("test", "commit2"), now, false, drive.FinishCommitOptions{}, shardSet))
+		order <- "finish"
+	}()
+	waitForWaiting(1)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "finish", <-order)
+}
 
-	err = client.CancelCommit(repo, "bogus")
-	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+func TestVerifyBlocks(t *testing.T) {
+	t.Parallel()
+	root := uniqueString("/tmp/pach_test/run")
+	address := runBlockServerWithRoot(t, root)
+	driver, err := drive.NewDriverWithOptions(address, drive.Options{VerifyBlocks: true})
+	require.NoError(t, err)
+	shard := uint64(0)
+	shardSet := map[uint64]bool{shard: true}
+	require.NoError(t, driver.AddShard(shard))
+	repo := pclient.NewRepo("test")
+	now := prototime.TimeToTimestamp(time.Now())
+	require.NoError(t, driver.CreateRepo(repo, now, nil, false, shardSet))
+	require.NoError(t, driver.StartCommit(repo, "commit1", "", "", now, nil, nil, false, shardSet))
+	file := pclient.NewFile("test", "commit1", "foo")
+	require.NoError(t, driver.PutFile(file, "", pfsclient.Delimiter_LINE, drive.PutFileOptions{}, shard, strings.NewReader("foo\nbar\n")))
+	require.NoError(t, driver.FinishCommit(file.Commit, now, false, drive.FinishCommitOptions{}, shardSet))
+	getFile := func(driver drive.Driver, offset int64, size int64) (string, error) {
+		reader, err := driver.GetFile(file, nil, offset, size, nil, shard, false, "")
+		if err != nil {
+			return "", err
+		}
+		defer reader.Close()
+		data, err := ioutil.ReadAll(reader)
+		return string(data), err
+	}
+	data, err := getFile(driver, 0, math.MaxInt64)
+	require.NoError(t, err)
+	require.Equal(t, "foo\nbar\n", data)
+	// ranges within a block are served from the verified block
+	data, err = getFile(driver, 4, 4)
+	require.NoError(t, err)
+	require.Equal(t, "bar\n", data)
 
-	_, err = client.InspectCommit(repo, "bogus")
-	require.Equal(t, "Commit bogus not found in repo test", err.Error())
+	// corrupt the block on disk without changing its size
+	blockDir := path.Join(root, "block")
+	blockFiles, err := ioutil.ReadDir(blockDir)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(blockFiles))
+	require.NoError(t, ioutil.WriteFile(path.Join(blockDir, blockFiles[0].Name()), []byte("foo\nbaz\n"), 0666))
 
-	_, err = client.ListBranch("blah")
-	require.Equal(t, "Repo blah not found", err.Error())
+	_, err = getFile(driver, 0, math.MaxInt64)
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "corrupt"))
+	_, err = getFile(driver, 0, 4)
+	require.YesError(t, err)
+	require.True(t, strings.Contains(err.Error(), "corrupt"))
 
-	_, err = client.InspectFile(repo, commit1.ID, "file", "", nil)
-	require.Equal(t, fmt.Sprintf("File file not found in repo %v at commit %v", repo, commit1.ID), err.Error())
+	// without verification the corrupt data is returned
+	unverified, err := drive.NewDriver(address)
+	require.NoError(t, err)
+	require.NoError(t, unverified.AddShard(shard))
+	data, err = getFile(unverified, 0, math.MaxInt64)
+	require.NoError(t, err)
+	require.Equal(t, "foo\nbaz\n", data)
+}
 
-	err = client.MakeDirectory(repo, "sdf", "foo")
-	require.Equal(t, "Commit sdf not found in repo test", err.Error())
+func TestScrubBlocks(t *testing.T) {
+	t.Parallel()
+	root := uniqueString("/tmp/pach_test/run")
+	driver, err := drive.NewDriverWithOptions(runBlockServerWithRoot(t, root), drive.Options{
+		ScrubInterval: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	shard := uint64(0)
+	shardSet := map[uint64]bool{shard: true}
+	require.NoError(t, driver.AddShard(shard))
+	repo := pclient.NewRepo("test")
+	now := prototime.TimeToTimestamp(time.Now())
+	require.NoError(t, driver.CreateRepo(repo, now, nil, false, shardSet))
+	require.NoError(t, driver.StartCommit(repo, "commit1", "", "", now, nil, nil, false, shardSet))
+	for _, name := range []string{"foo", "bar"} {
+		require.NoError(t, driver.PutFile(pclient.NewFile("test", "commit1", name), "", pfsclient.Delimiter_LINE,
+			drive.PutFileOptions{}, shard, strings.NewReader(name+"\n")))
+	}
+	require.NoError(t, driver.FinishCommit(pclient.NewCommit("test", "commit1"), now, false, drive.FinishCommitOptions{}, shardSet))
+
+	// waitForCorruptBlocks waits for a scrub to report n corrupt blocks
+	waitForCorruptBlocks := func(n uint64) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			shardStat, err := driver.ShardStats(shard)
+			require.NoError(t, err)
+			require.Equal(t, uint64(2), shardStat.Blocks)
+			if shardStat.CorruptBlocks == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d corrupt blocks but got %d", n, shardStat.CorruptBlocks)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForCorruptBlocks(0)
 
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	// corrupt one of the blocks on disk
+	blockFiles, err := ioutil.ReadDir(path.Join(root, "block"))
+	require.NoError(t, err)
+	require.Equal(t, 2, len(blockFiles))
+	blockPath := path.Join(root, "block", blockFiles[0].Name())
+	content, err := ioutil.ReadFile(blockPath)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(blockPath, []byte("baz\n"), 0666))
+	waitForCorruptBlocks(1)
 
-	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit1.ID, "file", 0, 0, "", nil, &buffer))
-	require.Equal(t, "foo\n", buffer.String())
-	err = client.GetFile(repo, "aninvalidcommitid", "file", 0, 0, "", nil, &buffer)
-	require.YesError(t, err)
+	// a missing block is reported too, and doesn't stop the scrub from
+	// checking the other block
+	otherBlockPath := path.Join(root, "block", blockFiles[1].Name())
+	otherContent, err := ioutil.ReadFile(otherBlockPath)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(otherBlockPath))
+	waitForCorruptBlocks(2)
 
-	require.Equal(t, fmt.Sprintf("Commit %v not found in repo %v", "aninvalidcommitid", repo), err.Error())
+	// the blocks stop being reported once they're intact again
+	require.NoError(t, ioutil.WriteFile(blockPath, content, 0666))
+	require.NoError(t, ioutil.WriteFile(otherBlockPath, otherContent, 0666))
+	waitForCorruptBlocks(0)
 }
 
-func TestATonOfPuts(t *testing.T) {
+func TestDeltaPuts(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
-
-	repo := "test"
-	require.NoError(t, client.CreateRepo(repo))
-
-	commit1, err := client.StartCommit(repo, "", "")
+	root := uniqueString("/tmp/pach_test/run")
+	driver, err := drive.NewDriverWithOptions(runBlockServerWithRoot(t, root), drive.Options{
+		DeltaPuts: true,
+	})
 	require.NoError(t, err)
+	shard := uint64(0)
+	shardSet := map[uint64]bool{shard: true}
+	require.NoError(t, driver.AddShard(shard))
+	repo := pclient.NewRepo("test")
+	now := prototime.TimeToTimestamp(time.Now())
+	require.NoError(t, driver.CreateRepo(repo, now, nil, false, shardSet))
 
-	rawMessage := `{
-		"level":"debug",
-		"message":{
-			"thing":"foo"
-		},
-		"timing":[1,3,34,6,7]
-	}`
-	numObjs := 5000
-	var expectedOutput []byte
-	for i := 0; i < numObjs; i++ {
-		_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(rawMessage))
+	// storedBytes returns the number of bytes on the block server
+	storedBytes := func() int64 {
+		blockFiles, err := ioutil.ReadDir(path.Join(root, "block"))
 		require.NoError(t, err)
-		expectedOutput = append(expectedOutput, []byte(rawMessage)...)
+		var result int64
+		for _, blockFile := range blockFiles {
+			result += blockFile.Size()
+		}
+		return result
 	}
-
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
-
-	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
-	require.Equal(t, string(expectedOutput), buffer.String())
+	var lines []string
+	for i := 0; i < 200000; i++ {
+		lines = append(lines, fmt.Sprintf("line %06d\n", i))
+	}
+	content := strings.Join(lines, "")
+	commitID := "commit0"
+	// put deletes the file and puts content, in a new commit
+	put := func(content string) {
+		parentID := commitID
+		commitID = uniqueString("commit")
+		require.NoError(t, driver.StartCommit(repo, commitID, parentID, "", now, nil, nil, false, shardSet))
+		file := pclient.NewFile("test", commitID, "file")
+		if parentID != "commit0" {
+			require.NoError(t, driver.DeleteFile(file, shard, false, ""))
+		}
+		require.NoError(t, driver.PutFile(file, "", pfsclient.Delimiter_LINE, drive.PutFileOptions{}, shard, strings.NewReader(content)))
+		require.NoError(t, driver.FinishCommit(pclient.NewCommit("test", commitID), now, false, drive.FinishCommitOptions{}, shardSet))
+		reader, err := driver.GetFile(file, nil, 0, math.MaxInt64, nil, shard, false, "")
+		require.NoError(t, err)
+		var buffer bytes.Buffer
+		_, err = io.Copy(&buffer, reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		require.Equal(t, content, buffer.String())
+	}
+	require.NoError(t, driver.StartCommit(repo, commitID, "", "", now, nil, nil, false, shardSet))
+	require.NoError(t, driver.FinishCommit(pclient.NewCommit("test", commitID), now, false, drive.FinishCommitOptions{}, shardSet))
+	put(content)
+	stored := storedBytes()
+	require.True(t, stored >= int64(len(content)))
+
+	// a minor edit only stores the chunks around it, not the whole file
+	lines[100000] = "LINE 100000\n"
+	lines[150000] = "LINE 150000\n"
+	content = strings.Join(lines, "")
+	put(content)
+	require.True(t, storedBytes()-stored < int64(len(content))/10)
+	stored = storedBytes()
+
+	// so does appending to the end of the file
+	content += "more\n"
+	put(content)
+	require.True(t, storedBytes()-stored < int64(len(content))/10)
+	stored = storedBytes()
+
+	// an edit that shifts the content stores everything after it
+	content = "first\n" + content
+	put(content)
+	require.True(t, storedBytes()-stored >= int64(len(content))/2)
 }
 
-func TestPutFileWithJSONDelimiter(t *testing.T) {
+func TestVerifyDiffsAnnotations(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	client, server := getClientAndServerWithOptions(t, drive.Options{VerifyDiffs: true})
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
-	commit1, err := client.StartCommit(repo, "", "")
+	commit, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	annotations := make(map[string]string)
+	for i := 0; i < 16; i++ {
+		annotations[fmt.Sprintf("key%d", i)] = fmt.Sprintf("value%d", i)
+	}
+	require.NoError(t, client.FinishCommitWithAnnotations(repo, commit.ID, annotations))
 
-	rawMessage := `{
-		"level":"debug",
-		"timestamp":"345",
-		"message":{
-			"thing":"foo"
-		},
-		"timing":[1,3,34,6,7]
-	}`
-
-	var expectedOutput []byte
-	for !(len(expectedOutput) > 9*1024*1024) {
-		expectedOutput = append(expectedOutput, []byte(rawMessage)...)
+	// maps are marshalled in a random order, reloading the diffs a few
+	// times would catch a checksum that depends on it
+	for i := 0; i < 3; i++ {
+		restartServer(server, t)
 	}
-	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_JSON, bytes.NewReader(expectedOutput))
+	commitInfo, err := client.InspectCommit(repo, commit.ID)
 	require.NoError(t, err)
-	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	require.Equal(t, annotations, commitInfo.Annotations)
+}
 
-	// Make sure all the content is there
-	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
-	require.Equal(t, len(expectedOutput), buffer.Len())
-	require.Equal(t, string(expectedOutput), buffer.String())
+func TestVerifyDiffs(t *testing.T) {
+	t.Parallel()
+	client, server := getClientAndServerWithOptions(t, drive.Options{VerifyDiffs: true})
 
-	// Now verify that each block contains only valid JSON objects
-	bigModulus := 10 // Make it big to make it less likely that I return both blocks together
-	for b := 0; b < bigModulus; b++ {
-		blockFilter := &pfsclient.Shard{
-			BlockNumber:  uint64(b),
-			BlockModulus: uint64(bigModulus),
-		}
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	commit, err := client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.PutFileWithXattrs(repo, commit.ID, "dir/foo", map[string]string{"user.a": "1"}, strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit.ID))
 
-		buffer.Reset()
-		require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", blockFilter, &buffer))
+	// untouched diffs match their checksums
+	restartServer(server, t)
 
-		// If any single block returns content of size equal to the total, we
-		// got a block collision and we're not testing anything
-		require.NotEqual(t, buffer.Len(), len(expectedOutput))
+	// dump the shard foo is in, the diffs are loaded from disk so they have
+	// checksums
+	shard := pfsserver.NewHasher(shards, 1).HashFile(pclient.NewFile(repo, commit.ID, "dir/foo"))
+	diffInfos, err := server[0].driver.DumpShard(shard, nil)
+	require.NoError(t, err)
+	var fooDiffInfo *pfsclient.DiffInfo
+	for _, diffInfo := range diffInfos {
+		require.True(t, diffInfo.Checksum != "")
+		if diffInfo.Diff.Commit.ID == commit.ID {
+			fooDiffInfo = diffInfo
+		}
+	}
+	require.True(t, fooDiffInfo != nil)
 
-		var value json.RawMessage
-		decoder := json.NewDecoder(&buffer)
-		for {
-			err = decoder.Decode(&value)
-			if err != nil {
-				if err == io.EOF {
-					break
-				} else {
-					require.NoError(t, err)
-				}
-			}
-			require.Equal(t, rawMessage, string(value))
+	loadShard := func(diffInfos []*pfsclient.DiffInfo) error {
+		blockAPIServer, err := NewLocalBlockAPIServer(uniqueString("/tmp/pach_test/run"))
+		require.NoError(t, err)
+		for _, diffInfo := range diffInfos {
+			_, err := blockAPIServer.CreateDiff(context.Background(), diffInfo)
+			require.NoError(t, err)
 		}
+		blockPort := atomic.AddInt32(&port, 1)
+		ready := make(chan bool)
+		go func() {
+			require.NoError(t, protoserver.Serve(
+				func(s *grpc.Server) {
+					pfsclient.RegisterBlockAPIServer(s, blockAPIServer)
+					close(ready)
+				},
+				protoserver.ServeOptions{Version: version.Version},
+				protoserver.ServeEnv{GRPCPort: uint16(blockPort)},
+			))
+		}()
+		<-ready
+		driver, err := drive.NewDriverWithOptions(fmt.Sprintf("localhost:%d", blockPort), drive.Options{VerifyDiffs: true})
+		require.NoError(t, err)
+		return driver.AddShard(shard)
 	}
+	require.NoError(t, loadShard(diffInfos))
+
+	// tamper with foo's content, the checksum no longer matches
+	fooDiffInfo.Appends["dir/foo"].BlockRefs[0].Range.Upper++
+	err = loadShard(diffInfos)
+	require.YesError(t, err)
+	_, ok := err.(*pfsserver.ErrDivergentData)
+	require.True(t, ok)
+	fooDiffInfo.Appends["dir/foo"].BlockRefs[0].Range.Upper--
+	fooDiffInfo.Appends["dir/foo"].Xattrs["user.a"] = "2"
+	_, ok = loadShard(diffInfos).(*pfsserver.ErrDivergentData)
+	require.True(t, ok)
 }
 
-func TestPutFileWithNoDelimiter(t *testing.T) {
+func TestPutFileBackpressure(t *testing.T) {
 	t.Parallel()
-	client, _ := getClientAndServer(t)
+	client, _ := getClientAndServerWithOptions(t, drive.Options{MaxUnflushedBytes: 10})
 
 	repo := "test"
 	require.NoError(t, client.CreateRepo(repo))
-
 	commit1, err := client.StartCommit(repo, "", "")
 	require.NoError(t, err)
-
-	rawMessage := "Some\ncontent\nthat\nshouldnt\nbe\nline\ndelimited.\n"
-
-	// Write a big blob that would normally not fit in a block
-	var expectedOutputA []byte
-	for !(len(expectedOutputA) > 9*1024*1024) {
-		expectedOutputA = append(expectedOutputA, []byte(rawMessage)...)
-	}
-	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_NONE, bytes.NewReader(expectedOutputA))
-	require.NoError(t, err)
-
-	// Write another big block
-	var expectedOutputB []byte
-	for !(len(expectedOutputB) > 18*1024*1024) {
-		expectedOutputB = append(expectedOutputB, []byte(rawMessage)...)
-	}
-	_, err = client.PutFileWithDelimiter(repo, commit1.ID, "foo", pfsclient.Delimiter_NONE, bytes.NewReader(expectedOutputB))
+	// the first write puts us over the limit
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader(strings.Repeat("foo\n", 5)))
 	require.NoError(t, err)
+	// so subsequent writes to the shard are rejected
+	_, err = client.PutFile(repo, commit1.ID, "foo", strings.NewReader("foo\n"))
+	require.YesError(t, err)
+	require.Matches(t, "unflushed bytes", err.Error())
 
-	// Finish the commit so I can read the data
+	// finishing the commit flushes the data and writes are accepted again
 	require.NoError(t, client.FinishCommit(repo, commit1.ID))
+	commit2, err := client.StartCommit(repo, commit1.ID, "")
+	require.NoError(t, err)
+	_, err = client.PutFile(repo, commit2.ID, "foo", strings.NewReader("foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit(repo, commit2.ID))
 
-	// Make sure all the content is there
 	var buffer bytes.Buffer
-	require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", nil, &buffer))
-	require.Equal(t, len(expectedOutputA)+len(expectedOutputB), buffer.Len())
-	require.Equal(t, string(append(expectedOutputA, expectedOutputB...)), buffer.String())
-
-	// Now verify that each block only contains objects of the size we've written
-	bigModulus := 10 // Make it big to make it less likely that I return both blocks together
-	blockLengths := []interface{}{len(expectedOutputA), len(expectedOutputB)}
-	for b := 0; b < bigModulus; b++ {
-		blockFilter := &pfsclient.Shard{
-			BlockNumber:  uint64(b),
-			BlockModulus: uint64(bigModulus),
-		}
+	require.NoError(t, client.GetFile(repo, commit2.ID, "foo", 0, 0, "", nil, &buffer))
+	require.Equal(t, strings.Repeat("foo\n", 6), buffer.String())
+}
 
-		buffer.Reset()
-		require.NoError(t, client.GetFile(repo, commit1.ID, "foo", 0, 0, "", blockFilter, &buffer))
+func TestMaxOpenCommitsPerRepo(t *testing.T) {
+	t.Parallel()
+	client, _ := getClientAndServerWithOptions(t, drive.Options{MaxOpenCommitsPerRepo: 3})
 
-		// If any single block returns content of size equal to the total, we
-		// got a block collision and we're not testing anything
-		require.NotEqual(t, buffer.Len(), len(expectedOutputA)+len(expectedOutputB))
-		if buffer.Len() == 0 {
-			continue
-		}
-		require.EqualOneOf(t, blockLengths, buffer.Len())
+	repo := "test"
+	require.NoError(t, client.CreateRepo(repo))
+	var commits []*pfsclient.Commit
+	for i := 0; i < 3; i++ {
+		commit, err := client.StartCommit(repo, "", "")
+		require.NoError(t, err)
+		commits = append(commits, commit)
 	}
+	_, err := client.StartCommit(repo, "", "")
+	require.YesError(t, err)
+	require.Matches(t, "too many open commits", err.Error())
+
+	// the limit is per repo
+	require.NoError(t, client.CreateRepo("other"))
+	commit, err := client.StartCommit("other", "", "")
+	require.NoError(t, err)
+	require.NoError(t, client.FinishCommit("other", commit.ID))
 
+	// finished and cancelled commits don't count
+	require.NoError(t, client.FinishCommit(repo, commits[0].ID))
+	commit, err = client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.StartCommit(repo, "", "")
+	require.YesError(t, err)
+	require.NoError(t, client.CancelCommit(repo, commits[1].ID))
+	_, err = client.StartCommit(repo, "", "")
+	require.NoError(t, err)
+	_, err = client.StartCommit(repo, "", "")
+	require.YesError(t, err)
+
+	// a deleted repo's open commits don't count against a new repo with the
+	// same name
+	require.NoError(t, client.DeleteRepo(repo))
+	require.NoError(t, client.CreateRepo(repo))
+	for i := 0; i < 3; i++ {
+		_, err := client.StartCommit(repo, "", "")
+		require.NoError(t, err)
+	}
 }
 
 func generateRandomString(n int) string {
@@ -1651,7 +5901,7 @@ func getBlockClient(t *testing.T) pfsclient.BlockAPIClient {
 	return pfsclient.NewBlockAPIClient(clientConn)
 }
 
-func runServers(t *testing.T, port int32, apiServer pfsclient.APIServer,
+func runServers(t testing.TB, port int32, apiServer pfsclient.APIServer,
 	internalAPIServer pfsclient.InternalAPIServer, blockAPIServer pfsclient.BlockAPIServer) {
 	ready := make(chan bool)
 	go func() {
@@ -1670,7 +5920,190 @@ func runServers(t *testing.T, port int32, apiServer pfsclient.APIServer,
 	<-ready
 }
 
-func getClientAndServer(t *testing.T) (pclient.APIClient, []*internalAPIServer) {
+// runBlockServer runs a block server on its own and returns its address.
+func runBlockServer(t testing.TB) string {
+	return runBlockServerWithRoot(t, uniqueString("/tmp/pach_test/run"))
+}
+
+// runBlockServerWithRoot is like runBlockServer except that the block
+// server stores its data under root.
+func runBlockServerWithRoot(t testing.TB, root string) string {
+	blockAPIServer, err := NewLocalBlockAPIServer(root)
+	require.NoError(t, err)
+	blockPort := atomic.AddInt32(&port, 1)
+	ready := make(chan bool)
+	go func() {
+		require.NoError(t, protoserver.Serve(
+			func(s *grpc.Server) {
+				pfsclient.RegisterBlockAPIServer(s, blockAPIServer)
+				close(ready)
+			},
+			protoserver.ServeOptions{Version: version.Version},
+			protoserver.ServeEnv{GRPCPort: uint16(blockPort)},
+		))
+	}()
+	<-ready
+	return fmt.Sprintf("localhost:%d", blockPort)
+}
+
+// runObjBlockServer runs a block server backed by objClient on its own and
+// returns a client for it.
+func runObjBlockServer(t testing.TB, objClient obj.Client, options BlockAPIServerOptions) *pclient.APIClient {
+	blockAPIServer, err := NewObjBlockAPIServerWithOptions(uniqueString("/tmp/pach_test/run"), objClient, options)
+	require.NoError(t, err)
+	blockPort := atomic.AddInt32(&port, 1)
+	ready := make(chan bool)
+	go func() {
+		require.NoError(t, protoserver.Serve(
+			func(s *grpc.Server) {
+				pfsclient.RegisterBlockAPIServer(s, blockAPIServer)
+				close(ready)
+			},
+			protoserver.ServeOptions{Version: version.Version},
+			protoserver.ServeEnv{GRPCPort: uint16(blockPort)},
+		))
+	}()
+	<-ready
+	client, err := pclient.NewFromAddress(fmt.Sprintf("localhost:%d", blockPort))
+	require.NoError(t, err)
+	return client
+}
+
+// memObjClient is an in memory obj.Client, writes take latency to simulate
+// a remote object store.
+type memObjClient struct {
+	latency time.Duration
+	// failWrite makes the nth write fail, 0 means writes don't fail
+	failWrite int32
+	writes    int32
+	lock      sync.Mutex
+	objects   map[string][]byte
+}
+
+func newMemObjClient(latency time.Duration) *memObjClient {
+	return &memObjClient{
+		latency: latency,
+		objects: make(map[string][]byte),
+	}
+}
+
+func (c *memObjClient) len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return len(c.objects)
+}
+
+func (c *memObjClient) reset() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.objects = make(map[string][]byte)
+}
+
+type memObjWriter struct {
+	bytes.Buffer
+	client *memObjClient
+	name   string
+}
+
+func (w *memObjWriter) Close() error {
+	time.Sleep(w.client.latency)
+	if atomic.AddInt32(&w.client.writes, 1) == atomic.LoadInt32(&w.client.failWrite) {
+		return fmt.Errorf("injected failure writing %s", w.name)
+	}
+	w.client.lock.Lock()
+	defer w.client.lock.Unlock()
+	// like the real object stores, existing objects are overwritten
+	w.client.objects[w.name] = w.Bytes()
+	return nil
+}
+
+func (c *memObjClient) Writer(name string) (io.WriteCloser, error) {
+	return &memObjWriter{client: c, name: name}, nil
+}
+
+func (c *memObjClient) Reader(name string, offset uint64, size uint64) (io.ReadCloser, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	data, ok := c.objects[name]
+	if !ok {
+		return nil, fmt.Errorf("object %s not found", name)
+	}
+	if offset > uint64(len(data)) {
+		offset = uint64(len(data))
+	}
+	data = data[offset:]
+	if size != 0 && size < uint64(len(data)) {
+		data = data[:size]
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *memObjClient) Delete(name string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if _, ok := c.objects[name]; !ok {
+		return fmt.Errorf("object %s not found", name)
+	}
+	delete(c.objects, name)
+	return nil
+}
+
+func (c *memObjClient) Walk(prefix string, fn func(name string) error) error {
+	c.lock.Lock()
+	var names []string
+	for name := range c.objects {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	c.lock.Unlock()
+	for _, name := range names {
+		if err := fn(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *memObjClient) IsRetryable(err error) bool {
+	return false
+}
+
+func getClientAndServer(t testing.TB) (pclient.APIClient, []*internalAPIServer) {
+	return getClientAndServerWithOptions(t, drive.Options{})
+}
+
+func getClientAndServerWithOptions(t testing.TB, options drive.Options) (pclient.APIClient, []*internalAPIServer) {
+	return getClientAndServerWithDrivers(t, func(address string) (drive.Driver, error) {
+		return drive.NewDriverWithOptions(address, options)
+	})
+}
+
+// getClientAndServerWithDrivers is like getClientAndServer except that each
+// server's driver is created by newDriver, address is the server's block
+// server.
+func getClientAndServerWithDrivers(t testing.TB, newDriver func(address string) (drive.Driver, error)) (pclient.APIClient, []*internalAPIServer) {
+	return getClientAndServerWithDriversAndAPIOptions(t, newDriver, APIServerOptions{}, InternalAPIServerOptions{})
+}
+
+// getClientAndServerWithAPIOptions is like getClientAndServer except that
+// the API servers are configured with options.
+func getClientAndServerWithAPIOptions(t testing.TB, options APIServerOptions) (pclient.APIClient, []*internalAPIServer) {
+	return getClientAndServerWithDriversAndAPIOptions(t, func(address string) (drive.Driver, error) {
+		return drive.NewDriver(address)
+	}, options, InternalAPIServerOptions{})
+}
+
+// getClientAndServerWithInternalAPIOptions is like getClientAndServer except
+// that the internal API servers are configured with options.
+func getClientAndServerWithInternalAPIOptions(t testing.TB, options InternalAPIServerOptions) (pclient.APIClient, []*internalAPIServer) {
+	return getClientAndServerWithDriversAndAPIOptions(t, func(address string) (drive.Driver, error) {
+		return drive.NewDriver(address)
+	}, APIServerOptions{}, options)
+}
+
+func getClientAndServerWithDriversAndAPIOptions(t testing.TB, newDriver func(address string) (drive.Driver, error),
+	apiOptions APIServerOptions, internalAPIOptions InternalAPIServerOptions) (pclient.APIClient, []*internalAPIServer) {
 	root := uniqueString("/tmp/pach_test/run")
 	t.Logf("root %s", root)
 	var ports []int32
@@ -1685,14 +6118,14 @@ func getClientAndServer(t *testing.T) (pclient.APIClient, []*internalAPIServer)
 	var internalAPIServers []*internalAPIServer
 	for i, port := range ports {
 		address := addresses[i]
-		driver, err := drive.NewDriver(address)
+		driver, err := newDriver(address)
 		require.NoError(t, err)
 		blockAPIServer, err := NewLocalBlockAPIServer(root)
 		require.NoError(t, err)
 		hasher := pfsserver.NewHasher(shards, 1)
 		dialer := grpcutil.NewDialer(grpc.WithInsecure())
-		apiServer := NewAPIServer(hasher, shard.NewRouter(sharder, dialer, address))
-		internalAPIServer := newInternalAPIServer(hasher, shard.NewRouter(sharder, dialer, address), driver)
+		apiServer := NewAPIServerWithOptions(hasher, shard.NewRouter(sharder, dialer, address), apiOptions)
+		internalAPIServer := newInternalAPIServer(hasher, shard.NewRouter(sharder, dialer, address), driver, internalAPIOptions)
 		internalAPIServers = append(internalAPIServers, internalAPIServer)
 		runServers(t, port, apiServer, internalAPIServer, blockAPIServer)
 		for i := 0; i < shards; i++ {
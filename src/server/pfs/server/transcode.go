@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// transcodeChunkSize is how much content a transcoding reader reads at a
+// time.
+const transcodeChunkSize = 64 * 1024
+
+// ErrInvalidEncoding is returned by Encoding.Decode for content that isn't
+// valid in the encoding, GetFile reports where in the content it is.
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// Encoding converts text between a character encoding and UTF-8.
+type Encoding interface {
+	// Decode appends the UTF-8 for the characters in src to dst and returns
+	// it along with the number of bytes of src it used. A character that's
+	// cut off at the end of src is left for the next call unless atEOF is
+	// set. If src contains an invalid character, Decode stops before it and
+	// returns ErrInvalidEncoding along with the bytes before it.
+	Decode(dst []byte, src []byte, atEOF bool) ([]byte, int, error)
+	// Encode appends the encoding of src, which is valid UTF-8 made of
+	// whole characters, to dst. It returns an error for characters that
+	// can't be encoded.
+	Encode(dst []byte, src []byte) ([]byte, error)
+}
+
+var (
+	encodingsLock sync.RWMutex
+	encodings     = map[string]Encoding{
+		"utf-8":      utf8Encoding{},
+		"iso-8859-1": latin1Encoding{},
+		"latin1":     latin1Encoding{},
+		"utf-16le":   utf16Encoding{binary.LittleEndian},
+		"utf-16be":   utf16Encoding{binary.BigEndian},
+	}
+)
+
+// RegisterEncoding makes GetFile able to transcode content from and to the
+// encoding called name, names aren't case sensitive. It replaces any
+// encoding already registered with name.
+func RegisterEncoding(name string, encoding Encoding) {
+	encodingsLock.Lock()
+	defer encodingsLock.Unlock()
+	encodings[strings.ToLower(name)] = encoding
+}
+
+func getEncoding(name string) (Encoding, error) {
+	encodingsLock.RLock()
+	defer encodingsLock.RUnlock()
+	encoding, ok := encodings[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported encoding %s", name)
+	}
+	return encoding, nil
+}
+
+// transcodingReader reads content in one encoding as another. Characters
+// can be split across the reads from the underlying reader, the bytes of a
+// split character are kept until the rest of it has been read.
+type transcodingReader struct {
+	reader   io.Reader
+	from     Encoding
+	fromName string
+	to       Encoding
+	toName   string
+	buffer   []byte
+	// pending is the start of a character that's been read but not decoded
+	pending []byte
+	// offset is where pending starts in the content
+	offset int64
+	// out is transcoded content that hasn't been returned yet
+	out []byte
+	eof bool
+}
+
+func newTranscodingReader(reader io.Reader, fromName string, toName string) (*transcodingReader, error) {
+	from, err := getEncoding(fromName)
+	if err != nil {
+		return nil, err
+	}
+	to, err := getEncoding(toName)
+	if err != nil {
+		return nil, err
+	}
+	return &transcodingReader{
+		reader:   reader,
+		from:     from,
+		fromName: fromName,
+		to:       to,
+		toName:   toName,
+		buffer:   make([]byte, transcodeChunkSize),
+	}, nil
+}
+
+func (r *transcodingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		n, err := r.reader.Read(r.buffer)
+		if err == io.EOF {
+			r.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		src := append(r.pending, r.buffer[:n]...)
+		decoded, used, err := r.from.Decode(nil, src, r.eof)
+		if err == ErrInvalidEncoding {
+			return 0, fmt.Errorf("content isn't valid %s at byte %d", r.fromName, r.offset+int64(used))
+		}
+		if err != nil {
+			return 0, err
+		}
+		r.pending = append([]byte(nil), src[used:]...)
+		r.offset += int64(used)
+		if r.out, err = r.to.Encode(nil, decoded); err != nil {
+			return 0, fmt.Errorf("content can't be encoded as %s: %s", r.toName, err.Error())
+		}
+	}
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+type utf8Encoding struct{}
+
+func (utf8Encoding) Decode(dst []byte, src []byte, atEOF bool) ([]byte, int, error) {
+	i := 0
+	for i < len(src) {
+		r, size := utf8.DecodeRune(src[i:])
+		if r == utf8.RuneError && size <= 1 {
+			if !atEOF && !utf8.FullRune(src[i:]) {
+				break
+			}
+			return append(dst, src[:i]...), i, ErrInvalidEncoding
+		}
+		i += size
+	}
+	return append(dst, src[:i]...), i, nil
+}
+
+func (utf8Encoding) Encode(dst []byte, src []byte) ([]byte, error) {
+	return append(dst, src...), nil
+}
+
+type latin1Encoding struct{}
+
+func (latin1Encoding) Decode(dst []byte, src []byte, atEOF bool) ([]byte, int, error) {
+	buffer := make([]byte, utf8.UTFMax)
+	for _, b := range src {
+		n := utf8.EncodeRune(buffer, rune(b))
+		dst = append(dst, buffer[:n]...)
+	}
+	return dst, len(src), nil
+}
+
+func (latin1Encoding) Encode(dst []byte, src []byte) ([]byte, error) {
+	for _, r := range string(src) {
+		if r > 0xFF {
+			return nil, fmt.Errorf("%q isn't in ISO-8859-1", r)
+		}
+		dst = append(dst, byte(r))
+	}
+	return dst, nil
+}
+
+type utf16Encoding struct {
+	order binary.ByteOrder
+}
+
+func (e utf16Encoding) Decode(dst []byte, src []byte, atEOF bool) ([]byte, int, error) {
+	buffer := make([]byte, utf8.UTFMax)
+	i := 0
+	for i < len(src) {
+		if len(src)-i < 2 {
+			break
+		}
+		r := rune(e.order.Uint16(src[i:]))
+		size := 2
+		if utf16.IsSurrogate(r) {
+			if len(src)-i < 4 {
+				break
+			}
+			r = utf16.DecodeRune(r, rune(e.order.Uint16(src[i+2:])))
+			if r == utf8.RuneError {
+				return dst, i, ErrInvalidEncoding
+			}
+			size = 4
+		}
+		n := utf8.EncodeRune(buffer, r)
+		dst = append(dst, buffer[:n]...)
+		i += size
+	}
+	if atEOF && i < len(src) {
+		// the content ends part way through a character
+		return dst, i, ErrInvalidEncoding
+	}
+	return dst, i, nil
+}
+
+func (e utf16Encoding) Encode(dst []byte, src []byte) ([]byte, error) {
+	unit := make([]byte, 2)
+	for _, r := range string(src) {
+		for _, u := range utf16.Encode([]rune{r}) {
+			e.order.PutUint16(unit, u)
+			dst = append(dst, unit...)
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+const (
+	urlFetchDialTimeout     = 30 * time.Second
+	urlFetchTLSTimeout      = 10 * time.Second
+	urlFetchResponseTimeout = time.Minute
+	urlFetchMaxRedirects    = 10
+)
+
+// privateNetworks are the networks that a urlFetcher that denies private
+// addresses won't connect to.
+var privateNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+)
+
+// urlFetcher fetches the URLs given to PutFileURL. Connecting and waiting
+// for the response headers time out, reading the body doesn't since it may
+// be arbitrarily big, it's bounded by the request's context instead.
+type urlFetcher struct {
+	client *http.Client
+}
+
+// newURLFetcher returns a urlFetcher. If denyPrivate is set it refuses to
+// connect to loopback, private and link local addresses, so that the server
+// can't be used to reach services that only it can see. The addresses are
+// checked when they're dialed, after the host has been resolved, so
+// redirects and DNS can't be used to get around the check. Proxies from the
+// environment aren't used when private addresses are denied, the check would
+// only see the proxy's address and the proxy would reach the target for us.
+func newURLFetcher(denyPrivate bool) *urlFetcher {
+	dialer := &net.Dialer{Timeout: urlFetchDialTimeout}
+	dial := dialer.Dial
+	proxy := http.ProxyFromEnvironment
+	if denyPrivate {
+		proxy = nil
+		dial = func(network string, address string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+			ips, err := net.LookupIP(host)
+			if err != nil {
+				return nil, err
+			}
+			for _, ip := range ips {
+				if isPrivateIP(ip) {
+					return nil, fmt.Errorf("%s resolves to %s which is a private address", host, ip)
+				}
+			}
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("%s doesn't resolve to any addresses", host)
+			}
+			// we dial the address we checked, dialing host would resolve it
+			// again
+			return dialer.Dial(network, net.JoinHostPort(ips[0].String(), port))
+		}
+	}
+	return &urlFetcher{
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:                 proxy,
+				Dial:                  dial,
+				TLSHandshakeTimeout:   urlFetchTLSTimeout,
+				ResponseHeaderTimeout: urlFetchResponseTimeout,
+			},
+			CheckRedirect: func(request *http.Request, via []*http.Request) error {
+				if len(via) >= urlFetchMaxRedirects {
+					return fmt.Errorf("stopped after %d redirects", urlFetchMaxRedirects)
+				}
+				return checkURLScheme(request.URL)
+			},
+		},
+	}
+}
+
+// get sends a GET for rawURL with headers, the request is cancelled when ctx
+// is done.
+func (f *urlFetcher) get(ctx context.Context, rawURL string, headers map[string]string) (*http.Response, error) {
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkURLScheme(parsedURL); err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	return f.client.Do(request.WithContext(ctx))
+}
+
+func checkURLScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%s has scheme %q, only http and https are supported", u.String(), u.Scheme)
+	}
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	var result []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		result = append(result, network)
+	}
+	return result
+}
@@ -0,0 +1,21 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// setXattrs sets xattrs on a local file, names that aren't in the user
+// namespace are put in it since the others are reserved for the system.
+func setXattrs(localPath string, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		if !strings.HasPrefix(name, "user.") {
+			name = "user." + name
+		}
+		if err := syscall.Setxattr(localPath, name, []byte(value), 0); err != nil {
+			return fmt.Errorf("error setting xattr %s on %s: %s", name, localPath, err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+// +build !linux
+
+package server
+
+import (
+	"fmt"
+)
+
+// setXattrs fails if there are any xattrs, they can only be set on Linux.
+func setXattrs(localPath string, xattrs map[string]string) error {
+	if len(xattrs) > 0 {
+		return fmt.Errorf("can't set xattrs on %s, xattrs are only supported on linux", localPath)
+	}
+	return nil
+}
@@ -30,6 +30,29 @@ func (d *DAG) NewNode(id string, parents []string) {
 	}
 }
 
+// RemoveNode removes a node from the DAG, only leaves should be removed
+// otherwise their children will become ghosts.
+func (d *DAG) RemoveNode(id string) {
+	for _, parentID := range d.parents[id] {
+		var children []string
+		for _, childID := range d.children[parentID] {
+			if childID != id {
+				children = append(children, childID)
+			}
+		}
+		if len(children) == 0 {
+			delete(d.children, parentID)
+			if _, ok := d.parents[parentID]; ok {
+				d.leaves[parentID] = true
+			}
+		} else {
+			d.children[parentID] = children
+		}
+	}
+	delete(d.parents, id)
+	delete(d.leaves, id)
+}
+
 // Sorted returns all nodes in a topologically sorted order
 func (d *DAG) Sorted() []string {
 	seen := make(map[string]bool)
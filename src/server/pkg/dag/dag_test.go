@@ -83,3 +83,18 @@ func TestGhosts(t *testing.T) {
 		d.Ghosts(),
 	)
 }
+
+func TestRemoveNode(t *testing.T) {
+	d := NewDAG(map[string][]string{
+		"1": {},
+		"2": {"1"},
+		"3": {"1"},
+	})
+	d.RemoveNode("3")
+	require.Equal(t, []string{"2"}, d.Leaves())
+	require.Equal(t, []string{"1", "2"}, d.Sorted())
+	d.RemoveNode("2")
+	require.Equal(t, []string{"1"}, d.Leaves())
+	require.Equal(t, []string{"1"}, d.Sorted())
+	require.Equal(t, 0, len(d.Ghosts()))
+}